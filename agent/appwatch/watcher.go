@@ -0,0 +1,292 @@
+package appwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"domanscy.group/parental-controls/agent/procfs"
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/apprules"
+)
+
+// DefaultInterval is how often /proc is scanned. Linux has no portable notification about started processes
+// for unprivileged programs, the proc connector needs CAP_NET_ADMIN, so the watcher polls. A blocked game
+// runs for up to one interval.
+const DefaultInterval = 2 * time.Second
+
+// maxScanGap caps the time counted between two scans, the machine may have been suspended in between.
+const maxScanGap = time.Minute
+
+const (
+	ReasonAppRule   = "app_rule"
+	ReasonTimeLimit = "time_limit"
+)
+
+type ProcFS interface {
+	Pids() ([]int, error)
+	Process(pid int) (*procfs.Process, error)
+}
+
+// Reporter queues activity events for the server, see uploader.Queue.
+type Reporter interface {
+	Add(eventType activity.Type, payload any, occurredAt time.Time) error
+}
+
+type tracked struct {
+	startTime uint64
+	app       string
+	// rule is the limit rule counting the time of the process, nil for processes the watcher ignores.
+	rule *apprules.Model
+	// decided is false until the process was matched against the current rules.
+	decided bool
+}
+
+// Watcher enforces app rules: it terminates blocked processes and those over their daily limit and
+// reports app_start and app_stop of limited apps, so the server counts them into the screen time.
+type Watcher struct {
+	procFS   ProcFS
+	kill     func(pid int) error
+	reporter Reporter
+	location *time.Location
+
+	mu        sync.Mutex
+	rules     []apprules.Model
+	processes map[int]*tracked
+	// usage is the time limited apps ran today, by the id of their rule.
+	usage    map[int]time.Duration
+	day      time.Time
+	lastScan time.Time
+}
+
+func New(procFS ProcFS, kill func(pid int) error, reporter Reporter, location *time.Location) *Watcher {
+	return &Watcher{
+		procFS:    procFS,
+		kill:      kill,
+		reporter:  reporter,
+		location:  location,
+		processes: map[int]*tracked{},
+		usage:     map[int]time.Duration{},
+	}
+}
+
+// SetRules replaces the rules, running processes are matched against them again on the next scan.
+func (watcher *Watcher) SetRules(rules []apprules.Model) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	watcher.rules = rules
+
+	for _, process := range watcher.processes {
+		process.decided = false
+	}
+}
+
+// Rules returns the rules the watcher enforces.
+func (watcher *Watcher) Rules() []apprules.Model {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	return slices.Clone(watcher.rules)
+}
+
+// Usage returns how long apps limited by the rule ran today.
+func (watcher *Watcher) Usage(ruleId int) time.Duration {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	return watcher.usage[ruleId]
+}
+
+func (watcher *Watcher) startOfDay(now time.Time) time.Time {
+	year, month, day := now.In(watcher.location).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, watcher.location)
+}
+
+// countUsage adds the time since the last scan to every limit rule with a running process,
+// a rule is counted once however many processes of the app run.
+func (watcher *Watcher) countUsage(now time.Time) {
+	day := watcher.startOfDay(now)
+	if !day.Equal(watcher.day) {
+		watcher.day = day
+		watcher.usage = map[int]time.Duration{}
+
+		if watcher.lastScan.Before(day) {
+			watcher.lastScan = day
+		}
+	}
+
+	elapsed := min(now.Sub(watcher.lastScan), maxScanGap)
+	if watcher.lastScan.IsZero() || elapsed <= 0 {
+		return
+	}
+
+	counted := map[int]bool{}
+
+	for _, process := range watcher.processes {
+		if process.rule == nil || counted[process.rule.Id] {
+			continue
+		}
+
+		counted[process.rule.Id] = true
+		watcher.usage[process.rule.Id] += elapsed
+	}
+}
+
+func (watcher *Watcher) overLimit(rule *apprules.Model) bool {
+	return watcher.usage[rule.Id] >= time.Duration(rule.DailyLimitMinutes)*time.Minute
+}
+
+// appName prefers the executable name, the kernel truncates the name of the process to 15 characters.
+func appName(process *procfs.Process) string {
+	if process.Exe != "" {
+		return path.Base(process.Exe)
+	}
+
+	return process.Name
+}
+
+func (watcher *Watcher) stop(process *tracked, now time.Time) error {
+	if process.rule == nil {
+		return nil
+	}
+
+	process.rule = nil
+
+	return watcher.reporter.Add(activity.TypeAppStop, activity.AppPayload{App: process.app}, now)
+}
+
+func (watcher *Watcher) terminate(pid int, app string, reason string, now time.Time) error {
+	err := watcher.kill(pid)
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to terminate process %d (%s): %w", pid, app, err)
+	}
+
+	return watcher.reporter.Add(activity.TypeBlock, activity.BlockPayload{App: app, Reason: reason}, now)
+}
+
+// decide matches a new process, or one seen before the rules changed, and acts on the rule.
+func (watcher *Watcher) decide(process *procfs.Process, previous *tracked, now time.Time) (*tracked, error) {
+	var err error
+
+	if previous != nil && previous.startTime != process.StartTime {
+		// The pid was reused by another process.
+		err = watcher.stop(previous, now)
+		previous = nil
+	}
+
+	current := &tracked{startTime: process.StartTime, app: appName(process), decided: true}
+
+	rule, decideErr := apprules.Decide(watcher.rules, &process.Process)
+	err = errors.Join(err, decideErr)
+
+	var limitRule *apprules.Model
+	if rule != nil && rule.Action == apprules.ActionLimit {
+		ruleCopy := *rule
+		limitRule = &ruleCopy
+	}
+
+	if previous != nil && previous.rule != nil {
+		if limitRule != nil && previous.rule.Id == limitRule.Id {
+			// Still limited by the same rule, app_start was already reported.
+			current.rule = limitRule
+			return current, err
+		}
+
+		err = errors.Join(err, watcher.stop(previous, now))
+	}
+
+	switch {
+	case rule == nil || rule.Action == apprules.ActionAllow:
+	case rule.Action == apprules.ActionBlock:
+		err = errors.Join(err, watcher.terminate(process.Pid, current.app, ReasonAppRule, now))
+	case watcher.overLimit(limitRule):
+		err = errors.Join(err, watcher.terminate(process.Pid, current.app, ReasonTimeLimit, now))
+	default:
+		current.rule = limitRule
+		err = errors.Join(err, watcher.reporter.Add(activity.TypeAppStart, activity.AppPayload{App: current.app}, now))
+	}
+
+	return current, err
+}
+
+// Scan looks at the running processes once, it returns the errors of single processes joined.
+// Every process is read on every scan, its start time tells a reused pid apart.
+func (watcher *Watcher) Scan(now time.Time) error {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	watcher.countUsage(now)
+	watcher.lastScan = now
+
+	pids, err := watcher.procFS.Pids()
+	if err != nil {
+		return err
+	}
+
+	running := make(map[int]bool, len(pids))
+	var errs error
+
+	for _, pid := range pids {
+		process, err := watcher.procFS.Process(pid)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		running[pid] = true
+
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+
+		previous := watcher.processes[pid]
+		if previous != nil && previous.decided && previous.startTime == process.StartTime {
+			continue
+		}
+
+		current, err := watcher.decide(process, previous, now)
+		errs = errors.Join(errs, err)
+		watcher.processes[pid] = current
+	}
+
+	for pid, process := range watcher.processes {
+		if !running[pid] {
+			errs = errors.Join(errs, watcher.stop(process, now))
+			delete(watcher.processes, pid)
+			continue
+		}
+
+		if process.rule != nil && watcher.overLimit(process.rule) {
+			errs = errors.Join(errs, watcher.stop(process, now))
+			errs = errors.Join(errs, watcher.terminate(pid, process.app, ReasonTimeLimit, now))
+		}
+	}
+
+	return errs
+}
+
+// Run scans every interval until ctx is done, now is the clock the usage is counted by.
+func (watcher *Watcher) Run(ctx context.Context, interval time.Duration, now func() time.Time) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := watcher.Scan(now())
+		if err != nil {
+			log.Printf("error occured while trying to scan processes: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
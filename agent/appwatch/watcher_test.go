@@ -0,0 +1,244 @@
+package appwatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/agent/procfs"
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/apprules"
+)
+
+// fakeProcFS is a process table in memory, killing a process removes it.
+type fakeProcFS struct {
+	processes map[int]*procfs.Process
+	killed    []int
+}
+
+func (fs *fakeProcFS) start(pid int, exe string, startTime uint64) {
+	fs.processes[pid] = &procfs.Process{
+		Process:   apprules.Process{Pid: pid, Name: path.Base(exe), Exe: exe},
+		StartTime: startTime,
+	}
+}
+
+func (fs *fakeProcFS) Pids() ([]int, error) {
+	pids := []int{}
+	for pid := range fs.processes {
+		pids = append(pids, pid)
+	}
+
+	slices.Sort(pids)
+
+	return pids, nil
+}
+
+func (fs *fakeProcFS) Process(pid int) (*procfs.Process, error) {
+	process, exists := fs.processes[pid]
+	if !exists {
+		return nil, fmt.Errorf("process %d: %w", pid, os.ErrNotExist)
+	}
+
+	return process, nil
+}
+
+func (fs *fakeProcFS) kill(pid int) error {
+	if _, exists := fs.processes[pid]; !exists {
+		return os.ErrProcessDone
+	}
+
+	delete(fs.processes, pid)
+	fs.killed = append(fs.killed, pid)
+
+	return nil
+}
+
+type reportedEvent struct {
+	eventType activity.Type
+	payload   string
+}
+
+type fakeReporter struct {
+	events []reportedEvent
+}
+
+func (reporter *fakeReporter) Add(eventType activity.Type, payload any, _ time.Time) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	reporter.events = append(reporter.events, reportedEvent{eventType: eventType, payload: string(encoded)})
+
+	return nil
+}
+
+func (reporter *fakeReporter) take() []reportedEvent {
+	events := reporter.events
+	reporter.events = nil
+
+	return events
+}
+
+func TestWatcher(t *testing.T) {
+	newWatcher := func() (*Watcher, *fakeProcFS, *fakeReporter) {
+		fs := &fakeProcFS{processes: map[int]*procfs.Process{}}
+		reporter := &fakeReporter{}
+
+		watcher := New(fs, fs.kill, reporter, time.UTC)
+		watcher.SetRules([]apprules.Model{
+			{Id: 1, MatchType: apprules.MatchPath, Pattern: "/usr/games/*", Action: apprules.ActionBlock},
+			{Id: 2, MatchType: apprules.MatchName, Pattern: "minecraft*", Action: apprules.ActionLimit, DailyLimitMinutes: 1},
+			{Id: 3, MatchType: apprules.MatchPath, Pattern: "/usr/games/gcompris", Action: apprules.ActionAllow},
+		})
+
+		return watcher, fs, reporter
+	}
+
+	start := time.Date(2024, 9, 1, 16, 0, 0, 0, time.UTC)
+
+	t.Run("terminates blocked processes and reports the attempt", func(t *testing.T) {
+		watcher, fs, reporter := newWatcher()
+
+		fs.start(100, "/usr/bin/firefox", 1)
+		fs.start(101, "/usr/games/supertux2", 2)
+		fs.start(102, "/usr/games/gcompris", 3)
+
+		err := watcher.Scan(start)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(fs.killed, []int{101}) {
+			t.Fatalf("Got killed %v, want [101]", fs.killed)
+		}
+
+		events := reporter.take()
+		if len(events) != 1 || events[0].eventType != activity.TypeBlock || events[0].payload != `{"app":"supertux2","reason":"app_rule"}` {
+			t.Fatalf("Unexpected events: %+v", events)
+		}
+	})
+
+	t.Run("counts limited apps once and terminates them over the limit", func(t *testing.T) {
+		watcher, fs, reporter := newWatcher()
+
+		fs.start(200, "/opt/minecraft/minecraft-launcher", 1)
+		fs.start(201, "/opt/minecraft/minecraft-runtime", 2)
+
+		for i := range 4 {
+			err := watcher.Scan(start.Add(time.Duration(i) * 20 * time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if watcher.Usage(2) != time.Minute {
+			t.Fatalf("Got usage %v, want 1m", watcher.Usage(2))
+		}
+
+		if len(fs.killed) != 2 {
+			t.Fatalf("Got killed %v, want both processes", fs.killed)
+		}
+
+		events := reporter.take()
+		starts, stops, blocks := 0, 0, 0
+		for _, event := range events {
+			switch event.eventType {
+			case activity.TypeAppStart:
+				starts++
+			case activity.TypeAppStop:
+				stops++
+			case activity.TypeBlock:
+				blocks++
+			}
+		}
+
+		if starts != 2 || stops != 2 || blocks != 2 {
+			t.Fatalf("Got %d starts, %d stops and %d blocks, want 2 of each: %+v", starts, stops, blocks, events)
+		}
+
+		fs.start(202, "/opt/minecraft/minecraft-launcher", 3)
+
+		err := watcher.Scan(start.Add(2 * time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		events = reporter.take()
+		if len(events) != 1 || events[0].payload != `{"app":"minecraft-launcher","reason":"time_limit"}` {
+			t.Fatalf("Unexpected events: %+v", events)
+		}
+	})
+
+	t.Run("resets usage at midnight and skips suspended time", func(t *testing.T) {
+		watcher, fs, _ := newWatcher()
+
+		fs.start(300, "/usr/bin/minecraft", 1)
+
+		late := time.Date(2024, 9, 1, 23, 59, 50, 0, time.UTC)
+		for _, now := range []time.Time{late, late.Add(20 * time.Second), late.Add(2 * time.Hour)} {
+			err := watcher.Scan(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// 10s after midnight and at most maxScanGap of the two hours without a scan.
+		if watcher.Usage(2) != 70*time.Second {
+			t.Fatalf("Got usage %v, want 1m10s", watcher.Usage(2))
+		}
+	})
+
+	t.Run("matches running processes again after rules change", func(t *testing.T) {
+		watcher, fs, reporter := newWatcher()
+
+		fs.start(400, "/usr/bin/minecraft", 1)
+
+		err := watcher.Scan(start)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		watcher.SetRules([]apprules.Model{
+			{Id: 4, MatchType: apprules.MatchName, Pattern: "minecraft", Action: apprules.ActionBlock},
+		})
+
+		err = watcher.Scan(start.Add(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		events := reporter.take()
+		if len(events) != 3 || events[0].eventType != activity.TypeAppStart || events[1].eventType != activity.TypeAppStop || events[2].eventType != activity.TypeBlock {
+			t.Fatalf("Unexpected events: %+v", events)
+		}
+	})
+
+	t.Run("tells a reused pid apart", func(t *testing.T) {
+		watcher, fs, reporter := newWatcher()
+
+		fs.start(500, "/usr/bin/minecraft", 1)
+
+		err := watcher.Scan(start)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fs.start(500, "/usr/games/supertux2", 2)
+
+		err = watcher.Scan(start.Add(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		events := reporter.take()
+		if len(events) != 3 || events[1].eventType != activity.TypeAppStop || events[2].payload != `{"app":"supertux2","reason":"app_rule"}` {
+			t.Fatalf("Unexpected events: %+v", events)
+		}
+	})
+}
@@ -0,0 +1,3 @@
+module domanscy.group/parental-controls/agent
+
+go 1.22.5
@@ -0,0 +1,181 @@
+package heartbeat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"domanscy.group/parental-controls/agent/seqfile"
+	"domanscy.group/parental-controls/server/heartbeats"
+)
+
+const seqFileName = "heartbeat_seq"
+
+var ErrStale = errors.New("server already received a newer heartbeat")
+var ErrRejected = errors.New("server rejected the heartbeat")
+
+type TamperEvent struct {
+	Type       heartbeats.TamperType `json:"type"`
+	Detail     string                `json:"detail"`
+	OccurredAt time.Time             `json:"occurredAt"`
+}
+
+type Response struct {
+	PolicyHash      string `json:"policyHash"`
+	IntervalSeconds int    `json:"intervalSeconds"`
+}
+
+// Sender signs heartbeats with the heartbeat key of the device and sends them with the tamper events
+// noticed since the previous one. Tamper events are kept in memory until the server accepts them.
+type Sender struct {
+	Url string
+	// Header is sent with every request, e.g. "Authorization: Device ...".
+	Header     http.Header
+	Key        string
+	Version    string
+	HttpClient *http.Client
+
+	seq          *seqfile.Counter
+	mu           sync.Mutex
+	tamperEvents []TamperEvent
+}
+
+// NewSender sends heartbeats to url, the sequence numbers are persisted in stateDir.
+func NewSender(url string, header http.Header, key string, version string, stateDir string) (*Sender, error) {
+	seq, err := seqfile.Open(filepath.Join(stateDir, seqFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sender{
+		Url:        url,
+		Header:     header,
+		Key:        key,
+		Version:    version,
+		HttpClient: &http.Client{Timeout: 30 * time.Second},
+		seq:        seq,
+	}, nil
+}
+
+// Report queues a tamper event for the next heartbeat, the oldest events are dropped when too many wait.
+func (sender *Sender) Report(tamperType heartbeats.TamperType, detail string, occurredAt time.Time) {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	sender.tamperEvents = append(sender.tamperEvents, TamperEvent{Type: tamperType, Detail: detail, OccurredAt: occurredAt.UTC()})
+
+	if len(sender.tamperEvents) > heartbeats.MaxTamperEvents {
+		sender.tamperEvents = sender.tamperEvents[len(sender.tamperEvents)-heartbeats.MaxTamperEvents:]
+	}
+}
+
+// drop removes the sent events, new ones may have been reported during the request.
+func (sender *Sender) drop(sent int) {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	sender.tamperEvents = sender.tamperEvents[sent:]
+}
+
+// Send sends a heartbeat reporting the hash of the policy the agent enforces.
+func (sender *Sender) Send(ctx context.Context, policyHash string, now time.Time) (Response, error) {
+	sender.mu.Lock()
+	tamperEvents := slices.Clone(sender.tamperEvents)
+	sender.mu.Unlock()
+
+	seq, err := sender.seq.Next()
+	if err != nil {
+		return Response{}, err
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"seq":          seq,
+		"sentAt":       now.UTC(),
+		"agentVersion": sender.Version,
+		"policyHash":   policyHash,
+		"tamperEvents": tamperEvents,
+	})
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to encode the heartbeat: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, sender.Url, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, err
+	}
+
+	for name, values := range sender.Header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(heartbeats.SignatureHeader, heartbeats.Sign(sender.Key, body))
+
+	response, err := sender.HttpClient.Do(request)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to send the heartbeat: %w", err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusConflict:
+		// the uninstall hook reserves numbers from the same file while the agent runs
+		return Response{}, errors.Join(ErrStale, sender.seq.SkipReserved())
+	case http.StatusBadRequest:
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		log.Printf("dropping %d tamper events: %s", len(tamperEvents), message)
+		sender.drop(len(tamperEvents))
+		return Response{}, fmt.Errorf("%w: %s", ErrRejected, message)
+	default:
+		return Response{}, fmt.Errorf("server responded with status %d to the heartbeat", response.StatusCode)
+	}
+
+	sender.drop(len(tamperEvents))
+
+	var decoded Response
+
+	err = json.NewDecoder(response.Body).Decode(&decoded)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to decode the response: %w", err)
+	}
+
+	return decoded, nil
+}
+
+// Run sends a heartbeat every interval the server asks for until ctx is done, dated with now. policyHash returns
+// the hash of the enforced policy and outdated is called when the server knows another one.
+func (sender *Sender) Run(ctx context.Context, now func() time.Time, policyHash func() string, outdated func()) error {
+	interval := heartbeats.Interval
+
+	for {
+		currentPolicyHash := policyHash()
+
+		response, err := sender.Send(ctx, currentPolicyHash, now())
+		if err != nil {
+			log.Printf("error occured while trying to send a heartbeat: %v", err)
+		} else {
+			if response.PolicyHash != currentPolicyHash {
+				outdated()
+			}
+
+			if response.IntervalSeconds > 0 {
+				interval = time.Duration(response.IntervalSeconds) * time.Second
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/agent/seqfile"
+	"domanscy.group/parental-controls/server/heartbeats"
+)
+
+func TestSender(t *testing.T) {
+	now := time.Date(2024, 9, 1, 16, 0, 0, 0, time.UTC)
+
+	type receivedHeartbeat struct {
+		Seq          int64         `json:"seq"`
+		PolicyHash   string        `json:"policyHash"`
+		TamperEvents []TamperEvent `json:"tamperEvents"`
+	}
+
+	var received []receivedHeartbeat
+	status := http.StatusOK
+	lastSeq := int64(0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if r.Header.Get("Authorization") != "Device token" || !heartbeats.VerifySignature("key", body, r.Header.Get(heartbeats.SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var heartbeat receivedHeartbeat
+
+		err = json.Unmarshal(body, &heartbeat)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if heartbeat.Seq <= lastSeq {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		lastSeq = heartbeat.Seq
+		received = append(received, heartbeat)
+
+		err = json.NewEncoder(w).Encode(Response{PolicyHash: "current", IntervalSeconds: 60})
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	stateDir := t.TempDir()
+
+	sender, err := NewSender(server.URL, http.Header{"Authorization": {"Device token"}}, "key", "1.0.0", stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("keeps tamper events until the server accepts them", func(t *testing.T) {
+		sender.Report(heartbeats.TamperClockChanged, "-2h0m0s", now)
+
+		status = http.StatusServiceUnavailable
+		_, err := sender.Send(context.Background(), "current", now)
+		if err == nil {
+			t.Fatal("Expected an error")
+		}
+
+		status = http.StatusOK
+		response, err := sender.Send(context.Background(), "current", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.PolicyHash != "current" || len(received) != 1 || len(received[0].TamperEvents) != 1 || received[0].TamperEvents[0].Detail != "-2h0m0s" {
+			t.Fatalf("Unexpected response %+v and heartbeats %+v", response, received)
+		}
+
+		_, err = sender.Send(context.Background(), "current", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(received) != 2 || len(received[1].TamperEvents) != 0 {
+			t.Fatalf("Got %+v, want the tamper event sent once", received)
+		}
+	})
+
+	t.Run("continues after numbers used by the uninstall hook", func(t *testing.T) {
+		hook, err := NewSender(server.URL, http.Header{"Authorization": {"Device token"}}, "key", "1.0.0", stateDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hook.Report(heartbeats.TamperUninstallAttempt, "", now)
+
+		_, err = hook.Send(context.Background(), "", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sender.Report(heartbeats.TamperClockChanged, "1h0m0s", now)
+
+		_, err = sender.Send(context.Background(), "current", now)
+		if !errors.Is(err, ErrStale) {
+			t.Fatalf("Expected %v, received %v", ErrStale, err)
+		}
+
+		_, err = sender.Send(context.Background(), "current", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		last := received[len(received)-1]
+		if len(last.TamperEvents) != 1 || last.TamperEvents[0].Detail != "1h0m0s" {
+			t.Fatalf("Got %+v, want the tamper event kept over the stale heartbeat", last)
+		}
+	})
+
+	t.Run("persists sequence numbers", func(t *testing.T) {
+		counter, err := seqfile.Open(filepath.Join(stateDir, seqFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seq, err := counter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if seq <= lastSeq {
+			t.Fatalf("Got %d, want more than %d", seq, lastSeq)
+		}
+	})
+}
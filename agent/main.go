@@ -0,0 +1,337 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"domanscy.group/env"
+	"domanscy.group/parental-controls/agent/appwatch"
+	"domanscy.group/parental-controls/agent/heartbeat"
+	"domanscy.group/parental-controls/agent/procfs"
+	"domanscy.group/parental-controls/agent/screentime"
+	"domanscy.group/parental-controls/agent/trustedclock"
+	"domanscy.group/parental-controls/agent/uploader"
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/heartbeats"
+	"domanscy.group/parental-controls/server/push"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// syncRetryInterval is how long the agent waits before fetching the policy again after a failure.
+const syncRetryInterval = time.Minute
+
+// budgetRefreshInterval picks up the screen time used on other devices of the child.
+const budgetRefreshInterval = 5 * time.Minute
+
+// timeTokenRefreshInterval keeps the trusted time anchored to the server while the device is online.
+const timeTokenRefreshInterval = 15 * time.Minute
+
+type agentConfig struct {
+	ServerUrl   string
+	DeviceId    int
+	DeviceToken string
+	// HeartbeatKey signs heartbeats, it is shown to the parent once when the device is created or its key is rotated.
+	HeartbeatKey string
+	StateDir     string
+	// Location must be the timezone of the server reports, days of the screen time budget start at its midnight.
+	Location *time.Location
+	// ChildUid is the account whose sessions count as screen time, -1 for every account.
+	ChildUid int
+	Warnings []time.Duration
+	// Language is the one of the notifications shown to the child.
+	Language i18n.Language
+}
+
+func (cfg *agentConfig) authorizationHeader() http.Header {
+	return http.Header{"Authorization": {"Device " + cfg.DeviceToken}}
+}
+
+func readConfig() (*agentConfig, error) {
+	cfg := &agentConfig{
+		StateDir: "/var/lib/parental-controls",
+		Location: time.Local,
+		ChildUid: -1,
+		Warnings: screentime.DefaultWarnings,
+		Language: i18n.DefaultLanguage,
+	}
+
+	serverUrl, exists, err := env.ParseValidUrlVarWithHttpOrHttpsProtocol("SERVER_URL")
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, errors.New("SERVER_URL is required")
+	}
+
+	cfg.ServerUrl = strings.TrimSuffix(serverUrl.String(), "/")
+
+	deviceId, exists, err := env.ParseIntVar("DEVICE_ID")
+	if err != nil {
+		return nil, err
+	} else if !exists || deviceId <= 0 {
+		return nil, errors.New("DEVICE_ID is required")
+	}
+
+	cfg.DeviceId = deviceId
+
+	deviceToken, exists := env.ParseStringVar("DEVICE_TOKEN")
+	if !exists || deviceToken == "" {
+		return nil, errors.New("DEVICE_TOKEN is required")
+	}
+
+	cfg.DeviceToken = deviceToken
+
+	heartbeatKey, exists := env.ParseStringVar("HEARTBEAT_KEY")
+	if !exists || heartbeatKey == "" {
+		return nil, errors.New("HEARTBEAT_KEY is required")
+	}
+
+	cfg.HeartbeatKey = heartbeatKey
+
+	if stateDir, exists := env.ParseStringVar("STATE_DIR"); exists {
+		cfg.StateDir = stateDir
+	}
+
+	if childUser, exists := env.ParseStringVar("CHILD_USER"); exists {
+		account, err := user.Lookup(childUser)
+		if err != nil {
+			return nil, fmt.Errorf("env 'CHILD_USER' must be an existing user: %w", err)
+		}
+
+		cfg.ChildUid, err = strconv.Atoi(account.Uid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid of user %s", childUser)
+		}
+	}
+
+	if rawWarnings, exists := env.ParseStringVar("SCREEN_TIME_WARNINGS"); exists {
+		cfg.Warnings = []time.Duration{}
+
+		for _, rawMinutes := range strings.Split(rawWarnings, ",") {
+			minutes, err := strconv.Atoi(strings.TrimSpace(rawMinutes))
+			if err != nil || minutes <= 0 {
+				return nil, errors.New("env 'SCREEN_TIME_WARNINGS' must be a comma separated list of minutes, e.g. 15,5,1")
+			}
+
+			cfg.Warnings = append(cfg.Warnings, time.Duration(minutes)*time.Minute)
+		}
+	}
+
+	if rawLanguage, exists := env.ParseStringVar("LANGUAGE"); exists {
+		language, supported := i18n.ParseLanguage(rawLanguage)
+		if !supported {
+			return nil, fmt.Errorf("env 'LANGUAGE' must be one of %v", i18n.Languages)
+		}
+
+		cfg.Language = language
+	}
+
+	return cfg, nil
+}
+
+func kill(pid int) error {
+	err := syscall.Kill(pid, syscall.SIGKILL)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+
+	return err
+}
+
+// keepSynced calls sync at start, whenever changed receives and every refreshInterval if it is not 0.
+// A failed sync is retried after syncRetryInterval.
+func keepSynced(ctx context.Context, name string, sync func(ctx context.Context) error, changed <-chan struct{}, refreshInterval time.Duration) {
+	for {
+		wait := refreshInterval
+
+		err := sync(ctx)
+		if err != nil {
+			log.Printf("error occured while trying to fetch %s: %v", name, err)
+			wait = syncRetryInterval
+		}
+
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timeout = time.After(wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-timeout:
+		}
+	}
+}
+
+// notify wakes up keepSynced without blocking, a pending notification is enough.
+func notify(changed chan<- struct{}) {
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
+
+// runUninstallHook tells the parents the agent is being removed, package scripts run it before the removal.
+func runUninstallHook(sender *heartbeat.Sender) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sender.Report(heartbeats.TamperUninstallAttempt, "", time.Now())
+
+	_, err := sender.Send(ctx, "", time.Now())
+	if err != nil {
+		log.Fatalf("error occured while trying to report the uninstall attempt: %v", err)
+	}
+}
+
+func main() {
+	cfg, err := readConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = os.MkdirAll(cfg.StateDir, 0o700)
+	if err != nil {
+		log.Fatalf("failed to create the state directory: %v", err)
+	}
+
+	heartbeatSender, err := heartbeat.NewSender(cfg.ServerUrl+"/device/heartbeats", cfg.authorizationHeader(), cfg.HeartbeatKey, version, cfg.StateDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "uninstall-hook" {
+		runUninstallHook(heartbeatSender)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	queue, err := uploader.NewQueue(fmt.Sprintf("%s/devices/%d/events", cfg.ServerUrl, cfg.DeviceId), cfg.authorizationHeader(), cfg.StateDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	clock, err := trustedclock.New(trustedclock.NewSystemClocks(), cfg.HeartbeatKey, cfg.DeviceId, cfg.StateDir, heartbeatSender)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	watcher := appwatch.New(procfs.New("/proc"), kill, queue, cfg.Location)
+	accountant := screentime.NewAccountant(screentime.NewLogind(cfg.ChildUid, cfg.Language), queue, cfg.Location, cfg.Warnings)
+
+	err = accountant.PersistUsage(filepath.Join(cfg.StateDir, "screen_time.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	appRulesChanged := make(chan struct{}, 1)
+	budgetChanged := make(chan struct{}, 1)
+
+	go keepSynced(ctx, "app rules", func(ctx context.Context) error {
+		rules, err := fetchAppRules(ctx, cfg)
+		if err == nil {
+			watcher.SetRules(rules)
+		}
+
+		return err
+	}, appRulesChanged, 0)
+	go keepSynced(ctx, "time token", func(ctx context.Context) error {
+		nonce, err := trustedclock.NewNonce()
+		if err != nil {
+			return err
+		}
+
+		token, err := fetchTimeToken(ctx, cfg, nonce)
+		if err != nil {
+			return err
+		}
+
+		return clock.SetToken(token, nonce)
+	}, nil, timeTokenRefreshInterval)
+	go keepSynced(ctx, "screen time", func(ctx context.Context) error {
+		// the usage collected offline is sent first, so the budget of the server includes it
+		err := queue.Flush(ctx)
+		if err != nil {
+			return err
+		}
+
+		budget, err := fetchScreenTimeBudget(ctx, cfg)
+		if err == nil {
+			accountant.SetBudget(budget)
+		}
+
+		return err
+	}, budgetChanged, budgetRefreshInterval)
+	go func() {
+		err := queue.Run(ctx, uploader.DefaultInterval)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("activity upload stopped: %v", err)
+		}
+	}()
+	go func() {
+		pushClient := push.NewClient(cfg.ServerUrl+"/device/events", cfg.authorizationHeader())
+
+		err := pushClient.Run(ctx, func(event push.Event) {
+			switch event.Type {
+			case push.EventTypePolicyChanged, push.EventTypeResync:
+				notify(appRulesChanged)
+				notify(budgetChanged)
+			case push.EventTypeTimeExtensionDecided:
+				notify(budgetChanged)
+			}
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("push channel stopped: %v", err)
+		}
+	}()
+	go func() {
+		policyHash := func() string {
+			return heartbeats.PolicyHash(watcher.Rules(), int(accountant.Budget().Daily/time.Minute))
+		}
+
+		err := heartbeatSender.Run(ctx, clock.Now, policyHash, func() {
+			notify(appRulesChanged)
+			notify(budgetChanged)
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("heartbeats stopped: %v", err)
+		}
+	}()
+	go func() {
+		err := accountant.Run(ctx, screentime.DefaultInterval, clock.Now)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("screen time accounting stopped: %v", err)
+		}
+	}()
+
+	err = watcher.Run(ctx, appwatch.DefaultInterval, clock.Now)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+
+	// Send what was collected before exiting, the rest is sent after the next start.
+	flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = queue.Flush(flushCtx)
+	if err != nil {
+		log.Printf("error occured while trying to send activity events: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package procfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"domanscy.group/parental-controls/server/apprules"
+)
+
+var ErrInvalidStat = errors.New("invalid stat file of the process")
+
+// FS reads processes from a proc filesystem mounted at Root, tests point it to a fake tree in a temporary directory.
+type FS struct {
+	Root string
+}
+
+func New(root string) *FS {
+	return &FS{Root: root}
+}
+
+// Process is a process read from the proc filesystem.
+type Process struct {
+	apprules.Process
+	// StartTime is the start time of the process in clock ticks since boot, a pid reused by
+	// another process has a different start time.
+	StartTime uint64
+}
+
+// Pids lists the numeric entries of the proc filesystem.
+func (fs *FS) Pids() ([]int, error) {
+	entries, err := os.ReadDir(fs.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the proc directory: %w", err)
+	}
+
+	pids := []int{}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid <= 0 || !entry.IsDir() {
+			continue
+		}
+
+		pids = append(pids, pid)
+	}
+
+	return pids, nil
+}
+
+// Process reads the process, the returned error satisfies errors.Is(err, os.ErrNotExist) when it has already exited.
+// Exe is empty for kernel threads and processes the agent is not allowed to inspect.
+func (fs *FS) Process(pid int) (*Process, error) {
+	dir := filepath.Join(fs.Root, strconv.Itoa(pid))
+
+	comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the name of process %d: %w", pid, err)
+	}
+
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the stat of process %d: %w", pid, err)
+	}
+
+	startTime, err := parseStartTime(string(stat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the stat of process %d: %w", pid, err)
+	}
+
+	exeLink := filepath.Join(dir, "exe")
+
+	exe, err := os.Readlink(exeLink)
+	if err != nil {
+		exe = ""
+	}
+
+	// The kernel appends " (deleted)" to executables replaced after the process started, e.g. by an update.
+	exe = strings.TrimSuffix(exe, " (deleted)")
+
+	return &Process{
+		Process: apprules.Process{
+			Pid:  pid,
+			Name: strings.TrimSuffix(string(comm), "\n"),
+			Exe:  exe,
+			ExeHash: func() (string, error) {
+				return hashFile(exeLink)
+			},
+		},
+		StartTime: startTime,
+	}, nil
+}
+
+// parseStartTime returns the 22nd field of /proc/<pid>/stat. The second field is the name in parentheses,
+// it may contain spaces and parentheses itself, so the fields are counted from the last closing parenthesis.
+func parseStartTime(stat string) (uint64, error) {
+	nameEnd := strings.LastIndexByte(stat, ')')
+	if nameEnd < 0 {
+		return 0, ErrInvalidStat
+	}
+
+	// The fields after the name start with the 3rd one, the state.
+	fields := strings.Fields(stat[nameEnd+1:])
+	if len(fields) < 22-2 {
+		return 0, ErrInvalidStat
+	}
+
+	startTime, err := strconv.ParseUint(fields[22-3], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidStat
+	}
+
+	return startTime, nil
+}
+
+// hashFile hashes through the exe link of the process, it reaches the executable even when it was deleted or
+// is not visible in the mount namespace of the agent.
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open the executable: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash the executable: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
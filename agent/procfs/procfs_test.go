@@ -0,0 +1,123 @@
+package procfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// fakeProcess writes the files of a process the way the kernel shows them in /proc.
+func fakeProcess(t *testing.T, root string, pid int, comm string, exe string, startTime int) {
+	dir := filepath.Join(root, strconv.Itoa(pid))
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat := strconv.Itoa(pid) + " (" + comm + ") S 1 1 1 0 -1 4194560 100 0 0 0 1 2 0 0 20 0 1 0 " + strconv.Itoa(startTime) + " 1000 100\n"
+
+	err = os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exe != "" {
+		err = os.Symlink(exe, filepath.Join(dir, "exe"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFS(t *testing.T) {
+	root := t.TempDir()
+	binaries := t.TempDir()
+
+	game := filepath.Join(binaries, "supertux2")
+	err := os.WriteFile(game, []byte("#!/bin/sh\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fakeProcess(t, root, 1, "systemd", "", 1)
+	fakeProcess(t, root, 4242, "super) tux", game, 98765)
+
+	err = os.MkdirAll(filepath.Join(root, "self"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New(root)
+
+	t.Run("lists only pids", func(t *testing.T) {
+		pids, err := fs.Pids()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(pids) != 2 || pids[0] != 1 || pids[1] != 4242 {
+			t.Fatalf("Got %v, want [1 4242]", pids)
+		}
+	})
+
+	t.Run("reads name, executable and start time", func(t *testing.T) {
+		process, err := fs.Process(4242)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if process.Name != "super) tux" || process.Exe != game || process.StartTime != 98765 {
+			t.Fatalf("Unexpected process: %+v", process)
+		}
+
+		hash, err := process.ExeHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum := sha256.Sum256([]byte("#!/bin/sh\n"))
+		if hash != hex.EncodeToString(sum[:]) {
+			t.Fatalf("Got hash %s, want %s", hash, hex.EncodeToString(sum[:]))
+		}
+	})
+
+	t.Run("leaves the executable of kernel threads empty", func(t *testing.T) {
+		process, err := fs.Process(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if process.Exe != "" {
+			t.Fatalf("Got %q, want empty executable", process.Exe)
+		}
+	})
+
+	t.Run("reports exited processes as not existing", func(t *testing.T) {
+		_, err := fs.Process(31337)
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("Expected %v, received %v", os.ErrNotExist, err)
+		}
+	})
+}
+
+func TestParseStartTime(t *testing.T) {
+	_, err := parseStartTime("12 (bash) S 1 2 3")
+	if !errors.Is(err, ErrInvalidStat) {
+		t.Fatalf("Expected %v, received %v", ErrInvalidStat, err)
+	}
+
+	_, err = parseStartTime("12 bash S")
+	if !errors.Is(err, ErrInvalidStat) {
+		t.Fatalf("Expected %v, received %v", ErrInvalidStat, err)
+	}
+}
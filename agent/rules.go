@@ -0,0 +1,67 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"domanscy.group/parental-controls/server/apprules"
+)
+
+type appRuleResponse struct {
+	Id                int    `json:"id"`
+	ChildId           int    `json:"childId"`
+	MatchType         string `json:"matchType"`
+	Pattern           string `json:"pattern"`
+	Action            string `json:"action"`
+	DailyLimitMinutes int    `json:"dailyLimitMinutes"`
+}
+
+// fetchAppRules downloads the rules of the child the device belongs to.
+func fetchAppRules(ctx context.Context, cfg *agentConfig) ([]apprules.Model, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.ServerUrl+"/device/app_rules", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header = cfg.authorizationHeader()
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch app rules: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server responded with status %d to fetching app rules", response.StatusCode)
+	}
+
+	var decoded []appRuleResponse
+
+	err = json.NewDecoder(response.Body).Decode(&decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode app rules: %w", err)
+	}
+
+	rules := make([]apprules.Model, 0, len(decoded))
+
+	for _, rule := range decoded {
+		rules = append(rules, apprules.Model{
+			Id:                rule.Id,
+			ChildId:           rule.ChildId,
+			MatchType:         apprules.MatchType(rule.MatchType),
+			Pattern:           rule.Pattern,
+			Action:            apprules.Action(rule.Action),
+			DailyLimitMinutes: rule.DailyLimitMinutes,
+		})
+	}
+
+	return rules, nil
+}
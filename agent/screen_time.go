@@ -0,0 +1,62 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"domanscy.group/parental-controls/agent/screentime"
+)
+
+type screenTimeResponse struct {
+	Date           string `json:"date"`
+	DailyMinutes   int    `json:"dailyMinutes"`
+	GrantedMinutes int    `json:"grantedMinutes"`
+	UsedSeconds    int64  `json:"usedSeconds"`
+}
+
+// fetchScreenTimeBudget downloads the budget of today, the day is the one of the server reports.
+func fetchScreenTimeBudget(ctx context.Context, cfg *agentConfig) (screentime.Budget, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.ServerUrl+"/device/screen_time", nil)
+	if err != nil {
+		return screentime.Budget{}, err
+	}
+
+	request.Header = cfg.authorizationHeader()
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return screentime.Budget{}, fmt.Errorf("failed to fetch screen time: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return screentime.Budget{}, fmt.Errorf("server responded with status %d to fetching screen time", response.StatusCode)
+	}
+
+	var decoded screenTimeResponse
+
+	err = json.NewDecoder(response.Body).Decode(&decoded)
+	if err != nil {
+		return screentime.Budget{}, fmt.Errorf("failed to decode screen time: %w", err)
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", decoded.Date, cfg.Location)
+	if err != nil {
+		return screentime.Budget{}, fmt.Errorf("failed to parse the day of screen time: %w", err)
+	}
+
+	return screentime.Budget{
+		Day:     day,
+		Daily:   time.Duration(decoded.DailyMinutes) * time.Minute,
+		Granted: time.Duration(decoded.GrantedMinutes) * time.Minute,
+		Used:    time.Duration(decoded.UsedSeconds) * time.Second,
+	}, nil
+}
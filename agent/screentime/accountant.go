@@ -0,0 +1,348 @@
+package screentime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"domanscy.group/parental-controls/server/activity"
+)
+
+// DefaultInterval is how often the sessions are polled, the child may use the machine that long after the budget ran out.
+const DefaultInterval = 5 * time.Second
+
+// maxPollGap caps the time counted between two polls, the machine may have been suspended in between.
+// A longer gap closes the usage interval where the gap started.
+const maxPollGap = time.Minute
+
+// usageSaveInterval is the most screen time lost when the agent restarts, the usage of today is saved this often.
+const usageSaveInterval = time.Minute
+
+// DefaultWarnings are the remaining times the child is warned at.
+var DefaultWarnings = []time.Duration{15 * time.Minute, 5 * time.Minute, time.Minute}
+
+// Session is a login session of the child as the operating system reports it.
+type Session struct {
+	Id   string
+	Uid  int
+	User string
+	// Active is true for the session in the foreground of its seat, e.g. not the one switched away from.
+	Active bool
+	Locked bool
+	// Idle is reported by the desktop environment after its idle timeout, the time until then counts as use.
+	Idle bool
+}
+
+func (session *Session) InUse() bool {
+	return session.Active && !session.Locked && !session.Idle
+}
+
+// Signals hides the operating system from the accounting, see Logind.
+type Signals interface {
+	Sessions() ([]Session, error)
+	Lock(session Session) error
+	Warn(session Session, remaining time.Duration) error
+}
+
+// Reporter queues activity events for the server, see uploader.Queue.
+type Reporter interface {
+	Add(eventType activity.Type, payload any, occurredAt time.Time) error
+}
+
+// Budget is the screen time of a day as the server knows it.
+type Budget struct {
+	// Day is the midnight the budget applies from.
+	Day time.Time
+	// Daily is 0 when the screen time is not limited.
+	Daily   time.Duration
+	Granted time.Duration
+	// Used is the screen time reported by all devices of the child until the budget was fetched.
+	Used time.Duration
+}
+
+// Accountant counts the time the child actually uses the machine, a session that is locked, idle or
+// in the background does not count. Usage intervals are reported as screen_on and screen_off events.
+// When the budget runs out the child is warned first and the sessions are locked after.
+type Accountant struct {
+	signals  Signals
+	reporter Reporter
+	location *time.Location
+	// warnings are sorted from the longest remaining time.
+	warnings []time.Duration
+
+	mu        sync.Mutex
+	budget    Budget
+	hasBudget bool
+	day       time.Time
+	// used is the time counted on this machine today.
+	used time.Duration
+	// usedAtSync is used at the moment the budget was set, the server knows everything before.
+	usedAtSync time.Duration
+	warned     map[time.Duration]bool
+	inUse      bool
+	lastPoll   time.Time
+	// usagePath is empty until PersistUsage, savedUsed is used as it was saved there last.
+	usagePath string
+	savedUsed time.Duration
+}
+
+// savedUsage is the time counted on this machine on a day, it survives restarts of an agent offline.
+type savedUsage struct {
+	Day  time.Time     `json:"day"`
+	Used time.Duration `json:"used"`
+}
+
+func NewAccountant(signals Signals, reporter Reporter, location *time.Location, warnings []time.Duration) *Accountant {
+	warnings = slices.Clone(warnings)
+	slices.Sort(warnings)
+	slices.Reverse(warnings)
+
+	return &Accountant{
+		signals:  signals,
+		reporter: reporter,
+		location: location,
+		warnings: warnings,
+		warned:   map[time.Duration]bool{},
+	}
+}
+
+func (accountant *Accountant) startOfDay(now time.Time) time.Time {
+	year, month, day := now.In(accountant.location).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, accountant.location)
+}
+
+// PersistUsage restores the usage saved at path and keeps saving it there, so the child does not get the time back
+// by restarting the machine while it is offline.
+func (accountant *Accountant) PersistUsage(path string) error {
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+
+	accountant.usagePath = path
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read the saved usage: %w", err)
+	}
+
+	var saved savedUsage
+
+	err = json.Unmarshal(content, &saved)
+	if err != nil {
+		return fmt.Errorf("invalid saved usage %s: %w", path, err)
+	}
+
+	accountant.day = saved.Day
+	accountant.used = saved.Used
+	accountant.savedUsed = saved.Used
+
+	return nil
+}
+
+func (accountant *Accountant) saveUsage() error {
+	content, err := json.Marshal(savedUsage{Day: accountant.day, Used: accountant.used})
+	if err != nil {
+		return fmt.Errorf("failed to encode the usage: %w", err)
+	}
+
+	temporaryPath := accountant.usagePath + ".tmp"
+
+	err = os.WriteFile(temporaryPath, content, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write the usage: %w", err)
+	}
+
+	err = os.Rename(temporaryPath, accountant.usagePath)
+	if err != nil {
+		return fmt.Errorf("failed to replace the usage: %w", err)
+	}
+
+	accountant.savedUsed = accountant.used
+
+	return nil
+}
+
+// SetBudget replaces the budget, warnings for more time than now remains are given again.
+func (accountant *Accountant) SetBudget(budget Budget) {
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+
+	accountant.budget = budget
+	accountant.hasBudget = true
+	accountant.usedAtSync = accountant.used
+
+	remaining, limited := accountant.remaining()
+	for _, warning := range accountant.warnings {
+		if limited && warning < remaining {
+			delete(accountant.warned, warning)
+		}
+	}
+}
+
+// Budget returns the budget last set, the zero Budget before the first one.
+func (accountant *Accountant) Budget() Budget {
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+
+	return accountant.budget
+}
+
+// Used returns the screen time of today, the larger of the time counted here and the time the server knows
+// about, so time on other devices counts and time not uploaded yet is not lost.
+func (accountant *Accountant) Used() time.Duration {
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+
+	return accountant.totalUsed()
+}
+
+func (accountant *Accountant) totalUsed() time.Duration {
+	if !accountant.hasBudget || !accountant.budget.Day.Equal(accountant.day) {
+		return accountant.used
+	}
+
+	return max(accountant.used, accountant.budget.Used+accountant.used-accountant.usedAtSync)
+}
+
+// Remaining returns the screen time left today, false when it is not limited.
+func (accountant *Accountant) Remaining() (time.Duration, bool) {
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+
+	return accountant.remaining()
+}
+
+func (accountant *Accountant) remaining() (time.Duration, bool) {
+	if !accountant.hasBudget || accountant.budget.Daily == 0 {
+		return 0, false
+	}
+
+	total := accountant.budget.Daily
+	if accountant.budget.Day.Equal(accountant.day) {
+		total += accountant.budget.Granted
+	}
+
+	return max(total-accountant.totalUsed(), 0), true
+}
+
+func (accountant *Accountant) startDay(day time.Time) {
+	accountant.day = day
+	accountant.used = 0
+	accountant.usedAtSync = 0
+	accountant.savedUsed = 0
+	accountant.warned = map[time.Duration]bool{}
+
+	if accountant.lastPoll.Before(day) {
+		accountant.lastPoll = day
+	}
+}
+
+// Poll looks at the sessions once and counts the time since the previous poll if the child used the machine.
+func (accountant *Accountant) Poll(now time.Time) error {
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+
+	sessions, err := accountant.signals.Sessions()
+	if err != nil {
+		return err
+	}
+
+	if day := accountant.startOfDay(now); !day.Equal(accountant.day) {
+		accountant.startDay(day)
+	}
+
+	var errs error
+
+	if accountant.inUse && !accountant.lastPoll.IsZero() {
+		elapsed := now.Sub(accountant.lastPoll)
+
+		if elapsed > maxPollGap {
+			accountant.used += maxPollGap
+			accountant.inUse = false
+			errs = errors.Join(errs, accountant.reporter.Add(activity.TypeScreenOff, activity.ScreenPayload{}, accountant.lastPoll.Add(maxPollGap)))
+		} else if elapsed > 0 {
+			accountant.used += elapsed
+		}
+	}
+
+	accountant.lastPoll = now
+
+	if accountant.usagePath != "" && accountant.used-accountant.savedUsed >= usageSaveInterval {
+		errs = errors.Join(errs, accountant.saveUsage())
+	}
+
+	inUse := false
+	for _, session := range sessions {
+		inUse = inUse || session.InUse()
+	}
+
+	if inUse != accountant.inUse {
+		eventType := activity.TypeScreenOff
+		if inUse {
+			eventType = activity.TypeScreenOn
+		}
+
+		accountant.inUse = inUse
+		errs = errors.Join(errs, accountant.reporter.Add(eventType, activity.ScreenPayload{}, now))
+	}
+
+	remaining, limited := accountant.remaining()
+	if !limited || !inUse {
+		return errs
+	}
+
+	if remaining == 0 {
+		for _, session := range sessions {
+			if session.InUse() {
+				errs = errors.Join(errs, accountant.signals.Lock(session))
+			}
+		}
+
+		return errs
+	}
+
+	// Only the shortest of the crossed thresholds is shown, e.g. after the budget was lowered.
+	warnAt := time.Duration(-1)
+	for _, warning := range accountant.warnings {
+		if remaining <= warning && !accountant.warned[warning] {
+			accountant.warned[warning] = true
+			warnAt = warning
+		}
+	}
+
+	if warnAt >= 0 {
+		for _, session := range sessions {
+			if session.InUse() {
+				errs = errors.Join(errs, accountant.signals.Warn(session, remaining))
+			}
+		}
+	}
+
+	return errs
+}
+
+// Run polls every interval until ctx is done, now is the clock the usage is counted by.
+func (accountant *Accountant) Run(ctx context.Context, interval time.Duration, now func() time.Time) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := accountant.Poll(now())
+		if err != nil {
+			log.Printf("error occured while trying to account screen time: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
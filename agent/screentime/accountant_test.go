@@ -0,0 +1,288 @@
+package screentime
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/activity"
+)
+
+type fakeSignals struct {
+	sessions []Session
+	locked   int
+	warnings []time.Duration
+}
+
+func (signals *fakeSignals) Sessions() ([]Session, error) {
+	return signals.sessions, nil
+}
+
+func (signals *fakeSignals) Lock(session Session) error {
+	signals.locked++
+
+	for i := range signals.sessions {
+		if signals.sessions[i].Id == session.Id {
+			signals.sessions[i].Locked = true
+		}
+	}
+
+	return nil
+}
+
+func (signals *fakeSignals) Warn(_ Session, remaining time.Duration) error {
+	signals.warnings = append(signals.warnings, remaining)
+	return nil
+}
+
+type reportedEvent struct {
+	eventType  activity.Type
+	occurredAt time.Time
+}
+
+type fakeReporter struct {
+	events []reportedEvent
+}
+
+func (reporter *fakeReporter) Add(eventType activity.Type, _ any, occurredAt time.Time) error {
+	reporter.events = append(reporter.events, reportedEvent{eventType: eventType, occurredAt: occurredAt})
+	return nil
+}
+
+// simulatedClock drives the accountant, every step moves the time and polls.
+type simulatedClock struct {
+	t          *testing.T
+	accountant *Accountant
+	now        time.Time
+}
+
+func (clock *simulatedClock) advance(step time.Duration, times int) {
+	for range times {
+		clock.now = clock.now.Add(step)
+
+		err := clock.accountant.Poll(clock.now)
+		if err != nil {
+			clock.t.Fatal(err)
+		}
+	}
+}
+
+func TestAccountant(t *testing.T) {
+	location := time.FixedZone("CEST", 2*60*60)
+	start := time.Date(2024, 9, 1, 16, 0, 0, 0, location)
+	day := time.Date(2024, 9, 1, 0, 0, 0, 0, location)
+
+	newAccountant := func(t *testing.T) (*simulatedClock, *fakeSignals, *fakeReporter) {
+		signals := &fakeSignals{sessions: []Session{{Id: "2", Uid: 1000, User: "adam", Active: true}}}
+		reporter := &fakeReporter{}
+		accountant := NewAccountant(signals, reporter, location, []time.Duration{time.Minute, 5 * time.Minute})
+
+		clock := &simulatedClock{t: t, accountant: accountant, now: start}
+		clock.advance(0, 1)
+
+		return clock, signals, reporter
+	}
+
+	t.Run("counts only sessions the child is using", func(t *testing.T) {
+		clock, signals, reporter := newAccountant(t)
+
+		clock.advance(5*time.Second, 12)
+
+		signals.sessions[0].Idle = true
+		clock.advance(5*time.Second, 12)
+
+		signals.sessions[0].Idle = false
+		signals.sessions[0].Active = false
+		clock.advance(5*time.Second, 12)
+
+		signals.sessions[0].Active = true
+		clock.advance(5*time.Second, 6)
+
+		if clock.accountant.Used() != 90*time.Second {
+			t.Fatalf("Got %v, want 1m30s", clock.accountant.Used())
+		}
+
+		want := []reportedEvent{
+			{activity.TypeScreenOn, start},
+			{activity.TypeScreenOff, start.Add(65 * time.Second)},
+			{activity.TypeScreenOn, start.Add(185 * time.Second)},
+		}
+
+		if fmt.Sprint(reporter.events) != fmt.Sprint(want) {
+			t.Fatalf("Got %v, want %v", reporter.events, want)
+		}
+	})
+
+	t.Run("warns at thresholds and locks when the budget runs out", func(t *testing.T) {
+		clock, signals, _ := newAccountant(t)
+
+		clock.accountant.SetBudget(Budget{Day: day, Daily: 10 * time.Minute})
+
+		clock.advance(5*time.Second, 5*12)
+		if len(signals.warnings) != 1 || signals.warnings[0] != 5*time.Minute {
+			t.Fatalf("Got warnings %v, want one at 5m", signals.warnings)
+		}
+
+		clock.advance(5*time.Second, 4*12)
+		if len(signals.warnings) != 2 || signals.locked != 0 {
+			t.Fatalf("Got warnings %v and %d locks, want two warnings and no lock", signals.warnings, signals.locked)
+		}
+
+		clock.advance(5*time.Second, 12)
+		if signals.locked != 1 {
+			t.Fatalf("Got %d locks, want 1", signals.locked)
+		}
+
+		signals.sessions[0].Locked = false
+		clock.advance(5*time.Second, 1)
+
+		if signals.locked != 2 {
+			t.Fatalf("Got %d locks, want the unlocked session locked again", signals.locked)
+		}
+
+		if remaining, limited := clock.accountant.Remaining(); remaining != 0 || !limited {
+			t.Fatalf("Got %v and %v, want nothing remaining", remaining, limited)
+		}
+	})
+
+	t.Run("adds time of other devices and granted extensions", func(t *testing.T) {
+		clock, signals, _ := newAccountant(t)
+
+		clock.advance(5*time.Second, 12)
+		clock.accountant.SetBudget(Budget{Day: day, Daily: 30 * time.Minute, Used: 25 * time.Minute})
+
+		clock.advance(5*time.Second, 12)
+		if clock.accountant.Used() != 26*time.Minute || len(signals.warnings) != 1 {
+			t.Fatalf("Got %v used and warnings %v, want 26m and a warning", clock.accountant.Used(), signals.warnings)
+		}
+
+		clock.accountant.SetBudget(Budget{Day: day, Daily: 30 * time.Minute, Granted: 15 * time.Minute, Used: 26 * time.Minute})
+
+		clock.advance(5*time.Second, 15*12)
+		if len(signals.warnings) != 2 || signals.warnings[1] != 5*time.Minute {
+			t.Fatalf("Got warnings %v, want the 5m warning again after the extension", signals.warnings)
+		}
+
+		if remaining, _ := clock.accountant.Remaining(); remaining != 4*time.Minute {
+			t.Fatalf("Got %v remaining, want 4m", remaining)
+		}
+	})
+
+	t.Run("does not count suspended time and starts over at midnight", func(t *testing.T) {
+		clock, _, reporter := newAccountant(t)
+
+		clock.advance(5*time.Second, 12)
+		clock.advance(time.Hour, 1)
+
+		if clock.accountant.Used() != 2*time.Minute {
+			t.Fatalf("Got %v, want 2m", clock.accountant.Used())
+		}
+
+		if reporter.events[1].eventType != activity.TypeScreenOff || !reporter.events[1].occurredAt.Equal(start.Add(2*time.Minute)) {
+			t.Fatalf("Unexpected events: %v", reporter.events)
+		}
+
+		clock.accountant.SetBudget(Budget{Day: day, Daily: time.Hour, Used: 50 * time.Minute})
+
+		clock.now = time.Date(2024, 9, 1, 23, 59, 30, 0, location)
+		clock.advance(30*time.Second, 3)
+
+		if clock.accountant.Used() != time.Minute {
+			t.Fatalf("Got %v, want only the minute after midnight", clock.accountant.Used())
+		}
+
+		if remaining, _ := clock.accountant.Remaining(); remaining != 59*time.Minute {
+			t.Fatalf("Got %v remaining, want the daily budget without the usage of yesterday", remaining)
+		}
+	})
+}
+
+func TestAccountantPersistUsage(t *testing.T) {
+	location := time.FixedZone("CEST", 2*60*60)
+	start := time.Date(2024, 9, 1, 16, 0, 0, 0, location)
+	day := time.Date(2024, 9, 1, 0, 0, 0, 0, location)
+	path := filepath.Join(t.TempDir(), "usage.json")
+
+	newAccountant := func(t *testing.T, now time.Time) *simulatedClock {
+		signals := &fakeSignals{sessions: []Session{{Id: "2", Uid: 1000, User: "adam", Active: true}}}
+		accountant := NewAccountant(signals, &fakeReporter{}, location, nil)
+
+		err := accountant.PersistUsage(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock := &simulatedClock{t: t, accountant: accountant, now: now}
+		clock.advance(0, 1)
+
+		return clock
+	}
+
+	clock := newAccountant(t, start)
+	clock.advance(5*time.Second, 12*30+6)
+
+	// the server did not get the usage of the offline machine before the restart
+	restarted := newAccountant(t, clock.now.Add(time.Minute))
+	restarted.accountant.SetBudget(Budget{Day: day, Daily: time.Hour, Used: 10 * time.Minute})
+
+	if restarted.accountant.Used() != 30*time.Minute {
+		t.Fatalf("Got %v, want the 30m saved before the restart", restarted.accountant.Used())
+	}
+
+	if remaining, _ := restarted.accountant.Remaining(); remaining != 30*time.Minute {
+		t.Fatalf("Got %v remaining, want 30m", remaining)
+	}
+
+	tomorrow := newAccountant(t, start.Add(24*time.Hour))
+	if tomorrow.accountant.Used() != 0 {
+		t.Fatalf("Got %v, want the usage of yesterday forgotten", tomorrow.accountant.Used())
+	}
+}
+
+func TestLogind(t *testing.T) {
+	logind := NewLogind(1000, i18n.LanguageEnglish)
+	logind.run = func(name string, args ...string) ([]byte, error) {
+		command := fmt.Sprint(append([]string{name}, args...))
+
+		switch command {
+		case "[loginctl list-sessions --no-legend --no-pager]":
+			return []byte("     2 1000 adam  seat0 tty2\n     3 1001 ewa   seat0 tty3\n     c1  120 gdm   seat0 tty1\n"), nil
+		case "[loginctl show-session 2 --no-pager -p Class -p Active -p LockedHint -p IdleHint]":
+			return []byte("Class=user\nActive=yes\nLockedHint=no\nIdleHint=yes\n"), nil
+		case "[loginctl lock-session 2]":
+			return nil, nil
+		case "[runuser -u adam -- env DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/1000/bus notify-send --urgency=critical Parental controls 5 minutes of screen time left, then the session will be locked.]":
+			return nil, nil
+		default:
+			return nil, errors.New("unexpected command " + command)
+		}
+	}
+
+	sessions, err := logind.Sessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sessions) != 1 || sessions[0] != (Session{Id: "2", Uid: 1000, User: "adam", Active: true, Idle: true}) {
+		t.Fatalf("Unexpected sessions: %+v", sessions)
+	}
+
+	err = logind.Lock(sessions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = logind.Warn(sessions[0], 4*time.Minute+30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCatalogIsComplete(t *testing.T) {
+	if missing := catalog.Missing(); len(missing) > 0 {
+		t.Fatalf("Missing translations: %v", missing)
+	}
+}
@@ -0,0 +1,18 @@
+package screentime
+
+import "domanscy.group/parental-controls/client/i18n"
+
+// catalog holds the texts of the notifications shown to the child.
+var catalog = i18n.Catalog{
+	i18n.LanguagePolish: {
+		"warning.title":          "Kontrola rodzicielska",
+		"warning.remaining.one":  "Pozostała %d min czasu przed ekranem, potem sesja zostanie zablokowana.",
+		"warning.remaining.few":  "Pozostały %d min czasu przed ekranem, potem sesja zostanie zablokowana.",
+		"warning.remaining.many": "Pozostało %d min czasu przed ekranem, potem sesja zostanie zablokowana.",
+	},
+	i18n.LanguageEnglish: {
+		"warning.title":           "Parental controls",
+		"warning.remaining.one":   "%d minute of screen time left, then the session will be locked.",
+		"warning.remaining.other": "%d minutes of screen time left, then the session will be locked.",
+	},
+}
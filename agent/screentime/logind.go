@@ -0,0 +1,118 @@
+package screentime
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"domanscy.group/parental-controls/client/i18n"
+)
+
+// Logind reads the sessions from systemd-logind through loginctl, which asks logind over the system D-Bus.
+// The desktop environment reports lock and idle state to logind as LockedHint and IdleHint.
+type Logind struct {
+	// Uid limits the sessions to the account of the child, -1 counts sessions of every user.
+	Uid int
+	// Language is the one of the notifications, the child reads them.
+	Language i18n.Language
+	// run executes a command and returns its standard output, tests replace it.
+	run func(name string, args ...string) ([]byte, error)
+}
+
+func NewLogind(uid int, language i18n.Language) *Logind {
+	return &Logind{
+		Uid:      uid,
+		Language: language,
+		run: func(name string, args ...string) ([]byte, error) {
+			return exec.Command(name, args...).Output()
+		},
+	}
+}
+
+// parseProperties parses the Key=Value lines loginctl show-session prints.
+func parseProperties(output []byte) map[string]string {
+	properties := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if found {
+			properties[key] = value
+		}
+	}
+
+	return properties
+}
+
+func (logind *Logind) Sessions() ([]Session, error) {
+	output, err := logind.run("loginctl", "list-sessions", "--no-legend", "--no-pager")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := []Session{}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+
+		uid, err := strconv.Atoi(fields[1])
+		if err != nil || logind.Uid >= 0 && uid != logind.Uid {
+			continue
+		}
+
+		output, err := logind.run("loginctl", "show-session", fields[0], "--no-pager", "-p", "Class", "-p", "Active", "-p", "LockedHint", "-p", "IdleHint")
+		if err != nil {
+			// The session ended after it was listed.
+			continue
+		}
+
+		properties := parseProperties(output)
+		if properties["Class"] != "user" {
+			continue
+		}
+
+		sessions = append(sessions, Session{
+			Id:     fields[0],
+			Uid:    uid,
+			User:   fields[2],
+			Active: properties["Active"] == "yes",
+			Locked: properties["LockedHint"] == "yes",
+			Idle:   properties["IdleHint"] == "yes",
+		})
+	}
+
+	return sessions, nil
+}
+
+func (logind *Logind) Lock(session Session) error {
+	_, err := logind.run("loginctl", "lock-session", session.Id)
+	if err != nil {
+		return fmt.Errorf("failed to lock session %s: %w", session.Id, err)
+	}
+
+	return nil
+}
+
+// Warn shows a desktop notification, it is sent on the session bus of the child as the child.
+func (logind *Logind) Warn(session Session, remaining time.Duration) error {
+	minutes := int((remaining + time.Minute - 1) / time.Minute)
+
+	_, err := logind.run(
+		"runuser", "-u", session.User, "--",
+		"env", fmt.Sprintf("DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/%d/bus", session.Uid),
+		"notify-send", "--urgency=critical", catalog.Text(logind.Language, "warning.title"),
+		catalog.Text(logind.Language, "warning.remaining", minutes),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to warn session %s: %w", session.Id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package seqfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// reservation sequence numbers are reserved with a single write of the file, after a restart
+// the counter continues after the reserved range and never returns a used number again.
+const reservation = 1000
+
+// Counter hands out increasing sequence numbers that survive restarts of the agent.
+type Counter struct {
+	path string
+
+	mu            sync.Mutex
+	next          int64
+	reservedUntil int64
+}
+
+// Open continues the counter persisted at path, a missing file starts it at 1.
+func Open(path string) (*Counter, error) {
+	counter := &Counter{path: path}
+
+	reserved, err := counter.read()
+	if err != nil {
+		return nil, err
+	}
+
+	counter.reservedUntil = reserved
+	counter.next = reserved + 1
+
+	return counter, nil
+}
+
+func (counter *Counter) read() (int64, error) {
+	content, err := os.ReadFile(counter.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read the sequence file: %w", err)
+	}
+
+	reserved, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil || reserved < 0 {
+		return 0, fmt.Errorf("invalid sequence file %s", counter.path)
+	}
+
+	return reserved, nil
+}
+
+func (counter *Counter) reserve() error {
+	reservedUntil := counter.next + reservation - 1
+	temporaryPath := counter.path + ".tmp"
+
+	err := os.WriteFile(temporaryPath, []byte(strconv.FormatInt(reservedUntil, 10)), 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write the sequence file: %w", err)
+	}
+
+	err = os.Rename(temporaryPath, counter.path)
+	if err != nil {
+		return fmt.Errorf("failed to replace the sequence file: %w", err)
+	}
+
+	counter.reservedUntil = reservedUntil
+
+	return nil
+}
+
+// Next returns the next sequence number.
+func (counter *Counter) Next() (int64, error) {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+
+	if counter.next > counter.reservedUntil {
+		err := counter.reserve()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	seq := counter.next
+	counter.next++
+
+	return seq, nil
+}
+
+// SkipReserved continues after the range persisted in the file, when another process reserved numbers from
+// the same file, e.g. the uninstall hook next to the running agent.
+func (counter *Counter) SkipReserved() error {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+
+	reserved, err := counter.read()
+	if err != nil {
+		return err
+	}
+
+	if reserved >= counter.next {
+		counter.next = reserved + 1
+		counter.reservedUntil = reserved
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package seqfile
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seq")
+
+	counter, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last int64
+	for range 3 {
+		last, err = counter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if last != 3 {
+		t.Fatalf("Got %d, want 3", last)
+	}
+
+	t.Run("continues after the reserved range after a restart", func(t *testing.T) {
+		restarted, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seq, err := restarted.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if seq != reservation+1 {
+			t.Fatalf("Got %d, want %d", seq, reservation+1)
+		}
+	})
+
+	t.Run("skips numbers reserved by another process", func(t *testing.T) {
+		err := counter.SkipReserved()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seq, err := counter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if seq != 2*reservation+1 {
+			t.Fatalf("Got %d, want %d", seq, 2*reservation+1)
+		}
+	})
+}
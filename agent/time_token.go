@@ -0,0 +1,52 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"domanscy.group/parental-controls/server/timetokens"
+)
+
+// fetchTimeToken asks the server to attest its time, the token is valid only for the nonce.
+func fetchTimeToken(ctx context.Context, cfg *agentConfig, nonce string) (timetokens.Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	body, err := json.Marshal(map[string]string{"nonce": nonce})
+	if err != nil {
+		return timetokens.Token{}, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.ServerUrl+"/device/time_tokens", bytes.NewReader(body))
+	if err != nil {
+		return timetokens.Token{}, err
+	}
+
+	request.Header = cfg.authorizationHeader()
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return timetokens.Token{}, fmt.Errorf("failed to fetch time token: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return timetokens.Token{}, fmt.Errorf("server responded with status %d to fetching time token", response.StatusCode)
+	}
+
+	var token timetokens.Token
+
+	err = json.NewDecoder(response.Body).Decode(&token)
+	if err != nil {
+		return timetokens.Token{}, fmt.Errorf("failed to decode time token: %w", err)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,231 @@
+package trustedclock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"domanscy.group/parental-controls/server/heartbeats"
+	"domanscy.group/parental-controls/server/timetokens"
+)
+
+// MaxSkew is how far the wall clock may be from the trusted time before it is reported and, when the trusted
+// time is known, ignored. NTP corrections stay well below it.
+const MaxSkew = 2 * time.Minute
+
+// persistInterval bounds how far the clock can be set back unnoticed over a reboot, the latest estimate
+// is saved at most this often.
+const persistInterval = time.Minute
+
+const stateFileName = "clock.json"
+
+// Reporter queues tamper events for the server, see heartbeat.Sender.
+type Reporter interface {
+	Report(tamperType heartbeats.TamperType, detail string, occurredAt time.Time)
+}
+
+// anchor is a point in time together with the time since boot it was seen at, the trusted time
+// of the same boot is the anchor moved by the time since boot passed.
+type anchor struct {
+	Time      time.Time     `json:"time"`
+	BootId    string        `json:"bootId"`
+	SinceBoot time.Duration `json:"sinceBoot"`
+	// Verified anchors come from a time token of the server, the others only bound the time from below.
+	Verified bool `json:"verified"`
+}
+
+func (anchor anchor) at(sinceBoot time.Duration) time.Time {
+	return anchor.Time.Add(sinceBoot - anchor.SinceBoot)
+}
+
+type state struct {
+	Anchor anchor `json:"anchor"`
+	// Latest is the latest estimate, after a reboot the time can not be earlier than it and the time since boot.
+	Latest anchor `json:"latest"`
+}
+
+// Clock estimates the time when the wall clock can not be trusted. The estimate counts from the last time token
+// of the server by the time since boot, which the child can not change. Without a token of the current boot
+// the wall clock is used, unless it is earlier than the latest estimate before the reboot.
+type Clock struct {
+	clocks    Clocks
+	key       string
+	deviceId  int
+	statePath string
+	reporter  Reporter
+
+	mu     sync.Mutex
+	state  state
+	skewed bool
+}
+
+// New continues the estimate saved in stateDir, tokens are verified with the heartbeat key of the device.
+func New(clocks Clocks, key string, deviceId int, stateDir string, reporter Reporter) (*Clock, error) {
+	clock := &Clock{
+		clocks:    clocks,
+		key:       key,
+		deviceId:  deviceId,
+		statePath: filepath.Join(stateDir, stateFileName),
+		reporter:  reporter,
+	}
+
+	content, err := os.ReadFile(clock.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return clock, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read the clock state: %w", err)
+	}
+
+	err = json.Unmarshal(content, &clock.state)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clock state %s: %w", clock.statePath, err)
+	}
+
+	return clock, nil
+}
+
+func (clock *Clock) save() error {
+	content, err := json.Marshal(clock.state)
+	if err != nil {
+		return fmt.Errorf("failed to encode the clock state: %w", err)
+	}
+
+	temporaryPath := clock.statePath + ".tmp"
+
+	err = os.WriteFile(temporaryPath, content, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write the clock state: %w", err)
+	}
+
+	err = os.Rename(temporaryPath, clock.statePath)
+	if err != nil {
+		return fmt.Errorf("failed to replace the clock state: %w", err)
+	}
+
+	return nil
+}
+
+// NewNonce returns a random nonce to request a time token with.
+func NewNonce() (string, error) {
+	nonce := make([]byte, 16)
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate a nonce: %w", err)
+	}
+
+	return hex.EncodeToString(nonce), nil
+}
+
+// SetToken anchors the estimate to the time of the server, the token must be the response to the nonce.
+func (clock *Clock) SetToken(token timetokens.Token, nonce string) error {
+	err := token.Verify(clock.key, clock.deviceId, nonce)
+	if err != nil {
+		return err
+	}
+
+	sinceBoot, err := clock.clocks.SinceBoot()
+	if err != nil {
+		return err
+	}
+
+	bootId, err := clock.clocks.BootId()
+	if err != nil {
+		return err
+	}
+
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	clock.state.Anchor = anchor{Time: token.IssuedAt, BootId: bootId, SinceBoot: sinceBoot, Verified: true}
+	clock.state.Latest = clock.state.Anchor
+
+	return clock.save()
+}
+
+// Estimate returns the trusted time. A wall clock further than MaxSkew from it is reported once, until it is
+// back in sync.
+func (clock *Clock) Estimate() (time.Time, error) {
+	sinceBoot, err := clock.clocks.SinceBoot()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	bootId, err := clock.clocks.BootId()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	wall := clock.clocks.Now()
+
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	current := clock.state.Anchor
+	// rebooted is true when the time offline is unknown, a clock moved forward since may be right
+	rebooted := false
+
+	switch {
+	case current.Time.IsZero():
+		current = anchor{Time: wall, BootId: bootId, SinceBoot: sinceBoot}
+	case current.BootId != bootId || sinceBoot < current.SinceBoot:
+		// at least the time since boot passed after the latest estimate
+		current = anchor{Time: clock.state.Latest.Time.Add(sinceBoot), BootId: bootId, SinceBoot: sinceBoot}
+		rebooted = true
+	}
+
+	trusted := current.at(sinceBoot)
+	skew := wall.Sub(trusted)
+
+	estimate := trusted
+	if skew.Abs() <= MaxSkew {
+		estimate = wall
+	} else if skew > 0 && !current.Verified {
+		// only the time since boot is known without a token, the clock moved forward is the best guess
+		estimate = wall
+		current = anchor{Time: wall, BootId: bootId, SinceBoot: sinceBoot}
+	}
+
+	skewed := skew.Abs() > MaxSkew && (skew < 0 || !rebooted)
+	if skewed && !clock.skewed {
+		detail := fmt.Sprintf("wall clock %s from the trusted time", skew.Round(time.Second))
+		if estimate.Equal(trusted) {
+			detail += ", ignored"
+		}
+
+		clock.reporter.Report(heartbeats.TamperClockChanged, detail, estimate)
+	}
+
+	clock.skewed = skewed
+
+	if current != clock.state.Anchor || clock.state.Latest.BootId != bootId || estimate.Sub(clock.state.Latest.Time) >= persistInterval {
+		clock.state.Anchor = current
+		clock.state.Latest = anchor{Time: estimate, BootId: bootId, SinceBoot: sinceBoot}
+
+		err = clock.save()
+		if err != nil {
+			// the estimate is still right, only a reboot before the next save loses it
+			log.Printf("error occured while trying to save the clock state: %v", err)
+		}
+	}
+
+	return estimate, nil
+}
+
+// Now returns the estimate, the wall clock if the clocks of the system can not be read.
+func (clock *Clock) Now() time.Time {
+	estimate, err := clock.Estimate()
+	if err != nil {
+		log.Printf("error occured while trying to estimate the time: %v", err)
+		return time.Now()
+	}
+
+	return estimate
+}
@@ -0,0 +1,247 @@
+package trustedclock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/heartbeats"
+	"domanscy.group/parental-controls/server/timetokens"
+)
+
+type fakeClocks struct {
+	wall      time.Time
+	sinceBoot time.Duration
+	bootId    string
+}
+
+func (clocks *fakeClocks) Now() time.Time {
+	return clocks.wall
+}
+
+func (clocks *fakeClocks) SinceBoot() (time.Duration, error) {
+	return clocks.sinceBoot, nil
+}
+
+func (clocks *fakeClocks) BootId() (string, error) {
+	return clocks.bootId, nil
+}
+
+// advance moves both clocks, as time passing without anyone touching them does.
+func (clocks *fakeClocks) advance(duration time.Duration) {
+	clocks.wall = clocks.wall.Add(duration)
+	clocks.sinceBoot += duration
+}
+
+// reboot starts a new boot after the machine was off for the duration.
+func (clocks *fakeClocks) reboot(bootId string, off time.Duration) {
+	clocks.wall = clocks.wall.Add(off)
+	clocks.sinceBoot = 0
+	clocks.bootId = bootId
+}
+
+type fakeReporter struct {
+	details []string
+}
+
+func (reporter *fakeReporter) Report(tamperType heartbeats.TamperType, detail string, _ time.Time) {
+	if tamperType == heartbeats.TamperClockChanged {
+		reporter.details = append(reporter.details, detail)
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2024, 9, 1, 16, 0, 0, 0, time.UTC)
+	nonce := strings.Repeat("n", timetokens.MinNonceLength)
+
+	newClock := func(t *testing.T) (*Clock, *fakeClocks, *fakeReporter, string) {
+		clocks := &fakeClocks{wall: start, sinceBoot: time.Hour, bootId: "first"}
+		reporter := &fakeReporter{}
+		stateDir := t.TempDir()
+
+		clock, err := New(clocks, "key", 1, stateDir, reporter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return clock, clocks, reporter, stateDir
+	}
+
+	setToken := func(t *testing.T, clock *Clock, issuedAt time.Time) {
+		token, err := timetokens.Issue("key", 1, nonce, issuedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = clock.SetToken(token, nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expect := func(t *testing.T, clock *Clock, want time.Time) {
+		t.Helper()
+
+		estimate, err := clock.Estimate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !estimate.Equal(want) {
+			t.Fatalf("Got %v, want %v", estimate, want)
+		}
+	}
+
+	t.Run("follows a wall clock in sync", func(t *testing.T) {
+		clock, clocks, reporter, _ := newClock(t)
+
+		expect(t, clock, start)
+
+		clocks.advance(time.Hour)
+		clocks.wall = clocks.wall.Add(30 * time.Second)
+		expect(t, clock, clocks.wall)
+
+		if len(reporter.details) != 0 {
+			t.Fatalf("Unexpected reports: %v", reporter.details)
+		}
+	})
+
+	t.Run("ignores a skewed wall clock once the server time is known", func(t *testing.T) {
+		clock, clocks, reporter, _ := newClock(t)
+
+		setToken(t, clock, start)
+
+		clocks.advance(10 * time.Minute)
+		clocks.wall = clocks.wall.Add(-2 * time.Hour)
+		expect(t, clock, start.Add(10*time.Minute))
+
+		// suspended machines count the time since boot too
+		clocks.advance(8 * time.Hour)
+		expect(t, clock, start.Add(8*time.Hour+10*time.Minute))
+
+		clocks.wall = start.Add(48 * time.Hour)
+		expect(t, clock, start.Add(8*time.Hour+10*time.Minute))
+
+		want := []string{"wall clock -2h0m0s from the trusted time, ignored"}
+		if len(reporter.details) != 1 || reporter.details[0] != want[0] {
+			t.Fatalf("Got %v, want %v reported once while the clock stays skewed", reporter.details, want)
+		}
+
+		clocks.wall = start.Add(8*time.Hour + 10*time.Minute)
+		expect(t, clock, clocks.wall)
+
+		clocks.wall = clocks.wall.Add(24 * time.Hour)
+		expect(t, clock, start.Add(8*time.Hour+10*time.Minute))
+
+		if len(reporter.details) != 2 || reporter.details[1] != "wall clock 24h0m0s from the trusted time, ignored" {
+			t.Fatalf("Got %v, want the clock set forward reported after it was in sync", reporter.details)
+		}
+	})
+
+	t.Run("refuses a clock set back over a reboot", func(t *testing.T) {
+		clock, clocks, reporter, stateDir := newClock(t)
+
+		setToken(t, clock, start)
+
+		clocks.advance(5 * time.Hour)
+		expect(t, clock, start.Add(5*time.Hour))
+
+		clocks.reboot("second", time.Minute)
+		clocks.advance(time.Minute)
+		clocks.wall = start.Add(time.Hour)
+
+		restarted, err := New(clocks, "key", 1, stateDir, reporter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expect(t, restarted, start.Add(5*time.Hour+time.Minute))
+
+		clocks.advance(time.Minute)
+		expect(t, restarted, start.Add(5*time.Hour+2*time.Minute))
+
+		if len(reporter.details) != 1 || !strings.HasSuffix(reporter.details[0], ", ignored") {
+			t.Fatalf("Got %v, want the rollback reported", reporter.details)
+		}
+	})
+
+	t.Run("accepts time passed while the machine was off", func(t *testing.T) {
+		clock, clocks, reporter, _ := newClock(t)
+
+		setToken(t, clock, start)
+
+		clocks.reboot("second", 10*time.Hour)
+		expect(t, clock, start.Add(10*time.Hour))
+
+		clocks.advance(time.Minute)
+		expect(t, clock, start.Add(10*time.Hour+time.Minute))
+
+		if len(reporter.details) != 0 {
+			t.Fatalf("Unexpected reports: %v", reporter.details)
+		}
+
+		// without a token of this boot the jump can not be ignored, but it is reported
+		clocks.wall = clocks.wall.Add(24 * time.Hour)
+		expect(t, clock, start.Add(34*time.Hour+time.Minute))
+
+		if len(reporter.details) != 1 || reporter.details[0] != "wall clock 24h0m0s from the trusted time" {
+			t.Fatalf("Got %v, want the jump reported", reporter.details)
+		}
+	})
+
+	t.Run("rejects tokens not issued for the request", func(t *testing.T) {
+		clock, _, _, _ := newClock(t)
+
+		token, err := timetokens.Issue("key", 1, nonce, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = clock.SetToken(token, strings.Repeat("m", timetokens.MinNonceLength))
+		if !errors.Is(err, timetokens.ErrInvalidToken) {
+			t.Fatalf("Expected %v, received %v", timetokens.ErrInvalidToken, err)
+		}
+
+		forged, err := timetokens.Issue("guessed", 1, nonce, start.Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = clock.SetToken(forged, nonce)
+		if !errors.Is(err, timetokens.ErrInvalidToken) {
+			t.Fatalf("Expected %v, received %v", timetokens.ErrInvalidToken, err)
+		}
+	})
+}
+
+func TestSystemClocks(t *testing.T) {
+	dir := t.TempDir()
+	clocks := SystemClocks{UptimePath: filepath.Join(dir, "uptime"), BootIdPath: filepath.Join(dir, "boot_id")}
+
+	err := os.WriteFile(clocks.UptimePath, []byte("3600.25 7000.00\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(clocks.BootIdPath, []byte("f7aded93-14d4-440d-908b-77425fe1afbd\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sinceBoot, err := clocks.SinceBoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sinceBoot != time.Hour+250*time.Millisecond {
+		t.Fatalf("Got %v, want 1h0m0.25s", sinceBoot)
+	}
+
+	bootId, err := clocks.BootId()
+	if err != nil || bootId != "f7aded93-14d4-440d-908b-77425fe1afbd" {
+		t.Fatalf("Got %q and %v", bootId, err)
+	}
+}
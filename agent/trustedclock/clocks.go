@@ -0,0 +1,67 @@
+package trustedclock
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clocks hides the operating system from the Clock, see SystemClocks.
+type Clocks interface {
+	// Now returns the wall clock without a monotonic reading.
+	Now() time.Time
+	// SinceBoot includes the time the machine was suspended.
+	SinceBoot() (time.Duration, error)
+	// BootId changes with every boot, the time since boot starts over with it.
+	BootId() (string, error)
+}
+
+// SystemClocks reads the time since boot from /proc/uptime, which counts suspended time unlike the
+// monotonic clock of Go.
+type SystemClocks struct {
+	UptimePath string
+	BootIdPath string
+}
+
+func NewSystemClocks() SystemClocks {
+	return SystemClocks{UptimePath: "/proc/uptime", BootIdPath: "/proc/sys/kernel/random/boot_id"}
+}
+
+func (clocks SystemClocks) Now() time.Time {
+	return time.Now().Round(0)
+}
+
+func (clocks SystemClocks) SinceBoot() (time.Duration, error) {
+	content, err := os.ReadFile(clocks.UptimePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read uptime: %w", err)
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("invalid uptime %q", content)
+	}
+
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid uptime %q", content)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func (clocks SystemClocks) BootId() (string, error) {
+	content, err := os.ReadFile(clocks.BootIdPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read boot id: %w", err)
+	}
+
+	bootId := strings.TrimSpace(string(content))
+	if bootId == "" {
+		return "", fmt.Errorf("empty boot id in %s", clocks.BootIdPath)
+	}
+
+	return bootId, nil
+}
@@ -0,0 +1,241 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"domanscy.group/parental-controls/agent/seqfile"
+	"domanscy.group/parental-controls/server/activity"
+)
+
+// DefaultInterval is how often queued events are sent.
+const DefaultInterval = time.Minute
+
+// MaxQueuedEvents bounds the memory of an agent offline for long, the oldest events are dropped first.
+const MaxQueuedEvents = 20 * activity.MaxBatchSize
+
+const seqFileName = "seq"
+
+// eventsFileName keeps the events not sent yet, so the usage of a device offline over a restart reaches the server.
+const eventsFileName = "events.json"
+
+var ErrRejected = errors.New("server rejected the events")
+
+type ingestResponse struct {
+	LastSeq int64 `json:"lastSeq"`
+}
+
+// Queue collects activity events and sends them in batches to the events endpoint of the device.
+// Events not sent yet are saved in the state directory and sent after a restart.
+type Queue struct {
+	Url string
+	// Header is sent with every request, e.g. "Authorization: Device ...".
+	Header     http.Header
+	HttpClient *http.Client
+
+	seq        *seqfile.Counter
+	eventsPath string
+	mu         sync.Mutex
+	events     []activity.IncomingEvent
+}
+
+// NewQueue sends events to url, the sequence numbers and the events not sent yet are persisted in stateDir.
+func NewQueue(url string, header http.Header, stateDir string) (*Queue, error) {
+	seq, err := seqfile.Open(filepath.Join(stateDir, seqFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	queue := &Queue{
+		Url:        url,
+		Header:     header,
+		HttpClient: &http.Client{Timeout: 30 * time.Second},
+		seq:        seq,
+		eventsPath: filepath.Join(stateDir, eventsFileName),
+	}
+
+	content, err := os.ReadFile(queue.eventsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return queue, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read the queued events: %w", err)
+	}
+
+	err = json.Unmarshal(content, &queue.events)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queued events file %s: %w", queue.eventsPath, err)
+	}
+
+	return queue, nil
+}
+
+func (queue *Queue) save() error {
+	content, err := json.Marshal(queue.events)
+	if err != nil {
+		return fmt.Errorf("failed to encode the queued events: %w", err)
+	}
+
+	temporaryPath := queue.eventsPath + ".tmp"
+
+	err = os.WriteFile(temporaryPath, content, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write the queued events: %w", err)
+	}
+
+	err = os.Rename(temporaryPath, queue.eventsPath)
+	if err != nil {
+		return fmt.Errorf("failed to replace the queued events: %w", err)
+	}
+
+	return nil
+}
+
+// Add queues an event, payload is encoded to JSON.
+func (queue *Queue) Add(eventType activity.Type, payload any, occurredAt time.Time) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode the payload: %w", err)
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	seq, err := queue.seq.Next()
+	if err != nil {
+		return err
+	}
+
+	queue.events = append(queue.events, activity.IncomingEvent{
+		Seq:        seq,
+		Type:       eventType,
+		OccurredAt: occurredAt.UTC(),
+		Payload:    encoded,
+	})
+
+	if len(queue.events) > MaxQueuedEvents {
+		queue.events = queue.events[len(queue.events)-MaxQueuedEvents:]
+	}
+
+	return queue.save()
+}
+
+// Len returns the number of events waiting to be sent.
+func (queue *Queue) Len() int {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	return len(queue.events)
+}
+
+// drop removes the sent events, new ones may have been added during the request.
+func (queue *Queue) drop(lastSeq int64) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	sent := 0
+	for sent < len(queue.events) && queue.events[sent].Seq <= lastSeq {
+		sent++
+	}
+
+	queue.events = queue.events[sent:]
+
+	err := queue.save()
+	if err != nil {
+		// sent again after a restart, the server skips events it already has by their sequence numbers
+		log.Printf("error occured while trying to save the queued events: %v", err)
+	}
+}
+
+// Flush sends the queued events in batches. A batch the server rejects as invalid is dropped,
+// sending it again would not help and would hold back the events after it.
+func (queue *Queue) Flush(ctx context.Context) error {
+	for {
+		queue.mu.Lock()
+		batch := queue.events[:min(len(queue.events), activity.MaxBatchSize)]
+		queue.mu.Unlock()
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		lastSeq, err := queue.send(ctx, batch)
+		if errors.Is(err, ErrRejected) {
+			log.Printf("dropping %d events: %v", len(batch), err)
+			lastSeq = batch[len(batch)-1].Seq
+		} else if err != nil {
+			return err
+		}
+
+		queue.drop(lastSeq)
+	}
+}
+
+func (queue *Queue) send(ctx context.Context, batch []activity.IncomingEvent) (int64, error) {
+	body, err := json.Marshal(map[string]any{"events": batch})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode the events: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, queue.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	for name, values := range queue.Header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := queue.HttpClient.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send the events: %w", err)
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusOK:
+	case response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusRequestEntityTooLarge:
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return 0, fmt.Errorf("%w: %s", ErrRejected, message)
+	default:
+		return 0, fmt.Errorf("server responded with status %d", response.StatusCode)
+	}
+
+	var decoded ingestResponse
+
+	err = json.NewDecoder(response.Body).Decode(&decoded)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode the response: %w", err)
+	}
+
+	return decoded.LastSeq, nil
+}
+
+// Run flushes every interval until ctx is done, a failed flush is retried on the next tick.
+func (queue *Queue) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		err := queue.Flush(ctx)
+		if err != nil {
+			log.Printf("error occured while trying to send activity events: %v", err)
+		}
+	}
+}
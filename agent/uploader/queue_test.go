@@ -0,0 +1,145 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/activity"
+)
+
+func TestQueue(t *testing.T) {
+	occurredAt := time.Date(2024, 9, 1, 16, 0, 0, 0, time.UTC)
+
+	t.Run("continues sequence numbers after a restart", func(t *testing.T) {
+		stateDir := t.TempDir()
+
+		queue, err := NewQueue("http://localhost", nil, stateDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for range 3 {
+			err = queue.Add(activity.TypeScreenOn, activity.ScreenPayload{}, occurredAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		restarted, err := NewQueue("http://localhost", nil, stateDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = restarted.Add(activity.TypeScreenOff, activity.ScreenPayload{}, occurredAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if restarted.Len() != 4 || restarted.events[3].Seq <= queue.events[2].Seq {
+			t.Fatalf("Got %+v after a restart, want the queued events kept and a seq more than %d", restarted.events, queue.events[2].Seq)
+		}
+	})
+
+	t.Run("sends batches and keeps events until they are accepted", func(t *testing.T) {
+		var received [][]activity.IncomingEvent
+		failing := true
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Device token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if failing {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			var body struct {
+				Events []activity.IncomingEvent `json:"events"`
+			}
+
+			err := json.NewDecoder(r.Body).Decode(&body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			received = append(received, body.Events)
+
+			err = json.NewEncoder(w).Encode(map[string]any{"lastSeq": body.Events[len(body.Events)-1].Seq})
+			if err != nil {
+				t.Error(err)
+			}
+		}))
+		defer server.Close()
+
+		stateDir := t.TempDir()
+
+		queue, err := NewQueue(server.URL, http.Header{"Authorization": {"Device token"}}, stateDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for range activity.MaxBatchSize + 1 {
+			err = queue.Add(activity.TypeAppStart, activity.AppPayload{App: "minecraft"}, occurredAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = queue.Flush(context.Background())
+		if err == nil || queue.Len() != activity.MaxBatchSize+1 {
+			t.Fatalf("Got %v with %d queued events, want an error and all events kept", err, queue.Len())
+		}
+
+		failing = false
+
+		err = queue.Flush(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(received) != 2 || len(received[0]) != activity.MaxBatchSize || len(received[1]) != 1 || queue.Len() != 0 {
+			t.Fatalf("Got %d batches and %d queued events, want 2 batches and none queued", len(received), queue.Len())
+		}
+
+		if string(received[1][0].Payload) != `{"app":"minecraft"}` {
+			t.Fatalf("Unexpected payload %s", received[1][0].Payload)
+		}
+
+		restarted, err := NewQueue(server.URL, nil, stateDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if restarted.Len() != 0 {
+			t.Fatalf("Got %d queued events after a restart, want the sent events forgotten", restarted.Len())
+		}
+	})
+
+	t.Run("drops batches the server rejects", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		queue, err := NewQueue(server.URL, nil, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = queue.Add(activity.TypeBlock, activity.BlockPayload{App: "supertux2", Reason: "app_rule"}, occurredAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = queue.Flush(context.Background())
+		if err != nil || queue.Len() != 0 {
+			t.Fatalf("Got %v with %d queued events, want the batch dropped", err, queue.Len())
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package components
+
+import (
+	"time"
+
+	"domanscy.group/parental-controls/client/i18n"
+	"github.com/chasefleming/elem-go"
+	"github.com/chasefleming/elem-go/attrs"
+)
+
+type BlockPageParams struct {
+	Domain string
+	// Reason is "blocklist", "category" or empty if the client could not be recognised.
+	Reason   string
+	Rule     string
+	Category string
+	// AskForAccessUrl is where the "ask for access" form is posted, the button is hidden when it is empty.
+	AskForAccessUrl string
+}
+
+func centeredCard(language i18n.Language, title string, elements ...elem.Node) *elem.Element {
+	return LocalizedTemplate(language, title,
+		elem.Div(attrs.Props{
+			attrs.Class: "flex flex-col min-h-screen",
+		},
+			Navbar(),
+			elem.Div(attrs.Props{
+				attrs.Class: "w-full flex-grow flex justify-center",
+			},
+				elem.Div(attrs.Props{
+					attrs.Class: "w-full max-w-lg rounded p-4 m-2 gap-4 flex flex-col",
+				}, elements...),
+			),
+		),
+	)
+}
+
+func cardHeader(text string) *elem.Element {
+	return elem.H2(attrs.Props{
+		attrs.Class: "text-2xl font-semibold text-center",
+	}, elem.Text(text))
+}
+
+func cardParagraph(text string) *elem.Element {
+	return elem.P(attrs.Props{
+		attrs.Class: "text-neutral-200 text-center",
+	}, elem.Text(text))
+}
+
+func BlockPage(language i18n.Language, params BlockPageParams) *elem.Element {
+	var reason string
+
+	switch params.Reason {
+	case "blocklist":
+		reason = catalog.Text(language, "block_page.reason_blocklist", params.Rule)
+	case "category":
+		category, found := catalog.Lookup(language, "category."+params.Category)
+		if !found {
+			category = params.Category
+		}
+
+		reason = catalog.Text(language, "block_page.reason_category", category, params.Rule)
+	default:
+		reason = catalog.Text(language, "block_page.reason_unknown")
+	}
+
+	return centeredCard(language, catalog.Text(language, "block_page.title"),
+		cardHeader(catalog.Text(language, "block_page.header")),
+		cardParagraph(catalog.Text(language, "block_page.blocked", params.Domain)),
+		cardParagraph(reason),
+		elem.If[elem.Node](params.AskForAccessUrl != "",
+			elem.Form(attrs.Props{
+				attrs.Class:  "flex flex-col gap-1 justify-center items-center",
+				attrs.Method: "post",
+				attrs.Action: params.AskForAccessUrl,
+			},
+				elem.Input(attrs.Props{
+					attrs.Type:  "hidden",
+					attrs.Name:  "domain",
+					attrs.Value: params.Domain,
+				}),
+				elem.Button(attrs.Props{
+					attrs.Type:  "submit",
+					attrs.Class: "px-4 py-2 bg-blue-700/50 hover:bg-blue-600 focus:bg-blue-600 focus:outline focus:outline-blue-500 rounded w-full",
+				}, elem.Text(catalog.Text(language, "block_page.ask_for_access"))),
+				elem.P(attrs.Props{
+					attrs.Class: "text-sm text-neutral-400 text-center",
+				}, elem.Text(catalog.Text(language, "block_page.ask_for_access_hint"))),
+			),
+			elem.None(),
+		),
+	)
+}
+
+func AccessRequestSentPage(language i18n.Language, domain string) *elem.Element {
+	return centeredCard(language, catalog.Text(language, "block_page.title"),
+		cardHeader(catalog.Text(language, "access_request.sent_header")),
+		cardParagraph(catalog.Text(language, "access_request.sent", domain)),
+	)
+}
+
+func AccessRequestApprovedPage(language i18n.Language, childName string, domain string, expiresAt time.Time) *elem.Element {
+	header := catalog.Text(language, "access_request.approved_header")
+
+	return centeredCard(language, header,
+		cardHeader(header),
+		cardParagraph(catalog.Text(language, "access_request.approved", childName, domain, expiresAt.Format("2006-01-02 15:04"))),
+	)
+}
+
+func AccessRequestDeniedPage(language i18n.Language, childName string, domain string) *elem.Element {
+	header := catalog.Text(language, "access_request.denied_header")
+
+	return centeredCard(language, header,
+		cardHeader(header),
+		cardParagraph(catalog.Text(language, "access_request.denied", childName, domain)),
+	)
+}
+
+func AccessRequestAlreadyDecidedPage(language i18n.Language) *elem.Element {
+	alreadyDecided := catalog.Text(language, "access_request.already_decided")
+
+	return centeredCard(language, alreadyDecided,
+		cardParagraph(alreadyDecided),
+	)
+}
+
+func AccessRequestExpiredPage(language i18n.Language) *elem.Element {
+	expired := catalog.Text(language, "access_request.expired")
+
+	return centeredCard(language, expired,
+		cardParagraph(expired),
+	)
+}
@@ -0,0 +1,82 @@
+package components
+
+import "domanscy.group/parental-controls/client/i18n"
+
+// catalog holds the texts of the pages of the components.
+var catalog = i18n.Catalog{
+	i18n.LanguagePolish: {
+		"login.header":       "Logowanie",
+		"login.email":        "Adres email",
+		"login.submit":       "Zaloguj",
+		"login.register_cta": "Nie masz konta? Kliknij tutaj.",
+
+		"block_page.title":               "Strona zablokowana",
+		"block_page.header":              "Ta strona jest zablokowana",
+		"block_page.blocked":             "Dostęp do %s został zablokowany przez kontrolę rodzicielską.",
+		"block_page.reason_blocklist":    "Domena znajduje się na liście blokowanych stron (reguła: %s).",
+		"block_page.reason_category":     "Domena należy do kategorii „%s” (wpis: %s).",
+		"block_page.reason_unknown":      "Ta strona nie jest dostępna w Twojej sieci.",
+		"block_page.ask_for_access":      "Poproś o dostęp",
+		"block_page.ask_for_access_hint": "Rodzic dostanie wiadomość i może zezwolić na dostęp przez określony czas.",
+
+		"category.adult":        "Treści dla dorosłych",
+		"category.gambling":     "Hazard",
+		"category.social_media": "Media społecznościowe",
+		"category.gaming":       "Gry",
+		"category.malware":      "Złośliwe oprogramowanie",
+		"category.ads":          "Reklamy",
+
+		"access_request.sent_header":     "Prośba została wysłana",
+		"access_request.sent":            "Rodzic otrzymał prośbę o dostęp do %s. Odśwież stronę, gdy ją zatwierdzi.",
+		"access_request.approved_header": "Dostęp przyznany",
+		"access_request.approved":        "%s może korzystać z %s do %s.",
+		"access_request.denied_header":   "Prośba odrzucona",
+		"access_request.denied":          "%s nie otrzyma dostępu do %s.",
+		"access_request.already_decided": "Ta prośba została już rozpatrzona.",
+		"access_request.expired":         "Ta prośba wygasła, dziecko może poprosić o dostęp ponownie.",
+
+		"time_extension.approved_header": "Dodatkowy czas przyznany",
+		"time_extension.approved.one":    "%s dostanie %d minutę dodatkowego czasu.",
+		"time_extension.approved.few":    "%s dostanie %d minuty dodatkowego czasu.",
+		"time_extension.approved.many":   "%s dostanie %d minut dodatkowego czasu.",
+		"time_extension.denied_header":   "Prośba odrzucona",
+		"time_extension.denied":          "%s nie dostanie dodatkowego czasu.",
+	},
+	i18n.LanguageEnglish: {
+		"login.header":       "Log in",
+		"login.email":        "Email address",
+		"login.submit":       "Log in",
+		"login.register_cta": "No account yet? Click here.",
+
+		"block_page.title":               "Page blocked",
+		"block_page.header":              "This page is blocked",
+		"block_page.blocked":             "Access to %s has been blocked by parental controls.",
+		"block_page.reason_blocklist":    "The domain is on the list of blocked sites (rule: %s).",
+		"block_page.reason_category":     "The domain belongs to the \"%s\" category (entry: %s).",
+		"block_page.reason_unknown":      "This page is not available on your network.",
+		"block_page.ask_for_access":      "Ask for access",
+		"block_page.ask_for_access_hint": "Your parent will get a message and can allow access for a limited time.",
+
+		"category.adult":        "Adult content",
+		"category.gambling":     "Gambling",
+		"category.social_media": "Social media",
+		"category.gaming":       "Gaming",
+		"category.malware":      "Malware",
+		"category.ads":          "Ads",
+
+		"access_request.sent_header":     "Request sent",
+		"access_request.sent":            "Your parent has been asked for access to %s. Refresh the page once they approve it.",
+		"access_request.approved_header": "Access granted",
+		"access_request.approved":        "%s can use %s until %s.",
+		"access_request.denied_header":   "Request denied",
+		"access_request.denied":          "%s will not get access to %s.",
+		"access_request.already_decided": "This request has already been decided.",
+		"access_request.expired":         "This request has expired, the child can ask for access again.",
+
+		"time_extension.approved_header": "Extra time granted",
+		"time_extension.approved.one":    "%s gets %d minute of extra time.",
+		"time_extension.approved.other":  "%s gets %d minutes of extra time.",
+		"time_extension.denied_header":   "Request denied",
+		"time_extension.denied":          "%s will not get extra time.",
+	},
+}
@@ -0,0 +1,9 @@
+package components
+
+import "testing"
+
+func TestCatalogHasAllLanguages(t *testing.T) {
+	if missing := catalog.Missing(); len(missing) > 0 {
+		t.Fatalf("Missing translations: %v", missing)
+	}
+}
@@ -1,14 +1,15 @@
 package components
 
 import (
+	"domanscy.group/parental-controls/client/i18n"
 	"github.com/chasefleming/elem-go"
 	"github.com/chasefleming/elem-go/attrs"
 )
 
 type GetUrlCallback func(name string, args map[string]interface{}) string
 
-func LoginPage(getUrl GetUrlCallback) *elem.Element {
-	return Template(
+func LoginPage(language i18n.Language, getUrl GetUrlCallback) *elem.Element {
+	return LocalizedTemplate(language, "Parental controls",
 		elem.Div(attrs.Props{
 			attrs.Class: "flex flex-col min-h-screen",
 		},
@@ -21,7 +22,7 @@ func LoginPage(getUrl GetUrlCallback) *elem.Element {
 				},
 					elem.H2(attrs.Props{
 						attrs.Class: "text-2xl font-semibold text-center",
-					}, elem.Text("Logowanie")),
+					}, elem.Text(catalog.Text(language, "login.header"))),
 
 					elem.Div(attrs.Props{
 						attrs.Class: "flex flex-col gap-1",
@@ -29,7 +30,7 @@ func LoginPage(getUrl GetUrlCallback) *elem.Element {
 						elem.Label(attrs.Props{
 							attrs.Class: "w-full text-neutral-200",
 							attrs.For:   "email",
-						}, elem.Text("Adres email")),
+						}, elem.Text(catalog.Text(language, "login.email"))),
 
 						elem.Input(attrs.Props{
 							attrs.Class: "w-full p-2 bg-zinc-900/80 rounded focus:outline focus:outline-blue-500",
@@ -43,11 +44,11 @@ func LoginPage(getUrl GetUrlCallback) *elem.Element {
 					},
 						elem.Button(attrs.Props{
 							attrs.Class: "px-4 py-2 bg-blue-700/50 hover:bg-blue-600 focus:bg-blue-600 focus:outline focus:outline-blue-500 rounded w-full",
-						}, elem.Text("Zaloguj")),
+						}, elem.Text(catalog.Text(language, "login.submit"))),
 						elem.A(attrs.Props{
 							attrs.Class: "px-4 py-2 text-blue-300",
 							attrs.Href:  getUrl("register", nil),
-						}, elem.Text("Nie masz konta? Kliknij tutaj.")),
+						}, elem.Text(catalog.Text(language, "login.register_cta"))),
 					),
 				),
 			),
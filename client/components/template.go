@@ -3,7 +3,9 @@ package components
 import (
 	"embed"
 	"fmt"
+	"io/fs"
 
+	"domanscy.group/parental-controls/client/i18n"
 	"github.com/chasefleming/elem-go"
 	"github.com/chasefleming/elem-go/attrs"
 )
@@ -44,15 +46,29 @@ func init() {
 	tailwindJsFileTimestamp = fileInfo.ModTime().UnixMilli()
 }
 
+// Assets returns the files referenced by Template, to be served under /assets/ by every server rendering the components.
+func Assets() fs.FS {
+	assets, err := fs.Sub(assetsFS, "local-assets-dir")
+	if err != nil {
+		panic(err)
+	}
+
+	return assets
+}
+
 func Template(elements ...elem.Node) *elem.Element {
+	return LocalizedTemplate(i18n.DefaultLanguage, "Parental controls", elements...)
+}
+
+func LocalizedTemplate(language i18n.Language, title string, elements ...elem.Node) *elem.Element {
 	// timestampsMutex.Lock()
 	// defer timestampsMutex.Unlock()
 
 	return elem.Html(attrs.Props{
-		attrs.Lang: "pl",
+		attrs.Lang: string(language),
 	},
 		elem.Head(nil,
-			elem.Title(nil, elem.Text("Parental controls")),
+			elem.Title(nil, elem.Text(title)),
 
 			elem.Meta(attrs.Props{
 				attrs.Name:    "unicode",
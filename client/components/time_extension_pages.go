@@ -0,0 +1,24 @@
+package components
+
+import (
+	"domanscy.group/parental-controls/client/i18n"
+	"github.com/chasefleming/elem-go"
+)
+
+func TimeExtensionApprovedPage(language i18n.Language, childName string, grantedMinutes int) *elem.Element {
+	header := catalog.Text(language, "time_extension.approved_header")
+
+	return centeredCard(language, header,
+		cardHeader(header),
+		cardParagraph(catalog.Text(language, "time_extension.approved", childName, grantedMinutes)),
+	)
+}
+
+func TimeExtensionDeniedPage(language i18n.Language, childName string) *elem.Element {
+	header := catalog.Text(language, "time_extension.denied_header")
+
+	return centeredCard(language, header,
+		cardHeader(header),
+		cardParagraph(catalog.Text(language, "time_extension.denied", childName)),
+	)
+}
@@ -7,6 +7,7 @@ import (
 	"net/smtp"
 
 	"domanscy.group/parental-controls/client/components"
+	"domanscy.group/parental-controls/client/i18n"
 )
 
 func GetUrl(opts ServerOpts) func(string, map[string]interface{}) string {
@@ -22,7 +23,7 @@ func GetUrl(opts ServerOpts) func(string, map[string]interface{}) string {
 
 func RenderLoginPageHttpHandler(opts ServerOpts) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		_, err := w.Write([]byte(components.LoginPage(GetUrl(opts)).Render()))
+		_, err := w.Write([]byte(components.LoginPage(i18n.PreferredLanguage(r.Header.Get("Accept-Language")), GetUrl(opts)).Render()))
 		if err != nil {
 			log.Printf("Error occured while trying to write body data: %s\n", err.Error())
 		}
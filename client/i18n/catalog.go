@@ -0,0 +1,130 @@
+package i18n
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Messages are the texts of one language by key, formatted with fmt.Sprintf. A plural message has a key per plural
+// form of the language instead, e.g. "minutes.one", "minutes.few" and "minutes.many" in Polish, see PluralFormOf.
+// Messages leaving out some of the arguments refer to the others by index, e.g. "%[1]s dostanie minutę".
+type Messages map[string]string
+
+// Catalog holds the messages of a part of the app, e.g. of the emails, in every language.
+type Catalog map[Language]Messages
+
+// Lookup formats the message of the key in the language with the arguments, the form of a plural message is picked
+// by its first int argument. Messages without verbs ignore the arguments, e.g. the Polish one form "godzinę". It
+// reports false when the language has no such message.
+func (catalog Catalog) Lookup(language Language, key string, args ...any) (string, bool) {
+	messages := catalog[language]
+
+	message, found := messages[key]
+	if !found {
+		n, counted := firstInt(args)
+		if !counted {
+			return "", false
+		}
+
+		message, found = messages[key+"."+string(PluralFormOf(language, n))]
+		if !found {
+			message, found = messages[key+"."+string(PluralOther)]
+		}
+
+		if !found {
+			return "", false
+		}
+	}
+
+	if len(args) == 0 || !strings.Contains(message, "%") {
+		return message, true
+	}
+
+	return fmt.Sprintf(message, args...), true
+}
+
+// Text is the message of the key in the language, or in DefaultLanguage when the language lacks it. A message missing
+// in both is shown as its key, so it is noticed instead of breaking the page.
+func (catalog Catalog) Text(language Language, key string, args ...any) string {
+	for _, candidate := range []Language{language, DefaultLanguage} {
+		message, found := catalog.Lookup(candidate, key, args...)
+		if found {
+			return message
+		}
+	}
+
+	return key
+}
+
+// TemplateFuncs translate html and text templates into the language with {{ t "key" .Argument }}. Templates are
+// parsed with the functions of any language and cloned with the ones of the reader before they are executed.
+func (catalog Catalog) TemplateFuncs(language Language) map[string]any {
+	return map[string]any{
+		"t": func(key string, args ...any) string {
+			return catalog.Text(language, key, args...)
+		},
+	}
+}
+
+// Missing lists the messages some language of Languages lacks, e.g. "en: subject", and the plural forms a language
+// needs but lacks, e.g. "pl: minutes.few". The tests of the catalogs check it is empty.
+func (catalog Catalog) Missing() []string {
+	keys := make(map[string]bool)
+	plurals := make(map[string]bool)
+
+	for _, messages := range catalog {
+		for key := range messages {
+			base, isPlural := pluralBase(key)
+			keys[base] = true
+			plurals[base] = plurals[base] || isPlural
+		}
+	}
+
+	var missing []string
+
+	for _, language := range Languages {
+		for key := range keys {
+			if !plurals[key] {
+				if _, found := catalog[language][key]; !found {
+					missing = append(missing, fmt.Sprintf("%s: %s", language, key))
+				}
+
+				continue
+			}
+
+			for _, form := range pluralForms[language] {
+				if _, found := catalog[language][key+"."+string(form)]; !found {
+					missing = append(missing, fmt.Sprintf("%s: %s.%s", language, key, form))
+				}
+			}
+		}
+	}
+
+	slices.Sort(missing)
+
+	return missing
+}
+
+func pluralBase(key string) (string, bool) {
+	for _, form := range []PluralForm{PluralOne, PluralFew, PluralMany, PluralOther} {
+		if base, found := strings.CutSuffix(key, "."+string(form)); found {
+			return base, true
+		}
+	}
+
+	return key, false
+}
+
+func firstInt(args []any) (int, bool) {
+	for _, arg := range args {
+		switch n := arg.(type) {
+		case int:
+			return n, true
+		case int64:
+			return int(n), true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,132 @@
+package i18n
+
+import (
+	"bytes"
+	"html/template"
+	"reflect"
+	"testing"
+)
+
+func TestMatchLanguage(t *testing.T) {
+	cases := []struct {
+		acceptLanguage string
+		want           Language
+		found          bool
+	}{
+		{"", "", false},
+		{"de-DE,fr;q=0.8", "", false},
+		{"en-GB,en;q=0.9", LanguageEnglish, true},
+		{"de, pl;q=0.5, EN;q=0.7", LanguageEnglish, true},
+		{"en;q=0, pl;q=0.1", LanguagePolish, true},
+		{"en;q=invalid, pl;q=0.1", LanguagePolish, true},
+	}
+
+	for _, c := range cases {
+		language, found := MatchLanguage(c.acceptLanguage)
+		if language != c.want || found != c.found {
+			t.Errorf("%q: expected %q %v, received %q %v", c.acceptLanguage, c.want, c.found, language, found)
+		}
+	}
+
+	if PreferredLanguage("de") != DefaultLanguage {
+		t.Fatalf("Expected the default language for unsupported ones, received %q", PreferredLanguage("de"))
+	}
+}
+
+func TestPluralFormOf(t *testing.T) {
+	polish := map[int]PluralForm{0: PluralMany, 1: PluralOne, 2: PluralFew, 4: PluralFew, 5: PluralMany, 12: PluralMany, 14: PluralMany,
+		21: PluralMany, 22: PluralFew, 104: PluralFew, 112: PluralMany, -3: PluralFew}
+	english := map[int]PluralForm{0: PluralOther, 1: PluralOne, 2: PluralOther, 21: PluralOther}
+
+	for n, want := range polish {
+		if form := PluralFormOf(LanguagePolish, n); form != want {
+			t.Errorf("pl %d: expected %s, received %s", n, want, form)
+		}
+	}
+
+	for n, want := range english {
+		if form := PluralFormOf(LanguageEnglish, n); form != want {
+			t.Errorf("en %d: expected %s, received %s", n, want, form)
+		}
+	}
+}
+
+func newTestCatalog() Catalog {
+	return Catalog{
+		LanguagePolish: {
+			"greeting":     "Cześć %s!",
+			"minutes.one":  "%[1]s dostanie minutę",
+			"minutes.few":  "%s dostanie %d minuty",
+			"minutes.many": "%s dostanie %d minut",
+			"only_polish":  "Tylko po polsku",
+		},
+		LanguageEnglish: {
+			"greeting":      "Hello %s!",
+			"minutes.one":   "%[1]s gets a minute",
+			"minutes.other": "%s gets %d minutes",
+		},
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	testCatalog := newTestCatalog()
+
+	cases := []struct {
+		language Language
+		key      string
+		args     []any
+		want     string
+	}{
+		{LanguageEnglish, "greeting", []any{"Ada"}, "Hello Ada!"},
+		{LanguagePolish, "minutes", []any{"Ada", 1}, "Ada dostanie minutę"},
+		{LanguagePolish, "minutes", []any{"Ada", 22}, "Ada dostanie 22 minuty"},
+		{LanguagePolish, "minutes", []any{"Ada", 25}, "Ada dostanie 25 minut"},
+		{LanguageEnglish, "minutes", []any{"Ada", 25}, "Ada gets 25 minutes"},
+		{LanguageEnglish, "only_polish", nil, "Tylko po polsku"},
+		{LanguageEnglish, "unknown", nil, "unknown"},
+	}
+
+	for _, c := range cases {
+		if text := testCatalog.Text(c.language, c.key, c.args...); text != c.want {
+			t.Errorf("%s %s %v: expected %q, received %q", c.language, c.key, c.args, c.want, text)
+		}
+	}
+
+	if _, found := testCatalog.Lookup(LanguageEnglish, "only_polish"); found {
+		t.Fatal("Expected Lookup not to fall back to the default language")
+	}
+
+	if _, found := testCatalog.Lookup(LanguagePolish, "minutes", "Ada"); found {
+		t.Fatal("Expected plural messages to require a count")
+	}
+
+	want := []string{"en: only_polish"}
+	if missing := testCatalog.Missing(); !reflect.DeepEqual(missing, want) {
+		t.Fatalf("Expected %v, received %v", want, missing)
+	}
+
+	delete(testCatalog[LanguagePolish], "minutes.few")
+
+	want = []string{"en: only_polish", "pl: minutes.few"}
+	if missing := testCatalog.Missing(); !reflect.DeepEqual(missing, want) {
+		t.Fatalf("Expected %v, received %v", want, missing)
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	testCatalog := newTestCatalog()
+
+	parsed := template.Must(template.New("page").Funcs(testCatalog.TemplateFuncs(DefaultLanguage)).Parse(`<p>{{ t "greeting" .Name }}</p>`))
+
+	translated := template.Must(parsed.Clone()).Funcs(testCatalog.TemplateFuncs(LanguageEnglish))
+
+	var body bytes.Buffer
+	err := translated.Execute(&body, struct{ Name string }{"<Ada>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body.String() != "<p>Hello &lt;Ada&gt;!</p>" {
+		t.Fatalf("Unexpected body %q", body.String())
+	}
+}
@@ -0,0 +1,79 @@
+// Package i18n translates the texts of the pages, the emails and the api messages. Texts live in catalogs by language
+// and key, see Catalog, the language of the reader comes from their preference, the Accept-Language header of the
+// request or the client they came from, see ParseLanguage and MatchLanguage.
+package i18n
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Language string
+
+const (
+	LanguagePolish  Language = "pl"
+	LanguageEnglish Language = "en"
+)
+
+// DefaultLanguage is used when nothing tells the language of the reader, the app started as a Polish one.
+const DefaultLanguage = LanguagePolish
+
+// Languages are the supported languages, every catalog should have the messages of all of them.
+var Languages = []Language{LanguagePolish, LanguageEnglish}
+
+// ParseLanguage reads a language tag, e.g. "en-GB", and reports whether its language is supported.
+func ParseLanguage(tag string) (Language, bool) {
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+
+	for _, language := range Languages {
+		if Language(primary) == language {
+			return language, true
+		}
+	}
+
+	return "", false
+}
+
+// MatchLanguage picks the supported language with the highest weight from the Accept-Language header, it reports
+// false when the header names none of them.
+func MatchLanguage(acceptLanguage string) (Language, bool) {
+	var bestLanguage Language
+	bestWeight := 0.0
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, parameters, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+		weight := 1.0
+
+		if value, found := strings.CutPrefix(strings.TrimSpace(parameters), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+
+			weight = parsed
+		}
+
+		language, supported := ParseLanguage(tag)
+		if !supported {
+			continue
+		}
+
+		if weight > bestWeight {
+			bestLanguage = language
+			bestWeight = weight
+		}
+	}
+
+	return bestLanguage, bestLanguage != ""
+}
+
+// PreferredLanguage is the language matched from the Accept-Language header, falling back to DefaultLanguage.
+func PreferredLanguage(acceptLanguage string) Language {
+	language, found := MatchLanguage(acceptLanguage)
+	if !found {
+		return DefaultLanguage
+	}
+
+	return language
+}
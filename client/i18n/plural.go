@@ -0,0 +1,43 @@
+package i18n
+
+type PluralForm string
+
+// The plural categories of the Unicode CLDR, only the ones of the supported languages.
+const (
+	PluralOne   PluralForm = "one"
+	PluralFew   PluralForm = "few"
+	PluralMany  PluralForm = "many"
+	PluralOther PluralForm = "other"
+)
+
+// PluralFormOf picks the plural form of the count in the language, following the CLDR rules for integers:
+// Polish has "1 minuta", "2 minuty", "5 minut" and "22 minuty", English has "1 minute" and "2 minutes".
+func PluralFormOf(language Language, n int) PluralForm {
+	if n < 0 {
+		n = -n
+	}
+
+	switch language {
+	case LanguagePolish:
+		switch {
+		case n == 1:
+			return PluralOne
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	default:
+		if n == 1 {
+			return PluralOne
+		}
+
+		return PluralOther
+	}
+}
+
+// pluralForms are the forms plural messages need in each language, PluralFormOf never picks the others.
+var pluralForms = map[Language][]PluralForm{
+	LanguagePolish:  {PluralOne, PluralFew, PluralMany},
+	LanguageEnglish: {PluralOne, PluralOther},
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math"
+	"net"
 	"net/url"
 	"os"
 	"strconv"
@@ -113,3 +114,31 @@ func ParseUint16Var(envName string) (value uint16, valid bool, err error) {
 
 	return parsedWithCast, true, nil
 }
+
+func ParseIpVar(envName string) (value net.IP, exists bool, err error) {
+	rawValue, exists := os.LookupEnv(envName)
+	if !exists {
+		return nil, false, nil
+	}
+
+	value = net.ParseIP(rawValue)
+	if value == nil {
+		return nil, true, fmt.Errorf("env '%s' must be a valid ipv4 or ipv6 address", envName)
+	}
+
+	return value, true, nil
+}
+
+func ParseHostPortVar(envName string) (value string, exists bool, err error) {
+	rawValue, exists := os.LookupEnv(envName)
+	if !exists {
+		return "", false, nil
+	}
+
+	_, _, err = net.SplitHostPort(rawValue)
+	if err != nil {
+		return "", true, fmt.Errorf("env '%s' must be in host:port format: %w", envName, err)
+	}
+
+	return rawValue, true, nil
+}
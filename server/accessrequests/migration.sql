@@ -0,0 +1,15 @@
+CREATE TABLE access_requests (
+    id INTEGER PRIMARY KEY,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    device_id INTEGER REFERENCES devices (id) ON DELETE SET NULL,
+    domain VARCHAR NOT NULL,
+    reason VARCHAR NOT NULL,
+    rule VARCHAR NOT NULL,
+    status VARCHAR NOT NULL DEFAULT 'pending',
+    token VARCHAR NOT NULL UNIQUE,
+    expires_at TIMESTAMP,
+    decided_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX access_requests_child_id_domain ON access_requests (child_id, domain);
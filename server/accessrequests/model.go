@@ -0,0 +1,246 @@
+package accessrequests
+
+import (
+	"crypto/rand"
+	"database/sql"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrDomainCannotBeEmpty = errors.New("domain can not be empty")
+var ErrAccessRequestWithThisIdDoesNotExist = errors.New("access request with this id does not exist")
+var ErrAccessRequestIsAlreadyDecided = errors.New("access request is already approved or denied")
+var ErrExpirationMustBeInTheFuture = errors.New("expiration of the exception must be in the future")
+var ErrAccessRequestHasExpired = errors.New("access request has expired")
+
+// PendingTtl is how long the parent can decide about a request, after it the links of the email stop working
+// and the child asks again instead of waiting for a request the parent has long forgotten.
+const PendingTtl = 24 * time.Hour
+
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusDenied   Status = "denied"
+)
+
+type Model struct {
+	Id      int
+	ChildId int
+	// DeviceId is 0 when the device the request came from has been removed.
+	DeviceId int
+	Domain   string
+	// Reason and Rule describe why the domain was blocked when the child asked for access.
+	Reason string
+	Rule   string
+	Status Status
+	// Token authorizes the approve and deny links sent to the parent.
+	Token string
+	// ExpiresAt is the end of the exception, zero unless the request was approved.
+	ExpiresAt time.Time
+	// DecidedAt is zero while the request is pending.
+	DecidedAt time.Time
+	CreatedAt time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("an unknown error occured while trying to generate random bytes using crypto/rand.Read: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+const selectColumns = "id, child_id, device_id, domain, reason, rule, status, token, expires_at, decided_at, created_at"
+
+func scanAccessRequest(row interface{ Scan(dest ...any) error }) (*Model, error) {
+	accessRequest := &Model{}
+
+	var deviceId sql.NullInt64
+	var expiresAt sql.NullTime
+	var decidedAt sql.NullTime
+
+	err := row.Scan(
+		&accessRequest.Id,
+		&accessRequest.ChildId,
+		&deviceId,
+		&accessRequest.Domain,
+		&accessRequest.Reason,
+		&accessRequest.Rule,
+		&accessRequest.Status,
+		&accessRequest.Token,
+		&expiresAt,
+		&decidedAt,
+		&accessRequest.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	accessRequest.DeviceId = int(deviceId.Int64)
+	accessRequest.ExpiresAt = expiresAt.Time
+	accessRequest.DecidedAt = decidedAt.Time
+
+	return accessRequest, nil
+}
+
+func findOne(db *sql.Tx, query string, args ...interface{}) (*Model, error) {
+	accessRequest, err := scanAccessRequest(db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return accessRequest, nil
+}
+
+func FindOneById(db *sql.Tx, id int) (*Model, error) {
+	return findOne(db, "SELECT "+selectColumns+" FROM access_requests WHERE id = $1", id)
+}
+
+func FindOneByToken(db *sql.Tx, token string) (*Model, error) {
+	return findOne(db, "SELECT "+selectColumns+" FROM access_requests WHERE token = $1", token)
+}
+
+// FindPendingByChildIdAndDomain lets the block page reuse a request instead of sending the parent another email.
+// Requests older than PendingTtl at now are not reused.
+func FindPendingByChildIdAndDomain(db *sql.Tx, childId int, domain string, now time.Time) (*Model, error) {
+	return findOne(
+		db,
+		"SELECT "+selectColumns+" FROM access_requests WHERE child_id = $1 AND domain = $2 AND status = $3 AND created_at > $4 ORDER BY id DESC LIMIT 1",
+		childId,
+		domain,
+		StatusPending,
+		now.Add(-PendingTtl).UTC(),
+	)
+}
+
+// FindActiveExceptionDomainsByChildId returns domains of approved requests that have not expired at the given time.
+func FindActiveExceptionDomainsByChildId(db *sql.Tx, childId int, at time.Time) ([]string, error) {
+	rows, err := db.Query(
+		"SELECT DISTINCT domain FROM access_requests WHERE child_id = $1 AND status = $2 AND expires_at > $3 ORDER BY domain",
+		childId,
+		StatusApproved,
+		at.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM access_requests ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	domains := make([]string, 0)
+
+	for rows.Next() {
+		var domain string
+
+		err := rows.Scan(&domain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		domains = append(domains, domain)
+	}
+
+	return domains, nil
+}
+
+func Create(db *sql.Tx, childId int, deviceId int, domain string, reason string, rule string) (int, string, error) {
+	if domain == "" {
+		return 0, "", ErrDomainCannotBeEmpty
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return 0, "", err
+	}
+
+	var nullableDeviceId sql.NullInt64
+	if deviceId != 0 {
+		nullableDeviceId = sql.NullInt64{Int64: int64(deviceId), Valid: true}
+	}
+
+	exec, err := db.Exec(
+		"INSERT INTO access_requests (child_id, device_id, domain, reason, rule, status, token) VALUES (?, ?, ?, ?, ?, ?, ?);",
+		childId,
+		nullableDeviceId,
+		domain,
+		reason,
+		rule,
+		StatusPending,
+		token,
+	)
+	if err != nil {
+		return 0, "", fmt.Errorf("an error occured while trying to execute query 'INSERT INTO access_requests ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, "", fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), token, nil
+}
+
+func decide(db *sql.Tx, id int, status Status, expiresAt sql.NullTime, decidedAt time.Time) error {
+	accessRequest, err := FindOneById(db, id)
+	if err != nil {
+		return err
+	}
+
+	if accessRequest == nil {
+		return ErrAccessRequestWithThisIdDoesNotExist
+	}
+
+	if accessRequest.Status != StatusPending {
+		return ErrAccessRequestIsAlreadyDecided
+	}
+
+	if !decidedAt.Before(accessRequest.CreatedAt.Add(PendingTtl)) {
+		return ErrAccessRequestHasExpired
+	}
+
+	_, err = db.Exec(
+		"UPDATE access_requests SET status = ?, expires_at = ?, decided_at = ? WHERE id = ? AND status = ?",
+		status,
+		expiresAt,
+		decidedAt.UTC(),
+		id,
+		StatusPending,
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE access_requests ...': %w", err)
+	}
+
+	return nil
+}
+
+// Approve allows the domain for the child until expiresAt.
+func Approve(db *sql.Tx, id int, now time.Time, expiresAt time.Time) error {
+	if !expiresAt.After(now) {
+		return ErrExpirationMustBeInTheFuture
+	}
+
+	return decide(db, id, StatusApproved, sql.NullTime{Time: expiresAt.UTC(), Valid: true}, now)
+}
+
+func Deny(db *sql.Tx, id int, now time.Time) error {
+	return decide(db, id, StatusDenied, sql.NullTime{}, now)
+}
@@ -0,0 +1,200 @@
+package accessrequests
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0009_access_requests": MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestAccessRequests(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	now := time.Now()
+
+	t.Run("creates pending request", func(t *testing.T) {
+		id, token, err := Create(tx, 1, 2, "casino.test", "category", "casino.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		accessRequest, err := FindOneByToken(tx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if accessRequest == nil || accessRequest.Id != id {
+			t.Fatalf("Expected access request %d, received %+v", id, accessRequest)
+		}
+
+		if accessRequest.Status != StatusPending || accessRequest.DeviceId != 2 || !accessRequest.ExpiresAt.IsZero() {
+			t.Errorf("Expected pending request of device 2 without expiration, received %+v", accessRequest)
+		}
+
+		pending, err := FindPendingByChildIdAndDomain(tx, 1, "casino.test", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pending == nil || pending.Id != id {
+			t.Errorf("Expected pending access request %d, received %+v", id, pending)
+		}
+	})
+
+	t.Run("returns error when domain is empty", func(t *testing.T) {
+		_, _, err := Create(tx, 1, 2, "", "blocklist", "")
+		if !errors.Is(err, ErrDomainCannotBeEmpty) {
+			t.Errorf("Expected %v, received %v", ErrDomainCannotBeEmpty, err)
+		}
+	})
+
+	t.Run("approved requests are exceptions until they expire", func(t *testing.T) {
+		id, _, err := Create(tx, 1, 0, "games.test", "blocklist", "games.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Approve(tx, id, now, now.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		domains, err := FindActiveExceptionDomainsByChildId(tx, 1, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(domains, []string{"games.test"}) {
+			t.Errorf("Expected [games.test], received %v", domains)
+		}
+
+		domains, err = FindActiveExceptionDomainsByChildId(tx, 1, now.Add(2*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(domains) != 0 {
+			t.Errorf("Expected no exceptions after expiration, received %v", domains)
+		}
+
+		err = Deny(tx, id, now)
+		if !errors.Is(err, ErrAccessRequestIsAlreadyDecided) {
+			t.Errorf("Expected %v, received %v", ErrAccessRequestIsAlreadyDecided, err)
+		}
+	})
+
+	t.Run("denied requests are not exceptions", func(t *testing.T) {
+		id, _, err := Create(tx, 3, 0, "chat.test", "blocklist", "chat.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Deny(tx, id, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		domains, err := FindActiveExceptionDomainsByChildId(tx, 3, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(domains) != 0 {
+			t.Errorf("Expected no exceptions, received %v", domains)
+		}
+
+		pending, err := FindPendingByChildIdAndDomain(tx, 3, "chat.test", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pending != nil {
+			t.Errorf("Expected no pending request, received %+v", pending)
+		}
+	})
+
+	t.Run("returns error when expiration is not in the future", func(t *testing.T) {
+		id, _, err := Create(tx, 4, 0, "video.test", "blocklist", "video.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Approve(tx, id, now, now)
+		if !errors.Is(err, ErrExpirationMustBeInTheFuture) {
+			t.Errorf("Expected %v, received %v", ErrExpirationMustBeInTheFuture, err)
+		}
+	})
+
+	t.Run("pending requests expire after their ttl", func(t *testing.T) {
+		id, _, err := Create(tx, 5, 0, "shop.test", "blocklist", "shop.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = tx.Exec("UPDATE access_requests SET created_at = ? WHERE id = ?", now.Add(-PendingTtl-time.Minute).UTC(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pending, err := FindPendingByChildIdAndDomain(tx, 5, "shop.test", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pending != nil {
+			t.Errorf("Expected no pending request, received %+v", pending)
+		}
+
+		err = Approve(tx, id, now, now.Add(time.Hour))
+		if !errors.Is(err, ErrAccessRequestHasExpired) {
+			t.Errorf("Expected %v, received %v", ErrAccessRequestHasExpired, err)
+		}
+
+		err = Deny(tx, id, now)
+		if !errors.Is(err, ErrAccessRequestHasExpired) {
+			t.Errorf("Expected %v, received %v", ErrAccessRequestHasExpired, err)
+		}
+	})
+
+	t.Run("returns error when request does not exist", func(t *testing.T) {
+		err := Deny(tx, 9999, now)
+		if !errors.Is(err, ErrAccessRequestWithThisIdDoesNotExist) {
+			t.Errorf("Expected %v, received %v", ErrAccessRequestWithThisIdDoesNotExist, err)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package activity
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxBatchSize is the number of events a device may send in a single request.
+const MaxBatchSize = 1000
+
+// MaxClockSkew is how far in the future an event may be dated, device clocks are not always in sync.
+const MaxClockSkew = 5 * time.Minute
+
+// MaxEventAge is the oldest event accepted, a device offline for longer drops what it collected.
+const MaxEventAge = 30 * 24 * time.Hour
+
+// insertChunkSize rows of 6 values per statement stay below the limit of 999 bound variables of older sqlite builds.
+const insertChunkSize = 100
+
+var ErrBatchTooLarge = fmt.Errorf("batch can not contain more than %d events", MaxBatchSize)
+var ErrInvalidSeq = errors.New("sequence number must be positive")
+var ErrOccurredAtOutOfRange = errors.New("occurredAt is missing, in the future or too old")
+var ErrInvalidPayload = errors.New("invalid activity event payload")
+
+// AppPayload is sent by devices for app_start and app_stop events.
+type AppPayload struct {
+	App   string `json:"app"`
+	Title string `json:"title,omitempty"`
+}
+
+// ScreenPayload is sent for screen_on and screen_off events, it carries no data.
+type ScreenPayload struct{}
+
+// BlockPayload is sent when the device itself blocked a domain or an app.
+type BlockPayload struct {
+	Domain string `json:"domain,omitempty"`
+	App    string `json:"app,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// IncomingEvent is an event reported by a device. Seq is assigned by the device and increases
+// with every event, a batch sent again after a failed request is stored only once.
+type IncomingEvent struct {
+	Seq        int64           `json:"seq"`
+	Type       Type            `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+func decodePayloadStrictly(payload json.RawMessage, target any) error {
+	if len(payload) == 0 || string(payload) == "null" {
+		payload = json.RawMessage("{}")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(target)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	return nil
+}
+
+func normalizePayload(eventType Type, payload json.RawMessage) (json.RawMessage, error) {
+	var normalized any
+
+	switch eventType {
+	case TypeDnsQuery:
+		decoded := DnsQueryPayload{}
+		if err := decodePayloadStrictly(payload, &decoded); err != nil {
+			return nil, err
+		}
+
+		if strings.TrimSpace(decoded.Domain) == "" {
+			return nil, fmt.Errorf("%w: domain is required", ErrInvalidPayload)
+		}
+
+		normalized = decoded
+	case TypeAppStart, TypeAppStop:
+		decoded := AppPayload{}
+		if err := decodePayloadStrictly(payload, &decoded); err != nil {
+			return nil, err
+		}
+
+		if strings.TrimSpace(decoded.App) == "" {
+			return nil, fmt.Errorf("%w: app is required", ErrInvalidPayload)
+		}
+
+		normalized = decoded
+	case TypeScreenOn, TypeScreenOff:
+		decoded := ScreenPayload{}
+		if err := decodePayloadStrictly(payload, &decoded); err != nil {
+			return nil, err
+		}
+
+		normalized = decoded
+	case TypeBlock:
+		decoded := BlockPayload{}
+		if err := decodePayloadStrictly(payload, &decoded); err != nil {
+			return nil, err
+		}
+
+		if strings.TrimSpace(decoded.Domain) == "" && strings.TrimSpace(decoded.App) == "" {
+			return nil, fmt.Errorf("%w: domain or app is required", ErrInvalidPayload)
+		}
+
+		normalized = decoded
+	default:
+		return nil, ErrInvalidType
+	}
+
+	encoded, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode activity event payload: %w", err)
+	}
+
+	return encoded, nil
+}
+
+// NormalizeEvent checks the event against the schema of its type and returns it with the payload re-encoded,
+// so unknown fields and formatting of the device never reach the database.
+func NormalizeEvent(event IncomingEvent, now time.Time) (IncomingEvent, error) {
+	if event.Seq <= 0 {
+		return IncomingEvent{}, ErrInvalidSeq
+	}
+
+	if !event.Type.IsValid() {
+		return IncomingEvent{}, ErrInvalidType
+	}
+
+	if event.OccurredAt.IsZero() || event.OccurredAt.After(now.Add(MaxClockSkew)) || event.OccurredAt.Before(now.Add(-MaxEventAge)) {
+		return IncomingEvent{}, ErrOccurredAtOutOfRange
+	}
+
+	payload, err := normalizePayload(event.Type, event.Payload)
+	if err != nil {
+		return IncomingEvent{}, err
+	}
+
+	event.Payload = payload
+
+	return event, nil
+}
+
+// CreateBatch stores normalized events of the device with multi-row inserts and returns the number of stored events.
+// Events with a sequence number the device already sent are skipped.
+func CreateBatch(db *sql.Tx, childId int, deviceId int, events []IncomingEvent) (int, error) {
+	if len(events) > MaxBatchSize {
+		return 0, ErrBatchTooLarge
+	}
+
+	inserted := 0
+
+	for start := 0; start < len(events); start += insertChunkSize {
+		chunk := events[start:min(start+insertChunkSize, len(events))]
+
+		query := strings.Builder{}
+		query.WriteString("INSERT OR IGNORE INTO activity_events (child_id, device_id, device_seq, type, payload, occurred_at) VALUES ")
+
+		args := make([]any, 0, len(chunk)*6)
+
+		for i, event := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+
+			query.WriteString("(?, ?, ?, ?, ?, ?)")
+			args = append(args, childId, deviceId, event.Seq, event.Type, string(event.Payload), event.OccurredAt.UTC())
+		}
+
+		exec, err := db.Exec(query.String(), args...)
+		if err != nil {
+			return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT OR IGNORE INTO activity_events ...': %w", err)
+		}
+
+		affected, err := exec.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("an error occured while trying to get number of inserted rows: %w", err)
+		}
+
+		inserted += int(affected)
+	}
+
+	if len(events) > 0 {
+		oldest := events[0].OccurredAt
+		for _, event := range events[1:] {
+			if event.OccurredAt.Before(oldest) {
+				oldest = event.OccurredAt
+			}
+		}
+
+		err := markRolledUpHoursDirty(db, childId, oldest)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return inserted, nil
+}
@@ -0,0 +1,201 @@
+package activity
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t testing.TB) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0006_activity":           MigrationFile,
+		"0012_activity_ingestion": IngestionMigrationFile,
+		"0015_activity_rollups":   RollupsMigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestNormalizeEvent(t *testing.T) {
+	now := time.Now()
+
+	t.Run("re-encodes valid payloads", func(t *testing.T) {
+		event, err := NormalizeEvent(IncomingEvent{
+			Seq:        1,
+			Type:       TypeAppStart,
+			OccurredAt: now,
+			Payload:    json.RawMessage(`{ "app" : "firefox" }`),
+		}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(event.Payload) != `{"app":"firefox"}` {
+			t.Errorf("Got %s, want %s", event.Payload, `{"app":"firefox"}`)
+		}
+
+		event, err = NormalizeEvent(IncomingEvent{Seq: 2, Type: TypeScreenOff, OccurredAt: now}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(event.Payload) != `{}` {
+			t.Errorf("Got %s, want %s", event.Payload, `{}`)
+		}
+	})
+
+	testCases := []struct {
+		name     string
+		event    IncomingEvent
+		expected error
+	}{
+		{"missing seq", IncomingEvent{Type: TypeScreenOn, OccurredAt: now}, ErrInvalidSeq},
+		{"unknown type", IncomingEvent{Seq: 1, Type: "keylogger", OccurredAt: now}, ErrInvalidType},
+		{"missing occurredAt", IncomingEvent{Seq: 1, Type: TypeScreenOn}, ErrOccurredAtOutOfRange},
+		{"future occurredAt", IncomingEvent{Seq: 1, Type: TypeScreenOn, OccurredAt: now.Add(time.Hour)}, ErrOccurredAtOutOfRange},
+		{"too old occurredAt", IncomingEvent{Seq: 1, Type: TypeScreenOn, OccurredAt: now.Add(-MaxEventAge - time.Hour)}, ErrOccurredAtOutOfRange},
+		{"unknown payload field", IncomingEvent{Seq: 1, Type: TypeScreenOn, OccurredAt: now, Payload: json.RawMessage(`{"brightness": 5}`)}, ErrInvalidPayload},
+		{"app without name", IncomingEvent{Seq: 1, Type: TypeAppStop, OccurredAt: now, Payload: json.RawMessage(`{"app": " "}`)}, ErrInvalidPayload},
+		{"dns query without domain", IncomingEvent{Seq: 1, Type: TypeDnsQuery, OccurredAt: now, Payload: json.RawMessage(`{"queryType": "A"}`)}, ErrInvalidPayload},
+		{"block without target", IncomingEvent{Seq: 1, Type: TypeBlock, OccurredAt: now, Payload: json.RawMessage(`{"reason": "schedule"}`)}, ErrInvalidPayload},
+		{"payload of wrong type", IncomingEvent{Seq: 1, Type: TypeAppStart, OccurredAt: now, Payload: json.RawMessage(`["firefox"]`)}, ErrInvalidPayload},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := NormalizeEvent(testCase.event, now)
+			if !errors.Is(err, testCase.expected) {
+				t.Errorf("Expected %v, received %v", testCase.expected, err)
+			}
+		})
+	}
+}
+
+func createTestEvents(count int, firstSeq int64, occurredAt time.Time) []IncomingEvent {
+	events := make([]IncomingEvent, 0, count)
+
+	for i := range count {
+		events = append(events, IncomingEvent{
+			Seq:        firstSeq + int64(i),
+			Type:       TypeAppStart,
+			OccurredAt: occurredAt,
+			Payload:    json.RawMessage(`{"app":"firefox"}`),
+		})
+	}
+
+	return events
+}
+
+func TestCreateBatch(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	now := time.Now()
+
+	t.Run("inserts events spanning several chunks", func(t *testing.T) {
+		inserted, err := CreateBatch(tx, 1, 10, createTestEvents(insertChunkSize*2+5, 1, now))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if inserted != insertChunkSize*2+5 {
+			t.Errorf("Got %d, want %d", inserted, insertChunkSize*2+5)
+		}
+	})
+
+	t.Run("skips events sent again", func(t *testing.T) {
+		inserted, err := CreateBatch(tx, 1, 10, createTestEvents(10, insertChunkSize*2, now))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if inserted != 4 {
+			t.Errorf("Got %d, want %d", inserted, 4)
+		}
+
+		events, err := FindAllByChildIdBetween(tx, 1, now.Add(-time.Minute), now.Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events) != insertChunkSize*2+9 {
+			t.Errorf("Got %d, want %d", len(events), insertChunkSize*2+9)
+		}
+	})
+
+	t.Run("sequence numbers are separate per device", func(t *testing.T) {
+		inserted, err := CreateBatch(tx, 1, 11, createTestEvents(3, 1, now))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if inserted != 3 {
+			t.Errorf("Got %d, want %d", inserted, 3)
+		}
+	})
+
+	t.Run("returns error for too large batch", func(t *testing.T) {
+		_, err := CreateBatch(tx, 1, 12, createTestEvents(MaxBatchSize+1, 1, now))
+		if !errors.Is(err, ErrBatchTooLarge) {
+			t.Errorf("Expected %v, received %v", ErrBatchTooLarge, err)
+		}
+	})
+}
+
+func BenchmarkCreateBatch(b *testing.B) {
+	db := openDatabase(b)
+	defer db.Close()
+
+	now := time.Now()
+	seq := int64(1)
+
+	b.ResetTimer()
+
+	for range b.N {
+		tx, err := db.Begin()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		_, err = CreateBatch(tx, 1, 10, createTestEvents(MaxBatchSize, seq, now))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		seq += MaxBatchSize
+	}
+
+	b.ReportMetric(float64(b.N*MaxBatchSize)/b.Elapsed().Seconds(), "events/s")
+}
@@ -0,0 +1,11 @@
+CREATE TABLE activity_events (
+    id INTEGER PRIMARY KEY,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    device_id INTEGER REFERENCES devices (id) ON DELETE SET NULL,
+    type VARCHAR NOT NULL,
+    payload TEXT NOT NULL DEFAULT '{}',
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX activity_events_child_id_occurred_at ON activity_events (child_id, occurred_at);
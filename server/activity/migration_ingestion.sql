@@ -0,0 +1,3 @@
+ALTER TABLE activity_events ADD COLUMN device_seq INTEGER;
+
+CREATE UNIQUE INDEX activity_events_device_id_device_seq ON activity_events (device_id, device_seq) WHERE device_seq IS NOT NULL;
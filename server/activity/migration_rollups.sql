@@ -0,0 +1,16 @@
+CREATE TABLE activity_rollups (
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    period VARCHAR NOT NULL,
+    bucket_start TIMESTAMP NOT NULL,
+    metric VARCHAR NOT NULL,
+    name VARCHAR NOT NULL DEFAULT '',
+    value INTEGER NOT NULL,
+    PRIMARY KEY (child_id, period, bucket_start, metric, name)
+);
+
+CREATE TABLE activity_compaction (
+    child_id INTEGER PRIMARY KEY REFERENCES children (id) ON DELETE CASCADE,
+    compacted_until TIMESTAMP,
+    dirty_from TIMESTAMP,
+    finalized_before TIMESTAMP
+);
@@ -0,0 +1,144 @@
+package activity
+
+import (
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrInvalidType = errors.New("invalid activity event type")
+
+type Type string
+
+const (
+	TypeDnsQuery  Type = "dns_query"
+	TypeAppStart  Type = "app_start"
+	TypeAppStop   Type = "app_stop"
+	TypeScreenOn  Type = "screen_on"
+	TypeScreenOff Type = "screen_off"
+	TypeBlock     Type = "block"
+)
+
+func (eventType Type) IsValid() bool {
+	switch eventType {
+	case TypeDnsQuery, TypeAppStart, TypeAppStop, TypeScreenOn, TypeScreenOff, TypeBlock:
+		return true
+	default:
+		return false
+	}
+}
+
+type Model struct {
+	Id      int
+	ChildId int
+	// DeviceId is 0 when the event is not tied to any device.
+	DeviceId   int
+	Type       Type
+	Payload    json.RawMessage
+	OccurredAt time.Time
+	CreatedAt  time.Time
+}
+
+// DnsQueryPayload is stored for every query of a managed device, no matter if it was answered over plain dns or DoH.
+// The forward proxy logs its requests the same way, without a query type and with the path when it saw one.
+type DnsQueryPayload struct {
+	Domain    string `json:"domain"`
+	QueryType string `json:"queryType,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Transport string `json:"transport"`
+	Blocked   bool   `json:"blocked"`
+	Reason    string `json:"reason,omitempty"`
+	Rule      string `json:"rule,omitempty"`
+	Category  string `json:"category,omitempty"`
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+//go:embed migration_ingestion.sql
+var IngestionMigrationFile string
+
+func Create(db *sql.Tx, childId int, deviceId int, eventType Type, payload any, occurredAt time.Time) (int, error) {
+	if !eventType.IsValid() {
+		return 0, ErrInvalidType
+	}
+
+	encodedPayload, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode activity event payload: %w", err)
+	}
+
+	var nullableDeviceId sql.NullInt64
+	if deviceId != 0 {
+		nullableDeviceId = sql.NullInt64{Int64: int64(deviceId), Valid: true}
+	}
+
+	exec, err := db.Exec(
+		"INSERT INTO activity_events (child_id, device_id, type, payload, occurred_at) VALUES (?, ?, ?, ?, ?);",
+		childId,
+		nullableDeviceId,
+		eventType,
+		string(encodedPayload),
+		occurredAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO activity_events ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	err = markRolledUpHoursDirty(db, childId, occurredAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// FindAllByChildIdBetween returns events of the child that occurred in [from, to), oldest first.
+func FindAllByChildIdBetween(db *sql.Tx, childId int, from time.Time, to time.Time) ([]Model, error) {
+	rows, err := db.Query(
+		"SELECT id, child_id, device_id, type, payload, occurred_at, created_at FROM activity_events WHERE child_id = $1 AND occurred_at >= $2 AND occurred_at < $3 ORDER BY occurred_at, id",
+		childId,
+		from.UTC(),
+		to.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM activity_events ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	events := make([]Model, 0)
+
+	for rows.Next() {
+		event := Model{}
+
+		var deviceId sql.NullInt64
+		var payload string
+
+		err := rows.Scan(&event.Id, &event.ChildId, &deviceId, &event.Type, &payload, &event.OccurredAt, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		event.DeviceId = int(deviceId.Int64)
+		event.Payload = json.RawMessage(payload)
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
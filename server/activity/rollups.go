@@ -0,0 +1,301 @@
+package activity
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+type Period string
+
+const (
+	PeriodHour Period = "hour"
+	PeriodDay  Period = "day"
+)
+
+type Metric string
+
+const (
+	// MetricScreenTime and MetricAppTime hold a time.Duration, the app is stored as the name.
+	MetricScreenTime Metric = "screen_time"
+	MetricAppTime    Metric = "app_time"
+	// MetricDomainQueries and MetricBlockedAttempts hold a count, the domain is stored as the name.
+	MetricDomainQueries   Metric = "domain_queries"
+	MetricBlockedAttempts Metric = "blocked_attempts"
+)
+
+// Rollup is a value aggregated from raw events of a child within an hour or a day.
+type Rollup struct {
+	ChildId     int
+	Period      Period
+	BucketStart time.Time
+	Metric      Metric
+	Name        string
+	Value       int64
+}
+
+// CompactionState tracks how far activity of a child is rolled up. Zero times mean the value is not set yet.
+type CompactionState struct {
+	ChildId int
+	// RetentionDays is the activity retention of the household of the child.
+	RetentionDays int
+	// CompactedUntil is the end of the last hour rolled up.
+	CompactedUntil time.Time
+	// DirtyFrom is the time of the oldest event stored after its hour was already rolled up.
+	DirtyFrom time.Time
+	// FinalizedBefore is the start of the oldest day that still has its raw events,
+	// rollups before it can not be computed again.
+	FinalizedBefore time.Time
+}
+
+// RolledUpUntil returns the time rollups of the child are up to date until.
+func (state *CompactionState) RolledUpUntil() time.Time {
+	until := state.CompactedUntil
+	if !state.DirtyFrom.IsZero() && state.DirtyFrom.Before(until) {
+		until = state.DirtyFrom
+	}
+
+	if until.Before(state.FinalizedBefore) {
+		until = state.FinalizedBefore
+	}
+
+	return until
+}
+
+//go:embed migration_rollups.sql
+var RollupsMigrationFile string
+
+// insertRollupsChunkSize rows of 6 values per statement stay below the limit of bound variables, like insertChunkSize.
+const insertRollupsChunkSize = 100
+
+const compactionStateQuery = `SELECT children.id, households.activity_retention_days, activity_compaction.compacted_until, activity_compaction.dirty_from, activity_compaction.finalized_before
+FROM children
+INNER JOIN households ON households.id = children.household_id
+LEFT JOIN activity_compaction ON activity_compaction.child_id = children.id`
+
+func scanCompactionState(row interface{ Scan(dest ...any) error }) (*CompactionState, error) {
+	state := &CompactionState{}
+
+	var compactedUntil sql.NullTime
+	var dirtyFrom sql.NullTime
+	var finalizedBefore sql.NullTime
+
+	err := row.Scan(&state.ChildId, &state.RetentionDays, &compactedUntil, &dirtyFrom, &finalizedBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	state.CompactedUntil = compactedUntil.Time
+	state.DirtyFrom = dirtyFrom.Time
+	state.FinalizedBefore = finalizedBefore.Time
+
+	return state, nil
+}
+
+func nullableTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// FindOneCompactionStateByChildId returns the state of the child, nil if the child does not exist.
+func FindOneCompactionStateByChildId(db *sql.Tx, childId int) (*CompactionState, error) {
+	state, err := scanCompactionState(db.QueryRow(compactionStateQuery+" WHERE children.id = $1", childId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return state, nil
+}
+
+// FindAllCompactionStates returns the state of every child, including children that were never compacted.
+func FindAllCompactionStates(db *sql.Tx) ([]CompactionState, error) {
+	rows, err := db.Query(compactionStateQuery + " ORDER BY children.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM children ... activity_compaction ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	states := make([]CompactionState, 0)
+
+	for rows.Next() {
+		state, err := scanCompactionState(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		states = append(states, *state)
+	}
+
+	return states, nil
+}
+
+func SaveCompactionState(db *sql.Tx, state *CompactionState) error {
+	_, err := db.Exec(
+		`INSERT INTO activity_compaction (child_id, compacted_until, dirty_from, finalized_before) VALUES ($1, $2, $3, $4)
+ON CONFLICT (child_id) DO UPDATE SET compacted_until = excluded.compacted_until, dirty_from = excluded.dirty_from, finalized_before = excluded.finalized_before`,
+		state.ChildId,
+		nullableTime(state.CompactedUntil),
+		nullableTime(state.DirtyFrom),
+		nullableTime(state.FinalizedBefore),
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'INSERT INTO activity_compaction ...': %w", err)
+	}
+
+	return nil
+}
+
+// markRolledUpHoursDirty makes the compaction roll up hours of the child again when an event arrives
+// for an hour that is already rolled up, e.g. from a device that was offline for a while.
+func markRolledUpHoursDirty(db *sql.Tx, childId int, occurredAt time.Time) error {
+	_, err := db.Exec(
+		"UPDATE activity_compaction SET dirty_from = $1 WHERE child_id = $2 AND compacted_until > $1 AND (dirty_from IS NULL OR dirty_from > $1)",
+		occurredAt.UTC(),
+		childId,
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE activity_compaction ...': %w", err)
+	}
+
+	return nil
+}
+
+// FindFirstOccurredAtByChildId returns the time of the oldest stored event of the child, zero time if there is none.
+func FindFirstOccurredAtByChildId(db *sql.Tx, childId int) (time.Time, error) {
+	var occurredAt time.Time
+
+	err := db.QueryRow("SELECT occurred_at FROM activity_events WHERE child_id = $1 ORDER BY occurred_at LIMIT 1", childId).Scan(&occurredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return occurredAt, nil
+}
+
+// DeleteAllByChildIdOccurredBefore deletes raw events of the child and returns the number of deleted events.
+func DeleteAllByChildIdOccurredBefore(db *sql.Tx, childId int, before time.Time) (int, error) {
+	exec, err := db.Exec("DELETE FROM activity_events WHERE child_id = $1 AND occurred_at < $2", childId, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'DELETE FROM activity_events ...': %w", err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get number of deleted rows: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// ReplaceRollups deletes rollups of the child with buckets starting in [from, to) and stores the given ones instead.
+func ReplaceRollups(db *sql.Tx, childId int, period Period, from time.Time, to time.Time, rollups []Rollup) error {
+	_, err := db.Exec(
+		"DELETE FROM activity_rollups WHERE child_id = $1 AND period = $2 AND bucket_start >= $3 AND bucket_start < $4",
+		childId,
+		period,
+		from.UTC(),
+		to.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'DELETE FROM activity_rollups ...': %w", err)
+	}
+
+	for start := 0; start < len(rollups); start += insertRollupsChunkSize {
+		chunk := rollups[start:min(start+insertRollupsChunkSize, len(rollups))]
+
+		query := strings.Builder{}
+		query.WriteString("INSERT INTO activity_rollups (child_id, period, bucket_start, metric, name, value) VALUES ")
+
+		args := make([]any, 0, len(chunk)*6)
+
+		for i, rollup := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+
+			query.WriteString("(?, ?, ?, ?, ?, ?)")
+			args = append(args, childId, period, rollup.BucketStart.UTC(), rollup.Metric, rollup.Name, rollup.Value)
+		}
+
+		_, err := db.Exec(query.String(), args...)
+		if err != nil {
+			return fmt.Errorf("an error occured while trying to execute query 'INSERT INTO activity_rollups ...': %w", err)
+		}
+	}
+
+	return nil
+}
+
+// FindAllRollupsByChildIdBetween returns rollups of the child with buckets starting in [from, to), oldest first.
+func FindAllRollupsByChildIdBetween(db *sql.Tx, childId int, period Period, from time.Time, to time.Time) ([]Rollup, error) {
+	rows, err := db.Query(
+		"SELECT child_id, period, bucket_start, metric, name, value FROM activity_rollups WHERE child_id = $1 AND period = $2 AND bucket_start >= $3 AND bucket_start < $4 ORDER BY bucket_start, metric, name",
+		childId,
+		period,
+		from.UTC(),
+		to.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM activity_rollups ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	rollups := make([]Rollup, 0)
+
+	for rows.Next() {
+		rollup := Rollup{}
+
+		err := rows.Scan(&rollup.ChildId, &rollup.Period, &rollup.BucketStart, &rollup.Metric, &rollup.Name, &rollup.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		rollups = append(rollups, rollup)
+	}
+
+	return rollups, nil
+}
+
+// DeleteRollupsOfMetricBefore deletes rollups of the metric of the child of every period with buckets starting before
+// the given time.
+func DeleteRollupsOfMetricBefore(db *sql.Tx, childId int, metric Metric, before time.Time) error {
+	_, err := db.Exec("DELETE FROM activity_rollups WHERE child_id = $1 AND metric = $2 AND bucket_start < $3", childId, metric, before.UTC())
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'DELETE FROM activity_rollups ...': %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRollupsBefore deletes rollups of the child with buckets starting before the given time.
+func DeleteRollupsBefore(db *sql.Tx, childId int, period Period, before time.Time) error {
+	_, err := db.Exec("DELETE FROM activity_rollups WHERE child_id = $1 AND period = $2 AND bucket_start < $3", childId, period, before.UTC())
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'DELETE FROM activity_rollups ...': %w", err)
+	}
+
+	return nil
+}
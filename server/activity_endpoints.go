@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/ratelimit"
+	"github.com/go-chi/chi"
+)
+
+// maxIngestBodyBytes limits the request body as sent, maxIngestDecodedBytes limits it after decompression.
+const maxIngestBodyBytes = 1 << 20
+const maxIngestDecodedBytes = 8 << 20
+
+// ingestEventsPerSecond and ingestEventsBurst let a device upload what it collected while offline,
+// but not flood the database with a steady stream of events.
+const ingestEventsPerSecond = 50
+const ingestEventsBurst = 5000
+
+var ErrInvalidDeviceId = errors.New("invalid device id")
+var ErrUnsupportedContentEncoding = errors.New("content encoding must be gzip or none")
+var ErrEventsBodyTooLarge = errors.New("events body is too large")
+var ErrEmptyEventsBatch = errors.New("events batch is empty")
+var ErrTooManyEvents = errors.New("too many events, retry later")
+
+type IngestEventsResponse struct {
+	Accepted   int `json:"accepted"`
+	Duplicates int `json:"duplicates"`
+	// LastSeq is the highest sequence number of the batch, the device may drop everything up to it.
+	LastSeq int64 `json:"lastSeq"`
+}
+
+func newIngestLimiter() *ratelimit.Limiter {
+	return ratelimit.NewLimiter(ingestEventsPerSecond, ingestEventsBurst)
+}
+
+// decodeEventsRequestBody reads the optionally gzip compressed body without letting a small compressed body expand without limit.
+func decodeEventsRequestBody(w http.ResponseWriter, r *http.Request) (IngestEventsRequestBody, error) {
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxIngestBodyBytes)
+
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			respondWith400(w, r, ErrInvalidJsonPayload)
+			return IngestEventsRequestBody{}, err
+		}
+
+		defer gzipReader.Close()
+
+		body = gzipReader
+	default:
+		respondWithError(w, r, http.StatusUnsupportedMediaType, ErrUnsupportedContentEncoding)
+		return IngestEventsRequestBody{}, ErrUnsupportedContentEncoding
+	}
+
+	decoded, err := io.ReadAll(io.LimitReader(body, maxIngestDecodedBytes+1))
+
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) || len(decoded) > maxIngestDecodedBytes {
+		respondWithError(w, r, http.StatusRequestEntityTooLarge, ErrEventsBodyTooLarge)
+		return IngestEventsRequestBody{}, ErrEventsBodyTooLarge
+	} else if err != nil {
+		respondWith400(w, r, ErrInvalidJsonPayload)
+		return IngestEventsRequestBody{}, err
+	}
+
+	return decodeJson[IngestEventsRequestBody](w, r, bytes.NewReader(decoded))
+}
+
+// containsScreenEvents tells if the events can change the screen time used by the child.
+func containsScreenEvents(events []activity.IncomingEvent) bool {
+	for _, event := range events {
+		if event.Type == activity.TypeScreenOn || event.Type == activity.TypeScreenOff {
+			return true
+		}
+	}
+
+	return false
+}
+
+type IngestEventsRequestBody struct {
+	Events []activity.IncomingEvent `json:"events"`
+}
+
+// HttpDevicesEventsIngest stores a batch of activity events of the device. Events carry sequence numbers
+// assigned by the device, so a batch sent again after a timeout is not stored twice.
+func HttpDevicesEventsIngest(cfg *ServerConfig, ingestLimiter *ratelimit.Limiter, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deviceId, err := strconv.Atoi(chi.URLParam(r, "deviceId"))
+		if err != nil || deviceId <= 0 {
+			respondWith400(w, r, ErrInvalidDeviceId)
+			return
+		}
+
+		device := authenticatedDevice(r)
+		if device.Id != deviceId {
+			respondWith404(w, r, ErrDeviceNotFound)
+			return
+		}
+
+		requestBody, err := decodeEventsRequestBody(w, r)
+		if err != nil {
+			return
+		}
+
+		if len(requestBody.Events) == 0 {
+			respondWith400(w, r, ErrEmptyEventsBatch)
+			return
+		}
+
+		if len(requestBody.Events) > activity.MaxBatchSize {
+			respondWith400(w, r, activity.ErrBatchTooLarge)
+			return
+		}
+
+		now := time.Now()
+
+		allowed, retryAfter := ingestLimiter.Allow(device.Id, len(requestBody.Events), now)
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			respondWith429(w, r, ErrTooManyEvents)
+			return
+		}
+
+		events := make([]activity.IncomingEvent, 0, len(requestBody.Events))
+		var lastSeq int64
+
+		for i, event := range requestBody.Events {
+			normalized, err := activity.NormalizeEvent(event, now)
+			if err != nil {
+				respondWithError(w, r, http.StatusBadRequest, fmt.Errorf("event %d: %v", i, err), newFieldError(fmt.Sprintf("events[%d]", i), err))
+				return
+			}
+
+			events = append(events, normalized)
+			lastSeq = max(lastSeq, normalized.Seq)
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		inserted, err := activity.CreateBatch(tx, device.ChildId, device.Id, events)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to store activity events: %v", err)
+			return
+		}
+
+		if inserted > 0 && containsScreenEvents(events) {
+			child, err := children.FindOneById(tx, device.ChildId)
+			if err != nil || child == nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to find child of the device: %v", err)
+				return
+			}
+
+			err = enqueueScreenTimeLimitReached(cfg, tx, child, now)
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to enqueue webhook event: %v", err)
+				return
+			}
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, IngestEventsResponse{
+			Accepted:   inserted,
+			Duplicates: len(events) - inserted,
+			LastSeq:    lastSeq,
+		})
+	}
+}
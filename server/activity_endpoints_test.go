@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/ratelimit"
+	"github.com/go-chi/chi"
+)
+
+func encodeTestEventsBatch(t testing.TB, count int, firstSeq int64, compress bool) []byte {
+	events := make([]activity.IncomingEvent, 0, count)
+
+	for i := range count {
+		events = append(events, activity.IncomingEvent{
+			Seq:        firstSeq + int64(i),
+			Type:       activity.TypeAppStart,
+			OccurredAt: time.Now(),
+			Payload:    json.RawMessage(`{"app":"firefox"}`),
+		})
+	}
+
+	encoded, err := json.Marshal(map[string]any{"events": events})
+	doTFatalIfErr(t, err)
+
+	if !compress {
+		return encoded
+	}
+
+	compressed := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&compressed)
+
+	_, err = gzipWriter.Write(encoded)
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, gzipWriter.Close())
+
+	return compressed.Bytes()
+}
+
+func newTestIngestRouter(db *sql.DB, ingestLimiter *ratelimit.Limiter) *chi.Mux {
+	router := chi.NewRouter()
+	router.With(AuthenticateDeviceToken(db)).Post("/devices/{deviceId}/events", HttpDevicesEventsIngest(testingCfg, ingestLimiter, db))
+
+	return router
+}
+
+func sendTestEventsBatch(router http.Handler, family testFamily, deviceId int, body []byte, contentEncoding string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:8080/devices/%d/events", deviceId), bytes.NewReader(body))
+	request.Header.Set("Authorization", "Device "+family.deviceToken)
+	request.Header.Set("Content-Type", "application/json")
+	if contentEncoding != "" {
+		request.Header.Set("Content-Encoding", contentEncoding)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestHttpDevicesEventsIngest(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	router := newTestIngestRouter(db, newIngestLimiter())
+
+	t.Run("stores compressed batch and skips it when sent again", func(t *testing.T) {
+		body := encodeTestEventsBatch(t, 250, 1, true)
+
+		for _, expected := range []IngestEventsResponse{{Accepted: 250, LastSeq: 250}, {Duplicates: 250, LastSeq: 250}} {
+			recorder := sendTestEventsBatch(router, family, family.deviceId, body, "gzip")
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+			}
+
+			var response IngestEventsResponse
+			doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+			if response != expected {
+				t.Errorf("Expected %+v, received %+v", expected, response)
+			}
+		}
+
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		events, err := activity.FindAllByChildIdBetween(tx, family.childId, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		if len(events) != 250 {
+			t.Errorf("Got %d, want %d", len(events), 250)
+		}
+	})
+
+	t.Run("accepts uncompressed batch", func(t *testing.T) {
+		recorder := sendTestEventsBatch(router, family, family.deviceId, encodeTestEventsBatch(t, 5, 1000, false), "")
+		if recorder.Code != http.StatusOK {
+			t.Errorf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+	})
+
+	t.Run("rejects whole batch with invalid event", func(t *testing.T) {
+		body := `{"events": [
+			{"seq": 2000, "type": "screen_on", "occurredAt": "` + time.Now().Format(time.RFC3339) + `"},
+			{"seq": 2001, "type": "app_start", "occurredAt": "` + time.Now().Format(time.RFC3339) + `", "payload": {"title": "no app"}}
+		]}`
+
+		recorder := sendTestEventsBatch(router, family, family.deviceId, []byte(body), "")
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		if !strings.HasPrefix(recorder.Body.String(), "event 1:") {
+			t.Errorf("Expected error of event 1, received %s", recorder.Body.String())
+		}
+	})
+
+	t.Run("returns 400 for empty or invalid body", func(t *testing.T) {
+		for _, body := range []string{`{"events": []}`, `{"events": `, `not json`, `{"events": [], "device": 1}`, `{"events": []} {}`} {
+			recorder := sendTestEventsBatch(router, family, family.deviceId, []byte(body), "")
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("returns 404 for events of another device", func(t *testing.T) {
+		recorder := sendTestEventsBatch(router, family, stranger.deviceId, encodeTestEventsBatch(t, 1, 1, false), "")
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("returns 415 for unknown content encoding", func(t *testing.T) {
+		recorder := sendTestEventsBatch(router, family, family.deviceId, encodeTestEventsBatch(t, 1, 1, false), "br")
+		if recorder.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusUnsupportedMediaType)
+		}
+
+		if recorder.Body.String() != ErrUnsupportedContentEncoding.Error() {
+			t.Errorf("Expected %q, received %q", ErrUnsupportedContentEncoding.Error(), recorder.Body.String())
+		}
+	})
+
+	t.Run("returns 413 when compressed body expands too much", func(t *testing.T) {
+		compressed := bytes.Buffer{}
+		gzipWriter := gzip.NewWriter(&compressed)
+		_, err := gzipWriter.Write(bytes.Repeat([]byte(" "), maxIngestDecodedBytes+1))
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, gzipWriter.Close())
+
+		recorder := sendTestEventsBatch(router, family, family.deviceId, compressed.Bytes(), "gzip")
+		if recorder.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusRequestEntityTooLarge)
+		}
+
+		if recorder.Body.String() != ErrEventsBodyTooLarge.Error() {
+			t.Errorf("Expected %q, received %q", ErrEventsBodyTooLarge.Error(), recorder.Body.String())
+		}
+	})
+
+	t.Run("returns 429 with retry after when device floods the server", func(t *testing.T) {
+		limitedRouter := newTestIngestRouter(db, ratelimit.NewLimiter(1, 10))
+
+		recorder := sendTestEventsBatch(limitedRouter, family, family.deviceId, encodeTestEventsBatch(t, 10, 3000, false), "")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		recorder = sendTestEventsBatch(limitedRouter, family, family.deviceId, encodeTestEventsBatch(t, 5, 3010, false), "")
+		if recorder.Code != http.StatusTooManyRequests {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusTooManyRequests)
+		}
+
+		if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "5" {
+			t.Errorf("Got %s, want %s", retryAfter, "5")
+		}
+	})
+}
+
+func BenchmarkHttpDevicesEventsIngest(b *testing.B) {
+	db := openDatabase(b)
+	defer db.Close()
+
+	family := createTestFamily(b, db, "parent@localhost.local")
+	router := newTestIngestRouter(db, ratelimit.NewLimiter(1e9, 1e9))
+
+	bodies := make([][]byte, 0, b.N)
+	for i := range b.N {
+		bodies = append(bodies, encodeTestEventsBatch(b, activity.MaxBatchSize, int64(i*activity.MaxBatchSize+1), true))
+	}
+
+	b.ResetTimer()
+
+	for i := range b.N {
+		recorder := sendTestEventsBatch(router, family, family.deviceId, bodies[i], "gzip")
+		if recorder.Code != http.StatusOK {
+			b.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+	}
+
+	b.ReportMetric(float64(b.N*activity.MaxBatchSize)/b.Elapsed().Seconds(), "events/s")
+}
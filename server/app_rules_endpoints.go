@@ -0,0 +1,263 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/apprules"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/push"
+	"github.com/go-chi/chi"
+)
+
+var ErrInvalidAppRuleId = errors.New("invalid app rule id")
+var ErrAppRuleNotFound = errors.New("app rule not found")
+
+type AppRuleResponse struct {
+	Id        int    `json:"id"`
+	ChildId   int    `json:"childId"`
+	MatchType string `json:"matchType"`
+	Pattern   string `json:"pattern"`
+	Action    string `json:"action"`
+	// DailyLimitMinutes is 0 for allow and block rules.
+	DailyLimitMinutes int       `json:"dailyLimitMinutes"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+func newAppRuleResponses(rules []apprules.Model) []AppRuleResponse {
+	response := make([]AppRuleResponse, 0, len(rules))
+
+	for _, rule := range rules {
+		response = append(response, AppRuleResponse{
+			Id:                rule.Id,
+			ChildId:           rule.ChildId,
+			MatchType:         string(rule.MatchType),
+			Pattern:           rule.Pattern,
+			Action:            string(rule.Action),
+			DailyLimitMinutes: rule.DailyLimitMinutes,
+			CreatedAt:         rule.CreatedAt,
+		})
+	}
+
+	return response
+}
+
+// findOwnedChildAndHandleError finds the child of the authenticated parent, it responds and returns nil if there is none.
+func findOwnedChildAndHandleError(w http.ResponseWriter, r *http.Request, tx *sql.Tx, childId int) *children.Model {
+	child, err := children.FindOneByIdAndOwnerUserId(tx, childId, authenticatedUserId(r))
+	if err != nil {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith500(w, r, nil)
+		log.Printf("error occured while trying to find child: %v", err)
+		return nil
+	}
+
+	if child == nil {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith404(w, r, ErrChildNotFound)
+		return nil
+	}
+
+	return child
+}
+
+type CreateAppRuleRequestBody struct {
+	MatchType         string `json:"matchType" validate:"required,oneof=path hash name"`
+	Pattern           string `json:"pattern" validate:"required"`
+	Action            string `json:"action" validate:"required,oneof=allow block limit"`
+	DailyLimitMinutes int    `json:"dailyLimitMinutes"`
+}
+
+func HttpChildrenAppRulesCreate(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreateAppRuleRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		_, err = apprules.Create(
+			tx,
+			child.Id,
+			apprules.MatchType(requestBody.MatchType),
+			requestBody.Pattern,
+			apprules.Action(requestBody.Action),
+			requestBody.DailyLimitMinutes,
+		)
+		if errors.Is(err, apprules.ErrInvalidPattern) || errors.Is(err, apprules.ErrInvalidDailyLimit) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if errors.Is(err, apprules.ErrRuleForThisPatternAlreadyExists) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create app rule: %v", err)
+			return
+		}
+
+		rules, err := apprules.FindAllByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find app rules: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishEvent(pushHub, child.Id, 0, push.EventTypePolicyChanged, PolicyChangedEvent{Setting: "app_rules"})
+
+		respondWithJson(w, r, http.StatusCreated, newAppRuleResponses(rules))
+	}
+}
+
+func HttpChildrenAppRulesList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		rules, err := apprules.FindAllByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find app rules: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, newAppRuleResponses(rules))
+	}
+}
+
+func HttpChildrenAppRulesDelete(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		ruleId, err := strconv.Atoi(chi.URLParam(r, "ruleId"))
+		if err != nil || ruleId <= 0 {
+			respondWith400(w, r, ErrInvalidAppRuleId)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		deleted, err := apprules.Delete(tx, child.Id, ruleId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to delete app rule: %v", err)
+			return
+		}
+
+		if !deleted {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrAppRuleNotFound)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishEvent(pushHub, child.Id, 0, push.EventTypePolicyChanged, PolicyChangedEvent{Setting: "app_rules"})
+
+		w.WriteHeader(204)
+	}
+}
+
+// HttpDeviceAppRulesList returns the app rules the agent enforces, it fetches them again after a policy_changed event.
+func HttpDeviceAppRulesList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device := authenticatedDevice(r)
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		rules, err := apprules.FindAllByChildId(tx, device.ChildId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find app rules: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, newAppRuleResponses(rules))
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"domanscy.group/parental-controls/server/push"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpAppRules(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/children/{childId}/app_rules", HttpChildrenAppRulesCreate(testingCfg, pushHub, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/children/{childId}/app_rules", HttpChildrenAppRulesList(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Delete("/children/{childId}/app_rules/{ruleId}", HttpChildrenAppRulesDelete(testingCfg, pushHub, db))
+	router.With(AuthenticateDeviceToken(db)).Get("/device/app_rules", HttpDeviceAppRulesList(testingCfg, db))
+
+	sendParentRequest := func(userId int, method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	rulesPath := fmt.Sprintf("/children/%d/app_rules", family.childId)
+
+	t.Run("creates rules and notifies devices", func(t *testing.T) {
+		subscription, _ := pushHub.Subscribe(family.childId, family.deviceId, 0)
+		defer subscription.Close()
+
+		recorder := sendParentRequest(family.userId, http.MethodPost, rulesPath, `{"matchType": "name", "pattern": "Minecraft*", "action": "limit", "dailyLimitMinutes": 60}`)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		var response []AppRuleResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if len(response) != 1 || response[0].Pattern != "minecraft*" || response[0].DailyLimitMinutes != 60 {
+			t.Fatalf("Unexpected response: %+v", response)
+		}
+
+		event := <-subscription.Events()
+		if event.Type != push.EventTypePolicyChanged || string(event.Data) != `{"setting":"app_rules"}` {
+			t.Fatalf("Unexpected event: %+v", event)
+		}
+	})
+
+	t.Run("rejects invalid and duplicate rules", func(t *testing.T) {
+		invalid := []string{
+			`{"matchType": "inode", "pattern": "1", "action": "block"}`,
+			`{"matchType": "path", "pattern": "games/supertux2", "action": "block"}`,
+			`{"matchType": "path", "pattern": "/usr/games/supertux2", "action": "uninstall"}`,
+			`{"matchType": "path", "pattern": "/usr/games/supertux2", "action": "limit"}`,
+		}
+
+		for _, body := range invalid {
+			recorder := sendParentRequest(family.userId, http.MethodPost, rulesPath, body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+
+		recorder := sendParentRequest(family.userId, http.MethodPost, rulesPath, `{"matchType": "name", "pattern": "minecraft*", "action": "block"}`)
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("devices fetch rules of their child", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/device/app_rules", nil)
+		request.Header.Set("Authorization", "Device "+family.deviceToken)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response []AppRuleResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if len(response) != 1 || response[0].Action != "limit" {
+			t.Fatalf("Unexpected response: %+v", response)
+		}
+	})
+
+	t.Run("does not show or delete rules of other parents children", func(t *testing.T) {
+		recorder := sendParentRequest(stranger.userId, http.MethodGet, rulesPath, "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(stranger.userId, http.MethodDelete, rulesPath+"/1", "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("deletes rules", func(t *testing.T) {
+		recorder := sendParentRequest(family.userId, http.MethodDelete, rulesPath+"/1", "")
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodDelete, rulesPath+"/1", "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, rulesPath, "")
+		if recorder.Code != http.StatusOK || strings.TrimSpace(recorder.Body.String()) != "[]" {
+			t.Fatalf("Got %d with %s, want empty list", recorder.Code, recorder.Body.String())
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package apprules
+
+import (
+	"path"
+	"strings"
+)
+
+// Process is a running program as seen by the agent on the device.
+type Process struct {
+	Pid int
+	// Name is the name the kernel knows the process by, e.g. /proc/<pid>/comm on Linux.
+	Name string
+	// Exe is the path of the executable.
+	Exe string
+	// ExeHash returns the hex encoded SHA-256 of the executable. It is called only when a rule matches by hash,
+	// hashing every started process would be too slow.
+	ExeHash func() (string, error)
+}
+
+// Matches reports whether the rule applies to the process.
+func (rule *Model) Matches(process *Process) (bool, error) {
+	switch rule.MatchType {
+	case MatchPath:
+		return process.Exe != "" && matchPattern(rule.Pattern, process.Exe), nil
+	case MatchName:
+		name := strings.ToLower(process.Name)
+		exeName := strings.ToLower(path.Base(process.Exe))
+
+		return name != "" && matchPattern(rule.Pattern, name) || process.Exe != "" && matchPattern(rule.Pattern, exeName), nil
+	case MatchHash:
+		if process.ExeHash == nil {
+			return false, nil
+		}
+
+		hash, err := process.ExeHash()
+		if err != nil {
+			return false, err
+		}
+
+		return hash == rule.Pattern, nil
+	default:
+		return false, nil
+	}
+}
+
+func matchPattern(pattern string, value string) bool {
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+// Decide returns the rule deciding about the process, nil if no rule matches. An allow rule wins over
+// the others, so a single game can be allowed in a blocked directory, and a block rule wins over a limit.
+func Decide(rules []Model, process *Process) (*Model, error) {
+	var decided *Model
+
+	for i := range rules {
+		rule := &rules[i]
+
+		if decided != nil && actionPriority(rule.Action) <= actionPriority(decided.Action) {
+			continue
+		}
+
+		matches, err := rule.Matches(process)
+		if err != nil {
+			return nil, err
+		}
+
+		if matches {
+			decided = rule
+		}
+	}
+
+	return decided, nil
+}
+
+func actionPriority(action Action) int {
+	switch action {
+	case ActionAllow:
+		return 3
+	case ActionBlock:
+		return 2
+	case ActionLimit:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,80 @@
+package apprules
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecide(t *testing.T) {
+	hashCalls := 0
+	supertux := &Process{
+		Pid:  100,
+		Name: "supertux2",
+		Exe:  "/usr/games/supertux2",
+		ExeHash: func() (string, error) {
+			hashCalls++
+			return "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", nil
+		},
+	}
+
+	firefox := &Process{Pid: 200, Name: "firefox", Exe: "/usr/lib/firefox/firefox"}
+
+	rules := []Model{
+		{Id: 1, MatchType: MatchPath, Pattern: "/usr/games/*", Action: ActionBlock},
+		{Id: 2, MatchType: MatchName, Pattern: "super*", Action: ActionLimit, DailyLimitMinutes: 30},
+		{Id: 3, MatchType: MatchHash, Pattern: "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", Action: ActionAllow},
+	}
+
+	t.Run("allow wins over block and block over limit", func(t *testing.T) {
+		rule, err := Decide(rules, supertux)
+		if err != nil || rule == nil || rule.Id != 3 {
+			t.Fatalf("Got %+v and %v, want rule 3", rule, err)
+		}
+
+		rule, err = Decide(rules[:2], supertux)
+		if err != nil || rule == nil || rule.Id != 1 {
+			t.Fatalf("Got %+v and %v, want rule 1", rule, err)
+		}
+	})
+
+	t.Run("hashes the executable only when a rule matches by hash", func(t *testing.T) {
+		hashCalls = 0
+
+		_, err := Decide(rules[:2], supertux)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hashCalls != 0 {
+			t.Fatalf("Got %d hash calls, want 0", hashCalls)
+		}
+	})
+
+	t.Run("matches names by the executable too", func(t *testing.T) {
+		renamed := &Process{Pid: 101, Name: "java", Exe: "/opt/SuperTux/SUPERTUX-launcher"}
+
+		rule, err := Decide(rules[1:2], renamed)
+		if err != nil || rule == nil || rule.Id != 2 {
+			t.Fatalf("Got %+v and %v, want rule 2", rule, err)
+		}
+	})
+
+	t.Run("returns nil without matching rules", func(t *testing.T) {
+		rule, err := Decide(rules, firefox)
+		if err != nil || rule != nil {
+			t.Fatalf("Got %+v and %v, want nil", rule, err)
+		}
+	})
+
+	t.Run("returns errors of hashing", func(t *testing.T) {
+		errUnreadable := errors.New("unreadable")
+		unreadable := &Process{Pid: 300, Name: "game", Exe: "/tmp/game", ExeHash: func() (string, error) {
+			return "", errUnreadable
+		}}
+
+		_, err := Decide(rules, unreadable)
+		if !errors.Is(err, errUnreadable) {
+			t.Fatalf("Expected %v, received %v", errUnreadable, err)
+		}
+	})
+}
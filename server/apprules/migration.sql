@@ -0,0 +1,10 @@
+CREATE TABLE app_rules (
+    id INTEGER PRIMARY KEY,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    match_type VARCHAR NOT NULL,
+    pattern VARCHAR NOT NULL,
+    action VARCHAR NOT NULL,
+    daily_limit_minutes INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (child_id, match_type, pattern)
+);
@@ -0,0 +1,181 @@
+package apprules
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+)
+
+// MaxDailyLimitMinutes is the longest daily limit of an app, a longer one would never be reached.
+const MaxDailyLimitMinutes = 24 * 60
+
+var ErrInvalidMatchType = errors.New("invalid match type")
+var ErrInvalidAction = errors.New("invalid action")
+var ErrInvalidPattern = errors.New("invalid pattern")
+var ErrInvalidDailyLimit = fmt.Errorf("daily limit must be between 1 and %d minutes for limit rules and 0 for the others", MaxDailyLimitMinutes)
+var ErrRuleForThisPatternAlreadyExists = errors.New("rule for this pattern already exists")
+
+type MatchType string
+
+const (
+	// MatchPath compares the path of the executable, the pattern may contain wildcards of path.Match.
+	MatchPath MatchType = "path"
+	// MatchHash compares the hex encoded SHA-256 of the executable, so a renamed copy is matched too.
+	MatchHash MatchType = "hash"
+	// MatchName compares the process name case insensitively, the pattern may contain wildcards of path.Match.
+	MatchName MatchType = "name"
+)
+
+func (matchType MatchType) IsValid() bool {
+	switch matchType {
+	case MatchPath, MatchHash, MatchName:
+		return true
+	default:
+		return false
+	}
+}
+
+type Action string
+
+const (
+	ActionAllow Action = "allow"
+	ActionBlock Action = "block"
+	// ActionLimit lets the app run until it was used for DailyLimitMinutes on the day.
+	ActionLimit Action = "limit"
+)
+
+func (action Action) IsValid() bool {
+	return action == ActionAllow || action == ActionBlock || action == ActionLimit
+}
+
+type Model struct {
+	Id        int
+	ChildId   int
+	MatchType MatchType
+	Pattern   string
+	Action    Action
+	// DailyLimitMinutes is set only for limit rules.
+	DailyLimitMinutes int
+	CreatedAt         time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+// NormalizePattern checks the pattern against its match type. Hashes are lowercased, names too,
+// as they are compared case insensitively.
+func NormalizePattern(matchType MatchType, pattern string) (string, error) {
+	pattern = strings.TrimSpace(pattern)
+
+	switch matchType {
+	case MatchPath:
+		if !strings.HasPrefix(pattern, "/") || path.Clean(pattern) != pattern {
+			return "", ErrInvalidPattern
+		}
+	case MatchHash:
+		pattern = strings.ToLower(pattern)
+		if len(pattern) != 64 || strings.Trim(pattern, "0123456789abcdef") != "" {
+			return "", ErrInvalidPattern
+		}
+	case MatchName:
+		pattern = strings.ToLower(pattern)
+		if pattern == "" || strings.Contains(pattern, "/") {
+			return "", ErrInvalidPattern
+		}
+	default:
+		return "", ErrInvalidMatchType
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", ErrInvalidPattern
+	}
+
+	return pattern, nil
+}
+
+const selectColumns = "id, child_id, match_type, pattern, action, daily_limit_minutes, created_at"
+
+func FindAllByChildId(db *sql.Tx, childId int) ([]Model, error) {
+	rows, err := db.Query("SELECT "+selectColumns+" FROM app_rules WHERE child_id = $1 ORDER BY id", childId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM app_rules ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	rules := make([]Model, 0)
+
+	for rows.Next() {
+		rule := Model{}
+		err := rows.Scan(&rule.Id, &rule.ChildId, &rule.MatchType, &rule.Pattern, &rule.Action, &rule.DailyLimitMinutes, &rule.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func Create(db *sql.Tx, childId int, matchType MatchType, pattern string, action Action, dailyLimitMinutes int) (int, error) {
+	if !action.IsValid() {
+		return 0, ErrInvalidAction
+	}
+
+	if action == ActionLimit && (dailyLimitMinutes < 1 || dailyLimitMinutes > MaxDailyLimitMinutes) || action != ActionLimit && dailyLimitMinutes != 0 {
+		return 0, ErrInvalidDailyLimit
+	}
+
+	pattern, err := NormalizePattern(matchType, pattern)
+	if err != nil {
+		return 0, err
+	}
+
+	exec, err := db.Exec(
+		"INSERT INTO app_rules (child_id, match_type, pattern, action, daily_limit_minutes) VALUES (?, ?, ?, ?, ?);",
+		childId,
+		matchType,
+		pattern,
+		action,
+		dailyLimitMinutes,
+	)
+	if err != nil {
+		if err.Error() == "UNIQUE constraint failed: app_rules.child_id, app_rules.match_type, app_rules.pattern" {
+			return 0, ErrRuleForThisPatternAlreadyExists
+		}
+
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO app_rules ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+func Delete(db *sql.Tx, childId int, id int) (bool, error) {
+	executed, err := db.Exec("DELETE FROM app_rules WHERE id = ? AND child_id = ?", id, childId)
+	if err != nil {
+		return false, fmt.Errorf("an error occured while trying to execute query 'DELETE FROM app_rules ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	return affectedRows > 0, nil
+}
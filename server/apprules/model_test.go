@@ -0,0 +1,146 @@
+package apprules
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"domanscy.group/parental-controls/server/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0016_app_rules": MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestNormalizePattern(t *testing.T) {
+	hash := strings.Repeat("AB", 32)
+
+	valid := []struct {
+		matchType MatchType
+		pattern   string
+		want      string
+	}{
+		{MatchPath, "/usr/games/supertux2", "/usr/games/supertux2"},
+		{MatchPath, " /home/*/Games/* ", "/home/*/Games/*"},
+		{MatchHash, hash, strings.ToLower(hash)},
+		{MatchName, "Steam*", "steam*"},
+	}
+
+	for _, testCase := range valid {
+		got, err := NormalizePattern(testCase.matchType, testCase.pattern)
+		if err != nil || got != testCase.want {
+			t.Errorf("%s %q: got %q and %v, want %q", testCase.matchType, testCase.pattern, got, err, testCase.want)
+		}
+	}
+
+	invalid := []struct {
+		matchType MatchType
+		pattern   string
+	}{
+		{MatchPath, "games/supertux2"},
+		{MatchPath, "/usr/games/../bin/bash"},
+		{MatchHash, "abc"},
+		{MatchHash, strings.Repeat("zz", 32)},
+		{MatchName, ""},
+		{MatchName, "bin/steam"},
+		{MatchName, "steam["},
+	}
+
+	for _, testCase := range invalid {
+		_, err := NormalizePattern(testCase.matchType, testCase.pattern)
+		if !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("%s %q: expected %v, received %v", testCase.matchType, testCase.pattern, ErrInvalidPattern, err)
+		}
+	}
+
+	_, err := NormalizePattern(MatchType("inode"), "1")
+	if !errors.Is(err, ErrInvalidMatchType) {
+		t.Errorf("Expected %v, received %v", ErrInvalidMatchType, err)
+	}
+}
+
+func TestAppRules(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	t.Run("validates action and daily limit", func(t *testing.T) {
+		_, err := Create(tx, 1, MatchName, "steam", Action("uninstall"), 0)
+		if !errors.Is(err, ErrInvalidAction) {
+			t.Errorf("Expected %v, received %v", ErrInvalidAction, err)
+		}
+
+		_, err = Create(tx, 1, MatchName, "steam", ActionLimit, 0)
+		if !errors.Is(err, ErrInvalidDailyLimit) {
+			t.Errorf("Expected %v, received %v", ErrInvalidDailyLimit, err)
+		}
+
+		_, err = Create(tx, 1, MatchName, "steam", ActionBlock, 30)
+		if !errors.Is(err, ErrInvalidDailyLimit) {
+			t.Errorf("Expected %v, received %v", ErrInvalidDailyLimit, err)
+		}
+	})
+
+	t.Run("creates, lists and deletes rules", func(t *testing.T) {
+		blockId, err := Create(tx, 1, MatchPath, "/usr/games/*", ActionBlock, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Create(tx, 1, MatchName, "Minecraft", ActionLimit, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Create(tx, 1, MatchName, "minecraft", ActionBlock, 0)
+		if !errors.Is(err, ErrRuleForThisPatternAlreadyExists) {
+			t.Errorf("Expected %v, received %v", ErrRuleForThisPatternAlreadyExists, err)
+		}
+
+		rules, err := FindAllByChildId(tx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rules) != 2 || rules[1].Pattern != "minecraft" || rules[1].DailyLimitMinutes != 60 {
+			t.Fatalf("Unexpected rules: %+v", rules)
+		}
+
+		deleted, err := Delete(tx, 2, blockId)
+		if err != nil || deleted {
+			t.Fatalf("Got %v and %v deleting a rule of another child, want false", deleted, err)
+		}
+
+		deleted, err = Delete(tx, 1, blockId)
+		if err != nil || !deleted {
+			t.Fatalf("Got %v and %v, want true", deleted, err)
+		}
+	})
+}
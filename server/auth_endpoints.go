@@ -1,12 +1,10 @@
 package main
 
 import (
-	"bytes"
 	"database/sql"
 	_ "embed"
 	"errors"
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
 	"net/url"
@@ -14,6 +12,7 @@ import (
 	"strings"
 
 	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/client/i18n"
 	"domanscy.group/parental-controls/server/users"
 	"domanscy.group/rckstrvcache"
 	"github.com/go-chi/chi"
@@ -21,32 +20,40 @@ import (
 
 var ErrUserWithGivenEmailDoesNotExist = errors.New("user with given email does not exist")
 
-func HttpAuthLogin(cfg *ServerConfig, _ *rckstrvcache.Store, _ *rckstrvcache.Store, db *sql.DB) func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		type RequestBody struct {
-			Email    string `json:"email"`
-			Callback string `json:"callback"`
-		}
-
-		var requestBody RequestBody
+type LoginRequestBody struct {
+	Email    string `json:"email" validate:"required,email"`
+	Callback string `json:"callback" validate:"required,url"`
+}
 
-		if err := decodeJsonRequestBodyAndSendHttpErrorIfInvalid(w, r, &requestBody); err != nil {
-			return
-		}
+// authFieldSentinel keeps the answers of the login and the registration to an invalid email or callback, see
+// fieldSentinels.
+func authFieldSentinel(field string) error {
+	switch field {
+	case "email":
+		return ErrInvalidEmail
+	case "callback":
+		return ErrInvalidCallbackUrl
+	default:
+		return nil
+	}
+}
 
-		requestBody.Email = strings.ToLower(requestBody.Email)
+func (LoginRequestBody) fieldSentinel(field string) error {
+	return authFieldSentinel(field)
+}
 
-		if err := parseEmailAddressAndHandleErrorIfInvalid(w, r, requestBody.Email); err != nil {
+func HttpAuthLogin(cfg *ServerConfig, _ *rckstrvcache.Store, _ *rckstrvcache.Store, db *sql.DB) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestBody, err := decodeJsonRequestBody[LoginRequestBody](w, r)
+		if err != nil {
 			return
 		}
 
-		if _, err := parseUrlAndHandleErrorIfInvalid(w, r, requestBody.Callback); err != nil {
-			return
-		}
+		requestBody.Email = strings.ToLower(requestBody.Email)
 
 		tx, err := db.BeginTx(r.Context(), nil)
 		if err != nil {
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			log.Printf("error occured while trying to start a transaction: %v", err)
 			return
 		}
@@ -54,44 +61,38 @@ func HttpAuthLogin(cfg *ServerConfig, _ *rckstrvcache.Store, _ *rckstrvcache.Sto
 		user, err := users.FindOneByEmail(tx, requestBody.Email)
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, tx.Rollback())
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			log.Printf("error occured while trying to find user by email: %v", err)
 			return
 		}
 
 		if user == nil {
 			err = littlehelpers.IfErrJoin(err, tx.Rollback())
-			respondWith400(w, r, ErrUserWithGivenEmailDoesNotExist.Error())
+			respondWith400(w, r, ErrUserWithGivenEmailDoesNotExist)
 			return
 		}
 
-		var message strings.Builder
-
-		message.WriteString(fmt.Sprintf("From: %s\r\n", cfg.EmailFromAddress))
-		message.WriteString(fmt.Sprintf("To: %s\r\n", requestBody.Email))
-		message.WriteString(fmt.Sprintf("Subject: %s\r\n", "Zaloguj się"))
-		message.WriteString(fmt.Sprintf("\r\n"))
-		message.WriteString(fmt.Sprintf("Hello world!\r\n"))
+		language := recipientLanguage(r, user, requestBody.Callback)
 
 		var mailBody strings.Builder
 
-		mailBody.WriteString("Otrzymaliśmy prośbę o zalogowanie się do systemu.<br />")
-		mailBody.WriteString(fmt.Sprintf("Prosimy o potwierdzenie czy ta osoba może się zalogować.<br />"))
+		mailBody.WriteString(fmt.Sprintf("%s<br />", mailCatalog.Text(language, "login.requested")))
+		mailBody.WriteString(fmt.Sprintf("%s<br />", mailCatalog.Text(language, "login.confirm")))
 		mailBody.WriteString(fmt.Sprintf("<br />"))
 
 		ip, err := getIPAddressFromRequest(w, r)
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, tx.Rollback())
-			respondWith400(w, r, err.Error())
+			respondWith400(w, r, err)
 			return
 		}
 
-		mailBody.WriteString(fmt.Sprintf("Adres IP logowania: %s<br />", ip))
+		mailBody.WriteString(fmt.Sprintf("%s<br />", mailCatalog.Text(language, "login.ip", ip)))
 
 		mailBody.WriteString(fmt.Sprintf("<br />"))
 
-		mailBody.WriteString(fmt.Sprintf("<a href=\"#\">Odrzuć</a> "))
-		mailBody.WriteString(fmt.Sprintf("<a href=\"#\">Zezwól</a>"))
+		mailBody.WriteString(fmt.Sprintf("<a href=\"#\">%s</a> ", mailCatalog.Text(language, "deny")))
+		mailBody.WriteString(fmt.Sprintf("<a href=\"#\">%s</a>", mailCatalog.Text(language, "login.allow")))
 
 		err = sendMailAndHandleError(
 			w, r,
@@ -99,20 +100,20 @@ func HttpAuthLogin(cfg *ServerConfig, _ *rckstrvcache.Store, _ *rckstrvcache.Sto
 			cfg.SmtpPort,
 			cfg.EmailFromAddress,
 			requestBody.Email,
-			"Potwierdź logowanie do kontroli rodzicielskiej",
+			mailCatalog.Text(language, "login.subject"),
 			mailBody.String(),
 		)
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, tx.Rollback())
 			log.Printf("failed to send login email: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
 		err = tx.Commit()
 		if err != nil {
 			log.Printf("failed to commit the transaction: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
@@ -122,35 +123,35 @@ func HttpAuthLogin(cfg *ServerConfig, _ *rckstrvcache.Store, _ *rckstrvcache.Sto
 
 //go:embed mail_templates/register.gohtml
 var startRegistrationProcessEmailBody string
-var startRegistrationProcessEmailTemplate = template.Must(template.New("email_template").Parse(startRegistrationProcessEmailBody))
+var startRegistrationProcessEmailTemplate = newMailTemplate(startRegistrationProcessEmailBody)
 
 var ErrUserWithGivenEmailAlreadyExists = errors.New("user with given email already exists")
 
-func HttpAuthStartRegistrationProcess(cfg *ServerConfig, regkeysStore *rckstrvcache.Store, _ *rckstrvcache.Store, db *sql.DB) func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		type RequestBody struct {
-			Email    string `json:"email"`
-			Callback string `json:"callback"`
-		}
-
-		var requestBody RequestBody
+type RegistrationRequestBody struct {
+	Email    string `json:"email" validate:"required,email"`
+	Callback string `json:"callback" validate:"required,url"`
+}
 
-		if err := decodeJsonRequestBodyAndSendHttpErrorIfInvalid(w, r, &requestBody); err != nil {
-			return
-		}
+func (RegistrationRequestBody) fieldSentinel(field string) error {
+	return authFieldSentinel(field)
+}
 
-		if err := parseEmailAddressAndHandleErrorIfInvalid(w, r, requestBody.Email); err != nil {
+func HttpAuthStartRegistrationProcess(cfg *ServerConfig, regkeysStore *rckstrvcache.Store, _ *rckstrvcache.Store, db *sql.DB) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestBody, err := decodeJsonRequestBody[RegistrationRequestBody](w, r)
+		if err != nil {
 			return
 		}
 
-		callbackUrl, err := parseUrlAndHandleErrorIfInvalid(w, r, requestBody.Callback)
+		callbackUrl, err := url.Parse(requestBody.Callback)
 		if err != nil {
+			respondWith400(w, r, ErrInvalidCallbackUrl)
 			return
 		}
 
 		tx, err := db.BeginTx(r.Context(), nil)
 		if err != nil {
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			log.Printf("error occured while trying to start a transaction: %v", err)
 			return
 		}
@@ -158,14 +159,14 @@ func HttpAuthStartRegistrationProcess(cfg *ServerConfig, regkeysStore *rckstrvca
 		user, err := users.FindOneByEmail(tx, requestBody.Email)
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, tx.Rollback())
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			log.Printf("error occured while trying to find user by email: %v", err)
 			return
 		}
 
 		if user != nil {
 			err = littlehelpers.IfErrJoin(err, tx.Rollback())
-			respondWith400(w, r, ErrUserWithGivenEmailAlreadyExists.Error())
+			respondWith400(w, r, ErrUserWithGivenEmailAlreadyExists)
 			return
 		}
 
@@ -180,12 +181,13 @@ func HttpAuthStartRegistrationProcess(cfg *ServerConfig, regkeysStore *rckstrvca
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, regkeysTx.Rollback(), tx.Rollback())
 			log.Printf("an error occured while trying to generate new regkey for email '%s': %v", requestBody.Email, err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
-		emailBody := bytes.NewBuffer([]byte{})
-		err = startRegistrationProcessEmailTemplate.ExecuteTemplate(emailBody, "email_template", struct {
+		language := recipientLanguage(r, nil, requestBody.Callback)
+
+		emailBody, err := executeMailTemplate(startRegistrationProcessEmailTemplate, language, struct {
 			InstanceAddr       string
 			IsOfficialInstance bool
 			Link               string
@@ -197,7 +199,7 @@ func HttpAuthStartRegistrationProcess(cfg *ServerConfig, regkeysStore *rckstrvca
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, regkeysTx.Rollback(), tx.Rollback())
 			log.Printf("failed to construct email template: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
@@ -207,13 +209,13 @@ func HttpAuthStartRegistrationProcess(cfg *ServerConfig, regkeysStore *rckstrvca
 			cfg.SmtpPort,
 			cfg.EmailFromAddress,
 			requestBody.Email,
-			"Potwierdź rejestracje w kontroli rodzicielskiej",
-			emailBody.String(),
+			mailCatalog.Text(language, "register.subject"),
+			emailBody,
 		)
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, regkeysTx.Rollback(), tx.Rollback())
 			log.Printf("failed to send mail: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
@@ -221,14 +223,14 @@ func HttpAuthStartRegistrationProcess(cfg *ServerConfig, regkeysStore *rckstrvca
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, tx.Rollback())
 			log.Printf("failed to commit to regkeys store: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
 		err = tx.Commit()
 		if err != nil {
 			log.Printf("failed to commit the transaction: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
@@ -243,13 +245,13 @@ func HttpAuthFinishRegistrationProcess(_ *ServerConfig, regkeyStore *rckstrvcach
 	return func(w http.ResponseWriter, r *http.Request) {
 		regkey := chi.URLParam(r, "regkey")
 		if regkey == "" {
-			respondWith400(w, r, ErrRegistrationKeyCannotBeEmpty.Error())
+			respondWith400(w, r, ErrRegistrationKeyCannotBeEmpty)
 			return
 		}
 
 		regkey, err := url.PathUnescape(regkey)
 		if err != nil {
-			respondWith400(w, r, ErrInvalidRegistrationKey.Error())
+			respondWith400(w, r, ErrInvalidRegistrationKey)
 			return
 		}
 
@@ -291,6 +293,19 @@ func HttpAuthFinishRegistrationProcess(_ *ServerConfig, regkeyStore *rckstrvcach
 				return fmt.Errorf("error occured while trying to create user in db: %v", err)
 			}
 
+			// the emails of the user are in the language of the client they registered with, or of their browser
+			language, supported := callbackLanguage(callbackUrl.String())
+			if !supported {
+				language, supported = i18n.MatchLanguage(r.Header.Get("Accept-Language"))
+			}
+
+			if supported {
+				err = users.UpdateLanguage(tx, userId, string(language))
+				if err != nil {
+					return littlehelpers.IfErrJoin(fmt.Errorf("error occured while trying to store language of the user: %w", err), tx.Rollback())
+				}
+			}
+
 			err = oneTimeAccessTokenStore.InTransaction(func(oneTimeAccessTokenStore rckstrvcache.StoreCompatible) error {
 				oneTimeAccessToken, err := oneTimeAccessTokenStore.Put(fmt.Sprintf("userId:%d", userId))
 				if err != nil {
@@ -331,12 +346,12 @@ func HttpAuthFinishRegistrationProcess(_ *ServerConfig, regkeyStore *rckstrvcach
 		})
 		if err != nil {
 			if errors.Is(err, ErrInvalidRegistrationKey) {
-				respondWith400(w, r, err.Error())
+				respondWith400(w, r, err)
 				return
 			}
 
 			log.Println(err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
@@ -352,19 +367,19 @@ func HttpAuthGetBearerTokenFromOtat(cfg *ServerConfig, regkeyStore *rckstrvcache
 		otatToken := chi.URLParam(r, "otat")
 
 		if len(otatToken) == 0 {
-			respondWith400(w, r, ErrInvalidOtat.Error())
+			respondWith400(w, r, ErrInvalidOtat)
 			return
 		}
 
 		otatTx, err := otatStore.Begin()
 		if err != nil {
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
 		otatToken, err = url.PathUnescape(otatToken)
 		if err != nil {
-			respondWith400(w, r, ErrInvalidOtat.Error())
+			respondWith400(w, r, ErrInvalidOtat)
 			return
 		}
 
@@ -372,13 +387,13 @@ func HttpAuthGetBearerTokenFromOtat(cfg *ServerConfig, regkeyStore *rckstrvcache
 		if err != nil {
 			err = littlehelpers.IfErrJoin(otatTx.Rollback(), err)
 			log.Printf("error occured while trying to get otat from cache: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
 		if !exists {
 			err = littlehelpers.IfErrJoin(err, otatTx.Rollback())
-			respondWith400(w, r, ErrInvalidOtat.Error())
+			respondWith400(w, r, ErrInvalidOtat)
 			return
 		}
 
@@ -386,7 +401,7 @@ func HttpAuthGetBearerTokenFromOtat(cfg *ServerConfig, regkeyStore *rckstrvcache
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, otatTx.Rollback())
 			log.Printf("error occured while trying to get userId from otat cache payload: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
@@ -394,7 +409,7 @@ func HttpAuthGetBearerTokenFromOtat(cfg *ServerConfig, regkeyStore *rckstrvcache
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, otatTx.Rollback())
 			log.Printf("error occured while trying to start transaction: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
@@ -402,7 +417,7 @@ func HttpAuthGetBearerTokenFromOtat(cfg *ServerConfig, regkeyStore *rckstrvcache
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, tx.Rollback(), otatTx.Rollback())
 			log.Printf("error occured while trying to find user by id: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
@@ -415,7 +430,7 @@ func HttpAuthGetBearerTokenFromOtat(cfg *ServerConfig, regkeyStore *rckstrvcache
 				err = errors.Join(err, txErr, otatTxErr)
 
 				log.Printf("failed to rollback transaction(s) after user == nil error: %v", err)
-				respondWith500(w, r, "")
+				respondWith500(w, r, nil)
 				return
 			} else {
 				txErr := tx.Commit()
@@ -425,12 +440,12 @@ func HttpAuthGetBearerTokenFromOtat(cfg *ServerConfig, regkeyStore *rckstrvcache
 
 				if err != nil {
 					log.Printf("failed to commit transaction(s) after user == nil error: %v", err)
-					respondWith500(w, r, "")
+					respondWith500(w, r, nil)
 					return
 				}
 			}
 
-			respondWith400(w, r, ErrInvalidOtat.Error())
+			respondWith400(w, r, ErrInvalidOtat)
 			return
 		}
 
@@ -438,7 +453,7 @@ func HttpAuthGetBearerTokenFromOtat(cfg *ServerConfig, regkeyStore *rckstrvcache
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, tx.Rollback(), otatTx.Rollback())
 			log.Printf("error occured while trying to create bearer token: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
@@ -446,14 +461,14 @@ func HttpAuthGetBearerTokenFromOtat(cfg *ServerConfig, regkeyStore *rckstrvcache
 		if err != nil {
 			err = littlehelpers.IfErrJoin(err, otatTx.Rollback())
 			log.Printf("error occured while trying to commit: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
 		err = otatTx.Commit()
 		if err != nil {
 			log.Printf("error occured while trying to commit: %v", err)
-			respondWith500(w, r, "")
+			respondWith500(w, r, nil)
 			return
 		}
 
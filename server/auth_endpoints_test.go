@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"domanscy.group/parental-controls/server/database"
+	"domanscy.group/parental-controls/server/migrations"
 	"domanscy.group/parental-controls/server/users"
 	"domanscy.group/rckstrvcache"
 	"github.com/go-chi/chi"
@@ -38,6 +39,9 @@ var testingCfg = &ServerConfig{
 	BearerTokenPrivateKey: rsaMustGenerateKey(),
 
 	DatabaseUrl: ":memory:",
+
+	ReportsLocation:  time.UTC,
+	HeartbeatTimeout: 15 * time.Minute,
 }
 
 func rsaMustGenerateKey() *rsa.PrivateKey {
@@ -49,21 +53,22 @@ func rsaMustGenerateKey() *rsa.PrivateKey {
 	return key
 }
 
-func doTFatalIfErr(t *testing.T, err error) {
+func doTFatalIfErr(t testing.TB, err error) {
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-func openDatabase(t *testing.T) *sql.DB {
+func openDatabase(t testing.TB) *sql.DB {
 	db, err := sql.Open("sqlite3", testingCfg.DatabaseUrl)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = database.Migrate(db, map[string]string{
-		"0001_users": users.MigrationFile,
-	})
+	// every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, migrations.All)
 	if err != nil {
 		t.Fatal(err)
 	}
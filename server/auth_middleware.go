@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/users"
+)
+
+var ErrMissingBearerToken = errors.New("missing bearer token")
+var ErrInvalidBearerToken = errors.New("invalid bearer token")
+var ErrMissingDeviceToken = errors.New("missing device token")
+var ErrInvalidDeviceToken = errors.New("invalid device token")
+
+type contextKey string
+
+const authenticatedUserIdContextKey contextKey = "authenticatedUserId"
+const authenticatedDeviceContextKey contextKey = "authenticatedDevice"
+const userLanguageContextKey contextKey = "userLanguage"
+
+// AuthenticateBearerToken rejects requests without a valid "Authorization: Bearer ..." header
+// and stores the id of the authenticated user in the request context, see authenticatedUserId.
+func AuthenticateBearerToken(cfg *ServerConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found || token == "" {
+				respondWith401(w, r, ErrMissingBearerToken)
+				return
+			}
+
+			userId, err := GetUserIdFromBearerToken(cfg.BearerTokenPrivateKey, []byte(token))
+			if err != nil {
+				respondWith401(w, r, ErrInvalidBearerToken)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authenticatedUserIdContextKey, userId)))
+		})
+	}
+}
+
+// authenticatedUserId must only be called by handlers behind AuthenticateBearerToken.
+func authenticatedUserId(r *http.Request) int {
+	return r.Context().Value(authenticatedUserIdContextKey).(int)
+}
+
+// LoadUserLanguage stores the language the authenticated user chose in the request context, so errors are written in
+// it instead of the one of the Accept-Language header, see requestLanguage. It must come after AuthenticateBearerToken.
+func LoadUserLanguage(db *sql.DB) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tx, err := db.BeginTx(r.Context(), &sql.TxOptions{ReadOnly: true})
+			if err != nil {
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to start a transaction: %v", err)
+				return
+			}
+
+			user, err := users.FindOneById(tx, authenticatedUserId(r))
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to find the authenticated user: %v", err)
+				return
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				respondWith500(w, r, nil)
+				log.Printf("failed to commit the transaction: %v", err)
+				return
+			}
+
+			if user == nil {
+				respondWith401(w, r, ErrInvalidBearerToken)
+				return
+			}
+
+			language, supported := i18n.ParseLanguage(user.Language)
+			if !supported {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userLanguageContextKey, language)))
+		})
+	}
+}
+
+// requestLanguage is the language of the responses, the one the user chose behind LoadUserLanguage and the one of the
+// Accept-Language header otherwise.
+func requestLanguage(r *http.Request) i18n.Language {
+	if language, chosen := r.Context().Value(userLanguageContextKey).(i18n.Language); chosen {
+		return language
+	}
+
+	return i18n.PreferredLanguage(r.Header.Get("Accept-Language"))
+}
+
+// AuthenticateDeviceToken rejects requests without a valid "Authorization: Device ..." header, the token is the one
+// the agent received when the device was created, not the one of the DoH url. The device is stored in the request
+// context, see authenticatedDevice.
+func AuthenticateDeviceToken(db *sql.DB) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Device ")
+			if !found || token == "" {
+				respondWith401(w, r, ErrMissingDeviceToken)
+				return
+			}
+
+			tx, err := db.BeginTx(r.Context(), &sql.TxOptions{ReadOnly: true})
+			if err != nil {
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to start a transaction: %v", err)
+				return
+			}
+
+			device, err := devices.FindOneByToken(tx, token)
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to find device by token: %v", err)
+				return
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				respondWith500(w, r, nil)
+				log.Printf("failed to commit the transaction: %v", err)
+				return
+			}
+
+			if device == nil {
+				respondWith401(w, r, ErrInvalidDeviceToken)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authenticatedDeviceContextKey, device)))
+		})
+	}
+}
+
+// authenticatedDevice must only be called by handlers behind AuthenticateDeviceToken.
+func authenticatedDevice(r *http.Request) *devices.Model {
+	return r.Context().Value(authenticatedDeviceContextKey).(*devices.Model)
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/client/components"
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/accessrequests"
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/dnsfilter"
+	"domanscy.group/parental-controls/server/domainrules"
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/webhooks"
+	"github.com/go-chi/chi"
+)
+
+var ErrUnknownDevice = errors.New("this device is not managed by parental controls")
+var ErrDomainIsNotBlocked = errors.New("domain is not blocked")
+var ErrAccessRequestNotFound = errors.New("access request not found")
+var ErrInvalidDuration = errors.New("invalid duration")
+
+// accessRequestDurations are the minutes offered to the parent in the email, approve links with any other duration
+// are rejected.
+var accessRequestDurations = []int{30, 60, 120, 24 * 60}
+
+func isOfferedAccessRequestDuration(minutes int) bool {
+	return slices.Contains(accessRequestDurations, minutes)
+}
+
+//go:embed mail_templates/access_request.gohtml
+var accessRequestEmailBody string
+var accessRequestEmailTemplate = newMailTemplate(accessRequestEmailBody)
+
+const askForAccessPath = "/ask_for_access"
+
+// NewBlockPageServer serves the block page to devices whose queries were answered with DnsBlockPageIp.
+// Every path of every host renders the block page, as the browser keeps the url the child tried to open.
+func NewBlockPageServer(cfg ServerConfig, db *sql.DB, blocklistMatcher *blocklists.Matcher) http.Handler {
+	r := chi.NewRouter()
+	r.Use(AssignRequestId)
+
+	policies := dnsfilter.NewDatabasePolicySource(db, blocklistMatcher)
+
+	r.Get("/assets/*", http.StripPrefix("/assets/", http.FileServerFS(components.Assets())).ServeHTTP)
+	r.Post(askForAccessPath, HttpBlockPageAskForAccess(&cfg, db, policies))
+	r.Get("/*", HttpBlockPage(&cfg, policies))
+
+	return r
+}
+
+func blockedDomainFromRequest(r *http.Request) string {
+	host := r.Host
+
+	if hostWithoutPort, _, err := net.SplitHostPort(host); err == nil {
+		host = hostWithoutPort
+	}
+
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// policyForBlockPageRequest recognises the device by the address it connected from. X-Forwarded-For is not trusted here,
+// otherwise anyone could ask for access in the name of another device.
+func policyForBlockPageRequest(r *http.Request, policies dnsfilter.PolicySource) (*dnsfilter.Policy, error) {
+	clientIp, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client ip address from '%s': %w", r.RemoteAddr, err)
+	}
+
+	return policies.PolicyForClientIp(r.Context(), clientIp)
+}
+
+func HttpBlockPage(_ *ServerConfig, policies dnsfilter.PolicySource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		language := i18n.PreferredLanguage(r.Header.Get("Accept-Language"))
+
+		params := components.BlockPageParams{Domain: blockedDomainFromRequest(r)}
+
+		policy, err := policyForBlockPageRequest(r, policies)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find policy of block page client: %v", err)
+			return
+		}
+
+		if policy != nil {
+			decision := policy.Decide(params.Domain)
+			if decision.Blocked {
+				params.Reason = string(decision.Reason)
+				params.Rule = decision.Rule
+				params.Category = string(decision.Category)
+				params.AskForAccessUrl = askForAccessPath
+			}
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		respondWithHtml(w, r, http.StatusForbidden, components.BlockPage(language, params))
+	}
+}
+
+func HttpBlockPageAskForAccess(cfg *ServerConfig, db *sql.DB, policies dnsfilter.PolicySource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		language := i18n.PreferredLanguage(r.Header.Get("Accept-Language"))
+
+		domain, err := domainrules.NormalizeDomain(r.PostFormValue("domain"))
+		if err != nil {
+			respondWith400(w, r, domainrules.ErrInvalidDomain)
+			return
+		}
+
+		policy, err := policyForBlockPageRequest(r, policies)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find policy of block page client: %v", err)
+			return
+		}
+
+		if policy == nil {
+			respondWith404(w, r, ErrUnknownDevice)
+			return
+		}
+
+		decision := policy.Decide(domain)
+		if !decision.Blocked {
+			respondWith400(w, r, ErrDomainIsNotBlocked)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		pending, err := accessrequests.FindPendingByChildIdAndDomain(tx, policy.ChildId, domain, time.Now())
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find pending access request: %v", err)
+			return
+		}
+
+		if pending != nil {
+			// the parent already got an email about this domain
+			err = tx.Rollback()
+			if err != nil {
+				log.Printf("failed to rollback the transaction: %v", err)
+			}
+
+			respondWithHtml(w, r, http.StatusOK, components.AccessRequestSentPage(language, domain))
+			return
+		}
+
+		child, parent, err := findChildAndParent(tx, policy.ChildId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find parent of the child: %v", err)
+			return
+		}
+
+		accessRequestId, token, err := accessrequests.Create(tx, child.Id, policy.DeviceId, domain, string(decision.Reason), decision.Rule)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create access request: %v", err)
+			return
+		}
+
+		err = enqueueWebhookEvent(tx, child, webhooks.EventAccessRequested, "", WebhookEventData{AccessRequest: &AccessRequestDecidedEvent{
+			Id:     accessRequestId,
+			Domain: domain,
+			Status: string(accessrequests.StatusPending),
+		}}, time.Now())
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to enqueue webhook event: %v", err)
+			return
+		}
+
+		type approveLink struct {
+			Label string
+			Link  string
+		}
+
+		approveLinks := make([]approveLink, 0, len(accessRequestDurations))
+
+		parentLanguage := userLanguage(parent)
+
+		for _, minutes := range accessRequestDurations {
+			approveLinks = append(approveLinks, approveLink{
+				Label: formatDuration(parentLanguage, minutes),
+				Link:  fmt.Sprintf("%s/access_requests/%s/approve?minutes=%d", cfg.AppUrl, url.PathEscape(token), minutes),
+			})
+		}
+
+		emailBody, err := executeMailTemplate(accessRequestEmailTemplate, parentLanguage, struct {
+			ChildName    string
+			Domain       string
+			Reason       string
+			Rule         string
+			Category     string
+			ApproveLinks []approveLink
+			DenyLink     string
+		}{
+			ChildName:    child.Name,
+			Domain:       domain,
+			Reason:       string(decision.Reason),
+			Rule:         decision.Rule,
+			Category:     string(decision.Category),
+			ApproveLinks: approveLinks,
+			DenyLink:     fmt.Sprintf("%s/access_requests/%s/deny", cfg.AppUrl, url.PathEscape(token)),
+		})
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			log.Printf("failed to construct email template: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		err = sendMailAndHandleError(
+			w, r,
+			cfg.SmtpAddress,
+			cfg.SmtpPort,
+			cfg.EmailFromAddress,
+			parent.Email,
+			mailCatalog.Text(parentLanguage, "access_request.subject", child.Name, domain),
+			emailBody,
+		)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			log.Printf("failed to send mail: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithHtml(w, r, http.StatusOK, components.AccessRequestSentPage(language, domain))
+	}
+}
+
+// findAccessRequestByTokenAndHandleErrorIfMissing responds with 404 if the token from the email link is unknown.
+func findAccessRequestByTokenAndHandleErrorIfMissing(w http.ResponseWriter, r *http.Request, tx *sql.Tx) (*accessrequests.Model, *children.Model, error) {
+	accessRequest, err := accessrequests.FindOneByToken(tx, chi.URLParam(r, "token"))
+	if err != nil {
+		respondWith500(w, r, nil)
+		return nil, nil, err
+	}
+
+	if accessRequest == nil {
+		respondWith404(w, r, ErrAccessRequestNotFound)
+		return nil, nil, ErrAccessRequestNotFound
+	}
+
+	child, err := children.FindOneById(tx, accessRequest.ChildId)
+	if err != nil {
+		respondWith500(w, r, nil)
+		return nil, nil, err
+	}
+
+	if child == nil {
+		respondWith404(w, r, ErrAccessRequestNotFound)
+		return nil, nil, ErrAccessRequestNotFound
+	}
+
+	return accessRequest, child, nil
+}
+
+func HttpAccessRequestsApprove(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		minutes, err := strconv.Atoi(r.URL.Query().Get("minutes"))
+		if err != nil || !isOfferedAccessRequestDuration(minutes) {
+			respondWith400(w, r, ErrInvalidDuration)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		accessRequest, child, err := findAccessRequestByTokenAndHandleErrorIfMissing(w, r, tx)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			log.Printf("error occured while trying to find access request: %v", err)
+			return
+		}
+
+		_, parent, err := findChildAndParent(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find parent of the child: %v", err)
+			return
+		}
+
+		// the link is opened by the parent the email was sent to
+		language := recipientLanguage(r, parent, "")
+
+		now := time.Now()
+		expiresAt := now.Add(time.Duration(minutes) * time.Minute)
+
+		err = accessrequests.Approve(tx, accessRequest.Id, now, expiresAt)
+		if errors.Is(err, accessrequests.ErrAccessRequestIsAlreadyDecided) {
+			err = tx.Rollback()
+			if err != nil {
+				log.Printf("failed to rollback the transaction: %v", err)
+			}
+
+			respondWithHtml(w, r, http.StatusConflict, components.AccessRequestAlreadyDecidedPage(language))
+			return
+		} else if errors.Is(err, accessrequests.ErrAccessRequestHasExpired) {
+			err = tx.Rollback()
+			if err != nil {
+				log.Printf("failed to rollback the transaction: %v", err)
+			}
+
+			respondWithHtml(w, r, http.StatusGone, components.AccessRequestExpiredPage(language))
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to approve access request: %v", err)
+			return
+		}
+
+		decidedEvent := AccessRequestDecidedEvent{
+			Id:        accessRequest.Id,
+			Domain:    accessRequest.Domain,
+			Status:    string(accessrequests.StatusApproved),
+			ExpiresAt: &expiresAt,
+		}
+
+		err = enqueueWebhookEvent(tx, child, webhooks.EventAccessRequestDecided, "", WebhookEventData{AccessRequest: &decidedEvent}, now)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to enqueue webhook event: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishEvent(pushHub, accessRequest.ChildId, 0, push.EventTypeAccessRequestDecided, decidedEvent)
+
+		respondWithHtml(w, r, http.StatusOK, components.AccessRequestApprovedPage(language, child.Name, accessRequest.Domain, expiresAt))
+	}
+}
+
+func HttpAccessRequestsDeny(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		accessRequest, child, err := findAccessRequestByTokenAndHandleErrorIfMissing(w, r, tx)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			log.Printf("error occured while trying to find access request: %v", err)
+			return
+		}
+
+		_, parent, err := findChildAndParent(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find parent of the child: %v", err)
+			return
+		}
+
+		// the link is opened by the parent the email was sent to
+		language := recipientLanguage(r, parent, "")
+
+		err = accessrequests.Deny(tx, accessRequest.Id, time.Now())
+		if errors.Is(err, accessrequests.ErrAccessRequestIsAlreadyDecided) {
+			err = tx.Rollback()
+			if err != nil {
+				log.Printf("failed to rollback the transaction: %v", err)
+			}
+
+			respondWithHtml(w, r, http.StatusConflict, components.AccessRequestAlreadyDecidedPage(language))
+			return
+		} else if errors.Is(err, accessrequests.ErrAccessRequestHasExpired) {
+			err = tx.Rollback()
+			if err != nil {
+				log.Printf("failed to rollback the transaction: %v", err)
+			}
+
+			respondWithHtml(w, r, http.StatusGone, components.AccessRequestExpiredPage(language))
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to deny access request: %v", err)
+			return
+		}
+
+		decidedEvent := AccessRequestDecidedEvent{
+			Id:     accessRequest.Id,
+			Domain: accessRequest.Domain,
+			Status: string(accessrequests.StatusDenied),
+		}
+
+		err = enqueueWebhookEvent(tx, child, webhooks.EventAccessRequestDecided, "", WebhookEventData{AccessRequest: &decidedEvent}, time.Now())
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to enqueue webhook event: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishEvent(pushHub, accessRequest.ChildId, 0, push.EventTypeAccessRequestDecided, decidedEvent)
+
+		respondWithHtml(w, r, http.StatusOK, components.AccessRequestDeniedPage(language, child.Name, accessRequest.Domain))
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"mailpitsuite"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/accessrequests"
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/domainrules"
+	"domanscy.group/parental-controls/server/push"
+	"github.com/go-chi/chi"
+)
+
+func prepareBlockPageTestFamily(t *testing.T, db *sql.DB) testFamily {
+	family := createTestFamily(t, db, "parent@localhost.local")
+
+	tx, err := db.Begin()
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, devices.UpdateIpAddress(tx, family.deviceId, "10.0.0.5"))
+	_, err = domainrules.Create(tx, family.childId, "blocked.test", domainrules.ActionBlock)
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, tx.Commit())
+
+	return family
+}
+
+func TestHttpBlockPage(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	prepareBlockPageTestFamily(t, db)
+
+	handler := NewBlockPageServer(*testingCfg, db, nil)
+
+	sendRequest := func(remoteAddr string, acceptLanguage string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "http://www.blocked.test/some/page", nil)
+		request.RemoteAddr = remoteAddr
+		request.Header.Set("Accept-Language", acceptLanguage)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("explains the rule and offers to ask for access", func(t *testing.T) {
+		recorder := sendRequest("10.0.0.5:51789", "en-US,en;q=0.9")
+
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusForbidden)
+		}
+
+		body := recorder.Body.String()
+		for _, expected := range []string{"www.blocked.test", "(rule: blocked.test)", `action="/ask_for_access"`} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("Expected body to contain %s, received:\n%s", expected, body)
+			}
+		}
+	})
+
+	t.Run("is rendered in polish by default", func(t *testing.T) {
+		recorder := sendRequest("10.0.0.5:51789", "")
+
+		if !strings.Contains(recorder.Body.String(), "Poproś o dostęp") {
+			t.Errorf("Expected polish block page, received:\n%s", recorder.Body.String())
+		}
+	})
+
+	t.Run("does not offer to ask for access to unknown devices", func(t *testing.T) {
+		recorder := sendRequest("10.0.0.6:51789", "en")
+
+		if strings.Contains(recorder.Body.String(), "/ask_for_access") {
+			t.Errorf("Expected no ask for access form, received:\n%s", recorder.Body.String())
+		}
+	})
+}
+
+func TestHttpBlockPageAskForAccess(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := prepareBlockPageTestFamily(t, db)
+
+	handler := NewBlockPageServer(*testingCfg, db, nil)
+
+	sendRequest := func(remoteAddr string, domain string) *httptest.ResponseRecorder {
+		form := url.Values{"domain": {domain}}
+
+		request := httptest.NewRequest(http.MethodPost, "http://blocked.test/ask_for_access", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.RemoteAddr = remoteAddr
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("returns 404 for unknown devices", func(t *testing.T) {
+		recorder := sendRequest("10.0.0.6:51789", "blocked.test")
+
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("returns 400 when domain is not blocked", func(t *testing.T) {
+		recorder := sendRequest("10.0.0.5:51789", "allowed.test")
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		if recorder.Body.String() != ErrDomainIsNotBlocked.Error() {
+			t.Errorf("Got %s, want %s", recorder.Body.String(), ErrDomainIsNotBlocked.Error())
+		}
+	})
+
+	t.Run("creates access request and emails the parent once", func(t *testing.T) {
+		mailpit := initializeMailpitAndDeleteAllMessages(t)
+		defer func(mailpit *mailpitsuite.Api) {
+			err := mailpit.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}(mailpit)
+
+		for i := 0; i < 2; i++ {
+			recorder := sendRequest("10.0.0.5:51789", "www.blocked.test")
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+			}
+		}
+
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		accessRequest, err := accessrequests.FindPendingByChildIdAndDomain(tx, family.childId, "www.blocked.test", time.Now())
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		if accessRequest == nil || accessRequest.Rule != "blocked.test" {
+			t.Fatalf("Expected pending access request blocked by blocked.test, received %+v", accessRequest)
+		}
+
+		messages, err := mailpit.GetAllMessages()
+		if err != nil {
+			t.Fatalf("failed to get mailpit messages: %s", err.Error())
+		}
+
+		if len(messages) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(messages))
+		}
+
+		messageSummary, err := mailpit.GetMessageSummary(messages[0].ID)
+		doTFatalIfErr(t, err)
+
+		expectedLink := fmt.Sprintf("%s/access_requests/%s/approve?minutes=60", testingCfg.AppUrl, accessRequest.Token)
+		if !strings.Contains(messageSummary.HTML, expectedLink) {
+			t.Errorf("Expected email to contain %s, received:\n%s", expectedLink, messageSummary.HTML)
+		}
+	})
+}
+
+func TestHttpAccessRequestsApproveAndDeny(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := prepareBlockPageTestFamily(t, db)
+
+	createAccessRequest := func(domain string) string {
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		_, token, err := accessrequests.Create(tx, family.childId, family.deviceId, domain, "blocklist", "blocked.test")
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		return token
+	}
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.Get("/access_requests/{token}/approve", HttpAccessRequestsApprove(testingCfg, pushHub, db))
+	router.Get("/access_requests/{token}/deny", HttpAccessRequestsDeny(testingCfg, pushHub, db))
+
+	sendRequest := func(path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("approves request for the chosen time", func(t *testing.T) {
+		token := createAccessRequest("blocked.test")
+
+		subscription, _ := pushHub.Subscribe(family.childId, family.deviceId, 0)
+		defer subscription.Close()
+
+		recorder := sendRequest(fmt.Sprintf("/access_requests/%s/approve?minutes=60", token))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		accessRequest, err := accessrequests.FindOneByToken(tx, token)
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		if accessRequest.Status != accessrequests.StatusApproved {
+			t.Errorf("Expected status %s, received %s", accessrequests.StatusApproved, accessRequest.Status)
+		}
+
+		if duration := accessRequest.ExpiresAt.Sub(accessRequest.DecidedAt); duration.Minutes() != 60 {
+			t.Errorf("Expected exception for 60 minutes, received %s", duration)
+		}
+
+		select {
+		case event := <-subscription.Events():
+			if event.Type != push.EventTypeAccessRequestDecided || !strings.Contains(string(event.Data), `"status":"approved"`) {
+				t.Errorf("Expected approved access request event, received %+v", event)
+			}
+		default:
+			t.Error("Expected access request decided event to be published")
+		}
+
+		recorder = sendRequest(fmt.Sprintf("/access_requests/%s/deny", token))
+		if recorder.Code != http.StatusConflict {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("denies request", func(t *testing.T) {
+		token := createAccessRequest("other.blocked.test")
+
+		recorder := sendRequest(fmt.Sprintf("/access_requests/%s/deny", token))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		recorder = sendRequest(fmt.Sprintf("/access_requests/%s/approve?minutes=30", token))
+		if recorder.Code != http.StatusConflict {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("returns 400 for duration that was not offered", func(t *testing.T) {
+		token := createAccessRequest("third.blocked.test")
+
+		recorder := sendRequest(fmt.Sprintf("/access_requests/%s/approve?minutes=100000", token))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("returns 410 for requests older than their ttl", func(t *testing.T) {
+		token := createAccessRequest("stale.blocked.test")
+
+		_, err := db.Exec("UPDATE access_requests SET created_at = ? WHERE token = ?", time.Now().Add(-accessrequests.PendingTtl-time.Minute).UTC(), token)
+		doTFatalIfErr(t, err)
+
+		for _, path := range []string{"/access_requests/%s/approve?minutes=30", "/access_requests/%s/deny"} {
+			recorder := sendRequest(fmt.Sprintf(path, token))
+			if recorder.Code != http.StatusGone {
+				t.Errorf("%s: got %d, want %d", path, recorder.Code, http.StatusGone)
+			}
+		}
+	})
+
+	t.Run("returns 404 for unknown token", func(t *testing.T) {
+		recorder := sendRequest("/access_requests/unknown/deny")
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+}
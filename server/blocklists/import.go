@@ -0,0 +1,176 @@
+package blocklists
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"domanscy.group/littlehelpers"
+)
+
+type ImportResult struct {
+	Name        string
+	DomainCount int
+	Skipped     int
+}
+
+// Import reads the list from the source file and stores it under the given name,
+// replacing every domain imported under that name before.
+func Import(db *sql.Tx, name string, category Category, format Format, source string) (ImportResult, error) {
+	if name == "" {
+		return ImportResult{}, ErrNameCannotBeEmpty
+	}
+
+	if !category.IsValid() {
+		return ImportResult{}, ErrInvalidCategory
+	}
+
+	if !format.IsValid() {
+		return ImportResult{}, ErrInvalidFormat
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to open blocklist file '%s': %w", source, err)
+	}
+
+	parsed, err := Parse(file, format)
+	if err != nil {
+		return ImportResult{}, littlehelpers.IfErrJoin(err, file.Close())
+	}
+
+	err = file.Close()
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to close blocklist file '%s': %w", source, err)
+	}
+
+	blocklistId, err := upsertBlocklist(db, name, category, format, source)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	_, err = db.Exec("DELETE FROM blocklist_domains WHERE blocklist_id = ?", blocklistId)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("an error occured while trying to execute query 'DELETE FROM blocklist_domains ...': %w", err)
+	}
+
+	domainCount, err := insertDomains(db, blocklistId, parsed.Domains)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	_, err = db.Exec("UPDATE blocklists SET domain_count = ?, updated_at = ? WHERE id = ?", domainCount, time.Now().UTC(), blocklistId)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("an error occured while trying to execute query 'UPDATE blocklists ...': %w", err)
+	}
+
+	return ImportResult{Name: name, DomainCount: domainCount, Skipped: parsed.Skipped}, nil
+}
+
+func upsertBlocklist(db *sql.Tx, name string, category Category, format Format, source string) (int, error) {
+	existing, err := FindOneByName(db, name)
+	if err != nil {
+		return 0, err
+	}
+
+	if existing != nil {
+		_, err = db.Exec("UPDATE blocklists SET category = ?, format = ?, source = ? WHERE id = ?", category, format, source, existing.Id)
+		if err != nil {
+			return 0, fmt.Errorf("an error occured while trying to execute query 'UPDATE blocklists ...': %w", err)
+		}
+
+		return existing.Id, nil
+	}
+
+	exec, err := db.Exec("INSERT INTO blocklists (name, category, format, source) VALUES (?, ?, ?, ?)", name, category, format, source)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO blocklists ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+func insertDomains(db *sql.Tx, blocklistId int, domains []string) (int, error) {
+	statement, err := db.Prepare("INSERT OR IGNORE INTO blocklist_domains (blocklist_id, domain) VALUES (?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare query 'INSERT INTO blocklist_domains ...': %w", err)
+	}
+
+	inserted := 0
+
+	for _, domain := range domains {
+		executed, err := statement.Exec(blocklistId, domain)
+		if err != nil {
+			return 0, littlehelpers.IfErrJoin(fmt.Errorf("an error occured while trying to insert domain '%s': %w", domain, err), statement.Close())
+		}
+
+		affectedRows, err := executed.RowsAffected()
+		if err != nil {
+			return 0, littlehelpers.IfErrJoin(fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err), statement.Close())
+		}
+
+		inserted += int(affectedRows)
+	}
+
+	err = statement.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to close prepared statement: %w", err)
+	}
+
+	return inserted, nil
+}
+
+// RefreshAll imports every known list again from its source file, each list in its own transaction,
+// so one missing file does not prevent the others from being refreshed.
+func RefreshAll(db *sql.DB) ([]ImportResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	blocklists, err := FindAll(tx)
+	if err != nil {
+		return nil, littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit the transaction: %w", err)
+	}
+
+	results := make([]ImportResult, 0, len(blocklists))
+	var refreshErrors []error
+
+	for _, blocklist := range blocklists {
+		tx, err := db.Begin()
+		if err != nil {
+			return results, fmt.Errorf("failed to open database transaction: %w", err)
+		}
+
+		result, err := Import(tx, blocklist.Name, blocklist.Category, blocklist.Format, blocklist.Source)
+		if err != nil {
+			refreshErrors = append(refreshErrors, littlehelpers.IfErrJoin(fmt.Errorf("failed to refresh blocklist '%s': %w", blocklist.Name, err), tx.Rollback()))
+			continue
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			refreshErrors = append(refreshErrors, fmt.Errorf("failed to commit refreshed blocklist '%s': %w", blocklist.Name, err))
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	if len(refreshErrors) > 0 {
+		return results, littlehelpers.IfErrJoin(refreshErrors[0], refreshErrors[1:]...)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,146 @@
+package blocklists
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"domanscy.group/parental-controls/server/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0008_blocklists": MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func writeListFile(t *testing.T, path string, content string) {
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func importList(t *testing.T, db *sql.DB, name string, category Category, format Format, source string) ImportResult {
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Import(tx, name, category, format, source)
+	if err != nil {
+		_ = tx.Rollback()
+		t.Fatal(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestImportAndMatch(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	gamblingList := filepath.Join(t.TempDir(), "gambling.txt")
+	writeListFile(t, gamblingList, "0.0.0.0 casino.test\n0.0.0.0 casino.test\n0.0.0.0 poker.test\n")
+
+	result := importList(t, db, "gambling", CategoryGambling, FormatHosts, gamblingList)
+	if result.DomainCount != 2 {
+		t.Errorf("Expected 2 imported domains, received %d", result.DomainCount)
+	}
+
+	matcher := NewMatcher()
+
+	err := matcher.Load(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("matches only blocked categories", func(t *testing.T) {
+		match, matched := matcher.Match("www.casino.test", []Category{CategoryAdult, CategoryGambling})
+		if !matched || match.Category != CategoryGambling || match.Domain != "casino.test" {
+			t.Errorf("Expected match of casino.test in gambling, received %+v (%t)", match, matched)
+		}
+
+		_, matched = matcher.Match("www.casino.test", []Category{CategoryAdult})
+		if matched {
+			t.Error("Expected no match for categories without gambling")
+		}
+	})
+
+	t.Run("reloads after the list is imported again", func(t *testing.T) {
+		reloaded, err := matcher.ReloadIfChanged(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reloaded {
+			t.Error("Expected no reload without changes")
+		}
+
+		writeListFile(t, gamblingList, "0.0.0.0 bets.test\n")
+		importList(t, db, "gambling", CategoryGambling, FormatHosts, gamblingList)
+
+		reloaded, err = matcher.ReloadIfChanged(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reloaded {
+			t.Fatal("Expected reload after the import")
+		}
+
+		if _, matched := matcher.Match("casino.test", []Category{CategoryGambling}); matched {
+			t.Error("Expected casino.test to be gone after the import replaced the list")
+		}
+
+		if _, matched := matcher.Match("bets.test", []Category{CategoryGambling}); !matched {
+			t.Error("Expected bets.test to be matched after reload")
+		}
+	})
+
+	t.Run("refreshes all lists from their sources", func(t *testing.T) {
+		writeListFile(t, gamblingList, "0.0.0.0 bets.test\n0.0.0.0 slots.test\n")
+
+		results, err := RefreshAll(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(results) != 1 || results[0].DomainCount != 2 {
+			t.Errorf("Expected one refreshed list with 2 domains, received %+v", results)
+		}
+	})
+
+	t.Run("returns error for invalid category", func(t *testing.T) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer tx.Rollback()
+
+		_, err = Import(tx, "homework", Category("homework"), FormatHosts, gamblingList)
+		if err != ErrInvalidCategory {
+			t.Errorf("Expected %v, received %v", ErrInvalidCategory, err)
+		}
+	})
+}
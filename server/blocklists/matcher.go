@@ -0,0 +1,147 @@
+package blocklists
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+type Match struct {
+	Category Category
+	// Domain is the blocklist entry that matched, the queried domain itself or one of its parents.
+	Domain string
+}
+
+// Matcher keeps every imported domain in memory, one suffix trie per category.
+// It is safe for concurrent use, Load swaps the tries only after they are fully built.
+type Matcher struct {
+	mutex    sync.RWMutex
+	tries    map[Category]*SuffixTrie
+	revision string
+}
+
+func NewMatcher() *Matcher {
+	return &Matcher{tries: map[Category]*SuffixTrie{}}
+}
+
+// Match checks the domain against the lists of the given categories, in the order of categories.
+func (matcher *Matcher) Match(domain string, categories []Category) (Match, bool) {
+	matcher.mutex.RLock()
+	defer matcher.mutex.RUnlock()
+
+	for _, category := range categories {
+		trie, found := matcher.tries[category]
+		if !found {
+			continue
+		}
+
+		if entry, matched := trie.Match(domain); matched {
+			return Match{Category: category, Domain: entry}, true
+		}
+	}
+
+	return Match{}, false
+}
+
+// revisionOf changes whenever a list is imported or refreshed, a re-import replaces all entries of the list.
+func revisionOf(db *sql.DB) (string, error) {
+	var revision string
+
+	err := db.QueryRow("SELECT COUNT(*) || ':' || COALESCE(MAX(updated_at), '') FROM blocklists").Scan(&revision)
+	if err != nil {
+		return "", fmt.Errorf("failed to read blocklists revision: %w", err)
+	}
+
+	return revision, nil
+}
+
+// Load reads all imported domains from the database.
+func (matcher *Matcher) Load(db *sql.DB) error {
+	revision, err := revisionOf(db)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query("SELECT blocklists.category, blocklist_domains.domain FROM blocklist_domains INNER JOIN blocklists ON blocklists.id = blocklist_domains.blocklist_id")
+	if err != nil {
+		return fmt.Errorf("failed to execute query 'SELECT ... FROM blocklist_domains ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	tries := map[Category]*SuffixTrie{}
+
+	for rows.Next() {
+		var category Category
+		var domain string
+
+		err := rows.Scan(&category, &domain)
+		if err != nil {
+			return fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		trie, found := tries[category]
+		if !found {
+			trie = NewSuffixTrie()
+			tries[category] = trie
+		}
+
+		trie.Insert(domain)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to read blocklist domains: %w", err)
+	}
+
+	matcher.mutex.Lock()
+	matcher.tries = tries
+	matcher.revision = revision
+	matcher.mutex.Unlock()
+
+	return nil
+}
+
+// ReloadIfChanged loads the lists again if anything was imported since the last load, e.g. by the cli.
+func (matcher *Matcher) ReloadIfChanged(db *sql.DB) (bool, error) {
+	revision, err := revisionOf(db)
+	if err != nil {
+		return false, err
+	}
+
+	matcher.mutex.RLock()
+	unchanged := revision == matcher.revision
+	matcher.mutex.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	return true, matcher.Load(db)
+}
+
+// WatchForChanges calls ReloadIfChanged every interval until ctx is done.
+// Reload errors are only logged, the previously loaded lists stay in use.
+func (matcher *Matcher) WatchForChanges(ctx context.Context, db *sql.DB, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+			reloaded, err := matcher.ReloadIfChanged(db)
+			if err != nil {
+				log.Printf("failed to reload blocklists: %v", err)
+			} else if reloaded {
+				log.Println("blocklists have been reloaded")
+			}
+		}
+	}
+}
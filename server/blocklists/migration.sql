@@ -0,0 +1,22 @@
+CREATE TABLE blocklists (
+    id INTEGER PRIMARY KEY,
+    name VARCHAR NOT NULL UNIQUE,
+    category VARCHAR NOT NULL,
+    format VARCHAR NOT NULL,
+    source VARCHAR NOT NULL,
+    domain_count INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE blocklist_domains (
+    blocklist_id INTEGER NOT NULL REFERENCES blocklists (id) ON DELETE CASCADE,
+    domain VARCHAR NOT NULL,
+    PRIMARY KEY (blocklist_id, domain)
+) WITHOUT ROWID;
+
+CREATE TABLE child_blocked_categories (
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    category VARCHAR NOT NULL,
+    PRIMARY KEY (child_id, category)
+);
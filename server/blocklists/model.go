@@ -0,0 +1,161 @@
+package blocklists
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrInvalidCategory = errors.New("invalid category")
+var ErrNameCannotBeEmpty = errors.New("blocklist name can not be empty")
+
+type Category string
+
+const (
+	CategoryAdult       Category = "adult"
+	CategoryGambling    Category = "gambling"
+	CategorySocialMedia Category = "social_media"
+	CategoryGaming      Category = "gaming"
+	CategoryMalware     Category = "malware"
+	CategoryAds         Category = "ads"
+)
+
+var Categories = []Category{
+	CategoryAdult,
+	CategoryGambling,
+	CategorySocialMedia,
+	CategoryGaming,
+	CategoryMalware,
+	CategoryAds,
+}
+
+func (category Category) IsValid() bool {
+	for _, known := range Categories {
+		if category == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Model struct {
+	Id       int
+	Name     string
+	Category Category
+	Format   Format
+	// Source is the path of the local file the list is (re)imported from.
+	Source      string
+	DomainCount int
+	UpdatedAt   time.Time
+	CreatedAt   time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+const selectColumns = "id, name, category, format, source, domain_count, updated_at, created_at"
+
+func scanBlocklist(row interface{ Scan(dest ...any) error }) (*Model, error) {
+	blocklist := &Model{}
+
+	err := row.Scan(&blocklist.Id, &blocklist.Name, &blocklist.Category, &blocklist.Format, &blocklist.Source, &blocklist.DomainCount, &blocklist.UpdatedAt, &blocklist.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return blocklist, nil
+}
+
+func FindOneByName(db *sql.Tx, name string) (*Model, error) {
+	blocklist, err := scanBlocklist(db.QueryRow("SELECT "+selectColumns+" FROM blocklists WHERE name = $1", name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return blocklist, nil
+}
+
+func FindAll(db *sql.Tx) ([]Model, error) {
+	rows, err := db.Query("SELECT " + selectColumns + " FROM blocklists ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM blocklists ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	blocklists := make([]Model, 0)
+
+	for rows.Next() {
+		blocklist, err := scanBlocklist(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		blocklists = append(blocklists, *blocklist)
+	}
+
+	return blocklists, nil
+}
+
+func FindBlockedCategoriesByChildId(db *sql.Tx, childId int) ([]Category, error) {
+	rows, err := db.Query("SELECT category FROM child_blocked_categories WHERE child_id = $1 ORDER BY category", childId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM child_blocked_categories ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	categories := make([]Category, 0)
+
+	for rows.Next() {
+		var category Category
+
+		err := rows.Scan(&category)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		categories = append(categories, category)
+	}
+
+	return categories, nil
+}
+
+// UpdateBlockedCategoriesOfChild replaces the categories chosen for the child.
+func UpdateBlockedCategoriesOfChild(db *sql.Tx, childId int, categories []Category) error {
+	for _, category := range categories {
+		if !category.IsValid() {
+			return ErrInvalidCategory
+		}
+	}
+
+	_, err := db.Exec("DELETE FROM child_blocked_categories WHERE child_id = ?", childId)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'DELETE FROM child_blocked_categories ...': %w", err)
+	}
+
+	for _, category := range categories {
+		_, err = db.Exec("INSERT OR IGNORE INTO child_blocked_categories (child_id, category) VALUES (?, ?)", childId, category)
+		if err != nil {
+			return fmt.Errorf("an error occured while trying to execute query 'INSERT INTO child_blocked_categories ...': %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package blocklists
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"domanscy.group/parental-controls/server/domainrules"
+)
+
+var ErrInvalidFormat = errors.New("invalid blocklist format")
+
+type Format string
+
+const (
+	// FormatHosts is the /etc/hosts format, e.g. "0.0.0.0 example.com", used by lists like StevenBlack/hosts.
+	FormatHosts Format = "hosts"
+	// FormatAdblock only understands rules blocking whole domains, e.g. "||example.com^".
+	FormatAdblock Format = "adblock"
+	// FormatDomains is one domain per line.
+	FormatDomains Format = "domains"
+)
+
+func (format Format) IsValid() bool {
+	return format == FormatHosts || format == FormatAdblock || format == FormatDomains
+}
+
+// hostsFileBuiltins are entries of every hosts file that must never end up on a blocklist.
+var hostsFileBuiltins = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+	"0.0.0.0":               true,
+}
+
+// adblockIgnoredOptions do not change which domain a rule blocks, rules with any other option are skipped.
+var adblockIgnoredOptions = map[string]bool{
+	"important":   true,
+	"all":         true,
+	"document":    true,
+	"doc":         true,
+	"third-party": true,
+	"3p":          true,
+}
+
+type ParseResult struct {
+	Domains []string
+	// Skipped counts lines that looked like rules but could not be turned into a domain.
+	Skipped int
+}
+
+func Parse(reader io.Reader, format Format) (ParseResult, error) {
+	if !format.IsValid() {
+		return ParseResult{}, ErrInvalidFormat
+	}
+
+	result := ParseResult{Domains: make([]string, 0)}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var candidates []string
+
+		line := strings.TrimSpace(scanner.Text())
+
+		switch format {
+		case FormatHosts:
+			candidates = parseHostsLine(line)
+		case FormatAdblock:
+			candidates = parseAdblockLine(line, &result.Skipped)
+		case FormatDomains:
+			candidates = parseDomainsLine(line)
+		}
+
+		for _, candidate := range candidates {
+			domain, err := domainrules.NormalizeDomain(candidate)
+			if err != nil {
+				result.Skipped++
+				continue
+			}
+
+			result.Domains = append(result.Domains, domain)
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return ParseResult{}, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+
+	return result, nil
+}
+
+func stripComment(line string, commentPrefix string) string {
+	if index := strings.Index(line, commentPrefix); index != -1 {
+		line = line[:index]
+	}
+
+	return strings.TrimSpace(line)
+}
+
+func parseHostsLine(line string) []string {
+	fields := strings.Fields(stripComment(line, "#"))
+	if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+		return nil
+	}
+
+	domains := make([]string, 0, len(fields)-1)
+
+	for _, field := range fields[1:] {
+		if hostsFileBuiltins[strings.ToLower(field)] {
+			continue
+		}
+
+		domains = append(domains, field)
+	}
+
+	return domains
+}
+
+func parseAdblockLine(line string, skipped *int) []string {
+	// comments, list metadata and exception rules
+	if line == "" || line[0] == '!' || line[0] == '[' || strings.HasPrefix(line, "@@") {
+		return nil
+	}
+
+	if !strings.HasPrefix(line, "||") {
+		// cosmetic and url rules can not be enforced with dns
+		*skipped++
+		return nil
+	}
+
+	rule, options, _ := strings.Cut(line[2:], "$")
+
+	domain, found := strings.CutSuffix(rule, "^")
+	if !found && strings.ContainsAny(rule, "/*^|") {
+		*skipped++
+		return nil
+	}
+
+	if options != "" {
+		for _, option := range strings.Split(options, ",") {
+			if !adblockIgnoredOptions[strings.TrimSpace(option)] {
+				*skipped++
+				return nil
+			}
+		}
+	}
+
+	return []string{domain}
+}
+
+func parseDomainsLine(line string) []string {
+	line = stripComment(line, "#")
+	if line == "" {
+		return nil
+	}
+
+	return []string{line}
+}
@@ -0,0 +1,91 @@
+package blocklists
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("parses hosts files", func(t *testing.T) {
+		list := `# StevenBlack style hosts file
+127.0.0.1 localhost
+::1 ip6-localhost ip6-loopback
+0.0.0.0 0.0.0.0
+0.0.0.0 Casino.Test # trailing comment
+0.0.0.0 poker.test bets.test
+
+not-an-ip something.test
+0.0.0.0 invalid_domain!
+`
+
+		result, err := Parse(strings.NewReader(list), FormatHosts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{"casino.test", "poker.test", "bets.test"}
+		if !slices.Equal(result.Domains, expected) {
+			t.Errorf("Expected %v, received %v", expected, result.Domains)
+		}
+
+		if result.Skipped != 1 {
+			t.Errorf("Expected 1 skipped line, received %d", result.Skipped)
+		}
+	})
+
+	t.Run("parses domain rules of adblock lists", func(t *testing.T) {
+		list := `[Adblock Plus 2.0]
+! Title: test list
+||ads.test^
+||tracker.test^$third-party
+||images.test^$image
+@@||allowed.test^
+##.banner
+/ads/*.js
+||cdn.test/ads/*
+`
+
+		result, err := Parse(strings.NewReader(list), FormatAdblock)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{"ads.test", "tracker.test"}
+		if !slices.Equal(result.Domains, expected) {
+			t.Errorf("Expected %v, received %v", expected, result.Domains)
+		}
+
+		if result.Skipped != 4 {
+			t.Errorf("Expected 4 skipped lines, received %d", result.Skipped)
+		}
+	})
+
+	t.Run("parses plain domain lists", func(t *testing.T) {
+		list := `# one domain per line
+social.test
+*.games.test
+
+chat.test # comment
+`
+
+		result, err := Parse(strings.NewReader(list), FormatDomains)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{"social.test", "games.test", "chat.test"}
+		if !slices.Equal(result.Domains, expected) {
+			t.Errorf("Expected %v, received %v", expected, result.Domains)
+		}
+	})
+
+	t.Run("returns error for unknown format", func(t *testing.T) {
+		_, err := Parse(strings.NewReader(""), Format("csv"))
+
+		if !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("Expected %v, received %v", ErrInvalidFormat, err)
+		}
+	})
+}
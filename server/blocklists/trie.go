@@ -0,0 +1,92 @@
+package blocklists
+
+import "strings"
+
+type trieNode struct {
+	children map[string]*trieNode
+	terminal bool
+}
+
+// SuffixTrie stores domains by their labels in reverse order (com -> example -> www),
+// so checking a domain against millions of entries costs as many map lookups as the domain has labels.
+// An entry matches the domain itself and all of its subdomains.
+type SuffixTrie struct {
+	root *trieNode
+	size int
+}
+
+func NewSuffixTrie() *SuffixTrie {
+	return &SuffixTrie{root: &trieNode{}}
+}
+
+// Insert expects a normalized domain, see domainrules.NormalizeDomain.
+func (trie *SuffixTrie) Insert(domain string) {
+	node := trie.root
+
+	for domain != "" {
+		var label string
+
+		dot := strings.LastIndexByte(domain, '.')
+		if dot == -1 {
+			label, domain = domain, ""
+		} else {
+			label, domain = domain[dot+1:], domain[:dot]
+		}
+
+		if node.children == nil {
+			node.children = make(map[string]*trieNode, 1)
+		}
+
+		child, found := node.children[label]
+		if !found {
+			child = &trieNode{}
+			node.children[label] = child
+		}
+
+		node = child
+	}
+
+	if !node.terminal {
+		node.terminal = true
+		trie.size++
+	}
+}
+
+// Match returns the shortest entry that is equal to the domain or is one of its parent domains.
+func (trie *SuffixTrie) Match(domain string) (string, bool) {
+	node := trie.root
+	rest := domain
+
+	for rest != "" {
+		var label string
+
+		dot := strings.LastIndexByte(rest, '.')
+		if dot == -1 {
+			label, rest = rest, ""
+		} else {
+			label, rest = rest[dot+1:], rest[:dot]
+		}
+
+		child, found := node.children[label]
+		if !found {
+			return "", false
+		}
+
+		if child.terminal {
+			if rest == "" {
+				return domain, true
+			}
+
+			return domain[len(rest)+1:], true
+		}
+
+		node = child
+	}
+
+	return "", false
+}
+
+// Len returns the number of distinct entries.
+func (trie *SuffixTrie) Len() int {
+	return trie.size
+}
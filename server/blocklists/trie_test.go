@@ -0,0 +1,39 @@
+package blocklists
+
+import "testing"
+
+func TestSuffixTrie(t *testing.T) {
+	trie := NewSuffixTrie()
+	trie.Insert("casino.test")
+	trie.Insert("ads.example.test")
+	trie.Insert("ads.example.test")
+	trie.Insert("deep.casino.test")
+
+	if trie.Len() != 3 {
+		t.Errorf("Expected 3 entries, received %d", trie.Len())
+	}
+
+	tests := []struct {
+		domain  string
+		matched bool
+		entry   string
+	}{
+		{"casino.test", true, "casino.test"},
+		{"www.casino.test", true, "casino.test"},
+		{"deep.casino.test", true, "casino.test"},
+		{"tracker.ads.example.test", true, "ads.example.test"},
+		{"example.test", false, ""},
+		{"notcasino.test", false, ""},
+		{"test", false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.domain, func(t *testing.T) {
+			entry, matched := trie.Match(test.domain)
+
+			if matched != test.matched || entry != test.entry {
+				t.Errorf("Expected (%s, %t), received (%s, %t)", test.entry, test.matched, entry, matched)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxIcsLineLength is the length lines are folded at, in bytes, as RFC 5545 asks.
+const MaxIcsLineLength = 75
+
+var ErrInvalidIcs = errors.New("invalid iCalendar file")
+
+const icsDateFormat = "20060102"
+const icsDateTimeFormat = "20060102T150405"
+
+// Event is the part of an iCalendar event an entry is made from.
+type Event struct {
+	Uid     string
+	Summary string
+	// StartDate and EndDate are both inclusive, unlike DTEND.
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// unfoldIcsLines joins the lines RFC 5545 folded, a line starting with a space or a tab continues the previous one.
+func unfoldIcsLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1024*1024)
+
+	lines := make([]string, 0)
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+func parseIcsProperty(line string) (icsProperty, error) {
+	inQuotes := false
+	colon := -1
+
+	for i, char := range line {
+		if char == '"' {
+			inQuotes = !inQuotes
+		} else if char == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+
+	if colon < 0 {
+		return icsProperty{}, fmt.Errorf("%w: line without a value: %q", ErrInvalidIcs, line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	property := icsProperty{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[colon+1:]}
+
+	for _, param := range parts[1:] {
+		name, value, _ := strings.Cut(param, "=")
+		property.params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+	}
+
+	return property, nil
+}
+
+func unescapeIcsText(value string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(value)
+}
+
+func escapeIcsText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(value)
+}
+
+// parseIcsDate returns the day the date or date-time value falls on, date-times without a zone are in location,
+// as are the ones in UTC or a zone this system does not know.
+func parseIcsDate(property icsProperty, location *time.Location) (time.Time, bool, error) {
+	if property.params["VALUE"] == "DATE" || len(property.value) == len(icsDateFormat) {
+		date, err := time.Parse(icsDateFormat, property.value)
+		return date, true, err
+	}
+
+	zone := location
+	if tzid, ok := property.params["TZID"]; ok {
+		if loaded, err := time.LoadLocation(tzid); err == nil {
+			zone = loaded
+		}
+	}
+
+	value := property.value
+	if strings.HasSuffix(value, "Z") {
+		value = strings.TrimSuffix(value, "Z")
+		zone = time.UTC
+	}
+
+	dateTime, err := time.ParseInLocation(icsDateTimeFormat, value, zone)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return Date(dateTime.In(location)), false, nil
+}
+
+// ParseIcs reads the events of an iCalendar file. Recurring and cancelled events are skipped, as are the ones
+// without a start, skipped tells how many there were. Date-times count for the whole day they fall on in location.
+func ParseIcs(r io.Reader, location *time.Location) (events []Event, skipped int, err error) {
+	lines, err := unfoldIcsLines(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrInvalidIcs, err)
+	}
+
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, 0, fmt.Errorf("%w: it does not start with BEGIN:VCALENDAR", ErrInvalidIcs)
+	}
+
+	events = make([]Event, 0)
+
+	var event *Event
+	var endsExclusive, recurring, cancelled bool
+	// nested components of an event, e.g. VALARM, have properties of their own
+	depth := 0
+
+	for _, line := range lines {
+		property, err := parseIcsProperty(line)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		value := strings.ToUpper(property.value)
+
+		switch {
+		case property.name == "BEGIN" && value == "VEVENT" && event == nil:
+			event = &Event{}
+			endsExclusive, recurring, cancelled = false, false, false
+			depth = 0
+		case event == nil:
+			continue
+		case property.name == "BEGIN":
+			depth++
+		case property.name == "END" && depth > 0:
+			depth--
+		case property.name == "END" && value == "VEVENT":
+			if recurring || cancelled || event.StartDate.IsZero() {
+				skipped++
+			} else {
+				if event.EndDate.IsZero() {
+					event.EndDate = event.StartDate
+				} else if endsExclusive && event.EndDate.After(event.StartDate) {
+					event.EndDate = event.EndDate.AddDate(0, 0, -1)
+				}
+
+				// without a uid re-importing the file would add the event again
+				if event.Uid == "" {
+					event.Uid = event.StartDate.Format(icsDateFormat) + "-" + event.Summary
+				}
+
+				events = append(events, *event)
+			}
+
+			event = nil
+		case depth > 0:
+			continue
+		case property.name == "UID":
+			event.Uid = property.value
+		case property.name == "SUMMARY":
+			event.Summary = unescapeIcsText(property.value)
+		case property.name == "STATUS":
+			cancelled = value == "CANCELLED"
+		case property.name == "RRULE" || property.name == "RDATE":
+			recurring = true
+		case property.name == "DTSTART":
+			event.StartDate, _, err = parseIcsDate(property, location)
+			if err != nil {
+				return nil, 0, fmt.Errorf("%w: invalid DTSTART %q", ErrInvalidIcs, property.value)
+			}
+		case property.name == "DTEND":
+			var isDate bool
+
+			event.EndDate, isDate, err = parseIcsDate(property, location)
+			if err != nil {
+				return nil, 0, fmt.Errorf("%w: invalid DTEND %q", ErrInvalidIcs, property.value)
+			}
+
+			// DTEND of all day events is the day after the last one, a date-time ending at midnight ends the day before too
+			endsExclusive = isDate || strings.HasSuffix(strings.TrimSuffix(property.value, "Z"), "T000000")
+		}
+	}
+
+	if event != nil {
+		return nil, 0, fmt.Errorf("%w: an event does not end", ErrInvalidIcs)
+	}
+
+	return events, skipped, nil
+}
+
+type icsWriter struct {
+	w   io.Writer
+	err error
+}
+
+// writeLine folds the line at MaxIcsLineLength bytes without splitting characters.
+func (writer *icsWriter) writeLine(line string) {
+	if writer.err != nil {
+		return
+	}
+
+	var folded strings.Builder
+
+	limit := MaxIcsLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		folded.WriteString(line[:cut])
+		folded.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space counts too
+		limit = MaxIcsLineLength - 1
+	}
+
+	folded.WriteString(line)
+	folded.WriteString("\r\n")
+
+	_, writer.err = io.WriteString(writer.w, folded.String())
+}
+
+// WriteIcs writes the entries as all day events, describe tells what an entry changes in the language of the reader.
+func WriteIcs(w io.Writer, calendarName string, host string, entries []Entry, describe func(entry Entry) string) error {
+	writer := &icsWriter{w: w}
+
+	writer.writeLine("BEGIN:VCALENDAR")
+	writer.writeLine("VERSION:2.0")
+	writer.writeLine("PRODID:-//domanscy.group//parental-controls//PL")
+	writer.writeLine("CALSCALE:GREGORIAN")
+	writer.writeLine("X-WR-CALNAME:" + escapeIcsText(calendarName))
+
+	for _, entry := range entries {
+		uid := entry.Uid
+		if uid == "" {
+			uid = "calendar-entry-" + strconv.Itoa(entry.Id) + "@" + host
+		}
+
+		writer.writeLine("BEGIN:VEVENT")
+		writer.writeLine("UID:" + escapeIcsText(uid))
+		writer.writeLine("DTSTAMP:" + entry.CreatedAt.UTC().Format(icsDateTimeFormat) + "Z")
+		writer.writeLine("DTSTART;VALUE=DATE:" + entry.StartDate.Format(icsDateFormat))
+		writer.writeLine("DTEND;VALUE=DATE:" + entry.EndDate.AddDate(0, 0, 1).Format(icsDateFormat))
+		writer.writeLine("SUMMARY:" + escapeIcsText(entry.Name))
+		writer.writeLine("DESCRIPTION:" + escapeIcsText(describe(entry)))
+		writer.writeLine("TRANSP:TRANSPARENT")
+		writer.writeLine("END:VEVENT")
+	}
+
+	writer.writeLine("END:VCALENDAR")
+
+	return writer.err
+}
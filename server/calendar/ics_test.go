@@ -0,0 +1,157 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const holidaysIcs = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Example//Holidays//PL\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Europe/Warsaw\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:new-year@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20240101\r\n" +
+	"DTEND;VALUE=DATE:20240102\r\n" +
+	"SUMMARY:Nowy Rok\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:winter-break@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20240129\r\n" +
+	"DTEND;VALUE=DATE:20240212\r\n" +
+	"SUMMARY:Ferie zimowe\\, województwo\r\n" +
+	"  mazowieckie\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"SUMMARY:Przypomnienie\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:dentist@example.com\r\n" +
+	"DTSTART;TZID=Europe/Warsaw:20240305T090000\r\n" +
+	"DTEND;TZID=Europe/Warsaw:20240305T100000\r\n" +
+	"SUMMARY:Dentysta\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:20240309T233000Z\r\n" +
+	"SUMMARY:Nocowanie\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:weekly@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20240105\r\n" +
+	"RRULE:FREQ=WEEKLY\r\n" +
+	"SUMMARY:Basen\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20240110\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"SUMMARY:Wycieczka\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseIcs(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, skipped, err := ParseIcs(strings.NewReader(holidaysIcs), warsaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		uid       string
+		summary   string
+		startDate string
+		endDate   string
+	}{
+		{"new-year@example.com", "Nowy Rok", "2024-01-01", "2024-01-01"},
+		{"winter-break@example.com", "Ferie zimowe, województwo mazowieckie", "2024-01-29", "2024-02-11"},
+		{"dentist@example.com", "Dentysta", "2024-03-05", "2024-03-05"},
+		// 23:30 UTC is already the next day in Warsaw
+		{"20240310-Nocowanie", "Nocowanie", "2024-03-10", "2024-03-10"},
+	}
+
+	if len(events) != len(want) || skipped != 2 {
+		t.Fatalf("Expected %d events and 2 skipped, received %+v and %d skipped", len(want), events, skipped)
+	}
+
+	for i, event := range events {
+		if event.Uid != want[i].uid || event.Summary != want[i].summary || event.StartDate.Format(time.DateOnly) != want[i].startDate || event.EndDate.Format(time.DateOnly) != want[i].endDate {
+			t.Errorf("Expected %+v, received %+v", want[i], event)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"BEGIN:VEVENT\r\nEND:VEVENT\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:yesterday\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20240101\r\n",
+	}
+
+	for _, ics := range invalid {
+		_, _, err := ParseIcs(strings.NewReader(ics), warsaw)
+		if !errors.Is(err, ErrInvalidIcs) {
+			t.Errorf("%q: expected %v, received %v", ics, ErrInvalidIcs, err)
+		}
+	}
+}
+
+func TestWriteIcs(t *testing.T) {
+	entries := []Entry{
+		{
+			Id:                     1,
+			Name:                   "Ferie zimowe; cały tydzień u babci w Zakopanem, bez komputera i bez telefonu",
+			StartDate:              time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC),
+			EndDate:                time.Date(2024, 2, 11, 0, 0, 0, 0, time.UTC),
+			ChildIds:               []int{},
+			DailyScreenTimeMinutes: 240,
+			CreatedAt:              time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			Id:        2,
+			Uid:       "sick@example.com",
+			Name:      "Chorobowe",
+			StartDate: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+			ChildIds:  []int{7},
+			CreatedAt: time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC),
+		},
+	}
+
+	var encoded strings.Builder
+
+	err := WriteIcs(&encoded, "Dom", "example.com", entries, func(entry Entry) string {
+		return fmt.Sprintf("%d; %d min", len(entry.ChildIds), entry.DailyScreenTimeMinutes)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(encoded.String(), "\r\n"), "\r\n") {
+		if len(line) > MaxIcsLineLength {
+			t.Errorf("Expected lines of at most %d bytes, received %q", MaxIcsLineLength, line)
+		}
+	}
+
+	for _, want := range []string{"UID:calendar-entry-1@example.com", "DTEND;VALUE=DATE:20240212", "DESCRIPTION:0\\; 240 min", "DESCRIPTION:1\\; 0 min"} {
+		if !strings.Contains(encoded.String(), want) {
+			t.Errorf("Expected %q in %s", want, encoded.String())
+		}
+	}
+
+	events, skipped, err := ParseIcs(strings.NewReader(encoded.String()), time.UTC)
+	if err != nil || skipped != 0 || len(events) != 2 {
+		t.Fatalf("Expected both entries back, received %+v, %d skipped, %v", events, skipped, err)
+	}
+
+	if events[0].Summary != entries[0].Name || !events[0].EndDate.Equal(entries[0].EndDate) || events[1].Uid != "sick@example.com" {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+}
@@ -0,0 +1,28 @@
+CREATE TABLE calendar_entries (
+    id INTEGER PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    -- uid of the imported iCalendar event, re-importing the same event updates the entry, NULL for entries added by hand
+    uid VARCHAR,
+    name VARCHAR NOT NULL,
+    -- both days are inclusive and stored as YYYY-MM-DD, so they compare as strings
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    daily_screen_time_minutes INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (household_id, uid)
+);
+
+CREATE INDEX calendar_entries_household_id_end_date ON calendar_entries (household_id, end_date);
+
+-- entries without rows here apply to all children of the household
+CREATE TABLE calendar_entry_children (
+    entry_id INTEGER NOT NULL REFERENCES calendar_entries (id) ON DELETE CASCADE,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    PRIMARY KEY (entry_id, child_id)
+);
+
+CREATE TABLE calendar_feeds (
+    household_id INTEGER PRIMARY KEY REFERENCES households (id) ON DELETE CASCADE,
+    token VARCHAR NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,327 @@
+package calendar
+
+import (
+	"crypto/rand"
+	"database/sql"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"domanscy.group/parental-controls/server/children"
+)
+
+// MaxNameLength is counted in characters, not bytes.
+const MaxNameLength = 100
+
+// MaxEntryDays is the longest an entry may last, longer ones are most likely a mistake in an imported file.
+const MaxEntryDays = 366
+
+var ErrInvalidName = fmt.Errorf("entry name can not be empty or longer than %d characters", MaxNameLength)
+var ErrInvalidDates = fmt.Errorf("entry must end on or after the day it starts and last at most %d days", MaxEntryDays)
+var ErrEntryWithThisIdDoesNotExist = errors.New("calendar entry with this id does not exist")
+
+//go:embed migration.sql
+var MigrationFile string
+
+// Entry replaces the daily screen time of children on the days from StartDate to EndDate.
+type Entry struct {
+	Id          int
+	HouseholdId int
+	// Uid is the uid of the imported iCalendar event, empty for entries added by hand.
+	Uid  string
+	Name string
+	// StartDate and EndDate are both inclusive, only their dates matter.
+	StartDate time.Time
+	EndDate   time.Time
+	// ChildIds is empty when the entry applies to all children of the household.
+	ChildIds []int
+	// DailyScreenTimeMinutes replaces the one of the children, 0 means the screen time is not limited, as for children.
+	DailyScreenTimeMinutes int
+	CreatedAt              time.Time
+}
+
+// AppliesTo tells whether the entry changes the screen time of the child.
+func (entry *Entry) AppliesTo(childId int) bool {
+	if len(entry.ChildIds) == 0 {
+		return true
+	}
+
+	for _, id := range entry.ChildIds {
+		if id == childId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Date drops the time of day of t, as it is in its location.
+func Date(t time.Time) time.Time {
+	year, month, day := t.Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func (entry *Entry) validate() error {
+	entry.Name = strings.TrimSpace(entry.Name)
+	if entry.Name == "" || utf8.RuneCountInString(entry.Name) > MaxNameLength {
+		return ErrInvalidName
+	}
+
+	entry.StartDate = Date(entry.StartDate)
+	entry.EndDate = Date(entry.EndDate)
+
+	if entry.EndDate.Before(entry.StartDate) || entry.EndDate.Sub(entry.StartDate) >= MaxEntryDays*24*time.Hour {
+		return ErrInvalidDates
+	}
+
+	if entry.DailyScreenTimeMinutes < 0 || entry.DailyScreenTimeMinutes > children.MaxDailyScreenTimeMinutes {
+		return children.ErrInvalidDailyScreenTime
+	}
+
+	return nil
+}
+
+const selectColumns = "calendar_entries.id, calendar_entries.household_id, calendar_entries.uid, calendar_entries.name, calendar_entries.start_date, calendar_entries.end_date, calendar_entries.daily_screen_time_minutes, calendar_entries.created_at"
+
+func scanEntry(row interface{ Scan(dest ...any) error }) (*Entry, error) {
+	entry := &Entry{ChildIds: make([]int, 0)}
+
+	var uid sql.NullString
+
+	err := row.Scan(&entry.Id, &entry.HouseholdId, &uid, &entry.Name, &entry.StartDate, &entry.EndDate, &entry.DailyScreenTimeMinutes, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Uid = uid.String
+
+	return entry, nil
+}
+
+func findAll(db *sql.Tx, query string, args ...any) ([]Entry, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM calendar_entries ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	entries := make([]Entry, 0)
+
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		entries = append(entries, *entry)
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error occured while trying to close rows reader: %w", err)
+	}
+
+	for i := range entries {
+		entries[i].ChildIds, err = findChildIds(db, entries[i].Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+func findChildIds(db *sql.Tx, entryId int) ([]int, error) {
+	rows, err := db.Query("SELECT child_id FROM calendar_entry_children WHERE entry_id = $1 ORDER BY child_id", entryId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM calendar_entry_children ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	childIds := make([]int, 0)
+
+	for rows.Next() {
+		var childId int
+
+		err := rows.Scan(&childId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		childIds = append(childIds, childId)
+	}
+
+	return childIds, nil
+}
+
+func FindOneById(db *sql.Tx, id int) (*Entry, error) {
+	entries, err := findAll(db, "SELECT "+selectColumns+" FROM calendar_entries WHERE id = $1", id)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	return &entries[0], nil
+}
+
+// FindAllByHouseholdId returns the entries ending on since or later, ordered by the day they start.
+func FindAllByHouseholdId(db *sql.Tx, householdId int, since time.Time) ([]Entry, error) {
+	return findAll(
+		db,
+		"SELECT "+selectColumns+" FROM calendar_entries WHERE household_id = $1 AND end_date >= $2 ORDER BY start_date, id",
+		householdId,
+		Date(since).Format(time.DateOnly),
+	)
+}
+
+// FindEffective returns the entry that sets the screen time of the child on the day, nil when the usual one applies.
+// Entries of the child win over the ones of the whole household, from entries of the same kind the last added wins.
+func FindEffective(db *sql.Tx, householdId int, childId int, day time.Time) (*Entry, error) {
+	entries, err := findAll(
+		db,
+		"SELECT "+selectColumns+" FROM calendar_entries "+
+			"LEFT JOIN calendar_entry_children ON calendar_entry_children.entry_id = calendar_entries.id AND calendar_entry_children.child_id = $1 "+
+			"WHERE calendar_entries.household_id = $2 AND calendar_entries.start_date <= $3 AND calendar_entries.end_date >= $3 "+
+			"AND (calendar_entry_children.child_id IS NOT NULL OR NOT EXISTS (SELECT 1 FROM calendar_entry_children WHERE entry_id = calendar_entries.id)) "+
+			"ORDER BY calendar_entry_children.child_id IS NOT NULL DESC, calendar_entries.id DESC LIMIT 1",
+		childId,
+		householdId,
+		Date(day).Format(time.DateOnly),
+	)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	return &entries[0], nil
+}
+
+func replaceChildIds(db *sql.Tx, entryId int, childIds []int) error {
+	_, err := db.Exec("DELETE FROM calendar_entry_children WHERE entry_id = ?", entryId)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'DELETE FROM calendar_entry_children ...': %w", err)
+	}
+
+	for _, childId := range childIds {
+		_, err := db.Exec("INSERT INTO calendar_entry_children (entry_id, child_id) VALUES (?, ?) ON CONFLICT DO NOTHING;", entryId, childId)
+		if err != nil {
+			return fmt.Errorf("an error occured while trying to execute query 'INSERT INTO calendar_entry_children ...': %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Save adds the entry, an entry with the uid of one already in the household replaces it instead.
+// The children are not checked, they must belong to the household.
+func Save(db *sql.Tx, entry Entry) (int, error) {
+	err := entry.validate()
+	if err != nil {
+		return 0, err
+	}
+
+	uid := sql.NullString{String: entry.Uid, Valid: entry.Uid != ""}
+
+	var id int
+
+	err = db.QueryRow(
+		"INSERT INTO calendar_entries (household_id, uid, name, start_date, end_date, daily_screen_time_minutes) VALUES (?, ?, ?, ?, ?, ?) "+
+			"ON CONFLICT (household_id, uid) DO UPDATE SET name = excluded.name, start_date = excluded.start_date, end_date = excluded.end_date, daily_screen_time_minutes = excluded.daily_screen_time_minutes "+
+			"RETURNING id;",
+		entry.HouseholdId,
+		uid,
+		entry.Name,
+		entry.StartDate.Format(time.DateOnly),
+		entry.EndDate.Format(time.DateOnly),
+		entry.DailyScreenTimeMinutes,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO calendar_entries ...': %w", err)
+	}
+
+	err = replaceChildIds(db, id, entry.ChildIds)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func Delete(db *sql.Tx, householdId int, id int) error {
+	executed, err := db.Exec("DELETE FROM calendar_entries WHERE id = ? AND household_id = ?", id, householdId)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'DELETE FROM calendar_entries ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	if affectedRows == 0 {
+		return ErrEntryWithThisIdDoesNotExist
+	}
+
+	// foreign keys are not enforced, the children of the entry would outlive it
+	return replaceChildIds(db, id, nil)
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("an unknown error occured while trying to generate random bytes using crypto/rand.Read: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// RotateFeedToken gives the household a new token of its calendar feed, the url with the previous one stops working.
+func RotateFeedToken(db *sql.Tx, householdId int) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO calendar_feeds (household_id, token) VALUES (?, ?) ON CONFLICT (household_id) DO UPDATE SET token = excluded.token, created_at = CURRENT_TIMESTAMP;",
+		householdId,
+		token,
+	)
+	if err != nil {
+		return "", fmt.Errorf("an error occured while trying to execute query 'INSERT INTO calendar_feeds ...': %w", err)
+	}
+
+	return token, nil
+}
+
+// FindHouseholdIdByFeedToken returns 0 when no household has the token.
+func FindHouseholdIdByFeedToken(db *sql.Tx, token string) (int, error) {
+	var householdId int
+
+	err := db.QueryRow("SELECT household_id FROM calendar_feeds WHERE token = $1", token).Scan(&householdId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return householdId, nil
+}
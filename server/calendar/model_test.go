@@ -0,0 +1,215 @@
+package calendar
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/database"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/users"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0001_users":      users.MigrationFile,
+		"0002_households": households.MigrationFile,
+		"0003_children":   children.MigrationFile,
+		"0025_calendar":   MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func day(value string) time.Time {
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return date
+}
+
+func TestEntries(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	householdId, err := households.Create(tx, 1, "Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adamId, err := children.Create(tx, householdId, "Adam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ewaId, err := children.Create(tx, householdId, "Ewa")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("validates entries", func(t *testing.T) {
+		invalid := []struct {
+			entry Entry
+			want  error
+		}{
+			{Entry{Name: " ", StartDate: day("2024-01-01"), EndDate: day("2024-01-01")}, ErrInvalidName},
+			{Entry{Name: "Ferie", StartDate: day("2024-01-02"), EndDate: day("2024-01-01")}, ErrInvalidDates},
+			{Entry{Name: "Ferie", StartDate: day("2024-01-01"), EndDate: day("2025-01-01")}, ErrInvalidDates},
+			{Entry{Name: "Ferie", StartDate: day("2024-01-01"), EndDate: day("2024-01-01"), DailyScreenTimeMinutes: -1}, children.ErrInvalidDailyScreenTime},
+		}
+
+		for _, test := range invalid {
+			test.entry.HouseholdId = householdId
+
+			_, err := Save(tx, test.entry)
+			if !errors.Is(err, test.want) {
+				t.Errorf("Expected %v, received %v", test.want, err)
+			}
+		}
+	})
+
+	t.Run("finds the entry effective for the child", func(t *testing.T) {
+		_, err := Save(tx, Entry{HouseholdId: householdId, Name: "Ferie", StartDate: day("2024-01-29"), EndDate: day("2024-02-11"), DailyScreenTimeMinutes: 240})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sickId, err := Save(tx, Entry{HouseholdId: householdId, Name: "Chorobowe", StartDate: day("2024-02-01"), EndDate: day("2024-02-02"), ChildIds: []int{ewaId}, DailyScreenTimeMinutes: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// added later, but for the whole household
+		_, err = Save(tx, Entry{HouseholdId: householdId, Name: "Wycieczka", StartDate: day("2024-02-02"), EndDate: day("2024-02-02"), DailyScreenTimeMinutes: 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			childId int
+			day     string
+			want    string
+		}{
+			{adamId, "2024-01-28", ""},
+			{adamId, "2024-01-29", "Ferie"},
+			{adamId, "2024-02-01", "Ferie"},
+			{adamId, "2024-02-02", "Wycieczka"},
+			{ewaId, "2024-02-01", "Chorobowe"},
+			{ewaId, "2024-02-02", "Chorobowe"},
+			{ewaId, "2024-02-11", "Ferie"},
+			{ewaId, "2024-02-12", ""},
+		}
+
+		for _, test := range tests {
+			entry, err := FindEffective(tx, householdId, test.childId, day(test.day))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := ""
+			if entry != nil {
+				got = entry.Name
+			}
+
+			if got != test.want {
+				t.Errorf("child %d on %s: expected %q, received %q", test.childId, test.day, test.want, got)
+			}
+		}
+
+		entries, err := FindAllByHouseholdId(tx, householdId, day("2024-02-02"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != 3 || entries[0].Name != "Ferie" || entries[1].Id != sickId || len(entries[1].ChildIds) != 1 || entries[1].ChildIds[0] != ewaId {
+			t.Fatalf("Unexpected entries: %+v", entries)
+		}
+
+		err = Delete(tx, householdId, sickId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Delete(tx, householdId, sickId)
+		if !errors.Is(err, ErrEntryWithThisIdDoesNotExist) {
+			t.Fatalf("Expected %v, received %v", ErrEntryWithThisIdDoesNotExist, err)
+		}
+
+		entry, err := FindEffective(tx, householdId, ewaId, day("2024-02-01"))
+		if err != nil || entry == nil || entry.Name != "Ferie" {
+			t.Fatalf("Expected Ferie after deleting the entry of the child, received %+v, %v", entry, err)
+		}
+	})
+
+	t.Run("updates imported entries with the same uid", func(t *testing.T) {
+		imported := Entry{HouseholdId: householdId, Uid: "easter@example.com", Name: "Wielkanoc", StartDate: day("2024-03-31"), EndDate: day("2024-03-31"), ChildIds: []int{adamId}}
+
+		firstId, err := Save(tx, imported)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		imported.EndDate = day("2024-04-01")
+		imported.ChildIds = []int{ewaId}
+
+		secondId, err := Save(tx, imported)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entry, err := FindOneById(tx, firstId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if firstId != secondId || !entry.EndDate.Equal(day("2024-04-01")) || entry.AppliesTo(adamId) || !entry.AppliesTo(ewaId) {
+			t.Fatalf("Expected the entry to be updated, received %+v", entry)
+		}
+	})
+
+	t.Run("rotates feed tokens", func(t *testing.T) {
+		first, err := RotateFeedToken(tx, householdId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second, err := RotateFeedToken(tx, householdId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for token, want := range map[string]int{first: 0, second: householdId, "": 0} {
+			got, err := FindHouseholdIdByFeedToken(tx, token)
+			if err != nil || got != want {
+				t.Errorf("%q: expected %d, received %d, %v", token, want, got, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/calendar"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/users"
+	"github.com/go-chi/chi"
+)
+
+// calendarExportHistory is how far back exported calendars go, parents rarely look further.
+const calendarExportHistory = 365 * 24 * time.Hour
+
+var ErrInvalidCalendarEntryId = errors.New("invalid calendar entry id")
+var ErrCalendarEntryNotFound = errors.New("calendar entry not found")
+var ErrInvalidCalendarDateFormat = errors.New("dates must be in format YYYY-MM-DD")
+var ErrCalendarFeedNotFound = errors.New("calendar feed not found")
+
+type CalendarEntryResponse struct {
+	Id        int    `json:"id"`
+	Name      string `json:"name"`
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+	// ChildIds is empty when the entry applies to all children of the household.
+	ChildIds               []int `json:"childIds"`
+	DailyScreenTimeMinutes int   `json:"dailyScreenTimeMinutes"`
+	// Imported tells whether the entry comes from an iCalendar file.
+	Imported  bool      `json:"imported"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newCalendarEntryResponse(entry *calendar.Entry) CalendarEntryResponse {
+	return CalendarEntryResponse{
+		Id:                     entry.Id,
+		Name:                   entry.Name,
+		StartDate:              entry.StartDate.Format(time.DateOnly),
+		EndDate:                entry.EndDate.Format(time.DateOnly),
+		ChildIds:               entry.ChildIds,
+		DailyScreenTimeMinutes: entry.DailyScreenTimeMinutes,
+		Imported:               entry.Uid != "",
+		CreatedAt:              entry.CreatedAt,
+	}
+}
+
+// findChildrenOfHouseholdAndHandleError checks that all the children belong to the household, it responds and returns
+// nil if one does not. Without childIds it returns all children of the household.
+func findChildrenOfHouseholdAndHandleError(w http.ResponseWriter, r *http.Request, tx *sql.Tx, householdId int, childIds []int) []children.Model {
+	householdChildren, err := children.FindAllByHouseholdId(tx, householdId)
+	if err != nil {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith500(w, r, nil)
+		log.Printf("error occured while trying to find children of household: %v", err)
+		return nil
+	}
+
+	if len(childIds) == 0 {
+		return householdChildren
+	}
+
+	found := make([]children.Model, 0, len(childIds))
+
+	for _, childId := range childIds {
+		for _, child := range householdChildren {
+			if child.Id == childId {
+				found = append(found, child)
+				break
+			}
+		}
+	}
+
+	if len(found) != len(childIds) {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith404(w, r, ErrChildNotFound)
+		return nil
+	}
+
+	return found
+}
+
+func respondToCalendarEntryError(w http.ResponseWriter, r *http.Request, tx *sql.Tx, err error) {
+	err = littlehelpers.IfErrJoin(err, tx.Rollback())
+
+	if errors.Is(err, calendar.ErrInvalidName) || errors.Is(err, calendar.ErrInvalidDates) || errors.Is(err, children.ErrInvalidDailyScreenTime) {
+		respondWith400(w, r, err)
+		return
+	}
+
+	respondWith500(w, r, nil)
+	log.Printf("error occured while trying to save calendar entry: %v", err)
+}
+
+// publishCalendarChanged lets agents of the children refetch their budget, an entry may start today.
+func publishCalendarChanged(pushHub *push.Hub, affected []children.Model) {
+	for _, child := range affected {
+		publishEvent(pushHub, child.Id, 0, push.EventTypePolicyChanged, PolicyChangedEvent{Setting: "calendar"})
+	}
+}
+
+// HttpHouseholdsCalendarList returns the entries that did not end yet, ordered by the day they start.
+func HttpHouseholdsCalendarList(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		entries, err := calendar.FindAllByHouseholdId(tx, household.Id, time.Now().In(cfg.ReportsLocation))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find calendar entries: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := make([]CalendarEntryResponse, 0, len(entries))
+		for i := range entries {
+			response = append(response, newCalendarEntryResponse(&entries[i]))
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
+
+type CreateCalendarEntryRequestBody struct {
+	Name                   string `json:"name" validate:"required,length=..100"`
+	StartDate              string `json:"startDate" validate:"required"`
+	EndDate                string `json:"endDate" validate:"required"`
+	ChildIds               []int  `json:"childIds"`
+	DailyScreenTimeMinutes int    `json:"dailyScreenTimeMinutes"`
+}
+
+func HttpHouseholdsCalendarCreate(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreateCalendarEntryRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		startDate, startErr := time.Parse(time.DateOnly, requestBody.StartDate)
+		endDate, endErr := time.Parse(time.DateOnly, requestBody.EndDate)
+		if startErr != nil || endErr != nil {
+			respondWith400(w, r, ErrInvalidCalendarDateFormat)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		affected := findChildrenOfHouseholdAndHandleError(w, r, tx, household.Id, requestBody.ChildIds)
+		if affected == nil {
+			return
+		}
+
+		entryId, err := calendar.Save(tx, calendar.Entry{
+			HouseholdId:            household.Id,
+			Name:                   requestBody.Name,
+			StartDate:              startDate,
+			EndDate:                endDate,
+			ChildIds:               requestBody.ChildIds,
+			DailyScreenTimeMinutes: requestBody.DailyScreenTimeMinutes,
+		})
+		if err != nil {
+			respondToCalendarEntryError(w, r, tx, err)
+			return
+		}
+
+		entry, err := calendar.FindOneById(tx, entryId)
+		if err != nil || entry == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find created calendar entry: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishCalendarChanged(pushHub, affected)
+
+		respondWithJson(w, r, http.StatusCreated, newCalendarEntryResponse(entry))
+	}
+}
+
+func HttpHouseholdsCalendarDelete(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		entryId, err := strconv.Atoi(chi.URLParam(r, "entryId"))
+		if err != nil || entryId <= 0 {
+			respondWith400(w, r, ErrInvalidCalendarEntryId)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		entry, err := calendar.FindOneById(tx, entryId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find calendar entry: %v", err)
+			return
+		}
+
+		if entry == nil || entry.HouseholdId != household.Id {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrCalendarEntryNotFound)
+			return
+		}
+
+		affected := findChildrenOfHouseholdAndHandleError(w, r, tx, household.Id, entry.ChildIds)
+		if affected == nil {
+			return
+		}
+
+		err = calendar.Delete(tx, household.Id, entry.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to delete calendar entry: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishCalendarChanged(pushHub, affected)
+
+		w.WriteHeader(204)
+	}
+}
+
+type ImportCalendarRequestBody struct {
+	Ics      string `json:"ics"`
+	ChildIds []int  `json:"childIds"`
+	// DailyScreenTimeMinutes has to be sent, 0 for no limit, so a forgotten field does not lift the limit of every
+	// imported day.
+	DailyScreenTimeMinutes *int `json:"dailyScreenTimeMinutes" validate:"required"`
+}
+
+type ImportCalendarResponse struct {
+	Imported int `json:"imported"`
+	// Skipped counts recurring, cancelled and unnamed events, they are not imported.
+	Skipped int `json:"skipped"`
+}
+
+// HttpHouseholdsCalendarImport adds the events of an iCalendar file as entries with the same screen time and children.
+// Importing a file again updates the entries of events it already imported.
+func HttpHouseholdsCalendarImport(cfg *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[ImportCalendarRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		events, skipped, err := calendar.ParseIcs(strings.NewReader(requestBody.Ics), cfg.ReportsLocation)
+		if err != nil {
+			respondWith400(w, r, err)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		affected := findChildrenOfHouseholdAndHandleError(w, r, tx, household.Id, requestBody.ChildIds)
+		if affected == nil {
+			return
+		}
+
+		response := ImportCalendarResponse{Skipped: skipped}
+
+		for _, event := range events {
+			if strings.TrimSpace(event.Summary) == "" {
+				response.Skipped++
+				continue
+			}
+
+			_, err := calendar.Save(tx, calendar.Entry{
+				HouseholdId:            household.Id,
+				Uid:                    event.Uid,
+				Name:                   event.Summary,
+				StartDate:              event.StartDate,
+				EndDate:                event.EndDate,
+				ChildIds:               requestBody.ChildIds,
+				DailyScreenTimeMinutes: *requestBody.DailyScreenTimeMinutes,
+			})
+			if err != nil {
+				respondToCalendarEntryError(w, r, tx, fmt.Errorf("event %q: %w", event.Summary, err))
+				return
+			}
+
+			response.Imported++
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishCalendarChanged(pushHub, affected)
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
+
+// describeCalendarEntry names the children of the entry, or all of them, and the screen time it sets.
+func describeCalendarEntry(language i18n.Language, entry calendar.Entry, names map[int]string) string {
+	limit := mailCatalog.Text(language, "calendar.no_screen_time")
+	if entry.DailyScreenTimeMinutes > 0 {
+		limit = mailCatalog.Text(language, "calendar.screen_time", entry.DailyScreenTimeMinutes)
+	}
+
+	if len(entry.ChildIds) == 0 {
+		return mailCatalog.Text(language, "calendar.all_children") + ", " + limit
+	}
+
+	described := make([]string, 0, len(entry.ChildIds)+1)
+	for _, childId := range entry.ChildIds {
+		if name, ok := names[childId]; ok {
+			described = append(described, name)
+		}
+	}
+
+	return strings.Join(append(described, limit), ", ")
+}
+
+// respondWithCalendarIcs writes the entries of the household from the last year on as an iCalendar file.
+func respondWithCalendarIcs(cfg *ServerConfig, w http.ResponseWriter, r *http.Request, tx *sql.Tx, household *households.Model) {
+	entries, err := calendar.FindAllByHouseholdId(tx, household.Id, time.Now().Add(-calendarExportHistory).In(cfg.ReportsLocation))
+	if err != nil {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith500(w, r, nil)
+		log.Printf("error occured while trying to find calendar entries: %v", err)
+		return
+	}
+
+	householdChildren, err := children.FindAllByHouseholdId(tx, household.Id)
+	if err != nil {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith500(w, r, nil)
+		log.Printf("error occured while trying to find children of household: %v", err)
+		return
+	}
+
+	// the calendar is described in the language of the parent, the feed is read without their session
+	owner, err := users.FindOneById(tx, household.OwnerUserId)
+	if err != nil {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith500(w, r, nil)
+		log.Printf("error occured while trying to find owner of household: %v", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("failed to commit the transaction: %v", err)
+		respondWith500(w, r, nil)
+		return
+	}
+
+	language := i18n.DefaultLanguage
+	if owner != nil {
+		language = userLanguage(owner)
+	}
+
+	names := make(map[int]string, len(householdChildren))
+	for _, child := range householdChildren {
+		names[child.Id] = child.Name
+	}
+
+	host := "localhost"
+	if appUrl, err := url.Parse(cfg.AppUrl); err == nil && appUrl.Hostname() != "" {
+		host = appUrl.Hostname()
+	}
+
+	var encoded bytes.Buffer
+
+	err = calendar.WriteIcs(&encoded, household.Name, host, entries, func(entry calendar.Entry) string {
+		return describeCalendarEntry(language, entry, names)
+	})
+	if err != nil {
+		respondWith500(w, r, nil)
+		log.Printf("error occured while trying to encode the calendar: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(200)
+	_, err = w.Write(encoded.Bytes())
+	if err != nil {
+		log.Println("Error writing response:", err)
+	}
+}
+
+func HttpHouseholdsCalendarExport(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		respondWithCalendarIcs(cfg, w, r, tx, household)
+	}
+}
+
+type CalendarFeedResponse struct {
+	Url string `json:"url"`
+}
+
+// HttpHouseholdsCalendarFeedRotate returns a new url calendar apps can subscribe to, the previous one stops working.
+// Calendar apps can not send the bearer token, the token in the url is all that protects the feed.
+func HttpHouseholdsCalendarFeedRotate(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		token, err := calendar.RotateFeedToken(tx, household.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to rotate calendar feed token: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, CalendarFeedResponse{Url: fmt.Sprintf("%s/calendar_feeds/%s.ics", cfg.AppUrl, url.PathEscape(token))})
+	}
+}
+
+// HttpCalendarFeed serves the calendar of the household the token in the url belongs to.
+func HttpCalendarFeed(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		householdId, err := calendar.FindHouseholdIdByFeedToken(tx, chi.URLParam(r, "token"))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find calendar feed: %v", err)
+			return
+		}
+
+		household, err := households.FindOneById(tx, householdId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find household: %v", err)
+			return
+		}
+
+		if household == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrCalendarFeedNotFound)
+			return
+		}
+
+		respondWithCalendarIcs(cfg, w, r, tx, household)
+	}
+}
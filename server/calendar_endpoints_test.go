@@ -0,0 +1,242 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/users"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpCalendar(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	tx, err := db.Begin()
+	doTFatalIfErr(t, err)
+
+	siblingId, err := children.Create(tx, family.householdId, "Ewa")
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, children.UpdateDailyScreenTime(tx, family.childId, 60))
+	doTFatalIfErr(t, tx.Commit())
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/households/{householdId}/calendar", HttpHouseholdsCalendarList(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/households/{householdId}/calendar", HttpHouseholdsCalendarCreate(testingCfg, pushHub, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Delete("/households/{householdId}/calendar/{entryId}", HttpHouseholdsCalendarDelete(testingCfg, pushHub, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/households/{householdId}/calendar/import", HttpHouseholdsCalendarImport(testingCfg, pushHub, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/households/{householdId}/calendar.ics", HttpHouseholdsCalendarExport(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/households/{householdId}/calendar/feed", HttpHouseholdsCalendarFeedRotate(testingCfg, db))
+	router.Get("/calendar_feeds/{token}.ics", HttpCalendarFeed(testingCfg, db))
+	router.With(AuthenticateDeviceToken(db)).Get("/device/screen_time", HttpDeviceScreenTime(testingCfg, db))
+
+	sendParentRequest := func(userId int, method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	dailyMinutesOnDevice := func() int {
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/device/screen_time", nil)
+		request.Header.Set("Authorization", "Device "+family.deviceToken)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		var response ScreenTimeResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		return response.DailyMinutes
+	}
+
+	calendarPath := fmt.Sprintf("/households/%d/calendar", family.householdId)
+	today := time.Now().In(testingCfg.ReportsLocation)
+	todayDate := today.Format(time.DateOnly)
+
+	var holiday CalendarEntryResponse
+
+	t.Run("replaces the daily screen time on the days of entries", func(t *testing.T) {
+		invalid := []string{
+			fmt.Sprintf(`{"name": "", "startDate": "%s", "endDate": "%s"}`, todayDate, todayDate),
+			fmt.Sprintf(`{"name": "Ferie", "startDate": "%s", "endDate": "tomorrow"}`, todayDate),
+			fmt.Sprintf(`{"name": "Ferie", "startDate": "%s", "endDate": "%s", "dailyScreenTimeMinutes": 1441}`, todayDate, todayDate),
+		}
+
+		for _, body := range invalid {
+			recorder := sendParentRequest(family.userId, http.MethodPost, calendarPath, body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+
+		recorder := sendParentRequest(family.userId, http.MethodPost, calendarPath, fmt.Sprintf(`{"name": "Ferie", "startDate": "%s", "endDate": "%s", "childIds": [%d]}`, todayDate, todayDate, stranger.childId))
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d with a child of another household, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(stranger.userId, http.MethodPost, calendarPath, fmt.Sprintf(`{"name": "Ferie", "startDate": "%s", "endDate": "%s"}`, todayDate, todayDate))
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		subscription, _ := pushHub.Subscribe(siblingId, 0, 0)
+		defer subscription.Close()
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, calendarPath, fmt.Sprintf(`{"name": "Ferie", "startDate": "%s", "endDate": "%s", "dailyScreenTimeMinutes": 240}`, today.AddDate(0, 0, -1).Format(time.DateOnly), today.AddDate(0, 0, 6).Format(time.DateOnly)))
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &holiday))
+
+		event := <-subscription.Events()
+		if event.Type != push.EventTypePolicyChanged || string(event.Data) != `{"setting":"calendar"}` {
+			t.Fatalf("Unexpected event: %+v", event)
+		}
+
+		if minutes := dailyMinutesOnDevice(); minutes != 240 {
+			t.Fatalf("Got %d daily minutes, want the 240 of the holiday", minutes)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, calendarPath, fmt.Sprintf(`{"name": "Chorobowe", "startDate": "%s", "endDate": "%s", "childIds": [%d], "dailyScreenTimeMinutes": 30}`, todayDate, todayDate, family.childId))
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		if minutes := dailyMinutesOnDevice(); minutes != 30 {
+			t.Fatalf("Got %d daily minutes, want the 30 of the entry of the child", minutes)
+		}
+	})
+
+	t.Run("imports iCalendar files again without duplicates", func(t *testing.T) {
+		ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+			"BEGIN:VEVENT\r\nUID:trip@example.com\r\nDTSTART;VALUE=DATE:" + today.AddDate(0, 0, 10).Format("20060102") + "\r\nSUMMARY:Wycieczka\r\nEND:VEVENT\r\n" +
+			"BEGIN:VEVENT\r\nUID:pool@example.com\r\nDTSTART;VALUE=DATE:20240105\r\nRRULE:FREQ=WEEKLY\r\nSUMMARY:Basen\r\nEND:VEVENT\r\n" +
+			"END:VCALENDAR\r\n"
+
+		body, err := json.Marshal(map[string]any{"ics": ics, "childIds": []int{siblingId}, "dailyScreenTimeMinutes": 0})
+		doTFatalIfErr(t, err)
+
+		for i := 0; i < 2; i++ {
+			recorder := sendParentRequest(family.userId, http.MethodPost, calendarPath+"/import", string(body))
+			if recorder.Code != http.StatusOK || recorder.Body.String() != `{"imported":1,"skipped":1}` {
+				t.Fatalf("Got %d %s, want one imported and one skipped event", recorder.Code, recorder.Body.String())
+			}
+		}
+
+		recorder := sendParentRequest(family.userId, http.MethodPost, calendarPath+"/import", `{"ics": "not a calendar", "dailyScreenTimeMinutes": 60}`)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		body, err = json.Marshal(map[string]any{"ics": ics, "childIds": []int{siblingId}})
+		doTFatalIfErr(t, err)
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, calendarPath+"/import", string(body))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d without the screen time of the imported days", recorder.Code, http.StatusBadRequest)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, calendarPath, "")
+
+		var entries []CalendarEntryResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &entries))
+
+		if len(entries) != 3 || entries[2].Name != "Wycieczka" || !entries[2].Imported || len(entries[2].ChildIds) != 1 || entries[2].ChildIds[0] != siblingId {
+			t.Fatalf("Unexpected entries: %+v", entries)
+		}
+	})
+
+	t.Run("exports the calendar and serves it to subscribers", func(t *testing.T) {
+		recorder := sendParentRequest(family.userId, http.MethodGet, calendarPath+".ics", "")
+		if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/calendar") {
+			t.Fatalf("Got %d %s, want a calendar", recorder.Code, recorder.Header().Get("Content-Type"))
+		}
+
+		exported := recorder.Body.String()
+		for _, want := range []string{"SUMMARY:Ferie", "SUMMARY:Chorobowe", "UID:trip@example.com", "Ewa\\, bez limitu czasu ekranowego"} {
+			if !strings.Contains(exported, want) {
+				t.Errorf("Expected %q in %s", want, exported)
+			}
+		}
+
+		var feeds []string
+
+		for i := 0; i < 2; i++ {
+			recorder = sendParentRequest(family.userId, http.MethodPost, calendarPath+"/feed", "")
+
+			var response struct {
+				Url string `json:"url"`
+			}
+			doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+			feeds = append(feeds, strings.TrimPrefix(response.Url, testingCfg.AppUrl))
+		}
+
+		for i, want := range []int{http.StatusNotFound, http.StatusOK} {
+			recorder = httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost:8080"+feeds[i], nil))
+
+			if recorder.Code != want {
+				t.Fatalf("%s: got %d, want %d", feeds[i], recorder.Code, want)
+			}
+		}
+
+		if recorder.Body.String() != exported {
+			t.Fatalf("Got %s from the feed, want the exported calendar", recorder.Body.String())
+		}
+
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, users.UpdateLanguage(tx, family.userId, "en"))
+		doTFatalIfErr(t, tx.Commit())
+
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost:8080"+feeds[1], nil))
+
+		if !strings.Contains(recorder.Body.String(), "Ewa\\, no screen time limit") {
+			t.Errorf("Expected the calendar described in the language of the parent, received %s", recorder.Body.String())
+		}
+	})
+
+	t.Run("deletes entries", func(t *testing.T) {
+		path := fmt.Sprintf("%s/%d", calendarPath, holiday.Id)
+
+		recorder := sendParentRequest(stranger.userId, http.MethodDelete, fmt.Sprintf("/households/%d/calendar/%d", stranger.householdId, holiday.Id), "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodDelete, path, "")
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodDelete, path, "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,7 @@
+CREATE TABLE children (
+    id INTEGER PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    block_mode VARCHAR NOT NULL DEFAULT 'nxdomain',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+ALTER TABLE children ADD COLUMN birth_date DATE;
+ALTER TABLE children ADD COLUMN policy_template VARCHAR NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE children ADD COLUMN safe_search BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE children ADD COLUMN youtube_restricted_mode VARCHAR NOT NULL DEFAULT 'off';
@@ -0,0 +1 @@
+ALTER TABLE children ADD COLUMN daily_screen_time_minutes INTEGER NOT NULL DEFAULT 0;
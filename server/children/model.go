@@ -0,0 +1,271 @@
+package children
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrNameCannotBeEmpty = errors.New("child name can not be empty")
+var ErrInvalidBlockMode = errors.New("invalid block mode")
+var ErrInvalidYoutubeRestrictedMode = errors.New("invalid youtube restricted mode")
+var ErrInvalidDailyScreenTime = fmt.Errorf("daily screen time must be between 0 and %d minutes", MaxDailyScreenTimeMinutes)
+var ErrChildWithThisIdDoesNotExist = errors.New("child with this id does not exist")
+var ErrInvalidBirthDate = errors.New("birth date can not be in the future")
+
+// MaxDailyScreenTimeMinutes is a whole day, 0 means the screen time is not limited.
+const MaxDailyScreenTimeMinutes = 24 * 60
+
+// BlockMode tells the dns filter how to answer queries for blocked domains.
+type BlockMode string
+
+const (
+	BlockModeNxdomain  BlockMode = "nxdomain"
+	BlockModeBlockPage BlockMode = "block_page"
+)
+
+func (mode BlockMode) IsValid() bool {
+	return mode == BlockModeNxdomain || mode == BlockModeBlockPage
+}
+
+// YoutubeRestrictedMode maps to the two restriction levels YouTube offers for networks.
+type YoutubeRestrictedMode string
+
+const (
+	YoutubeRestrictedModeOff      YoutubeRestrictedMode = "off"
+	YoutubeRestrictedModeModerate YoutubeRestrictedMode = "moderate"
+	YoutubeRestrictedModeStrict   YoutubeRestrictedMode = "strict"
+)
+
+func (mode YoutubeRestrictedMode) IsValid() bool {
+	return mode == YoutubeRestrictedModeOff || mode == YoutubeRestrictedModeModerate || mode == YoutubeRestrictedModeStrict
+}
+
+type Model struct {
+	Id          int
+	HouseholdId int
+	Name        string
+	BlockMode   BlockMode
+	// SafeSearch enforces safe search in Google, Bing and DuckDuckGo.
+	SafeSearch            bool
+	YoutubeRestrictedMode YoutubeRestrictedMode
+	// DailyScreenTimeMinutes is the screen time budget of a day without granted extensions, 0 when not limited.
+	DailyScreenTimeMinutes int
+	// BirthDate is zero when the parent did not enter it, only its date is meaningful.
+	BirthDate time.Time
+	// PolicyTemplate is the key of the template applied last, empty when none was applied.
+	PolicyTemplate string
+	CreatedAt      time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+//go:embed migration_safe_search.sql
+var SafeSearchMigrationFile string
+
+//go:embed migration_screen_time.sql
+var ScreenTimeMigrationFile string
+
+//go:embed migration_birth_date.sql
+var BirthDateMigrationFile string
+
+const selectColumns = "children.id, children.household_id, children.name, children.block_mode, children.safe_search, children.youtube_restricted_mode, children.daily_screen_time_minutes, children.birth_date, children.policy_template, children.created_at"
+
+func scanChild(row interface{ Scan(dest ...any) error }) (*Model, error) {
+	child := &Model{}
+
+	var birthDate sql.NullTime
+
+	err := row.Scan(&child.Id, &child.HouseholdId, &child.Name, &child.BlockMode, &child.SafeSearch, &child.YoutubeRestrictedMode, &child.DailyScreenTimeMinutes, &birthDate, &child.PolicyTemplate, &child.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	child.BirthDate = birthDate.Time
+
+	return child, nil
+}
+
+func findOne(db *sql.Tx, query string, args ...interface{}) (*Model, error) {
+	child, err := scanChild(db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return child, nil
+}
+
+func FindOneById(db *sql.Tx, id int) (*Model, error) {
+	return findOne(db, "SELECT "+selectColumns+" FROM children WHERE id = $1", id)
+}
+
+func FindAllByHouseholdId(db *sql.Tx, householdId int) ([]Model, error) {
+	rows, err := db.Query("SELECT "+selectColumns+" FROM children WHERE household_id = $1 ORDER BY id", householdId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM children ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	children := make([]Model, 0)
+
+	for rows.Next() {
+		child, err := scanChild(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		children = append(children, *child)
+	}
+
+	return children, nil
+}
+
+func Create(db *sql.Tx, householdId int, name string) (int, error) {
+	if name == "" {
+		return 0, ErrNameCannotBeEmpty
+	}
+
+	exec, err := db.Exec("INSERT INTO children (household_id, name) VALUES (?, ?);", householdId, name)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO children ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+func UpdateBlockMode(db *sql.Tx, id int, blockMode BlockMode) error {
+	if !blockMode.IsValid() {
+		return ErrInvalidBlockMode
+	}
+
+	executed, err := db.Exec("UPDATE children SET block_mode = ? WHERE id = ?", blockMode, id)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE children ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	if affectedRows == 0 {
+		return ErrChildWithThisIdDoesNotExist
+	}
+
+	return nil
+}
+
+// FindOneByIdAndOwnerUserId finds the child only if it belongs to a household owned by the given user.
+func FindOneByIdAndOwnerUserId(db *sql.Tx, id int, ownerUserId int) (*Model, error) {
+	return findOne(
+		db,
+		"SELECT "+selectColumns+" FROM children INNER JOIN households ON households.id = children.household_id WHERE children.id = $1 AND households.owner_user_id = $2",
+		id,
+		ownerUserId,
+	)
+}
+
+func UpdateSafeSearch(db *sql.Tx, id int, safeSearch bool, youtubeRestrictedMode YoutubeRestrictedMode) error {
+	if !youtubeRestrictedMode.IsValid() {
+		return ErrInvalidYoutubeRestrictedMode
+	}
+
+	executed, err := db.Exec("UPDATE children SET safe_search = ?, youtube_restricted_mode = ? WHERE id = ?", safeSearch, youtubeRestrictedMode, id)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE children ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	if affectedRows == 0 {
+		return ErrChildWithThisIdDoesNotExist
+	}
+
+	return nil
+}
+
+func UpdateDailyScreenTime(db *sql.Tx, id int, minutes int) error {
+	if minutes < 0 || minutes > MaxDailyScreenTimeMinutes {
+		return ErrInvalidDailyScreenTime
+	}
+
+	executed, err := db.Exec("UPDATE children SET daily_screen_time_minutes = ? WHERE id = ?", minutes, id)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE children ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	if affectedRows == 0 {
+		return ErrChildWithThisIdDoesNotExist
+	}
+
+	return nil
+}
+
+// UpdateBirthDate stores only the date of birthDate, as it is in its location.
+func UpdateBirthDate(db *sql.Tx, id int, birthDate time.Time, now time.Time) error {
+	year, month, day := birthDate.Date()
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	if date.After(now) {
+		return ErrInvalidBirthDate
+	}
+
+	executed, err := db.Exec("UPDATE children SET birth_date = ? WHERE id = ?", date.Format(time.DateOnly), id)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE children ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	if affectedRows == 0 {
+		return ErrChildWithThisIdDoesNotExist
+	}
+
+	return nil
+}
+
+func UpdatePolicyTemplate(db *sql.Tx, id int, key string) error {
+	executed, err := db.Exec("UPDATE children SET policy_template = ? WHERE id = ?", key, id)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE children ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	if affectedRows == 0 {
+		return ErrChildWithThisIdDoesNotExist
+	}
+
+	return nil
+}
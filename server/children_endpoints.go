@@ -0,0 +1,442 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/policytemplates"
+	"domanscy.group/parental-controls/server/push"
+)
+
+var ErrInvalidBirthDateFormat = errors.New("birth date must be a date in format YYYY-MM-DD")
+
+type PolicyTemplateUpgradeResponse struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+type ChildResponse struct {
+	Id          int    `json:"id"`
+	HouseholdId int    `json:"householdId"`
+	Name        string `json:"name"`
+	// BirthDate and Age are null when the parent did not enter the birth date.
+	BirthDate      *string `json:"birthDate"`
+	Age            *int    `json:"age"`
+	PolicyTemplate string  `json:"policyTemplate"`
+	// TemplateUpgrade is the built-in template the child grew into, the parent applies it to accept the upgrade.
+	TemplateUpgrade *PolicyTemplateUpgradeResponse `json:"templateUpgrade"`
+	CreatedAt       time.Time                      `json:"createdAt"`
+}
+
+func newChildResponse(cfg *ServerConfig, child *children.Model, now time.Time, language i18n.Language) ChildResponse {
+	response := ChildResponse{
+		Id:             child.Id,
+		HouseholdId:    child.HouseholdId,
+		Name:           child.Name,
+		PolicyTemplate: child.PolicyTemplate,
+		CreatedAt:      child.CreatedAt,
+	}
+
+	if !child.BirthDate.IsZero() {
+		birthDate := child.BirthDate.Format(time.DateOnly)
+		age := policytemplates.Age(child.BirthDate, now, cfg.ReportsLocation)
+
+		response.BirthDate = &birthDate
+		response.Age = &age
+	}
+
+	if upgrade := policytemplates.Upgrade(child, now, cfg.ReportsLocation); upgrade != nil {
+		response.TemplateUpgrade = &PolicyTemplateUpgradeResponse{Key: upgrade.Key, Name: upgrade.NameIn(language)}
+	}
+
+	return response
+}
+
+// parseBirthDate returns the zero time for an empty birth date.
+func parseBirthDate(rawBirthDate string) (time.Time, error) {
+	if rawBirthDate == "" {
+		return time.Time{}, nil
+	}
+
+	birthDate, err := time.Parse(time.DateOnly, rawBirthDate)
+	if err != nil {
+		return time.Time{}, ErrInvalidBirthDateFormat
+	}
+
+	return birthDate, nil
+}
+
+type UpdateSafeSearchRequestBody struct {
+	SafeSearch            bool   `json:"safeSearch"`
+	YoutubeRestrictedMode string `json:"youtubeRestrictedMode" validate:"required,oneof=off moderate strict"`
+}
+
+func HttpChildrenUpdateSafeSearch(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[UpdateSafeSearchRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		youtubeRestrictedMode := children.YoutubeRestrictedMode(requestBody.YoutubeRestrictedMode)
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child, err := children.FindOneByIdAndOwnerUserId(tx, childId, authenticatedUserId(r))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child: %v", err)
+			return
+		}
+
+		if child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrChildNotFound)
+			return
+		}
+
+		err = children.UpdateSafeSearch(tx, child.Id, requestBody.SafeSearch, youtubeRestrictedMode)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to update safe search settings: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishEvent(pushHub, child.Id, 0, push.EventTypePolicyChanged, PolicyChangedEvent{Setting: "safe_search"})
+
+		w.WriteHeader(204)
+	}
+}
+
+type UpdateBlockModeRequestBody struct {
+	BlockMode string `json:"blockMode" validate:"required,oneof=nxdomain block_page"`
+}
+
+// HttpChildrenUpdateBlockMode chooses whether blocked domains resolve to nothing or to the block page. The dns filter
+// reads the mode on every query, so devices are not notified.
+func HttpChildrenUpdateBlockMode(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[UpdateBlockModeRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		err = children.UpdateBlockMode(tx, child.Id, children.BlockMode(requestBody.BlockMode))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to update block mode: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+type UpdateBlockedCategoriesRequestBody struct {
+	Categories []string `json:"categories" validate:"oneof=adult gambling social_media gaming malware ads"`
+}
+
+func HttpChildrenUpdateBlockedCategories(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[UpdateBlockedCategoriesRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		categories := make([]blocklists.Category, 0, len(requestBody.Categories))
+
+		for _, category := range requestBody.Categories {
+			categories = append(categories, blocklists.Category(category))
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child, err := children.FindOneByIdAndOwnerUserId(tx, childId, authenticatedUserId(r))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child: %v", err)
+			return
+		}
+
+		if child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrChildNotFound)
+			return
+		}
+
+		err = blocklists.UpdateBlockedCategoriesOfChild(tx, child.Id, categories)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to update blocked categories: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishEvent(pushHub, child.Id, 0, push.EventTypePolicyChanged, PolicyChangedEvent{Setting: "blocked_categories"})
+
+		w.WriteHeader(204)
+	}
+}
+
+type CreateChildRequestBody struct {
+	Name      string `json:"name" validate:"required"`
+	BirthDate string `json:"birthDate"`
+}
+
+// HttpHouseholdsChildrenCreate creates the profile of a child, the built-in template for the age of the child is
+// applied when the birth date is known.
+func HttpHouseholdsChildrenCreate(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreateChildRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		birthDate, err := parseBirthDate(requestBody.BirthDate)
+		if err != nil {
+			respondWith400(w, r, err)
+			return
+		}
+
+		now := time.Now()
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		childId, err := children.Create(tx, household.Id, requestBody.Name)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create child: %v", err)
+			return
+		}
+
+		if !birthDate.IsZero() {
+			err = children.UpdateBirthDate(tx, childId, birthDate, now)
+			if errors.Is(err, children.ErrInvalidBirthDate) {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith400(w, r, err)
+				return
+			} else if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to update birth date: %v", err)
+				return
+			}
+
+			if template := policytemplates.BuiltinForAge(policytemplates.Age(birthDate, now, cfg.ReportsLocation)); template != nil {
+				err = policytemplates.Apply(tx, childId, template)
+				if err != nil {
+					err = littlehelpers.IfErrJoin(err, tx.Rollback())
+					respondWith500(w, r, nil)
+					log.Printf("error occured while trying to apply policy template: %v", err)
+					return
+				}
+			}
+		}
+
+		child, err := children.FindOneById(tx, childId)
+		if err != nil || child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find created child: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusCreated, newChildResponse(cfg, child, now, requestLanguage(r)))
+	}
+}
+
+// HttpHouseholdsChildrenList returns the children with the template upgrades they are offered.
+func HttpHouseholdsChildrenList(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		householdChildren, err := children.FindAllByHouseholdId(tx, household.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find children: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		now := time.Now()
+		response := make([]ChildResponse, 0, len(householdChildren))
+
+		for i := range householdChildren {
+			response = append(response, newChildResponse(cfg, &householdChildren[i], now, requestLanguage(r)))
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
+
+type UpdateBirthDateRequestBody struct {
+	BirthDate string `json:"birthDate" validate:"required"`
+}
+
+// HttpChildrenUpdateBirthDate only changes the birth date, a template for the new age is offered as an upgrade.
+func HttpChildrenUpdateBirthDate(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[UpdateBirthDateRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		birthDate, err := parseBirthDate(requestBody.BirthDate)
+		if err != nil {
+			respondWith400(w, r, err)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		err = children.UpdateBirthDate(tx, child.Id, birthDate, time.Now())
+		if errors.Is(err, children.ErrInvalidBirthDate) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to update birth date: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
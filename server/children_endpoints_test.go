@@ -0,0 +1,241 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/validation"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpChildrenUpdateSafeSearch(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Put("/children/{childId}/safe_search", HttpChildrenUpdateSafeSearch(testingCfg, pushHub, db))
+
+	sendRequest := func(childId int, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:8080/children/%d/safe_search", childId), strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, family.userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("updates safe search settings of the child", func(t *testing.T) {
+		subscription, _ := pushHub.Subscribe(family.childId, family.deviceId, 0)
+		defer subscription.Close()
+
+		recorder := sendRequest(family.childId, `{"safeSearch": true, "youtubeRestrictedMode": "moderate"}`)
+
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		child, err := children.FindOneById(tx, family.childId)
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		if !child.SafeSearch || child.YoutubeRestrictedMode != children.YoutubeRestrictedModeModerate {
+			t.Errorf("Expected safe search with moderate youtube restricted mode, received %t and %s", child.SafeSearch, child.YoutubeRestrictedMode)
+		}
+
+		select {
+		case event := <-subscription.Events():
+			if event.Type != push.EventTypePolicyChanged {
+				t.Errorf("Expected %s, received %s", push.EventTypePolicyChanged, event.Type)
+			}
+		default:
+			t.Error("Expected policy changed event to be published")
+		}
+	})
+
+	t.Run("returns 400 for unknown youtube restricted mode", func(t *testing.T) {
+		recorder := sendRequest(family.childId, `{"safeSearch": true, "youtubeRestrictedMode": "extreme"}`)
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		want := validation.FieldError{Field: "youtubeRestrictedMode", Err: validation.ErrNotOneOf, Args: []any{"off, moderate, strict"}}.Error()
+		if recorder.Body.String() != want {
+			t.Errorf("Got %s, want %s", recorder.Body.String(), want)
+		}
+	})
+
+	t.Run("returns 404 if child belongs to someone else", func(t *testing.T) {
+		recorder := sendRequest(stranger.childId, `{"safeSearch": true, "youtubeRestrictedMode": "off"}`)
+
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestHttpChildrenUpdateBlockedCategories(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Put("/children/{childId}/blocked_categories", HttpChildrenUpdateBlockedCategories(testingCfg, pushHub, db))
+
+	sendRequest := func(childId int, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:8080/children/%d/blocked_categories", childId), strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, family.userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	findBlockedCategories := func(t *testing.T) []blocklists.Category {
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		categories, err := blocklists.FindBlockedCategoriesByChildId(tx, family.childId)
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		return categories
+	}
+
+	t.Run("replaces blocked categories of the child", func(t *testing.T) {
+		recorder := sendRequest(family.childId, `{"categories": ["gambling", "adult"]}`)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		recorder = sendRequest(family.childId, `{"categories": ["malware"]}`)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		categories := findBlockedCategories(t)
+		if len(categories) != 1 || categories[0] != blocklists.CategoryMalware {
+			t.Errorf("Expected [malware], received %v", categories)
+		}
+	})
+
+	t.Run("returns 400 for unknown category", func(t *testing.T) {
+		recorder := sendRequest(family.childId, `{"categories": ["homework"]}`)
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		want := validation.FieldError{Field: "categories[0]", Err: validation.ErrNotOneOf, Args: []any{"adult, gambling, social_media, gaming, malware, ads"}}.Error()
+		if recorder.Body.String() != want {
+			t.Errorf("Got %s, want %s", recorder.Body.String(), want)
+		}
+	})
+
+	t.Run("returns 404 if child belongs to someone else", func(t *testing.T) {
+		recorder := sendRequest(stranger.childId, `{"categories": []}`)
+
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestHttpChildrenUpdateBlockMode(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Put("/children/{childId}/block_mode", HttpChildrenUpdateBlockMode(testingCfg, db))
+
+	sendRequest := func(childId int, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:8080/children/%d/block_mode", childId), strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, family.userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	findBlockMode := func(t *testing.T) children.BlockMode {
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		child, err := children.FindOneById(tx, family.childId)
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		return child.BlockMode
+	}
+
+	t.Run("updates the block mode of the child", func(t *testing.T) {
+		recorder := sendRequest(family.childId, `{"blockMode": "block_page"}`)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		if mode := findBlockMode(t); mode != children.BlockModeBlockPage {
+			t.Errorf("Got %s, want %s", mode, children.BlockModeBlockPage)
+		}
+	})
+
+	t.Run("returns 400 for unknown block modes", func(t *testing.T) {
+		for _, body := range []string{`{"blockMode": "redirect"}`, `{}`} {
+			recorder := sendRequest(family.childId, body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("returns 404 if child belongs to someone else", func(t *testing.T) {
+		recorder := sendRequest(stranger.childId, `{"blockMode": "nxdomain"}`)
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		if mode := findBlockMode(t); mode != children.BlockModeBlockPage {
+			t.Errorf("Got %s, want %s", mode, children.BlockModeBlockPage)
+		}
+	})
+}
@@ -4,18 +4,40 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"database/sql"
 	"encoding/hex"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/database"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/migrations"
+	"domanscy.group/parental-controls/server/proxyca"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 var command string
 var output string
+var databaseUrl string
+var blocklistName string
+var blocklistCategory string
+var blocklistFormat string
+var blocklistFile string
+var householdId int
 
 func init() {
-	flag.StringVar(&output, "output", "", "Output file location, valid for commands: generate-private-key. If output is not supplied, it will write to stdout.")
+	flag.StringVar(&output, "output", "", "Output file location, valid for commands: generate-private-key, generate-proxy-ca. If output is not supplied, it will write to stdout.")
+	flag.StringVar(&databaseUrl, "database", "", "Database url, the same as DATABASE_URL of the server, valid for commands: import-blocklist, refresh-blocklists, generate-proxy-ca, remove-proxy-ca.")
+	flag.StringVar(&blocklistName, "name", "", "Unique name of the blocklist, importing under an existing name replaces that list, valid for commands: import-blocklist.")
+	flag.StringVar(&blocklistCategory, "category", "", "Category of the blocklist (adult, gambling, social_media, gaming, malware, ads), valid for commands: import-blocklist.")
+	flag.StringVar(&blocklistFormat, "format", "hosts", "Format of the blocklist file (hosts, adblock, domains), valid for commands: import-blocklist.")
+	flag.StringVar(&blocklistFile, "file", "", "Path to the blocklist file, it is remembered for refresh-blocklists, valid for commands: import-blocklist.")
+	flag.IntVar(&householdId, "household", 0, "Id of the household, valid for commands: generate-proxy-ca, remove-proxy-ca.")
 }
 
 func usage() {
@@ -23,6 +45,11 @@ func usage() {
 	fmt.Println()
 	fmt.Println("Arguments:")
 	fmt.Println("  generate-private-key - generates private key to stdout (there is also an option to write to the file directly, see -output)")
+	fmt.Println("  import-blocklist     - imports a category blocklist from a local file (see -database, -name, -category, -format and -file)")
+	fmt.Println("  refresh-blocklists   - imports every known blocklist again from the file it was imported from (see -database)")
+	fmt.Println("  generate-proxy-ca    - turns on https interception of the forward proxy for the household and writes the certificate")
+	fmt.Println("                         to install on its devices, a new one replaces the previous (see -database, -household and -output)")
+	fmt.Println("  remove-proxy-ca      - turns off https interception of the forward proxy for the household (see -database and -household)")
 	fmt.Println()
 	fmt.Println("Options:")
 	flag.PrintDefaults()
@@ -51,8 +78,124 @@ func main() {
 				log.Fatalf("failed to write private key to file '%s' with permissions: %d", output, 0666)
 			}
 		}
+	} else if command == "import-blocklist" {
+		db := openDatabase()
+		defer db.Close()
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Fatalf("failed to open database transaction: %v", err)
+		}
+
+		result, err := blocklists.Import(tx, blocklistName, blocklists.Category(blocklistCategory), blocklists.Format(blocklistFormat), blocklistFile)
+		if err != nil {
+			log.Fatalf("failed to import blocklist: %v", littlehelpers.IfErrJoin(err, tx.Rollback()))
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Fatalf("failed to commit the transaction: %v", err)
+		}
+
+		fmt.Printf("imported %d domains to blocklist '%s', skipped %d lines\n", result.DomainCount, result.Name, result.Skipped)
+	} else if command == "refresh-blocklists" {
+		db := openDatabase()
+		defer db.Close()
+
+		results, err := blocklists.RefreshAll(db)
+
+		for _, result := range results {
+			fmt.Printf("refreshed blocklist '%s' with %d domains, skipped %d lines\n", result.Name, result.DomainCount, result.Skipped)
+		}
+
+		if err != nil {
+			log.Fatalf("failed to refresh blocklists: %v", err)
+		}
+	} else if command == "generate-proxy-ca" {
+		db := openDatabase()
+		defer db.Close()
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Fatalf("failed to open database transaction: %v", err)
+		}
+
+		household, err := households.FindOneById(tx, householdId)
+		if err != nil {
+			log.Fatalf("failed to find household: %v", littlehelpers.IfErrJoin(err, tx.Rollback()))
+		}
+
+		if household == nil {
+			log.Fatal(littlehelpers.IfErrJoin(fmt.Errorf("household %d does not exist", householdId), tx.Rollback()))
+		}
+
+		certificatePem, privateKeyPem, err := proxyca.Generate(fmt.Sprintf("Parental controls %s", household.Name), time.Now())
+		if err != nil {
+			log.Fatalf("failed to generate proxy certificate authority: %v", littlehelpers.IfErrJoin(err, tx.Rollback()))
+		}
+
+		err = proxyca.Save(tx, household.Id, certificatePem, privateKeyPem)
+		if err != nil {
+			log.Fatalf("failed to save proxy certificate authority: %v", littlehelpers.IfErrJoin(err, tx.Rollback()))
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Fatalf("failed to commit the transaction: %v", err)
+		}
+
+		if output == "" {
+			fmt.Print(certificatePem)
+		} else {
+			err = os.WriteFile(output, []byte(certificatePem), 0644)
+			if err != nil {
+				log.Fatalf("failed to write certificate to file '%s': %v", output, err)
+			}
+		}
+	} else if command == "remove-proxy-ca" {
+		db := openDatabase()
+		defer db.Close()
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Fatalf("failed to open database transaction: %v", err)
+		}
+
+		deleted, err := proxyca.DeleteByHouseholdId(tx, householdId)
+		if err != nil {
+			log.Fatalf("failed to remove proxy certificate authority: %v", littlehelpers.IfErrJoin(err, tx.Rollback()))
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Fatalf("failed to commit the transaction: %v", err)
+		}
+
+		if !deleted {
+			fmt.Printf("household %d had no proxy certificate authority\n", householdId)
+		} else {
+			fmt.Printf("removed proxy certificate authority of household %d, its devices can remove the certificate\n", householdId)
+		}
 	} else {
 		fmt.Println("unknown command supplied")
 		flag.Usage()
 	}
 }
+
+func openDatabase() *sql.DB {
+	if databaseUrl == "" {
+		log.Fatal("option -database is required")
+	}
+
+	db, err := sql.Open("sqlite3", databaseUrl)
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
+
+	err = database.Migrate(db, migrations.All)
+	if err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	return db
+}
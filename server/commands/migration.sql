@@ -0,0 +1,16 @@
+CREATE TABLE device_commands (
+    id INTEGER PRIMARY KEY,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    device_id INTEGER NOT NULL REFERENCES devices (id) ON DELETE CASCADE,
+    type VARCHAR NOT NULL,
+    message VARCHAR NOT NULL DEFAULT '',
+    not_before TIMESTAMP NOT NULL,
+    status VARCHAR NOT NULL DEFAULT 'pending',
+    delivery_attempts INTEGER NOT NULL DEFAULT 0,
+    delivered_at TIMESTAMP,
+    acknowledged_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX device_commands_device_id ON device_commands (device_id, status, not_before);
+CREATE INDEX device_commands_child_id ON device_commands (child_id, created_at);
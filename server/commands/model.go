@@ -0,0 +1,286 @@
+package commands
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxMessageLength is counted in characters, not bytes.
+const MaxMessageLength = 500
+
+// HistoryLimit is the number of the newest commands shown in the status view of a child.
+const HistoryLimit = 100
+
+var ErrInvalidCommandType = errors.New("command type must be one of lock_screen, pause_network, resume or show_message")
+var ErrMissingMessage = errors.New("show_message command requires a message")
+var ErrMessageTooLong = fmt.Errorf("message can not be longer than %d characters", MaxMessageLength)
+var ErrCommandWithThisIdDoesNotExist = errors.New("command with this id does not exist")
+var ErrCommandIsCancelled = errors.New("command has been cancelled")
+
+type Type string
+
+const (
+	TypeLockScreen   Type = "lock_screen"
+	TypePauseNetwork Type = "pause_network"
+	TypeResume       Type = "resume"
+	TypeShowMessage  Type = "show_message"
+)
+
+func (commandType Type) IsValid() bool {
+	switch commandType {
+	case TypeLockScreen, TypePauseNetwork, TypeResume, TypeShowMessage:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanAutoResume reports whether a resume command may be scheduled after the command.
+func (commandType Type) CanAutoResume() bool {
+	return commandType == TypeLockScreen || commandType == TypePauseNetwork
+}
+
+type Status string
+
+const (
+	// StatusPending commands have not been fetched by the device yet.
+	StatusPending Status = "pending"
+	// StatusDelivered commands were sent to the device at least once, they are sent again until acknowledged.
+	StatusDelivered    Status = "delivered"
+	StatusAcknowledged Status = "acknowledged"
+	// StatusCancelled is used for scheduled resumes replaced by a newer lock, pause or resume of the device.
+	StatusCancelled Status = "cancelled"
+)
+
+type Model struct {
+	Id       int
+	ChildId  int
+	DeviceId int
+	Type     Type
+	// Message is shown on the device, it is empty unless the type is show_message.
+	Message string
+	// NotBefore is the time the command becomes due, later than CreatedAt only for scheduled resumes.
+	NotBefore        time.Time
+	Status           Status
+	DeliveryAttempts int
+	// DeliveredAt is the first delivery, zero while pending.
+	DeliveredAt time.Time
+	// AcknowledgedAt is zero until the device confirms it executed the command.
+	AcknowledgedAt time.Time
+	CreatedAt      time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+const selectColumns = "id, child_id, device_id, type, message, not_before, status, delivery_attempts, delivered_at, acknowledged_at, created_at"
+
+func scanCommand(row interface{ Scan(dest ...any) error }) (*Model, error) {
+	command := &Model{}
+
+	var deliveredAt sql.NullTime
+	var acknowledgedAt sql.NullTime
+
+	err := row.Scan(
+		&command.Id,
+		&command.ChildId,
+		&command.DeviceId,
+		&command.Type,
+		&command.Message,
+		&command.NotBefore,
+		&command.Status,
+		&command.DeliveryAttempts,
+		&deliveredAt,
+		&acknowledgedAt,
+		&command.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	command.DeliveredAt = deliveredAt.Time
+	command.AcknowledgedAt = acknowledgedAt.Time
+
+	return command, nil
+}
+
+func findAll(db *sql.Tx, query string, args ...any) ([]Model, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM device_commands ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	commands := make([]Model, 0)
+
+	for rows.Next() {
+		command, err := scanCommand(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		commands = append(commands, *command)
+	}
+
+	return commands, nil
+}
+
+func FindOneById(db *sql.Tx, id int) (*Model, error) {
+	command, err := scanCommand(db.QueryRow("SELECT "+selectColumns+" FROM device_commands WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return command, nil
+}
+
+// FindAllByChildId returns the newest commands sent to devices of the child, newest first.
+func FindAllByChildId(db *sql.Tx, childId int) ([]Model, error) {
+	return findAll(db, "SELECT "+selectColumns+" FROM device_commands WHERE child_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2", childId, HistoryLimit)
+}
+
+// FindAllDueByDeviceId returns commands the device has to execute, oldest first so they are applied in order.
+func FindAllDueByDeviceId(db *sql.Tx, deviceId int, now time.Time) ([]Model, error) {
+	return findAll(
+		db,
+		"SELECT "+selectColumns+" FROM device_commands WHERE device_id = $1 AND status IN ($2, $3) AND not_before <= $4 ORDER BY not_before, id",
+		deviceId,
+		StatusPending,
+		StatusDelivered,
+		now.UTC(),
+	)
+}
+
+// FindAllBecomingDueBetween returns scheduled commands whose time came in (from, to], used to notify connected devices.
+func FindAllBecomingDueBetween(db *sql.Tx, from time.Time, to time.Time) ([]Model, error) {
+	return findAll(
+		db,
+		"SELECT "+selectColumns+" FROM device_commands WHERE status = $1 AND not_before > $2 AND not_before <= $3 ORDER BY not_before, id",
+		StatusPending,
+		from.UTC(),
+		to.UTC(),
+	)
+}
+
+func Create(db *sql.Tx, childId int, deviceId int, commandType Type, message string, notBefore time.Time) (int, error) {
+	if !commandType.IsValid() {
+		return 0, ErrInvalidCommandType
+	}
+
+	if commandType == TypeShowMessage && message == "" {
+		return 0, ErrMissingMessage
+	}
+
+	if commandType != TypeShowMessage {
+		message = ""
+	}
+
+	if utf8.RuneCountInString(message) > MaxMessageLength {
+		return 0, ErrMessageTooLong
+	}
+
+	exec, err := db.Exec(
+		"INSERT INTO device_commands (child_id, device_id, type, message, not_before, status) VALUES (?, ?, ?, ?, ?, ?);",
+		childId,
+		deviceId,
+		commandType,
+		message,
+		notBefore.UTC(),
+		StatusPending,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO device_commands ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// CancelScheduledResumes cancels resumes of the device that are not due yet, so an auto-resume of
+// an earlier pause can not cut short a lock, pause or resume the parent sent afterwards.
+func CancelScheduledResumes(db *sql.Tx, deviceId int, now time.Time) error {
+	_, err := db.Exec(
+		"UPDATE device_commands SET status = ? WHERE device_id = ? AND type = ? AND status = ? AND not_before > ?",
+		StatusCancelled,
+		deviceId,
+		TypeResume,
+		StatusPending,
+		now.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE device_commands ...': %w", err)
+	}
+
+	return nil
+}
+
+// MarkDelivered records another delivery attempt of the command, the status changes only for pending commands.
+func MarkDelivered(db *sql.Tx, id int, now time.Time) error {
+	_, err := db.Exec(
+		`UPDATE device_commands
+		SET delivery_attempts = delivery_attempts + 1,
+			delivered_at = COALESCE(delivered_at, ?),
+			status = CASE WHEN status = ? THEN ? ELSE status END
+		WHERE id = ?`,
+		now.UTC(),
+		StatusPending,
+		StatusDelivered,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE device_commands ...': %w", err)
+	}
+
+	return nil
+}
+
+// Acknowledge marks the command as executed by the device. Acknowledging it again is not an error
+// and keeps the time of the first acknowledgement, devices retry when they miss the response.
+func Acknowledge(db *sql.Tx, id int, now time.Time) error {
+	command, err := FindOneById(db, id)
+	if err != nil {
+		return err
+	}
+
+	if command == nil {
+		return ErrCommandWithThisIdDoesNotExist
+	}
+
+	switch command.Status {
+	case StatusAcknowledged:
+		return nil
+	case StatusCancelled:
+		return ErrCommandIsCancelled
+	}
+
+	_, err = db.Exec(
+		"UPDATE device_commands SET status = ?, acknowledged_at = ? WHERE id = ? AND status IN (?, ?)",
+		StatusAcknowledged,
+		now.UTC(),
+		id,
+		StatusPending,
+		StatusDelivered,
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE device_commands ...': %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package commands
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0011_commands": MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestCommands(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	now := time.Now()
+
+	t.Run("validates type and message", func(t *testing.T) {
+		_, err := Create(tx, 1, 1, Type("self_destruct"), "", now)
+		if !errors.Is(err, ErrInvalidCommandType) {
+			t.Errorf("Expected %v, received %v", ErrInvalidCommandType, err)
+		}
+
+		_, err = Create(tx, 1, 1, TypeShowMessage, "", now)
+		if !errors.Is(err, ErrMissingMessage) {
+			t.Errorf("Expected %v, received %v", ErrMissingMessage, err)
+		}
+
+		_, err = Create(tx, 1, 1, TypeShowMessage, strings.Repeat("ą", MaxMessageLength+1), now)
+		if !errors.Is(err, ErrMessageTooLong) {
+			t.Errorf("Expected %v, received %v", ErrMessageTooLong, err)
+		}
+	})
+
+	t.Run("drops message of commands other than show_message", func(t *testing.T) {
+		id, err := Create(tx, 1, 1, TypeLockScreen, "ignored", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		command, err := FindOneById(tx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if command.Message != "" || command.Status != StatusPending {
+			t.Errorf("Expected pending command without message, received %+v", command)
+		}
+	})
+
+	t.Run("keeps returning delivered commands until acknowledged", func(t *testing.T) {
+		id, err := Create(tx, 2, 20, TypePauseNetwork, "", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for attempt := 1; attempt <= 2; attempt++ {
+			due, err := FindAllDueByDeviceId(tx, 20, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(due) != 1 || due[0].Id != id {
+				t.Fatalf("Expected command %d to be due, received %+v", id, due)
+			}
+
+			err = MarkDelivered(tx, id, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		command, err := FindOneById(tx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if command.Status != StatusDelivered || command.DeliveryAttempts != 2 {
+			t.Errorf("Expected delivered command with 2 attempts, received %+v", command)
+		}
+
+		for range 2 {
+			err = Acknowledge(tx, id, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		due, err := FindAllDueByDeviceId(tx, 20, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(due) != 0 {
+			t.Errorf("Expected no due commands, received %+v", due)
+		}
+	})
+
+	t.Run("scheduled resume becomes due at its time and can be cancelled", func(t *testing.T) {
+		resumeAt := now.Add(30 * time.Minute)
+
+		id, err := Create(tx, 3, 30, TypeResume, "", resumeAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		due, err := FindAllDueByDeviceId(tx, 30, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(due) != 0 {
+			t.Errorf("Expected no due commands, received %+v", due)
+		}
+
+		becomingDue, err := FindAllBecomingDueBetween(tx, now, resumeAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(becomingDue) != 1 || becomingDue[0].Id != id {
+			t.Errorf("Expected command %d to become due, received %+v", id, becomingDue)
+		}
+
+		err = CancelScheduledResumes(tx, 30, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		due, err = FindAllDueByDeviceId(tx, 30, resumeAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(due) != 0 {
+			t.Errorf("Expected cancelled resume not to be due, received %+v", due)
+		}
+
+		err = Acknowledge(tx, id, now)
+		if !errors.Is(err, ErrCommandIsCancelled) {
+			t.Errorf("Expected %v, received %v", ErrCommandIsCancelled, err)
+		}
+	})
+
+	t.Run("returns error when acknowledging unknown command", func(t *testing.T) {
+		err := Acknowledge(tx, 9999, now)
+		if !errors.Is(err, ErrCommandWithThisIdDoesNotExist) {
+			t.Errorf("Expected %v, received %v", ErrCommandWithThisIdDoesNotExist, err)
+		}
+	})
+
+	t.Run("lists commands of the child newest first", func(t *testing.T) {
+		commands, err := FindAllByChildId(tx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(commands) != 1 || commands[0].Status != StatusAcknowledged || commands[0].AcknowledgedAt.IsZero() {
+			t.Errorf("Expected single acknowledged command, received %+v", commands)
+		}
+	})
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/commands"
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/push"
+	"github.com/go-chi/chi"
+)
+
+// MaxAutoResumeMinutes limits how long a lock or pause may last before it is lifted automatically.
+const MaxAutoResumeMinutes = 24 * 60
+
+var ErrInvalidCommandId = errors.New("invalid command id")
+var ErrCommandNotFound = errors.New("command not found")
+var ErrDeviceNotFound = errors.New("device not found")
+var ErrChildHasNoDevices = errors.New("child has no devices")
+var ErrInvalidAutoResume = fmt.Errorf("auto resume must be between 1 and %d minutes and is only possible for lock_screen and pause_network", MaxAutoResumeMinutes)
+
+type CommandResponse struct {
+	Id       int    `json:"id"`
+	ChildId  int    `json:"childId"`
+	DeviceId int    `json:"deviceId"`
+	Type     string `json:"type"`
+	Message  string `json:"message,omitempty"`
+	// NotBefore is later than createdAt only for automatic resumes.
+	NotBefore        time.Time `json:"notBefore"`
+	Status           string    `json:"status"`
+	DeliveryAttempts int       `json:"deliveryAttempts"`
+	// DeliveredAt and AcknowledgedAt are null until it happens.
+	DeliveredAt    *time.Time `json:"deliveredAt"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func newCommandResponse(command *commands.Model) CommandResponse {
+	response := CommandResponse{
+		Id:               command.Id,
+		ChildId:          command.ChildId,
+		DeviceId:         command.DeviceId,
+		Type:             string(command.Type),
+		Message:          command.Message,
+		NotBefore:        command.NotBefore,
+		Status:           string(command.Status),
+		DeliveryAttempts: command.DeliveryAttempts,
+		CreatedAt:        command.CreatedAt,
+	}
+
+	if !command.DeliveredAt.IsZero() {
+		response.DeliveredAt = &command.DeliveredAt
+	}
+
+	if !command.AcknowledgedAt.IsZero() {
+		response.AcknowledgedAt = &command.AcknowledgedAt
+	}
+
+	return response
+}
+
+func newCommandResponses(commandsList []commands.Model) []CommandResponse {
+	responses := make([]CommandResponse, 0, len(commandsList))
+
+	for i := range commandsList {
+		responses = append(responses, newCommandResponse(&commandsList[i]))
+	}
+
+	return responses
+}
+
+type CreateCommandRequestBody struct {
+	Type    string `json:"type" validate:"required,oneof=lock_screen pause_network resume show_message"`
+	Message string `json:"message" validate:"length=..500"`
+	// DeviceId 0 sends the command to every device of the child.
+	DeviceId int `json:"deviceId"`
+	// AutoResumeMinutes schedules a resume after a lock or pause, 0 keeps the device locked until resumed by hand.
+	AutoResumeMinutes int `json:"autoResumeMinutes"`
+}
+
+// HttpChildrenCommandsCreate queues the command for one device of the child or, when deviceId is omitted, for all of them.
+// Connected devices are notified right away, the others get the command the next time they fetch their commands.
+func HttpChildrenCommandsCreate(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreateCommandRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		commandType := commands.Type(requestBody.Type)
+
+		if requestBody.AutoResumeMinutes != 0 && (!commandType.CanAutoResume() || requestBody.AutoResumeMinutes < 0 || requestBody.AutoResumeMinutes > MaxAutoResumeMinutes) {
+			respondWith400(w, r, ErrInvalidAutoResume)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child, err := children.FindOneByIdAndOwnerUserId(tx, childId, authenticatedUserId(r))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child: %v", err)
+			return
+		}
+
+		if child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrChildNotFound)
+			return
+		}
+
+		childDevices, err := devices.FindAllByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find devices of the child: %v", err)
+			return
+		}
+
+		targetDeviceIds := make([]int, 0, len(childDevices))
+
+		for _, device := range childDevices {
+			if requestBody.DeviceId == 0 || requestBody.DeviceId == device.Id {
+				targetDeviceIds = append(targetDeviceIds, device.Id)
+			}
+		}
+
+		if len(targetDeviceIds) == 0 {
+			err = tx.Rollback()
+			if err != nil {
+				log.Printf("failed to rollback the transaction: %v", err)
+			}
+
+			if requestBody.DeviceId != 0 {
+				respondWith404(w, r, ErrDeviceNotFound)
+			} else {
+				respondWith400(w, r, ErrChildHasNoDevices)
+			}
+
+			return
+		}
+
+		now := time.Now()
+		createdIds := make([]int, 0, len(targetDeviceIds)*2)
+
+		for _, deviceId := range targetDeviceIds {
+			if commandType != commands.TypeShowMessage {
+				err = commands.CancelScheduledResumes(tx, deviceId, now)
+				if err != nil {
+					err = littlehelpers.IfErrJoin(err, tx.Rollback())
+					respondWith500(w, r, nil)
+					log.Printf("error occured while trying to cancel scheduled resumes: %v", err)
+					return
+				}
+			}
+
+			commandId, err := commands.Create(tx, child.Id, deviceId, commandType, strings.TrimSpace(requestBody.Message), now)
+			if errors.Is(err, commands.ErrMissingMessage) || errors.Is(err, commands.ErrMessageTooLong) {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith400(w, r, err)
+				return
+			} else if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to create command: %v", err)
+				return
+			}
+
+			createdIds = append(createdIds, commandId)
+
+			if requestBody.AutoResumeMinutes == 0 {
+				continue
+			}
+
+			resumeAt := now.Add(time.Duration(requestBody.AutoResumeMinutes) * time.Minute)
+
+			commandId, err = commands.Create(tx, child.Id, deviceId, commands.TypeResume, "", resumeAt)
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to schedule resume command: %v", err)
+				return
+			}
+
+			createdIds = append(createdIds, commandId)
+		}
+
+		created := make([]commands.Model, 0, len(createdIds))
+
+		for _, commandId := range createdIds {
+			command, err := commands.FindOneById(tx, commandId)
+			if err != nil || command == nil {
+				err = littlehelpers.IfErrJoin(fmt.Errorf("created command %d not found: %w", commandId, err), tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to find created command: %v", err)
+				return
+			}
+
+			created = append(created, *command)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		for i := range created {
+			// scheduled resumes are published by watchScheduledCommands once they are due
+			if created[i].NotBefore.After(now) {
+				continue
+			}
+
+			publishEvent(pushHub, created[i].ChildId, created[i].DeviceId, push.EventTypeCommand, newCommandResponse(&created[i]))
+		}
+
+		respondWithJson(w, r, http.StatusCreated, newCommandResponses(created))
+	}
+}
+
+// HttpChildrenCommandsList is the status view of the commands, it shows which devices received and executed them.
+func HttpChildrenCommandsList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child, err := children.FindOneByIdAndOwnerUserId(tx, childId, authenticatedUserId(r))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child: %v", err)
+			return
+		}
+
+		if child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrChildNotFound)
+			return
+		}
+
+		childCommands, err := commands.FindAllByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find commands of the child: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, newCommandResponses(childCommands))
+	}
+}
+
+// HttpDeviceCommandsList returns the due commands of the device, oldest first. A command is returned
+// on every call until the device acknowledges it, so a command lost with a dropped response is sent again.
+func HttpDeviceCommandsList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device := authenticatedDevice(r)
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		now := time.Now()
+
+		due, err := commands.FindAllDueByDeviceId(tx, device.Id, now)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find due commands: %v", err)
+			return
+		}
+
+		for _, command := range due {
+			err = commands.MarkDelivered(tx, command.Id, now)
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to mark command as delivered: %v", err)
+				return
+			}
+		}
+
+		due, err = commands.FindAllDueByDeviceId(tx, device.Id, now)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find due commands: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, newCommandResponses(due))
+	}
+}
+
+// HttpDeviceCommandsAcknowledge confirms the device executed the command, repeating it is harmless.
+func HttpDeviceCommandsAcknowledge(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		commandId, err := strconv.Atoi(chi.URLParam(r, "commandId"))
+		if err != nil || commandId <= 0 {
+			respondWith400(w, r, ErrInvalidCommandId)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		command, err := commands.FindOneById(tx, commandId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find command: %v", err)
+			return
+		}
+
+		if command == nil || command.DeviceId != authenticatedDevice(r).Id {
+			err = tx.Rollback()
+			if err != nil {
+				log.Printf("failed to rollback the transaction: %v", err)
+			}
+
+			respondWith404(w, r, ErrCommandNotFound)
+			return
+		}
+
+		err = commands.Acknowledge(tx, command.Id, time.Now())
+		if errors.Is(err, commands.ErrCommandIsCancelled) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to acknowledge command: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+// watchScheduledCommands notifies connected devices about automatic resumes when they become due.
+// Devices that are not connected get them the next time they fetch their commands.
+func watchScheduledCommands(ctx context.Context, db *sql.DB, pushHub *push.Hub, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	checkedUpTo := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			continue
+		}
+
+		due, err := commands.FindAllBecomingDueBetween(tx, checkedUpTo, now)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			log.Printf("error occured while trying to find scheduled commands: %v", err)
+			continue
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			continue
+		}
+
+		checkedUpTo = now
+
+		for i := range due {
+			publishEvent(pushHub, due[i].ChildId, due[i].DeviceId, push.EventTypeCommand, newCommandResponse(&due[i]))
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/commands"
+	"domanscy.group/parental-controls/server/push"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpCommands(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/children/{childId}/commands", HttpChildrenCommandsCreate(testingCfg, pushHub, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/children/{childId}/commands", HttpChildrenCommandsList(testingCfg, db))
+	router.With(AuthenticateDeviceToken(db)).Get("/device/commands", HttpDeviceCommandsList(testingCfg, db))
+	router.With(AuthenticateDeviceToken(db)).Post("/device/commands/{commandId}/ack", HttpDeviceCommandsAcknowledge(testingCfg, db))
+
+	sendParentRequest := func(method string, childId int, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, fmt.Sprintf("http://localhost:8080/children/%d/commands", childId), strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, family.userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	sendDeviceRequest := func(method string, path string, deviceToken string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+path, nil)
+		request.Header.Set("Authorization", "Device "+deviceToken)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	fetchDeviceCommands := func(t *testing.T) []CommandResponse {
+		recorder := sendDeviceRequest(http.MethodGet, "/device/commands", family.deviceToken)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response []CommandResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		return response
+	}
+
+	t.Run("queues pause with auto resume and notifies connected device", func(t *testing.T) {
+		subscription, _ := pushHub.Subscribe(family.childId, family.deviceId, 0)
+		defer subscription.Close()
+
+		recorder := sendParentRequest(http.MethodPost, family.childId, `{"type": "pause_network", "autoResumeMinutes": 30}`)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		var created []CommandResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+
+		if len(created) != 2 || created[0].Type != string(commands.TypePauseNetwork) || created[1].Type != string(commands.TypeResume) {
+			t.Fatalf("Expected pause followed by scheduled resume, received %+v", created)
+		}
+
+		if minutes := created[1].NotBefore.Sub(created[0].NotBefore).Minutes(); minutes != 30 {
+			t.Errorf("Expected resume after 30 minutes, received %f", minutes)
+		}
+
+		select {
+		case event := <-subscription.Events():
+			if event.Type != push.EventTypeCommand || event.DeviceId != family.deviceId {
+				t.Errorf("Expected command event for the device, received %+v", event)
+			}
+		default:
+			t.Fatal("Expected command event to be published")
+		}
+
+		select {
+		case event := <-subscription.Events():
+			t.Errorf("Expected scheduled resume not to be published yet, received %+v", event)
+		default:
+		}
+	})
+
+	t.Run("delivers command until acknowledged and accepts repeated acknowledgements", func(t *testing.T) {
+		first := fetchDeviceCommands(t)
+		second := fetchDeviceCommands(t)
+
+		if len(first) != 1 || len(second) != 1 || first[0].Id != second[0].Id {
+			t.Fatalf("Expected the same single due command twice, received %+v and %+v", first, second)
+		}
+
+		if second[0].Status != string(commands.StatusDelivered) || second[0].DeliveryAttempts != 2 {
+			t.Errorf("Expected delivered command with 2 attempts, received %+v", second[0])
+		}
+
+		for range 2 {
+			recorder := sendDeviceRequest(http.MethodPost, fmt.Sprintf("/device/commands/%d/ack", first[0].Id), family.deviceToken)
+			if recorder.Code != http.StatusNoContent {
+				t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+			}
+		}
+
+		if remaining := fetchDeviceCommands(t); len(remaining) != 0 {
+			t.Errorf("Expected no due commands, received %+v", remaining)
+		}
+	})
+
+	t.Run("resume cancels scheduled auto resume", func(t *testing.T) {
+		recorder := sendParentRequest(http.MethodPost, family.childId, fmt.Sprintf(`{"type": "resume", "deviceId": %d}`, family.deviceId))
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		recorder = sendParentRequest(http.MethodGet, family.childId, "")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var history []CommandResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &history))
+
+		statuses := make([]string, 0, len(history))
+		for _, command := range history {
+			statuses = append(statuses, command.Type+":"+command.Status)
+		}
+
+		expected := "resume:pending,resume:cancelled,pause_network:acknowledged"
+		if strings.Join(statuses, ",") != expected {
+			t.Errorf("Expected %s, received %s", expected, strings.Join(statuses, ","))
+		}
+	})
+
+	t.Run("returns 404 when acknowledging command of another device", func(t *testing.T) {
+		commandsOfDevice := fetchDeviceCommands(t)
+		if len(commandsOfDevice) != 1 {
+			t.Fatalf("Got %d, want %d", len(commandsOfDevice), 1)
+		}
+
+		recorder := sendDeviceRequest(http.MethodPost, fmt.Sprintf("/device/commands/%d/ack", commandsOfDevice[0].Id), stranger.deviceToken)
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("returns 400 for invalid commands", func(t *testing.T) {
+		for _, body := range []string{
+			`{"type": "self_destruct"}`,
+			`{"type": "show_message", "message": "   "}`,
+			`{"type": "show_message", "message": "hi", "autoResumeMinutes": 10}`,
+			fmt.Sprintf(`{"type": "lock_screen", "autoResumeMinutes": %d}`, MaxAutoResumeMinutes+1),
+		} {
+			recorder := sendParentRequest(http.MethodPost, family.childId, body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("returns 404 for child or device of someone else", func(t *testing.T) {
+		recorder := sendParentRequest(http.MethodPost, stranger.childId, `{"type": "lock_screen"}`)
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(http.MethodPost, family.childId, fmt.Sprintf(`{"type": "lock_screen", "deviceId": %d}`, stranger.deviceId))
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(http.MethodGet, stranger.childId, "")
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestWatchScheduledCommands(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	tx, err := db.Begin()
+	doTFatalIfErr(t, err)
+
+	resumeId, err := commands.Create(tx, family.childId, family.deviceId, commands.TypeResume, "", time.Now().Add(50*time.Millisecond))
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, tx.Commit())
+
+	subscription, _ := pushHub.Subscribe(family.childId, family.deviceId, 0)
+	defer subscription.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go watchScheduledCommands(ctx, db, pushHub, 10*time.Millisecond)
+
+	select {
+	case event := <-subscription.Events():
+		var command CommandResponse
+		doTFatalIfErr(t, json.Unmarshal(event.Data, &command))
+
+		if event.Type != push.EventTypeCommand || command.Id != resumeId {
+			t.Errorf("Expected command event of resume %d, received %+v", resumeId, event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for scheduled resume")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
 )
 
 var ErrMigrationsTableAlreadyExists = errors.New("migrations table already exists")
@@ -73,6 +74,8 @@ func confirmMigration(db *sql.DB, migrationName string) error {
 	return nil
 }
 
+// Migrate executes migrations that have not been confirmed yet, sorted by their names,
+// so a migration altering a table can rely on the one creating it if it has a lower number.
 func Migrate(db *sql.DB, migrations map[string]string) error {
 	migrationsTableExists, err := DoesTableExists(db, "migrations")
 	if err != nil {
@@ -86,7 +89,16 @@ func Migrate(db *sql.DB, migrations map[string]string) error {
 		}
 	}
 
-	for migrationName, sqlQuery := range migrations {
+	migrationNames := make([]string, 0, len(migrations))
+	for migrationName := range migrations {
+		migrationNames = append(migrationNames, migrationName)
+	}
+
+	sort.Strings(migrationNames)
+
+	for _, migrationName := range migrationNames {
+		sqlQuery := migrations[migrationName]
+
 		migrationConfirmed, err := isMigrationConfirmed(db, migrationName)
 		if err != nil {
 			return fmt.Errorf("error occured while trying to obtain information about confirmation of migration execution: %w", err)
@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"testing"
 )
@@ -290,4 +291,22 @@ func TestMigrate(t *testing.T) {
 
 		assertCompleted(t, db)
 	})
+	t.Run("executes migrations in order of their names", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		orderedMigrations := map[string]string{}
+		for i := 1; i <= 20; i++ {
+			orderedMigrations[fmt.Sprintf("%04d_alter", i)] = fmt.Sprintf("ALTER TABLE table1 ADD COLUMN column%d INTEGER;", i)
+		}
+
+		orderedMigrations["0000_create"] = "CREATE TABLE table1 (id INTEGER PRIMARY KEY);"
+
+		err = Migrate(db, orderedMigrations)
+		if err != nil {
+			t.Errorf("Expected nil, received: %v", err)
+		}
+	})
 }
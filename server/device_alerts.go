@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/heartbeats"
+)
+
+const deviceAlertTimeFormat = "02.01.2006 15:04"
+
+//go:embed mail_templates/device_alert.gohtml
+var deviceAlertEmailBody string
+var deviceAlertEmailTemplate = newMailTemplate(deviceAlertEmailBody)
+
+type deviceAlertTamperEvent struct {
+	OccurredAt  string
+	Description string
+	Detail      string
+}
+
+// pendingDeviceAlert collects everything the parents were not told about a single device yet.
+type pendingDeviceAlert struct {
+	missing      *heartbeats.Model
+	tamperEvents []heartbeats.TamperEvent
+}
+
+// sendDeviceAlert emails the parent of the device about the pending alert and marks it as sent,
+// an email that could not be sent is retried on the next run.
+func sendDeviceAlert(ctx context.Context, cfg *ServerConfig, db *sql.DB, deviceId int, alert pendingDeviceAlert, now time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	device, err := devices.FindOneById(tx, deviceId)
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	if device == nil {
+		return littlehelpers.IfErrJoin(fmt.Errorf("device %d not found", deviceId), tx.Rollback())
+	}
+
+	child, parent, err := findChildAndParent(tx, device.ChildId)
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	parentLanguage := userLanguage(parent)
+
+	missingSince := ""
+	if alert.missing != nil {
+		missingSince = alert.missing.ReceivedAt.In(cfg.ReportsLocation).Format(deviceAlertTimeFormat)
+
+		err = heartbeats.MarkMissingAlerted(tx, deviceId, now)
+		if err != nil {
+			return littlehelpers.IfErrJoin(err, tx.Rollback())
+		}
+	}
+
+	tamperEvents := make([]deviceAlertTamperEvent, 0, len(alert.tamperEvents))
+	tamperEventIds := make([]int, 0, len(alert.tamperEvents))
+
+	for _, event := range alert.tamperEvents {
+		tamperEvents = append(tamperEvents, deviceAlertTamperEvent{
+			OccurredAt:  event.OccurredAt.In(cfg.ReportsLocation).Format(deviceAlertTimeFormat),
+			Description: mailCatalog.Text(parentLanguage, "tamper."+string(event.Type)),
+			Detail:      event.Detail,
+		})
+		tamperEventIds = append(tamperEventIds, event.Id)
+	}
+
+	err = heartbeats.MarkTamperEventsAlerted(tx, tamperEventIds, now)
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	emailBody, err := executeMailTemplate(deviceAlertEmailTemplate, parentLanguage, struct {
+		DeviceName   string
+		ChildName    string
+		MissingSince string
+		TamperEvents []deviceAlertTamperEvent
+	}{
+		DeviceName:   device.Name,
+		ChildName:    child.Name,
+		MissingSince: missingSince,
+		TamperEvents: tamperEvents,
+	})
+	if err != nil {
+		return littlehelpers.IfErrJoin(fmt.Errorf("failed to construct email template: %w", err), tx.Rollback())
+	}
+
+	err = sendMail(
+		cfg.SmtpAddress,
+		cfg.SmtpPort,
+		cfg.EmailFromAddress,
+		parent.Email,
+		mailCatalog.Text(parentLanguage, "device_alert.subject", device.Name),
+		emailBody,
+	)
+	if err != nil {
+		return littlehelpers.IfErrJoin(fmt.Errorf("failed to send device alert to user %d: %w", parent.Id, err), tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+// sendDeviceAlerts emails parents about devices that stopped sending heartbeats and about tamper events,
+// every device gets at most one email per run.
+func sendDeviceAlerts(ctx context.Context, cfg *ServerConfig, db *sql.DB, now time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	missing, err := heartbeats.FindAllMissing(tx, now.Add(-cfg.HeartbeatTimeout))
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	tamperEvents, err := heartbeats.FindAllNotAlertedTamperEvents(tx)
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	alerts := make(map[int]pendingDeviceAlert)
+
+	for i := range missing {
+		alert := alerts[missing[i].DeviceId]
+		alert.missing = &missing[i]
+		alerts[missing[i].DeviceId] = alert
+	}
+
+	for _, event := range tamperEvents {
+		alert := alerts[event.DeviceId]
+		alert.tamperEvents = append(alert.tamperEvents, event)
+		alerts[event.DeviceId] = alert
+	}
+
+	deviceIds := make([]int, 0, len(alerts))
+	for deviceId := range alerts {
+		deviceIds = append(deviceIds, deviceId)
+	}
+
+	sort.Ints(deviceIds)
+
+	var errs error
+
+	for _, deviceId := range deviceIds {
+		// one parent with a broken address must not stop the alerts of the others
+		errs = errors.Join(errs, sendDeviceAlert(ctx, cfg, db, deviceId, alerts[deviceId], now))
+	}
+
+	return errs
+}
@@ -0,0 +1,8 @@
+CREATE TABLE devices (
+    id INTEGER PRIMARY KEY,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    token VARCHAR NOT NULL UNIQUE,
+    ip_address VARCHAR UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+ALTER TABLE devices ADD COLUMN doh_token VARCHAR NOT NULL DEFAULT '';
+UPDATE devices SET doh_token = lower(hex(randomblob(32)));
+CREATE UNIQUE INDEX devices_doh_token ON devices (doh_token);
@@ -0,0 +1,2 @@
+ALTER TABLE devices ADD COLUMN heartbeat_key VARCHAR NOT NULL DEFAULT '';
+UPDATE devices SET heartbeat_key = lower(hex(randomblob(32)));
@@ -0,0 +1,209 @@
+package devices
+
+import (
+	"crypto/rand"
+	"database/sql"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrNameCannotBeEmpty = errors.New("device name can not be empty")
+var ErrDeviceWithThisIdDoesNotExist = errors.New("device with this id does not exist")
+var ErrIpAddressAlreadyAssigned = errors.New("ip address is already assigned to another device")
+
+type Model struct {
+	Id      int
+	ChildId int
+	Name    string
+	// Token authenticates the agent of the device to the api.
+	Token string
+	// DohToken identifies the device in its DoH url, which the child may find in the settings of the browser, so it
+	// only resolves names and never authenticates the agent.
+	DohToken string
+	// IpAddress is empty when the device can not be recognised by its address (e.g. it is behind a shared NAT).
+	IpAddress string
+	// HeartbeatKey signs the heartbeats of the agent, unlike DohToken it is only given to the agent.
+	HeartbeatKey string
+	CreatedAt    time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+//go:embed migration_heartbeat_key.sql
+var HeartbeatKeyMigrationFile string
+
+//go:embed migration_doh_token.sql
+var DohTokenMigrationFile string
+
+const selectColumns = "id, child_id, name, token, doh_token, ip_address, heartbeat_key, created_at"
+
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("an unknown error occured while trying to generate random bytes using crypto/rand.Read: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func scanDevice(row interface{ Scan(dest ...any) error }) (*Model, error) {
+	device := &Model{}
+
+	var ipAddress sql.NullString
+
+	err := row.Scan(&device.Id, &device.ChildId, &device.Name, &device.Token, &device.DohToken, &ipAddress, &device.HeartbeatKey, &device.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	device.IpAddress = ipAddress.String
+
+	return device, nil
+}
+
+func findOne(db *sql.Tx, query string, args ...interface{}) (*Model, error) {
+	device, err := scanDevice(db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return device, nil
+}
+
+func FindOneById(db *sql.Tx, id int) (*Model, error) {
+	return findOne(db, "SELECT "+selectColumns+" FROM devices WHERE id = $1", id)
+}
+
+func FindOneByToken(db *sql.Tx, token string) (*Model, error) {
+	return findOne(db, "SELECT "+selectColumns+" FROM devices WHERE token = $1", token)
+}
+
+func FindOneByDohToken(db *sql.Tx, dohToken string) (*Model, error) {
+	return findOne(db, "SELECT "+selectColumns+" FROM devices WHERE doh_token = $1", dohToken)
+}
+
+func FindOneByIpAddress(db *sql.Tx, ipAddress string) (*Model, error) {
+	return findOne(db, "SELECT "+selectColumns+" FROM devices WHERE ip_address = $1", ipAddress)
+}
+
+func FindAllByChildId(db *sql.Tx, childId int) ([]Model, error) {
+	rows, err := db.Query("SELECT "+selectColumns+" FROM devices WHERE child_id = $1 ORDER BY id", childId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM devices ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	devices := make([]Model, 0)
+
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		devices = append(devices, *device)
+	}
+
+	return devices, nil
+}
+
+// Create inserts a new device with a freshly generated token, DoH token and heartbeat key and returns its id together with the token.
+func Create(db *sql.Tx, childId int, name string) (int, string, error) {
+	if name == "" {
+		return 0, "", ErrNameCannotBeEmpty
+	}
+
+	token, err := GenerateToken()
+	if err != nil {
+		return 0, "", err
+	}
+
+	dohToken, err := GenerateToken()
+	if err != nil {
+		return 0, "", err
+	}
+
+	heartbeatKey, err := GenerateToken()
+	if err != nil {
+		return 0, "", err
+	}
+
+	exec, err := db.Exec("INSERT INTO devices (child_id, name, token, doh_token, heartbeat_key) VALUES (?, ?, ?, ?, ?);", childId, name, token, dohToken, heartbeatKey)
+	if err != nil {
+		return 0, "", fmt.Errorf("an error occured while trying to execute query 'INSERT INTO devices ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, "", fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), token, nil
+}
+
+// RotateHeartbeatKey gives the device a new heartbeat key and returns it, heartbeats signed with the previous one are rejected.
+func RotateHeartbeatKey(db *sql.Tx, id int) (string, error) {
+	heartbeatKey, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	executed, err := db.Exec("UPDATE devices SET heartbeat_key = ? WHERE id = ?", heartbeatKey, id)
+	if err != nil {
+		return "", fmt.Errorf("an error occured while trying to execute query 'UPDATE devices ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	if affectedRows == 0 {
+		return "", ErrDeviceWithThisIdDoesNotExist
+	}
+
+	return heartbeatKey, nil
+}
+
+// UpdateIpAddress assigns the address the dns resolver recognises the device by, empty string clears it.
+func UpdateIpAddress(db *sql.Tx, id int, ipAddress string) error {
+	var value sql.NullString
+	if ipAddress != "" {
+		value = sql.NullString{String: ipAddress, Valid: true}
+	}
+
+	executed, err := db.Exec("UPDATE devices SET ip_address = ? WHERE id = ?", value, id)
+	if err != nil {
+		if err.Error() == "UNIQUE constraint failed: devices.ip_address" {
+			return ErrIpAddressAlreadyAssigned
+		}
+
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE devices ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	if affectedRows == 0 {
+		return ErrDeviceWithThisIdDoesNotExist
+	}
+
+	return nil
+}
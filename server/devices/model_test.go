@@ -0,0 +1,204 @@
+package devices
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"domanscy.group/parental-controls/server/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0004_devices":               MigrationFile,
+		"0018_devices_heartbeat_key": HeartbeatKeyMigrationFile,
+		"0028_devices_doh_token":     DohTokenMigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestCreate(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	firstId, firstToken, err := Create(tx, 1, "Laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, secondToken, err := Create(tx, 1, "Phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if firstToken == secondToken {
+		t.Errorf("Expected two different tokens, received the same one twice: %s", firstToken)
+	}
+
+	device, err := FindOneByToken(tx, firstToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if device == nil || device.Id != firstId || device.Name != "Laptop" || device.IpAddress != "" {
+		t.Fatalf("Expected device %d named Laptop without ip address, received %+v", firstId, device)
+	}
+
+	if len(device.HeartbeatKey) != 64 || device.HeartbeatKey == device.Token {
+		t.Errorf("Expected a heartbeat key other than the token, received %q", device.HeartbeatKey)
+	}
+
+	if len(device.DohToken) != 64 || device.DohToken == device.Token || device.DohToken == device.HeartbeatKey {
+		t.Errorf("Expected a DoH token other than the token and the heartbeat key, received %q", device.DohToken)
+	}
+
+	found, err := FindOneByDohToken(tx, device.DohToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found == nil || found.Id != firstId {
+		t.Errorf("Expected device %d by its DoH token, received %+v", firstId, found)
+	}
+
+	found, err = FindOneByDohToken(tx, firstToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found != nil {
+		t.Errorf("Expected no device by the token of the agent, received %+v", found)
+	}
+
+	_, _, err = Create(tx, 1, "")
+	if !errors.Is(err, ErrNameCannotBeEmpty) {
+		t.Errorf("Expected %v, received %v", ErrNameCannotBeEmpty, err)
+	}
+}
+
+func TestUpdateIpAddress(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	firstId, _, err := Create(tx, 1, "Laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secondId, _, err := Create(tx, 1, "Phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = UpdateIpAddress(tx, firstId, "10.0.0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = UpdateIpAddress(tx, secondId, "10.0.0.5")
+	if !errors.Is(err, ErrIpAddressAlreadyAssigned) {
+		t.Errorf("Expected %v, received %v", ErrIpAddressAlreadyAssigned, err)
+	}
+
+	// many devices without an address must not collide with each other
+	err = UpdateIpAddress(tx, firstId, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device, err := FindOneByIpAddress(tx, "10.0.0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if device != nil {
+		t.Errorf("Expected nil, received %+v", device)
+	}
+
+	err = UpdateIpAddress(tx, 999, "10.0.0.6")
+	if !errors.Is(err, ErrDeviceWithThisIdDoesNotExist) {
+		t.Errorf("Expected %v, received %v", ErrDeviceWithThisIdDoesNotExist, err)
+	}
+}
+
+func TestRotateHeartbeatKey(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	id, _, err := Create(tx, 1, "Laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := FindOneById(tx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	heartbeatKey, err := RotateHeartbeatKey(tx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := FindOneById(tx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if heartbeatKey == "" || heartbeatKey == before.HeartbeatKey || after.HeartbeatKey != heartbeatKey {
+		t.Errorf("Expected a new heartbeat key, received %q, was %q, stored %q", heartbeatKey, before.HeartbeatKey, after.HeartbeatKey)
+	}
+
+	_, err = RotateHeartbeatKey(tx, 999)
+	if !errors.Is(err, ErrDeviceWithThisIdDoesNotExist) {
+		t.Errorf("Expected %v, received %v", ErrDeviceWithThisIdDoesNotExist, err)
+	}
+}
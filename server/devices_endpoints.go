@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/devices"
+	"github.com/go-chi/chi"
+)
+
+var ErrInvalidChildId = errors.New("invalid child id")
+var ErrChildNotFound = errors.New("child not found")
+var ErrInvalidIpAddress = errors.New("invalid ip address")
+
+type DeviceResponse struct {
+	Id      int    `json:"id"`
+	ChildId int    `json:"childId"`
+	Name    string `json:"name"`
+	DohUrl  string `json:"dohUrl"`
+	// Token is shown once, the agent authenticates with it. Unlike the DoH url it is never entered in a browser.
+	Token string `json:"token"`
+	// HeartbeatKey is shown once, the agent signs its heartbeats with it. A new one can be had from
+	// POST /devices/{deviceId}/heartbeat_key.
+	HeartbeatKey string `json:"heartbeatKey"`
+}
+
+func dohUrlForDevice(cfg *ServerConfig, dohToken string) string {
+	return fmt.Sprintf("%s/dns-query/%s", cfg.AppUrl, dohToken)
+}
+
+func parseChildIdAndHandleErrorIfInvalid(w http.ResponseWriter, r *http.Request) (int, error) {
+	childId, err := strconv.Atoi(chi.URLParam(r, "childId"))
+	if err != nil || childId <= 0 {
+		respondWith400(w, r, ErrInvalidChildId)
+		return 0, ErrInvalidChildId
+	}
+
+	return childId, nil
+}
+
+type CreateDeviceRequestBody struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func HttpDevicesCreate(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreateDeviceRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child, err := children.FindOneByIdAndOwnerUserId(tx, childId, authenticatedUserId(r))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child: %v", err)
+			return
+		}
+
+		if child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrChildNotFound)
+			return
+		}
+
+		deviceId, token, err := devices.Create(tx, child.Id, requestBody.Name)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create device: %v", err)
+			return
+		}
+
+		device, err := devices.FindOneById(tx, deviceId)
+		if err != nil || device == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find created device: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusCreated, DeviceResponse{
+			Id:           deviceId,
+			ChildId:      child.Id,
+			Name:         requestBody.Name,
+			DohUrl:       dohUrlForDevice(cfg, device.DohToken),
+			Token:        token,
+			HeartbeatKey: device.HeartbeatKey,
+		})
+	}
+}
+
+// findOwnedDeviceAndHandleError finds the device of a child of the authenticated parent, it responds and returns nil if
+// there is none.
+func findOwnedDeviceAndHandleError(w http.ResponseWriter, r *http.Request, tx *sql.Tx, deviceId int) *devices.Model {
+	device, err := devices.FindOneById(tx, deviceId)
+	if err != nil {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith500(w, r, nil)
+		log.Printf("error occured while trying to find device: %v", err)
+		return nil
+	}
+
+	var child *children.Model
+	if device != nil {
+		child, err = children.FindOneByIdAndOwnerUserId(tx, device.ChildId, authenticatedUserId(r))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child: %v", err)
+			return nil
+		}
+	}
+
+	if child == nil {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith404(w, r, ErrDeviceNotFound)
+		return nil
+	}
+
+	return device
+}
+
+type UpdateIpAddressRequestBody struct {
+	// IpAddress is the address the dns resolver recognises the device by, an empty one clears it.
+	IpAddress string `json:"ipAddress"`
+}
+
+// HttpDevicesUpdateIpAddress maps the address plain dns queries come from to the device, so they are answered with the
+// policy of its child. Devices behind a shared NAT can not be told apart and have to use their DoH url instead.
+func HttpDevicesUpdateIpAddress(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deviceId, err := strconv.Atoi(chi.URLParam(r, "deviceId"))
+		if err != nil || deviceId <= 0 {
+			respondWith400(w, r, ErrInvalidDeviceId)
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[UpdateIpAddressRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		ipAddress := ""
+		if requestBody.IpAddress != "" {
+			parsed := net.ParseIP(requestBody.IpAddress)
+			if parsed == nil {
+				respondWith400(w, r, ErrInvalidIpAddress)
+				return
+			}
+
+			ipAddress = parsed.String()
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		device := findOwnedDeviceAndHandleError(w, r, tx, deviceId)
+		if device == nil {
+			return
+		}
+
+		err = devices.UpdateIpAddress(tx, device.Id, ipAddress)
+		if errors.Is(err, devices.ErrIpAddressAlreadyAssigned) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to update ip address: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/users"
+	"domanscy.group/parental-controls/server/validation"
+	"github.com/go-chi/chi"
+)
+
+type testFamily struct {
+	userId      int
+	householdId int
+	childId     int
+	deviceId    int
+	deviceToken string
+	dohToken    string
+}
+
+// createTestFamily creates a parent with one household, one child and one device of that child.
+func createTestFamily(t testing.TB, db *sql.DB, email string) testFamily {
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	family := testFamily{}
+
+	family.userId, err = users.Create(tx, email)
+	doTFatalIfErr(t, err)
+
+	family.householdId, err = households.Create(tx, family.userId, "Home")
+	doTFatalIfErr(t, err)
+
+	family.childId, err = children.Create(tx, family.householdId, "Adam")
+	doTFatalIfErr(t, err)
+
+	family.deviceId, family.deviceToken, err = devices.Create(tx, family.childId, "Laptop")
+	doTFatalIfErr(t, err)
+
+	device, err := devices.FindOneById(tx, family.deviceId)
+	doTFatalIfErr(t, err)
+
+	family.dohToken = device.DohToken
+
+	doTFatalIfErr(t, tx.Commit())
+
+	return family
+}
+
+func bearerHeaderForUser(t *testing.T, userId int) string {
+	bearer, err := CreateBearerTokenForUser(testingCfg.BearerTokenPrivateKey, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "Bearer " + string(bearer)
+}
+
+func TestHttpDevicesCreate(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/children/{childId}/devices", HttpDevicesCreate(testingCfg, db))
+
+	sendRequest := func(childId int, authorization string, body string) *httptest.ResponseRecorder {
+		request, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:8080/children/%d/devices", childId), strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request.Header.Set("Authorization", authorization)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("creates device and returns its DoH url", func(t *testing.T) {
+		recorder := sendRequest(family.childId, bearerHeaderForUser(t, family.userId), `{"name": "Phone"}`)
+
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		var response DeviceResponse
+		err := json.Unmarshal(recorder.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		device, err := devices.FindOneById(tx, response.Id)
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		if device == nil || device.ChildId != family.childId || device.Name != "Phone" {
+			t.Fatalf("Expected device named Phone of child %d, received %+v", family.childId, device)
+		}
+
+		expectedUrl := testingCfg.AppUrl + "/dns-query/" + device.DohToken
+		if response.DohUrl != expectedUrl {
+			t.Errorf("Expected DoH url %s, received %s", expectedUrl, response.DohUrl)
+		}
+
+		if response.Token != device.Token || strings.Contains(response.DohUrl, device.Token) {
+			t.Errorf("Expected token %s apart from the DoH url, received %s and %s", device.Token, response.Token, response.DohUrl)
+		}
+
+		if response.HeartbeatKey == "" || response.HeartbeatKey != device.HeartbeatKey {
+			t.Errorf("Expected heartbeat key %s, received %s", device.HeartbeatKey, response.HeartbeatKey)
+		}
+	})
+
+	t.Run("returns 401 without bearer token", func(t *testing.T) {
+		recorder := sendRequest(family.childId, "", `{"name": "Phone"}`)
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusUnauthorized)
+		}
+
+		recorder = sendRequest(family.childId, "Bearer notatoken", `{"name": "Phone"}`)
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("returns 404 if child belongs to someone else", func(t *testing.T) {
+		recorder := sendRequest(stranger.childId, bearerHeaderForUser(t, family.userId), `{"name": "Phone"}`)
+
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("returns 400 if name is empty", func(t *testing.T) {
+		recorder := sendRequest(family.childId, bearerHeaderForUser(t, family.userId), `{"name": ""}`)
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		want := validation.FieldError{Field: "name", Err: validation.ErrRequired}.Error()
+		if recorder.Body.String() != want {
+			t.Errorf("Got %s, want %s", recorder.Body.String(), want)
+		}
+	})
+}
+
+func TestHttpDevicesUpdateIpAddress(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Put("/devices/{deviceId}/ip_address", HttpDevicesUpdateIpAddress(testingCfg, db))
+
+	sendRequest := func(userId int, deviceId int, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:8080/devices/%d/ip_address", deviceId), strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	findIpAddress := func(t *testing.T, deviceId int) string {
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		device, err := devices.FindOneById(tx, deviceId)
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		return device.IpAddress
+	}
+
+	t.Run("assigns the ip address to the device", func(t *testing.T) {
+		recorder := sendRequest(family.userId, family.deviceId, `{"ipAddress": "192.168.1.20"}`)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		if ipAddress := findIpAddress(t, family.deviceId); ipAddress != "192.168.1.20" {
+			t.Errorf("Got %q, want 192.168.1.20", ipAddress)
+		}
+	})
+
+	t.Run("rejects invalid and taken addresses", func(t *testing.T) {
+		recorder := sendRequest(family.userId, family.deviceId, `{"ipAddress": "192.168.1"}`)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		recorder = sendRequest(stranger.userId, stranger.deviceId, `{"ipAddress": "192.168.1.20"}`)
+		if recorder.Code != http.StatusConflict {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("returns 404 for devices of other parents", func(t *testing.T) {
+		recorder := sendRequest(stranger.userId, family.deviceId, `{"ipAddress": ""}`)
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		if ipAddress := findIpAddress(t, family.deviceId); ipAddress != "192.168.1.20" {
+			t.Errorf("Got %q, want 192.168.1.20", ipAddress)
+		}
+	})
+
+	t.Run("clears the ip address", func(t *testing.T) {
+		recorder := sendRequest(family.userId, family.deviceId, `{"ipAddress": ""}`)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		if ipAddress := findIpAddress(t, family.deviceId); ipAddress != "" {
+			t.Errorf("Got %q, want no address", ipAddress)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/reports"
+	"domanscy.group/parental-controls/server/scheduler"
+	"domanscy.group/parental-controls/server/users"
+)
+
+const digestDateFormat = "02.01.2006"
+
+//go:embed mail_templates/weekly_digest.gohtml
+var weeklyDigestEmailBody string
+var weeklyDigestEmailTemplate = newMailTemplate(weeklyDigestEmailBody)
+
+type digestDay struct {
+	Date            string
+	ScreenTime      string
+	BlockedAttempts int
+}
+
+type digestNamedDuration struct {
+	Name     string
+	Duration string
+}
+
+type digestChild struct {
+	Name            string
+	ScreenTime      string
+	BlockedAttempts int
+	TimeRequests    int
+	GrantedMinutes  int
+	Days            []digestDay
+	TopApps         []digestNamedDuration
+	TopDomains      []reports.NamedCount
+}
+
+// formatScreenTime formats the duration the way it is read in the digest, e.g. "2 godz. 5 min".
+func formatScreenTime(language i18n.Language, duration time.Duration) string {
+	minutes := int(duration.Round(time.Minute).Minutes())
+	if minutes < 60 {
+		return mailCatalog.Text(language, "weekly_digest.minutes", minutes)
+	}
+
+	return mailCatalog.Text(language, "weekly_digest.hours", minutes/60, minutes%60)
+}
+
+// lastCompletedWeekStart returns the Monday midnight starting the last full week before now.
+func lastCompletedWeekStart(now time.Time, location *time.Location) time.Time {
+	today := reports.StartOfDay(now, location)
+	daysSinceMonday := (int(today.Weekday()) + 6) % 7
+
+	return today.AddDate(0, 0, -daysSinceMonday-7)
+}
+
+func newDigestChild(language i18n.Language, child *children.Model, days []reports.DailyStats) (digestChild, error) {
+	summary, err := reports.Summarize(days)
+	if err != nil {
+		return digestChild{}, err
+	}
+
+	digest := digestChild{
+		Name:            child.Name,
+		ScreenTime:      formatScreenTime(language, summary.ScreenTime),
+		BlockedAttempts: summary.BlockedAttempts,
+		TimeRequests:    summary.TimeRequests,
+		GrantedMinutes:  summary.GrantedMinutes,
+		Days:            make([]digestDay, 0, len(days)),
+		TopApps:         make([]digestNamedDuration, 0, len(summary.TopApps)),
+		TopDomains:      summary.TopDomains,
+	}
+
+	for _, day := range days {
+		digest.Days = append(digest.Days, digestDay{
+			Date:            day.Date.Format(digestDateFormat),
+			ScreenTime:      formatScreenTime(language, day.ScreenTime),
+			BlockedAttempts: day.BlockedAttempts,
+		})
+	}
+
+	for _, app := range summary.TopApps {
+		digest.TopApps = append(digest.TopApps, digestNamedDuration{Name: app.Name, Duration: formatScreenTime(language, app.Duration)})
+	}
+
+	return digest, nil
+}
+
+// sendWeeklyDigest emails the parent a summary of the week of every child in their households.
+// Parents without children and parents who already got the digest of the week are skipped.
+func sendWeeklyDigest(cfg *ServerConfig, db *sql.DB, userId int, weekStart time.Time, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	sent, err := reports.WasDigestSent(tx, userId, weekStart)
+	if err != nil || sent {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	parent, err := users.FindOneById(tx, userId)
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	if parent == nil {
+		return littlehelpers.IfErrJoin(fmt.Errorf("user %d not found", userId), tx.Rollback())
+	}
+
+	parentLanguage := userLanguage(parent)
+
+	parentHouseholds, err := households.FindAllByOwnerUserId(tx, userId)
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	digestChildren := make([]digestChild, 0)
+
+	for _, household := range parentHouseholds {
+		householdChildren, err := children.FindAllByHouseholdId(tx, household.Id)
+		if err != nil {
+			return littlehelpers.IfErrJoin(err, tx.Rollback())
+		}
+
+		for i := range householdChildren {
+			days, err := reports.ComputeDaily(tx, householdChildren[i].Id, weekStart, 7, cfg.ReportsLocation, now)
+			if err != nil {
+				return littlehelpers.IfErrJoin(err, tx.Rollback())
+			}
+
+			digest, err := newDigestChild(parentLanguage, &householdChildren[i], days)
+			if err != nil {
+				return littlehelpers.IfErrJoin(err, tx.Rollback())
+			}
+
+			digestChildren = append(digestChildren, digest)
+		}
+	}
+
+	if len(digestChildren) == 0 {
+		return tx.Rollback()
+	}
+
+	weekEnd := weekStart.AddDate(0, 0, 6)
+
+	emailBody, err := executeMailTemplate(weeklyDigestEmailTemplate, parentLanguage, struct {
+		From     string
+		To       string
+		Children []digestChild
+	}{
+		From:     weekStart.Format(digestDateFormat),
+		To:       weekEnd.Format(digestDateFormat),
+		Children: digestChildren,
+	})
+	if err != nil {
+		return littlehelpers.IfErrJoin(fmt.Errorf("failed to construct email template: %w", err), tx.Rollback())
+	}
+
+	err = reports.RecordDigestSent(tx, userId, weekStart, now)
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	err = sendMail(
+		cfg.SmtpAddress,
+		cfg.SmtpPort,
+		cfg.EmailFromAddress,
+		parent.Email,
+		mailCatalog.Text(parentLanguage, "weekly_digest.subject", weekStart.Format(digestDateFormat), weekEnd.Format(digestDateFormat)),
+		emailBody,
+	)
+	if err != nil {
+		return littlehelpers.IfErrJoin(fmt.Errorf("failed to send weekly digest to user %d: %w", userId, err), tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+// sendWeeklyDigests sends the digest of the last full week to every parent who did not get it yet,
+// so running it again after a failure or a restart only sends the missing ones.
+func sendWeeklyDigests(cfg *ServerConfig, db *sql.DB, now time.Time) error {
+	weekStart := lastCompletedWeekStart(now, cfg.ReportsLocation)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	ownerUserIds, err := households.FindAllOwnerUserIds(tx)
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	var errs error
+
+	for _, userId := range ownerUserIds {
+		// one parent with a broken address must not stop the digest of the others
+		errs = errors.Join(errs, sendWeeklyDigest(cfg, db, userId, weekStart, now))
+	}
+
+	return errs
+}
+
+func newWeeklyDigestJob(cfg *ServerConfig, db *sql.DB) scheduler.Job {
+	return scheduler.Job{
+		Name:       "weekly digest",
+		Schedule:   scheduler.Weekly{Weekday: time.Monday, Hour: 7, Minute: 0, Location: cfg.ReportsLocation},
+		RunOnStart: true,
+		Run: func(_ context.Context, now time.Time) error {
+			return sendWeeklyDigests(cfg, db, now)
+		},
+	}
+}
@@ -0,0 +1,107 @@
+package dnsfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"domanscy.group/parental-controls/server/children"
+	"github.com/miekg/dns"
+)
+
+var ErrNoQuestion = errors.New("dns message does not contain a question")
+
+const blockedAnswerTtl = 60
+
+type Filter struct {
+	upstream    string
+	blockPageIp net.IP
+	udpClient   *dns.Client
+	tcpClient   *dns.Client
+}
+
+// NewFilter creates a filter forwarding allowed queries to upstream (host:port).
+// blockPageIp may be nil, children with block page mode then receive empty answers instead.
+func NewFilter(upstream string, blockPageIp net.IP) *Filter {
+	return &Filter{
+		upstream:    upstream,
+		blockPageIp: blockPageIp,
+		udpClient:   &dns.Client{Net: "udp", Timeout: time.Second * 5},
+		tcpClient:   &dns.Client{Net: "tcp", Timeout: time.Second * 5},
+	}
+}
+
+// Resolve answers the request for the given policy. Nil policy means the client is not managed and every query is forwarded.
+func (filter *Filter) Resolve(ctx context.Context, policy *Policy, request *dns.Msg) (*dns.Msg, Decision, error) {
+	if len(request.Question) == 0 {
+		return nil, Decision{}, ErrNoQuestion
+	}
+
+	decision := Decision{}
+
+	if policy != nil {
+		decision = policy.Decide(request.Question[0].Name)
+	}
+
+	if decision.Blocked {
+		return filter.blockedResponse(request, policy.BlockMode), decision, nil
+	}
+
+	if decision.RewriteTo != "" {
+		response, err := filter.rewrite(ctx, request, decision.RewriteTo)
+		if err != nil {
+			return nil, decision, err
+		}
+
+		return response, decision, nil
+	}
+
+	response, err := filter.forward(ctx, request)
+	if err != nil {
+		return nil, decision, err
+	}
+
+	return response, decision, nil
+}
+
+func (filter *Filter) forward(ctx context.Context, request *dns.Msg) (*dns.Msg, error) {
+	response, _, err := filter.udpClient.ExchangeContext(ctx, request, filter.upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to forward query to upstream '%s': %w", filter.upstream, err)
+	}
+
+	if response.Truncated {
+		response, _, err = filter.tcpClient.ExchangeContext(ctx, request, filter.upstream)
+		if err != nil {
+			return nil, fmt.Errorf("failed to forward truncated query to upstream '%s' over tcp: %w", filter.upstream, err)
+		}
+	}
+
+	return response, nil
+}
+
+func (filter *Filter) blockedResponse(request *dns.Msg, blockMode children.BlockMode) *dns.Msg {
+	response := new(dns.Msg)
+
+	if blockMode != children.BlockModeBlockPage {
+		response.SetRcode(request, dns.RcodeNameError)
+		return response
+	}
+
+	response.SetReply(request)
+
+	question := request.Question[0]
+	header := dns.RR_Header{Name: question.Name, Class: dns.ClassINET, Ttl: blockedAnswerTtl}
+
+	if ipv4 := filter.blockPageIp.To4(); ipv4 != nil && question.Qtype == dns.TypeA {
+		header.Rrtype = dns.TypeA
+		response.Answer = append(response.Answer, &dns.A{Hdr: header, A: ipv4})
+	} else if ipv4 == nil && filter.blockPageIp != nil && question.Qtype == dns.TypeAAAA {
+		header.Rrtype = dns.TypeAAAA
+		response.Answer = append(response.Answer, &dns.AAAA{Hdr: header, AAAA: filter.blockPageIp})
+	}
+
+	return response
+}
@@ -0,0 +1,287 @@
+package dnsfilter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/accessrequests"
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/domainrules"
+)
+
+// Policy is everything the filter needs to know about the child behind a single query.
+type Policy struct {
+	ChildId   int
+	DeviceId  int
+	BlockMode children.BlockMode
+	Allowlist []string
+	Blocklist []string
+	// Exceptions are domains the parent allowed for a limited time after the child asked for access.
+	Exceptions []string
+	// PathAllowlist and PathBlocklist are seen only by the forward proxy, see DecideUrl.
+	PathAllowlist []domainrules.PathRule
+	PathBlocklist []domainrules.PathRule
+
+	BlockedCategories []blocklists.Category
+	// CategoryLists is consulted only when BlockedCategories is not empty.
+	CategoryLists CategoryMatcher
+
+	SafeSearch            bool
+	YoutubeRestrictedMode children.YoutubeRestrictedMode
+}
+
+// CategoryMatcher is implemented by blocklists.Matcher.
+type CategoryMatcher interface {
+	Match(domain string, categories []blocklists.Category) (blocklists.Match, bool)
+}
+
+type Reason string
+
+const (
+	ReasonNone       Reason = ""
+	ReasonAllowlist  Reason = "allowlist"
+	ReasonException  Reason = "exception"
+	ReasonBlocklist  Reason = "blocklist"
+	ReasonCategory   Reason = "category"
+	ReasonSafeSearch Reason = "safe_search"
+	// ReasonPathAllowlist and ReasonPathBlocklist are decided by the forward proxy only.
+	ReasonPathAllowlist Reason = "path_allowlist"
+	ReasonPathBlocklist Reason = "path_blocklist"
+)
+
+type Decision struct {
+	Blocked bool
+	Reason  Reason
+	// Rule is the allowlist or blocklist entry that decided about the query, empty if nothing matched.
+	Rule string
+	// Category is set when the domain was found on one of the category blocklists.
+	Category blocklists.Category
+	// RewriteTo is the safe search host the query has to be answered with, empty if there is no rewrite.
+	RewriteTo string
+}
+
+func matchesDomain(domain string, rule string) bool {
+	return domain == rule || strings.HasSuffix(domain, "."+rule)
+}
+
+func findMatchingRule(domain string, rules []string) (string, bool) {
+	for _, rule := range rules {
+		if matchesDomain(domain, rule) {
+			return rule, true
+		}
+	}
+
+	return "", false
+}
+
+func normalizeQueryName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// Decide checks the domain against the allowlist and temporary exceptions first, so parents can punch holes in broader blocks,
+// then against the blocklist of the child and the lists of blocked categories.
+// Allowing a domain does not turn off safe search for it, the rewrite applies to every domain that is not blocked.
+func (policy *Policy) Decide(domain string) Decision {
+	domain = normalizeQueryName(domain)
+
+	decision := Decision{Blocked: false, Reason: ReasonNone}
+
+	if rule, found := findMatchingRule(domain, policy.Allowlist); found {
+		decision = Decision{Blocked: false, Reason: ReasonAllowlist, Rule: rule}
+	} else if rule, found := findMatchingRule(domain, policy.Exceptions); found {
+		decision = Decision{Blocked: false, Reason: ReasonException, Rule: rule}
+	} else if rule, found := findMatchingRule(domain, policy.Blocklist); found {
+		return Decision{Blocked: true, Reason: ReasonBlocklist, Rule: rule}
+	} else if match, found := policy.matchCategories(domain); found {
+		return Decision{Blocked: true, Reason: ReasonCategory, Rule: match.Domain, Category: match.Category}
+	}
+
+	if host, found := SafeSearchHost(domain, policy.SafeSearch, policy.YoutubeRestrictedMode); found {
+		return Decision{Blocked: false, Reason: ReasonSafeSearch, Rule: domain, RewriteTo: host}
+	}
+
+	return decision
+}
+
+// DecideUrl checks the path rules of the domain before deciding about the domain itself, a path rule is more specific
+// than any domain rule. An allowed path is still rewritten to the safe search host.
+func (policy *Policy) DecideUrl(host string, requestPath string) Decision {
+	domain := normalizeQueryName(host)
+
+	if rule, found := findMatchingPathRule(domain, requestPath, policy.PathAllowlist); found {
+		decision := Decision{Blocked: false, Reason: ReasonPathAllowlist, Rule: rule.String()}
+		decision.RewriteTo, _ = SafeSearchHost(domain, policy.SafeSearch, policy.YoutubeRestrictedMode)
+
+		return decision
+	}
+
+	if rule, found := findMatchingPathRule(domain, requestPath, policy.PathBlocklist); found {
+		return Decision{Blocked: true, Reason: ReasonPathBlocklist, Rule: rule.String()}
+	}
+
+	return policy.Decide(domain)
+}
+
+// HasPathRules is true when some path rule applies to the domain, the proxy has to see the requests to it then.
+func (policy *Policy) HasPathRules(host string) bool {
+	domain := normalizeQueryName(host)
+
+	for _, rules := range [][]domainrules.PathRule{policy.PathAllowlist, policy.PathBlocklist} {
+		for _, rule := range rules {
+			if matchesDomain(domain, rule.Domain) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func findMatchingPathRule(domain string, requestPath string, rules []domainrules.PathRule) (domainrules.PathRule, bool) {
+	for _, rule := range rules {
+		if matchesDomain(domain, rule.Domain) && rule.MatchesPath(requestPath) {
+			return rule, true
+		}
+	}
+
+	return domainrules.PathRule{}, false
+}
+
+func (policy *Policy) matchCategories(domain string) (blocklists.Match, bool) {
+	if len(policy.BlockedCategories) == 0 || policy.CategoryLists == nil {
+		return blocklists.Match{}, false
+	}
+
+	return policy.CategoryLists.Match(domain, policy.BlockedCategories)
+}
+
+// PolicySource maps a client to the policy of its child. The methods return nil policy if the client is unknown.
+type PolicySource interface {
+	PolicyForClientIp(ctx context.Context, ip string) (*Policy, error)
+	PolicyForDeviceToken(ctx context.Context, token string) (*Policy, error)
+	PolicyForDohToken(ctx context.Context, dohToken string) (*Policy, error)
+}
+
+type DatabasePolicySource struct {
+	db            *sql.DB
+	categoryLists CategoryMatcher
+}
+
+func NewDatabasePolicySource(db *sql.DB, categoryLists CategoryMatcher) *DatabasePolicySource {
+	return &DatabasePolicySource{db: db, categoryLists: categoryLists}
+}
+
+func (source *DatabasePolicySource) PolicyForClientIp(ctx context.Context, ip string) (*Policy, error) {
+	return source.policyForDevice(ctx, func(tx *sql.Tx) (*devices.Model, error) {
+		return devices.FindOneByIpAddress(tx, ip)
+	})
+}
+
+func (source *DatabasePolicySource) PolicyForDeviceToken(ctx context.Context, token string) (*Policy, error) {
+	return source.policyForDevice(ctx, func(tx *sql.Tx) (*devices.Model, error) {
+		return devices.FindOneByToken(tx, token)
+	})
+}
+
+func (source *DatabasePolicySource) PolicyForDohToken(ctx context.Context, dohToken string) (*Policy, error) {
+	return source.policyForDevice(ctx, func(tx *sql.Tx) (*devices.Model, error) {
+		return devices.FindOneByDohToken(tx, dohToken)
+	})
+}
+
+func (source *DatabasePolicySource) policyForDevice(ctx context.Context, findDevice func(tx *sql.Tx) (*devices.Model, error)) (*Policy, error) {
+	tx, err := source.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	policy, err := loadPolicy(tx, findDevice)
+	if err != nil {
+		return nil, littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	if policy != nil {
+		policy.CategoryLists = source.categoryLists
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit the transaction: %w", err)
+	}
+
+	return policy, nil
+}
+
+func loadPolicy(tx *sql.Tx, findDevice func(tx *sql.Tx) (*devices.Model, error)) (*Policy, error) {
+	device, err := findDevice(tx)
+	if err != nil {
+		return nil, fmt.Errorf("error occured while trying to find device: %w", err)
+	}
+
+	if device == nil {
+		return nil, nil
+	}
+
+	child, err := children.FindOneById(tx, device.ChildId)
+	if err != nil {
+		return nil, fmt.Errorf("error occured while trying to find child of device %d: %w", device.Id, err)
+	}
+
+	if child == nil {
+		return nil, nil
+	}
+
+	rules, err := domainrules.FindAllByChildId(tx, child.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error occured while trying to find domain rules of child %d: %w", child.Id, err)
+	}
+
+	pathRules, err := domainrules.FindAllPathRulesByChildId(tx, child.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error occured while trying to find path rules of child %d: %w", child.Id, err)
+	}
+
+	blockedCategories, err := blocklists.FindBlockedCategoriesByChildId(tx, child.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error occured while trying to find blocked categories of child %d: %w", child.Id, err)
+	}
+
+	exceptions, err := accessrequests.FindActiveExceptionDomainsByChildId(tx, child.Id, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error occured while trying to find access exceptions of child %d: %w", child.Id, err)
+	}
+
+	policy := &Policy{
+		ChildId:               child.Id,
+		DeviceId:              device.Id,
+		BlockMode:             child.BlockMode,
+		Exceptions:            exceptions,
+		BlockedCategories:     blockedCategories,
+		SafeSearch:            child.SafeSearch,
+		YoutubeRestrictedMode: child.YoutubeRestrictedMode,
+	}
+
+	for _, rule := range rules {
+		if rule.Action == domainrules.ActionAllow {
+			policy.Allowlist = append(policy.Allowlist, rule.Domain)
+		} else {
+			policy.Blocklist = append(policy.Blocklist, rule.Domain)
+		}
+	}
+
+	for _, rule := range pathRules {
+		if rule.Action == domainrules.ActionAllow {
+			policy.PathAllowlist = append(policy.PathAllowlist, rule)
+		} else {
+			policy.PathBlocklist = append(policy.PathBlocklist, rule)
+		}
+	}
+
+	return policy, nil
+}
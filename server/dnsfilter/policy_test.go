@@ -0,0 +1,203 @@
+package dnsfilter
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/database"
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/domainrules"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/migrations"
+	"domanscy.group/parental-controls/server/users"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDatabasePolicySource(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	// every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, migrations.All)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userId, err := users.Create(tx, "parent@localhost.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	householdId, err := households.Create(tx, userId, "Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	childId, err := children.Create(tx, householdId, "Adam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = children.UpdateBlockMode(tx, childId, children.BlockModeBlockPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = children.UpdateSafeSearch(tx, childId, true, children.YoutubeRestrictedModeStrict)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deviceId, token, err := devices.Create(tx, childId, "Laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device, err := devices.FindOneById(tx, deviceId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dohToken := device.DohToken
+
+	err = devices.UpdateIpAddress(tx, deviceId, "10.0.0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = domainrules.Create(tx, childId, "Blocked.test.", domainrules.ActionBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = domainrules.Create(tx, childId, "*.school.blocked.test", domainrules.ActionAllow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = domainrules.CreatePathRule(tx, childId, "YouTube.com", "/@channel/", domainrules.ActionBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = blocklists.UpdateBlockedCategoriesOfChild(tx, childId, []blocklists.Category{blocklists.CategoryGambling})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matcher := blocklists.NewMatcher()
+	source := NewDatabasePolicySource(db, matcher)
+
+	assertPolicy := func(t *testing.T, policy *Policy) {
+		if policy == nil {
+			t.Fatal("Expected policy, received nil")
+		}
+
+		if policy.ChildId != childId || policy.DeviceId != deviceId {
+			t.Errorf("Expected child %d and device %d, received child %d and device %d", childId, deviceId, policy.ChildId, policy.DeviceId)
+		}
+
+		if policy.BlockMode != children.BlockModeBlockPage {
+			t.Errorf("Expected block mode %s, received %s", children.BlockModeBlockPage, policy.BlockMode)
+		}
+
+		if !policy.SafeSearch || policy.YoutubeRestrictedMode != children.YoutubeRestrictedModeStrict {
+			t.Errorf("Expected safe search with strict youtube restricted mode, received %t and %s", policy.SafeSearch, policy.YoutubeRestrictedMode)
+		}
+
+		if len(policy.Blocklist) != 1 || policy.Blocklist[0] != "blocked.test" {
+			t.Errorf("Expected blocklist [blocked.test], received %v", policy.Blocklist)
+		}
+
+		if len(policy.Allowlist) != 1 || policy.Allowlist[0] != "school.blocked.test" {
+			t.Errorf("Expected allowlist [school.blocked.test], received %v", policy.Allowlist)
+		}
+
+		if len(policy.PathBlocklist) != 1 || policy.PathBlocklist[0].String() != "youtube.com/@channel" || len(policy.PathAllowlist) != 0 {
+			t.Errorf("Expected path blocklist [youtube.com/@channel], received %v and allowlist %v", policy.PathBlocklist, policy.PathAllowlist)
+		}
+
+		if len(policy.BlockedCategories) != 1 || policy.BlockedCategories[0] != blocklists.CategoryGambling {
+			t.Errorf("Expected blocked categories [gambling], received %v", policy.BlockedCategories)
+		}
+
+		if policy.CategoryLists != matcher {
+			t.Errorf("Expected category lists of the source, received %v", policy.CategoryLists)
+		}
+	}
+
+	t.Run("finds policy by client ip", func(t *testing.T) {
+		policy, err := source.PolicyForClientIp(context.Background(), "10.0.0.5")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertPolicy(t, policy)
+	})
+
+	t.Run("finds policy by device token", func(t *testing.T) {
+		policy, err := source.PolicyForDeviceToken(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertPolicy(t, policy)
+	})
+
+	t.Run("finds policy by DoH token", func(t *testing.T) {
+		policy, err := source.PolicyForDohToken(context.Background(), dohToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertPolicy(t, policy)
+	})
+
+	t.Run("returns nil for unknown clients", func(t *testing.T) {
+		policy, err := source.PolicyForClientIp(context.Background(), "10.0.0.6")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if policy != nil {
+			t.Errorf("Expected nil, received %+v", policy)
+		}
+
+		policy, err = source.PolicyForDeviceToken(context.Background(), "unknown")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if policy != nil {
+			t.Errorf("Expected nil, received %+v", policy)
+		}
+
+		policy, err = source.PolicyForDohToken(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if policy != nil {
+			t.Errorf("Expected nil for the token of the agent, received %+v", policy)
+		}
+	})
+}
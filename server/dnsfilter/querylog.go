@@ -0,0 +1,75 @@
+package dnsfilter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/activity"
+	"github.com/miekg/dns"
+)
+
+type Transport string
+
+const (
+	TransportUdp Transport = "udp"
+	TransportTcp Transport = "tcp"
+	TransportDoh Transport = "doh"
+	// TransportProxy is used for requests of the forward proxy, see RequestLog.
+	TransportProxy Transport = "proxy"
+)
+
+// QueryLog records queries of managed devices. It is never called for clients without a policy.
+type QueryLog interface {
+	LogQuery(ctx context.Context, policy *Policy, transport Transport, question dns.Question, decision Decision) error
+}
+
+// RequestLog records requests the forward proxy decided about, the path is empty for tunnels it did not look into.
+type RequestLog interface {
+	LogRequest(ctx context.Context, policy *Policy, host string, path string, decision Decision) error
+}
+
+type DatabaseQueryLog struct {
+	db *sql.DB
+}
+
+func NewDatabaseQueryLog(db *sql.DB) *DatabaseQueryLog {
+	return &DatabaseQueryLog{db: db}
+}
+
+func (queryLog *DatabaseQueryLog) LogQuery(ctx context.Context, policy *Policy, transport Transport, question dns.Question, decision Decision) error {
+	return queryLog.log(ctx, policy, activity.DnsQueryPayload{
+		Domain:    normalizeQueryName(question.Name),
+		QueryType: dns.TypeToString[question.Qtype],
+		Transport: string(transport),
+	}, decision)
+}
+
+func (queryLog *DatabaseQueryLog) LogRequest(ctx context.Context, policy *Policy, host string, path string, decision Decision) error {
+	return queryLog.log(ctx, policy, activity.DnsQueryPayload{
+		Domain:    normalizeQueryName(host),
+		Path:      path,
+		Transport: string(TransportProxy),
+	}, decision)
+}
+
+func (queryLog *DatabaseQueryLog) log(ctx context.Context, policy *Policy, payload activity.DnsQueryPayload, decision Decision) error {
+	tx, err := queryLog.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	payload.Blocked = decision.Blocked
+	payload.Reason = string(decision.Reason)
+	payload.Rule = decision.Rule
+	payload.Category = string(decision.Category)
+
+	_, err = activity.Create(tx, policy.ChildId, policy.DeviceId, activity.TypeDnsQuery, payload, time.Now())
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,128 @@
+package dnsfilter
+
+import (
+	"context"
+	"fmt"
+
+	"domanscy.group/parental-controls/server/children"
+	"github.com/miekg/dns"
+)
+
+// Endpoints published by the search engines for network-enforced safe search, see
+// https://support.google.com/websearch/answer/186669, https://support.google.com/a/answer/6214622,
+// https://help.bing.microsoft.com/#apex/bing/en-us/10003/0 and https://duckduckgo.com/duckduckgo-help-pages/features/safe-search/
+const (
+	googleSafeSearchHost     = "forcesafesearch.google.com"
+	bingSafeSearchHost       = "strict.bing.com"
+	duckDuckGoSafeSearchHost = "safe.duckduckgo.com"
+	youtubeStrictHost        = "restrict.youtube.com"
+	youtubeModerateHost      = "restrictmoderate.youtube.com"
+)
+
+const safeSearchCnameTtl = 300
+
+// googleTopLevelDomains are the country domains google search is served from, see https://www.google.com/supported_domains
+var googleTopLevelDomains = []string{
+	"com", "ad", "ae", "com.af", "com.ag", "al", "am", "co.ao", "com.ar", "as", "at", "com.au", "az", "ba", "com.bd",
+	"be", "bf", "bg", "com.bh", "bi", "bj", "com.bn", "com.bo", "com.br", "bs", "bt", "co.bw", "by", "com.bz", "ca",
+	"cd", "cf", "cg", "ch", "ci", "co.ck", "cl", "cm", "cn", "com.co", "co.cr", "com.cu", "cv", "com.cy", "cz", "de",
+	"dj", "dk", "dm", "com.do", "dz", "com.ec", "ee", "com.eg", "es", "com.et", "fi", "com.fj", "fm", "fr", "ga", "ge",
+	"gg", "com.gh", "com.gi", "gl", "gm", "gr", "com.gt", "gy", "com.hk", "hn", "hr", "ht", "hu", "co.id", "ie",
+	"co.il", "im", "co.in", "iq", "is", "it", "je", "com.jm", "jo", "co.jp", "co.ke", "com.kh", "ki", "kg", "co.kr",
+	"com.kw", "kz", "la", "com.lb", "li", "lk", "co.ls", "lt", "lu", "lv", "com.ly", "co.ma", "md", "me", "mg", "mk",
+	"ml", "com.mm", "mn", "com.mt", "mu", "mv", "mw", "com.mx", "com.my", "co.mz", "com.na", "com.ng", "com.ni", "ne",
+	"nl", "no", "com.np", "nr", "nu", "co.nz", "com.om", "com.pa", "com.pe", "com.pg", "com.ph", "com.pk", "pl", "pn",
+	"com.pr", "ps", "pt", "com.py", "com.qa", "ro", "ru", "rw", "com.sa", "com.sb", "sc", "se", "com.sg", "sh", "si",
+	"sk", "com.sl", "sn", "so", "sm", "sr", "st", "com.sv", "td", "tg", "co.th", "com.tj", "tl", "tm", "tn", "to",
+	"com.tr", "tt", "com.tw", "co.tz", "com.ua", "co.ug", "co.uk", "com.uy", "co.uz", "com.vc", "co.ve", "co.vi",
+	"com.vn", "vu", "ws", "rs", "co.za", "co.zm", "co.zw", "cat",
+}
+
+var searchEngineSafeSearchHosts = buildSearchEngineSafeSearchHosts()
+
+var youtubeHosts = []string{
+	"www.youtube.com",
+	"m.youtube.com",
+	"youtubei.googleapis.com",
+	"youtube.googleapis.com",
+	"www.youtube-nocookie.com",
+}
+
+func buildSearchEngineSafeSearchHosts() map[string]string {
+	hosts := map[string]string{
+		"www.bing.com":          bingSafeSearchHost,
+		"bing.com":              bingSafeSearchHost,
+		"duckduckgo.com":        duckDuckGoSafeSearchHost,
+		"www.duckduckgo.com":    duckDuckGoSafeSearchHost,
+		"start.duckduckgo.com":  duckDuckGoSafeSearchHost,
+		"html.duckduckgo.com":   duckDuckGoSafeSearchHost,
+		"lite.duckduckgo.com":   duckDuckGoSafeSearchHost,
+		"duckduckgo.onion":      duckDuckGoSafeSearchHost,
+		"www.duckduckgo.onion":  duckDuckGoSafeSearchHost,
+		"safe.duckduckgo.onion": duckDuckGoSafeSearchHost,
+	}
+
+	for _, topLevelDomain := range googleTopLevelDomains {
+		hosts["google."+topLevelDomain] = googleSafeSearchHost
+		hosts["www.google."+topLevelDomain] = googleSafeSearchHost
+	}
+
+	return hosts
+}
+
+// SafeSearchHost returns the host queries for domain should be rewritten to, if any.
+// Only exact hosts are rewritten, e.g. mail.google.com keeps working as usual.
+func SafeSearchHost(domain string, safeSearch bool, youtubeRestrictedMode children.YoutubeRestrictedMode) (string, bool) {
+	domain = normalizeQueryName(domain)
+
+	if safeSearch {
+		if host, found := searchEngineSafeSearchHosts[domain]; found {
+			return host, true
+		}
+	}
+
+	if youtubeRestrictedMode == children.YoutubeRestrictedModeStrict || youtubeRestrictedMode == children.YoutubeRestrictedModeModerate {
+		for _, youtubeHost := range youtubeHosts {
+			if domain != youtubeHost {
+				continue
+			}
+
+			if youtubeRestrictedMode == children.YoutubeRestrictedModeStrict {
+				return youtubeStrictHost, true
+			}
+
+			return youtubeModerateHost, true
+		}
+	}
+
+	return "", false
+}
+
+// rewrite answers the request with a CNAME to host followed by the upstream answers for host,
+// the same response a recursive resolver gives for a CNAME found in the zone.
+func (filter *Filter) rewrite(ctx context.Context, request *dns.Msg, host string) (*dns.Msg, error) {
+	question := request.Question[0]
+
+	upstreamRequest := request.Copy()
+	upstreamRequest.Question[0].Name = dns.Fqdn(host)
+
+	upstreamResponse, err := filter.forward(ctx, upstreamRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve safe search host '%s': %w", host, err)
+	}
+
+	response := new(dns.Msg)
+	response.SetRcode(request, upstreamResponse.Rcode)
+	response.RecursionAvailable = upstreamResponse.RecursionAvailable
+
+	response.Answer = append(response.Answer, &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: question.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: safeSearchCnameTtl},
+		Target: dns.Fqdn(host),
+	})
+
+	if question.Qtype != dns.TypeCNAME {
+		response.Answer = append(response.Answer, upstreamResponse.Answer...)
+	}
+
+	return response, nil
+}
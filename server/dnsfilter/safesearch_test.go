@@ -0,0 +1,107 @@
+package dnsfilter
+
+import (
+	"testing"
+
+	"domanscy.group/parental-controls/server/children"
+	"github.com/miekg/dns"
+)
+
+func TestSafeSearchHost(t *testing.T) {
+	testCases := []struct {
+		domain       string
+		safeSearch   bool
+		youtubeMode  children.YoutubeRestrictedMode
+		expectedHost string
+	}{
+		{"www.google.com", true, children.YoutubeRestrictedModeOff, "forcesafesearch.google.com"},
+		{"google.com.", true, children.YoutubeRestrictedModeOff, "forcesafesearch.google.com"},
+		{"WWW.GOOGLE.PL", true, children.YoutubeRestrictedModeOff, "forcesafesearch.google.com"},
+		{"www.google.co.uk", true, children.YoutubeRestrictedModeOff, "forcesafesearch.google.com"},
+		{"www.bing.com", true, children.YoutubeRestrictedModeOff, "strict.bing.com"},
+		{"duckduckgo.com", true, children.YoutubeRestrictedModeOff, "safe.duckduckgo.com"},
+		{"start.duckduckgo.com", true, children.YoutubeRestrictedModeOff, "safe.duckduckgo.com"},
+		{"www.youtube.com", false, children.YoutubeRestrictedModeStrict, "restrict.youtube.com"},
+		{"m.youtube.com", false, children.YoutubeRestrictedModeStrict, "restrict.youtube.com"},
+		{"youtubei.googleapis.com", false, children.YoutubeRestrictedModeModerate, "restrictmoderate.youtube.com"},
+		{"www.youtube-nocookie.com", true, children.YoutubeRestrictedModeModerate, "restrictmoderate.youtube.com"},
+
+		// toggles turned off
+		{"www.google.com", false, children.YoutubeRestrictedModeStrict, ""},
+		{"www.youtube.com", true, children.YoutubeRestrictedModeOff, ""},
+
+		// hosts which are not search pages
+		{"mail.google.com", true, children.YoutubeRestrictedModeStrict, ""},
+		{"googleapis.com", true, children.YoutubeRestrictedModeStrict, ""},
+		{"google.example.com", true, children.YoutubeRestrictedModeStrict, ""},
+		{"music.youtube.com", true, children.YoutubeRestrictedModeStrict, ""},
+	}
+
+	for _, testCase := range testCases {
+		host, found := SafeSearchHost(testCase.domain, testCase.safeSearch, testCase.youtubeMode)
+
+		if found != (testCase.expectedHost != "") || host != testCase.expectedHost {
+			t.Errorf("%s (safe search %t, youtube %s): expected '%s', received '%s'", testCase.domain, testCase.safeSearch, testCase.youtubeMode, testCase.expectedHost, host)
+		}
+	}
+}
+
+func TestPolicyDecideWithSafeSearch(t *testing.T) {
+	policy := &Policy{
+		Allowlist:  []string{"google.com"},
+		Blocklist:  []string{"bing.com"},
+		SafeSearch: true,
+	}
+
+	decision := policy.Decide("www.google.com.")
+	expected := Decision{Blocked: false, Reason: ReasonSafeSearch, Rule: "www.google.com", RewriteTo: "forcesafesearch.google.com"}
+	if decision != expected {
+		t.Errorf("Expected allowed domain to still be rewritten: %+v, received %+v", expected, decision)
+	}
+
+	decision = policy.Decide("www.bing.com")
+	if !decision.Blocked || decision.RewriteTo != "" {
+		t.Errorf("Expected blocked domain to not be rewritten, received %+v", decision)
+	}
+}
+
+func TestServerWithSafeSearch(t *testing.T) {
+	address := startFilteringServer(t, &fakePolicySource{
+		byIp: map[string]*Policy{
+			"127.0.0.1": {
+				ChildId:               1,
+				BlockMode:             children.BlockModeNxdomain,
+				SafeSearch:            true,
+				YoutubeRestrictedMode: children.YoutubeRestrictedModeStrict,
+			},
+		},
+	}, &fakeQueryLog{})
+
+	testCases := []struct {
+		domain string
+		target string
+	}{
+		{"www.google.com", "forcesafesearch.google.com."},
+		{"www.youtube.com", "restrict.youtube.com."},
+	}
+
+	for _, testCase := range testCases {
+		t.Run("rewrites "+testCase.domain, func(t *testing.T) {
+			response := query(t, "udp", address, testCase.domain, dns.TypeA)
+
+			if response.Rcode != dns.RcodeSuccess || len(response.Answer) != 2 {
+				t.Fatalf("Expected NOERROR with CNAME and A, received %s with %v", dns.RcodeToString[response.Rcode], response.Answer)
+			}
+
+			cname, ok := response.Answer[0].(*dns.CNAME)
+			if !ok || cname.Hdr.Name != testCase.domain+"." || cname.Target != testCase.target {
+				t.Errorf("Expected CNAME %s. -> %s, received %s", testCase.domain, testCase.target, response.Answer[0].String())
+			}
+
+			a, ok := response.Answer[1].(*dns.A)
+			if !ok || a.Hdr.Name != testCase.target || !a.A.Equal(upstreamAnswerIp) {
+				t.Errorf("Expected A %s -> %s, received %s", testCase.target, upstreamAnswerIp, response.Answer[1].String())
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package dnsfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const queryTimeout = time.Second * 10
+
+// Server listens for plain dns queries on both udp and tcp and recognises children by the client ip address.
+type Server struct {
+	filter   *Filter
+	policies PolicySource
+	queryLog QueryLog
+
+	udpServer *dns.Server
+	tcpServer *dns.Server
+}
+
+func NewServer(filter *Filter, policies PolicySource, queryLog QueryLog) *Server {
+	return &Server{
+		filter:   filter,
+		policies: policies,
+		queryLog: queryLog,
+	}
+}
+
+// Start binds udp and tcp on the same address and serves both in the background.
+// Errors returned while serving are sent to errCh. Port 0 picks a random port, see Addr.
+func (server *Server) Start(address string, errCh chan<- error) error {
+	packetConn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on udp address '%s': %w", address, err)
+	}
+
+	// when port 0 was requested tcp has to use the port picked for udp
+	listener, err := net.Listen("tcp", packetConn.LocalAddr().String())
+	if err != nil {
+		closeErr := packetConn.Close()
+		return errors.Join(fmt.Errorf("failed to listen on tcp address '%s': %w", packetConn.LocalAddr().String(), err), closeErr)
+	}
+
+	server.udpServer = &dns.Server{PacketConn: packetConn, Handler: server}
+	server.tcpServer = &dns.Server{Listener: listener, Handler: server}
+
+	go func() {
+		err := server.udpServer.ActivateAndServe()
+		if err != nil {
+			errCh <- fmt.Errorf("dns udp server stopped: %w", err)
+		}
+	}()
+
+	go func() {
+		err := server.tcpServer.ActivateAndServe()
+		if err != nil {
+			errCh <- fmt.Errorf("dns tcp server stopped: %w", err)
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the address the server is listening on, it is the same for udp and tcp.
+func (server *Server) Addr() string {
+	return server.udpServer.PacketConn.LocalAddr().String()
+}
+
+func (server *Server) Shutdown() error {
+	return errors.Join(server.udpServer.Shutdown(), server.tcpServer.Shutdown())
+}
+
+func (server *Server) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
+	clientIp, _, err := net.SplitHostPort(w.RemoteAddr().String())
+	if err != nil {
+		log.Printf("failed to read client ip address from '%s': %v", w.RemoteAddr().String(), err)
+		writeServerFailure(w, request)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	policy, err := server.policies.PolicyForClientIp(ctx, clientIp)
+	if err != nil {
+		log.Printf("failed to find policy for client '%s': %v", clientIp, err)
+		writeServerFailure(w, request)
+		return
+	}
+
+	response, decision, err := server.filter.Resolve(ctx, policy, request)
+	if errors.Is(err, ErrNoQuestion) {
+		response = new(dns.Msg)
+		response.SetRcode(request, dns.RcodeFormatError)
+	} else if err != nil {
+		log.Printf("failed to resolve query from client '%s': %v", clientIp, err)
+		writeServerFailure(w, request)
+		return
+	}
+
+	if policy != nil && len(request.Question) > 0 {
+		transport := TransportUdp
+		if _, isTcp := w.RemoteAddr().(*net.TCPAddr); isTcp {
+			transport = TransportTcp
+		}
+
+		err = server.queryLog.LogQuery(ctx, policy, transport, request.Question[0], decision)
+		if err != nil {
+			log.Printf("failed to log query from client '%s': %v", clientIp, err)
+		}
+	}
+
+	err = w.WriteMsg(response)
+	if err != nil {
+		log.Printf("failed to write dns response to client '%s': %v", clientIp, err)
+	}
+}
+
+func writeServerFailure(w dns.ResponseWriter, request *dns.Msg) {
+	response := new(dns.Msg)
+	response.SetRcode(request, dns.RcodeServerFailure)
+
+	err := w.WriteMsg(response)
+	if err != nil {
+		log.Printf("failed to write SERVFAIL response: %v", err)
+	}
+}
@@ -0,0 +1,349 @@
+package dnsfilter
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/domainrules"
+	"github.com/miekg/dns"
+)
+
+var upstreamAnswerIp = net.ParseIP("192.0.2.10")
+var blockPageIp = net.ParseIP("192.0.2.99")
+
+type fakePolicySource struct {
+	byIp    map[string]*Policy
+	byToken map[string]*Policy
+}
+
+func (source *fakePolicySource) PolicyForClientIp(_ context.Context, ip string) (*Policy, error) {
+	return source.byIp[ip], nil
+}
+
+func (source *fakePolicySource) PolicyForDeviceToken(_ context.Context, token string) (*Policy, error) {
+	return source.byToken[token], nil
+}
+
+func (source *fakePolicySource) PolicyForDohToken(_ context.Context, _ string) (*Policy, error) {
+	return nil, nil
+}
+
+type loggedQuery struct {
+	childId   int
+	transport Transport
+	domain    string
+	blocked   bool
+}
+
+type fakeQueryLog struct {
+	mutex   sync.Mutex
+	queries []loggedQuery
+}
+
+func (queryLog *fakeQueryLog) LogQuery(_ context.Context, policy *Policy, transport Transport, question dns.Question, decision Decision) error {
+	queryLog.mutex.Lock()
+	defer queryLog.mutex.Unlock()
+
+	queryLog.queries = append(queryLog.queries, loggedQuery{
+		childId:   policy.ChildId,
+		transport: transport,
+		domain:    question.Name,
+		blocked:   decision.Blocked,
+	})
+
+	return nil
+}
+
+func (queryLog *fakeQueryLog) all() []loggedQuery {
+	queryLog.mutex.Lock()
+	defer queryLog.mutex.Unlock()
+
+	return append([]loggedQuery{}, queryLog.queries...)
+}
+
+// startFakeUpstream answers every A query with upstreamAnswerIp.
+func startFakeUpstream(t *testing.T) string {
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upstream := &dns.Server{
+		PacketConn: packetConn,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, request *dns.Msg) {
+			response := new(dns.Msg)
+			response.SetReply(request)
+
+			if request.Question[0].Qtype == dns.TypeA {
+				response.Answer = append(response.Answer, &dns.A{
+					Hdr: dns.RR_Header{Name: request.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+					A:   upstreamAnswerIp,
+				})
+			}
+
+			err := w.WriteMsg(response)
+			if err != nil {
+				t.Error(err)
+			}
+		}),
+	}
+
+	go func() {
+		err := upstream.ActivateAndServe()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	t.Cleanup(func() {
+		err := upstream.Shutdown()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	return packetConn.LocalAddr().String()
+}
+
+func startFilteringServer(t *testing.T, policies PolicySource, queryLog QueryLog) string {
+	upstream := startFakeUpstream(t)
+
+	errCh := make(chan error, 2)
+
+	server := NewServer(NewFilter(upstream, blockPageIp), policies, queryLog)
+
+	err := server.Start("127.0.0.1:0", errCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err := server.Shutdown()
+		if err != nil {
+			t.Error(err)
+		}
+
+		select {
+		case err = <-errCh:
+			t.Error(err)
+		default:
+			// nothing
+		}
+	})
+
+	return server.Addr()
+}
+
+func query(t *testing.T, network string, address string, domain string, qtype uint16) *dns.Msg {
+	request := new(dns.Msg)
+	request.SetQuestion(dns.Fqdn(domain), qtype)
+
+	client := &dns.Client{Net: network}
+
+	response, _, err := client.Exchange(request, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+func assertAnswerIsIp(t *testing.T, response *dns.Msg, expected net.IP) {
+	if response.Rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected rcode NOERROR, received %s", dns.RcodeToString[response.Rcode])
+	}
+
+	if len(response.Answer) != 1 {
+		t.Fatalf("Expected one answer, received %d", len(response.Answer))
+	}
+
+	a, ok := response.Answer[0].(*dns.A)
+	if !ok {
+		t.Fatalf("Expected A record, received %s", response.Answer[0].String())
+	}
+
+	if !a.A.Equal(expected) {
+		t.Errorf("Expected %s, received %s", expected, a.A)
+	}
+}
+
+func TestServer(t *testing.T) {
+	policies := &fakePolicySource{
+		byIp: map[string]*Policy{
+			"127.0.0.1": {
+				ChildId:   1,
+				BlockMode: children.BlockModeNxdomain,
+				Allowlist: []string{"kids.example.com"},
+				Blocklist: []string{"example.com", "blocked.test"},
+			},
+		},
+	}
+
+	queryLog := &fakeQueryLog{}
+
+	address := startFilteringServer(t, policies, queryLog)
+
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network+": forwards allowed domains to upstream", func(t *testing.T) {
+			response := query(t, network, address, "allowed.test", dns.TypeA)
+			assertAnswerIsIp(t, response, upstreamAnswerIp)
+		})
+
+		t.Run(network+": answers blocked domains with NXDOMAIN", func(t *testing.T) {
+			response := query(t, network, address, "www.blocked.test", dns.TypeA)
+
+			if response.Rcode != dns.RcodeNameError {
+				t.Errorf("Expected NXDOMAIN, received %s", dns.RcodeToString[response.Rcode])
+			}
+		})
+
+		t.Run(network+": allowlist wins over blocklist", func(t *testing.T) {
+			response := query(t, network, address, "video.kids.example.com", dns.TypeA)
+			assertAnswerIsIp(t, response, upstreamAnswerIp)
+		})
+	}
+
+	t.Run("logs every query with its transport", func(t *testing.T) {
+		queries := queryLog.all()
+
+		if len(queries) != 6 {
+			t.Fatalf("Expected 6 logged queries, received %d", len(queries))
+		}
+
+		expected := loggedQuery{childId: 1, transport: TransportTcp, domain: "www.blocked.test.", blocked: true}
+		if queries[4] != expected {
+			t.Errorf("Expected %+v, received %+v", expected, queries[4])
+		}
+
+		if queries[0].transport != TransportUdp {
+			t.Errorf("Expected transport %s, received %s", TransportUdp, queries[0].transport)
+		}
+	})
+}
+
+func TestServerInBlockPageMode(t *testing.T) {
+	address := startFilteringServer(t, &fakePolicySource{
+		byIp: map[string]*Policy{
+			"127.0.0.1": {
+				ChildId:   1,
+				BlockMode: children.BlockModeBlockPage,
+				Blocklist: []string{"blocked.test"},
+			},
+		},
+	}, &fakeQueryLog{})
+
+	t.Run("answers A queries with block page ip", func(t *testing.T) {
+		response := query(t, "udp", address, "blocked.test", dns.TypeA)
+		assertAnswerIsIp(t, response, blockPageIp)
+	})
+
+	t.Run("answers AAAA queries with empty answer", func(t *testing.T) {
+		response := query(t, "udp", address, "blocked.test", dns.TypeAAAA)
+		if response.Rcode != dns.RcodeSuccess || len(response.Answer) != 0 {
+			t.Errorf("Expected empty NOERROR answer, received %s with %d answers", dns.RcodeToString[response.Rcode], len(response.Answer))
+		}
+	})
+}
+
+func TestServerWithUnknownClient(t *testing.T) {
+	queryLog := &fakeQueryLog{}
+
+	address := startFilteringServer(t, &fakePolicySource{}, queryLog)
+
+	response := query(t, "udp", address, "blocked.test", dns.TypeA)
+	assertAnswerIsIp(t, response, upstreamAnswerIp)
+
+	if len(queryLog.all()) != 0 {
+		t.Errorf("Expected queries of unknown clients to not be logged, received %d", len(queryLog.all()))
+	}
+}
+
+type fakeCategoryMatcher map[blocklists.Category]*blocklists.SuffixTrie
+
+func (matcher fakeCategoryMatcher) Match(domain string, categories []blocklists.Category) (blocklists.Match, bool) {
+	for _, category := range categories {
+		if entry, matched := matcher[category].Match(domain); matched {
+			return blocklists.Match{Category: category, Domain: entry}, true
+		}
+	}
+
+	return blocklists.Match{}, false
+}
+
+func TestPolicyDecide(t *testing.T) {
+	categoryLists := blocklists.NewSuffixTrie()
+	categoryLists.Insert("casino.test")
+	categoryLists.Insert("school.casino.test")
+
+	policy := &Policy{
+		Allowlist:         []string{"school.example.com", "school.casino.test"},
+		Blocklist:         []string{"example.com"},
+		Exceptions:        []string{"games.example.com"},
+		BlockedCategories: []blocklists.Category{blocklists.CategoryGambling},
+		CategoryLists:     fakeCategoryMatcher{blocklists.CategoryGambling: categoryLists},
+	}
+
+	testCases := []struct {
+		domain   string
+		expected Decision
+	}{
+		{"example.com.", Decision{Blocked: true, Reason: ReasonBlocklist, Rule: "example.com"}},
+		{"WWW.Example.com", Decision{Blocked: true, Reason: ReasonBlocklist, Rule: "example.com"}},
+		{"school.example.com", Decision{Blocked: false, Reason: ReasonAllowlist, Rule: "school.example.com"}},
+		{"notexample.com", Decision{Blocked: false, Reason: ReasonNone}},
+		{"www.games.example.com", Decision{Blocked: false, Reason: ReasonException, Rule: "games.example.com"}},
+		{"www.casino.test", Decision{Blocked: true, Reason: ReasonCategory, Rule: "casino.test", Category: blocklists.CategoryGambling}},
+		{"school.casino.test", Decision{Blocked: false, Reason: ReasonAllowlist, Rule: "school.casino.test"}},
+	}
+
+	for _, testCase := range testCases {
+		decision := policy.Decide(testCase.domain)
+		if decision != testCase.expected {
+			t.Errorf("%s: expected %+v, received %+v", testCase.domain, testCase.expected, decision)
+		}
+	}
+}
+
+func TestPolicyDecideUrl(t *testing.T) {
+	policy := &Policy{
+		Blocklist: []string{"blocked.test"},
+		PathAllowlist: []domainrules.PathRule{
+			{Domain: "blocked.test", Path: "/school"},
+			{Domain: "youtube.com", Path: "/@education"},
+		},
+		PathBlocklist: []domainrules.PathRule{
+			{Domain: "youtube.com", Path: "/@channel"},
+		},
+		SafeSearch:            true,
+		YoutubeRestrictedMode: children.YoutubeRestrictedModeStrict,
+	}
+
+	testCases := []struct {
+		host     string
+		path     string
+		expected Decision
+	}{
+		{"www.youtube.com", "/@channel", Decision{Blocked: true, Reason: ReasonPathBlocklist, Rule: "youtube.com/@channel"}},
+		{"youtube.com", "/@channel/videos", Decision{Blocked: true, Reason: ReasonPathBlocklist, Rule: "youtube.com/@channel"}},
+		{"www.youtube.com", "/@channelfan", Decision{Blocked: false, Reason: ReasonSafeSearch, Rule: "www.youtube.com", RewriteTo: "restrict.youtube.com"}},
+		{"www.youtube.com", "/@education", Decision{Blocked: false, Reason: ReasonPathAllowlist, Rule: "youtube.com/@education", RewriteTo: "restrict.youtube.com"}},
+		{"blocked.test", "/school/math", Decision{Blocked: false, Reason: ReasonPathAllowlist, Rule: "blocked.test/school"}},
+		{"blocked.test", "/", Decision{Blocked: true, Reason: ReasonBlocklist, Rule: "blocked.test"}},
+		{"example.com", "/@channel", Decision{Blocked: false, Reason: ReasonNone}},
+	}
+
+	for _, testCase := range testCases {
+		decision := policy.DecideUrl(testCase.host, testCase.path)
+		if decision != testCase.expected {
+			t.Errorf("%s%s: expected %+v, received %+v", testCase.host, testCase.path, testCase.expected, decision)
+		}
+	}
+
+	if !policy.HasPathRules("m.youtube.com") || !policy.HasPathRules("blocked.test") || policy.HasPathRules("example.com") {
+		t.Errorf("Expected path rules for youtube.com and blocked.test only")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"domanscy.group/parental-controls/server/dnsfilter"
+	"github.com/go-chi/chi"
+	"github.com/miekg/dns"
+)
+
+const dnsMessageContentType = "application/dns-message"
+
+// dnsMessageMaxSize is the largest message that fits in the two byte length prefix of dns over tcp.
+const dnsMessageMaxSize = 65535
+
+var ErrUnknownDeviceToken = errors.New("unknown device token")
+var ErrInvalidDnsMessage = errors.New("invalid dns message")
+var ErrUnsupportedContentType = errors.New("unsupported content type, expected " + dnsMessageContentType)
+var ErrDnsMessageTooLarge = errors.New("dns message too large")
+
+func readDnsMessageFromRequest(w http.ResponseWriter, r *http.Request) (*dns.Msg, error) {
+	var packed []byte
+
+	if r.Method == http.MethodGet {
+		encoded := r.URL.Query().Get("dns")
+
+		decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil || len(decoded) == 0 {
+			respondWith400(w, r, ErrInvalidDnsMessage)
+			return nil, ErrInvalidDnsMessage
+		}
+
+		packed = decoded
+	} else {
+		if r.Header.Get("Content-Type") != dnsMessageContentType {
+			respondWithError(w, r, http.StatusUnsupportedMediaType, ErrUnsupportedContentType)
+			return nil, ErrUnsupportedContentType
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, dnsMessageMaxSize+1))
+		if err != nil {
+			respondWith400(w, r, ErrInvalidDnsMessage)
+			return nil, err
+		}
+
+		if len(body) > dnsMessageMaxSize {
+			respondWithError(w, r, http.StatusRequestEntityTooLarge, ErrDnsMessageTooLarge)
+			return nil, ErrDnsMessageTooLarge
+		}
+
+		packed = body
+	}
+
+	request := new(dns.Msg)
+
+	err := request.Unpack(packed)
+	if err != nil || len(request.Question) == 0 {
+		respondWith400(w, r, ErrInvalidDnsMessage)
+		return nil, ErrInvalidDnsMessage
+	}
+
+	return request, nil
+}
+
+// minimalTtl is used as the http cache lifetime so that caches never outlive the records they hold.
+func minimalTtl(response *dns.Msg) (uint32, bool) {
+	var ttl uint32
+	found := false
+
+	for _, sections := range [][]dns.RR{response.Answer, response.Ns} {
+		for _, rr := range sections {
+			if !found || rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+				found = true
+			}
+		}
+	}
+
+	return ttl, found
+}
+
+// HttpDnsQuery implements RFC 8484 (DNS over HTTPS). Devices are recognised by the DoH token in their DoH url
+// and receive answers filtered with the policy of their child, the same way as over plain dns. The token the agent
+// authenticates with is not accepted, so the url found in the settings of the browser does not give access to the api.
+func HttpDnsQuery(_ *ServerConfig, filter *dnsfilter.Filter, policies dnsfilter.PolicySource, queryLog dnsfilter.QueryLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := chi.URLParam(r, "token")
+
+		policy, err := policies.PolicyForDohToken(r.Context(), token)
+		if err != nil {
+			log.Printf("error occured while trying to find policy for DoH token: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		if policy == nil {
+			respondWith404(w, r, ErrUnknownDeviceToken)
+			return
+		}
+
+		request, err := readDnsMessageFromRequest(w, r)
+		if err != nil {
+			return
+		}
+
+		response, decision, err := filter.Resolve(r.Context(), policy, request)
+		if err != nil {
+			log.Printf("error occured while trying to resolve DoH query: %v", err)
+
+			response = new(dns.Msg)
+			response.SetRcode(request, dns.RcodeServerFailure)
+		} else {
+			err = queryLog.LogQuery(r.Context(), policy, dnsfilter.TransportDoh, request.Question[0], decision)
+			if err != nil {
+				log.Printf("error occured while trying to log DoH query: %v", err)
+			}
+		}
+
+		packed, err := response.Pack()
+		if err != nil {
+			log.Printf("error occured while trying to pack DoH response: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", dnsMessageContentType)
+
+		if ttl, found := minimalTtl(response); found {
+			w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", ttl))
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(packed)
+		if err != nil {
+			log.Println("Error writing response:", err)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/dnsfilter"
+	"domanscy.group/parental-controls/server/domainrules"
+	"github.com/go-chi/chi"
+	"github.com/miekg/dns"
+)
+
+var fakeUpstreamAnswerIp = net.ParseIP("192.0.2.10")
+
+// startFakeDnsUpstream answers every A query with fakeUpstreamAnswerIp and a ttl of 300 seconds.
+func startFakeDnsUpstream(t *testing.T) string {
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upstream := &dns.Server{
+		PacketConn: packetConn,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, request *dns.Msg) {
+			response := new(dns.Msg)
+			response.SetReply(request)
+
+			if request.Question[0].Qtype == dns.TypeA {
+				response.Answer = append(response.Answer, &dns.A{
+					Hdr: dns.RR_Header{Name: request.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+					A:   fakeUpstreamAnswerIp,
+				})
+			}
+
+			err := w.WriteMsg(response)
+			if err != nil {
+				t.Error(err)
+			}
+		}),
+	}
+
+	go func() {
+		err := upstream.ActivateAndServe()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	t.Cleanup(func() {
+		err := upstream.Shutdown()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	return packetConn.LocalAddr().String()
+}
+
+func packDnsQuery(t *testing.T, domain string) []byte {
+	request := new(dns.Msg)
+	request.SetQuestion(dns.Fqdn(domain), dns.TypeA)
+	request.Id = 0
+
+	packed, err := request.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return packed
+}
+
+func unpackDnsResponse(t *testing.T, recorder *httptest.ResponseRecorder) *dns.Msg {
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+	}
+
+	if recorder.Header().Get("Content-Type") != dnsMessageContentType {
+		t.Errorf("Expected content type %s, received %s", dnsMessageContentType, recorder.Header().Get("Content-Type"))
+	}
+
+	response := new(dns.Msg)
+	err := response.Unpack(recorder.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+func TestHttpDnsQuery(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+
+	tx, err := db.Begin()
+	doTFatalIfErr(t, err)
+	_, err = domainrules.Create(tx, family.childId, "blocked.test", domainrules.ActionBlock)
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, tx.Commit())
+
+	filter := dnsfilter.NewFilter(startFakeDnsUpstream(t), nil)
+	handler := HttpDnsQuery(testingCfg, filter, dnsfilter.NewDatabasePolicySource(db, nil), dnsfilter.NewDatabaseQueryLog(db))
+
+	router := chi.NewRouter()
+	router.Get("/dns-query/{token}", handler)
+	router.Post("/dns-query/{token}", handler)
+
+	sendGet := func(token string, dnsParam string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/dns-query/"+token+"?dns="+dnsParam, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	sendPost := func(token string, contentType string, body []byte) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/dns-query/"+token, bytes.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("resolves allowed domains with GET", func(t *testing.T) {
+		recorder := sendGet(family.dohToken, base64.RawURLEncoding.EncodeToString(packDnsQuery(t, "allowed.test")))
+		response := unpackDnsResponse(t, recorder)
+
+		if len(response.Answer) != 1 || !response.Answer[0].(*dns.A).A.Equal(fakeUpstreamAnswerIp) {
+			t.Fatalf("Expected answer %s, received %v", fakeUpstreamAnswerIp, response.Answer)
+		}
+
+		if recorder.Header().Get("Cache-Control") != "max-age=300" {
+			t.Errorf("Expected Cache-Control max-age=300, received %s", recorder.Header().Get("Cache-Control"))
+		}
+	})
+
+	t.Run("blocks domains from the blocklist with POST", func(t *testing.T) {
+		recorder := sendPost(family.dohToken, dnsMessageContentType, packDnsQuery(t, "www.blocked.test"))
+		response := unpackDnsResponse(t, recorder)
+
+		if response.Rcode != dns.RcodeNameError {
+			t.Errorf("Expected NXDOMAIN, received %s", dns.RcodeToString[response.Rcode])
+		}
+	})
+
+	t.Run("logs queries as activity events", func(t *testing.T) {
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		events, err := activity.FindAllByChildIdBetween(tx, family.childId, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		if len(events) != 2 {
+			t.Fatalf("Expected 2 events, received %d", len(events))
+		}
+
+		var payload activity.DnsQueryPayload
+		doTFatalIfErr(t, json.Unmarshal(events[1].Payload, &payload))
+
+		expected := activity.DnsQueryPayload{Domain: "www.blocked.test", QueryType: "A", Transport: "doh", Blocked: true, Reason: "blocklist", Rule: "blocked.test"}
+		if payload != expected {
+			t.Errorf("Expected %+v, received %+v", expected, payload)
+		}
+
+		if events[1].Type != activity.TypeDnsQuery || events[1].DeviceId != family.deviceId {
+			t.Errorf("Expected dns query event of device %d, received %+v", family.deviceId, events[1])
+		}
+	})
+
+	t.Run("returns 404 for unknown device tokens", func(t *testing.T) {
+		recorder := sendGet("unknown", base64.RawURLEncoding.EncodeToString(packDnsQuery(t, "allowed.test")))
+
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("returns 404 for the token the agent authenticates with", func(t *testing.T) {
+		recorder := sendGet(family.deviceToken, base64.RawURLEncoding.EncodeToString(packDnsQuery(t, "allowed.test")))
+
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("returns 415 for POST with other content type", func(t *testing.T) {
+		recorder := sendPost(family.dohToken, "application/json", packDnsQuery(t, "allowed.test"))
+
+		if recorder.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusUnsupportedMediaType)
+		}
+	})
+
+	t.Run("returns 400 for invalid dns messages", func(t *testing.T) {
+		recorder := sendGet(family.dohToken, "not-a-dns-message")
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		recorder = sendPost(family.dohToken, dnsMessageContentType, []byte{1, 2, 3})
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/domainrules"
+	"github.com/go-chi/chi"
+)
+
+var ErrInvalidDomainRuleId = errors.New("invalid domain rule id")
+var ErrDomainRuleNotFound = errors.New("domain rule not found")
+
+type DomainRuleResponse struct {
+	Id        int       `json:"id"`
+	ChildId   int       `json:"childId"`
+	Domain    string    `json:"domain"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newDomainRuleResponses(rules []domainrules.Model) []DomainRuleResponse {
+	response := make([]DomainRuleResponse, 0, len(rules))
+
+	for _, rule := range rules {
+		response = append(response, DomainRuleResponse{
+			Id:        rule.Id,
+			ChildId:   rule.ChildId,
+			Domain:    rule.Domain,
+			Action:    string(rule.Action),
+			CreatedAt: rule.CreatedAt,
+		})
+	}
+
+	return response
+}
+
+type CreateDomainRuleRequestBody struct {
+	Domain string `json:"domain" validate:"required"`
+	Action string `json:"action" validate:"required,oneof=allow block"`
+}
+
+// HttpChildrenDomainRulesCreate allows or blocks a domain and its subdomains for the child. The dns filter reads the
+// rules of every query, so devices are not notified.
+func HttpChildrenDomainRulesCreate(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreateDomainRuleRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		_, err = domainrules.Create(tx, child.Id, requestBody.Domain, domainrules.Action(requestBody.Action))
+		if errors.Is(err, domainrules.ErrInvalidDomain) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if errors.Is(err, domainrules.ErrRuleForThisDomainAlreadyExists) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create domain rule: %v", err)
+			return
+		}
+
+		rules, err := domainrules.FindAllByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find domain rules: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusCreated, newDomainRuleResponses(rules))
+	}
+}
+
+func HttpChildrenDomainRulesList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		rules, err := domainrules.FindAllByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find domain rules: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, newDomainRuleResponses(rules))
+	}
+}
+
+func HttpChildrenDomainRulesDelete(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		ruleId, err := strconv.Atoi(chi.URLParam(r, "ruleId"))
+		if err != nil || ruleId <= 0 {
+			respondWith400(w, r, ErrInvalidDomainRuleId)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		deleted, err := domainrules.Delete(tx, child.Id, ruleId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to delete domain rule: %v", err)
+			return
+		}
+
+		if !deleted {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrDomainRuleNotFound)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
@@ -0,0 +1,8 @@
+CREATE TABLE domain_rules (
+    id INTEGER PRIMARY KEY,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    domain VARCHAR NOT NULL,
+    action VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (child_id, domain)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE path_rules (
+    id INTEGER PRIMARY KEY,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    domain VARCHAR NOT NULL,
+    path VARCHAR NOT NULL,
+    action VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (child_id, domain, path)
+);
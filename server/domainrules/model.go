@@ -0,0 +1,132 @@
+package domainrules
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDomain = errors.New("invalid domain")
+var ErrInvalidAction = errors.New("invalid action")
+var ErrRuleForThisDomainAlreadyExists = errors.New("rule for this domain already exists")
+
+type Action string
+
+const (
+	ActionAllow Action = "allow"
+	ActionBlock Action = "block"
+)
+
+func (action Action) IsValid() bool {
+	return action == ActionAllow || action == ActionBlock
+}
+
+type Model struct {
+	Id        int
+	ChildId   int
+	Domain    string
+	Action    Action
+	CreatedAt time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+// NormalizeDomain lowercases the domain and strips the trailing dot of a fully qualified name.
+// Rules always apply to the domain itself and all of its subdomains, so a leading "*." is stripped as well.
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimSuffix(domain, ".")
+	domain = strings.TrimPrefix(domain, "*.")
+
+	if domain == "" || len(domain) > 253 {
+		return "", ErrInvalidDomain
+	}
+
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", ErrInvalidDomain
+		}
+
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+				return "", ErrInvalidDomain
+			}
+		}
+	}
+
+	return domain, nil
+}
+
+func FindAllByChildId(db *sql.Tx, childId int) ([]Model, error) {
+	rows, err := db.Query("SELECT id, child_id, domain, action, created_at FROM domain_rules WHERE child_id = $1 ORDER BY id", childId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM domain_rules ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	rules := make([]Model, 0)
+
+	for rows.Next() {
+		rule := Model{}
+		err := rows.Scan(&rule.Id, &rule.ChildId, &rule.Domain, &rule.Action, &rule.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func Create(db *sql.Tx, childId int, domain string, action Action) (int, error) {
+	if !action.IsValid() {
+		return 0, ErrInvalidAction
+	}
+
+	domain, err := NormalizeDomain(domain)
+	if err != nil {
+		return 0, err
+	}
+
+	exec, err := db.Exec("INSERT INTO domain_rules (child_id, domain, action) VALUES (?, ?, ?);", childId, domain, action)
+	if err != nil {
+		if err.Error() == "UNIQUE constraint failed: domain_rules.child_id, domain_rules.domain" {
+			return 0, ErrRuleForThisDomainAlreadyExists
+		}
+
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO domain_rules ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+func Delete(db *sql.Tx, childId int, id int) (bool, error) {
+	executed, err := db.Exec("DELETE FROM domain_rules WHERE id = ? AND child_id = ?", id, childId)
+	if err != nil {
+		return false, fmt.Errorf("an error occured while trying to execute query 'DELETE FROM domain_rules ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	return affectedRows > 0, nil
+}
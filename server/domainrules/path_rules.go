@@ -0,0 +1,133 @@
+package domainrules
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+)
+
+// MaxPathLength is the longest path of a path rule.
+const MaxPathLength = 1024
+
+var ErrInvalidPath = errors.New("invalid path, it has to start with / and can not be / alone or contain a query")
+var ErrRuleForThisPathAlreadyExists = errors.New("rule for this path already exists")
+
+// PathRule allows or blocks the path and everything below it on the domain and its subdomains. Only the forward proxy
+// sees paths, the dns filter decides about whole domains.
+type PathRule struct {
+	Id        int
+	ChildId   int
+	Domain    string
+	Path      string
+	Action    Action
+	CreatedAt time.Time
+}
+
+//go:embed migration_path_rules.sql
+var PathRulesMigrationFile string
+
+// NormalizePath cleans the path and strips the trailing slash, "/@channel/" and "/@channel" are the same rule.
+// Paths are case sensitive, so they are not lowercased.
+func NormalizePath(rulePath string) (string, error) {
+	rulePath = strings.TrimSpace(rulePath)
+
+	if !strings.HasPrefix(rulePath, "/") || len(rulePath) > MaxPathLength || strings.ContainsAny(rulePath, "?# ") {
+		return "", ErrInvalidPath
+	}
+
+	rulePath = path.Clean(rulePath)
+	if rulePath == "/" {
+		return "", ErrInvalidPath
+	}
+
+	return rulePath, nil
+}
+
+// MatchesPath is true when the request path is the rule path or below it, "/@channel" does not match "/@channelfan".
+func (rule PathRule) MatchesPath(requestPath string) bool {
+	return requestPath == rule.Path || strings.HasPrefix(requestPath, rule.Path+"/")
+}
+
+// String returns the rule the way it is logged, e.g. "youtube.com/@channel".
+func (rule PathRule) String() string {
+	return rule.Domain + rule.Path
+}
+
+func FindAllPathRulesByChildId(db *sql.Tx, childId int) ([]PathRule, error) {
+	rows, err := db.Query("SELECT id, child_id, domain, path, action, created_at FROM path_rules WHERE child_id = $1 ORDER BY id", childId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM path_rules ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	rules := make([]PathRule, 0)
+
+	for rows.Next() {
+		rule := PathRule{}
+		err := rows.Scan(&rule.Id, &rule.ChildId, &rule.Domain, &rule.Path, &rule.Action, &rule.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func CreatePathRule(db *sql.Tx, childId int, domain string, rulePath string, action Action) (int, error) {
+	if !action.IsValid() {
+		return 0, ErrInvalidAction
+	}
+
+	domain, err := NormalizeDomain(domain)
+	if err != nil {
+		return 0, err
+	}
+
+	rulePath, err = NormalizePath(rulePath)
+	if err != nil {
+		return 0, err
+	}
+
+	exec, err := db.Exec("INSERT INTO path_rules (child_id, domain, path, action) VALUES (?, ?, ?, ?);", childId, domain, rulePath, action)
+	if err != nil {
+		if err.Error() == "UNIQUE constraint failed: path_rules.child_id, path_rules.domain, path_rules.path" {
+			return 0, ErrRuleForThisPathAlreadyExists
+		}
+
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO path_rules ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+func DeletePathRule(db *sql.Tx, childId int, id int) (bool, error) {
+	executed, err := db.Exec("DELETE FROM path_rules WHERE id = ? AND child_id = ?", id, childId)
+	if err != nil {
+		return false, fmt.Errorf("an error occured while trying to execute query 'DELETE FROM path_rules ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	return affectedRows > 0, nil
+}
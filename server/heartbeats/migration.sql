@@ -0,0 +1,24 @@
+CREATE TABLE device_heartbeats (
+    device_id INTEGER PRIMARY KEY REFERENCES devices (id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL,
+    sent_at TIMESTAMP NOT NULL,
+    received_at TIMESTAMP NOT NULL,
+    agent_version VARCHAR NOT NULL,
+    policy_hash VARCHAR NOT NULL,
+    policy_mismatch_since TIMESTAMP,
+    policy_mismatch_alerted BOOLEAN NOT NULL DEFAULT 0,
+    missing_alerted_at TIMESTAMP
+);
+
+CREATE TABLE tamper_events (
+    id INTEGER PRIMARY KEY,
+    device_id INTEGER NOT NULL REFERENCES devices (id) ON DELETE CASCADE,
+    type VARCHAR NOT NULL,
+    detail VARCHAR NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    alerted_at TIMESTAMP
+);
+
+CREATE INDEX tamper_events_device_id_created_at ON tamper_events (device_id, created_at);
+CREATE INDEX tamper_events_alerted_at ON tamper_events (alerted_at);
@@ -0,0 +1,303 @@
+package heartbeats
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Interval is how often agents send a heartbeat, the server tells them in the response.
+const Interval = time.Minute
+
+// PolicyMismatchGrace is how long an agent may enforce another policy than the current one before parents are alerted,
+// it fetches a changed policy within seconds, but may be offline for a while.
+const PolicyMismatchGrace = 10 * time.Minute
+
+// MaxTamperEvents is the number of tamper events a single heartbeat may carry.
+const MaxTamperEvents = 100
+
+// MaxDetailLength is counted in characters, not bytes.
+const MaxDetailLength = 500
+
+var ErrInvalidTamperType = errors.New("invalid tamper event type")
+var ErrDetailTooLong = fmt.Errorf("tamper event detail can not be longer than %d characters", MaxDetailLength)
+
+type TamperType string
+
+const (
+	// TamperClockChanged is reported when the wall clock moved other than the time since boot.
+	TamperClockChanged TamperType = "clock_changed"
+	// TamperUninstallAttempt is reported by the package scripts before the agent is removed.
+	TamperUninstallAttempt TamperType = "uninstall_attempt"
+	// TamperPolicyHashMismatch is recorded by the server when the agent kept enforcing another policy for longer than PolicyMismatchGrace.
+	TamperPolicyHashMismatch TamperType = "policy_hash_mismatch"
+)
+
+func (tamperType TamperType) IsValid() bool {
+	switch tamperType {
+	case TamperClockChanged, TamperUninstallAttempt, TamperPolicyHashMismatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsReportedByAgents tells whether agents may report the type, the rest is only recorded by the server.
+func (tamperType TamperType) IsReportedByAgents() bool {
+	return tamperType == TamperClockChanged || tamperType == TamperUninstallAttempt
+}
+
+// Model is the last heartbeat of a device, devices without an agent never have one.
+type Model struct {
+	DeviceId int
+	// Seq increases with every heartbeat of the agent, a heartbeat with a lower one is a replay.
+	Seq int64
+	// SentAt is the clock of the device, ReceivedAt the clock of the server.
+	SentAt       time.Time
+	ReceivedAt   time.Time
+	AgentVersion string
+	PolicyHash   string
+	// PolicyMismatchSince is zero while the agent enforces the current policy.
+	PolicyMismatchSince   time.Time
+	PolicyMismatchAlerted bool
+	// MissingAlertedAt is zero until parents were told that heartbeats stopped, a new heartbeat clears it.
+	MissingAlertedAt time.Time
+}
+
+type TamperEvent struct {
+	Id         int
+	DeviceId   int
+	Type       TamperType
+	Detail     string
+	OccurredAt time.Time
+	CreatedAt  time.Time
+	// AlertedAt is zero until the parents were emailed about the event.
+	AlertedAt time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+const selectColumns = "device_id, seq, sent_at, received_at, agent_version, policy_hash, policy_mismatch_since, policy_mismatch_alerted, missing_alerted_at"
+
+func scanHeartbeat(row interface{ Scan(dest ...any) error }) (*Model, error) {
+	heartbeat := &Model{}
+
+	var policyMismatchSince sql.NullTime
+	var missingAlertedAt sql.NullTime
+
+	err := row.Scan(
+		&heartbeat.DeviceId,
+		&heartbeat.Seq,
+		&heartbeat.SentAt,
+		&heartbeat.ReceivedAt,
+		&heartbeat.AgentVersion,
+		&heartbeat.PolicyHash,
+		&policyMismatchSince,
+		&heartbeat.PolicyMismatchAlerted,
+		&missingAlertedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeat.PolicyMismatchSince = policyMismatchSince.Time
+	heartbeat.MissingAlertedAt = missingAlertedAt.Time
+
+	return heartbeat, nil
+}
+
+func nullableTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func FindOneByDeviceId(db *sql.Tx, deviceId int) (*Model, error) {
+	heartbeat, err := scanHeartbeat(db.QueryRow("SELECT "+selectColumns+" FROM device_heartbeats WHERE device_id = $1", deviceId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return heartbeat, nil
+}
+
+// FindAllMissing returns the heartbeats received before the given time the parents were not alerted about yet.
+func FindAllMissing(db *sql.Tx, receivedBefore time.Time) ([]Model, error) {
+	rows, err := db.Query(
+		"SELECT "+selectColumns+" FROM device_heartbeats WHERE received_at < $1 AND missing_alerted_at IS NULL ORDER BY device_id",
+		receivedBefore.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM device_heartbeats ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	heartbeats := make([]Model, 0)
+
+	for rows.Next() {
+		heartbeat, err := scanHeartbeat(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		heartbeats = append(heartbeats, *heartbeat)
+	}
+
+	return heartbeats, nil
+}
+
+// Save stores the heartbeat as the last one of its device.
+func Save(db *sql.Tx, heartbeat *Model) error {
+	_, err := db.Exec(
+		`INSERT INTO device_heartbeats (device_id, seq, sent_at, received_at, agent_version, policy_hash, policy_mismatch_since, policy_mismatch_alerted, missing_alerted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (device_id) DO UPDATE SET
+			seq = excluded.seq,
+			sent_at = excluded.sent_at,
+			received_at = excluded.received_at,
+			agent_version = excluded.agent_version,
+			policy_hash = excluded.policy_hash,
+			policy_mismatch_since = excluded.policy_mismatch_since,
+			policy_mismatch_alerted = excluded.policy_mismatch_alerted,
+			missing_alerted_at = excluded.missing_alerted_at`,
+		heartbeat.DeviceId,
+		heartbeat.Seq,
+		heartbeat.SentAt.UTC(),
+		heartbeat.ReceivedAt.UTC(),
+		heartbeat.AgentVersion,
+		heartbeat.PolicyHash,
+		nullableTime(heartbeat.PolicyMismatchSince),
+		heartbeat.PolicyMismatchAlerted,
+		nullableTime(heartbeat.MissingAlertedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'INSERT INTO device_heartbeats ...': %w", err)
+	}
+
+	return nil
+}
+
+func MarkMissingAlerted(db *sql.Tx, deviceId int, alertedAt time.Time) error {
+	_, err := db.Exec("UPDATE device_heartbeats SET missing_alerted_at = ? WHERE device_id = ?", alertedAt.UTC(), deviceId)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE device_heartbeats ...': %w", err)
+	}
+
+	return nil
+}
+
+const selectTamperEventColumns = "id, device_id, type, detail, occurred_at, created_at, alerted_at"
+
+func scanTamperEvent(row interface{ Scan(dest ...any) error }) (*TamperEvent, error) {
+	event := &TamperEvent{}
+
+	var alertedAt sql.NullTime
+
+	err := row.Scan(&event.Id, &event.DeviceId, &event.Type, &event.Detail, &event.OccurredAt, &event.CreatedAt, &alertedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	event.AlertedAt = alertedAt.Time
+
+	return event, nil
+}
+
+func findAllTamperEvents(db *sql.Tx, query string, args ...any) ([]TamperEvent, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM tamper_events ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	events := make([]TamperEvent, 0)
+
+	for rows.Next() {
+		event, err := scanTamperEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		events = append(events, *event)
+	}
+
+	return events, nil
+}
+
+func CreateTamperEvent(db *sql.Tx, deviceId int, tamperType TamperType, detail string, occurredAt time.Time, createdAt time.Time) (int, error) {
+	if !tamperType.IsValid() {
+		return 0, ErrInvalidTamperType
+	}
+
+	detail = strings.TrimSpace(detail)
+	if utf8.RuneCountInString(detail) > MaxDetailLength {
+		return 0, ErrDetailTooLong
+	}
+
+	exec, err := db.Exec(
+		"INSERT INTO tamper_events (device_id, type, detail, occurred_at, created_at) VALUES (?, ?, ?, ?, ?)",
+		deviceId,
+		tamperType,
+		detail,
+		occurredAt.UTC(),
+		createdAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO tamper_events ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// FindRecentTamperEventsByDeviceId returns up to limit events of the device, the newest first.
+func FindRecentTamperEventsByDeviceId(db *sql.Tx, deviceId int, limit int) ([]TamperEvent, error) {
+	return findAllTamperEvents(
+		db,
+		"SELECT "+selectTamperEventColumns+" FROM tamper_events WHERE device_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2",
+		deviceId,
+		limit,
+	)
+}
+
+// FindAllNotAlertedTamperEvents returns the events parents were not emailed about yet, in order of their devices.
+func FindAllNotAlertedTamperEvents(db *sql.Tx) ([]TamperEvent, error) {
+	return findAllTamperEvents(db, "SELECT "+selectTamperEventColumns+" FROM tamper_events WHERE alerted_at IS NULL ORDER BY device_id, id")
+}
+
+func MarkTamperEventsAlerted(db *sql.Tx, ids []int, alertedAt time.Time) error {
+	for _, id := range ids {
+		_, err := db.Exec("UPDATE tamper_events SET alerted_at = ? WHERE id = ?", alertedAt.UTC(), id)
+		if err != nil {
+			return fmt.Errorf("an error occured while trying to execute query 'UPDATE tamper_events ...': %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package heartbeats
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/apprules"
+	"domanscy.group/parental-controls/server/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0019_heartbeats": MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestHeartbeats(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	now := time.Date(2024, 9, 1, 16, 0, 0, 0, time.UTC)
+
+	t.Run("saves the last heartbeat of a device", func(t *testing.T) {
+		heartbeat, err := FindOneByDeviceId(tx, 1)
+		if err != nil || heartbeat != nil {
+			t.Fatalf("Got %+v and %v, want nil for a device without heartbeats", heartbeat, err)
+		}
+
+		for seq := range 2 {
+			err = Save(tx, &Model{
+				DeviceId:            1,
+				Seq:                 int64(seq + 1),
+				SentAt:              now.Add(time.Duration(seq) * time.Minute),
+				ReceivedAt:          now.Add(time.Duration(seq) * time.Minute),
+				AgentVersion:        "1.0.0",
+				PolicyHash:          "abc",
+				PolicyMismatchSince: now,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		heartbeat, err = FindOneByDeviceId(tx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if heartbeat == nil || heartbeat.Seq != 2 || !heartbeat.ReceivedAt.Equal(now.Add(time.Minute)) || !heartbeat.PolicyMismatchSince.Equal(now) || !heartbeat.MissingAlertedAt.IsZero() {
+			t.Fatalf("Unexpected heartbeat: %+v", heartbeat)
+		}
+	})
+
+	t.Run("finds missing heartbeats until the parents were alerted", func(t *testing.T) {
+		err := Save(tx, &Model{DeviceId: 2, Seq: 1, SentAt: now.Add(-time.Hour), ReceivedAt: now.Add(-time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		missing, err := FindAllMissing(tx, now.Add(-15*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(missing) != 1 || missing[0].DeviceId != 2 {
+			t.Fatalf("Got %+v, want device 2", missing)
+		}
+
+		err = MarkMissingAlerted(tx, 2, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		missing, err = FindAllMissing(tx, now.Add(-15*time.Minute))
+		if err != nil || len(missing) != 0 {
+			t.Fatalf("Got %+v and %v, want none", missing, err)
+		}
+	})
+
+	t.Run("stores tamper events until they are alerted", func(t *testing.T) {
+		_, err := CreateTamperEvent(tx, 1, TamperType("rooted"), "", now, now)
+		if !errors.Is(err, ErrInvalidTamperType) {
+			t.Errorf("Expected %v, received %v", ErrInvalidTamperType, err)
+		}
+
+		_, err = CreateTamperEvent(tx, 1, TamperClockChanged, strings.Repeat("ą", MaxDetailLength+1), now, now)
+		if !errors.Is(err, ErrDetailTooLong) {
+			t.Errorf("Expected %v, received %v", ErrDetailTooLong, err)
+		}
+
+		first, err := CreateTamperEvent(tx, 1, TamperClockChanged, " -2h0m0s ", now.Add(-time.Minute), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second, err := CreateTamperEvent(tx, 1, TamperUninstallAttempt, "", now, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		events, err := FindAllNotAlertedTamperEvents(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events) != 2 || events[0].Id != first || events[0].Detail != "-2h0m0s" {
+			t.Fatalf("Unexpected events: %+v", events)
+		}
+
+		err = MarkTamperEventsAlerted(tx, []int{first}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		events, err = FindAllNotAlertedTamperEvents(tx)
+		if err != nil || len(events) != 1 || events[0].Id != second {
+			t.Fatalf("Got %+v and %v, want only the second event", events, err)
+		}
+
+		recent, err := FindRecentTamperEventsByDeviceId(tx, 1, 1)
+		if err != nil || len(recent) != 1 || recent[0].Id != second {
+			t.Fatalf("Got %+v and %v, want the newest event", recent, err)
+		}
+	})
+}
+
+func TestPolicyHash(t *testing.T) {
+	rules := []apprules.Model{
+		{Id: 1, MatchType: apprules.MatchName, Pattern: "minecraft", Action: apprules.ActionLimit, DailyLimitMinutes: 60},
+		{Id: 2, MatchType: apprules.MatchPath, Pattern: "/usr/games/*", Action: apprules.ActionBlock},
+	}
+
+	reordered := []apprules.Model{
+		{Id: 7, MatchType: apprules.MatchPath, Pattern: "/usr/games/*", Action: apprules.ActionBlock},
+		{Id: 8, MatchType: apprules.MatchName, Pattern: "minecraft", Action: apprules.ActionLimit, DailyLimitMinutes: 60},
+	}
+
+	if PolicyHash(rules, 120) != PolicyHash(reordered, 120) {
+		t.Error("Expected the same hash for the same rules in another order")
+	}
+
+	if PolicyHash(rules, 120) == PolicyHash(rules, 90) || PolicyHash(rules, 120) == PolicyHash(rules[:1], 120) {
+		t.Error("Expected another hash for another policy")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"seq":1}`)
+	signature := Sign("key", body)
+
+	if !VerifySignature("key", body, signature) {
+		t.Error("Expected a valid signature")
+	}
+
+	if VerifySignature("other", body, signature) || VerifySignature("key", []byte(`{"seq":2}`), signature) || VerifySignature("key", body, "zz") || VerifySignature("", body, Sign("", body)) {
+		t.Error("Expected an invalid signature")
+	}
+}
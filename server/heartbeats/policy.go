@@ -0,0 +1,52 @@
+package heartbeats
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	"domanscy.group/parental-controls/server/apprules"
+)
+
+type hashedRule struct {
+	MatchType         apprules.MatchType `json:"matchType"`
+	Pattern           string             `json:"pattern"`
+	Action            apprules.Action    `json:"action"`
+	DailyLimitMinutes int                `json:"dailyLimitMinutes"`
+}
+
+// PolicyHash summarizes the policy an agent enforces, the agent reports it in heartbeats and the server compares it
+// with the hash of the current policy. Ids and the order of the rules do not change the hash.
+func PolicyHash(rules []apprules.Model, dailyScreenTimeMinutes int) string {
+	hashedRules := make([]hashedRule, 0, len(rules))
+	for _, rule := range rules {
+		hashedRules = append(hashedRules, hashedRule{
+			MatchType:         rule.MatchType,
+			Pattern:           rule.Pattern,
+			Action:            rule.Action,
+			DailyLimitMinutes: rule.DailyLimitMinutes,
+		})
+	}
+
+	sort.Slice(hashedRules, func(i, j int) bool {
+		if hashedRules[i].MatchType != hashedRules[j].MatchType {
+			return hashedRules[i].MatchType < hashedRules[j].MatchType
+		}
+
+		return hashedRules[i].Pattern < hashedRules[j].Pattern
+	})
+
+	// Encoding a struct of plain values can not fail.
+	encoded, _ := json.Marshal(struct {
+		AppRules               []hashedRule `json:"appRules"`
+		DailyScreenTimeMinutes int          `json:"dailyScreenTimeMinutes"`
+	}{
+		AppRules:               hashedRules,
+		DailyScreenTimeMinutes: dailyScreenTimeMinutes,
+	})
+
+	sum := sha256.Sum256(encoded)
+
+	return hex.EncodeToString(sum[:])
+}
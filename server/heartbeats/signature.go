@@ -0,0 +1,29 @@
+package heartbeats
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body keyed with the heartbeat key of the device.
+const SignatureHeader = "X-Heartbeat-Signature"
+
+func Sign(key string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifySignature(key string, body []byte, signature string) bool {
+	decoded, err := hex.DecodeString(signature)
+	if err != nil || key == "" {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+
+	return hmac.Equal(decoded, mac.Sum(nil))
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/apprules"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/heartbeats"
+	"domanscy.group/parental-controls/server/reports"
+	"domanscy.group/parental-controls/server/webhooks"
+	"github.com/go-chi/chi"
+)
+
+const maxHeartbeatBodyBytes = 64 << 10
+
+// recentTamperEventsLimit is the number of tamper events shown in the health of a device.
+const recentTamperEventsLimit = 20
+
+var ErrInvalidHeartbeatSignature = errors.New("invalid heartbeat signature")
+var ErrStaleHeartbeat = errors.New("heartbeat is older than the last one received")
+var ErrTooManyTamperEvents = fmt.Errorf("heartbeat can not carry more than %d tamper events", heartbeats.MaxTamperEvents)
+
+type HeartbeatResponse struct {
+	// PolicyHash is the hash of the current policy, an agent reporting another one fetches the policy again.
+	PolicyHash      string `json:"policyHash"`
+	IntervalSeconds int    `json:"intervalSeconds"`
+}
+
+type DeviceHealthStatus string
+
+const (
+	DeviceHealthNeverSeen DeviceHealthStatus = "never_seen"
+	DeviceHealthOk        DeviceHealthStatus = "ok"
+	DeviceHealthMissing   DeviceHealthStatus = "missing"
+)
+
+type TamperEventResponse struct {
+	Type       string    `json:"type"`
+	Detail     string    `json:"detail"`
+	OccurredAt time.Time `json:"occurredAt"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type DeviceHealthResponse struct {
+	DeviceId int                `json:"deviceId"`
+	Status   DeviceHealthStatus `json:"status"`
+	// LastHeartbeatAt and AgentVersion are empty for devices that never sent a heartbeat.
+	LastHeartbeatAt *time.Time            `json:"lastHeartbeatAt"`
+	AgentVersion    string                `json:"agentVersion"`
+	PolicyUpToDate  bool                  `json:"policyUpToDate"`
+	TamperEvents    []TamperEventResponse `json:"tamperEvents"`
+}
+
+// currentPolicyHash hashes the policy agents of the child enforce now, see heartbeats.PolicyHash.
+func currentPolicyHash(cfg *ServerConfig, tx *sql.Tx, child *children.Model, now time.Time) (string, error) {
+	rules, err := apprules.FindAllByChildId(tx, child.Id)
+	if err != nil {
+		return "", err
+	}
+
+	dailyMinutes, err := effectiveDailyScreenTimeMinutes(tx, child, reports.StartOfDay(now, cfg.ReportsLocation))
+	if err != nil {
+		return "", err
+	}
+
+	return heartbeats.PolicyHash(rules, dailyMinutes), nil
+}
+
+type TamperEventRequestBody struct {
+	Type       string    `json:"type"`
+	Detail     string    `json:"detail"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+type HeartbeatRequestBody struct {
+	Seq          int64                    `json:"seq"`
+	SentAt       time.Time                `json:"sentAt"`
+	AgentVersion string                   `json:"agentVersion"`
+	PolicyHash   string                   `json:"policyHash"`
+	TamperEvents []TamperEventRequestBody `json:"tamperEvents"`
+}
+
+// HttpDeviceHeartbeatsCreate records a heartbeat of the agent. The body is signed with the heartbeat key of the device,
+// which only the agent knows, so a heartbeat can not be faked with the device token alone.
+func HttpDeviceHeartbeatsCreate(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device := authenticatedDevice(r)
+		now := time.Now()
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHeartbeatBodyBytes))
+
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			respondWithError(w, r, http.StatusRequestEntityTooLarge, nil)
+			return
+		} else if err != nil {
+			respondWith400(w, r, ErrInvalidJsonPayload)
+			return
+		}
+
+		if !heartbeats.VerifySignature(device.HeartbeatKey, body, r.Header.Get(heartbeats.SignatureHeader)) {
+			respondWith401(w, r, ErrInvalidHeartbeatSignature)
+			return
+		}
+
+		requestBody, err := decodeJson[HeartbeatRequestBody](w, r, bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+
+		if requestBody.Seq <= 0 || requestBody.SentAt.IsZero() {
+			respondWith400(w, r, ErrInvalidJsonPayload)
+			return
+		}
+
+		if len(requestBody.TamperEvents) > heartbeats.MaxTamperEvents {
+			respondWith400(w, r, ErrTooManyTamperEvents)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		previous, err := heartbeats.FindOneByDeviceId(tx, device.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find the last heartbeat: %v", err)
+			return
+		}
+
+		if previous != nil && requestBody.Seq <= previous.Seq {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, ErrStaleHeartbeat)
+			return
+		}
+
+		child, err := children.FindOneById(tx, device.ChildId)
+		if err != nil || child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child of the device: %v", err)
+			return
+		}
+
+		policyHash, err := currentPolicyHash(cfg, tx, child, now)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to hash the policy: %v", err)
+			return
+		}
+
+		heartbeat := &heartbeats.Model{
+			DeviceId:     device.Id,
+			Seq:          requestBody.Seq,
+			SentAt:       requestBody.SentAt,
+			ReceivedAt:   now,
+			AgentVersion: requestBody.AgentVersion,
+			PolicyHash:   requestBody.PolicyHash,
+		}
+
+		if requestBody.PolicyHash != policyHash {
+			heartbeat.PolicyMismatchSince = now
+			if previous != nil && !previous.PolicyMismatchSince.IsZero() {
+				heartbeat.PolicyMismatchSince = previous.PolicyMismatchSince
+				heartbeat.PolicyMismatchAlerted = previous.PolicyMismatchAlerted
+			}
+		}
+
+		for i, event := range requestBody.TamperEvents {
+			// the server records policy mismatches itself, an agent reporting one could alert parents with any detail
+			if !heartbeats.TamperType(event.Type).IsReportedByAgents() {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWithError(w, r, http.StatusBadRequest, fmt.Errorf("tamper event %d: %v", i, heartbeats.ErrInvalidTamperType), newFieldError(fmt.Sprintf("tamperEvents[%d]", i), heartbeats.ErrInvalidTamperType))
+				return
+			}
+
+			_, err = heartbeats.CreateTamperEvent(tx, device.Id, heartbeats.TamperType(event.Type), event.Detail, event.OccurredAt, now)
+			if errors.Is(err, heartbeats.ErrInvalidTamperType) || errors.Is(err, heartbeats.ErrDetailTooLong) {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWithError(w, r, http.StatusBadRequest, fmt.Errorf("tamper event %d: %v", i, err), newFieldError(fmt.Sprintf("tamperEvents[%d]", i), err))
+				return
+			} else if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to store tamper event: %v", err)
+				return
+			}
+
+			err = enqueueWebhookEvent(tx, child, webhooks.EventTamperDetected, "", WebhookEventData{TamperEvent: &WebhookTamperEvent{
+				DeviceId:   device.Id,
+				Type:       event.Type,
+				Detail:     event.Detail,
+				OccurredAt: event.OccurredAt,
+			}}, now)
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to enqueue webhook event: %v", err)
+				return
+			}
+		}
+
+		if !heartbeat.PolicyMismatchSince.IsZero() && !heartbeat.PolicyMismatchAlerted && now.Sub(heartbeat.PolicyMismatchSince) >= heartbeats.PolicyMismatchGrace {
+			detail := fmt.Sprintf("agent enforces policy %s instead of %s", requestBody.PolicyHash, policyHash)
+
+			_, err = heartbeats.CreateTamperEvent(tx, device.Id, heartbeats.TamperPolicyHashMismatch, detail, heartbeat.PolicyMismatchSince, now)
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to store tamper event: %v", err)
+				return
+			}
+
+			err = enqueueWebhookEvent(tx, child, webhooks.EventTamperDetected, "", WebhookEventData{TamperEvent: &WebhookTamperEvent{
+				DeviceId:   device.Id,
+				Type:       string(heartbeats.TamperPolicyHashMismatch),
+				Detail:     detail,
+				OccurredAt: heartbeat.PolicyMismatchSince,
+			}}, now)
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to enqueue webhook event: %v", err)
+				return
+			}
+
+			heartbeat.PolicyMismatchAlerted = true
+		}
+
+		err = heartbeats.Save(tx, heartbeat)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to save heartbeat: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, HeartbeatResponse{
+			PolicyHash:      policyHash,
+			IntervalSeconds: int(heartbeats.Interval.Seconds()),
+		})
+	}
+}
+
+// HttpDevicesHealth tells the dashboard whether the agent of the device is running and enforcing the current policy.
+func HttpDevicesHealth(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deviceId, err := strconv.Atoi(chi.URLParam(r, "deviceId"))
+		if err != nil || deviceId <= 0 {
+			respondWith400(w, r, ErrInvalidDeviceId)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		device, err := devices.FindOneById(tx, deviceId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find device: %v", err)
+			return
+		}
+
+		var child *children.Model
+		if device != nil {
+			child, err = children.FindOneByIdAndOwnerUserId(tx, device.ChildId, authenticatedUserId(r))
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to find child: %v", err)
+				return
+			}
+		}
+
+		if child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrDeviceNotFound)
+			return
+		}
+
+		heartbeat, err := heartbeats.FindOneByDeviceId(tx, device.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find the last heartbeat: %v", err)
+			return
+		}
+
+		policyHash, err := currentPolicyHash(cfg, tx, child, time.Now())
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to hash the policy: %v", err)
+			return
+		}
+
+		tamperEvents, err := heartbeats.FindRecentTamperEventsByDeviceId(tx, device.Id, recentTamperEventsLimit)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find tamper events: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := DeviceHealthResponse{
+			DeviceId:     device.Id,
+			Status:       DeviceHealthNeverSeen,
+			TamperEvents: make([]TamperEventResponse, 0, len(tamperEvents)),
+		}
+
+		if heartbeat != nil {
+			response.Status = DeviceHealthOk
+			if time.Since(heartbeat.ReceivedAt) > cfg.HeartbeatTimeout {
+				response.Status = DeviceHealthMissing
+			}
+
+			response.LastHeartbeatAt = &heartbeat.ReceivedAt
+			response.AgentVersion = heartbeat.AgentVersion
+			response.PolicyUpToDate = heartbeat.PolicyHash == policyHash
+		}
+
+		for _, event := range tamperEvents {
+			response.TamperEvents = append(response.TamperEvents, TamperEventResponse{
+				Type:       string(event.Type),
+				Detail:     event.Detail,
+				OccurredAt: event.OccurredAt,
+				CreatedAt:  event.CreatedAt,
+			})
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
+
+type HeartbeatKeyResponse struct {
+	// HeartbeatKey is shown once, the agent has to be configured with it before its heartbeats are accepted again.
+	HeartbeatKey string `json:"heartbeatKey"`
+}
+
+// HttpDevicesHeartbeatKeyRotate gives the device a new heartbeat key, for devices created before keys were shown to parents
+// and for keys that leaked. Heartbeats signed with the previous key are rejected.
+func HttpDevicesHeartbeatKeyRotate(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deviceId, err := strconv.Atoi(chi.URLParam(r, "deviceId"))
+		if err != nil || deviceId <= 0 {
+			respondWith400(w, r, ErrInvalidDeviceId)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		device := findOwnedDeviceAndHandleError(w, r, tx, deviceId)
+		if device == nil {
+			return
+		}
+
+		heartbeatKey, err := devices.RotateHeartbeatKey(tx, device.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to rotate heartbeat key: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, HeartbeatKeyResponse{HeartbeatKey: heartbeatKey})
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/heartbeats"
+	"github.com/go-chi/chi"
+	"mailpitsuite"
+)
+
+func findTestHeartbeatKey(t *testing.T, db *sql.DB, deviceId int) string {
+	tx, err := db.Begin()
+	doTFatalIfErr(t, err)
+
+	device, err := devices.FindOneById(tx, deviceId)
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, tx.Commit())
+
+	return device.HeartbeatKey
+}
+
+func TestHttpHeartbeats(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+	heartbeatKey := findTestHeartbeatKey(t, db, family.deviceId)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateDeviceToken(db)).Post("/device/heartbeats", HttpDeviceHeartbeatsCreate(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/devices/{deviceId}/health", HttpDevicesHealth(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/devices/{deviceId}/heartbeat_key", HttpDevicesHeartbeatKeyRotate(testingCfg, db))
+
+	sendHeartbeat := func(key string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/device/heartbeats", strings.NewReader(body))
+		request.Header.Set("Authorization", "Device "+family.deviceToken)
+		request.Header.Set(heartbeats.SignatureHeader, heartbeats.Sign(key, []byte(body)))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	getHealth := func(userId int, deviceId int) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:8080/devices/%d/health", deviceId), nil)
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	heartbeatBody := func(seq int, policyHash string, tamperEvents string) string {
+		return fmt.Sprintf(`{"seq": %d, "sentAt": %q, "agentVersion": "1.2.0", "policyHash": %q, "tamperEvents": [%s]}`, seq, time.Now().UTC().Format(time.RFC3339), policyHash, tamperEvents)
+	}
+
+	var currentPolicy string
+
+	t.Run("never seen devices are reported as such", func(t *testing.T) {
+		recorder := getHealth(family.userId, family.deviceId)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response DeviceHealthResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if response.Status != DeviceHealthNeverSeen || response.LastHeartbeatAt != nil || len(response.TamperEvents) != 0 {
+			t.Fatalf("Unexpected health: %+v", response)
+		}
+	})
+
+	t.Run("rejects heartbeats without a valid signature", func(t *testing.T) {
+		recorder := sendHeartbeat("notthekey", heartbeatBody(1, "", ""))
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusUnauthorized)
+		}
+
+		request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/device/heartbeats", strings.NewReader(heartbeatBody(1, "", "")))
+		request.Header.Set("Authorization", "Device "+family.deviceToken)
+
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("Got %d without a signature, want %d", recorder.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("records the heartbeat and returns the current policy hash", func(t *testing.T) {
+		recorder := sendHeartbeat(heartbeatKey, heartbeatBody(1, "", `{"type": "clock_changed", "detail": "-2h0m0s", "occurredAt": "2024-09-01T16:00:00Z"}`))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response HeartbeatResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if response.PolicyHash == "" || response.IntervalSeconds != 60 {
+			t.Fatalf("Unexpected response: %+v", response)
+		}
+
+		currentPolicy = response.PolicyHash
+	})
+
+	t.Run("rejects replayed heartbeats and invalid tamper events", func(t *testing.T) {
+		recorder := sendHeartbeat(heartbeatKey, heartbeatBody(1, currentPolicy, ""))
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusConflict)
+		}
+
+		recorder = sendHeartbeat(heartbeatKey, heartbeatBody(2, currentPolicy, `{"type": "rooted", "occurredAt": "2024-09-01T16:00:00Z"}`))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		recorder = sendHeartbeat(heartbeatKey, heartbeatBody(2, currentPolicy, `{"type": "policy_hash_mismatch", "detail": "fake", "occurredAt": "2024-09-01T16:00:00Z"}`))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d for a tamper event only the server records, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		for _, body := range []string{`{"seq": 2, "sentAt": "2024-09-01T16:00:00Z", "battery": 80}`, heartbeatBody(2, currentPolicy, "") + `{}`} {
+			recorder = sendHeartbeat(heartbeatKey, body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("reports the health of the device", func(t *testing.T) {
+		recorder := sendHeartbeat(heartbeatKey, heartbeatBody(2, currentPolicy, ""))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		recorder = getHealth(family.userId, family.deviceId)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response DeviceHealthResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if response.Status != DeviceHealthOk || response.LastHeartbeatAt == nil || response.AgentVersion != "1.2.0" || !response.PolicyUpToDate {
+			t.Fatalf("Unexpected health: %+v", response)
+		}
+
+		if len(response.TamperEvents) != 1 || response.TamperEvents[0].Type != string(heartbeats.TamperClockChanged) || response.TamperEvents[0].Detail != "-2h0m0s" {
+			t.Fatalf("Unexpected tamper events: %+v", response.TamperEvents)
+		}
+
+		recorder = getHealth(stranger.userId, family.deviceId)
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d for a stranger, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("records a tamper event when the agent keeps enforcing another policy", func(t *testing.T) {
+		recorder := sendHeartbeat(heartbeatKey, heartbeatBody(3, "outdated", ""))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		heartbeat, err := heartbeats.FindOneByDeviceId(tx, family.deviceId)
+		doTFatalIfErr(t, err)
+
+		// the agent may have been offline while the policy changed, parents are alerted only after the grace period
+		heartbeat.PolicyMismatchSince = time.Now().Add(-heartbeats.PolicyMismatchGrace - time.Minute)
+		doTFatalIfErr(t, heartbeats.Save(tx, heartbeat))
+		doTFatalIfErr(t, tx.Commit())
+
+		for seq := 4; seq <= 5; seq++ {
+			recorder = sendHeartbeat(heartbeatKey, heartbeatBody(seq, "outdated", ""))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+			}
+		}
+
+		recorder = getHealth(family.userId, family.deviceId)
+
+		var response DeviceHealthResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if response.PolicyUpToDate || len(response.TamperEvents) != 2 || response.TamperEvents[0].Type != string(heartbeats.TamperPolicyHashMismatch) {
+			t.Fatalf("Expected a single policy mismatch event, received %+v", response)
+		}
+	})
+
+	t.Run("rotates the heartbeat key", func(t *testing.T) {
+		rotate := func(userId int) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:8080/devices/%d/heartbeat_key", family.deviceId), nil)
+			request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			return recorder
+		}
+
+		recorder := rotate(stranger.userId)
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d for a stranger, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = rotate(family.userId)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response HeartbeatKeyResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if response.HeartbeatKey == "" || response.HeartbeatKey == heartbeatKey {
+			t.Fatalf("Expected a new heartbeat key, received %+v", response)
+		}
+
+		recorder = sendHeartbeat(heartbeatKey, heartbeatBody(6, currentPolicy, ""))
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("Got %d with the previous key, want %d", recorder.Code, http.StatusUnauthorized)
+		}
+
+		recorder = sendHeartbeat(response.HeartbeatKey, heartbeatBody(6, currentPolicy, ""))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d with the new key, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+	})
+}
+
+func TestSendDeviceAlerts(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	now := time.Now()
+
+	tx, err := db.Begin()
+	doTFatalIfErr(t, err)
+
+	lastSeen := now.Add(-time.Hour)
+	doTFatalIfErr(t, heartbeats.Save(tx, &heartbeats.Model{DeviceId: family.deviceId, Seq: 1, SentAt: lastSeen, ReceivedAt: lastSeen}))
+
+	_, err = heartbeats.CreateTamperEvent(tx, family.deviceId, heartbeats.TamperUninstallAttempt, "", lastSeen, lastSeen)
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, tx.Commit())
+
+	mailpit := initializeMailpitAndDeleteAllMessages(t)
+	defer func(mailpit *mailpitsuite.Api) {
+		err := mailpit.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(mailpit)
+
+	doTFatalIfErr(t, sendDeviceAlerts(context.Background(), testingCfg, db, now))
+
+	messages, err := mailpit.GetAllMessages()
+	if err != nil {
+		t.Fatalf("failed to get mailpit messages: %s", err.Error())
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("Got %d messages, want 1", len(messages))
+	}
+
+	messageSummary, err := mailpit.GetMessageSummary(messages[0].ID)
+	if err != nil {
+		t.Fatalf("failed to get message summary: %s", err.Error())
+	}
+
+	for _, expected := range []string{"Laptop", "Adam", "nie odzywa się", "Próba odinstalowania aplikacji"} {
+		if !strings.Contains(messageSummary.HTML, expected) {
+			t.Fatalf("Alert does not contain %q: %s", expected, messageSummary.HTML)
+		}
+	}
+
+	// parents are alerted once, not every minute until the device comes back
+	doTFatalIfErr(t, sendDeviceAlerts(context.Background(), testingCfg, db, now.Add(time.Minute)))
+
+	messages, err = mailpit.GetAllMessages()
+	if err != nil {
+		t.Fatalf("failed to get mailpit messages: %s", err.Error())
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("Got %d messages after second run, want 1", len(messages))
+	}
+}
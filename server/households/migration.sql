@@ -0,0 +1,6 @@
+CREATE TABLE households (
+    id INTEGER PRIMARY KEY,
+    owner_user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+ALTER TABLE households ADD COLUMN activity_retention_days INTEGER NOT NULL DEFAULT 30;
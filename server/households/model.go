@@ -0,0 +1,144 @@
+package households
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultActivityRetentionDays is how long raw activity events are kept unless the parent changes it.
+// Rollups of the activity are kept longer, so reports still work after the raw events are deleted.
+const DefaultActivityRetentionDays = 30
+
+const MinActivityRetentionDays = 1
+const MaxActivityRetentionDays = 365
+
+var ErrNameCannotBeEmpty = errors.New("household name can not be empty")
+var ErrInvalidActivityRetentionDays = fmt.Errorf("activity retention must be between %d and %d days", MinActivityRetentionDays, MaxActivityRetentionDays)
+
+type Model struct {
+	Id                    int
+	OwnerUserId           int
+	Name                  string
+	ActivityRetentionDays int
+	CreatedAt             time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+//go:embed migration_retention.sql
+var RetentionMigrationFile string
+
+const selectColumns = "id, owner_user_id, name, activity_retention_days, created_at"
+
+func scanHousehold(row interface{ Scan(dest ...any) error }, household *Model) error {
+	return row.Scan(&household.Id, &household.OwnerUserId, &household.Name, &household.ActivityRetentionDays, &household.CreatedAt)
+}
+
+func FindOneById(db *sql.Tx, id int) (*Model, error) {
+	row := db.QueryRow("SELECT "+selectColumns+" FROM households WHERE id = $1", id)
+
+	household := &Model{}
+
+	err := scanHousehold(row, household)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return household, nil
+}
+
+func FindAllByOwnerUserId(db *sql.Tx, ownerUserId int) ([]Model, error) {
+	rows, err := db.Query("SELECT "+selectColumns+" FROM households WHERE owner_user_id = $1 ORDER BY id", ownerUserId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM households ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	households := make([]Model, 0)
+
+	for rows.Next() {
+		household := Model{}
+		err := scanHousehold(rows, &household)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		households = append(households, household)
+	}
+
+	return households, nil
+}
+
+// FindAllOwnerUserIds returns every user owning at least one household.
+func FindAllOwnerUserIds(db *sql.Tx) ([]int, error) {
+	rows, err := db.Query("SELECT DISTINCT owner_user_id FROM households ORDER BY owner_user_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM households ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	ownerUserIds := make([]int, 0)
+
+	for rows.Next() {
+		var ownerUserId int
+
+		err := rows.Scan(&ownerUserId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		ownerUserIds = append(ownerUserIds, ownerUserId)
+	}
+
+	return ownerUserIds, nil
+}
+
+func Create(db *sql.Tx, ownerUserId int, name string) (int, error) {
+	if name == "" {
+		return 0, ErrNameCannotBeEmpty
+	}
+
+	exec, err := db.Exec("INSERT INTO households (owner_user_id, name) VALUES (?, ?);", ownerUserId, name)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO households ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+func UpdateActivityRetentionDays(db *sql.Tx, id int, days int) error {
+	if days < MinActivityRetentionDays || days > MaxActivityRetentionDays {
+		return ErrInvalidActivityRetentionDays
+	}
+
+	_, err := db.Exec("UPDATE households SET activity_retention_days = $1 WHERE id = $2", days, id)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE households ...': %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/households"
+	"github.com/go-chi/chi"
+)
+
+var ErrInvalidHouseholdId = errors.New("invalid household id")
+var ErrHouseholdNotFound = errors.New("household not found")
+
+func parseHouseholdIdAndHandleErrorIfInvalid(w http.ResponseWriter, r *http.Request) (int, error) {
+	householdId, err := strconv.Atoi(chi.URLParam(r, "householdId"))
+	if err != nil || householdId <= 0 {
+		respondWith400(w, r, ErrInvalidHouseholdId)
+		return 0, ErrInvalidHouseholdId
+	}
+
+	return householdId, nil
+}
+
+// findOwnedHouseholdAndHandleError finds the household of the authenticated parent, it responds and returns nil if there is none.
+func findOwnedHouseholdAndHandleError(w http.ResponseWriter, r *http.Request, tx *sql.Tx, householdId int) *households.Model {
+	household, err := households.FindOneById(tx, householdId)
+	if err != nil {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith500(w, r, nil)
+		log.Printf("error occured while trying to find household: %v", err)
+		return nil
+	}
+
+	if household == nil || household.OwnerUserId != authenticatedUserId(r) {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith404(w, r, ErrHouseholdNotFound)
+		return nil
+	}
+
+	return household
+}
+
+type HouseholdResponse struct {
+	Id                    int       `json:"id"`
+	Name                  string    `json:"name"`
+	ActivityRetentionDays int       `json:"activityRetentionDays"`
+	CreatedAt             time.Time `json:"createdAt"`
+}
+
+func newHouseholdResponse(household *households.Model) HouseholdResponse {
+	return HouseholdResponse{
+		Id:                    household.Id,
+		Name:                  household.Name,
+		ActivityRetentionDays: household.ActivityRetentionDays,
+		CreatedAt:             household.CreatedAt,
+	}
+}
+
+type CreateHouseholdRequestBody struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// HttpHouseholdsCreate creates a household owned by the authenticated parent.
+func HttpHouseholdsCreate(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestBody, err := decodeJsonRequestBody[CreateHouseholdRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		householdId, err := households.Create(tx, authenticatedUserId(r), requestBody.Name)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create household: %v", err)
+			return
+		}
+
+		household, err := households.FindOneById(tx, householdId)
+		if err != nil || household == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find created household: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusCreated, newHouseholdResponse(household))
+	}
+}
+
+// HttpHouseholdsList lists the households owned by the authenticated parent.
+func HttpHouseholdsList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		ownedHouseholds, err := households.FindAllByOwnerUserId(tx, authenticatedUserId(r))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find households: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := make([]HouseholdResponse, 0, len(ownedHouseholds))
+
+		for i := range ownedHouseholds {
+			response = append(response, newHouseholdResponse(&ownedHouseholds[i]))
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
+
+type UpdateActivityRetentionRequestBody struct {
+	Days int `json:"days"`
+}
+
+// HttpHouseholdsUpdateActivityRetention changes how many days raw activity events of children in the household are kept.
+// Older events and the domains queried on those days are deleted by the next activity compaction, reports keep working
+// from the totals of their rollups.
+func HttpHouseholdsUpdateActivityRetention(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[UpdateActivityRetentionRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		if requestBody.Days < households.MinActivityRetentionDays || requestBody.Days > households.MaxActivityRetentionDays {
+			respondWith400(w, r, households.ErrInvalidActivityRetentionDays)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household, err := households.FindOneById(tx, householdId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find household: %v", err)
+			return
+		}
+
+		if household == nil || household.OwnerUserId != authenticatedUserId(r) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrHouseholdNotFound)
+			return
+		}
+
+		err = households.UpdateActivityRetentionDays(tx, household.Id, requestBody.Days)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to update activity retention: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
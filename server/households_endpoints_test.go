@@ -0,0 +1,163 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"domanscy.group/parental-controls/server/households"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpHouseholdsCreateAndList(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/households", HttpHouseholdsCreate(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/households", HttpHouseholdsList(testingCfg, db))
+
+	sendRequest := func(method string, userId int, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080/households", strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	listHouseholds := func(t *testing.T, userId int) []HouseholdResponse {
+		recorder := sendRequest(http.MethodGet, userId, "")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response []HouseholdResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		return response
+	}
+
+	var created HouseholdResponse
+
+	t.Run("creates a household", func(t *testing.T) {
+		recorder := sendRequest(http.MethodPost, family.userId, `{"name": "Cottage"}`)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+
+		if created.Id <= 0 || created.Name != "Cottage" || created.ActivityRetentionDays != households.DefaultActivityRetentionDays {
+			t.Fatalf("Unexpected household %+v", created)
+		}
+	})
+
+	t.Run("rejects a household without a name", func(t *testing.T) {
+		for _, body := range []string{`{"name": ""}`, `{}`} {
+			recorder := sendRequest(http.MethodPost, family.userId, body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("lists only the households of the parent", func(t *testing.T) {
+		owned := listHouseholds(t, family.userId)
+		if len(owned) != 2 || owned[0].Id != family.householdId || owned[1] != created {
+			t.Fatalf("Unexpected households %+v", owned)
+		}
+
+		strangers := listHouseholds(t, stranger.userId)
+		if len(strangers) != 1 || strangers[0].Id != stranger.householdId {
+			t.Fatalf("Unexpected households of the stranger %+v", strangers)
+		}
+	})
+}
+
+func TestHttpHouseholdsUpdateActivityRetention(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Put("/households/{householdId}/activity_retention", HttpHouseholdsUpdateActivityRetention(testingCfg, db))
+
+	sendRequest := func(userId int, householdId int, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:8080/households/%d/activity_retention", householdId), strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	findRetentionDays := func(t *testing.T) int {
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		household, err := households.FindOneById(tx, family.householdId)
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		return household.ActivityRetentionDays
+	}
+
+	t.Run("defaults to 30 days", func(t *testing.T) {
+		if days := findRetentionDays(t); days != households.DefaultActivityRetentionDays {
+			t.Fatalf("Got %d, want %d", days, households.DefaultActivityRetentionDays)
+		}
+	})
+
+	t.Run("updates the retention", func(t *testing.T) {
+		recorder := sendRequest(family.userId, family.householdId, `{"days": 7}`)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		if days := findRetentionDays(t); days != 7 {
+			t.Fatalf("Got %d, want 7", days)
+		}
+	})
+
+	t.Run("rejects retention out of range", func(t *testing.T) {
+		for _, body := range []string{`{"days": 0}`, `{"days": 366}`} {
+			recorder := sendRequest(family.userId, family.householdId, body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("does not update households of other parents", func(t *testing.T) {
+		recorder := sendRequest(stranger.userId, family.householdId, `{"days": 1}`)
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		if days := findRetentionDays(t); days != 7 {
+			t.Fatalf("Got %d, want 7", days)
+		}
+	})
+}
@@ -0,0 +1,857 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/accessrequests"
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/apprules"
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/calendar"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/commands"
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/domainrules"
+	"domanscy.group/parental-controls/server/heartbeats"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/policytemplates"
+	"domanscy.group/parental-controls/server/reports"
+	"domanscy.group/parental-controls/server/rewards"
+	"domanscy.group/parental-controls/server/timeextensions"
+	"domanscy.group/parental-controls/server/timetokens"
+	"domanscy.group/parental-controls/server/users"
+	"domanscy.group/parental-controls/server/validation"
+	"domanscy.group/parental-controls/server/webhooks"
+)
+
+// ErrorResponse is the body of error responses for clients preferring application/json, the others get the message
+// as plain text, see respondWithError.
+type ErrorResponse struct {
+	Error ApiError `json:"error"`
+}
+
+type ApiError struct {
+	// Code is meant for programs and does not change, e.g. "invalid_email", Message is meant for people and is in
+	// the language of the request.
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	RequestId string       `json:"requestId"`
+	Details   []FieldError `json:"details,omitempty"`
+}
+
+// FieldError describes an invalid field of the request, Field is its path in the json body, e.g. "events[2]".
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// rule is the broken validation rule, its arguments format the translated message, e.g. the maximum length.
+	rule *validation.FieldError
+}
+
+var ErrInvalidFields = errors.New("request contains invalid fields")
+
+// errorCodes are the codes of the sentinels the handlers respond with, found with errors.Is so wrapped sentinels keep
+// their code. Sentinels of different packages with the same message, e.g. "invalid action", share the code.
+var errorCodes = map[error]string{
+	// http_errors.go
+	ErrInvalidFields: "invalid_fields",
+
+	// activity_endpoints.go
+	ErrInvalidDeviceId:            "invalid_device_id",
+	ErrUnsupportedContentEncoding: "unsupported_content_encoding",
+	ErrEventsBodyTooLarge:         "events_body_too_large",
+	ErrEmptyEventsBatch:           "empty_events_batch",
+	ErrTooManyEvents:              "too_many_events",
+
+	// app_rules_endpoints.go
+	ErrInvalidAppRuleId: "invalid_app_rule_id",
+	ErrAppRuleNotFound:  "app_rule_not_found",
+
+	// auth_endpoints.go
+	ErrUserWithGivenEmailDoesNotExist:  "user_not_found",
+	ErrUserWithGivenEmailAlreadyExists: "user_already_exists",
+	ErrRegistrationKeyCannotBeEmpty:    "registration_key_empty",
+	ErrInvalidRegistrationKey:          "invalid_registration_key",
+	ErrInvalidOtat:                     "invalid_otat",
+
+	// auth_middleware.go
+	ErrMissingBearerToken: "missing_bearer_token",
+	ErrInvalidBearerToken: "invalid_bearer_token",
+	ErrMissingDeviceToken: "missing_device_token",
+	ErrInvalidDeviceToken: "invalid_device_token",
+
+	// block_page_endpoints.go
+	ErrUnknownDevice:         "unknown_device",
+	ErrDomainIsNotBlocked:    "domain_not_blocked",
+	ErrAccessRequestNotFound: "access_request_not_found",
+	ErrInvalidDuration:       "invalid_duration",
+
+	// calendar_endpoints.go
+	ErrInvalidCalendarEntryId:    "invalid_calendar_entry_id",
+	ErrCalendarEntryNotFound:     "calendar_entry_not_found",
+	ErrInvalidCalendarDateFormat: "invalid_calendar_date_format",
+	ErrCalendarFeedNotFound:      "calendar_feed_not_found",
+
+	// children_endpoints.go
+	ErrInvalidBirthDateFormat: "invalid_birth_date_format",
+
+	// commands_endpoints.go
+	ErrInvalidCommandId:  "invalid_command_id",
+	ErrCommandNotFound:   "command_not_found",
+	ErrDeviceNotFound:    "device_not_found",
+	ErrChildHasNoDevices: "child_has_no_devices",
+	ErrInvalidAutoResume: "invalid_auto_resume",
+
+	// devices_endpoints.go
+	ErrInvalidChildId:   "invalid_child_id",
+	ErrChildNotFound:    "child_not_found",
+	ErrInvalidIpAddress: "invalid_ip_address",
+
+	// doh_endpoints.go
+	ErrUnknownDeviceToken:     "unknown_device_token",
+	ErrInvalidDnsMessage:      "invalid_dns_message",
+	ErrUnsupportedContentType: "unsupported_content_type",
+	ErrDnsMessageTooLarge:     "dns_message_too_large",
+
+	// domain_rules_endpoints.go
+	ErrInvalidDomainRuleId: "invalid_domain_rule_id",
+	ErrDomainRuleNotFound:  "domain_rule_not_found",
+
+	// heartbeats_endpoints.go
+	ErrInvalidHeartbeatSignature: "invalid_heartbeat_signature",
+	ErrStaleHeartbeat:            "stale_heartbeat",
+	ErrTooManyTamperEvents:       "too_many_tamper_events",
+
+	// households_endpoints.go
+	ErrInvalidHouseholdId: "invalid_household_id",
+	ErrHouseholdNotFound:  "household_not_found",
+
+	// http_helpers.go
+	ErrInvalidJsonPayload:  "invalid_json_payload",
+	ErrInvalidEmail:        "invalid_email",
+	ErrInvalidCallbackUrl:  "invalid_callback_url",
+	ErrRequestBodyTooLarge: "request_body_too_large",
+	ErrUnknownField:        "unknown_field",
+	ErrInvalidFieldType:    "invalid_field_type",
+
+	// path_rules_endpoints.go
+	ErrInvalidPathRuleId: "invalid_path_rule_id",
+	ErrPathRuleNotFound:  "path_rule_not_found",
+
+	// policy_templates_endpoints.go
+	ErrBuiltinPolicyTemplate:  "builtin_policy_template",
+	ErrPolicyTemplateNotFound: "policy_template_not_found",
+	ErrNoChildrenToApplyTo:    "no_children_to_apply_to",
+
+	// reports_endpoints.go
+	ErrInvalidReportDate: "invalid_report_date",
+
+	// rewards_endpoints.go
+	ErrInvalidChoreId:           "invalid_chore_id",
+	ErrChoreNotFound:            "chore_not_found",
+	ErrInvalidChoreCompletionId: "invalid_chore_completion_id",
+	ErrChoreCompletionNotFound:  "chore_completion_not_found",
+
+	// time_extension_endpoints.go
+	ErrInvalidTimeExtensionRequestId: "invalid_time_extension_request_id",
+	ErrTimeExtensionRequestNotFound:  "time_extension_request_not_found",
+	ErrInvalidDecisionLink:           "invalid_decision_link",
+	ErrInvalidDecision:               "invalid_decision",
+
+	// webhooks_endpoints.go
+	ErrInvalidWebhookId:         "invalid_webhook_id",
+	ErrWebhookNotFound:          "webhook_not_found",
+	ErrInvalidWebhookDeliveryId: "invalid_webhook_delivery_id",
+	ErrWebhookDeliveryNotFound:  "webhook_delivery_not_found",
+
+	accessrequests.ErrDomainCannotBeEmpty:                 "domain_empty",
+	accessrequests.ErrAccessRequestWithThisIdDoesNotExist: "access_request_not_found",
+	accessrequests.ErrAccessRequestIsAlreadyDecided:       "access_request_already_decided",
+	accessrequests.ErrExpirationMustBeInTheFuture:         "expiration_in_the_past",
+	accessrequests.ErrAccessRequestHasExpired:             "access_request_expired",
+
+	activity.ErrBatchTooLarge:        "events_batch_too_large",
+	activity.ErrInvalidSeq:           "invalid_seq",
+	activity.ErrOccurredAtOutOfRange: "occurred_at_out_of_range",
+	activity.ErrInvalidPayload:       "invalid_activity_event_payload",
+	activity.ErrInvalidType:          "invalid_activity_event_type",
+
+	apprules.ErrInvalidMatchType:                "invalid_match_type",
+	apprules.ErrInvalidAction:                   "invalid_action",
+	apprules.ErrInvalidPattern:                  "invalid_pattern",
+	apprules.ErrInvalidDailyLimit:               "invalid_daily_limit",
+	apprules.ErrRuleForThisPatternAlreadyExists: "app_rule_already_exists",
+
+	blocklists.ErrInvalidFormat:     "invalid_blocklist_format",
+	blocklists.ErrInvalidCategory:   "invalid_category",
+	blocklists.ErrNameCannotBeEmpty: "blocklist_name_empty",
+
+	calendar.ErrInvalidIcs:                  "invalid_ics",
+	calendar.ErrInvalidName:                 "invalid_calendar_entry_name",
+	calendar.ErrInvalidDates:                "invalid_calendar_entry_dates",
+	calendar.ErrEntryWithThisIdDoesNotExist: "calendar_entry_not_found",
+
+	children.ErrNameCannotBeEmpty:            "child_name_empty",
+	children.ErrInvalidBlockMode:             "invalid_block_mode",
+	children.ErrInvalidYoutubeRestrictedMode: "invalid_youtube_restricted_mode",
+	children.ErrInvalidDailyScreenTime:       "invalid_daily_screen_time",
+	children.ErrChildWithThisIdDoesNotExist:  "child_not_found",
+	children.ErrInvalidBirthDate:             "birth_date_in_the_future",
+
+	commands.ErrInvalidCommandType:            "invalid_command_type",
+	commands.ErrMissingMessage:                "missing_message",
+	commands.ErrMessageTooLong:                "message_too_long",
+	commands.ErrCommandWithThisIdDoesNotExist: "command_not_found",
+	commands.ErrCommandIsCancelled:            "command_cancelled",
+
+	devices.ErrNameCannotBeEmpty:            "device_name_empty",
+	devices.ErrDeviceWithThisIdDoesNotExist: "device_not_found",
+	devices.ErrIpAddressAlreadyAssigned:     "ip_address_already_assigned",
+
+	domainrules.ErrInvalidDomain:                  "invalid_domain",
+	domainrules.ErrInvalidAction:                  "invalid_action",
+	domainrules.ErrRuleForThisDomainAlreadyExists: "domain_rule_already_exists",
+	domainrules.ErrInvalidPath:                    "invalid_path",
+	domainrules.ErrRuleForThisPathAlreadyExists:   "path_rule_already_exists",
+
+	heartbeats.ErrInvalidTamperType: "invalid_tamper_event_type",
+	heartbeats.ErrDetailTooLong:     "tamper_event_detail_too_long",
+
+	households.ErrNameCannotBeEmpty:            "household_name_empty",
+	households.ErrInvalidActivityRetentionDays: "invalid_activity_retention",
+
+	policytemplates.ErrInvalidName:                       "invalid_policy_template_name",
+	policytemplates.ErrTemplateWithThisNameAlreadyExists: "policy_template_already_exists",
+
+	reports.ErrInvalidDays: "invalid_days",
+	reports.ErrNoDays:      "no_days",
+
+	rewards.ErrInvalidTitle:                     "invalid_chore_title",
+	rewards.ErrInvalidPoints:                    "invalid_points",
+	rewards.ErrInvalidRecurrence:                "invalid_recurrence",
+	rewards.ErrChoreAlreadyDone:                 "chore_already_done",
+	rewards.ErrCompletionWithThisIdDoesNotExist: "chore_completion_not_found",
+	rewards.ErrCompletionIsAlreadyDecided:       "chore_completion_already_decided",
+	rewards.ErrInvalidAdjustment:                "invalid_adjustment",
+	rewards.ErrInvalidDescription:               "invalid_description",
+	rewards.ErrInvalidExchangeRate:              "invalid_exchange_rate",
+	rewards.ErrNoExchangeRate:                   "no_exchange_rate",
+	rewards.ErrInvalidRedemptionMinutes:         "invalid_redemption_minutes",
+	rewards.ErrInsufficientPoints:               "insufficient_points",
+	rewards.ErrDailyLimitReached:                "daily_limit_reached",
+
+	timeextensions.ErrInvalidMinutes:                             "invalid_minutes",
+	timeextensions.ErrReasonTooLong:                              "reason_too_long",
+	timeextensions.ErrTimeExtensionRequestWithThisIdDoesNotExist: "time_extension_request_not_found",
+	timeextensions.ErrTimeExtensionRequestIsAlreadyDecided:       "time_extension_request_already_decided",
+
+	timetokens.ErrInvalidNonce: "invalid_nonce",
+	timetokens.ErrInvalidToken: "invalid_time_token",
+
+	users.ErrEmailCannotBeEmpty:              "email_empty",
+	users.ErrUserWithGivenEmailAlreadyExists: "user_already_exists",
+	users.ErrUserWithThisIdDoesNotExist:      "user_not_found",
+
+	webhooks.ErrInvalidUrl:                         "invalid_webhook_url",
+	webhooks.ErrInvalidEventType:                   "invalid_webhook_event_type",
+	webhooks.ErrTooManySubscriptions:               "too_many_webhooks",
+	webhooks.ErrSubscriptionWithThisIdDoesNotExist: "webhook_not_found",
+	webhooks.ErrDeliveryWithThisIdDoesNotExist:     "webhook_delivery_not_found",
+
+	// validation, the messages of these codes are formats of the arguments of the rule
+	validation.ErrRequired:         "required",
+	validation.ErrInvalidEmail:     "not_email",
+	validation.ErrInvalidUrl:       "not_url",
+	validation.ErrTooShort:         "too_short",
+	validation.ErrTooLong:          "too_long",
+	validation.ErrNotOneOf:         "not_allowed",
+	validation.ErrInvalidTimeOfDay: "invalid_time_of_day",
+}
+
+// errorCode finds the code of the sentinel the error is or wraps.
+func errorCode(err error) (string, bool) {
+	for sentinel, code := range errorCodes {
+		if errors.Is(err, sentinel) {
+			return code, true
+		}
+	}
+
+	return "", false
+}
+
+// errorMessages translates the messages of the codes, every code has a message in every language. The English ones
+// are the messages of the sentinels, the ones of the validation rules describe the field.
+var errorMessages = i18n.Catalog{
+	i18n.LanguagePolish: {
+		"bad_request":           "Nieprawidłowe żądanie",
+		"unauthorized":          "Brak autoryzacji",
+		"not_found":             "Nie znaleziono",
+		"conflict":              "Konflikt",
+		"too_many_requests":     "Zbyt wiele żądań",
+		"internal_server_error": "Wewnętrzny błąd serwera",
+		"invalid_fields":        "Żądanie zawiera nieprawidłowe pola",
+
+		"invalid_device_id":            "Nieprawidłowy identyfikator urządzenia",
+		"unsupported_content_encoding": "Kodowanie treści musi być gzip lub brak",
+		"events_body_too_large":        "Treść zdarzeń jest zbyt duża",
+		"empty_events_batch":           "Paczka zdarzeń jest pusta",
+		"too_many_events":              "Zbyt wiele zdarzeń, spróbuj ponownie później",
+
+		"invalid_app_rule_id": "Nieprawidłowy identyfikator reguły aplikacji",
+		"app_rule_not_found":  "Nie znaleziono reguły aplikacji",
+
+		"user_not_found":           "Użytkownik o podanym adresie email nie istnieje",
+		"user_already_exists":      "Użytkownik o podanym adresie email już istnieje",
+		"registration_key_empty":   "Klucz rejestracji nie może być pusty",
+		"invalid_registration_key": "Nieprawidłowy klucz rejestracji",
+		"invalid_otat":             "Nieprawidłowy jednorazowy token dostępu",
+
+		"missing_bearer_token": "Brak tokenu bearer",
+		"invalid_bearer_token": "Nieprawidłowy token bearer",
+		"missing_device_token": "Brak tokenu urządzenia",
+		"invalid_device_token": "Nieprawidłowy token urządzenia",
+
+		"unknown_device":           "To urządzenie nie jest objęte kontrolą rodzicielską",
+		"domain_not_blocked":       "Domena nie jest zablokowana",
+		"access_request_not_found": "Nie znaleziono prośby o dostęp",
+		"invalid_duration":         "Nieprawidłowy czas trwania",
+
+		"invalid_calendar_entry_id":    "Nieprawidłowy identyfikator wpisu kalendarza",
+		"calendar_entry_not_found":     "Nie znaleziono wpisu kalendarza",
+		"invalid_calendar_date_format": "Daty muszą mieć format RRRR-MM-DD",
+		"calendar_feed_not_found":      "Nie znaleziono kanału kalendarza",
+
+		"invalid_birth_date_format": "Data urodzenia musi mieć format RRRR-MM-DD",
+
+		"invalid_command_id":   "Nieprawidłowy identyfikator polecenia",
+		"command_not_found":    "Nie znaleziono polecenia",
+		"device_not_found":     "Nie znaleziono urządzenia",
+		"child_has_no_devices": "Dziecko nie ma żadnych urządzeń",
+		"invalid_auto_resume":  fmt.Sprintf("Automatyczne wznowienie musi wynosić od 1 do %d minut i jest możliwe tylko dla lock_screen i pause_network", MaxAutoResumeMinutes),
+
+		"invalid_child_id":   "Nieprawidłowy identyfikator dziecka",
+		"child_not_found":    "Nie znaleziono dziecka",
+		"invalid_ip_address": "Nieprawidłowy adres IP",
+
+		"unknown_device_token":     "Nieznany token urządzenia",
+		"invalid_dns_message":      "Nieprawidłowa wiadomość DNS",
+		"unsupported_content_type": "Nieobsługiwany typ treści, oczekiwano application/dns-message",
+		"dns_message_too_large":    "Wiadomość DNS jest zbyt duża",
+
+		"invalid_domain_rule_id": "Nieprawidłowy identyfikator reguły domeny",
+		"domain_rule_not_found":  "Nie znaleziono reguły domeny",
+
+		"invalid_heartbeat_signature": "Nieprawidłowy podpis sygnału życia",
+		"stale_heartbeat":             "Sygnał życia jest starszy niż ostatnio otrzymany",
+		"too_many_tamper_events.one":  "Sygnał życia może zawierać najwyżej %d zdarzenie ingerencji",
+		"too_many_tamper_events.few":  "Sygnał życia może zawierać najwyżej %d zdarzenia ingerencji",
+		"too_many_tamper_events.many": "Sygnał życia może zawierać najwyżej %d zdarzeń ingerencji",
+
+		"invalid_household_id": "Nieprawidłowy identyfikator gospodarstwa domowego",
+		"household_not_found":  "Nie znaleziono gospodarstwa domowego",
+
+		"invalid_json_payload":        "Nieprawidłowa treść json",
+		"invalid_email":               "Nieprawidłowy adres email",
+		"invalid_callback_url":        "Nieprawidłowy adres zwrotny",
+		"request_body_too_large.one":  "Treść żądania może mieć najwyżej %d bajt",
+		"request_body_too_large.few":  "Treść żądania może mieć najwyżej %d bajty",
+		"request_body_too_large.many": "Treść żądania może mieć najwyżej %d bajtów",
+		"unknown_field":               "Nieznane pole",
+		"invalid_field_type":          "Nieprawidłowy typ pola",
+
+		"invalid_path_rule_id": "Nieprawidłowy identyfikator reguły ścieżki",
+		"path_rule_not_found":  "Nie znaleziono reguły ścieżki",
+
+		"builtin_policy_template":   "Wbudowanych szablonów zasad nie można usunąć",
+		"policy_template_not_found": "Nie znaleziono szablonu zasad",
+		"no_children_to_apply_to":   "Wymagane jest co najmniej jedno dziecko",
+
+		"invalid_report_date": "Parametr from musi być datą w formacie RRRR-MM-DD",
+
+		"invalid_chore_id":            "Nieprawidłowy identyfikator obowiązku",
+		"chore_not_found":             "Nie znaleziono obowiązku",
+		"invalid_chore_completion_id": "Nieprawidłowy identyfikator wykonania obowiązku",
+		"chore_completion_not_found":  "Nie znaleziono wykonania obowiązku",
+
+		"invalid_time_extension_request_id": "Nieprawidłowy identyfikator prośby o dodatkowy czas",
+		"time_extension_request_not_found":  "Nie znaleziono prośby o dodatkowy czas",
+		"invalid_decision_link":             "Link decyzji jest nieprawidłowy lub wygasł",
+		"invalid_decision":                  "Decyzja musi mieć wartość approve lub deny",
+
+		"invalid_webhook_id":          "Nieprawidłowy identyfikator webhooka",
+		"webhook_not_found":           "Nie znaleziono webhooka",
+		"invalid_webhook_delivery_id": "Nieprawidłowy identyfikator dostarczenia webhooka",
+		"webhook_delivery_not_found":  "Nie znaleziono dostarczenia webhooka",
+
+		"domain_empty":                   "Domena nie może być pusta",
+		"access_request_already_decided": "Prośba o dostęp została już zatwierdzona lub odrzucona",
+		"expiration_in_the_past":         "Wygaśnięcie wyjątku musi nastąpić w przyszłości",
+		"access_request_expired":         "Prośba o dostęp wygasła",
+
+		"events_batch_too_large.one":     "Paczka może zawierać najwyżej %d zdarzenie",
+		"events_batch_too_large.few":     "Paczka może zawierać najwyżej %d zdarzenia",
+		"events_batch_too_large.many":    "Paczka może zawierać najwyżej %d zdarzeń",
+		"invalid_seq":                    "Numer sekwencyjny musi być dodatni",
+		"occurred_at_out_of_range":       "Brak occurredAt lub data jest w przyszłości albo zbyt stara",
+		"invalid_activity_event_payload": "Nieprawidłowa treść zdarzenia aktywności",
+		"invalid_activity_event_type":    "Nieprawidłowy typ zdarzenia aktywności",
+
+		"invalid_match_type":      "Nieprawidłowy typ dopasowania",
+		"invalid_action":          "Nieprawidłowa akcja",
+		"invalid_pattern":         "Nieprawidłowy wzorzec",
+		"invalid_daily_limit":     fmt.Sprintf("Dzienny limit musi wynosić od 1 do %d minut dla reguł z limitem i 0 dla pozostałych", apprules.MaxDailyLimitMinutes),
+		"app_rule_already_exists": "Reguła dla tego wzorca już istnieje",
+
+		"invalid_blocklist_format": "Nieprawidłowy format listy blokowanych stron",
+		"invalid_category":         "Nieprawidłowa kategoria",
+		"blocklist_name_empty":     "Nazwa listy blokowanych stron nie może być pusta",
+
+		"invalid_ics":                       "Nieprawidłowy plik iCalendar",
+		"invalid_calendar_entry_name.one":   "Nazwa wpisu nie może być pusta ani dłuższa niż %d znak",
+		"invalid_calendar_entry_name.few":   "Nazwa wpisu nie może być pusta ani dłuższa niż %d znaki",
+		"invalid_calendar_entry_name.many":  "Nazwa wpisu nie może być pusta ani dłuższa niż %d znaków",
+		"invalid_calendar_entry_dates.one":  "Wpis musi kończyć się w dniu rozpoczęcia lub później i trwać najwyżej %d dzień",
+		"invalid_calendar_entry_dates.few":  "Wpis musi kończyć się w dniu rozpoczęcia lub później i trwać najwyżej %d dni",
+		"invalid_calendar_entry_dates.many": "Wpis musi kończyć się w dniu rozpoczęcia lub później i trwać najwyżej %d dni",
+
+		"child_name_empty":                "Imię dziecka nie może być puste",
+		"invalid_block_mode":              "Nieprawidłowy tryb blokowania",
+		"invalid_youtube_restricted_mode": "Nieprawidłowy tryb ograniczonego dostępu YouTube",
+		"invalid_daily_screen_time":       fmt.Sprintf("Dzienny czas przed ekranem musi wynosić od 0 do %d minut", children.MaxDailyScreenTimeMinutes),
+		"birth_date_in_the_future":        "Data urodzenia nie może być w przyszłości",
+
+		"invalid_command_type":  "Typ polecenia musi być jednym z lock_screen, pause_network, resume lub show_message",
+		"missing_message":       "Polecenie show_message wymaga wiadomości",
+		"message_too_long.one":  "Wiadomość nie może być dłuższa niż %d znak",
+		"message_too_long.few":  "Wiadomość nie może być dłuższa niż %d znaki",
+		"message_too_long.many": "Wiadomość nie może być dłuższa niż %d znaków",
+		"command_cancelled":     "Polecenie zostało anulowane",
+
+		"device_name_empty":           "Nazwa urządzenia nie może być pusta",
+		"ip_address_already_assigned": "Adres IP jest już przypisany do innego urządzenia",
+
+		"invalid_domain":             "Nieprawidłowa domena",
+		"domain_rule_already_exists": "Reguła dla tej domeny już istnieje",
+		"invalid_path":               "Nieprawidłowa ścieżka, musi zaczynać się od / i nie może być samym / ani zawierać zapytania",
+		"path_rule_already_exists":   "Reguła dla tej ścieżki już istnieje",
+
+		"invalid_tamper_event_type":         "Nieprawidłowy typ zdarzenia ingerencji",
+		"tamper_event_detail_too_long.one":  "Opis zdarzenia ingerencji nie może być dłuższy niż %d znak",
+		"tamper_event_detail_too_long.few":  "Opis zdarzenia ingerencji nie może być dłuższy niż %d znaki",
+		"tamper_event_detail_too_long.many": "Opis zdarzenia ingerencji nie może być dłuższy niż %d znaków",
+
+		"household_name_empty":       "Nazwa gospodarstwa domowego nie może być pusta",
+		"invalid_activity_retention": fmt.Sprintf("Przechowywanie aktywności musi wynosić od %d do %d dni", households.MinActivityRetentionDays, households.MaxActivityRetentionDays),
+
+		"invalid_policy_template_name.one":  "Nazwa szablonu nie może być pusta ani dłuższa niż %d znak",
+		"invalid_policy_template_name.few":  "Nazwa szablonu nie może być pusta ani dłuższa niż %d znaki",
+		"invalid_policy_template_name.many": "Nazwa szablonu nie może być pusta ani dłuższa niż %d znaków",
+		"policy_template_already_exists":    "Szablon o tej nazwie już istnieje",
+
+		"invalid_days": fmt.Sprintf("Liczba dni musi wynosić od 1 do %d", reports.MaxDays),
+		"no_days":      "Brak dni do podsumowania",
+
+		"invalid_chore_title.one":          "Tytuł nie może być pusty ani dłuższy niż %d znak",
+		"invalid_chore_title.few":          "Tytuł nie może być pusty ani dłuższy niż %d znaki",
+		"invalid_chore_title.many":         "Tytuł nie może być pusty ani dłuższy niż %d znaków",
+		"invalid_points":                   fmt.Sprintf("Liczba punktów musi wynosić od 1 do %d", rewards.MaxChorePoints),
+		"invalid_recurrence":               "Nieprawidłowa powtarzalność",
+		"chore_already_done":               "Obowiązek został już wykonany w tym okresie",
+		"chore_completion_already_decided": "Wykonanie obowiązku zostało już zatwierdzone lub odrzucone",
+		"invalid_adjustment":               fmt.Sprintf("Korekta musi wynosić od -%d do %d punktów i nie może być zerem", rewards.MaxChorePoints, rewards.MaxChorePoints),
+		"invalid_description.one":          "Opis nie może być pusty ani dłuższy niż %d znak",
+		"invalid_description.few":          "Opis nie może być pusty ani dłuższy niż %d znaki",
+		"invalid_description.many":         "Opis nie może być pusty ani dłuższy niż %d znaków",
+		"invalid_exchange_rate":            fmt.Sprintf("Punkty i minuty muszą wynosić od 1 do %d, a dzienny limit od 0 do %d minut", rewards.MaxExchangeRateValue, rewards.MaxDailyLimitMinutes),
+		"no_exchange_rate":                 "Kurs wymiany nie został ustawiony",
+		"invalid_redemption_minutes":       "Liczba minut musi być dodatnią wielokrotnością minut kursu wymiany",
+		"insufficient_points":              "Za mało punktów",
+		"daily_limit_reached":              "Dzienny limit wymienionych minut zostałby przekroczony",
+
+		"invalid_minutes":                        fmt.Sprintf("Liczba minut musi wynosić od 1 do %d", timeextensions.MaxMinutes),
+		"reason_too_long.one":                    "Powód nie może być dłuższy niż %d znak",
+		"reason_too_long.few":                    "Powód nie może być dłuższy niż %d znaki",
+		"reason_too_long.many":                   "Powód nie może być dłuższy niż %d znaków",
+		"time_extension_request_already_decided": "Prośba o dodatkowy czas została już zatwierdzona lub odrzucona",
+
+		"invalid_nonce":      fmt.Sprintf("Nonce musi mieć od %d do %d znaków", timetokens.MinNonceLength, timetokens.MaxNonceLength),
+		"invalid_time_token": "Nieprawidłowy token czasu",
+
+		"email_empty": "Adres email nie może być pusty",
+
+		"invalid_webhook_url":        fmt.Sprintf("Adres musi być bezwzględnym adresem http lub https o długości najwyżej %d znaków", webhooks.MaxUrlLength),
+		"invalid_webhook_event_type": "Nieprawidłowy typ zdarzenia",
+		"too_many_webhooks.one":      "Gospodarstwo domowe może mieć najwyżej %d webhook",
+		"too_many_webhooks.few":      "Gospodarstwo domowe może mieć najwyżej %d webhooki",
+		"too_many_webhooks.many":     "Gospodarstwo domowe może mieć najwyżej %d webhooków",
+
+		"required":            "jest wymagane",
+		"not_email":           "musi być adresem email",
+		"not_url":             "musi być bezwzględnym adresem http lub https",
+		"too_short.one":       "musi mieć co najmniej %d znak",
+		"too_short.few":       "musi mieć co najmniej %d znaki",
+		"too_short.many":      "musi mieć co najmniej %d znaków",
+		"too_long.one":        "może mieć najwyżej %d znak",
+		"too_long.few":        "może mieć najwyżej %d znaki",
+		"too_long.many":       "może mieć najwyżej %d znaków",
+		"not_allowed":         "musi być jedną z wartości: %s",
+		"invalid_time_of_day": "musi być godziną w formacie GG:MM",
+	},
+	i18n.LanguageEnglish: {
+		"bad_request":           "Bad Request",
+		"unauthorized":          "Unauthorized",
+		"not_found":             "Not Found",
+		"conflict":              "Conflict",
+		"too_many_requests":     "Too Many Requests",
+		"internal_server_error": "Internal Server Error",
+		"invalid_fields":        "request contains invalid fields",
+
+		"invalid_device_id":            "invalid device id",
+		"unsupported_content_encoding": "content encoding must be gzip or none",
+		"events_body_too_large":        "events body is too large",
+		"empty_events_batch":           "events batch is empty",
+		"too_many_events":              "too many events, retry later",
+
+		"invalid_app_rule_id": "invalid app rule id",
+		"app_rule_not_found":  "app rule not found",
+
+		"user_not_found":           "user with given email does not exist",
+		"user_already_exists":      "user with given email already exists",
+		"registration_key_empty":   "registration key can not be empty",
+		"invalid_registration_key": "invalid registration key",
+		"invalid_otat":             "invalid one time access token",
+
+		"missing_bearer_token": "missing bearer token",
+		"invalid_bearer_token": "invalid bearer token",
+		"missing_device_token": "missing device token",
+		"invalid_device_token": "invalid device token",
+
+		"unknown_device":           "this device is not managed by parental controls",
+		"domain_not_blocked":       "domain is not blocked",
+		"access_request_not_found": "access request not found",
+		"invalid_duration":         "invalid duration",
+
+		"invalid_calendar_entry_id":    "invalid calendar entry id",
+		"calendar_entry_not_found":     "calendar entry not found",
+		"invalid_calendar_date_format": "dates must be in format YYYY-MM-DD",
+		"calendar_feed_not_found":      "calendar feed not found",
+
+		"invalid_birth_date_format": "birth date must be a date in format YYYY-MM-DD",
+
+		"invalid_command_id":   "invalid command id",
+		"command_not_found":    "command not found",
+		"device_not_found":     "device not found",
+		"child_has_no_devices": "child has no devices",
+		"invalid_auto_resume":  fmt.Sprintf("auto resume must be between 1 and %d minutes and is only possible for lock_screen and pause_network", MaxAutoResumeMinutes),
+
+		"invalid_child_id":   "invalid child id",
+		"child_not_found":    "child not found",
+		"invalid_ip_address": "invalid ip address",
+
+		"unknown_device_token":     "unknown device token",
+		"invalid_dns_message":      "invalid dns message",
+		"unsupported_content_type": "unsupported content type, expected application/dns-message",
+		"dns_message_too_large":    "dns message too large",
+
+		"invalid_domain_rule_id": "invalid domain rule id",
+		"domain_rule_not_found":  "domain rule not found",
+
+		"invalid_heartbeat_signature":  "invalid heartbeat signature",
+		"stale_heartbeat":              "heartbeat is older than the last one received",
+		"too_many_tamper_events.one":   "heartbeat can not carry more than %d tamper event",
+		"too_many_tamper_events.other": "heartbeat can not carry more than %d tamper events",
+
+		"invalid_household_id": "invalid household id",
+		"household_not_found":  "household not found",
+
+		"invalid_json_payload":         "Invalid json payload",
+		"invalid_email":                "Invalid email",
+		"invalid_callback_url":         "Invalid callback url",
+		"request_body_too_large.one":   "request body can not be larger than %d byte",
+		"request_body_too_large.other": "request body can not be larger than %d bytes",
+		"unknown_field":                "unknown field",
+		"invalid_field_type":           "invalid type of field",
+
+		"invalid_path_rule_id": "invalid path rule id",
+		"path_rule_not_found":  "path rule not found",
+
+		"builtin_policy_template":   "built-in policy templates can not be deleted",
+		"policy_template_not_found": "policy template not found",
+		"no_children_to_apply_to":   "at least one child is required",
+
+		"invalid_report_date": "from must be a date in YYYY-MM-DD format",
+
+		"invalid_chore_id":            "invalid chore id",
+		"chore_not_found":             "chore not found",
+		"invalid_chore_completion_id": "invalid chore completion id",
+		"chore_completion_not_found":  "chore completion not found",
+
+		"invalid_time_extension_request_id": "invalid time extension request id",
+		"time_extension_request_not_found":  "time extension request not found",
+		"invalid_decision_link":             "decision link is invalid or has expired",
+		"invalid_decision":                  "decision must be either approve or deny",
+
+		"invalid_webhook_id":          "invalid webhook id",
+		"webhook_not_found":           "webhook not found",
+		"invalid_webhook_delivery_id": "invalid webhook delivery id",
+		"webhook_delivery_not_found":  "webhook delivery not found",
+
+		"domain_empty":                   "domain can not be empty",
+		"access_request_already_decided": "access request is already approved or denied",
+		"expiration_in_the_past":         "expiration of the exception must be in the future",
+		"access_request_expired":         "access request has expired",
+
+		"events_batch_too_large.one":     "batch can not contain more than %d event",
+		"events_batch_too_large.other":   "batch can not contain more than %d events",
+		"invalid_seq":                    "sequence number must be positive",
+		"occurred_at_out_of_range":       "occurredAt is missing, in the future or too old",
+		"invalid_activity_event_payload": "invalid activity event payload",
+		"invalid_activity_event_type":    "invalid activity event type",
+
+		"invalid_match_type":      "invalid match type",
+		"invalid_action":          "invalid action",
+		"invalid_pattern":         "invalid pattern",
+		"invalid_daily_limit":     fmt.Sprintf("daily limit must be between 1 and %d minutes for limit rules and 0 for the others", apprules.MaxDailyLimitMinutes),
+		"app_rule_already_exists": "rule for this pattern already exists",
+
+		"invalid_blocklist_format": "invalid blocklist format",
+		"invalid_category":         "invalid category",
+		"blocklist_name_empty":     "blocklist name can not be empty",
+
+		"invalid_ics":                        "invalid iCalendar file",
+		"invalid_calendar_entry_name.one":    "entry name can not be empty or longer than %d character",
+		"invalid_calendar_entry_name.other":  "entry name can not be empty or longer than %d characters",
+		"invalid_calendar_entry_dates.one":   "entry must end on or after the day it starts and last at most %d day",
+		"invalid_calendar_entry_dates.other": "entry must end on or after the day it starts and last at most %d days",
+
+		"child_name_empty":                "child name can not be empty",
+		"invalid_block_mode":              "invalid block mode",
+		"invalid_youtube_restricted_mode": "invalid youtube restricted mode",
+		"invalid_daily_screen_time":       fmt.Sprintf("daily screen time must be between 0 and %d minutes", children.MaxDailyScreenTimeMinutes),
+		"birth_date_in_the_future":        "birth date can not be in the future",
+
+		"invalid_command_type":   "command type must be one of lock_screen, pause_network, resume or show_message",
+		"missing_message":        "show_message command requires a message",
+		"message_too_long.one":   "message can not be longer than %d character",
+		"message_too_long.other": "message can not be longer than %d characters",
+		"command_cancelled":      "command has been cancelled",
+
+		"device_name_empty":           "device name can not be empty",
+		"ip_address_already_assigned": "ip address is already assigned to another device",
+
+		"invalid_domain":             "invalid domain",
+		"domain_rule_already_exists": "rule for this domain already exists",
+		"invalid_path":               "invalid path, it has to start with / and can not be / alone or contain a query",
+		"path_rule_already_exists":   "rule for this path already exists",
+
+		"invalid_tamper_event_type":          "invalid tamper event type",
+		"tamper_event_detail_too_long.one":   "tamper event detail can not be longer than %d character",
+		"tamper_event_detail_too_long.other": "tamper event detail can not be longer than %d characters",
+
+		"household_name_empty":       "household name can not be empty",
+		"invalid_activity_retention": fmt.Sprintf("activity retention must be between %d and %d days", households.MinActivityRetentionDays, households.MaxActivityRetentionDays),
+
+		"invalid_policy_template_name.one":   "template name can not be empty or longer than %d character",
+		"invalid_policy_template_name.other": "template name can not be empty or longer than %d characters",
+		"policy_template_already_exists":     "template with this name already exists",
+
+		"invalid_days": fmt.Sprintf("days must be between 1 and %d", reports.MaxDays),
+		"no_days":      "no days to summarize",
+
+		"invalid_chore_title.one":          "title can not be empty or longer than %d character",
+		"invalid_chore_title.other":        "title can not be empty or longer than %d characters",
+		"invalid_points":                   fmt.Sprintf("points must be between 1 and %d", rewards.MaxChorePoints),
+		"invalid_recurrence":               "invalid recurrence",
+		"chore_already_done":               "chore is already done in this period",
+		"chore_completion_already_decided": "chore completion is already approved or rejected",
+		"invalid_adjustment":               fmt.Sprintf("adjustment must be between -%d and %d points and not zero", rewards.MaxChorePoints, rewards.MaxChorePoints),
+		"invalid_description.one":          "description can not be empty or longer than %d character",
+		"invalid_description.other":        "description can not be empty or longer than %d characters",
+		"invalid_exchange_rate":            fmt.Sprintf("points and minutes must be between 1 and %d and the daily limit between 0 and %d minutes", rewards.MaxExchangeRateValue, rewards.MaxDailyLimitMinutes),
+		"no_exchange_rate":                 "exchange rate is not configured",
+		"invalid_redemption_minutes":       "minutes must be a positive multiple of the minutes of the exchange rate",
+		"insufficient_points":              "not enough points",
+		"daily_limit_reached":              "daily limit of redeemed minutes would be exceeded",
+
+		"invalid_minutes":                        fmt.Sprintf("minutes must be between 1 and %d", timeextensions.MaxMinutes),
+		"reason_too_long.one":                    "reason can not be longer than %d character",
+		"reason_too_long.other":                  "reason can not be longer than %d characters",
+		"time_extension_request_already_decided": "time extension request is already approved or denied",
+
+		"invalid_nonce":      fmt.Sprintf("nonce must have between %d and %d characters", timetokens.MinNonceLength, timetokens.MaxNonceLength),
+		"invalid_time_token": "invalid time token",
+
+		"email_empty": "email can not be empty",
+
+		"invalid_webhook_url":        fmt.Sprintf("url must be an absolute http or https url of at most %d characters", webhooks.MaxUrlLength),
+		"invalid_webhook_event_type": "invalid event type",
+		"too_many_webhooks.one":      "a household can have at most %d webhook",
+		"too_many_webhooks.other":    "a household can have at most %d webhooks",
+
+		"required":            "is required",
+		"not_email":           "must be an email address",
+		"not_url":             "must be an absolute http or https url",
+		"too_short.one":       "must have at least %d character",
+		"too_short.other":     "must have at least %d characters",
+		"too_long.one":        "can have at most %d character",
+		"too_long.other":      "can have at most %d characters",
+		"not_allowed":         "must be one of %s",
+		"invalid_time_of_day": "must be a time of day in format HH:MM",
+	},
+}
+
+// errorMessageArgs are the limits in the messages of the codes, the count picking the plural form of the message.
+var errorMessageArgs = map[string][]any{
+	"too_many_tamper_events":       {heartbeats.MaxTamperEvents},
+	"request_body_too_large":       {maxJsonRequestBodySize},
+	"events_batch_too_large":       {activity.MaxBatchSize},
+	"invalid_calendar_entry_name":  {calendar.MaxNameLength},
+	"invalid_calendar_entry_dates": {calendar.MaxEntryDays},
+	"message_too_long":             {commands.MaxMessageLength},
+	"tamper_event_detail_too_long": {heartbeats.MaxDetailLength},
+	"invalid_policy_template_name": {policytemplates.MaxNameLength},
+	"invalid_chore_title":          {rewards.MaxTitleLength},
+	"invalid_description":          {rewards.MaxTitleLength},
+	"reason_too_long":              {timeextensions.MaxReasonLength},
+	"too_many_webhooks":            {webhooks.MaxSubscriptions},
+}
+
+// statusErrorCode is the code of errors without a sentinel, e.g. "internal_server_error".
+func statusErrorCode(status int) string {
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+// newFieldError describes the field rejected with err, its message is translated when the response is written.
+func newFieldError(field string, err error) FieldError {
+	code, found := errorCode(err)
+	if !found {
+		code = statusErrorCode(http.StatusBadRequest)
+	}
+
+	return FieldError{Field: field, Code: code, Message: err.Error()}
+}
+
+// newValidationFieldError describes a rule broken by the field, the message of its code is formatted with the
+// arguments of the rule.
+func newValidationFieldError(fieldError validation.FieldError) FieldError {
+	detail := newFieldError(fieldError.Field, fieldError.Err)
+	detail.Message = fieldError.Message()
+	detail.rule = &fieldError
+
+	return detail
+}
+
+func translateErrorMessage(language i18n.Language, code string, message string, args ...any) string {
+	if len(args) == 0 {
+		args = errorMessageArgs[code]
+	}
+
+	translated, ok := errorMessages.Lookup(language, code, args...)
+	if !ok {
+		return message
+	}
+
+	return translated
+}
+
+// prefersJson reports whether the Accept header of the request ranks application/json above text/plain. Clients
+// without an Accept header or accepting anything keep getting the plain text they always did.
+func prefersJson(r *http.Request) bool {
+	jsonWeight := 0.0
+	textWeight := 0.0
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, parameters, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		weight := 1.0
+		if value, ok := parameters["q"]; ok {
+			weight, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "application/json":
+			jsonWeight = max(jsonWeight, weight)
+		case "text/plain":
+			textWeight = max(textWeight, weight)
+		}
+	}
+
+	return jsonWeight > textWeight
+}
+
+// respondWithError writes the message of the error as plain text, or as ErrorResponse for clients preferring json,
+// a nil error is the text of the status. The code of the envelope is the one of the sentinel the error is or wraps,
+// its message is translated to the language of the request, see requestLanguage. Details describe the invalid fields,
+// an error with details but without a sentinel is ErrInvalidFields in the envelope and only its message in plain text.
+func respondWithError(w http.ResponseWriter, r *http.Request, status int, err error, details ...FieldError) {
+	message := http.StatusText(status)
+	if err != nil {
+		message = err.Error()
+	}
+
+	if !prefersJson(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		_, err := w.Write([]byte(message))
+		if err != nil {
+			log.Println("Error writing response:", err)
+		}
+
+		return
+	}
+
+	language := requestLanguage(r)
+
+	code, translatable := errorCode(err)
+	if !translatable && len(details) != 0 {
+		code = errorCodes[ErrInvalidFields]
+		message = ErrInvalidFields.Error()
+		translatable = true
+	} else if !translatable {
+		// errors without a sentinel, e.g. one describing the error of a field, keep their message
+		code = statusErrorCode(status)
+		translatable = err == nil
+	}
+
+	apiError := ApiError{Code: code, Message: message, RequestId: requestId(r)}
+	if translatable {
+		apiError.Message = translateErrorMessage(language, code, message)
+	}
+
+	for _, detail := range details {
+		var args []any
+		if detail.rule != nil {
+			args = detail.rule.Args
+		}
+
+		detail.Message = translateErrorMessage(language, detail.Code, detail.Message, args...)
+		apiError.Details = append(apiError.Details, detail)
+	}
+
+	encoded, err := json.Marshal(ErrorResponse{Error: apiError})
+	if err != nil {
+		log.Printf("Error encoding error response: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(encoded)
+	if err != nil {
+		log.Println("Error writing response:", err)
+	}
+}
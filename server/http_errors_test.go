@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/validation"
+	"domanscy.group/parental-controls/server/webhooks"
+)
+
+func sendTestError(t *testing.T, header http.Header, respond func(w http.ResponseWriter, r *http.Request)) (*httptest.ResponseRecorder, ErrorResponse) {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/", nil)
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	recorder := httptest.NewRecorder()
+	AssignRequestId(http.HandlerFunc(respond)).ServeHTTP(recorder, request)
+
+	var decoded ErrorResponse
+	if recorder.Header().Get("Content-Type") == "application/json" {
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &decoded))
+	}
+
+	return recorder, decoded
+}
+
+func TestRespondWithError(t *testing.T) {
+	respondWithInvalidJson := func(w http.ResponseWriter, r *http.Request) {
+		respondWith400(w, r, ErrInvalidJsonPayload)
+	}
+
+	t.Run("plain text for clients not asking for json", func(t *testing.T) {
+		for _, accept := range []string{"", "*/*", "text/html,*/*;q=0.8", "application/json, text/plain, */*", "application/json;q=0.5, text/plain"} {
+			recorder, _ := sendTestError(t, http.Header{"Accept": {accept}}, respondWithInvalidJson)
+
+			if recorder.Code != http.StatusBadRequest || recorder.Header().Get("Content-Type") != "text/plain; charset=utf-8" || recorder.Body.String() != ErrInvalidJsonPayload.Error() {
+				t.Fatalf("Accept %q: expected the plain text message, received %d %q", accept, recorder.Code, recorder.Body.String())
+			}
+		}
+	})
+
+	t.Run("json with the code of the sentinel", func(t *testing.T) {
+		recorder, decoded := sendTestError(t, http.Header{"Accept": {"application/json"}, "Accept-Language": {"en"}, "X-Request-Id": {"req-1"}}, respondWithInvalidJson)
+
+		want := ApiError{Code: "invalid_json_payload", Message: ErrInvalidJsonPayload.Error(), RequestId: "req-1"}
+		if recorder.Code != http.StatusBadRequest || decoded.Error.Code != want.Code || decoded.Error.Message != want.Message || decoded.Error.RequestId != want.RequestId {
+			t.Fatalf("Expected %+v, received %d %s", want, recorder.Code, recorder.Body.String())
+		}
+
+		if recorder.Header().Get("X-Request-Id") != "req-1" {
+			t.Fatalf("Expected the request id in the response, received %q", recorder.Header().Get("X-Request-Id"))
+		}
+	})
+
+	t.Run("message in the language of the request", func(t *testing.T) {
+		_, decoded := sendTestError(t, http.Header{"Accept": {"application/json"}, "Accept-Language": {"pl-PL,en;q=0.5"}}, respondWithInvalidJson)
+
+		if decoded.Error.Code != "invalid_json_payload" || decoded.Error.Message != "Nieprawidłowa treść json" {
+			t.Fatalf("Expected the Polish message, received %+v", decoded.Error)
+		}
+	})
+
+	t.Run("code of the status without a message", func(t *testing.T) {
+		_, decoded := sendTestError(t, http.Header{"Accept": {"application/json"}, "Accept-Language": {"en"}}, func(w http.ResponseWriter, r *http.Request) {
+			respondWith500(w, r, nil)
+		})
+
+		if decoded.Error.Code != "internal_server_error" || decoded.Error.Message != "Internal Server Error" {
+			t.Fatalf("Expected the code of the status, received %+v", decoded.Error)
+		}
+	})
+
+	t.Run("details of invalid fields", func(t *testing.T) {
+		respond := func(w http.ResponseWriter, r *http.Request) {
+			respondWithError(w, r, http.StatusBadRequest, fmt.Errorf("event 2: %v", activity.ErrInvalidType), newFieldError("events[2]", activity.ErrInvalidType))
+		}
+
+		recorder, _ := sendTestError(t, nil, respond)
+		if recorder.Body.String() != "event 2: invalid activity event type" {
+			t.Fatalf("Expected the plain text message, received %q", recorder.Body.String())
+		}
+
+		_, decoded := sendTestError(t, http.Header{"Accept": {"application/json"}, "Accept-Language": {"en"}}, respond)
+
+		want := FieldError{Field: "events[2]", Code: "invalid_activity_event_type", Message: activity.ErrInvalidType.Error()}
+		if decoded.Error.Code != "invalid_fields" || len(decoded.Error.Details) != 1 || decoded.Error.Details[0] != want {
+			t.Fatalf("Expected invalid_fields with %+v, received %+v", want, decoded.Error)
+		}
+	})
+
+	t.Run("details of broken validation rules", func(t *testing.T) {
+		errs := validation.Errors{{Field: "name", Err: validation.ErrTooLong, Args: []any{50}}, {Field: "email", Err: validation.ErrRequired}}
+		respond := func(w http.ResponseWriter, r *http.Request) {
+			respondWithError(w, r, http.StatusBadRequest, errs, newValidationFieldError(errs[0]), newValidationFieldError(errs[1]))
+		}
+
+		recorder, _ := sendTestError(t, nil, respond)
+		if recorder.Body.String() != "name: can have at most 50 characters; email: is required" {
+			t.Fatalf("Expected the plain text message, received %q", recorder.Body.String())
+		}
+
+		_, decoded := sendTestError(t, http.Header{"Accept": {"application/json"}, "Accept-Language": {"pl"}}, respond)
+
+		want := []FieldError{{Field: "name", Code: "too_long", Message: "może mieć najwyżej 50 znaków"}, {Field: "email", Code: "required", Message: "jest wymagane"}}
+		if decoded.Error.Code != "invalid_fields" || !reflect.DeepEqual(decoded.Error.Details, want) {
+			t.Fatalf("Expected invalid_fields with %+v, received %+v", want, decoded.Error)
+		}
+	})
+
+	t.Run("plural forms of the limits", func(t *testing.T) {
+		cases := []struct {
+			language string
+			args     []any
+			want     string
+		}{
+			{"pl", []any{1}, "może mieć najwyżej 1 znak"},
+			{"pl", []any{3}, "może mieć najwyżej 3 znaki"},
+			{"pl", []any{12}, "może mieć najwyżej 12 znaków"},
+			{"en", []any{1}, "can have at most 1 character"},
+			{"en", []any{3}, "can have at most 3 characters"},
+		}
+
+		for _, c := range cases {
+			fieldError := validation.FieldError{Field: "name", Err: validation.ErrTooLong, Args: c.args}
+			respond := func(w http.ResponseWriter, r *http.Request) {
+				respondWithError(w, r, http.StatusBadRequest, fieldError, newValidationFieldError(fieldError))
+			}
+
+			_, decoded := sendTestError(t, http.Header{"Accept": {"application/json"}, "Accept-Language": {c.language}}, respond)
+			if len(decoded.Error.Details) != 1 || decoded.Error.Details[0].Message != c.want {
+				t.Errorf("%s %v: expected %q, received %+v", c.language, c.args, c.want, decoded.Error.Details)
+			}
+		}
+
+		message := translateErrorMessage(i18n.LanguagePolish, "too_many_webhooks", webhooks.ErrTooManySubscriptions.Error())
+		if message != "Gospodarstwo domowe może mieć najwyżej 10 webhooków" {
+			t.Fatalf("Expected the limit of webhooks in the message, received %q", message)
+		}
+	})
+}
+
+func TestAssignRequestId(t *testing.T) {
+	generated := regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	for _, incoming := range []string{"", "contains spaces", string(make([]byte, maxRequestIdLength+1))} {
+		recorder, _ := sendTestError(t, http.Header{"X-Request-Id": {incoming}}, func(w http.ResponseWriter, r *http.Request) {
+			if requestId(r) != w.Header().Get("X-Request-Id") {
+				t.Fatalf("Expected the id of the response in the context, received %q", requestId(r))
+			}
+		})
+
+		if !generated.MatchString(recorder.Header().Get("X-Request-Id")) {
+			t.Fatalf("Expected a generated id instead of %q, received %q", incoming, recorder.Header().Get("X-Request-Id"))
+		}
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	code := regexp.MustCompile(`^[a-z]+(_[a-z]+)*$`)
+
+	// the sdk finds the sentinels of the codes, the ones with the same message must share a code
+	codesByMessage := map[string]string{}
+	for sentinel, sentinelCode := range errorCodes {
+		if !code.MatchString(sentinelCode) {
+			t.Errorf("%q: invalid code %q", sentinel, sentinelCode)
+		}
+
+		if other, found := codesByMessage[sentinel.Error()]; found && other != sentinelCode {
+			t.Errorf("%q: sentinels with the same message have the codes %q and %q", sentinel, sentinelCode, other)
+		}
+		codesByMessage[sentinel.Error()] = sentinelCode
+
+		// a count finds the plural messages too
+		for _, language := range i18n.Languages {
+			if _, ok := errorMessages.Lookup(language, sentinelCode, 1); !ok {
+				t.Errorf("%q: no %s message for %q", sentinel, language, sentinelCode)
+			}
+		}
+	}
+
+	// the languages translate the same codes, plural ones may have other forms
+	codesOf := func(language i18n.Language) []string {
+		var codes []string
+		for key := range errorMessages[language] {
+			for _, form := range []string{".one", ".few", ".many", ".other"} {
+				key = strings.TrimSuffix(key, form)
+			}
+
+			if !slices.Contains(codes, key) {
+				codes = append(codes, key)
+			}
+		}
+
+		slices.Sort(codes)
+		return codes
+	}
+
+	for _, language := range i18n.Languages {
+		if !slices.Equal(codesOf(language), codesOf(i18n.DefaultLanguage)) {
+			t.Errorf("The %s codes differ from the %s ones:\n%v\n%v", language, i18n.DefaultLanguage, codesOf(language), codesOf(i18n.DefaultLanguage))
+		}
+	}
+
+	if missing := errorMessages.Missing(); len(missing) > 0 {
+		t.Errorf("Missing translations: %v", missing)
+	}
+}
@@ -3,78 +3,132 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
-	"net/mail"
-	"net/url"
+	"strings"
+
+	"domanscy.group/parental-controls/server/validation"
 )
 
+// maxJsonRequestBodySize leaves room for calendar imports, the largest json bodies of the routes.
+const maxJsonRequestBodySize = 1024 * 1024
+
 var ErrInvalidJsonPayload = errors.New("Invalid json payload")
 var ErrInvalidEmail = errors.New("Invalid email")
 var ErrInvalidCallbackUrl = errors.New("Invalid callback url")
+var ErrRequestBodyTooLarge = fmt.Errorf("request body can not be larger than %d bytes", maxJsonRequestBodySize)
+var ErrUnknownField = errors.New("unknown field")
+var ErrInvalidFieldType = errors.New("invalid type of field")
 
-func respondWith400(w http.ResponseWriter, _ *http.Request, message string) {
-	if message == "" {
-		message = "Bad Request"
-	}
+func respondWith400(w http.ResponseWriter, r *http.Request, err error) {
+	respondWithError(w, r, 400, err)
+}
 
-	w.WriteHeader(400)
-	_, err := w.Write([]byte(message))
-	if err != nil {
-		log.Println("Error writing response:", err)
-	}
+// fieldSentinels is implemented by the bodies whose fields had sentinels of their own before their rules were checked
+// by validate tags. A single invalid field is answered with its sentinel, so the plain text stays what clients expect.
+type fieldSentinels interface {
+	// fieldSentinel returns nil for fields without a sentinel.
+	fieldSentinel(field string) error
+}
+
+// decodeJsonRequestBody decodes the json body into a T and checks the rules of its validate tags, see the validation
+// package, every broken rule is reported. Bodies larger than maxJsonRequestBodySize, unknown fields and anything after
+// the json value are rejected. The error response is sent already when an error is returned.
+func decodeJsonRequestBody[T any](w http.ResponseWriter, r *http.Request) (T, error) {
+	return decodeJson[T](w, r, http.MaxBytesReader(w, r.Body, maxJsonRequestBodySize))
 }
 
-func decodeJsonRequestBodyAndSendHttpErrorIfInvalid(w http.ResponseWriter, r *http.Request, decodedStruct interface{}) error {
-	decoder := json.NewDecoder(r.Body)
+// decodeJson is decodeJsonRequestBody for bodies read already, e.g. to check their signature or decompress them.
+func decodeJson[T any](w http.ResponseWriter, r *http.Request, body io.Reader) (T, error) {
+	var requestBody T
 
-	err := decoder.Decode(decodedStruct)
-	if err != nil {
-		respondWith400(w, r, ErrInvalidJsonPayload.Error())
-		return err
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&requestBody)
+	if err == nil {
+		_, err = decoder.Token()
+		if err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = ErrInvalidJsonPayload
+		}
 	}
 
-	return nil
-}
+	var sizeErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+
+	if errors.As(err, &sizeErr) {
+		respondWithError(w, r, http.StatusRequestEntityTooLarge, ErrRequestBodyTooLarge)
+		return requestBody, err
+	} else if errors.As(err, &typeErr) && typeErr.Field != "" {
+		respondWithError(w, r, http.StatusBadRequest, ErrInvalidJsonPayload, newFieldError(typeErr.Field, ErrInvalidFieldType))
+		return requestBody, err
+	} else if field, ok := strings.CutPrefix(fmt.Sprint(err), "json: unknown field "); ok {
+		respondWithError(w, r, http.StatusBadRequest, ErrInvalidJsonPayload, newFieldError(strings.Trim(field, `"`), ErrUnknownField))
+		return requestBody, err
+	} else if err != nil {
+		respondWith400(w, r, ErrInvalidJsonPayload)
+		return requestBody, err
+	}
 
-func parseEmailAddressAndHandleErrorIfInvalid(w http.ResponseWriter, r *http.Request, email string) error {
-	address, err := mail.ParseAddress(email)
-	if err != nil {
-		respondWith400(w, r, ErrInvalidEmail.Error())
-		return err
+	errs := validation.Validate(requestBody)
+	if body, ok := any(requestBody).(fieldSentinels); ok && len(errs) == 1 {
+		if sentinel := body.fieldSentinel(errs[0].Field); sentinel != nil {
+			respondWithError(w, r, http.StatusBadRequest, sentinel, newValidationFieldError(errs[0]))
+			return requestBody, errs
+		}
 	}
 
-	if !(address.Name == "" && address.Address != "") {
-		respondWith400(w, r, ErrInvalidEmail.Error())
-		return ErrInvalidEmail
+	if len(errs) != 0 {
+		details := make([]FieldError, 0, len(errs))
+		for _, fieldError := range errs {
+			details = append(details, newValidationFieldError(fieldError))
+		}
+
+		respondWithError(w, r, http.StatusBadRequest, errs, details...)
+		return requestBody, errs
 	}
 
-	return nil
+	return requestBody, nil
 }
 
-func parseUrlAndHandleErrorIfInvalid(w http.ResponseWriter, r *http.Request, value string) (*url.URL, error) {
-	parsed, err := url.Parse(value)
-	if err != nil {
-		respondWith400(w, r, ErrInvalidCallbackUrl.Error())
-		return nil, err
-	} else if parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
-		respondWith400(w, r, ErrInvalidCallbackUrl.Error())
-		return nil, ErrInvalidCallbackUrl
-	}
+func respondWith500(w http.ResponseWriter, r *http.Request, err error) {
+	respondWithError(w, r, 500, err)
+}
+
+func respondWith401(w http.ResponseWriter, r *http.Request, err error) {
+	respondWithError(w, r, 401, err)
+}
+
+func respondWith404(w http.ResponseWriter, r *http.Request, err error) {
+	respondWithError(w, r, 404, err)
+}
+
+func respondWith409(w http.ResponseWriter, r *http.Request, err error) {
+	respondWithError(w, r, 409, err)
+}
 
-	return parsed, nil
+func respondWith429(w http.ResponseWriter, r *http.Request, err error) {
+	respondWithError(w, r, 429, err)
 }
 
-func respondWith500(w http.ResponseWriter, _ *http.Request, message string) {
-	if message == "" {
-		message = "Internal Server Error"
+func respondWithJson(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("Error encoding json response: %v", err)
+		respondWith500(w, r, nil)
+		return
 	}
 
-	w.WriteHeader(500)
-	_, err := w.Write([]byte(message))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(encoded)
 	if err != nil {
-		log.Printf("Error responding with 500: %v", err)
+		log.Println("Error writing response:", err)
 	}
 }
 
@@ -92,3 +146,13 @@ func getIPAddressFromRequest(_ http.ResponseWriter, req *http.Request) (string,
 
 	return "", errors.New("could not retrieve ip address from this request")
 }
+
+// respondWithHtml renders one of the client/components pages.
+func respondWithHtml(w http.ResponseWriter, _ *http.Request, status int, page interface{ Render() string }) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, err := w.Write([]byte(page.Render()))
+	if err != nil {
+		log.Println("Error writing response:", err)
+	}
+}
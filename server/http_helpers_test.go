@@ -0,0 +1,216 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"domanscy.group/parental-controls/server/apprules"
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/calendar"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/commands"
+	"domanscy.group/parental-controls/server/domainrules"
+	"domanscy.group/parental-controls/server/policytemplates"
+	"domanscy.group/parental-controls/server/rewards"
+	"domanscy.group/parental-controls/server/validation"
+	"domanscy.group/parental-controls/server/webhooks"
+)
+
+type testDecodedRequestBody struct {
+	Name     string   `json:"name" validate:"required,length=..5"`
+	Email    string   `json:"email" validate:"email"`
+	Mode     string   `json:"mode" validate:"oneof=strict moderate"`
+	Bedtimes []string `json:"bedtimes" validate:"timeofday"`
+	Days     int      `json:"days"`
+}
+
+func TestDecodeJsonRequestBody(t *testing.T) {
+	decode := func(body string) (testDecodedRequestBody, int, ErrorResponse, error) {
+		var decoded testDecodedRequestBody
+		var decodeErr error
+
+		recorder, response := sendTestError(t, http.Header{"Accept": {"application/json"}, "Accept-Language": {"en"}}, func(w http.ResponseWriter, r *http.Request) {
+			r.Body = io.NopCloser(strings.NewReader(body))
+
+			decoded, decodeErr = decodeJsonRequestBody[testDecodedRequestBody](w, r)
+		})
+
+		return decoded, recorder.Code, response, decodeErr
+	}
+
+	t.Run("valid body", func(t *testing.T) {
+		decoded, _, _, err := decode(`{"name": "Ada", "mode": "strict", "bedtimes": ["20:30"], "days": 2}` + "\n")
+		doTFatalIfErr(t, err)
+
+		want := testDecodedRequestBody{Name: "Ada", Mode: "strict", Bedtimes: []string{"20:30"}, Days: 2}
+		if !reflect.DeepEqual(decoded, want) {
+			t.Fatalf("Expected %+v, received %+v", want, decoded)
+		}
+	})
+
+	t.Run("every broken rule at once", func(t *testing.T) {
+		_, status, response, err := decode(`{"name": "Adelajda", "email": "ada", "mode": "off", "bedtimes": ["20:30", "8pm"]}`)
+
+		want := []FieldError{
+			{Field: "name", Code: "too_long", Message: "can have at most 5 characters"},
+			{Field: "email", Code: "not_email", Message: "must be an email address"},
+			{Field: "mode", Code: "not_allowed", Message: "must be one of strict, moderate"},
+			{Field: "bedtimes[1]", Code: "invalid_time_of_day", Message: "must be a time of day in format HH:MM"},
+		}
+		if err == nil || status != http.StatusBadRequest || response.Error.Code != "invalid_fields" || !reflect.DeepEqual(response.Error.Details, want) {
+			t.Fatalf("Expected %+v, received %d %+v", want, status, response.Error)
+		}
+	})
+
+	t.Run("strict decoding", func(t *testing.T) {
+		cases := []struct {
+			name    string
+			body    string
+			details []FieldError
+		}{
+			{"unknown field", `{"name": "Ada", "nmae": "Ada"}`, []FieldError{{Field: "nmae", Code: "unknown_field", Message: ErrUnknownField.Error()}}},
+			{"invalid type", `{"name": "Ada", "days": "2"}`, []FieldError{{Field: "days", Code: "invalid_field_type", Message: ErrInvalidFieldType.Error()}}},
+			{"trailing data", `{"name": "Ada"} garbage`, nil},
+			{"second value", `{"name": "Ada"}{"name": "Ada"}`, nil},
+			{"empty body", "", nil},
+		}
+
+		for _, c := range cases {
+			_, status, response, err := decode(c.body)
+
+			if err == nil || status != http.StatusBadRequest || response.Error.Code != "invalid_json_payload" || !reflect.DeepEqual(response.Error.Details, c.details) {
+				t.Errorf("%s: expected invalid_json_payload with %+v, received %d %+v", c.name, c.details, status, response.Error)
+			}
+		}
+	})
+
+	t.Run("single invalid field with a sentinel", func(t *testing.T) {
+		decodeLogin := func(header http.Header, body string) (*httptest.ResponseRecorder, ErrorResponse) {
+			return sendTestError(t, header, func(w http.ResponseWriter, r *http.Request) {
+				r.Body = io.NopCloser(strings.NewReader(body))
+
+				_, _ = decodeJsonRequestBody[LoginRequestBody](w, r)
+			})
+		}
+
+		recorder, _ := decodeLogin(nil, `{"email": "ada", "callback": "https://example.com"}`)
+		if recorder.Code != http.StatusBadRequest || recorder.Body.String() != ErrInvalidEmail.Error() {
+			t.Errorf("Expected plain text %q, received %d %q", ErrInvalidEmail, recorder.Code, recorder.Body.String())
+		}
+
+		_, response := decodeLogin(http.Header{"Accept": {"application/json"}, "Accept-Language": {"en"}}, `{"email": "ada@example.com", "callback": "nowhere"}`)
+
+		want := []FieldError{{Field: "callback", Code: "not_url", Message: "must be an absolute http or https url"}}
+		if response.Error.Code != "invalid_callback_url" || !reflect.DeepEqual(response.Error.Details, want) {
+			t.Errorf("Expected invalid_callback_url with %+v, received %+v", want, response.Error)
+		}
+
+		recorder, _ = decodeLogin(nil, `{"email": "ada", "callback": "nowhere"}`)
+		if recorder.Body.String() == ErrInvalidEmail.Error() || recorder.Body.String() == ErrInvalidCallbackUrl.Error() {
+			t.Errorf("Expected every invalid field to be described, received %q", recorder.Body.String())
+		}
+	})
+
+	t.Run("body size limit", func(t *testing.T) {
+		_, status, response, err := decode(`{"name": "` + strings.Repeat("a", maxJsonRequestBodySize) + `"}`)
+
+		if err == nil || status != http.StatusRequestEntityTooLarge || response.Error.Code != "request_body_too_large" {
+			t.Fatalf("Expected request_body_too_large, received %d %+v", status, response.Error)
+		}
+	})
+}
+
+// TestRequestBodyRules keeps the rules of the request bodies in line with the values and limits of the packages the
+// bodies are passed to.
+func TestRequestBodyRules(t *testing.T) {
+	rulesOf := func(body any, field string) []validation.Rule {
+		structField, found := reflect.TypeOf(body).FieldByName(field)
+		if !found {
+			t.Fatalf("%T has no field %s", body, field)
+		}
+
+		rules, err := validation.ParseRules(structField.Tag.Get("validate"))
+		doTFatalIfErr(t, err)
+
+		return rules
+	}
+
+	ruleOf := func(body any, field string, name string) validation.Rule {
+		for _, rule := range rulesOf(body, field) {
+			if rule.Name == name {
+				return rule
+			}
+		}
+
+		t.Fatalf("%T.%s has no %s rule", body, field, name)
+		return validation.Rule{}
+	}
+
+	categories := make([]string, 0, len(blocklists.Categories))
+	for _, category := range blocklists.Categories {
+		categories = append(categories, string(category))
+	}
+
+	eventTypes := make([]string, 0, len(webhooks.EventTypes))
+	for _, eventType := range webhooks.EventTypes {
+		eventTypes = append(eventTypes, string(eventType))
+	}
+
+	oneOfCases := []struct {
+		body    any
+		field   string
+		isValid func(value string) bool
+		// all are the valid values when the package lists them
+		all []string
+	}{
+		{CreateAppRuleRequestBody{}, "MatchType", func(value string) bool { return apprules.MatchType(value).IsValid() }, nil},
+		{CreateAppRuleRequestBody{}, "Action", func(value string) bool { return apprules.Action(value).IsValid() }, nil},
+		{CreateDomainRuleRequestBody{}, "Action", func(value string) bool { return domainrules.Action(value).IsValid() }, nil},
+		{UpdateBlockModeRequestBody{}, "BlockMode", func(value string) bool { return children.BlockMode(value).IsValid() }, nil},
+		{CreatePathRuleRequestBody{}, "Action", func(value string) bool { return domainrules.Action(value).IsValid() }, nil},
+		{CreateCommandRequestBody{}, "Type", func(value string) bool { return commands.Type(value).IsValid() }, nil},
+		{UpdateSafeSearchRequestBody{}, "YoutubeRestrictedMode", func(value string) bool { return children.YoutubeRestrictedMode(value).IsValid() }, nil},
+		{UpdateBlockedCategoriesRequestBody{}, "Categories", func(value string) bool { return blocklists.Category(value).IsValid() }, categories},
+		{CreatePolicyTemplateRequestBody{}, "BlockedCategories", func(value string) bool { return blocklists.Category(value).IsValid() }, categories},
+		{CreatePolicyTemplateRequestBody{}, "YoutubeRestrictedMode", func(value string) bool { return children.YoutubeRestrictedMode(value).IsValid() }, nil},
+		{CreatePolicyTemplateRequestBody{}, "BlockMode", func(value string) bool { return children.BlockMode(value).IsValid() }, nil},
+		{CreateWebhookRequestBody{}, "EventTypes", func(value string) bool { return webhooks.EventType(value).IsValid() }, eventTypes},
+		{CreateChoreRequestBody{}, "Recurrence", func(value string) bool { return rewards.Recurrence(value).IsValid() }, nil},
+	}
+
+	for _, c := range oneOfCases {
+		values := ruleOf(c.body, c.field, validation.RuleOneOf).Values
+		for _, value := range values {
+			if !c.isValid(value) {
+				t.Errorf("%T.%s: %q is not valid", c.body, c.field, value)
+			}
+		}
+
+		if c.all != nil && !reflect.DeepEqual(values, c.all) {
+			t.Errorf("%T.%s: expected %v, received %v", c.body, c.field, c.all, values)
+		}
+	}
+
+	lengthCases := []struct {
+		body  any
+		field string
+		max   int
+	}{
+		{CreateCommandRequestBody{}, "Message", commands.MaxMessageLength},
+		{CreateCalendarEntryRequestBody{}, "Name", calendar.MaxNameLength},
+		{CreatePolicyTemplateRequestBody{}, "Name", policytemplates.MaxNameLength},
+		{CreateWebhookRequestBody{}, "Url", webhooks.MaxUrlLength},
+		{CreateChoreRequestBody{}, "Title", rewards.MaxTitleLength},
+		{CreatePointsAdjustmentRequestBody{}, "Description", rewards.MaxTitleLength},
+	}
+
+	for _, c := range lengthCases {
+		if rule := ruleOf(c.body, c.field, validation.RuleLength); rule.Max != c.max {
+			t.Errorf("%T.%s: expected the maximum length %d, received %d", c.body, c.field, c.max, rule.Max)
+		}
+	}
+}
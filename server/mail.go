@@ -1,42 +1,85 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"net/smtp"
 	"strings"
+
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/users"
 )
 
-func sendMailAndHandleError(
-	w http.ResponseWriter,
-	r *http.Request,
-	smtpAddress string,
-	smtpPort uint16,
-	fromAddress string,
-	toAddress string,
-	subject string,
-	body string,
-) error {
+// findChildAndParent returns the child and the owner of its household, who receives emails about the child.
+func findChildAndParent(tx *sql.Tx, childId int) (*children.Model, *users.Model, error) {
+	child, err := children.FindOneById(tx, childId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if child == nil {
+		return nil, nil, fmt.Errorf("child %d not found", childId)
+	}
+
+	household, err := households.FindOneById(tx, child.HouseholdId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if household == nil {
+		return nil, nil, fmt.Errorf("household %d of child %d not found", child.HouseholdId, child.Id)
+	}
+
+	parent, err := users.FindOneById(tx, household.OwnerUserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if parent == nil {
+		return nil, nil, fmt.Errorf("owner %d of household %d not found", household.OwnerUserId, household.Id)
+	}
+
+	return child, parent, nil
+}
+
+// sendMail sends an html email, handlers should use sendMailAndHandleError instead.
+func sendMail(smtpAddress string, smtpPort uint16, fromAddress string, toAddress string, subject string, body string) error {
 	var message strings.Builder
 
 	message.WriteString(fmt.Sprintf("From: %s\r\n", fromAddress))
 	message.WriteString(fmt.Sprintf("To: %s\r\n", toAddress))
-	message.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
+	message.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject)))
 	message.WriteString(fmt.Sprintf("Content-Type: text/html; charset=utf-8\r\n"))
 	message.WriteString("\r\n")
 	message.WriteString(body)
 
-	err := smtp.SendMail(
+	return smtp.SendMail(
 		fmt.Sprintf("%s:%d", smtpAddress, smtpPort),
 		nil,
 		fromAddress,
 		[]string{toAddress},
 		[]byte(message.String()),
 	)
+}
+
+func sendMailAndHandleError(
+	w http.ResponseWriter,
+	r *http.Request,
+	smtpAddress string,
+	smtpPort uint16,
+	fromAddress string,
+	toAddress string,
+	subject string,
+	body string,
+) error {
+	err := sendMail(smtpAddress, smtpPort, fromAddress, toAddress, subject, body)
 	if err != nil {
 		log.Println(err)
-		respondWith500(w, r, "")
+		respondWith500(w, r, nil)
 		return err
 	}
 
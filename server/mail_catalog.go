@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/users"
+)
+
+// mailCatalog holds the texts of the emails, the subjects included, and of the calendars exported for the parent.
+var mailCatalog = i18n.Catalog{
+	i18n.LanguagePolish: {
+		"approve_for": "Zezwól na %s",
+		"deny":        "Odrzuć",
+
+		"duration.minutes.one":  "minutę",
+		"duration.minutes.few":  "%d minuty",
+		"duration.minutes.many": "%d minut",
+		"duration.hours.one":    "godzinę",
+		"duration.hours.few":    "%d godziny",
+		"duration.hours.many":   "%d godzin",
+		"duration.whole_day":    "cały dzień",
+
+		"login.subject":   "Potwierdź logowanie do kontroli rodzicielskiej",
+		"login.requested": "Otrzymaliśmy prośbę o zalogowanie się do systemu.",
+		"login.confirm":   "Prosimy o potwierdzenie czy ta osoba może się zalogować.",
+		"login.ip":        "Adres IP logowania: %s",
+		"login.allow":     "Zezwól",
+
+		"register.subject":       "Potwierdź rejestracje w kontroli rodzicielskiej",
+		"register.header":        "Witaj w systemie kontroli rodzicielskiej!",
+		"register.confirm":       "Jeżeli chcesz się zarejestrować, kliknij przycisk poniżej.",
+		"register.ignore":        "Jeżeli to nie ty się rejestrowałeś, zignoruj tego maila, ktoś najwyraźniej się pomylił.",
+		"register.unofficial":    "Uwaga! Ta prośba o potwierdzenie nie pochodzi z oficjalnej strony.",
+		"register.check_address": "Dokładnie sprawdź adres widniejący na przycisku poniżej!",
+		"register.finish":        "Dokończ rejestrację w %s",
+
+		"access_request.subject":             "%s prosi o dostęp do %s",
+		"access_request.blocked_by_category": "Strona została zablokowana ze względu na kategorię %s (wpis: %s).",
+		"access_request.blocked_by_rule":     "Strona została zablokowana przez regułę %s.",
+		"access_request.hint":                "Możesz zezwolić na dostęp na określony czas, po nim strona znowu będzie blokowana.",
+
+		"time_extension_request.subject.one":  "%s prosi o %d minutę dodatkowego czasu",
+		"time_extension_request.subject.few":  "%s prosi o %d minuty dodatkowego czasu",
+		"time_extension_request.subject.many": "%s prosi o %d minut dodatkowego czasu",
+		"time_extension_request.reason":       "Powód: %s",
+
+		"device_alert.subject":        "Urządzenie %s wymaga uwagi",
+		"device_alert.header":         "Urządzenie %s (%s) wymaga uwagi",
+		"device_alert.missing_since":  "Aplikacja kontroli rodzicielskiej nie odzywa się od",
+		"device_alert.missing_causes": "Urządzenie może być wyłączone, bez internetu albo aplikacja została zatrzymana.",
+		"device_alert.tamper_events":  "Wykryto próby obejścia ochrony:",
+		"device_alert.when":           "Kiedy",
+		"device_alert.what":           "Co się stało",
+		"device_alert.detail":         "Szczegóły",
+
+		"tamper.clock_changed":        "Zmieniono zegar urządzenia",
+		"tamper.uninstall_attempt":    "Próba odinstalowania aplikacji",
+		"tamper.policy_hash_mismatch": "Aplikacja nie stosuje aktualnych zasad",
+
+		"weekly_digest.subject":          "Podsumowanie tygodnia %s – %s",
+		"weekly_digest.screen_time":      "Czas przed ekranem",
+		"weekly_digest.blocked_attempts": "Zablokowane próby",
+		"weekly_digest.time_requests":    "Prośby o dodatkowy czas",
+		"weekly_digest.granted.one":      "przyznano %d minutę",
+		"weekly_digest.granted.few":      "przyznano %d minuty",
+		"weekly_digest.granted.many":     "przyznano %d minut",
+		"weekly_digest.day":              "Dzień",
+		"weekly_digest.top_apps":         "Najczęściej używane aplikacje",
+		"weekly_digest.top_domains":      "Najczęściej odwiedzane strony",
+		"weekly_digest.minutes":          "%d min",
+		"weekly_digest.hours":            "%d godz. %d min",
+
+		"calendar.all_children":   "Wszystkie dzieci",
+		"calendar.screen_time":    "czas ekranowy: %d min dziennie",
+		"calendar.no_screen_time": "bez limitu czasu ekranowego",
+	},
+	i18n.LanguageEnglish: {
+		"approve_for": "Allow for %s",
+		"deny":        "Deny",
+
+		"duration.minutes.one":   "a minute",
+		"duration.minutes.other": "%d minutes",
+		"duration.hours.one":     "an hour",
+		"duration.hours.other":   "%d hours",
+		"duration.whole_day":     "the whole day",
+
+		"login.subject":   "Confirm logging in to parental controls",
+		"login.requested": "We received a request to log in to the system.",
+		"login.confirm":   "Please confirm whether this person may log in.",
+		"login.ip":        "IP address of the login: %s",
+		"login.allow":     "Allow",
+
+		"register.subject":       "Confirm signing up for parental controls",
+		"register.header":        "Welcome to parental controls!",
+		"register.confirm":       "If you want to sign up, click the button below.",
+		"register.ignore":        "If it was not you signing up, ignore this email, someone apparently made a mistake.",
+		"register.unofficial":    "Warning! This confirmation request does not come from the official site.",
+		"register.check_address": "Carefully check the address on the button below!",
+		"register.finish":        "Finish signing up at %s",
+
+		"access_request.subject":             "%s asks for access to %s",
+		"access_request.blocked_by_category": "The site was blocked because of the %s category (entry: %s).",
+		"access_request.blocked_by_rule":     "The site was blocked by the rule %s.",
+		"access_request.hint":                "You can allow access for a limited time, after that the site will be blocked again.",
+
+		"time_extension_request.subject.one":   "%s asks for %d minute of extra time",
+		"time_extension_request.subject.other": "%s asks for %d minutes of extra time",
+		"time_extension_request.reason":        "Reason: %s",
+
+		"device_alert.subject":        "Device %s needs attention",
+		"device_alert.header":         "Device %s (%s) needs attention",
+		"device_alert.missing_since":  "The parental controls app has not been heard from since",
+		"device_alert.missing_causes": "The device may be turned off or offline, or the app was stopped.",
+		"device_alert.tamper_events":  "Attempts to get around the protection were detected:",
+		"device_alert.when":           "When",
+		"device_alert.what":           "What happened",
+		"device_alert.detail":         "Details",
+
+		"tamper.clock_changed":        "The clock of the device was changed",
+		"tamper.uninstall_attempt":    "Attempt to uninstall the app",
+		"tamper.policy_hash_mismatch": "The app does not apply the current rules",
+
+		"weekly_digest.subject":          "Summary of the week %s – %s",
+		"weekly_digest.screen_time":      "Screen time",
+		"weekly_digest.blocked_attempts": "Blocked attempts",
+		"weekly_digest.time_requests":    "Extra time requests",
+		"weekly_digest.granted.one":      "%d minute granted",
+		"weekly_digest.granted.other":    "%d minutes granted",
+		"weekly_digest.day":              "Day",
+		"weekly_digest.top_apps":         "Most used apps",
+		"weekly_digest.top_domains":      "Most visited sites",
+		"weekly_digest.minutes":          "%d min",
+		"weekly_digest.hours":            "%d h %d min",
+
+		"calendar.all_children":   "All children",
+		"calendar.screen_time":    "screen time: %d min a day",
+		"calendar.no_screen_time": "no screen time limit",
+	},
+}
+
+// newMailTemplate parses an email, its texts are looked up with {{ t "key" }} in the language it is executed in.
+func newMailTemplate(body string) *template.Template {
+	return template.Must(template.New("email_template").Funcs(mailCatalog.TemplateFuncs(i18n.DefaultLanguage)).Parse(body))
+}
+
+// executeMailTemplate renders the email in the language of the recipient.
+func executeMailTemplate(tmpl *template.Template, language i18n.Language, data any) (string, error) {
+	translated, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	body := bytes.NewBuffer([]byte{})
+	err = translated.Funcs(mailCatalog.TemplateFuncs(language)).ExecuteTemplate(body, "email_template", data)
+	if err != nil {
+		return "", err
+	}
+
+	return body.String(), nil
+}
+
+// userLanguage is the language the user chose for the emails, DefaultLanguage for the ones who did not.
+func userLanguage(user *users.Model) i18n.Language {
+	language, supported := i18n.ParseLanguage(user.Language)
+	if !supported {
+		return i18n.DefaultLanguage
+	}
+
+	return language
+}
+
+// callbackLanguage is the language of the client the user came from, given by the lang parameter of its callback,
+// e.g. "https://dashboard.local/login?lang=en".
+func callbackLanguage(callback string) (i18n.Language, bool) {
+	callbackUrl, err := url.Parse(callback)
+	if err != nil {
+		return "", false
+	}
+
+	return i18n.ParseLanguage(callbackUrl.Query().Get("lang"))
+}
+
+// recipientLanguage picks the language of an email sent in answer to the request: the one the user chose, then the
+// one of the client they came from, then the Accept-Language header of the request. The user is nil before they
+// register.
+func recipientLanguage(r *http.Request, user *users.Model, callback string) i18n.Language {
+	if user != nil {
+		if language, supported := i18n.ParseLanguage(user.Language); supported {
+			return language
+		}
+	}
+
+	if language, supported := callbackLanguage(callback); supported {
+		return language
+	}
+
+	return i18n.PreferredLanguage(r.Header.Get("Accept-Language"))
+}
+
+// formatDuration names the duration the way it follows "approve_for", e.g. "30 minut", "godzinę" or "cały dzień".
+func formatDuration(language i18n.Language, minutes int) string {
+	switch {
+	case minutes == 24*60:
+		return mailCatalog.Text(language, "duration.whole_day")
+	case minutes%60 == 0:
+		return mailCatalog.Text(language, "duration.hours", minutes/60)
+	default:
+		return mailCatalog.Text(language, "duration.minutes", minutes)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/users"
+)
+
+func TestMailCatalog(t *testing.T) {
+	if missing := mailCatalog.Missing(); len(missing) > 0 {
+		t.Fatalf("Missing translations: %v", missing)
+	}
+}
+
+func TestExecuteMailTemplate(t *testing.T) {
+	data := struct {
+		InstanceAddr       string
+		IsOfficialInstance bool
+		Link               string
+	}{
+		InstanceAddr: "localhost:8080",
+		Link:         testingCfg.AppUrl + "/finish_registration/regkey",
+	}
+
+	expected := bytes.NewBuffer([]byte{})
+	err := embed01Template.ExecuteTemplate(expected, "embed01", struct {
+		AppUrl string
+		Token  string
+	}{
+		AppUrl: testingCfg.AppUrl,
+		Token:  "regkey",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	polish, err := executeMailTemplate(startRegistrationProcessEmailTemplate, i18n.LanguagePolish, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the expected email is stored with windows line endings
+	if !strings.Contains(polish, strings.ReplaceAll(expected.String(), "\r\n", "\n")) {
+		t.Fatalf("Expected the Polish email to stay the same.\n\nExpected:\n%s\n\nReceived:\n%s", expected.String(), polish)
+	}
+
+	english, err := executeMailTemplate(startRegistrationProcessEmailTemplate, i18n.LanguageEnglish, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, text := range []string{"Welcome to parental controls!", "Finish signing up at localhost:8080"} {
+		if !strings.Contains(english, text) {
+			t.Errorf("Expected %q in the English email, received:\n%s", text, english)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	cases := []struct {
+		language i18n.Language
+		minutes  int
+		want     string
+	}{
+		{i18n.LanguagePolish, 1, "minutę"},
+		{i18n.LanguagePolish, 15, "15 minut"},
+		{i18n.LanguagePolish, 22, "22 minuty"},
+		{i18n.LanguagePolish, 60, "godzinę"},
+		{i18n.LanguagePolish, 120, "2 godziny"},
+		{i18n.LanguagePolish, 300, "5 godzin"},
+		{i18n.LanguagePolish, 24 * 60, "cały dzień"},
+		{i18n.LanguageEnglish, 30, "30 minutes"},
+		{i18n.LanguageEnglish, 60, "an hour"},
+		{i18n.LanguageEnglish, 120, "2 hours"},
+	}
+
+	for _, c := range cases {
+		if label := formatDuration(c.language, c.minutes); label != c.want {
+			t.Errorf("%s %d: expected %q, received %q", c.language, c.minutes, c.want, label)
+		}
+	}
+}
+
+func TestRecipientLanguage(t *testing.T) {
+	request, err := http.NewRequest(http.MethodPost, "http://localhost:8080/auth/login", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+	cases := []struct {
+		user     *users.Model
+		callback string
+		want     i18n.Language
+	}{
+		{&users.Model{Language: "pl"}, "http://localhost:8080/login?lang=en", i18n.LanguagePolish},
+		{&users.Model{}, "http://localhost:8080/login?lang=pl", i18n.LanguagePolish},
+		{nil, "http://localhost:8080/login?lang=de", i18n.LanguageEnglish},
+		{nil, "http://localhost:8080/login", i18n.LanguageEnglish},
+	}
+
+	for _, c := range cases {
+		if language := recipientLanguage(request, c.user, c.callback); language != c.want {
+			t.Errorf("%+v %s: expected %s, received %s", c.user, c.callback, c.want, language)
+		}
+	}
+}
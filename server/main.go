@@ -1,16 +1,27 @@
 package main
 
 import (
+	"context"
 	"crypto/rsa"
 	"database/sql"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"time"
+	_ "time/tzdata"
 
 	"domanscy.group/env"
+	"domanscy.group/parental-controls/client/components"
+	"domanscy.group/parental-controls/server/blocklists"
 	"domanscy.group/parental-controls/server/database"
-	"domanscy.group/parental-controls/server/users"
+	"domanscy.group/parental-controls/server/dnsfilter"
+	"domanscy.group/parental-controls/server/migrations"
+	"domanscy.group/parental-controls/server/proxy"
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/reports"
+	"domanscy.group/parental-controls/server/scheduler"
+	"domanscy.group/parental-controls/server/webhooks"
 	"domanscy.group/rckstrvcache"
 	"github.com/go-chi/chi"
 	_ "github.com/mattn/go-sqlite3"
@@ -28,21 +39,132 @@ type ServerConfig struct {
 	BearerTokenPrivateKey *rsa.PrivateKey
 
 	DatabaseUrl string
+
+	DnsServerAddress string
+	DnsServerPort    uint16
+	// DnsUpstream is the host:port of the resolver that receives all allowed queries.
+	DnsUpstream string
+	// DnsBlockPageIp is returned for blocked domains of children in block page mode, nil if not configured.
+	DnsBlockPageIp net.IP
+	// BlockPageServerAddress is the host:port the block page is served on, it should be reachable at DnsBlockPageIp on port 80.
+	// Empty if the block page server is disabled.
+	BlockPageServerAddress string
+	// ProxyServerAddress is the host:port of the filtering forward proxy, for households that can not change the dns
+	// server of their router. Empty if the proxy is disabled.
+	ProxyServerAddress string
+
+	// ReportsLocation is the timezone days of usage reports and the weekly digest start at midnight in.
+	ReportsLocation *time.Location
+
+	// HeartbeatTimeout is how long a device with an agent may stay silent before parents are alerted.
+	HeartbeatTimeout time.Duration
 }
 
-func NewServer(cfg ServerConfig, regkeysStore *rckstrvcache.Store, oneTimeAccessTokenStore *rckstrvcache.Store, db *sql.DB) http.Handler {
+func NewServer(cfg ServerConfig, regkeysStore *rckstrvcache.Store, oneTimeAccessTokenStore *rckstrvcache.Store, timeExtensionTokensStore *rckstrvcache.Store, db *sql.DB, blocklistMatcher *blocklists.Matcher, pushHub *push.Hub) http.Handler {
 	r := chi.NewRouter()
+	r.Use(AssignRequestId)
+
+	dnsFilter := dnsfilter.NewFilter(cfg.DnsUpstream, cfg.DnsBlockPageIp)
+	dnsPolicies := dnsfilter.NewDatabasePolicySource(db, blocklistMatcher)
+	dnsQueryLog := dnsfilter.NewDatabaseQueryLog(db)
+	ingestLimiter := newIngestLimiter()
+
+	r.Get("/openapi.json", HttpOpenApi(&cfg))
 
 	r.Post("/login", HttpAuthLogin(&cfg, regkeysStore, oneTimeAccessTokenStore, db))
 	r.Post("/register", HttpAuthStartRegistrationProcess(&cfg, regkeysStore, oneTimeAccessTokenStore, db))
 	r.Get("/finish_registration/{regkey}", HttpAuthFinishRegistrationProcess(&cfg, regkeysStore, oneTimeAccessTokenStore, db))
 	r.Post("/get_bearer_from_otat/{otat}", HttpAuthGetBearerTokenFromOtat(&cfg, regkeysStore, oneTimeAccessTokenStore, db))
 
+	r.Get("/assets/*", http.StripPrefix("/assets/", http.FileServerFS(components.Assets())).ServeHTTP)
+	r.Get("/access_requests/{token}/approve", HttpAccessRequestsApprove(&cfg, pushHub, db))
+	r.Get("/access_requests/{token}/deny", HttpAccessRequestsDeny(&cfg, pushHub, db))
+	r.Get("/time_extension_requests/decide/{token}", HttpTimeExtensionRequestsDecide(&cfg, timeExtensionTokensStore, pushHub, db))
+
+	r.Get("/dns-query/{token}", HttpDnsQuery(&cfg, dnsFilter, dnsPolicies, dnsQueryLog))
+	r.Post("/dns-query/{token}", HttpDnsQuery(&cfg, dnsFilter, dnsPolicies, dnsQueryLog))
+	r.Get("/calendar_feeds/{token}.ics", HttpCalendarFeed(&cfg, db))
+
+	r.Group(func(r chi.Router) {
+		r.Use(AuthenticateBearerToken(&cfg), LoadUserLanguage(db))
+
+		r.Put("/me/language", HttpUsersUpdateLanguage(&cfg, db))
+		r.Post("/children/{childId}/devices", HttpDevicesCreate(&cfg, db))
+		r.Put("/children/{childId}/safe_search", HttpChildrenUpdateSafeSearch(&cfg, pushHub, db))
+		r.Put("/children/{childId}/blocked_categories", HttpChildrenUpdateBlockedCategories(&cfg, pushHub, db))
+		r.Get("/children/{childId}/time_extension_requests", HttpChildrenTimeExtensionRequestsList(&cfg, db))
+		r.Post("/children/{childId}/commands", HttpChildrenCommandsCreate(&cfg, pushHub, db))
+		r.Get("/children/{childId}/commands", HttpChildrenCommandsList(&cfg, db))
+		r.Get("/children/{childId}/reports/daily", HttpChildrenReportsDaily(&cfg, db))
+		r.Post("/households", HttpHouseholdsCreate(&cfg, db))
+		r.Get("/households", HttpHouseholdsList(&cfg, db))
+		r.Put("/households/{householdId}/activity_retention", HttpHouseholdsUpdateActivityRetention(&cfg, db))
+		r.Post("/households/{householdId}/children", HttpHouseholdsChildrenCreate(&cfg, db))
+		r.Get("/households/{householdId}/children", HttpHouseholdsChildrenList(&cfg, db))
+		r.Get("/households/{householdId}/policy_templates", HttpHouseholdsPolicyTemplatesList(&cfg, db))
+		r.Post("/households/{householdId}/policy_templates", HttpHouseholdsPolicyTemplatesCreate(&cfg, db))
+		r.Delete("/households/{householdId}/policy_templates/{templateKey}", HttpHouseholdsPolicyTemplatesDelete(&cfg, db))
+		r.Post("/households/{householdId}/policy_templates/{templateKey}/apply", HttpHouseholdsPolicyTemplatesApply(&cfg, pushHub, db))
+		r.Get("/households/{householdId}/calendar", HttpHouseholdsCalendarList(&cfg, db))
+		r.Post("/households/{householdId}/calendar", HttpHouseholdsCalendarCreate(&cfg, pushHub, db))
+		r.Delete("/households/{householdId}/calendar/{entryId}", HttpHouseholdsCalendarDelete(&cfg, pushHub, db))
+		r.Post("/households/{householdId}/calendar/import", HttpHouseholdsCalendarImport(&cfg, pushHub, db))
+		r.Get("/households/{householdId}/calendar.ics", HttpHouseholdsCalendarExport(&cfg, db))
+		r.Post("/households/{householdId}/calendar/feed", HttpHouseholdsCalendarFeedRotate(&cfg, db))
+		r.Get("/households/{householdId}/webhooks", HttpHouseholdsWebhooksList(&cfg, db))
+		r.Post("/households/{householdId}/webhooks", HttpHouseholdsWebhooksCreate(&cfg, db))
+		r.Delete("/households/{householdId}/webhooks/{webhookId}", HttpHouseholdsWebhooksDelete(&cfg, db))
+		r.Get("/households/{householdId}/webhooks/{webhookId}/deliveries", HttpHouseholdsWebhooksDeliveriesList(&cfg, db))
+		r.Post("/households/{householdId}/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", HttpHouseholdsWebhooksDeliveriesRedeliver(&cfg, db))
+		r.Put("/children/{childId}/birth_date", HttpChildrenUpdateBirthDate(&cfg, db))
+		r.Post("/children/{childId}/app_rules", HttpChildrenAppRulesCreate(&cfg, pushHub, db))
+		r.Get("/children/{childId}/app_rules", HttpChildrenAppRulesList(&cfg, db))
+		r.Delete("/children/{childId}/app_rules/{ruleId}", HttpChildrenAppRulesDelete(&cfg, pushHub, db))
+		r.Put("/children/{childId}/screen_time", HttpChildrenUpdateScreenTime(&cfg, pushHub, db))
+		r.Post("/children/{childId}/domain_rules", HttpChildrenDomainRulesCreate(&cfg, db))
+		r.Get("/children/{childId}/domain_rules", HttpChildrenDomainRulesList(&cfg, db))
+		r.Delete("/children/{childId}/domain_rules/{ruleId}", HttpChildrenDomainRulesDelete(&cfg, db))
+		r.Put("/children/{childId}/block_mode", HttpChildrenUpdateBlockMode(&cfg, db))
+		r.Post("/children/{childId}/path_rules", HttpChildrenPathRulesCreate(&cfg, db))
+		r.Get("/children/{childId}/path_rules", HttpChildrenPathRulesList(&cfg, db))
+		r.Delete("/children/{childId}/path_rules/{ruleId}", HttpChildrenPathRulesDelete(&cfg, db))
+		r.Post("/children/{childId}/chores", HttpChildrenChoresCreate(&cfg, db))
+		r.Get("/children/{childId}/chores", HttpChildrenChoresList(&cfg, db))
+		r.Delete("/children/{childId}/chores/{choreId}", HttpChildrenChoresDelete(&cfg, db))
+		r.Get("/children/{childId}/chore_completions", HttpChildrenChoreCompletionsList(&cfg, db))
+		r.Post("/children/{childId}/chore_completions/{completionId}/decision", HttpChildrenChoreCompletionsDecide(&cfg, db))
+		r.Get("/children/{childId}/points", HttpChildrenPointsGet(&cfg, db))
+		r.Post("/children/{childId}/points/adjustments", HttpChildrenPointsAdjustmentsCreate(&cfg, db))
+		r.Put("/children/{childId}/exchange_rate", HttpChildrenUpdateExchangeRate(&cfg, db))
+		r.Get("/devices/{deviceId}/health", HttpDevicesHealth(&cfg, db))
+		r.Post("/devices/{deviceId}/heartbeat_key", HttpDevicesHeartbeatKeyRotate(&cfg, db))
+		r.Put("/devices/{deviceId}/ip_address", HttpDevicesUpdateIpAddress(&cfg, db))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(AuthenticateDeviceToken(db))
+
+		r.Post("/device/time_extension_requests", HttpDeviceTimeExtensionRequestsCreate(&cfg, timeExtensionTokensStore, db))
+		r.Get("/device/time_extension_requests/{requestId}", HttpDeviceTimeExtensionRequestsGet(&cfg, db))
+		r.Get("/device/events", HttpDeviceEvents(&cfg, pushHub))
+		r.Get("/device/commands", HttpDeviceCommandsList(&cfg, db))
+		r.Post("/device/commands/{commandId}/ack", HttpDeviceCommandsAcknowledge(&cfg, db))
+		r.Post("/devices/{deviceId}/events", HttpDevicesEventsIngest(&cfg, ingestLimiter, db))
+		r.Get("/device/app_rules", HttpDeviceAppRulesList(&cfg, db))
+		r.Get("/device/screen_time", HttpDeviceScreenTime(&cfg, db))
+		r.Get("/device/chores", HttpDeviceChoresList(&cfg, db))
+		r.Post("/device/chores/{choreId}/completions", HttpDeviceChoreCompletionsCreate(&cfg, db))
+		r.Get("/device/points", HttpDevicePointsGet(&cfg, db))
+		r.Post("/device/points/redemptions", HttpDevicePointsRedemptionsCreate(&cfg, pushHub, db))
+		r.Post("/device/heartbeats", HttpDeviceHeartbeatsCreate(&cfg, db))
+		r.Post("/device/time_tokens", HttpDeviceTimeTokensCreate(&cfg))
+	})
+
 	return r
 }
 
-func startServer(cfg ServerConfig, regkeysStore *rckstrvcache.Store, otatStore *rckstrvcache.Store, db *sql.DB, errCh chan<- error) {
-	handler := NewServer(cfg, regkeysStore, otatStore, db)
+func startServer(cfg ServerConfig, regkeysStore *rckstrvcache.Store, otatStore *rckstrvcache.Store, timeExtensionTokensStore *rckstrvcache.Store, db *sql.DB, blocklistMatcher *blocklists.Matcher, pushHub *push.Hub, errCh chan<- error) {
+	handler := NewServer(cfg, regkeysStore, otatStore, timeExtensionTokensStore, db, blocklistMatcher, pushHub)
 
 	err := http.ListenAndServe(fmt.Sprintf("%s:%d", cfg.ServerAddress, cfg.ServerPort), handler)
 	if err != nil {
@@ -50,6 +172,36 @@ func startServer(cfg ServerConfig, regkeysStore *rckstrvcache.Store, otatStore *
 	}
 }
 
+func startBlockPageServer(cfg ServerConfig, db *sql.DB, blocklistMatcher *blocklists.Matcher, errCh chan<- error) {
+	err := http.ListenAndServe(cfg.BlockPageServerAddress, NewBlockPageServer(cfg, db, blocklistMatcher))
+	if err != nil {
+		errCh <- err
+	}
+}
+
+func startDnsServer(cfg ServerConfig, db *sql.DB, blocklistMatcher *blocklists.Matcher, errCh chan<- error) (*dnsfilter.Server, error) {
+	filter := dnsfilter.NewFilter(cfg.DnsUpstream, cfg.DnsBlockPageIp)
+	server := dnsfilter.NewServer(filter, dnsfilter.NewDatabasePolicySource(db, blocklistMatcher), dnsfilter.NewDatabaseQueryLog(db))
+
+	err := server.Start(fmt.Sprintf("%s:%d", cfg.DnsServerAddress, cfg.DnsServerPort), errCh)
+	if err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
+
+func startProxyServer(cfg ServerConfig, db *sql.DB, blocklistMatcher *blocklists.Matcher, errCh chan<- error) (*proxy.Server, error) {
+	server := proxy.NewServer(dnsfilter.NewDatabasePolicySource(db, blocklistMatcher), dnsfilter.NewDatabaseQueryLog(db), proxy.NewDatabaseAuthoritySource(db))
+
+	err := server.Start(cfg.ProxyServerAddress, errCh)
+	if err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
+
 func readConfig() ServerConfig {
 	appUrl, exists, err := env.ParseValidUrlVarWithHttpOrHttpsProtocol("APP_URL")
 	if !exists {
@@ -114,15 +266,89 @@ func readConfig() ServerConfig {
 		log.Fatalf("env '%s' is required", "DATABASE_URL")
 	}
 
+	dnsServerAddress, exists := env.ParseStringVar("DNS_SERVER_ADDRESS")
+	if !exists {
+		log.Fatalf("env '%s' is required", "DNS_SERVER_ADDRESS")
+	}
+
+	dnsServerPort, exists, err := env.ParseUint16Var("DNS_SERVER_PORT")
+	if !exists {
+		log.Fatalf("env '%s' is required", "DNS_SERVER_PORT")
+	}
+
+	if err != nil {
+		log.Fatalf("env '%s' parsing error: %v", "DNS_SERVER_PORT", err)
+	}
+
+	dnsUpstream, exists, err := env.ParseHostPortVar("DNS_UPSTREAM")
+	if !exists {
+		log.Fatalf("env '%s' is required", "DNS_UPSTREAM")
+	}
+
+	if err != nil {
+		log.Fatalf("env '%s' parsing error: %v", "DNS_UPSTREAM", err)
+	}
+
+	// optional, children in block page mode get empty answers without it
+	dnsBlockPageIp, _, err := env.ParseIpVar("DNS_BLOCK_PAGE_IP")
+	if err != nil {
+		log.Fatalf("env '%s' parsing error: %v", "DNS_BLOCK_PAGE_IP", err)
+	}
+
+	// optional, the block page is not served without it
+	blockPageServerAddress, _, err := env.ParseHostPortVar("BLOCK_PAGE_SERVER_ADDRESS")
+	if err != nil {
+		log.Fatalf("env '%s' parsing error: %v", "BLOCK_PAGE_SERVER_ADDRESS", err)
+	}
+
+	// optional, the forward proxy is not started without it
+	proxyServerAddress, _, err := env.ParseHostPortVar("PROXY_SERVER_ADDRESS")
+	if err != nil {
+		log.Fatalf("env '%s' parsing error: %v", "PROXY_SERVER_ADDRESS", err)
+	}
+
+	// optional, parents are mostly in Poland
+	reportsTimezone, exists := env.ParseStringVar("REPORTS_TIMEZONE")
+	if !exists {
+		reportsTimezone = "Europe/Warsaw"
+	}
+
+	reportsLocation, err := time.LoadLocation(reportsTimezone)
+	if err != nil {
+		log.Fatalf("env '%s' parsing error: %v", "REPORTS_TIMEZONE", err)
+	}
+
+	// optional, agents send a heartbeat every minute
+	heartbeatTimeoutMinutes, exists, err := env.ParseIntVar("HEARTBEAT_TIMEOUT_MINUTES")
+	if err != nil {
+		log.Fatalf("env '%s' parsing error: %v", "HEARTBEAT_TIMEOUT_MINUTES", err)
+	}
+
+	if !exists {
+		heartbeatTimeoutMinutes = 15
+	}
+
+	if heartbeatTimeoutMinutes <= 0 {
+		log.Fatalf("env '%s' has to be a positive number of minutes", "HEARTBEAT_TIMEOUT_MINUTES")
+	}
+
 	cfg := ServerConfig{
-		AppUrl:                appUrlWithoutTrailingSlash,
-		ServerAddress:         serverAddress,
-		ServerPort:            serverPort,
-		EmailFromAddress:      emailFromAddress,
-		SmtpAddress:           smtpAddress,
-		SmtpPort:              smtpPort,
-		BearerTokenPrivateKey: bearerTokenPrivateKey,
-		DatabaseUrl:           databaseUrl,
+		AppUrl:                 appUrlWithoutTrailingSlash,
+		ServerAddress:          serverAddress,
+		ServerPort:             serverPort,
+		EmailFromAddress:       emailFromAddress,
+		SmtpAddress:            smtpAddress,
+		SmtpPort:               smtpPort,
+		BearerTokenPrivateKey:  bearerTokenPrivateKey,
+		DatabaseUrl:            databaseUrl,
+		DnsServerAddress:       dnsServerAddress,
+		DnsServerPort:          dnsServerPort,
+		DnsUpstream:            dnsUpstream,
+		DnsBlockPageIp:         dnsBlockPageIp,
+		BlockPageServerAddress: blockPageServerAddress,
+		ProxyServerAddress:     proxyServerAddress,
+		ReportsLocation:        reportsLocation,
+		HeartbeatTimeout:       time.Duration(heartbeatTimeoutMinutes) * time.Minute,
 	}
 
 	return cfg
@@ -157,6 +383,16 @@ func main() {
 		logFatalIfErr(store.Close())
 	}(otatStore)
 
+	// decision links in time extension emails stay valid for a day
+	timeExtensionTokensStore, timeExtensionTokensStoreErrCh, err := rckstrvcache.InitializeStore(time.Hour * 24)
+	if err != nil {
+		log.Fatalf("fatal error occured while trying to initialize time extension tokens store: %v", err)
+	}
+
+	defer func(store *rckstrvcache.Store) {
+		logFatalIfErr(store.Close())
+	}(timeExtensionTokensStore)
+
 	db, err := sql.Open("sqlite3", cfg.DatabaseUrl)
 	if err != nil {
 		log.Fatal(err)
@@ -166,25 +402,115 @@ func main() {
 		logFatalIfErr(db.Close())
 	}(db)
 
-	err = database.Migrate(db, map[string]string{
-		"0001_users": users.MigrationFile,
-	})
+	err = database.Migrate(db, migrations.All)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	blocklistMatcher := blocklists.NewMatcher()
+
+	err = blocklistMatcher.Load(db)
+	if err != nil {
+		log.Fatalf("failed to load blocklists: %v", err)
+	}
+
+	blocklistWatcherCtx, stopBlocklistWatcher := context.WithCancel(context.Background())
+	defer stopBlocklistWatcher()
+
+	// lists imported with the cli are picked up without restarting the server
+	go blocklistMatcher.WatchForChanges(blocklistWatcherCtx, db, time.Minute)
+
+	// devices reconnecting after a restart are told to resync, the history is kept only in memory
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	scheduledCommandsWatcherCtx, stopScheduledCommandsWatcher := context.WithCancel(context.Background())
+	defer stopScheduledCommandsWatcher()
+
+	go watchScheduledCommands(scheduledCommandsWatcherCtx, db, pushHub, 15*time.Second)
+
+	jobScheduler := scheduler.New()
+	jobScheduler.Add(newWeeklyDigestJob(&cfg, db))
+	jobScheduler.Add(scheduler.Job{
+		Name:       "activity compaction",
+		Schedule:   scheduler.Every(time.Hour),
+		RunOnStart: true,
+		Run: func(ctx context.Context, now time.Time) error {
+			return reports.CompactActivity(ctx, db, cfg.ReportsLocation, now)
+		},
+	})
+	jobScheduler.Add(scheduler.Job{
+		Name:     "device alerts",
+		Schedule: scheduler.Every(time.Minute),
+		Run: func(ctx context.Context, now time.Time) error {
+			return sendDeviceAlerts(ctx, &cfg, db, now)
+		},
+	})
+
+	webhookDispatcher := webhooks.NewDispatcher()
+	jobScheduler.Add(scheduler.Job{
+		Name:       "webhook deliveries",
+		Schedule:   scheduler.Every(15 * time.Second),
+		RunOnStart: true,
+		Run: func(ctx context.Context, now time.Time) error {
+			return webhookDispatcher.DeliverDue(ctx, db, now)
+		},
+	})
+
+	jobSchedulerCtx, stopJobScheduler := context.WithCancel(context.Background())
+	defer stopJobScheduler()
+
+	go jobScheduler.Run(jobSchedulerCtx)
+
 	httpServerErrCh := make(chan error)
 
-	go startServer(cfg, regkeysStore, otatStore, db, httpServerErrCh)
+	go startServer(cfg, regkeysStore, otatStore, timeExtensionTokensStore, db, blocklistMatcher, pushHub, httpServerErrCh)
+
+	blockPageServerErrCh := make(chan error)
+
+	if cfg.BlockPageServerAddress != "" {
+		go startBlockPageServer(cfg, db, blocklistMatcher, blockPageServerErrCh)
+	}
+
+	dnsServerErrCh := make(chan error)
+
+	dnsServer, err := startDnsServer(cfg, db, blocklistMatcher, dnsServerErrCh)
+	if err != nil {
+		log.Fatalf("failed to start dns server: %v", err)
+	}
+
+	defer func(dnsServer *dnsfilter.Server) {
+		logFatalIfErr(dnsServer.Shutdown())
+	}(dnsServer)
+
+	proxyServerErrCh := make(chan error)
+
+	if cfg.ProxyServerAddress != "" {
+		proxyServer, err := startProxyServer(cfg, db, blocklistMatcher, proxyServerErrCh)
+		if err != nil {
+			log.Fatalf("failed to start proxy server: %v", err)
+		}
+
+		defer func(proxyServer *proxy.Server) {
+			logFatalIfErr(proxyServer.Shutdown())
+		}(proxyServer)
+	}
 
 	for {
 		select {
 		case err = <-httpServerErrCh:
 			log.Fatalf("Error from http server: %v", err)
+		case err = <-blockPageServerErrCh:
+			log.Fatalf("Error from block page server: %v", err)
+		case err = <-dnsServerErrCh:
+			log.Fatalf("Error from dns server: %v", err)
+		case err = <-proxyServerErrCh:
+			log.Fatalf("Error from proxy server: %v", err)
 		case err = <-otatStoreErrCh:
 			log.Fatalf("Error from one time access token store: %v", err)
 		case err = <-regkeyErrCh:
 			log.Fatalf("Error from regkey store: %v", err)
+		case err = <-timeExtensionTokensStoreErrCh:
+			log.Fatalf("Error from time extension tokens store: %v", err)
 		default:
 			// nothing
 		}
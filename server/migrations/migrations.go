@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"domanscy.group/parental-controls/server/accessrequests"
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/apprules"
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/calendar"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/commands"
+	"domanscy.group/parental-controls/server/devices"
+	"domanscy.group/parental-controls/server/domainrules"
+	"domanscy.group/parental-controls/server/heartbeats"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/policytemplates"
+	"domanscy.group/parental-controls/server/proxyca"
+	"domanscy.group/parental-controls/server/reports"
+	"domanscy.group/parental-controls/server/rewards"
+	"domanscy.group/parental-controls/server/timeextensions"
+	"domanscy.group/parental-controls/server/users"
+	"domanscy.group/parental-controls/server/webhooks"
+)
+
+// All migrations of the server database, executed in order of their names by database.Migrate.
+var All = map[string]string{
+	"0001_users":                 users.MigrationFile,
+	"0002_households":            households.MigrationFile,
+	"0003_children":              children.MigrationFile,
+	"0004_devices":               devices.MigrationFile,
+	"0005_domain_rules":          domainrules.MigrationFile,
+	"0006_activity":              activity.MigrationFile,
+	"0007_children_safe_search":  children.SafeSearchMigrationFile,
+	"0008_blocklists":            blocklists.MigrationFile,
+	"0009_access_requests":       accessrequests.MigrationFile,
+	"0010_time_extensions":       timeextensions.MigrationFile,
+	"0011_commands":              commands.MigrationFile,
+	"0012_activity_ingestion":    activity.IngestionMigrationFile,
+	"0013_digest_deliveries":     reports.MigrationFile,
+	"0014_households_retention":  households.RetentionMigrationFile,
+	"0015_activity_rollups":      activity.RollupsMigrationFile,
+	"0016_app_rules":             apprules.MigrationFile,
+	"0017_children_screen_time":  children.ScreenTimeMigrationFile,
+	"0018_devices_heartbeat_key": devices.HeartbeatKeyMigrationFile,
+	"0019_heartbeats":            heartbeats.MigrationFile,
+	"0020_path_rules":            domainrules.PathRulesMigrationFile,
+	"0021_proxy_authorities":     proxyca.MigrationFile,
+	"0022_rewards":               rewards.MigrationFile,
+	"0023_children_birth_date":   children.BirthDateMigrationFile,
+	"0024_policy_templates":      policytemplates.MigrationFile,
+	"0025_calendar":              calendar.MigrationFile,
+	"0026_webhooks":              webhooks.MigrationFile,
+	"0027_users_language":        users.LanguageMigrationFile,
+	"0028_devices_doh_token":     devices.DohTokenMigrationFile,
+}
@@ -0,0 +1,240 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenApi    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	generator *generator
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lowercase http method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationId string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Security    []map[string][]string `json:"security"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Route describes a route of the server, the schemas of its bodies are generated from the Go types the handler
+// decodes and encodes, so the document can not drift from the handlers.
+type Route struct {
+	Method string
+	// Pattern is the chi pattern of the route, {name} parameters ending with "Id" are integers.
+	Pattern     string
+	OperationId string
+	Summary     string
+	Tag         string
+	// Security is the name of the security scheme of the route, empty for public routes.
+	Security string
+	Query    []Parameter
+	// Request is a value of the type of the json request body, nil when the route takes none.
+	Request   any
+	Responses []RouteResponse
+}
+
+type RouteResponse struct {
+	Status      int
+	Description string
+	// Body is a value of the type of the json response body, nil when the response has none.
+	Body any
+	// ContentType is set for bodies that are not json, they are described as strings. "*/*" stands for any type.
+	ContentType string
+	// PlainText describes a text/plain alternative of the json body, for clients not asking for json.
+	PlainText bool
+}
+
+func New(title string, version string, description string) *Document {
+	return &Document{
+		OpenApi: Version,
+		Info:    Info{Title: title, Version: version, Description: description},
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+		generator: newGenerator(),
+	}
+}
+
+func (document *Document) AddSecurityScheme(name string, scheme SecurityScheme) {
+	document.Components.SecuritySchemes[name] = &scheme
+}
+
+var patternParameter = regexp.MustCompile(`\{([^}]+)}`)
+
+// Path converts the chi pattern to the path template of the document, the wildcard of chi becomes the {path} parameter.
+func Path(pattern string) string {
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.TrimSuffix(pattern, "*") + "{path}"
+	}
+
+	return pattern
+}
+
+// Add describes the route, it panics when the route is described already or its types can not be described,
+// as both are mistakes in the list of routes.
+func (document *Document) Add(route Route) {
+	path := Path(route.Pattern)
+	method := strings.ToLower(route.Method)
+
+	item, ok := document.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		document.Paths[path] = item
+	}
+
+	if _, ok := (*item)[method]; ok {
+		panic(fmt.Sprintf("openapi: %s %s is described twice", route.Method, route.Pattern))
+	}
+
+	operation := &Operation{
+		OperationId: route.OperationId,
+		Summary:     route.Summary,
+		Security:    make([]map[string][]string, 0),
+		Responses:   make(map[string]*Response),
+	}
+
+	if route.Tag != "" {
+		operation.Tags = []string{route.Tag}
+	}
+
+	if route.Security != "" {
+		if _, ok := document.Components.SecuritySchemes[route.Security]; !ok {
+			panic(fmt.Sprintf("openapi: unknown security scheme %q of %s %s", route.Security, route.Method, route.Pattern))
+		}
+
+		operation.Security = append(operation.Security, map[string][]string{route.Security: {}})
+	}
+
+	for _, match := range patternParameter.FindAllStringSubmatch(path, -1) {
+		schema := &Schema{Type: "string"}
+		if strings.HasSuffix(match[1], "Id") {
+			schema = &Schema{Type: "integer", Minimum: floatPointer(1)}
+		}
+
+		operation.Parameters = append(operation.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: schema})
+	}
+
+	for _, parameter := range route.Query {
+		parameter.In = "query"
+		operation.Parameters = append(operation.Parameters, parameter)
+	}
+
+	if route.Request != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				"application/json": {Schema: document.schema(reflect.TypeOf(route.Request), false)},
+			},
+		}
+	}
+
+	for _, response := range route.Responses {
+		description := response.Description
+		if description == "" {
+			description = http.StatusText(response.Status)
+		}
+
+		described := &Response{Description: description}
+
+		if response.ContentType != "" {
+			described.Content = map[string]MediaType{response.ContentType: {Schema: &Schema{Type: "string"}}}
+		} else if response.Body != nil {
+			described.Content = map[string]MediaType{"application/json": {Schema: document.schema(reflect.TypeOf(response.Body), true)}}
+		}
+
+		if response.PlainText {
+			if described.Content == nil {
+				described.Content = make(map[string]MediaType)
+			}
+
+			described.Content["text/plain"] = MediaType{Schema: &Schema{Type: "string"}}
+		}
+
+		operation.Responses[strconv.Itoa(response.Status)] = described
+	}
+
+	(*item)[method] = operation
+}
+
+func (document *Document) schema(t reflect.Type, output bool) *Schema {
+	schema, err := document.generator.schema(t, output, document.Components.Schemas)
+	if err != nil {
+		panic(fmt.Sprintf("openapi: %v", err))
+	}
+
+	return schema
+}
+
+// Operation returns the operation of the route with the chi pattern, nil if it is not described.
+func (document *Document) Operation(method string, pattern string) *Operation {
+	item, ok := document.Paths[Path(pattern)]
+	if !ok {
+		return nil
+	}
+
+	return (*item)[strings.ToLower(method)]
+}
+
+func floatPointer(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testOwner struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type testPet struct {
+	Id        int        `json:"id"`
+	Name      string     `json:"name"`
+	Tags      []string   `json:"tags"`
+	Owner     *testOwner `json:"owner"`
+	BornAt    time.Time  `json:"bornAt"`
+	Nickname  string     `json:"nickname,omitempty"`
+	Vaccinate *time.Time `json:"vaccinate"`
+	internal  int
+}
+
+type testError struct {
+	Code string `json:"code"`
+}
+
+type testCreatePetRequestBody struct {
+	Name    string   `json:"name" validate:"required,length=1..50"`
+	Species string   `json:"species" validate:"oneof=cat dog"`
+	Tags    []string `json:"tags" validate:"length=..10"`
+	Vet     string   `json:"vet" validate:"email"`
+	FeedAt  string   `json:"feedAt" validate:"timeofday"`
+	Notes   string   `json:"notes"`
+}
+
+func newTestDocument() *Document {
+	document := New("Pets", "1", "")
+	document.AddSecurityScheme("bearerAuth", SecurityScheme{Type: "http", Scheme: "bearer"})
+
+	document.Add(Route{
+		Method:      http.MethodPost,
+		Pattern:     "/owners/{ownerId}/pets",
+		OperationId: "petsCreate",
+		Security:    "bearerAuth",
+		Request:     testCreatePetRequestBody{},
+		Responses: []RouteResponse{
+			{Status: http.StatusCreated, Body: testPet{}},
+			{Status: http.StatusBadRequest, ContentType: "text/plain"},
+			{Status: http.StatusUnprocessableEntity, Body: testError{}, PlainText: true},
+		},
+	})
+	document.Add(Route{
+		Method:      http.MethodGet,
+		Pattern:     "/files/*",
+		OperationId: "filesGet",
+		Responses:   []RouteResponse{{Status: http.StatusOK, ContentType: "*/*"}, {Status: http.StatusNoContent}},
+	})
+
+	return document
+}
+
+func TestDocumentAdd(t *testing.T) {
+	document := newTestDocument()
+
+	operation := document.Operation(http.MethodPost, "/owners/{ownerId}/pets")
+	if operation == nil {
+		t.Fatal("Expected the route to be described")
+	}
+
+	if len(operation.Parameters) != 1 || operation.Parameters[0].In != "path" || operation.Parameters[0].Schema.Type != "integer" {
+		t.Fatalf("Unexpected parameters: %+v", operation.Parameters)
+	}
+
+	if operation.Responses["400"].Description != "Bad Request" {
+		t.Fatalf("Expected the description of the status, got %q", operation.Responses["400"].Description)
+	}
+
+	if document.Operation(http.MethodGet, "/files/*") == nil || document.Paths["/files/{path}"] == nil {
+		t.Fatal("Expected the wildcard to be described as the path parameter")
+	}
+
+	pet := document.Components.Schemas["testPet"]
+	if pet == nil {
+		t.Fatalf("Expected the testPet component, got %v", reflect.ValueOf(document.Components.Schemas).MapKeys())
+	}
+
+	wantRequired := []string{"id", "name", "tags", "owner", "bornAt", "vaccinate"}
+	if !reflect.DeepEqual(pet.Required, wantRequired) {
+		t.Fatalf("Expected required %v, got %v", wantRequired, pet.Required)
+	}
+
+	if pet.Properties["bornAt"].Format != "date-time" || !pet.Properties["vaccinate"].Nullable {
+		t.Fatalf("Unexpected time properties: %+v %+v", pet.Properties["bornAt"], pet.Properties["vaccinate"])
+	}
+
+	if owner := pet.Properties["owner"]; !owner.Nullable || len(owner.AllOf) != 1 || owner.AllOf[0].Ref != componentsPrefix+"testOwner" {
+		t.Fatalf("Expected a nullable reference to the owner, got %+v", owner)
+	}
+
+	if _, ok := pet.Properties["internal"]; ok {
+		t.Fatal("Expected unexported fields to be left out")
+	}
+
+	input := document.Components.Schemas["testCreatePetRequestBodyInput"]
+	if input == nil || !reflect.DeepEqual(input.Required, []string{"name"}) {
+		t.Fatalf("Expected the request body with the required fields of its rules only, got %+v", input)
+	}
+
+	if name := input.Properties["name"]; *name.MinLength != 1 || *name.MaxLength != 50 {
+		t.Fatalf("Expected the length of the name, got %+v", name)
+	}
+
+	if tags := input.Properties["tags"]; tags.MaxLength != nil || tags.Items.MinLength != nil || *tags.Items.MaxLength != 10 {
+		t.Fatalf("Expected the length of the items of the tags, got %+v %+v", tags, tags.Items)
+	}
+
+	if !reflect.DeepEqual(input.Properties["species"].Enum, []string{"cat", "dog"}) || input.Properties["vet"].Format != "email" ||
+		input.Properties["feedAt"].Pattern != timeOfDayPattern {
+		t.Fatalf("Expected the rules of the fields, got %+v", input.Properties)
+	}
+
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(encoded), `"$ref":"#/components/schemas/testPet"`) {
+		t.Fatalf("Expected a reference to the component in %s", encoded)
+	}
+}
+
+func TestDocumentAddPanicsOnDuplicates(t *testing.T) {
+	document := newTestDocument()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected a panic")
+		}
+	}()
+
+	document.Add(Route{Method: http.MethodGet, Pattern: "/files/*", OperationId: "filesGetAgain"})
+}
+
+func TestDocumentValidateResponse(t *testing.T) {
+	document := newTestDocument()
+
+	cases := []struct {
+		name        string
+		method      string
+		pattern     string
+		status      int
+		contentType string
+		body        string
+		valid       bool
+	}{
+		{"valid body", http.MethodPost, "/owners/{ownerId}/pets", 201, "application/json",
+			`{"id": 1, "name": "Rex", "tags": ["dog"], "owner": null, "bornAt": "2020-01-02T03:04:05Z", "vaccinate": null}`, true},
+		{"optional field and nested object", http.MethodPost, "/owners/{ownerId}/pets", 201, "application/json; charset=utf-8",
+			`{"id": 1, "name": "Rex", "tags": [], "owner": {"id": 2, "name": "Ann"}, "bornAt": "2020-01-02T03:04:05Z", "vaccinate": "2021-01-02T03:04:05Z", "nickname": "R"}`, true},
+		{"missing field", http.MethodPost, "/owners/{ownerId}/pets", 201, "application/json",
+			`{"id": 1, "name": "Rex", "tags": [], "owner": null, "bornAt": "2020-01-02T03:04:05Z"}`, false},
+		{"null slice", http.MethodPost, "/owners/{ownerId}/pets", 201, "application/json",
+			`{"id": 1, "name": "Rex", "tags": null, "owner": null, "bornAt": "2020-01-02T03:04:05Z", "vaccinate": null}`, false},
+		{"unexpected field", http.MethodPost, "/owners/{ownerId}/pets", 201, "application/json",
+			`{"id": 1, "name": "Rex", "tags": [], "owner": null, "bornAt": "2020-01-02T03:04:05Z", "vaccinate": null, "age": 3}`, false},
+		{"fractional integer", http.MethodPost, "/owners/{ownerId}/pets", 201, "application/json",
+			`{"id": 1.5, "name": "Rex", "tags": [], "owner": null, "bornAt": "2020-01-02T03:04:05Z", "vaccinate": null}`, false},
+		{"invalid date-time", http.MethodPost, "/owners/{ownerId}/pets", 201, "application/json",
+			`{"id": 1, "name": "Rex", "tags": [], "owner": null, "bornAt": "yesterday", "vaccinate": null}`, false},
+		{"plain text error", http.MethodPost, "/owners/{ownerId}/pets", 400, "text/plain; charset=utf-8", "invalid name", true},
+		{"json error with a plain text alternative", http.MethodPost, "/owners/{ownerId}/pets", 422, "application/json", `{"code": "invalid_name"}`, true},
+		{"plain text alternative of a json error", http.MethodPost, "/owners/{ownerId}/pets", 422, "text/plain; charset=utf-8", "invalid name", true},
+		{"undescribed status", http.MethodPost, "/owners/{ownerId}/pets", 409, "text/plain", "conflict", false},
+		{"undescribed content type", http.MethodPost, "/owners/{ownerId}/pets", 400, "text/html", "<p>invalid</p>", false},
+		{"any content type", http.MethodGet, "/files/*", 200, "image/png", "png", true},
+		{"body of a response without content", http.MethodGet, "/files/*", 204, "", "body", false},
+		{"undescribed route", http.MethodGet, "/owners/{ownerId}/pets", 200, "application/json", "[]", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := document.ValidateResponse(c.method, c.pattern, c.status, c.contentType, []byte(c.body))
+			if c.valid && err != nil {
+				t.Fatalf("Expected a valid response, got %v", err)
+			}
+
+			if !c.valid && err == nil {
+				t.Fatal("Expected an invalid response")
+			}
+		})
+	}
+
+	err := document.ValidateResponse(http.MethodDelete, "/files/*", 204, "", nil)
+	if !errors.Is(err, ErrNotDescribed) {
+		t.Fatalf("Expected ErrNotDescribed, got %v", err)
+	}
+}
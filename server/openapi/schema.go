@@ -0,0 +1,272 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"domanscy.group/parental-controls/server/validation"
+)
+
+const componentsPrefix = "#/components/schemas/"
+
+// Schema is the subset of the schema object of OpenAPI 3.0 the generator uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+type component struct {
+	t      reflect.Type
+	output bool
+}
+
+// generator names the components of struct types, types of different packages with the same name get the name
+// of the package as a prefix. A type used in request and response bodies is described twice, as fields of
+// responses are always present and those of requests may be left out.
+type generator struct {
+	names map[component]string
+	// types holds the type of every name given, to tell types of different packages apart
+	types map[string]reflect.Type
+}
+
+func newGenerator() *generator {
+	return &generator{names: make(map[component]string), types: make(map[string]reflect.Type)}
+}
+
+func (g *generator) componentName(key component) string {
+	if name, ok := g.names[key]; ok {
+		return name
+	}
+
+	name := key.t.Name()
+	if other, ok := g.types[name]; ok && other != key.t {
+		packagePath := strings.Split(key.t.PkgPath(), "/")
+		packageName := packagePath[len(packagePath)-1]
+		name = strings.ToUpper(packageName[:1]) + packageName[1:] + name
+	}
+
+	g.types[name] = key.t
+
+	if !key.output {
+		name += "Input"
+	}
+
+	g.names[key] = name
+
+	return name
+}
+
+// schema describes the type the way encoding/json encodes it, output types require every field without omitempty.
+func (g *generator) schema(t reflect.Type, output bool, components map[string]*Schema) (*Schema, error) {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t == rawMessageType:
+		return &Schema{}, nil
+	case t.Kind() != reflect.Pointer && t.Implements(jsonMarshalerType):
+		return &Schema{}, nil
+	case t.Kind() != reflect.Pointer && t.Implements(textMarshalerType):
+		return &Schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}, nil
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Pointer:
+		elem, err := g.schema(t.Elem(), output, components)
+		if err != nil {
+			return nil, err
+		}
+
+		if elem.Ref != "" {
+			return &Schema{AllOf: []*Schema{elem}, Nullable: true}, nil
+		}
+
+		elem.Nullable = true
+
+		return elem, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}, nil
+		}
+
+		items, err := g.schema(t.Elem(), output, components)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := g.schema(t.Elem(), output, components)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, output, components)
+		}
+
+		key := component{t: t, output: output}
+		_, described := g.names[key]
+		name := g.componentName(key)
+
+		if !described {
+			// registered before the fields are described, so recursive types end in a reference
+			components[name] = &Schema{}
+
+			schema, err := g.structSchema(t, output, components)
+			if err != nil {
+				return nil, err
+			}
+
+			components[name] = schema
+		}
+
+		return &Schema{Ref: componentsPrefix + name}, nil
+	}
+
+	return nil, fmt.Errorf("type %s of kind %s can not be described", t, t.Kind())
+}
+
+func (g *generator) structSchema(t reflect.Type, output bool, components map[string]*Schema) (*Schema, error) {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	err := g.addFields(schema, t, output, components)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+func (g *generator) addFields(schema *Schema, t reflect.Type, output bool, components map[string]*Schema) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				err := g.addFields(schema, embedded, output, components)
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := g.schema(field.Type, output, components)
+		if err != nil {
+			return fmt.Errorf("field %s of %s: %w", field.Name, t, err)
+		}
+
+		schema.Properties[name] = property
+
+		if output && !strings.Contains(","+options+",", ",omitempty,") {
+			schema.Required = append(schema.Required, name)
+		}
+
+		if !output {
+			err = addRules(schema, name, property, field.Tag.Get("validate"))
+			if err != nil {
+				return fmt.Errorf("field %s of %s: %w", field.Name, t, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// timeOfDayPattern matches the validation.TimeOfDayLayout values.
+const timeOfDayPattern = "^([01][0-9]|2[0-3]):[0-5][0-9]$"
+
+// addRules describes the rules of the validate tag of a request field, see the validation package. The rules of a
+// slice constrain its items.
+func addRules(schema *Schema, name string, property *Schema, tag string) error {
+	rules, err := validation.ParseRules(tag)
+	if err != nil {
+		return err
+	}
+
+	constrained := property
+	if property.Type == "array" && property.Items != nil {
+		constrained = property.Items
+	}
+
+	for _, rule := range rules {
+		switch rule.Name {
+		case validation.RuleRequired:
+			schema.Required = append(schema.Required, name)
+		case validation.RuleEmail:
+			constrained.Format = "email"
+		case validation.RuleUrl:
+			constrained.Format = "uri"
+		case validation.RuleLength:
+			if rule.Min > 0 {
+				constrained.MinLength = &rule.Min
+			}
+
+			if rule.Max >= 0 {
+				constrained.MaxLength = &rule.Max
+			}
+		case validation.RuleOneOf:
+			constrained.Enum = rule.Values
+		case validation.RuleTimeOfDay:
+			constrained.Pattern = timeOfDayPattern
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotDescribed = errors.New("not described by the document")
+
+// ValidateResponse checks a response of the route with the chi pattern against the document: the status must be
+// described, with the content type, and json bodies must match the schema.
+func (document *Document) ValidateResponse(method string, pattern string, status int, contentType string, body []byte) error {
+	operation := document.Operation(method, pattern)
+	if operation == nil {
+		return fmt.Errorf("%s %s: %w", method, pattern, ErrNotDescribed)
+	}
+
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("%s %s: status %d: %w", method, pattern, status, ErrNotDescribed)
+	}
+
+	if len(response.Content) == 0 {
+		if len(body) != 0 {
+			return fmt.Errorf("%s %s: status %d: body of %d bytes %w", method, pattern, status, len(body), ErrNotDescribed)
+		}
+
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%s %s: status %d: invalid content type %q: %w", method, pattern, status, contentType, err)
+	}
+
+	content, ok := response.Content[mediaType]
+	if !ok {
+		content, ok = response.Content["*/*"]
+	}
+
+	if !ok {
+		return fmt.Errorf("%s %s: status %d: content type %s %w", method, pattern, status, mediaType, ErrNotDescribed)
+	}
+
+	if mediaType != "application/json" {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value any
+	err = decoder.Decode(&value)
+	if err != nil {
+		return fmt.Errorf("%s %s: status %d: invalid json: %w", method, pattern, status, err)
+	}
+
+	err = document.Validate(content.Schema, value)
+	if err != nil {
+		return fmt.Errorf("%s %s: status %d: %w", method, pattern, status, err)
+	}
+
+	return nil
+}
+
+// Validate checks the value decoded from json, with numbers as json.Number, against the schema. It is stricter than
+// OpenAPI and rejects properties the schema does not describe, a handler encoding another type than the described
+// one is a mistake too.
+func (document *Document) Validate(schema *Schema, value any) error {
+	return document.validate(schema, value, "$")
+}
+
+func (document *Document) validate(schema *Schema, value any, at string) error {
+	if schema.Ref != "" {
+		referenced, ok := document.Components.Schemas[strings.TrimPrefix(schema.Ref, componentsPrefix)]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", at, schema.Ref)
+		}
+
+		return document.validate(referenced, value, at)
+	}
+
+	if value == nil {
+		if schema.Nullable || (schema.Type == "" && len(schema.AllOf) == 0) {
+			return nil
+		}
+
+		return fmt.Errorf("%s: null is not nullable", at)
+	}
+
+	for _, part := range schema.AllOf {
+		err := document.validate(part, value, at)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", at, value)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: expected a number, got %T", at, value)
+		}
+
+		if schema.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				return fmt.Errorf("%s: expected an integer, got %s", at, number)
+			}
+		}
+
+		if schema.Minimum != nil {
+			if parsed, _ := number.Float64(); parsed < *schema.Minimum {
+				return fmt.Errorf("%s: %s is less than %v", at, number, *schema.Minimum)
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string, got %T", at, value)
+		}
+
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, text)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", at, value)
+		}
+
+		for i, item := range items {
+			err := document.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i))
+			if err != nil {
+				return err
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", at, value)
+		}
+
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+
+		for name, property := range object {
+			propertySchema, ok := schema.Properties[name]
+			if !ok {
+				propertySchema = schema.AdditionalProperties
+			}
+
+			if propertySchema == nil {
+				return fmt.Errorf("%s: unexpected property %q", at, name)
+			}
+
+			err := document.validate(propertySchema, property, at+"."+name)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unknown type %s", at, schema.Type)
+	}
+
+	return nil
+}
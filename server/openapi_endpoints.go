@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"domanscy.group/parental-controls/server/openapi"
+	"domanscy.group/parental-controls/server/timetokens"
+)
+
+const openApiBearerAuth = "bearerAuth"
+const openApiDeviceAuth = "deviceAuth"
+
+// errorResponses describes the errors of a route, as ErrorResponse or plain text, every route may fail with 500.
+func errorResponses(statuses ...int) []openapi.RouteResponse {
+	responses := make([]openapi.RouteResponse, 0, len(statuses)+1)
+
+	for _, status := range append(statuses, http.StatusInternalServerError) {
+		responses = append(responses, openapi.RouteResponse{Status: status, Body: ErrorResponse{}, PlainText: true})
+	}
+
+	return responses
+}
+
+func jsonResponse(status int, body any, errorStatuses ...int) []openapi.RouteResponse {
+	return append([]openapi.RouteResponse{{Status: status, Body: body}}, errorResponses(errorStatuses...)...)
+}
+
+func noContentResponse(errorStatuses ...int) []openapi.RouteResponse {
+	return append([]openapi.RouteResponse{{Status: http.StatusNoContent}}, errorResponses(errorStatuses...)...)
+}
+
+func contentResponse(status int, contentType string, errorStatuses ...int) []openapi.RouteResponse {
+	return append([]openapi.RouteResponse{{Status: status, ContentType: contentType}}, errorResponses(errorStatuses...)...)
+}
+
+func htmlPageResponses(errorStatuses ...int) []openapi.RouteResponse {
+	return append([]openapi.RouteResponse{
+		{Status: http.StatusOK, ContentType: "text/html"},
+		{Status: http.StatusConflict, Description: "Already decided", ContentType: "text/html"},
+	}, errorResponses(errorStatuses...)...)
+}
+
+func queryParameter(name string, schemaType string, required bool) openapi.Parameter {
+	return openapi.Parameter{Name: name, Required: required, Schema: &openapi.Schema{Type: schemaType}}
+}
+
+// openApiRoutes lists every route of NewServer, the test of the document fails when one of them is missing here.
+var openApiRoutes = []openapi.Route{
+	{Method: http.MethodGet, Pattern: "/openapi.json", OperationId: "openApiGet", Tag: "meta", Summary: "This document",
+		Responses: jsonResponse(http.StatusOK, map[string]any{})},
+
+	{Method: http.MethodPost, Pattern: "/login", OperationId: "authLogin", Tag: "auth", Summary: "Sends a login link to the parent",
+		Request: LoginRequestBody{}, Responses: noContentResponse(http.StatusBadRequest)},
+	{Method: http.MethodPost, Pattern: "/register", OperationId: "authStartRegistrationProcess", Tag: "auth", Summary: "Sends a registration link to the parent",
+		Request: RegistrationRequestBody{}, Responses: noContentResponse(http.StatusBadRequest)},
+	{Method: http.MethodGet, Pattern: "/finish_registration/{regkey}", OperationId: "authFinishRegistrationProcess", Tag: "auth", Summary: "Creates the account and redirects to the callback with a one-time access token",
+		Responses: append([]openapi.RouteResponse{{Status: http.StatusTemporaryRedirect}}, errorResponses(http.StatusBadRequest)...)},
+	{Method: http.MethodPost, Pattern: "/get_bearer_from_otat/{otat}", OperationId: "authGetBearerTokenFromOtat", Tag: "auth", Summary: "Exchanges a one-time access token for a bearer token",
+		Responses: contentResponse(http.StatusOK, "text/plain", http.StatusBadRequest)},
+
+	{Method: http.MethodGet, Pattern: "/assets/*", OperationId: "assetsGet", Tag: "pages", Summary: "Static files of the html pages",
+		Responses: contentResponse(http.StatusOK, "*/*", http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/access_requests/{token}/approve", OperationId: "accessRequestsApprove", Tag: "pages", Summary: "Approves an access request from the link in the email",
+		Query: []openapi.Parameter{queryParameter("minutes", "integer", true)}, Responses: htmlPageResponses(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/access_requests/{token}/deny", OperationId: "accessRequestsDeny", Tag: "pages", Summary: "Denies an access request from the link in the email",
+		Responses: htmlPageResponses(http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/time_extension_requests/decide/{token}", OperationId: "timeExtensionRequestsDecide", Tag: "pages", Summary: "Decides about a time extension request from the link in the email",
+		Query:     []openapi.Parameter{queryParameter("decision", "string", true), queryParameter("minutes", "integer", false)},
+		Responses: htmlPageResponses(http.StatusBadRequest, http.StatusNotFound)},
+
+	{Method: http.MethodGet, Pattern: "/dns-query/{token}", OperationId: "dnsQueryGet", Tag: "dns", Summary: "DNS over HTTPS (RFC 8484)",
+		Query:     []openapi.Parameter{queryParameter("dns", "string", true)},
+		Responses: contentResponse(http.StatusOK, dnsMessageContentType, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/dns-query/{token}", OperationId: "dnsQueryPost", Tag: "dns", Summary: "DNS over HTTPS (RFC 8484)",
+		Responses: contentResponse(http.StatusOK, dnsMessageContentType, http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType)},
+	{Method: http.MethodGet, Pattern: "/calendar_feeds/{token}.ics", OperationId: "calendarFeedGet", Tag: "calendar", Summary: "The calendar of the household for calendar apps",
+		Responses: contentResponse(http.StatusOK, "text/calendar", http.StatusNotFound)},
+
+	{Method: http.MethodPut, Pattern: "/me/language", OperationId: "usersUpdateLanguage", Tag: "users", Summary: "Sets the language of the emails of the parent", Security: openApiBearerAuth,
+		Request: UpdateLanguageRequestBody{}, Responses: noContentResponse(http.StatusBadRequest)},
+	{Method: http.MethodPost, Pattern: "/children/{childId}/devices", OperationId: "devicesCreate", Tag: "devices", Security: openApiBearerAuth,
+		Request: CreateDeviceRequestBody{}, Responses: jsonResponse(http.StatusCreated, DeviceResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPut, Pattern: "/children/{childId}/safe_search", OperationId: "childrenUpdateSafeSearch", Tag: "children", Security: openApiBearerAuth,
+		Request: UpdateSafeSearchRequestBody{}, Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPut, Pattern: "/children/{childId}/blocked_categories", OperationId: "childrenUpdateBlockedCategories", Tag: "children", Security: openApiBearerAuth,
+		Request: UpdateBlockedCategoriesRequestBody{}, Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/children/{childId}/time_extension_requests", OperationId: "childrenTimeExtensionRequestsList", Tag: "time extensions", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []TimeExtensionRequestResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/children/{childId}/commands", OperationId: "childrenCommandsCreate", Tag: "commands", Security: openApiBearerAuth,
+		Request: CreateCommandRequestBody{}, Responses: jsonResponse(http.StatusCreated, []CommandResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/children/{childId}/commands", OperationId: "childrenCommandsList", Tag: "commands", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []CommandResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/children/{childId}/reports/daily", OperationId: "childrenReportsDaily", Tag: "reports", Security: openApiBearerAuth,
+		Query:     []openapi.Parameter{queryParameter("days", "integer", false), queryParameter("from", "string", false)},
+		Responses: jsonResponse(http.StatusOK, []DailyReportResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/households", OperationId: "householdsCreate", Tag: "households", Security: openApiBearerAuth,
+		Request: CreateHouseholdRequestBody{}, Responses: jsonResponse(http.StatusCreated, HouseholdResponse{}, http.StatusBadRequest)},
+	{Method: http.MethodGet, Pattern: "/households", OperationId: "householdsList", Tag: "households", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []HouseholdResponse{})},
+	{Method: http.MethodPut, Pattern: "/households/{householdId}/activity_retention", OperationId: "householdsUpdateActivityRetention", Tag: "households", Security: openApiBearerAuth,
+		Request: UpdateActivityRetentionRequestBody{}, Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/households/{householdId}/children", OperationId: "householdsChildrenCreate", Tag: "children", Security: openApiBearerAuth,
+		Request: CreateChildRequestBody{}, Responses: jsonResponse(http.StatusCreated, ChildResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/households/{householdId}/children", OperationId: "householdsChildrenList", Tag: "children", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []ChildResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/households/{householdId}/policy_templates", OperationId: "householdsPolicyTemplatesList", Tag: "policy templates", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []PolicyTemplateResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/households/{householdId}/policy_templates", OperationId: "householdsPolicyTemplatesCreate", Tag: "policy templates", Security: openApiBearerAuth,
+		Request: CreatePolicyTemplateRequestBody{}, Responses: jsonResponse(http.StatusCreated, PolicyTemplateResponse{}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodDelete, Pattern: "/households/{householdId}/policy_templates/{templateKey}", OperationId: "householdsPolicyTemplatesDelete", Tag: "policy templates", Security: openApiBearerAuth,
+		Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/households/{householdId}/policy_templates/{templateKey}/apply", OperationId: "householdsPolicyTemplatesApply", Tag: "policy templates", Security: openApiBearerAuth,
+		Request: ApplyPolicyTemplateRequestBody{}, Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/households/{householdId}/calendar", OperationId: "householdsCalendarList", Tag: "calendar", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []CalendarEntryResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/households/{householdId}/calendar", OperationId: "householdsCalendarCreate", Tag: "calendar", Security: openApiBearerAuth,
+		Request: CreateCalendarEntryRequestBody{}, Responses: jsonResponse(http.StatusCreated, CalendarEntryResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodDelete, Pattern: "/households/{householdId}/calendar/{entryId}", OperationId: "householdsCalendarDelete", Tag: "calendar", Security: openApiBearerAuth,
+		Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/households/{householdId}/calendar/import", OperationId: "householdsCalendarImport", Tag: "calendar", Security: openApiBearerAuth,
+		Request: ImportCalendarRequestBody{}, Responses: jsonResponse(http.StatusOK, ImportCalendarResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/households/{householdId}/calendar.ics", OperationId: "householdsCalendarExport", Tag: "calendar", Security: openApiBearerAuth,
+		Responses: contentResponse(http.StatusOK, "text/calendar", http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/households/{householdId}/calendar/feed", OperationId: "householdsCalendarFeedRotate", Tag: "calendar", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, CalendarFeedResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/households/{householdId}/webhooks", OperationId: "householdsWebhooksList", Tag: "webhooks", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []WebhookResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/households/{householdId}/webhooks", OperationId: "householdsWebhooksCreate", Tag: "webhooks", Security: openApiBearerAuth,
+		Request: CreateWebhookRequestBody{}, Responses: jsonResponse(http.StatusCreated, WebhookResponse{}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodDelete, Pattern: "/households/{householdId}/webhooks/{webhookId}", OperationId: "householdsWebhooksDelete", Tag: "webhooks", Security: openApiBearerAuth,
+		Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/households/{householdId}/webhooks/{webhookId}/deliveries", OperationId: "householdsWebhooksDeliveriesList", Tag: "webhooks", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []WebhookDeliveryResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/households/{householdId}/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", OperationId: "householdsWebhooksDeliveriesRedeliver", Tag: "webhooks", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusAccepted, WebhookDeliveryResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPut, Pattern: "/children/{childId}/birth_date", OperationId: "childrenUpdateBirthDate", Tag: "children", Security: openApiBearerAuth,
+		Request: UpdateBirthDateRequestBody{}, Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/children/{childId}/app_rules", OperationId: "childrenAppRulesCreate", Tag: "app rules", Security: openApiBearerAuth,
+		Request: CreateAppRuleRequestBody{}, Responses: jsonResponse(http.StatusCreated, []AppRuleResponse{}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodGet, Pattern: "/children/{childId}/app_rules", OperationId: "childrenAppRulesList", Tag: "app rules", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []AppRuleResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodDelete, Pattern: "/children/{childId}/app_rules/{ruleId}", OperationId: "childrenAppRulesDelete", Tag: "app rules", Security: openApiBearerAuth,
+		Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPut, Pattern: "/children/{childId}/screen_time", OperationId: "childrenUpdateScreenTime", Tag: "screen time", Security: openApiBearerAuth,
+		Request: UpdateScreenTimeRequestBody{}, Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/children/{childId}/domain_rules", OperationId: "childrenDomainRulesCreate", Tag: "domain rules", Security: openApiBearerAuth,
+		Request: CreateDomainRuleRequestBody{}, Responses: jsonResponse(http.StatusCreated, []DomainRuleResponse{}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodGet, Pattern: "/children/{childId}/domain_rules", OperationId: "childrenDomainRulesList", Tag: "domain rules", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []DomainRuleResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodDelete, Pattern: "/children/{childId}/domain_rules/{ruleId}", OperationId: "childrenDomainRulesDelete", Tag: "domain rules", Security: openApiBearerAuth,
+		Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPut, Pattern: "/children/{childId}/block_mode", OperationId: "childrenUpdateBlockMode", Tag: "children", Security: openApiBearerAuth,
+		Request: UpdateBlockModeRequestBody{}, Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/children/{childId}/path_rules", OperationId: "childrenPathRulesCreate", Tag: "path rules", Security: openApiBearerAuth,
+		Request: CreatePathRuleRequestBody{}, Responses: jsonResponse(http.StatusCreated, []PathRuleResponse{}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodGet, Pattern: "/children/{childId}/path_rules", OperationId: "childrenPathRulesList", Tag: "path rules", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []PathRuleResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodDelete, Pattern: "/children/{childId}/path_rules/{ruleId}", OperationId: "childrenPathRulesDelete", Tag: "path rules", Security: openApiBearerAuth,
+		Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/children/{childId}/chores", OperationId: "childrenChoresCreate", Tag: "rewards", Security: openApiBearerAuth,
+		Request: CreateChoreRequestBody{}, Responses: jsonResponse(http.StatusCreated, ChoreResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/children/{childId}/chores", OperationId: "childrenChoresList", Tag: "rewards", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []ChoreResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodDelete, Pattern: "/children/{childId}/chores/{choreId}", OperationId: "childrenChoresDelete", Tag: "rewards", Security: openApiBearerAuth,
+		Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/children/{childId}/chore_completions", OperationId: "childrenChoreCompletionsList", Tag: "rewards", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, []ChoreCompletionResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/children/{childId}/chore_completions/{completionId}/decision", OperationId: "childrenChoreCompletionsDecide", Tag: "rewards", Security: openApiBearerAuth,
+		Request: DecideChoreCompletionRequestBody{}, Responses: jsonResponse(http.StatusOK, ChoreCompletionResponse{}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodGet, Pattern: "/children/{childId}/points", OperationId: "childrenPointsGet", Tag: "rewards", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, PointsResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/children/{childId}/points/adjustments", OperationId: "childrenPointsAdjustmentsCreate", Tag: "rewards", Security: openApiBearerAuth,
+		Request: CreatePointsAdjustmentRequestBody{}, Responses: jsonResponse(http.StatusCreated, PointsResponse{}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodPut, Pattern: "/children/{childId}/exchange_rate", OperationId: "childrenUpdateExchangeRate", Tag: "rewards", Security: openApiBearerAuth,
+		Request: UpdateExchangeRateRequestBody{}, Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/devices/{deviceId}/health", OperationId: "devicesHealth", Tag: "devices", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, DeviceHealthResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Pattern: "/devices/{deviceId}/heartbeat_key", OperationId: "devicesHeartbeatKeyRotate", Tag: "devices", Security: openApiBearerAuth,
+		Responses: jsonResponse(http.StatusOK, HeartbeatKeyResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPut, Pattern: "/devices/{deviceId}/ip_address", OperationId: "devicesUpdateIpAddress", Tag: "devices", Security: openApiBearerAuth,
+		Request: UpdateIpAddressRequestBody{}, Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+
+	{Method: http.MethodPost, Pattern: "/device/time_extension_requests", OperationId: "deviceTimeExtensionRequestsCreate", Tag: "agent", Security: openApiDeviceAuth,
+		Request: CreateTimeExtensionRequestRequestBody{}, Responses: jsonResponse(http.StatusCreated, TimeExtensionRequestResponse{}, http.StatusBadRequest, http.StatusConflict)},
+	{Method: http.MethodGet, Pattern: "/device/time_extension_requests/{requestId}", OperationId: "deviceTimeExtensionRequestsGet", Tag: "agent", Security: openApiDeviceAuth,
+		Responses: jsonResponse(http.StatusOK, TimeExtensionRequestResponse{}, http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Pattern: "/device/events", OperationId: "deviceEvents", Tag: "agent", Security: openApiDeviceAuth, Summary: "Server-sent events of the device",
+		Responses: contentResponse(http.StatusOK, "text/event-stream")},
+	{Method: http.MethodGet, Pattern: "/device/commands", OperationId: "deviceCommandsList", Tag: "agent", Security: openApiDeviceAuth,
+		Responses: jsonResponse(http.StatusOK, []CommandResponse{})},
+	{Method: http.MethodPost, Pattern: "/device/commands/{commandId}/ack", OperationId: "deviceCommandsAcknowledge", Tag: "agent", Security: openApiDeviceAuth,
+		Responses: noContentResponse(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodPost, Pattern: "/devices/{deviceId}/events", OperationId: "devicesEventsIngest", Tag: "agent", Security: openApiDeviceAuth,
+		Request: IngestEventsRequestBody{}, Responses: jsonResponse(http.StatusOK, IngestEventsResponse{}, http.StatusBadRequest, http.StatusNotFound, http.StatusTooManyRequests)},
+	{Method: http.MethodGet, Pattern: "/device/app_rules", OperationId: "deviceAppRulesList", Tag: "agent", Security: openApiDeviceAuth,
+		Responses: jsonResponse(http.StatusOK, []AppRuleResponse{})},
+	{Method: http.MethodGet, Pattern: "/device/screen_time", OperationId: "deviceScreenTime", Tag: "agent", Security: openApiDeviceAuth,
+		Responses: jsonResponse(http.StatusOK, ScreenTimeResponse{})},
+	{Method: http.MethodGet, Pattern: "/device/chores", OperationId: "deviceChoresList", Tag: "agent", Security: openApiDeviceAuth,
+		Responses: jsonResponse(http.StatusOK, []DeviceChoreResponse{})},
+	{Method: http.MethodPost, Pattern: "/device/chores/{choreId}/completions", OperationId: "deviceChoreCompletionsCreate", Tag: "agent", Security: openApiDeviceAuth,
+		Responses: jsonResponse(http.StatusCreated, ChoreCompletionResponse{}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodGet, Pattern: "/device/points", OperationId: "devicePointsGet", Tag: "agent", Security: openApiDeviceAuth,
+		Responses: jsonResponse(http.StatusOK, PointsResponse{})},
+	{Method: http.MethodPost, Pattern: "/device/points/redemptions", OperationId: "devicePointsRedemptionsCreate", Tag: "agent", Security: openApiDeviceAuth,
+		Request: CreatePointsRedemptionRequestBody{}, Responses: jsonResponse(http.StatusCreated, PointsResponse{}, http.StatusBadRequest, http.StatusConflict)},
+	{Method: http.MethodPost, Pattern: "/device/heartbeats", OperationId: "deviceHeartbeatsCreate", Tag: "agent", Security: openApiDeviceAuth, Summary: "Signed with the heartbeat key of the device",
+		Request: HeartbeatRequestBody{}, Responses: jsonResponse(http.StatusOK, HeartbeatResponse{}, http.StatusBadRequest, http.StatusConflict)},
+	{Method: http.MethodPost, Pattern: "/device/time_tokens", OperationId: "deviceTimeTokensCreate", Tag: "agent", Security: openApiDeviceAuth,
+		Request: CreateTimeTokenRequestBody{}, Responses: jsonResponse(http.StatusOK, timetokens.Token{}, http.StatusBadRequest)},
+}
+
+// newOpenApiDocument describes the routes of NewServer, the block page server is not part of the api.
+func newOpenApiDocument() *openapi.Document {
+	document := openapi.New("Parental controls", "1", "The api of the parental controls server, for the dashboard of parents and the agents on devices of children. Errors are plain text, or an ErrorResponse with a code for clients sending \"Accept: application/json\".")
+
+	document.AddSecurityScheme(openApiBearerAuth, openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "Token of the parent from /get_bearer_from_otat.",
+	})
+	document.AddSecurityScheme(openApiDeviceAuth, openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        "Authorization",
+		Description: `Token of the device from POST /children/{childId}/devices, sent as "Device <token>".`,
+	})
+
+	for _, route := range openApiRoutes {
+		if route.Security != "" {
+			route.Responses = append(route.Responses, errorResponses(http.StatusUnauthorized)[0])
+		}
+
+		// request bodies have a size limit, see decodeJsonRequestBody
+		if route.Request != nil {
+			route.Responses = append(route.Responses, errorResponses(http.StatusRequestEntityTooLarge)[0])
+		}
+
+		document.Add(route)
+	}
+
+	return document
+}
+
+var openApiDocument = sync.OnceValue(newOpenApiDocument)
+
+func HttpOpenApi(_ *ServerConfig) http.HandlerFunc {
+	encoded := sync.OnceValues(func() ([]byte, error) {
+		return json.Marshal(openApiDocument())
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := encoded()
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to encode the openapi document: %v", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(body)
+		if err != nil {
+			log.Println("Error writing response:", err)
+		}
+	}
+}
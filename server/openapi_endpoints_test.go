@@ -0,0 +1,243 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/push"
+	"github.com/go-chi/chi"
+)
+
+func TestOpenApiDescribesEveryRoute(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	server := NewServer(*testingCfg, nil, nil, nil, db, nil, push.NewHub(push.DefaultHistorySize))
+	document := openApiDocument()
+
+	served := make(map[string]bool)
+
+	err := chi.Walk(server.(chi.Routes), func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		served[method+" "+route] = true
+
+		if document.Operation(method, route) == nil {
+			t.Errorf("%s %s is not described in the openapi document", method, route)
+		}
+
+		return nil
+	})
+	doTFatalIfErr(t, err)
+
+	for _, route := range openApiRoutes {
+		if !served[route.Method+" "+route.Pattern] {
+			t.Errorf("%s %s is described in the openapi document but not served", route.Method, route.Pattern)
+		}
+	}
+}
+
+// TestOpenApiResponses sends requests through the whole server and checks every response against the document.
+func TestOpenApiResponses(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	server := NewServer(*testingCfg, nil, nil, nil, db, nil, push.NewHub(push.DefaultHistorySize))
+	routes := server.(chi.Routes)
+	document := openApiDocument()
+
+	parent := bearerHeaderForUser(t, family.userId)
+	strangerParent := bearerHeaderForUser(t, stranger.userId)
+	device := "Device " + family.deviceToken
+
+	// accept is the Accept header of the requests, errors are plain text without it
+	accept := ""
+
+	send := func(t *testing.T, authorization string, method string, path string, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		request := httptest.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(body))
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		if recorder.Code >= http.StatusInternalServerError {
+			t.Fatalf("%s %s: got %d, response body: %s", method, path, recorder.Code, recorder.Body.String())
+		}
+
+		rctx := chi.NewRouteContext()
+		if !routes.Match(rctx, method, request.URL.Path) {
+			t.Fatalf("%s %s: no route matches", method, path)
+		}
+
+		err := document.ValidateResponse(method, rctx.RoutePattern(), recorder.Code, recorder.Header().Get("Content-Type"), recorder.Body.Bytes())
+		if err != nil {
+			t.Fatalf("%v, response body: %s", err, recorder.Body.String())
+		}
+
+		return recorder
+	}
+
+	sendAndDecode := func(t *testing.T, authorization string, method string, path string, body string, wantStatus int, v any) {
+		t.Helper()
+
+		recorder := send(t, authorization, method, path, body)
+		if recorder.Code != wantStatus {
+			t.Fatalf("%s %s: got %d, want %d, response body: %s", method, path, recorder.Code, wantStatus, recorder.Body.String())
+		}
+
+		if v != nil {
+			doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), v))
+		}
+	}
+
+	childPath := fmt.Sprintf("/children/%d", family.childId)
+	householdPath := fmt.Sprintf("/households/%d", family.householdId)
+
+	t.Run("serves the document", func(t *testing.T) {
+		var described map[string]any
+		sendAndDecode(t, "", http.MethodGet, "/openapi.json", "", http.StatusOK, &described)
+
+		if described["openapi"] != "3.0.3" {
+			t.Fatalf("Unexpected document: %v", described["openapi"])
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, accept = range []string{"", "application/json"} {
+			send(t, "", http.MethodGet, childPath+"/commands", "")
+			send(t, strangerParent, http.MethodGet, childPath+"/commands", "")
+			send(t, parent, http.MethodGet, "/children/abc/commands", "")
+			send(t, parent, http.MethodPost, householdPath+"/children", "not json")
+			send(t, parent, http.MethodPost, householdPath+"/children", `{"name": 1}`)
+			send(t, "Device invalid", http.MethodGet, "/device/commands", "")
+			send(t, device, http.MethodPost, fmt.Sprintf("/devices/%d/events", family.deviceId), `{"events": [{"seq": 1, "type": "unknown"}]}`)
+			send(t, "", http.MethodGet, "/calendar_feeds/invalid.ics", "")
+			send(t, "", http.MethodGet, "/access_requests/invalid/deny", "")
+		}
+
+		accept = ""
+	})
+
+	t.Run("parent routes", func(t *testing.T) {
+		sendAndDecode(t, parent, http.MethodPost, householdPath+"/children", `{"name": "Ada", "birthDate": "2015-03-01"}`, http.StatusCreated, nil)
+		sendAndDecode(t, parent, http.MethodGet, householdPath+"/children", "", http.StatusOK, nil)
+		sendAndDecode(t, parent, http.MethodPut, householdPath+"/activity_retention", `{"days": 30}`, http.StatusNoContent, nil)
+
+		sendAndDecode(t, parent, http.MethodPost, childPath+"/devices", `{"name": "Tablet"}`, http.StatusCreated, nil)
+		sendAndDecode(t, parent, http.MethodGet, fmt.Sprintf("/devices/%d/health", family.deviceId), "", http.StatusOK, nil)
+		sendAndDecode(t, parent, http.MethodPut, childPath+"/safe_search", `{"safeSearch": true, "youtubeRestrictedMode": "strict"}`, http.StatusNoContent, nil)
+		sendAndDecode(t, parent, http.MethodPut, childPath+"/blocked_categories", `{"categories": []}`, http.StatusNoContent, nil)
+		sendAndDecode(t, parent, http.MethodPut, childPath+"/birth_date", `{"birthDate": "2016-04-02"}`, http.StatusNoContent, nil)
+		sendAndDecode(t, parent, http.MethodPut, childPath+"/screen_time", `{"dailyMinutes": 120}`, http.StatusNoContent, nil)
+		sendAndDecode(t, parent, http.MethodPut, childPath+"/exchange_rate", `{"points": 10, "minutes": 5, "dailyLimitMinutes": 60}`, http.StatusNoContent, nil)
+		sendAndDecode(t, parent, http.MethodGet, childPath+"/time_extension_requests", "", http.StatusOK, nil)
+		sendAndDecode(t, parent, http.MethodGet, childPath+"/reports/daily?days=3", "", http.StatusOK, nil)
+
+		sendAndDecode(t, parent, http.MethodPost, childPath+"/commands", `{"type": "show_message", "message": "Dinner"}`, http.StatusCreated, nil)
+		sendAndDecode(t, parent, http.MethodGet, childPath+"/commands", "", http.StatusOK, nil)
+
+		var appRules []AppRuleResponse
+		sendAndDecode(t, parent, http.MethodPost, childPath+"/app_rules", `{"matchType": "name", "pattern": "game.exe", "action": "block"}`, http.StatusCreated, &appRules)
+		send(t, parent, http.MethodPost, childPath+"/app_rules", `{"matchType": "name", "pattern": "game.exe", "action": "block"}`)
+		sendAndDecode(t, parent, http.MethodGet, childPath+"/app_rules", "", http.StatusOK, nil)
+		sendAndDecode(t, parent, http.MethodDelete, fmt.Sprintf("%s/app_rules/%d", childPath, appRules[0].Id), "", http.StatusNoContent, nil)
+
+		var pathRules []PathRuleResponse
+		sendAndDecode(t, parent, http.MethodPost, childPath+"/path_rules", `{"domain": "example.com", "path": "/games", "action": "block"}`, http.StatusCreated, &pathRules)
+		sendAndDecode(t, parent, http.MethodGet, childPath+"/path_rules", "", http.StatusOK, nil)
+		sendAndDecode(t, parent, http.MethodDelete, fmt.Sprintf("%s/path_rules/%d", childPath, pathRules[0].Id), "", http.StatusNoContent, nil)
+
+		var template PolicyTemplateResponse
+		sendAndDecode(t, parent, http.MethodGet, householdPath+"/policy_templates", "", http.StatusOK, nil)
+		sendAndDecode(t, parent, http.MethodPost, householdPath+"/policy_templates", `{"name": "School", "dailyScreenTimeMinutes": 90, "blockedCategories": [], "youtubeRestrictedMode": "moderate", "blockMode": "block_page"}`, http.StatusCreated, &template)
+		sendAndDecode(t, parent, http.MethodPost, fmt.Sprintf("%s/policy_templates/%s/apply", householdPath, template.Key), fmt.Sprintf(`{"childIds": [%d]}`, family.childId), http.StatusNoContent, nil)
+		sendAndDecode(t, parent, http.MethodDelete, fmt.Sprintf("%s/policy_templates/%s", householdPath, template.Key), "", http.StatusNoContent, nil)
+
+		var entry CalendarEntryResponse
+		sendAndDecode(t, parent, http.MethodPost, householdPath+"/calendar", `{"name": "Holidays", "startDate": "2026-07-01", "endDate": "2026-07-31", "dailyScreenTimeMinutes": 180}`, http.StatusCreated, &entry)
+		sendAndDecode(t, parent, http.MethodGet, householdPath+"/calendar", "", http.StatusOK, nil)
+		sendAndDecode(t, parent, http.MethodGet, householdPath+"/calendar.ics", "", http.StatusOK, nil)
+
+		var feed CalendarFeedResponse
+		sendAndDecode(t, parent, http.MethodPost, householdPath+"/calendar/feed", "", http.StatusOK, &feed)
+		feedUrl, err := url.Parse(feed.Url)
+		doTFatalIfErr(t, err)
+		sendAndDecode(t, "", http.MethodGet, feedUrl.Path, "", http.StatusOK, nil)
+
+		sendAndDecode(t, parent, http.MethodDelete, fmt.Sprintf("%s/calendar/%d", householdPath, entry.Id), "", http.StatusNoContent, nil)
+
+		var webhook WebhookResponse
+		sendAndDecode(t, parent, http.MethodPost, householdPath+"/webhooks", `{"url": "https://example.com/hook"}`, http.StatusCreated, &webhook)
+		sendAndDecode(t, parent, http.MethodGet, householdPath+"/webhooks", "", http.StatusOK, nil)
+		sendAndDecode(t, parent, http.MethodGet, fmt.Sprintf("%s/webhooks/%d/deliveries", householdPath, webhook.Id), "", http.StatusOK, nil)
+		sendAndDecode(t, parent, http.MethodDelete, fmt.Sprintf("%s/webhooks/%d", householdPath, webhook.Id), "", http.StatusNoContent, nil)
+	})
+
+	t.Run("rewards", func(t *testing.T) {
+		var chore ChoreResponse
+		sendAndDecode(t, parent, http.MethodPost, childPath+"/chores", `{"title": "Dishes", "points": 20, "recurrence": "daily"}`, http.StatusCreated, &chore)
+		sendAndDecode(t, parent, http.MethodGet, childPath+"/chores", "", http.StatusOK, nil)
+		sendAndDecode(t, device, http.MethodGet, "/device/chores", "", http.StatusOK, nil)
+
+		var completion ChoreCompletionResponse
+		sendAndDecode(t, device, http.MethodPost, fmt.Sprintf("/device/chores/%d/completions", chore.Id), "", http.StatusCreated, &completion)
+		send(t, device, http.MethodPost, fmt.Sprintf("/device/chores/%d/completions", chore.Id), "")
+		sendAndDecode(t, parent, http.MethodGet, childPath+"/chore_completions", "", http.StatusOK, nil)
+		sendAndDecode(t, parent, http.MethodPost, fmt.Sprintf("%s/chore_completions/%d/decision", childPath, completion.Id), `{"decision": "approve"}`, http.StatusOK, nil)
+
+		sendAndDecode(t, parent, http.MethodPost, childPath+"/points/adjustments", `{"points": 5, "description": "Bonus"}`, http.StatusCreated, nil)
+		sendAndDecode(t, parent, http.MethodGet, childPath+"/points", "", http.StatusOK, nil)
+		sendAndDecode(t, device, http.MethodGet, "/device/points", "", http.StatusOK, nil)
+		sendAndDecode(t, device, http.MethodPost, "/device/points/redemptions", `{"minutes": 5}`, http.StatusCreated, nil)
+		send(t, device, http.MethodPost, "/device/points/redemptions", `{"minutes": 500}`)
+
+		sendAndDecode(t, parent, http.MethodDelete, fmt.Sprintf("%s/chores/%d", childPath, chore.Id), "", http.StatusNoContent, nil)
+	})
+
+	t.Run("device routes", func(t *testing.T) {
+		var commands []CommandResponse
+		sendAndDecode(t, device, http.MethodGet, "/device/commands", "", http.StatusOK, &commands)
+		if len(commands) == 0 {
+			t.Fatal("Expected the command created by the parent")
+		}
+
+		sendAndDecode(t, device, http.MethodPost, fmt.Sprintf("/device/commands/%d/ack", commands[0].Id), "", http.StatusNoContent, nil)
+		send(t, device, http.MethodPost, fmt.Sprintf("/device/commands/%d/ack", commands[0].Id), "")
+
+		sendAndDecode(t, device, http.MethodGet, "/device/app_rules", "", http.StatusOK, nil)
+		sendAndDecode(t, device, http.MethodGet, "/device/screen_time", "", http.StatusOK, nil)
+		sendAndDecode(t, device, http.MethodGet, "/device/time_extension_requests/1", "", http.StatusNotFound, nil)
+
+		events := `{"events": [{"seq": 1, "type": "screen_on", "occurredAt": "` + time.Now().Format(time.RFC3339) + `", "payload": {}}]}`
+		sendAndDecode(t, device, http.MethodPost, fmt.Sprintf("/devices/%d/events", family.deviceId), events, http.StatusOK, nil)
+
+		send(t, device, http.MethodPost, "/device/heartbeats", "not json")
+		send(t, device, http.MethodPost, "/device/time_tokens", `{"nonce": "abc"}`)
+	})
+}
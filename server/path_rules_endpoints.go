@@ -0,0 +1,198 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/domainrules"
+	"github.com/go-chi/chi"
+)
+
+var ErrInvalidPathRuleId = errors.New("invalid path rule id")
+var ErrPathRuleNotFound = errors.New("path rule not found")
+
+type PathRuleResponse struct {
+	Id        int       `json:"id"`
+	ChildId   int       `json:"childId"`
+	Domain    string    `json:"domain"`
+	Path      string    `json:"path"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newPathRuleResponses(rules []domainrules.PathRule) []PathRuleResponse {
+	response := make([]PathRuleResponse, 0, len(rules))
+
+	for _, rule := range rules {
+		response = append(response, PathRuleResponse{
+			Id:        rule.Id,
+			ChildId:   rule.ChildId,
+			Domain:    rule.Domain,
+			Path:      rule.Path,
+			Action:    string(rule.Action),
+			CreatedAt: rule.CreatedAt,
+		})
+	}
+
+	return response
+}
+
+type CreatePathRuleRequestBody struct {
+	Domain string `json:"domain" validate:"required"`
+	Path   string `json:"path" validate:"required"`
+	Action string `json:"action" validate:"required,oneof=allow block"`
+}
+
+// HttpChildrenPathRulesCreate adds a rule the forward proxy enforces, the dns filter can not see paths. The proxy reads
+// the rules of every request, so devices are not notified.
+func HttpChildrenPathRulesCreate(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreatePathRuleRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		_, err = domainrules.CreatePathRule(tx, child.Id, requestBody.Domain, requestBody.Path, domainrules.Action(requestBody.Action))
+		if errors.Is(err, domainrules.ErrInvalidDomain) || errors.Is(err, domainrules.ErrInvalidPath) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if errors.Is(err, domainrules.ErrRuleForThisPathAlreadyExists) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create path rule: %v", err)
+			return
+		}
+
+		rules, err := domainrules.FindAllPathRulesByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find path rules: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusCreated, newPathRuleResponses(rules))
+	}
+}
+
+func HttpChildrenPathRulesList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		rules, err := domainrules.FindAllPathRulesByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find path rules: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, newPathRuleResponses(rules))
+	}
+}
+
+func HttpChildrenPathRulesDelete(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		ruleId, err := strconv.Atoi(chi.URLParam(r, "ruleId"))
+		if err != nil || ruleId <= 0 {
+			respondWith400(w, r, ErrInvalidPathRuleId)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		deleted, err := domainrules.DeletePathRule(tx, child.Id, ruleId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to delete path rule: %v", err)
+			return
+		}
+
+		if !deleted {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrPathRuleNotFound)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestHttpPathRules(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/children/{childId}/path_rules", HttpChildrenPathRulesCreate(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/children/{childId}/path_rules", HttpChildrenPathRulesList(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Delete("/children/{childId}/path_rules/{ruleId}", HttpChildrenPathRulesDelete(testingCfg, db))
+
+	sendParentRequest := func(userId int, method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	rulesPath := fmt.Sprintf("/children/%d/path_rules", family.childId)
+
+	t.Run("creates rules", func(t *testing.T) {
+		recorder := sendParentRequest(family.userId, http.MethodPost, rulesPath, `{"domain": "YouTube.com", "path": "/@channel/", "action": "block"}`)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		var response []PathRuleResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if len(response) != 1 || response[0].Domain != "youtube.com" || response[0].Path != "/@channel" || response[0].Action != "block" {
+			t.Fatalf("Unexpected response: %+v", response)
+		}
+	})
+
+	t.Run("rejects invalid and duplicate rules", func(t *testing.T) {
+		invalid := []string{
+			`{"domain": "youtube..com", "path": "/@channel", "action": "block"}`,
+			`{"domain": "youtube.com", "path": "@channel", "action": "block"}`,
+			`{"domain": "youtube.com", "path": "/", "action": "block"}`,
+			`{"domain": "youtube.com", "path": "/watch?v=1", "action": "block"}`,
+			`{"domain": "youtube.com", "path": "/@channel", "action": "limit"}`,
+		}
+
+		for _, body := range invalid {
+			recorder := sendParentRequest(family.userId, http.MethodPost, rulesPath, body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+
+		recorder := sendParentRequest(family.userId, http.MethodPost, rulesPath, `{"domain": "youtube.com", "path": "/@channel", "action": "allow"}`)
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("does not show or delete rules of other parents children", func(t *testing.T) {
+		recorder := sendParentRequest(stranger.userId, http.MethodGet, rulesPath, "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(stranger.userId, http.MethodDelete, rulesPath+"/1", "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("deletes rules", func(t *testing.T) {
+		recorder := sendParentRequest(family.userId, http.MethodDelete, rulesPath+"/1", "")
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodDelete, rulesPath+"/1", "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, rulesPath, "")
+		if recorder.Code != http.StatusOK || strings.TrimSpace(recorder.Body.String()) != "[]" {
+			t.Fatalf("Got %d with %s, want empty list", recorder.Code, recorder.Body.String())
+		}
+	})
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/policytemplates"
+	"domanscy.group/parental-controls/server/push"
+	"github.com/go-chi/chi"
+)
+
+var ErrBuiltinPolicyTemplate = errors.New("built-in policy templates can not be deleted")
+var ErrPolicyTemplateNotFound = errors.New("policy template not found")
+var ErrNoChildrenToApplyTo = errors.New("at least one child is required")
+
+type PolicyTemplateResponse struct {
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	Builtin bool   `json:"builtin"`
+	// MinAge and MaxAge are null for templates saved by the household.
+	MinAge                 *int       `json:"minAge"`
+	MaxAge                 *int       `json:"maxAge"`
+	DailyScreenTimeMinutes int        `json:"dailyScreenTimeMinutes"`
+	BlockedCategories      []string   `json:"blockedCategories"`
+	SafeSearch             bool       `json:"safeSearch"`
+	YoutubeRestrictedMode  string     `json:"youtubeRestrictedMode"`
+	BlockMode              string     `json:"blockMode"`
+	CreatedAt              *time.Time `json:"createdAt"`
+}
+
+func newPolicyTemplateResponse(template *policytemplates.Template, language i18n.Language) PolicyTemplateResponse {
+	response := PolicyTemplateResponse{
+		Key:                    template.Key,
+		Name:                   template.NameIn(language),
+		Builtin:                template.IsBuiltin(),
+		DailyScreenTimeMinutes: template.Policy.DailyScreenTimeMinutes,
+		BlockedCategories:      make([]string, 0, len(template.Policy.BlockedCategories)),
+		SafeSearch:             template.Policy.SafeSearch,
+		YoutubeRestrictedMode:  string(template.Policy.YoutubeRestrictedMode),
+		BlockMode:              string(template.Policy.BlockMode),
+	}
+
+	if template.IsBuiltin() {
+		minAge, maxAge := template.MinAge, template.MaxAge
+		response.MinAge = &minAge
+		response.MaxAge = &maxAge
+	} else {
+		createdAt := template.CreatedAt
+		response.CreatedAt = &createdAt
+	}
+
+	for _, category := range template.Policy.BlockedCategories {
+		response.BlockedCategories = append(response.BlockedCategories, string(category))
+	}
+
+	return response
+}
+
+// HttpHouseholdsPolicyTemplatesList returns the built-in templates followed by the ones saved by the household.
+func HttpHouseholdsPolicyTemplatesList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		templates, err := policytemplates.FindAllByHouseholdId(tx, household.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find policy templates: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := make([]PolicyTemplateResponse, 0, len(policytemplates.Builtins)+len(templates))
+
+		for i := range policytemplates.Builtins {
+			response = append(response, newPolicyTemplateResponse(&policytemplates.Builtins[i], requestLanguage(r)))
+		}
+
+		for i := range templates {
+			response = append(response, newPolicyTemplateResponse(&templates[i], requestLanguage(r)))
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
+
+type CreatePolicyTemplateRequestBody struct {
+	Name                   string   `json:"name" validate:"required,length=..50"`
+	DailyScreenTimeMinutes int      `json:"dailyScreenTimeMinutes"`
+	BlockedCategories      []string `json:"blockedCategories" validate:"oneof=adult gambling social_media gaming malware ads"`
+	SafeSearch             bool     `json:"safeSearch"`
+	YoutubeRestrictedMode  string   `json:"youtubeRestrictedMode" validate:"required,oneof=off moderate strict"`
+	BlockMode              string   `json:"blockMode" validate:"required,oneof=nxdomain block_page"`
+}
+
+func HttpHouseholdsPolicyTemplatesCreate(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreatePolicyTemplateRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		policy := policytemplates.Policy{
+			DailyScreenTimeMinutes: requestBody.DailyScreenTimeMinutes,
+			BlockedCategories:      make([]blocklists.Category, 0, len(requestBody.BlockedCategories)),
+			SafeSearch:             requestBody.SafeSearch,
+			YoutubeRestrictedMode:  children.YoutubeRestrictedMode(requestBody.YoutubeRestrictedMode),
+			BlockMode:              children.BlockMode(requestBody.BlockMode),
+		}
+
+		for _, category := range requestBody.BlockedCategories {
+			policy.BlockedCategories = append(policy.BlockedCategories, blocklists.Category(category))
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		templateId, err := policytemplates.Create(tx, household.Id, requestBody.Name, policy)
+		if errors.Is(err, children.ErrInvalidDailyScreenTime) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if errors.Is(err, policytemplates.ErrTemplateWithThisNameAlreadyExists) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create policy template: %v", err)
+			return
+		}
+
+		template, err := policytemplates.FindOneById(tx, templateId)
+		if err != nil || template == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find created policy template: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusCreated, newPolicyTemplateResponse(template, requestLanguage(r)))
+	}
+}
+
+func HttpHouseholdsPolicyTemplatesDelete(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		template, err := policytemplates.FindOneByKey(tx, household.Id, chi.URLParam(r, "templateKey"))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find policy template: %v", err)
+			return
+		}
+
+		if template != nil && template.IsBuiltin() {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, ErrBuiltinPolicyTemplate)
+			return
+		}
+
+		deleted := false
+
+		if template != nil {
+			deleted, err = policytemplates.Delete(tx, household.Id, template.Id)
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to delete policy template: %v", err)
+				return
+			}
+		}
+
+		if !deleted {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrPolicyTemplateNotFound)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+type ApplyPolicyTemplateRequestBody struct {
+	ChildIds []int `json:"childIds"`
+}
+
+// HttpHouseholdsPolicyTemplatesApply replaces the settings of the children with the template, applying the built-in
+// template a child is offered accepts the upgrade.
+func HttpHouseholdsPolicyTemplatesApply(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[ApplyPolicyTemplateRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		if len(requestBody.ChildIds) == 0 {
+			respondWith400(w, r, ErrNoChildrenToApplyTo)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		template, err := policytemplates.FindOneByKey(tx, household.Id, chi.URLParam(r, "templateKey"))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find policy template: %v", err)
+			return
+		}
+
+		if template == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrPolicyTemplateNotFound)
+			return
+		}
+
+		for _, childId := range requestBody.ChildIds {
+			child, err := children.FindOneById(tx, childId)
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to find child: %v", err)
+				return
+			}
+
+			if child == nil || child.HouseholdId != household.Id {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith404(w, r, ErrChildNotFound)
+				return
+			}
+
+			err = policytemplates.Apply(tx, child.Id, template)
+			if err != nil {
+				err = littlehelpers.IfErrJoin(err, tx.Rollback())
+				respondWith500(w, r, nil)
+				log.Printf("error occured while trying to apply policy template: %v", err)
+				return
+			}
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		for _, childId := range requestBody.ChildIds {
+			publishEvent(pushHub, childId, 0, push.EventTypePolicyChanged, PolicyChangedEvent{Setting: "policy_template"})
+		}
+
+		w.WriteHeader(204)
+	}
+}
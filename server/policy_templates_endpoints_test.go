@@ -0,0 +1,234 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/push"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpPolicyTemplates(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/households/{householdId}/children", HttpHouseholdsChildrenCreate(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/households/{householdId}/children", HttpHouseholdsChildrenList(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/households/{householdId}/policy_templates", HttpHouseholdsPolicyTemplatesList(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/households/{householdId}/policy_templates", HttpHouseholdsPolicyTemplatesCreate(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Delete("/households/{householdId}/policy_templates/{templateKey}", HttpHouseholdsPolicyTemplatesDelete(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/households/{householdId}/policy_templates/{templateKey}/apply", HttpHouseholdsPolicyTemplatesApply(testingCfg, pushHub, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Put("/children/{childId}/birth_date", HttpChildrenUpdateBirthDate(testingCfg, db))
+
+	sendParentRequest := func(userId int, method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	householdPath := fmt.Sprintf("/households/%d", family.householdId)
+	today := time.Now().In(testingCfg.ReportsLocation)
+
+	// born a year and a day after the 7th birthday, so the child is 6 until tomorrow
+	birthDate := time.Date(today.Year()-7, today.Month(), today.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1).Format(time.DateOnly)
+
+	var child ChildResponse
+
+	t.Run("applies the built-in template for the age of new children", func(t *testing.T) {
+		invalid := []string{
+			`{"name": "", "birthDate": "2015-01-01"}`,
+			`{"name": "Ewa", "birthDate": "01.01.2015"}`,
+			fmt.Sprintf(`{"name": "Ewa", "birthDate": "%d-01-01"}`, today.Year()+1),
+		}
+
+		for _, body := range invalid {
+			recorder := sendParentRequest(family.userId, http.MethodPost, householdPath+"/children", body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+
+		recorder := sendParentRequest(stranger.userId, http.MethodPost, householdPath+"/children", `{"name": "Ewa"}`)
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, householdPath+"/children", fmt.Sprintf(`{"name": "Ewa", "birthDate": "%s"}`, birthDate))
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &child))
+
+		if *child.BirthDate != birthDate || *child.Age != 6 || child.PolicyTemplate != "under_7" || child.TemplateUpgrade != nil {
+			t.Fatalf("Unexpected response: %+v", child)
+		}
+
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		model, err := children.FindOneById(tx, child.Id)
+		doTFatalIfErr(t, err)
+
+		categories, err := blocklists.FindBlockedCategoriesByChildId(tx, child.Id)
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		if model.DailyScreenTimeMinutes != 60 || model.YoutubeRestrictedMode != children.YoutubeRestrictedModeStrict || len(categories) != 6 {
+			t.Fatalf("Unexpected child: %+v with %v", model, categories)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, householdPath+"/children", `{"name": "Adam junior"}`)
+		if recorder.Code != http.StatusCreated || !strings.Contains(recorder.Body.String(), `"policyTemplate":""`) {
+			t.Fatalf("Got %d %s, want a child without a template", recorder.Code, recorder.Body.String())
+		}
+	})
+
+	t.Run("offers the upgrade when the child grows into the next bracket", func(t *testing.T) {
+		recorder := sendParentRequest(family.userId, http.MethodPut, fmt.Sprintf("/children/%d/birth_date", child.Id), fmt.Sprintf(`{"birthDate": "%d-01-01"}`, today.Year()-8))
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, householdPath+"/children", "")
+
+		var response []ChildResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if len(response) != 3 || response[1].TemplateUpgrade == nil || response[1].TemplateUpgrade.Key != "7_12" || response[1].TemplateUpgrade.Name != "7–12 lat" {
+			t.Fatalf("Unexpected response: %+v", response)
+		}
+
+		subscription, _ := pushHub.Subscribe(child.Id, 0, 0)
+		defer subscription.Close()
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, householdPath+"/policy_templates/7_12/apply", fmt.Sprintf(`{"childIds": [%d]}`, child.Id))
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		event := <-subscription.Events()
+		if event.Type != push.EventTypePolicyChanged || string(event.Data) != `{"setting":"policy_template"}` {
+			t.Fatalf("Unexpected event: %+v", event)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, householdPath+"/children", "")
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if response[1].PolicyTemplate != "7_12" || response[1].TemplateUpgrade != nil {
+			t.Fatalf("Unexpected response: %+v", response[1])
+		}
+	})
+
+	t.Run("saves household templates and applies them to several children", func(t *testing.T) {
+		recorder := sendParentRequest(family.userId, http.MethodPost, householdPath+"/policy_templates", `{"name": "Wakacje", "dailyScreenTimeMinutes": 240, "blockedCategories": ["news"], "youtubeRestrictedMode": "off", "blockMode": "nxdomain"}`)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		body := `{"name": "Wakacje", "dailyScreenTimeMinutes": 240, "blockedCategories": ["adult"], "safeSearch": true, "youtubeRestrictedMode": "moderate", "blockMode": "nxdomain"}`
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, householdPath+"/policy_templates", body)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		var template PolicyTemplateResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &template))
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, householdPath+"/policy_templates", body)
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusConflict)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, householdPath+"/policy_templates", "")
+
+		var templates []PolicyTemplateResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &templates))
+
+		if len(templates) != 4 || !templates[0].Builtin || templates[3].Key != template.Key || templates[3].MinAge != nil {
+			t.Fatalf("Unexpected response: %+v", templates)
+		}
+
+		applyPath := householdPath + "/policy_templates/" + template.Key + "/apply"
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, applyPath, fmt.Sprintf(`{"childIds": [%d, %d]}`, family.childId, stranger.childId))
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d with a child of another household, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(stranger.userId, http.MethodPost, fmt.Sprintf("/households/%d/policy_templates/%s/apply", stranger.householdId, template.Key), fmt.Sprintf(`{"childIds": [%d]}`, stranger.childId))
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d with a template of another household, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, applyPath, fmt.Sprintf(`{"childIds": [%d, %d]}`, family.childId, child.Id))
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		for _, childId := range []int{family.childId, child.Id} {
+			model, err := children.FindOneById(tx, childId)
+			doTFatalIfErr(t, err)
+
+			if model.PolicyTemplate != template.Key || model.DailyScreenTimeMinutes != 240 || model.BlockMode != children.BlockModeNxdomain {
+				t.Errorf("Unexpected child: %+v", model)
+			}
+		}
+
+		doTFatalIfErr(t, tx.Commit())
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, householdPath+"/children", "")
+		if strings.Contains(recorder.Body.String(), `"templateUpgrade":{`) {
+			t.Fatalf("Got %s, want no upgrades offered over a household template", recorder.Body.String())
+		}
+	})
+
+	t.Run("deletes only household templates", func(t *testing.T) {
+		recorder := sendParentRequest(family.userId, http.MethodDelete, householdPath+"/policy_templates/teen", "")
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		recorder = sendParentRequest(stranger.userId, http.MethodDelete, householdPath+"/policy_templates/custom_1", "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodDelete, householdPath+"/policy_templates/custom_1", "")
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodDelete, householdPath+"/policy_templates/custom_1", "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package policytemplates
+
+import "domanscy.group/parental-controls/client/i18n"
+
+// builtinNames holds the names of the built-in templates by their keys.
+var builtinNames = i18n.Catalog{
+	i18n.LanguagePolish: {
+		"under_7": "Poniżej 7 lat",
+		"7_12":    "7–12 lat",
+		"teen":    "Nastolatek",
+	},
+	i18n.LanguageEnglish: {
+		"under_7": "Under 7",
+		"7_12":    "7–12 years",
+		"teen":    "Teenager",
+	},
+}
+
+// NameIn returns the name of the template in the language, templates saved by a household keep the name they were
+// given.
+func (template *Template) NameIn(language i18n.Language) string {
+	if !template.IsBuiltin() {
+		return template.Name
+	}
+
+	return builtinNames.Text(language, template.Key)
+}
@@ -0,0 +1,13 @@
+CREATE TABLE policy_templates (
+    id INTEGER PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    daily_screen_time_minutes INTEGER NOT NULL,
+    -- comma separated blocklist categories
+    blocked_categories VARCHAR NOT NULL,
+    safe_search BOOLEAN NOT NULL,
+    youtube_restricted_mode VARCHAR NOT NULL,
+    block_mode VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (household_id, name)
+);
@@ -0,0 +1,177 @@
+package policytemplates
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+)
+
+// MaxNameLength is counted in characters, not bytes.
+const MaxNameLength = 50
+
+var ErrInvalidName = fmt.Errorf("template name can not be empty or longer than %d characters", MaxNameLength)
+var ErrTemplateWithThisNameAlreadyExists = errors.New("template with this name already exists")
+
+//go:embed migration.sql
+var MigrationFile string
+
+const selectColumns = "id, household_id, name, daily_screen_time_minutes, blocked_categories, safe_search, youtube_restricted_mode, block_mode, created_at"
+
+func scanTemplate(row interface{ Scan(dest ...any) error }) (*Template, error) {
+	template := &Template{}
+
+	var blockedCategories string
+
+	err := row.Scan(
+		&template.Id,
+		&template.HouseholdId,
+		&template.Name,
+		&template.Policy.DailyScreenTimeMinutes,
+		&blockedCategories,
+		&template.Policy.SafeSearch,
+		&template.Policy.YoutubeRestrictedMode,
+		&template.Policy.BlockMode,
+		&template.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	template.Key = customKeyPrefix + strconv.Itoa(template.Id)
+	template.Policy.BlockedCategories = make([]blocklists.Category, 0)
+
+	for _, category := range strings.Split(blockedCategories, ",") {
+		if category != "" {
+			template.Policy.BlockedCategories = append(template.Policy.BlockedCategories, blocklists.Category(category))
+		}
+	}
+
+	return template, nil
+}
+
+// Validate checks the policy the same way the endpoints changing a single setting do.
+func (policy *Policy) Validate() error {
+	if policy.DailyScreenTimeMinutes < 0 || policy.DailyScreenTimeMinutes > children.MaxDailyScreenTimeMinutes {
+		return children.ErrInvalidDailyScreenTime
+	}
+
+	for _, category := range policy.BlockedCategories {
+		if !category.IsValid() {
+			return blocklists.ErrInvalidCategory
+		}
+	}
+
+	if !policy.YoutubeRestrictedMode.IsValid() {
+		return children.ErrInvalidYoutubeRestrictedMode
+	}
+
+	if !policy.BlockMode.IsValid() {
+		return children.ErrInvalidBlockMode
+	}
+
+	return nil
+}
+
+func FindOneById(db *sql.Tx, id int) (*Template, error) {
+	template, err := scanTemplate(db.QueryRow("SELECT "+selectColumns+" FROM policy_templates WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return template, nil
+}
+
+// FindAllByHouseholdId returns only the templates saved by the household, see Builtins for the others.
+func FindAllByHouseholdId(db *sql.Tx, householdId int) ([]Template, error) {
+	rows, err := db.Query("SELECT "+selectColumns+" FROM policy_templates WHERE household_id = $1 ORDER BY id", householdId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM policy_templates ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	templates := make([]Template, 0)
+
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		templates = append(templates, *template)
+	}
+
+	return templates, nil
+}
+
+func Create(db *sql.Tx, householdId int, name string, policy Policy) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxNameLength {
+		return 0, ErrInvalidName
+	}
+
+	err := policy.Validate()
+	if err != nil {
+		return 0, err
+	}
+
+	blockedCategories := make([]string, 0, len(policy.BlockedCategories))
+	for _, category := range policy.BlockedCategories {
+		blockedCategories = append(blockedCategories, string(category))
+	}
+
+	exec, err := db.Exec(
+		"INSERT INTO policy_templates (household_id, name, daily_screen_time_minutes, blocked_categories, safe_search, youtube_restricted_mode, block_mode) VALUES (?, ?, ?, ?, ?, ?, ?);",
+		householdId,
+		name,
+		policy.DailyScreenTimeMinutes,
+		strings.Join(blockedCategories, ","),
+		policy.SafeSearch,
+		policy.YoutubeRestrictedMode,
+		policy.BlockMode,
+	)
+	if err != nil {
+		if err.Error() == "UNIQUE constraint failed: policy_templates.household_id, policy_templates.name" {
+			return 0, ErrTemplateWithThisNameAlreadyExists
+		}
+
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO policy_templates ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// Delete keeps the settings of children the template was applied to.
+func Delete(db *sql.Tx, householdId int, id int) (bool, error) {
+	executed, err := db.Exec("DELETE FROM policy_templates WHERE id = ? AND household_id = ?", id, householdId)
+	if err != nil {
+		return false, fmt.Errorf("an error occured while trying to execute query 'DELETE FROM policy_templates ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	return affectedRows > 0, nil
+}
@@ -0,0 +1,195 @@
+package policytemplates
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+)
+
+// customKeyPrefix starts the keys of templates saved by a household, followed by their id.
+const customKeyPrefix = "custom_"
+
+// Policy is what a template sets on a child, settings it does not hold are left as they are.
+type Policy struct {
+	DailyScreenTimeMinutes int
+	BlockedCategories      []blocklists.Category
+	SafeSearch             bool
+	YoutubeRestrictedMode  children.YoutubeRestrictedMode
+	BlockMode              children.BlockMode
+}
+
+type Template struct {
+	// Id is zero for built-in templates.
+	Id int
+	// Key identifies the template, built-in ones have fixed keys, the others are custom_<id>.
+	Key string
+	// Name is empty for built-in templates, their names are translated, see NameIn.
+	Name string
+	// MinAge and MaxAge bound the ages a built-in template is for, both are inclusive.
+	MinAge int
+	MaxAge int
+	// HouseholdId is zero for built-in templates.
+	HouseholdId int
+	Policy      Policy
+	CreatedAt   time.Time
+}
+
+func (template *Template) IsBuiltin() bool {
+	return template.HouseholdId == 0
+}
+
+// Builtins are ordered by age, children older than the last one get no template.
+var Builtins = []Template{
+	{
+		Key:    "under_7",
+		MinAge: 0,
+		MaxAge: 6,
+		Policy: Policy{
+			DailyScreenTimeMinutes: 60,
+			BlockedCategories: []blocklists.Category{
+				blocklists.CategoryAdult,
+				blocklists.CategoryGambling,
+				blocklists.CategorySocialMedia,
+				blocklists.CategoryGaming,
+				blocklists.CategoryMalware,
+				blocklists.CategoryAds,
+			},
+			SafeSearch:            true,
+			YoutubeRestrictedMode: children.YoutubeRestrictedModeStrict,
+			BlockMode:             children.BlockModeBlockPage,
+		},
+	},
+	{
+		Key:    "7_12",
+		MinAge: 7,
+		MaxAge: 12,
+		Policy: Policy{
+			DailyScreenTimeMinutes: 120,
+			BlockedCategories: []blocklists.Category{
+				blocklists.CategoryAdult,
+				blocklists.CategoryGambling,
+				blocklists.CategorySocialMedia,
+				blocklists.CategoryMalware,
+			},
+			SafeSearch:            true,
+			YoutubeRestrictedMode: children.YoutubeRestrictedModeModerate,
+			BlockMode:             children.BlockModeBlockPage,
+		},
+	},
+	{
+		Key:    "teen",
+		MinAge: 13,
+		MaxAge: 17,
+		Policy: Policy{
+			DailyScreenTimeMinutes: 180,
+			BlockedCategories: []blocklists.Category{
+				blocklists.CategoryAdult,
+				blocklists.CategoryGambling,
+				blocklists.CategoryMalware,
+			},
+			SafeSearch:            true,
+			YoutubeRestrictedMode: children.YoutubeRestrictedModeOff,
+			BlockMode:             children.BlockModeBlockPage,
+		},
+	},
+}
+
+// Age returns the full years of a child born on the date of birthDate on the day now falls in the location.
+func Age(birthDate time.Time, now time.Time, location *time.Location) int {
+	birthYear, birthMonth, birthDay := birthDate.Date()
+	year, month, day := now.In(location).Date()
+
+	age := year - birthYear
+	if month < birthMonth || (month == birthMonth && day < birthDay) {
+		age--
+	}
+
+	return age
+}
+
+// BuiltinForAge returns nil when no built-in template covers the age.
+func BuiltinForAge(age int) *Template {
+	for i := range Builtins {
+		if age >= Builtins[i].MinAge && age <= Builtins[i].MaxAge {
+			return &Builtins[i]
+		}
+	}
+
+	return nil
+}
+
+func findBuiltin(key string) *Template {
+	for i := range Builtins {
+		if Builtins[i].Key == key {
+			return &Builtins[i]
+		}
+	}
+
+	return nil
+}
+
+// FindOneByKey finds a built-in template or one saved by the household, nil when there is none.
+func FindOneByKey(db *sql.Tx, householdId int, key string) (*Template, error) {
+	if builtin := findBuiltin(key); builtin != nil {
+		return builtin, nil
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(key, customKeyPrefix))
+	if err != nil || !strings.HasPrefix(key, customKeyPrefix) {
+		return nil, nil
+	}
+
+	template, err := FindOneById(db, id)
+	if err != nil || template == nil || template.HouseholdId != householdId {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// Upgrade returns the built-in template for the current age of the child when it replaced the one applied
+// from its previous age. Children without a birth date and with templates chosen by a parent are never offered one.
+func Upgrade(child *children.Model, now time.Time, location *time.Location) *Template {
+	if child.BirthDate.IsZero() || findBuiltin(child.PolicyTemplate) == nil {
+		return nil
+	}
+
+	bracket := BuiltinForAge(Age(child.BirthDate, now, location))
+	if bracket == nil || bracket.Key == child.PolicyTemplate {
+		return nil
+	}
+
+	return bracket
+}
+
+// Apply replaces the settings of the child with the policy of the template and remembers the template.
+func Apply(db *sql.Tx, childId int, template *Template) error {
+	policy := template.Policy
+
+	err := children.UpdateDailyScreenTime(db, childId, policy.DailyScreenTimeMinutes)
+	if err != nil {
+		return fmt.Errorf("failed to apply daily screen time: %w", err)
+	}
+
+	err = children.UpdateSafeSearch(db, childId, policy.SafeSearch, policy.YoutubeRestrictedMode)
+	if err != nil {
+		return fmt.Errorf("failed to apply safe search: %w", err)
+	}
+
+	err = children.UpdateBlockMode(db, childId, policy.BlockMode)
+	if err != nil {
+		return fmt.Errorf("failed to apply block mode: %w", err)
+	}
+
+	err = blocklists.UpdateBlockedCategoriesOfChild(db, childId, policy.BlockedCategories)
+	if err != nil {
+		return fmt.Errorf("failed to apply blocked categories: %w", err)
+	}
+
+	return children.UpdatePolicyTemplate(db, childId, template.Key)
+}
@@ -0,0 +1,273 @@
+package policytemplates
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/blocklists"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/database"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/users"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0001_users":                users.MigrationFile,
+		"0002_households":           households.MigrationFile,
+		"0003_children":             children.MigrationFile,
+		"0007_children_safe_search": children.SafeSearchMigrationFile,
+		"0008_blocklists":           blocklists.MigrationFile,
+		"0017_children_screen_time": children.ScreenTimeMigrationFile,
+		"0023_children_birth_date":  children.BirthDateMigrationFile,
+		"0024_policy_templates":     MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestAge(t *testing.T) {
+	birthDate := time.Date(2016, 3, 10, 0, 0, 0, 0, time.UTC)
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		now  time.Time
+		want int
+	}{
+		{time.Date(2023, 3, 9, 12, 0, 0, 0, time.UTC), 6},
+		{time.Date(2023, 3, 10, 0, 0, 0, 0, time.UTC), 7},
+		// already the birthday in Warsaw
+		{time.Date(2023, 3, 9, 23, 30, 0, 0, time.UTC), 7},
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 7},
+	}
+
+	for _, test := range tests {
+		if got := Age(birthDate, test.now, warsaw); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.now, got, test.want)
+		}
+	}
+}
+
+func TestBuiltinForAge(t *testing.T) {
+	tests := map[int]string{0: "under_7", 6: "under_7", 7: "7_12", 12: "7_12", 13: "teen", 17: "teen", 18: ""}
+
+	for age, want := range tests {
+		got := ""
+		if template := BuiltinForAge(age); template != nil {
+			got = template.Key
+		}
+
+		if got != want {
+			t.Errorf("%d: got %q, want %q", age, got, want)
+		}
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	now := time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	householdId, err := households.Create(tx, 1, "Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	childId, err := children.Create(tx, householdId, "Adam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("validates custom templates", func(t *testing.T) {
+		valid := Policy{YoutubeRestrictedMode: children.YoutubeRestrictedModeOff, BlockMode: children.BlockModeNxdomain}
+
+		invalid := []struct {
+			name   string
+			policy func(policy Policy) Policy
+			want   error
+		}{
+			{"", func(policy Policy) Policy { return policy }, ErrInvalidName},
+			{"Weekend", func(policy Policy) Policy { policy.DailyScreenTimeMinutes = -1; return policy }, children.ErrInvalidDailyScreenTime},
+			{"Weekend", func(policy Policy) Policy {
+				policy.BlockedCategories = []blocklists.Category{"news"}
+				return policy
+			}, blocklists.ErrInvalidCategory},
+			{"Weekend", func(policy Policy) Policy { policy.BlockMode = "drop"; return policy }, children.ErrInvalidBlockMode},
+		}
+
+		for _, test := range invalid {
+			_, err := Create(tx, householdId, test.name, test.policy(valid))
+			if !errors.Is(err, test.want) {
+				t.Errorf("Expected %v, received %v", test.want, err)
+			}
+		}
+
+		_, err := Create(tx, householdId, "Weekend", valid)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Create(tx, householdId, "Weekend", valid)
+		if !errors.Is(err, ErrTemplateWithThisNameAlreadyExists) {
+			t.Fatalf("Expected %v, received %v", ErrTemplateWithThisNameAlreadyExists, err)
+		}
+	})
+
+	t.Run("finds templates by key within the household only", func(t *testing.T) {
+		templateId, err := Create(tx, householdId, "Holidays", Policy{
+			DailyScreenTimeMinutes: 240,
+			BlockedCategories:      []blocklists.Category{blocklists.CategoryAdult, blocklists.CategoryAds},
+			YoutubeRestrictedMode:  children.YoutubeRestrictedModeModerate,
+			BlockMode:              children.BlockModeNxdomain,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		template, err := FindOneByKey(tx, householdId, "custom_"+strconv.Itoa(templateId))
+		if err != nil || template == nil {
+			t.Fatalf("Expected the template, received %v", err)
+		}
+
+		if template.Name != "Holidays" || !reflect.DeepEqual(template.Policy.BlockedCategories, []blocklists.Category{blocklists.CategoryAdult, blocklists.CategoryAds}) {
+			t.Fatalf("Unexpected template: %+v", template)
+		}
+
+		missing := []struct {
+			householdId int
+			key         string
+		}{
+			{householdId, "custom_x"},
+			{householdId, "unknown"},
+			{householdId + 1, template.Key},
+		}
+
+		for _, test := range missing {
+			template, err := FindOneByKey(tx, test.householdId, test.key)
+			if err != nil || template != nil {
+				t.Errorf("%+v: expected no template, received %+v, %v", test, template, err)
+			}
+		}
+
+		builtin, err := FindOneByKey(tx, householdId, "teen")
+		if err != nil || builtin == nil || !builtin.IsBuiltin() {
+			t.Fatalf("Expected the built-in template, received %+v, %v", builtin, err)
+		}
+	})
+
+	t.Run("applies templates and offers upgrades of built-in ones", func(t *testing.T) {
+		err := children.UpdateBirthDate(tx, childId, time.Date(2016, 3, 11, 0, 0, 0, 0, time.UTC), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Apply(tx, childId, BuiltinForAge(6))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		child, err := children.FindOneById(tx, childId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		categories, err := blocklists.FindBlockedCategoriesByChildId(tx, childId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if child.PolicyTemplate != "under_7" || child.DailyScreenTimeMinutes != 60 || !child.SafeSearch || child.BlockMode != children.BlockModeBlockPage || len(categories) != 6 {
+			t.Fatalf("Unexpected child: %+v with %v", child, categories)
+		}
+
+		if upgrade := Upgrade(child, now, time.UTC); upgrade != nil {
+			t.Fatalf("Expected no upgrade the day before the birthday, received %s", upgrade.Key)
+		}
+
+		upgrade := Upgrade(child, now.AddDate(0, 0, 1), time.UTC)
+		if upgrade == nil || upgrade.Key != "7_12" {
+			t.Fatalf("Expected the 7_12 upgrade, received %+v", upgrade)
+		}
+
+		custom, err := FindOneByKey(tx, householdId, "custom_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Apply(tx, childId, custom)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		child, err = children.FindOneById(tx, childId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if upgrade := Upgrade(child, now.AddDate(0, 0, 1), time.UTC); upgrade != nil {
+			t.Fatalf("Expected no upgrade of a custom template, received %s", upgrade.Key)
+		}
+	})
+
+	t.Run("refuses birth dates in the future", func(t *testing.T) {
+		err := children.UpdateBirthDate(tx, childId, now.AddDate(0, 0, 1), now)
+		if !errors.Is(err, children.ErrInvalidBirthDate) {
+			t.Fatalf("Expected %v, received %v", children.ErrInvalidBirthDate, err)
+		}
+	})
+}
+
+func TestBuiltinNames(t *testing.T) {
+	if missing := builtinNames.Missing(); len(missing) > 0 {
+		t.Fatalf("Missing translations: %v", missing)
+	}
+
+	for _, language := range i18n.Languages {
+		for i := range Builtins {
+			if _, found := builtinNames.Lookup(language, Builtins[i].Key); !found {
+				t.Errorf("%s: built-in template %s has no name", language, Builtins[i].Key)
+			}
+		}
+	}
+
+	custom := Template{Id: 1, Key: "custom_1", Name: "Holidays", HouseholdId: 1}
+	if name := custom.NameIn(i18n.LanguageEnglish); name != "Holidays" {
+		t.Errorf("Expected the name given by the household, received %q", name)
+	}
+
+	if name := Builtins[2].NameIn(i18n.LanguageEnglish); name != "Teenager" {
+		t.Errorf("Expected the english name, received %q", name)
+	}
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/proxyca"
+)
+
+// maxCachedLeaves bounds the memory of the leaf cache, it starts over when full.
+const maxCachedLeaves = 1000
+
+// AuthoritySource finds the certificate authority of the household of the child. It returns nil authority when
+// the household did not opt in to intercepting https.
+type AuthoritySource interface {
+	AuthorityForChild(ctx context.Context, childId int) (*proxyca.Authority, error)
+}
+
+type DatabaseAuthoritySource struct {
+	db *sql.DB
+}
+
+func NewDatabaseAuthoritySource(db *sql.DB) *DatabaseAuthoritySource {
+	return &DatabaseAuthoritySource{db: db}
+}
+
+func (source *DatabaseAuthoritySource) AuthorityForChild(ctx context.Context, childId int) (*proxyca.Authority, error) {
+	tx, err := source.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	model, err := proxyca.FindOneByChildId(tx, childId)
+	if err != nil {
+		return nil, littlehelpers.IfErrJoin(fmt.Errorf("error occured while trying to find proxy authority of child %d: %w", childId, err), tx.Rollback())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit the transaction: %w", err)
+	}
+
+	if model == nil {
+		return nil, nil
+	}
+
+	return proxyca.Parse(model.CertificatePem, model.PrivateKeyPem)
+}
+
+type leafKey struct {
+	authority [sha256.Size]byte
+	host      string
+}
+
+// leafCache keeps the certificates issued for intercepted hosts, issuing one takes a new key pair.
+type leafCache struct {
+	mu     sync.Mutex
+	leaves map[leafKey]*tls.Certificate
+}
+
+func newLeafCache() *leafCache {
+	return &leafCache{leaves: make(map[leafKey]*tls.Certificate)}
+}
+
+// get returns a certificate for the host valid for at least half of proxyca.LeafValidity.
+func (cache *leafCache) get(authority *proxyca.Authority, host string, now time.Time) (*tls.Certificate, error) {
+	key := leafKey{authority: sha256.Sum256(authority.Certificate.Raw), host: host}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if leaf, found := cache.leaves[key]; found && leaf.Leaf.NotAfter.Sub(now) > proxyca.LeafValidity/2 {
+		return leaf, nil
+	}
+
+	leaf, err := authority.Issue(host, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cache.leaves) >= maxCachedLeaves {
+		clear(cache.leaves)
+	}
+
+	cache.leaves[key] = leaf
+
+	return leaf, nil
+}
@@ -0,0 +1,23 @@
+package proxy
+
+import (
+	"net/http"
+
+	"domanscy.group/parental-controls/client/i18n"
+)
+
+// catalog holds the texts the proxy answers with instead of the pages it does not let through.
+var catalog = i18n.Catalog{
+	i18n.LanguagePolish: {
+		"blocked": "Ta strona została zablokowana przez kontrolę rodzicielską.",
+	},
+	i18n.LanguageEnglish: {
+		"blocked": "This page was blocked by parental controls.",
+	},
+}
+
+// blockedMessage is all a child sees of a blocked page in the language of their browser, the block page can not be
+// shown for a domain of someone else.
+func blockedMessage(r *http.Request) string {
+	return catalog.Text(i18n.PreferredLanguage(r.Header.Get("Accept-Language")), "blocked")
+}
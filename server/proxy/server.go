@@ -0,0 +1,225 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"domanscy.group/parental-controls/server/dnsfilter"
+)
+
+const lookupTimeout = time.Second * 10
+const dialTimeout = time.Second * 10
+const shutdownTimeout = time.Second * 5
+
+var ErrUnknownClient = errors.New("this device is not managed by parental controls")
+var ErrNotProxyRequest = errors.New("only absolute http urls and CONNECT can be requested from a proxy")
+var ErrInvalidConnectTarget = errors.New("CONNECT target has to be host:port")
+
+// Server is a forward proxy filtering with the same policy and logging requests to the same log as the dns filter.
+// Clients send the DoH token of their device, the last part of its DoH url, as the password of Proxy-Authorization,
+// or are recognised by their ip address.
+// Unknown clients are refused, an open proxy would be abused by anyone who finds it.
+//
+// https is tunneled without looking into it, the domain and the server name of the TLS handshake are all the proxy
+// decides about. Only households with a certificate authority, see proxyca, have the requests to domains with path
+// rules intercepted, every other domain is still tunneled.
+type Server struct {
+	policies    dnsfilter.PolicySource
+	requestLog  dnsfilter.RequestLog
+	authorities AuthoritySource
+
+	// dial connects to upstream servers, both for tunnels and for forwarded requests.
+	dial      func(ctx context.Context, network string, address string) (net.Conn, error)
+	transport *http.Transport
+	leaves    *leafCache
+
+	listener   net.Listener
+	httpServer *http.Server
+}
+
+func NewServer(policies dnsfilter.PolicySource, requestLog dnsfilter.RequestLog, authorities AuthoritySource) *Server {
+	server := &Server{
+		policies:    policies,
+		requestLog:  requestLog,
+		authorities: authorities,
+		dial:        (&net.Dialer{Timeout: dialTimeout}).DialContext,
+		leaves:      newLeafCache(),
+	}
+
+	server.transport = &http.Transport{
+		DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return server.dial(ctx, network, address)
+		},
+		TLSClientConfig:     &tls.Config{},
+		TLSHandshakeTimeout: dialTimeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	return server
+}
+
+// Start serves in the background, errors returned while serving are sent to errCh. Port 0 picks a random port, see Addr.
+func (server *Server) Start(address string, errCh chan<- error) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on tcp address '%s': %w", address, err)
+	}
+
+	server.listener = listener
+	server.httpServer = &http.Server{Handler: server, ReadHeaderTimeout: 30 * time.Second}
+
+	go func() {
+		err := server.httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- fmt.Errorf("proxy server stopped: %w", err)
+		}
+	}()
+
+	return nil
+}
+
+func (server *Server) Addr() string {
+	return server.listener.Addr().String()
+}
+
+// Shutdown stops accepting clients, tunnels that are already open are not waited for.
+func (server *Server) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	server.transport.CloseIdleConnections()
+
+	return server.httpServer.Shutdown(ctx)
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	policy, err := server.policyForRequest(r)
+	if err != nil {
+		log.Printf("failed to find policy for proxy client '%s': %v", r.RemoteAddr, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if policy == nil {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="parental-controls"`)
+		http.Error(w, ErrUnknownClient.Error(), http.StatusProxyAuthRequired)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		server.serveConnect(w, r, policy)
+		return
+	}
+
+	if r.URL.Scheme != "http" || r.URL.Host == "" {
+		http.Error(w, ErrNotProxyRequest.Error(), http.StatusBadRequest)
+		return
+	}
+
+	upstream := &url.URL{Scheme: "http", Host: r.URL.Host}
+	if r.URL.Port() == "" {
+		upstream.Host = net.JoinHostPort(r.URL.Hostname(), "80")
+	}
+
+	server.forward(w, r, policy, upstream)
+}
+
+// dohTokenFromRequest returns the password of Proxy-Authorization, found is true whenever the header was sent.
+// It is the DoH token and not the one the agent authenticates with, the proxy settings are readable on the device.
+func dohTokenFromRequest(r *http.Request) (token string, found bool) {
+	header := r.Header.Get("Proxy-Authorization")
+	if header == "" {
+		return "", false
+	}
+
+	scheme, credentials, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "Basic") {
+		return "", true
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return "", true
+	}
+
+	_, token, _ = strings.Cut(string(decoded), ":")
+
+	return token, true
+}
+
+// policyForRequest returns nil policy for unknown clients. A client that sent credentials is never recognised
+// by its ip address, wrong credentials would go unnoticed otherwise.
+func (server *Server) policyForRequest(r *http.Request) (*dnsfilter.Policy, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), lookupTimeout)
+	defer cancel()
+
+	if token, found := dohTokenFromRequest(r); found {
+		if token == "" {
+			return nil, nil
+		}
+
+		return server.policies.PolicyForDohToken(ctx, token)
+	}
+
+	clientIp, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client ip address from '%s': %w", r.RemoteAddr, err)
+	}
+
+	return server.policies.PolicyForClientIp(ctx, clientIp)
+}
+
+func (server *Server) logRequest(policy *dnsfilter.Policy, host string, path string, decision dnsfilter.Decision) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	err := server.requestLog.LogRequest(ctx, policy, host, path, decision)
+	if err != nil {
+		log.Printf("failed to log proxy request to '%s': %v", host, err)
+	}
+}
+
+// forward decides about the request and sends the allowed ones to upstream (scheme and host:port), or to the safe
+// search host of the domain on the same port.
+func (server *Server) forward(w http.ResponseWriter, r *http.Request, policy *dnsfilter.Policy, upstream *url.URL) {
+	host := upstream.Hostname()
+
+	decision := policy.DecideUrl(host, r.URL.Path)
+	server.logRequest(policy, host, r.URL.Path, decision)
+
+	if decision.Blocked {
+		http.Error(w, blockedMessage(r), http.StatusForbidden)
+		return
+	}
+
+	target := *upstream
+	if decision.RewriteTo != "" {
+		target.Host = net.JoinHostPort(decision.RewriteTo, upstream.Port())
+	}
+
+	reverseProxy := &httputil.ReverseProxy{
+		Rewrite: func(request *httputil.ProxyRequest) {
+			request.Out.URL.Scheme = target.Scheme
+			request.Out.URL.Host = target.Host
+			request.Out.Host = request.In.Host
+		},
+		Transport: server.transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("failed to forward proxy request to '%s': %v", target.Host, err)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+
+	reverseProxy.ServeHTTP(w, r)
+}
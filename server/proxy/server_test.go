@@ -0,0 +1,394 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/client/i18n"
+	"domanscy.group/parental-controls/server/dnsfilter"
+	"domanscy.group/parental-controls/server/domainrules"
+	"domanscy.group/parental-controls/server/proxyca"
+)
+
+type fakePolicySource struct {
+	byDohToken map[string]*dnsfilter.Policy
+}
+
+func (source *fakePolicySource) PolicyForClientIp(_ context.Context, _ string) (*dnsfilter.Policy, error) {
+	return nil, nil
+}
+
+func (source *fakePolicySource) PolicyForDeviceToken(_ context.Context, _ string) (*dnsfilter.Policy, error) {
+	return nil, nil
+}
+
+func (source *fakePolicySource) PolicyForDohToken(_ context.Context, dohToken string) (*dnsfilter.Policy, error) {
+	return source.byDohToken[dohToken], nil
+}
+
+type loggedRequest struct {
+	childId int
+	host    string
+	path    string
+	blocked bool
+}
+
+type fakeRequestLog struct {
+	mutex    sync.Mutex
+	requests []loggedRequest
+}
+
+func (requestLog *fakeRequestLog) LogRequest(_ context.Context, policy *dnsfilter.Policy, host string, path string, decision dnsfilter.Decision) error {
+	requestLog.mutex.Lock()
+	defer requestLog.mutex.Unlock()
+
+	requestLog.requests = append(requestLog.requests, loggedRequest{childId: policy.ChildId, host: host, path: path, blocked: decision.Blocked})
+
+	return nil
+}
+
+// last waits a moment for requests logged after the response was sent, e.g. when a tunnel was closed.
+func (requestLog *fakeRequestLog) last(t *testing.T) loggedRequest {
+	t.Helper()
+
+	for attempt := 0; attempt < 100; attempt++ {
+		requestLog.mutex.Lock()
+		count := len(requestLog.requests)
+		requestLog.mutex.Unlock()
+
+		if count > 0 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	requestLog.mutex.Lock()
+	defer requestLog.mutex.Unlock()
+
+	if len(requestLog.requests) == 0 {
+		t.Fatal("Expected a logged request")
+	}
+
+	last := requestLog.requests[len(requestLog.requests)-1]
+	requestLog.requests = nil
+
+	return last
+}
+
+type fakeAuthoritySource map[int]*proxyca.Authority
+
+func (source fakeAuthoritySource) AuthorityForChild(_ context.Context, childId int) (*proxyca.Authority, error) {
+	return source[childId], nil
+}
+
+// echoHandler answers with the host and the path it received.
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = fmt.Fprintf(w, "%s%s", r.Host, r.URL.Path)
+})
+
+func TestCatalogIsComplete(t *testing.T) {
+	if missing := catalog.Missing(); len(missing) > 0 {
+		t.Fatalf("Missing translations: %v", missing)
+	}
+}
+
+func TestServer(t *testing.T) {
+	plainUpstream := httptest.NewServer(echoHandler)
+	defer plainUpstream.Close()
+
+	// the certificate of httptest is valid for example.com and its subdomains
+	tlsUpstream := httptest.NewTLSServer(echoHandler)
+	defer tlsUpstream.Close()
+
+	now := time.Now()
+
+	certificatePem, privateKeyPem, err := proxyca.Generate("Home", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authority, err := proxyca.Parse(certificatePem, privateKeyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newPolicy := func(childId int) *dnsfilter.Policy {
+		return &dnsfilter.Policy{
+			ChildId:       childId,
+			Blocklist:     []string{"blocked.example.com"},
+			PathBlocklist: []domainrules.PathRule{{Domain: "www.example.com", Path: "/@channel"}},
+			SafeSearch:    true,
+		}
+	}
+
+	policies := &fakePolicySource{byDohToken: map[string]*dnsfilter.Policy{
+		"tunneled":    newPolicy(1),
+		"intercepted": newPolicy(2),
+	}}
+
+	requestLog := &fakeRequestLog{}
+
+	server := NewServer(policies, requestLog, fakeAuthoritySource{2: authority})
+
+	// every domain resolves to the upstream of its scheme
+	server.dial = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+
+		if host != "127.0.0.1" && port == "80" {
+			address = plainUpstream.Listener.Addr().String()
+		} else if host != "127.0.0.1" {
+			address = tlsUpstream.Listener.Addr().String()
+		}
+
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+
+	upstreamRoots := x509.NewCertPool()
+	upstreamRoots.AddCert(tlsUpstream.Certificate())
+	server.transport.TLSClientConfig = &tls.Config{RootCAs: upstreamRoots}
+
+	errCh := make(chan error, 1)
+
+	err = server.Start("127.0.0.1:0", errCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err := server.Shutdown()
+		if err != nil {
+			t.Error(err)
+		}
+
+		select {
+		case err = <-errCh:
+			t.Error(err)
+		default:
+			// nothing
+		}
+	})
+
+	authorityRoots := x509.NewCertPool()
+	authorityRoots.AddCert(authority.Certificate)
+
+	newClient := func(token string, roots *x509.CertPool) *http.Client {
+		proxyUrl := &url.URL{Scheme: "http", Host: server.Addr()}
+		if token != "" {
+			proxyUrl.User = url.UserPassword("device", token)
+		}
+
+		return &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyUrl),
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		}}
+	}
+
+	get := func(t *testing.T, client *http.Client, requestUrl string) (*http.Response, string) {
+		t.Helper()
+
+		response, err := client.Get(requestUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer response.Body.Close()
+
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return response, string(body)
+	}
+
+	t.Run("refuses unknown clients", func(t *testing.T) {
+		for _, token := range []string{"", "unknown"} {
+			response, _ := get(t, newClient(token, nil), "http://www.example.com/")
+
+			if response.StatusCode != http.StatusProxyAuthRequired {
+				t.Errorf("%q: got %d, want %d", token, response.StatusCode, http.StatusProxyAuthRequired)
+			}
+		}
+	})
+
+	t.Run("filters plain http by path", func(t *testing.T) {
+		client := newClient("tunneled", nil)
+
+		response, body := get(t, client, "http://www.example.com/@channel/videos")
+		if response.StatusCode != http.StatusForbidden || !strings.Contains(body, catalog.Text(i18n.DefaultLanguage, "blocked")) {
+			t.Fatalf("Got %d %q, want the request blocked", response.StatusCode, body)
+		}
+
+		if logged := requestLog.last(t); logged != (loggedRequest{childId: 1, host: "www.example.com", path: "/@channel/videos", blocked: true}) {
+			t.Fatalf("Unexpected log: %+v", logged)
+		}
+
+		response, body = get(t, client, "http://www.example.com/@channelfan")
+		if response.StatusCode != http.StatusOK || body != "www.example.com/@channelfan" {
+			t.Fatalf("Got %d %q, want the request forwarded", response.StatusCode, body)
+		}
+
+		requestLog.last(t)
+	})
+
+	t.Run("answers blocked requests in the language of the browser", func(t *testing.T) {
+		request, err := http.NewRequest(http.MethodGet, "http://www.example.com/@channel/videos", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request.Header.Set("Accept-Language", "en-GB,en;q=0.9")
+
+		response, err := newClient("tunneled", nil).Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer response.Body.Close()
+
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.StatusCode != http.StatusForbidden || !strings.Contains(string(body), catalog.Text(i18n.LanguageEnglish, "blocked")) {
+			t.Fatalf("Got %d %q, want the request blocked in english", response.StatusCode, body)
+		}
+	})
+
+	t.Run("forwards plain http to the safe search host", func(t *testing.T) {
+		var dialed []string
+
+		dial := server.dial
+		server.dial = func(ctx context.Context, network string, address string) (net.Conn, error) {
+			dialed = append(dialed, address)
+			return dial(ctx, network, address)
+		}
+
+		defer func() {
+			server.dial = dial
+		}()
+
+		// a fresh client, so it does not reuse a connection to another host
+		response, body := get(t, newClient("tunneled", nil), "http://www.google.com/search")
+		if response.StatusCode != http.StatusOK || body != "www.google.com/search" {
+			t.Fatalf("Got %d %q, want the request forwarded with its host", response.StatusCode, body)
+		}
+
+		if len(dialed) != 1 || dialed[0] != "forcesafesearch.google.com:80" {
+			t.Fatalf("Got %v, want forcesafesearch.google.com:80 dialed", dialed)
+		}
+
+		requestLog.last(t)
+	})
+
+	t.Run("tunnels https without interception by default", func(t *testing.T) {
+		response, body := get(t, newClient("tunneled", upstreamRoots), "https://www.example.com/@channel")
+		if response.StatusCode != http.StatusOK || body != "www.example.com/@channel" {
+			t.Fatalf("Got %d %q, want the tunnel to reach upstream", response.StatusCode, body)
+		}
+
+		if logged := requestLog.last(t); logged != (loggedRequest{childId: 1, host: "www.example.com", path: "", blocked: false}) {
+			t.Fatalf("Unexpected log: %+v", logged)
+		}
+	})
+
+	t.Run("refuses CONNECT to blocked domains", func(t *testing.T) {
+		_, err := newClient("tunneled", upstreamRoots).Get("https://blocked.example.com/")
+		if err == nil || !strings.Contains(err.Error(), "Forbidden") {
+			t.Fatalf("Expected the CONNECT refused, received %v", err)
+		}
+
+		if logged := requestLog.last(t); !logged.blocked || logged.host != "blocked.example.com" {
+			t.Fatalf("Unexpected log: %+v", logged)
+		}
+	})
+
+	t.Run("closes tunnels to blocked server names", func(t *testing.T) {
+		conn, err := net.Dial("tcp", server.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer conn.Close()
+
+		_, err = fmt.Fprintf(conn, "CONNECT www.example.com:443 HTTP/1.1\r\nHost: www.example.com:443\r\nProxy-Authorization: Basic %s\r\n\r\n", base64.StdEncoding.EncodeToString([]byte("device:tunneled")))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reader := bufio.NewReader(conn)
+
+		response, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Got %d, want the tunnel opened", response.StatusCode)
+		}
+
+		client := tls.Client(&bufferedConn{Conn: conn, reader: reader}, &tls.Config{ServerName: "blocked.example.com", RootCAs: upstreamRoots})
+
+		err = client.Handshake()
+		if err == nil {
+			t.Fatal("Expected the tunnel closed")
+		}
+
+		if logged := requestLog.last(t); !logged.blocked || logged.host != "blocked.example.com" {
+			t.Fatalf("Unexpected log: %+v", logged)
+		}
+	})
+
+	t.Run("intercepts hosts with path rules when the household has an authority", func(t *testing.T) {
+		client := newClient("intercepted", authorityRoots)
+
+		response, body := get(t, client, "https://www.example.com/@channel/videos")
+		if response.StatusCode != http.StatusForbidden || !strings.Contains(body, catalog.Text(i18n.DefaultLanguage, "blocked")) {
+			t.Fatalf("Got %d %q, want the request blocked", response.StatusCode, body)
+		}
+
+		if issuer := response.TLS.PeerCertificates[0].Issuer.CommonName; issuer != "Home" {
+			t.Fatalf("Got certificate issued by %q, want the authority of the household", issuer)
+		}
+
+		if logged := requestLog.last(t); logged != (loggedRequest{childId: 2, host: "www.example.com", path: "/@channel/videos", blocked: true}) {
+			t.Fatalf("Unexpected log: %+v", logged)
+		}
+
+		response, body = get(t, client, "https://www.example.com/watch")
+		if response.StatusCode != http.StatusOK || body != "www.example.com/watch" {
+			t.Fatalf("Got %d %q, want the request forwarded", response.StatusCode, body)
+		}
+
+		if logged := requestLog.last(t); logged != (loggedRequest{childId: 2, host: "www.example.com", path: "/watch", blocked: false}) {
+			t.Fatalf("Unexpected log: %+v", logged)
+		}
+	})
+
+	t.Run("tunnels hosts without path rules even with an authority", func(t *testing.T) {
+		response, body := get(t, newClient("intercepted", upstreamRoots), "https://tunneled.example.com/@channel")
+		if response.StatusCode != http.StatusOK || body != "tunneled.example.com/@channel" {
+			t.Fatalf("Got %d %q, want the tunnel to reach upstream", response.StatusCode, body)
+		}
+
+		requestLog.last(t)
+	})
+}
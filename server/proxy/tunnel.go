@@ -0,0 +1,319 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"domanscy.group/parental-controls/server/dnsfilter"
+	"domanscy.group/parental-controls/server/proxyca"
+)
+
+// helloTimeout is how long a client may keep a tunnel open before saying which server it wants, clients speaking
+// anything else than TLS are tunneled after it with the decision about the CONNECT target.
+const helloTimeout = time.Second * 10
+
+var errHelloPeeked = errors.New("client hello peeked")
+var errReadOnly = errors.New("connection is read only")
+
+// bufferedConn reads the bytes already read from the connection before the connection itself.
+type bufferedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (conn *bufferedConn) Read(p []byte) (int, error) {
+	return conn.reader.Read(p)
+}
+
+// readOnlyConn lets a TLS handshake read the client hello without answering it.
+type readOnlyConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (conn readOnlyConn) Read(p []byte) (int, error) {
+	return conn.reader.Read(p)
+}
+
+func (conn readOnlyConn) Write(_ []byte) (int, error) {
+	return 0, errReadOnly
+}
+
+// peekServerName reads the server name from the TLS client hello, empty if the client sent none or does not speak TLS.
+// The bytes read are read again from the connection afterwards.
+func peekServerName(conn *bufferedConn) string {
+	var peeked bytes.Buffer
+	serverName := ""
+
+	err := conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	if err != nil {
+		return ""
+	}
+
+	handshake := tls.Server(readOnlyConn{Conn: conn.Conn, reader: io.TeeReader(conn.reader, &peeked)}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errHelloPeeked
+		},
+	})
+
+	// the handshake always fails, the hello is all it is needed for
+	_ = handshake.Handshake()
+
+	conn.reader = io.MultiReader(&peeked, conn.reader)
+
+	err = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(serverName)
+}
+
+// authorityForHost returns the authority only when the household opted in to interception and the child has path
+// rules for the host, every other host is tunneled.
+func (server *Server) authorityForHost(policy *dnsfilter.Policy, host string) (*proxyca.Authority, error) {
+	if !policy.HasPathRules(host) {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	return server.authorities.AuthorityForChild(ctx, policy.ChildId)
+}
+
+// serveConnect decides about the CONNECT target before answering it and about the server name of the TLS handshake
+// after, a child could ask for an allowed host and then talk to a blocked one behind the same ip address.
+func (server *Server) serveConnect(w http.ResponseWriter, r *http.Request, policy *dnsfilter.Policy) {
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil || host == "" || port == "" {
+		http.Error(w, ErrInvalidConnectTarget.Error(), http.StatusBadRequest)
+		return
+	}
+
+	host = strings.ToLower(host)
+	decision := policy.Decide(host)
+
+	authority, err := server.authorityForHost(policy, host)
+	if err != nil {
+		log.Printf("failed to find proxy authority of child %d: %v", policy.ChildId, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if decision.Blocked && authority == nil {
+		server.logRequest(policy, host, "", decision)
+		http.Error(w, blockedMessage(r), http.StatusForbidden)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		log.Printf("proxy connection of '%s' can not be hijacked", r.RemoteAddr)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("failed to hijack proxy connection of '%s': %v", r.RemoteAddr, err)
+		return
+	}
+
+	defer func(conn net.Conn) {
+		err := conn.Close()
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("failed to close proxy connection of '%s': %v", r.RemoteAddr, err)
+		}
+	}(conn)
+
+	_, err = conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+	if err != nil {
+		return
+	}
+
+	client := &bufferedConn{Conn: conn, reader: buffered.Reader}
+
+	serverName := peekServerName(client)
+	if serverName == "" {
+		serverName = host
+	}
+
+	if serverName != host {
+		if serverNameDecision := policy.Decide(serverName); serverNameDecision.Blocked || !decision.Blocked {
+			decision = serverNameDecision
+		}
+
+		authority, err = server.authorityForHost(policy, serverName)
+		if err != nil {
+			log.Printf("failed to find proxy authority of child %d: %v", policy.ChildId, err)
+			return
+		}
+	}
+
+	if authority != nil {
+		server.intercept(client, policy, authority, serverName, port)
+		return
+	}
+
+	server.logRequest(policy, serverName, "", decision)
+
+	if decision.Blocked {
+		return
+	}
+
+	target := host
+	if decision.RewriteTo != "" {
+		target = decision.RewriteTo
+	}
+
+	server.tunnel(client, net.JoinHostPort(target, port))
+}
+
+func (server *Server) tunnel(client *bufferedConn, address string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	upstream, err := server.dial(ctx, "tcp", address)
+	if err != nil {
+		log.Printf("failed to connect to '%s': %v", address, err)
+		return
+	}
+
+	defer func(upstream net.Conn) {
+		err := upstream.Close()
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("failed to close connection to '%s': %v", address, err)
+		}
+	}(upstream)
+
+	done := make(chan struct{}, 2)
+
+	copyAndClose := func(destination net.Conn, source io.Reader) {
+		_, _ = io.Copy(destination, source)
+
+		// lets the other direction finish what the peer still sends
+		if tcpConn, ok := destination.(interface{ CloseWrite() error }); ok {
+			_ = tcpConn.CloseWrite()
+		}
+
+		done <- struct{}{}
+	}
+
+	go copyAndClose(upstream, client)
+	go copyAndClose(client.Conn, upstream)
+
+	<-done
+	<-done
+}
+
+// intercept terminates TLS with a certificate of the household authority and forwards every request decided with
+// the path rules to serverName.
+func (server *Server) intercept(client *bufferedConn, policy *dnsfilter.Policy, authority *proxyca.Authority, serverName string, port string) {
+	certificate, err := server.leaves.get(authority, serverName, time.Now())
+	if err != nil {
+		log.Printf("failed to issue proxy certificate for '%s': %v", serverName, err)
+		return
+	}
+
+	conn := tls.Server(client, &tls.Config{
+		Certificates: []tls.Certificate{*certificate},
+		NextProtos:   []string{"http/1.1"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), helloTimeout)
+	defer cancel()
+
+	err = conn.HandshakeContext(ctx)
+	if err != nil {
+		// mostly devices that do not trust the authority of the household yet
+		log.Printf("failed to intercept connection of child %d to '%s': %v", policy.ChildId, serverName, err)
+		return
+	}
+
+	upstream := &url.URL{Scheme: "https", Host: net.JoinHostPort(serverName, port)}
+
+	httpServer := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+
+			// the request has to be for the server the certificate was issued for
+			if !strings.EqualFold(host, serverName) {
+				http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+				return
+			}
+
+			server.forward(w, r, policy, upstream)
+		}),
+		ReadHeaderTimeout: 30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	_ = httpServer.Serve(newSingleConnListener(conn))
+}
+
+// singleConnListener serves a single connection, Accept blocks after it until the connection is closed.
+type singleConnListener struct {
+	conn   net.Conn
+	addr   net.Addr
+	closed chan struct{}
+}
+
+type closeNotifyingConn struct {
+	net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (conn *closeNotifyingConn) Close() error {
+	conn.once.Do(func() {
+		close(conn.closed)
+	})
+
+	return conn.Conn.Close()
+}
+
+func newSingleConnListener(conn net.Conn) *singleConnListener {
+	closed := make(chan struct{})
+
+	return &singleConnListener{
+		conn:   &closeNotifyingConn{Conn: conn, closed: closed},
+		addr:   conn.LocalAddr(),
+		closed: closed,
+	}
+}
+
+func (listener *singleConnListener) Accept() (net.Conn, error) {
+	if listener.conn != nil {
+		conn := listener.conn
+		listener.conn = nil
+
+		return conn, nil
+	}
+
+	<-listener.closed
+
+	return nil, net.ErrClosed
+}
+
+func (listener *singleConnListener) Close() error {
+	return nil
+}
+
+func (listener *singleConnListener) Addr() net.Addr {
+	return listener.addr
+}
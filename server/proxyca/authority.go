@@ -0,0 +1,169 @@
+package proxyca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+// AuthorityValidity is long, every device of the household has to install the certificate again after it expires.
+const AuthorityValidity = 10 * 365 * 24 * time.Hour
+
+// LeafValidity is short, the proxy issues leaves on demand and never stores them.
+const LeafValidity = 7 * 24 * time.Hour
+
+var ErrInvalidAuthority = errors.New("invalid proxy certificate authority")
+var ErrInvalidHost = errors.New("invalid host")
+
+// Authority signs the certificates the forward proxy presents to the devices of a household when it intercepts
+// their https traffic.
+type Authority struct {
+	Certificate *x509.Certificate
+	privateKey  crypto.Signer
+}
+
+func randomSerialNumber() (*big.Int, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a serial number: %w", err)
+	}
+
+	return serialNumber, nil
+}
+
+// Generate creates a self signed authority that can sign only leaf certificates, both are returned PEM encoded.
+func Generate(commonName string, now time.Time) (certificatePem string, privateKeyPem string, err error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate the private key: %w", err)
+	}
+
+	serialNumber, err := randomSerialNumber()
+	if err != nil {
+		return "", "", err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"Parental controls"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(AuthorityValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create the certificate: %w", err)
+	}
+
+	encodedKey, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode the private key: %w", err)
+	}
+
+	certificatePem = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}))
+	privateKeyPem = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encodedKey}))
+
+	return certificatePem, privateKeyPem, nil
+}
+
+// Parse reads an authority created by Generate.
+func Parse(certificatePem string, privateKeyPem string) (*Authority, error) {
+	certificateBlock, _ := pem.Decode([]byte(certificatePem))
+	if certificateBlock == nil || certificateBlock.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%w: certificate is not PEM encoded", ErrInvalidAuthority)
+	}
+
+	certificate, err := x509.ParseCertificate(certificateBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAuthority, err)
+	}
+
+	if !certificate.IsCA {
+		return nil, fmt.Errorf("%w: certificate is not a certificate authority", ErrInvalidAuthority)
+	}
+
+	keyBlock, _ := pem.Decode([]byte(privateKeyPem))
+	if keyBlock == nil || keyBlock.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%w: private key is not PEM encoded", ErrInvalidAuthority)
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAuthority, err)
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: private key can not sign", ErrInvalidAuthority)
+	}
+
+	return &Authority{Certificate: certificate, privateKey: signer}, nil
+}
+
+// Issue signs a certificate for the host, a domain or an ip address. It is valid for LeafValidity,
+// but never longer than the authority.
+func (authority *Authority) Issue(host string, now time.Time) (*tls.Certificate, error) {
+	if host == "" {
+		return nil, ErrInvalidHost
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the private key: %w", err)
+	}
+
+	serialNumber, err := randomSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	notAfter := now.Add(LeafValidity)
+	if notAfter.After(authority.Certificate.NotAfter) {
+		notAfter = authority.Certificate.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	encoded, err := x509.CreateCertificate(rand.Reader, template, authority.Certificate, privateKey.Public(), authority.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the certificate for %s: %w", host, err)
+	}
+
+	leaf, err := x509.ParseCertificate(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the certificate for %s: %w", host, err)
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{encoded, authority.Certificate.Raw},
+		PrivateKey:  privateKey,
+		Leaf:        leaf,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package proxyca
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAuthority(t *testing.T) {
+	now := time.Date(2024, 9, 1, 16, 0, 0, 0, time.UTC)
+
+	certificatePem, privateKeyPem, err := Generate("Home", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authority, err := Parse(certificatePem, privateKeyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(authority.Certificate)
+
+	for _, host := range []string{"www.youtube.com", "192.0.2.10"} {
+		leaf, err := authority.Issue(host, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = leaf.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, CurrentTime: now.Add(LeafValidity - time.Minute)})
+		if err != nil {
+			t.Fatalf("%s: %v", host, err)
+		}
+
+		_, err = leaf.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, CurrentTime: now.Add(LeafValidity + time.Minute)})
+		if err == nil {
+			t.Fatalf("%s: expected the certificate to expire after %v", host, LeafValidity)
+		}
+
+		_, err = leaf.Leaf.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots, CurrentTime: now})
+		if err == nil {
+			t.Fatalf("%s: expected the certificate to be valid for the host only", host)
+		}
+	}
+
+	// leaves can not sign further certificates
+	leaf, err := authority.Issue("www.youtube.com", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if leaf.Leaf.IsCA {
+		t.Fatal("Expected a leaf certificate")
+	}
+
+	_, err = Parse(certificatePem, "")
+	if !errors.Is(err, ErrInvalidAuthority) {
+		t.Fatalf("Expected %v, received %v", ErrInvalidAuthority, err)
+	}
+
+	_, err = authority.Issue("", now)
+	if !errors.Is(err, ErrInvalidHost) {
+		t.Fatalf("Expected %v, received %v", ErrInvalidHost, err)
+	}
+}
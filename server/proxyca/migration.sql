@@ -0,0 +1,6 @@
+CREATE TABLE proxy_authorities (
+    household_id INTEGER PRIMARY KEY REFERENCES households (id) ON DELETE CASCADE,
+    certificate_pem VARCHAR NOT NULL,
+    private_key_pem VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
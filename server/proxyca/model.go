@@ -0,0 +1,82 @@
+package proxyca
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Model is the certificate authority of a household. Households without one never have their https traffic
+// intercepted by the forward proxy.
+type Model struct {
+	HouseholdId    int
+	CertificatePem string
+	PrivateKeyPem  string
+	CreatedAt      time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+const selectColumns = "proxy_authorities.household_id, proxy_authorities.certificate_pem, proxy_authorities.private_key_pem, proxy_authorities.created_at"
+
+func findOne(db *sql.Tx, query string, args ...any) (*Model, error) {
+	model := &Model{}
+
+	err := db.QueryRow(query, args...).Scan(&model.HouseholdId, &model.CertificatePem, &model.PrivateKeyPem, &model.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return model, nil
+}
+
+func FindOneByHouseholdId(db *sql.Tx, householdId int) (*Model, error) {
+	return findOne(db, "SELECT "+selectColumns+" FROM proxy_authorities WHERE household_id = $1", householdId)
+}
+
+func FindOneByChildId(db *sql.Tx, childId int) (*Model, error) {
+	return findOne(
+		db,
+		"SELECT "+selectColumns+" FROM proxy_authorities INNER JOIN children ON children.household_id = proxy_authorities.household_id WHERE children.id = $1",
+		childId,
+	)
+}
+
+// Save replaces the authority of the household, devices trusting the previous one have to install the new certificate.
+func Save(db *sql.Tx, householdId int, certificatePem string, privateKeyPem string) error {
+	_, err := db.Exec(
+		`INSERT INTO proxy_authorities (household_id, certificate_pem, private_key_pem, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (household_id) DO UPDATE SET
+			certificate_pem = excluded.certificate_pem,
+			private_key_pem = excluded.private_key_pem,
+			created_at = excluded.created_at`,
+		householdId,
+		certificatePem,
+		privateKeyPem,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'INSERT INTO proxy_authorities ...': %w", err)
+	}
+
+	return nil
+}
+
+func DeleteByHouseholdId(db *sql.Tx, householdId int) (bool, error) {
+	executed, err := db.Exec("DELETE FROM proxy_authorities WHERE household_id = ?", householdId)
+	if err != nil {
+		return false, fmt.Errorf("an error occured while trying to execute query 'DELETE FROM proxy_authorities ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	return affectedRows > 0, nil
+}
@@ -0,0 +1,153 @@
+package push
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnauthorized = errors.New("server rejected the credentials of the device")
+
+// Client keeps a device connected to the events endpoint. After the stream ends it reconnects
+// with exponential backoff, sending the last received sequence number so missed events are replayed.
+type Client struct {
+	Url string
+	// Header is sent with every connection attempt, e.g. "Authorization: Device ...".
+	Header     http.Header
+	HttpClient *http.Client
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// LastSeq is the sequence number of the last handled event, 0 to start without replay.
+	LastSeq uint64
+}
+
+func NewClient(url string, header http.Header) *Client {
+	return &Client{
+		Url:        url,
+		Header:     header,
+		HttpClient: &http.Client{},
+		MinBackoff: RetryInterval,
+		MaxBackoff: 5 * time.Minute,
+	}
+}
+
+// Run calls handle for every event until ctx is done or the server rejects the device.
+// Events are handled at most once, duplicates delivered around a reconnect are skipped.
+func (client *Client) Run(ctx context.Context, handle func(Event)) error {
+	backoff := client.MinBackoff
+
+	for {
+		received, err := client.stream(ctx, handle)
+		if errors.Is(err, ErrUnauthorized) {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if received {
+			backoff = client.MinBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, client.MaxBackoff)
+	}
+}
+
+// stream reads one connection, it reports whether anything was received so a healthy connection resets the backoff.
+func (client *Client) stream(ctx context.Context, handle func(Event)) (bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, client.Url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	for name, values := range client.Header {
+		request.Header[name] = values
+	}
+
+	request.Header.Set("Accept", "text/event-stream")
+	if client.LastSeq != 0 {
+		request.Header.Set("Last-Event-ID", strconv.FormatUint(client.LastSeq, 10))
+	}
+
+	response, err := client.HttpClient.Do(request)
+	if err != nil {
+		return false, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusUnauthorized {
+		return false, ErrUnauthorized
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status of events stream: %d", response.StatusCode)
+	}
+
+	received := false
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var data strings.Builder
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		received = true
+
+		if line == "" {
+			if data.Len() > 0 {
+				client.dispatch(data.String(), handle)
+				data.Reset()
+			}
+
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+
+			data.WriteString(value)
+		case "retry":
+			if milliseconds, err := strconv.Atoi(value); err == nil && milliseconds > 0 {
+				client.MinBackoff = time.Duration(milliseconds) * time.Millisecond
+			}
+		}
+	}
+
+	return received, scanner.Err()
+}
+
+func (client *Client) dispatch(data string, handle func(Event)) {
+	var event Event
+
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return
+	}
+
+	if event.Type != EventTypeResync && event.Seq <= client.LastSeq {
+		return
+	}
+
+	client.LastSeq = event.Seq
+	handle(event)
+}
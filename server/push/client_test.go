@@ -0,0 +1,179 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func waitForSubscribers(t *testing.T, hub *Hub, childId int, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.SubscriberCount(childId) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Got %d subscribers, want %d", hub.SubscriberCount(childId), want)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func receiveHandled(t *testing.T, handled chan Event) Event {
+	t.Helper()
+
+	select {
+	case event := <-handled:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for handled event")
+	}
+
+	return Event{}
+}
+
+func TestClient(t *testing.T) {
+	RetryInterval = 10 * time.Millisecond
+	defer func() { RetryInterval = 2 * time.Second }()
+
+	t.Run("reconnects after dropped connection and receives missed events exactly once", func(t *testing.T) {
+		hub := NewHub(DefaultHistorySize)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = hub.ServeEvents(w, r, 1, 10)
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		client := NewClient(server.URL, http.Header{})
+		client.MaxBackoff = 50 * time.Millisecond
+
+		handled := make(chan Event, 16)
+		runErrCh := make(chan error, 1)
+		go func() {
+			runErrCh <- client.Run(ctx, func(event Event) { handled <- event })
+		}()
+
+		waitForSubscribers(t, hub, 1, 1)
+
+		first, _ := hub.Publish(1, 0, EventTypePolicyChanged, nil)
+		if event := receiveHandled(t, handled); event.Seq != first.Seq {
+			t.Fatalf("Got %d, want %d", event.Seq, first.Seq)
+		}
+
+		server.CloseClientConnections()
+		waitForSubscribers(t, hub, 1, 0)
+
+		second, _ := hub.Publish(1, 0, EventTypeAccessRequestDecided, nil)
+		third, _ := hub.Publish(1, 10, EventTypeTimeExtensionDecided, nil)
+
+		for _, want := range []Event{second, third} {
+			if event := receiveHandled(t, handled); event.Seq != want.Seq || event.Type != want.Type {
+				t.Errorf("Expected %+v, received %+v", want, event)
+			}
+		}
+
+		waitForSubscribers(t, hub, 1, 1)
+
+		fourth, _ := hub.Publish(1, 0, EventTypePolicyChanged, nil)
+		if event := receiveHandled(t, handled); event.Seq != fourth.Seq {
+			t.Errorf("Got %d, want %d", event.Seq, fourth.Seq)
+		}
+
+		select {
+		case event := <-handled:
+			t.Errorf("Expected no more events, received %+v", event)
+		default:
+		}
+
+		cancel()
+		if err := <-runErrCh; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v, received %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("skips events delivered twice", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, seq := range []int{5, 6, 6, 5, 7} {
+				fmt.Fprintf(w, "id: %d\nevent: policy_changed\ndata: {\"seq\":%d,\"type\":\"policy_changed\",\"childId\":1}\n\n", seq, seq)
+			}
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		client := NewClient(server.URL, http.Header{})
+		handled := make(chan Event, 16)
+
+		go func() {
+			_ = client.Run(ctx, func(event Event) { handled <- event })
+		}()
+
+		for _, want := range []uint64{5, 6, 7} {
+			if event := receiveHandled(t, handled); event.Seq != want {
+				t.Errorf("Got %d, want %d", event.Seq, want)
+			}
+		}
+
+		select {
+		case event := <-handled:
+			t.Errorf("Expected no more events, received %+v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("stops when the server rejects the device", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Device valid" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL, http.Header{"Authorization": []string{"Device invalid"}})
+
+		err := client.Run(context.Background(), func(event Event) {})
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("Expected %v, received %v", ErrUnauthorized, err)
+		}
+	})
+
+	t.Run("sends last event id when reconnecting", func(t *testing.T) {
+		lastEventIds := make(chan string, 4)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lastEventIds <- r.Header.Get("Last-Event-ID")
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "id: 42\ndata: {\"seq\":42,\"type\":\"policy_changed\",\"childId\":1}\n\n")
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		client := NewClient(server.URL, http.Header{})
+		go func() {
+			_ = client.Run(ctx, func(event Event) {})
+		}()
+
+		for _, want := range []string{"", "42"} {
+			select {
+			case received := <-lastEventIds:
+				if received != want {
+					t.Errorf("Expected %q, received %q", want, received)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Timed out waiting for connection")
+			}
+		}
+	})
+}
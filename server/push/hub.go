@@ -0,0 +1,194 @@
+package push
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	// EventTypeResync tells the device that events it missed are no longer available and it has to fetch its state again.
+	EventTypeResync               EventType = "resync"
+	EventTypePolicyChanged        EventType = "policy_changed"
+	EventTypeAccessRequestDecided EventType = "access_request_decided"
+	EventTypeTimeExtensionDecided EventType = "time_extension_decided"
+	// EventTypeCommand carries a command the device has to execute and acknowledge.
+	EventTypeCommand EventType = "command"
+)
+
+type Event struct {
+	Seq     uint64    `json:"seq"`
+	Type    EventType `json:"type"`
+	ChildId int       `json:"childId"`
+	// DeviceId is 0 for events meant for every device of the child.
+	DeviceId  int             `json:"deviceId,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// DefaultHistorySize is the number of events kept per child for replay after a reconnect.
+const DefaultHistorySize = 256
+
+// subscriptionBufferSize events may wait for a slow device before it is disconnected, it replays the rest after reconnecting.
+const subscriptionBufferSize = 64
+
+// Hub fans out events to the devices of a child connected to this server.
+// Sequence numbers start at the current unix time in microseconds, so they keep growing across restarts
+// and a device reconnecting after a restart is told to resync instead of waiting for events that are gone.
+type Hub struct {
+	mutex sync.Mutex
+	// startSeq is the sequence number the hub started with, devices with an older one saw events of a previous run.
+	startSeq    uint64
+	seq         uint64
+	historySize int
+	history     map[int][]Event
+	// trimmedUpTo is the sequence number of the newest event of the child dropped from the history.
+	trimmedUpTo map[int]uint64
+	subscribers map[int]map[*Subscription]struct{}
+}
+
+func NewHub(historySize int) *Hub {
+	startSeq := uint64(time.Now().UnixMicro())
+
+	return &Hub{
+		startSeq:    startSeq,
+		seq:         startSeq,
+		historySize: historySize,
+		history:     map[int][]Event{},
+		trimmedUpTo: map[int]uint64{},
+		subscribers: map[int]map[*Subscription]struct{}{},
+	}
+}
+
+type Subscription struct {
+	hub      *Hub
+	childId  int
+	deviceId int
+	events   chan Event
+}
+
+// Events is closed when the subscription is closed or the device could not keep up with the events.
+func (subscription *Subscription) Events() <-chan Event {
+	return subscription.events
+}
+
+func (subscription *Subscription) accepts(event Event) bool {
+	return event.DeviceId == 0 || event.DeviceId == subscription.deviceId
+}
+
+func (subscription *Subscription) Close() {
+	subscription.hub.mutex.Lock()
+	defer subscription.hub.mutex.Unlock()
+
+	subscription.hub.removeLocked(subscription)
+}
+
+func (hub *Hub) removeLocked(subscription *Subscription) {
+	subscribers := hub.subscribers[subscription.childId]
+	if _, found := subscribers[subscription]; !found {
+		return
+	}
+
+	delete(subscribers, subscription)
+	if len(subscribers) == 0 {
+		delete(hub.subscribers, subscription.childId)
+	}
+
+	close(subscription.events)
+}
+
+// Publish sends the event to connected devices of the child, deviceId 0 means all of them.
+func (hub *Hub) Publish(childId int, deviceId int, eventType EventType, data any) (Event, error) {
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode %s event data: %w", eventType, err)
+	}
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.seq++
+
+	event := Event{
+		Seq:       hub.seq,
+		Type:      eventType,
+		ChildId:   childId,
+		DeviceId:  deviceId,
+		Data:      encodedData,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	history := append(hub.history[childId], event)
+	if len(history) > hub.historySize {
+		trimmed := len(history) - hub.historySize
+		hub.trimmedUpTo[childId] = history[trimmed-1].Seq
+		history = append([]Event(nil), history[trimmed:]...)
+	}
+
+	hub.history[childId] = history
+
+	for subscription := range hub.subscribers[childId] {
+		if !subscription.accepts(event) {
+			continue
+		}
+
+		select {
+		case subscription.events <- event:
+		default:
+			hub.removeLocked(subscription)
+		}
+	}
+
+	return event, nil
+}
+
+// Subscribe returns the events the device missed since lastSeq followed by a subscription for new ones.
+// lastSeq 0 means a fresh start without replay. When some of the missed events were already dropped
+// from the history the replay is a single resync event.
+func (hub *Hub) Subscribe(childId int, deviceId int, lastSeq uint64) (*Subscription, []Event) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	subscription := &Subscription{
+		hub:      hub,
+		childId:  childId,
+		deviceId: deviceId,
+		events:   make(chan Event, subscriptionBufferSize),
+	}
+
+	if hub.subscribers[childId] == nil {
+		hub.subscribers[childId] = map[*Subscription]struct{}{}
+	}
+
+	hub.subscribers[childId][subscription] = struct{}{}
+
+	replay := make([]Event, 0)
+
+	if lastSeq == 0 {
+		return subscription, replay
+	}
+
+	if lastSeq < hub.startSeq || lastSeq < hub.trimmedUpTo[childId] || lastSeq > hub.seq {
+		replay = append(replay, Event{Seq: hub.seq, Type: EventTypeResync, ChildId: childId, DeviceId: deviceId, CreatedAt: time.Now().UTC()})
+		return subscription, replay
+	}
+
+	for _, event := range hub.history[childId] {
+		if event.Seq > lastSeq && subscription.accepts(event) {
+			replay = append(replay, event)
+		}
+	}
+
+	return subscription, replay
+}
+
+// SubscriberCount returns the number of connections of the child, used by tests and diagnostics.
+func (hub *Hub) SubscriberCount(childId int) int {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	return len(hub.subscribers[childId])
+}
@@ -0,0 +1,197 @@
+package push
+
+import (
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, subscription *Subscription) Event {
+	t.Helper()
+
+	select {
+	case event, open := <-subscription.Events():
+		if !open {
+			t.Fatal("Subscription was closed")
+		}
+
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+
+	return Event{}
+}
+
+func expectNoEvent(t *testing.T, subscription *Subscription) {
+	t.Helper()
+
+	select {
+	case event := <-subscription.Events():
+		t.Fatalf("Expected no event, received %+v", event)
+	default:
+	}
+}
+
+func TestHub(t *testing.T) {
+	t.Run("fans out child events to every device of the child", func(t *testing.T) {
+		hub := NewHub(DefaultHistorySize)
+
+		phone, _ := hub.Subscribe(1, 10, 0)
+		defer phone.Close()
+		laptop, _ := hub.Subscribe(1, 11, 0)
+		defer laptop.Close()
+		otherChild, _ := hub.Subscribe(2, 20, 0)
+		defer otherChild.Close()
+
+		published, err := hub.Publish(1, 0, EventTypePolicyChanged, map[string]string{"what": "safe_search"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, subscription := range []*Subscription{phone, laptop} {
+			event := receive(t, subscription)
+			if event.Seq != published.Seq || event.Type != EventTypePolicyChanged {
+				t.Errorf("Expected %+v, received %+v", published, event)
+			}
+		}
+
+		expectNoEvent(t, otherChild)
+	})
+
+	t.Run("device events reach only that device", func(t *testing.T) {
+		hub := NewHub(DefaultHistorySize)
+
+		phone, _ := hub.Subscribe(1, 10, 0)
+		defer phone.Close()
+		laptop, _ := hub.Subscribe(1, 11, 0)
+		defer laptop.Close()
+
+		_, err := hub.Publish(1, 11, EventTypeTimeExtensionDecided, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		event := receive(t, laptop)
+		if event.DeviceId != 11 {
+			t.Errorf("Got %d, want %d", event.DeviceId, 11)
+		}
+
+		expectNoEvent(t, phone)
+	})
+
+	t.Run("replays events missed since the last sequence number", func(t *testing.T) {
+		hub := NewHub(DefaultHistorySize)
+
+		first, _ := hub.Publish(1, 0, EventTypePolicyChanged, nil)
+		second, _ := hub.Publish(1, 0, EventTypeAccessRequestDecided, nil)
+		_, _ = hub.Publish(1, 99, EventTypeTimeExtensionDecided, nil)
+		third, _ := hub.Publish(1, 10, EventTypeTimeExtensionDecided, nil)
+
+		subscription, replay := hub.Subscribe(1, 10, first.Seq)
+		defer subscription.Close()
+
+		if len(replay) != 2 {
+			t.Fatalf("Got %d, want %d", len(replay), 2)
+		}
+
+		if replay[0].Seq != second.Seq || replay[1].Seq != third.Seq {
+			t.Errorf("Expected %d and %d, received %d and %d", second.Seq, third.Seq, replay[0].Seq, replay[1].Seq)
+		}
+	})
+
+	t.Run("does not replay anything on a fresh start", func(t *testing.T) {
+		hub := NewHub(DefaultHistorySize)
+
+		_, _ = hub.Publish(1, 0, EventTypePolicyChanged, nil)
+
+		subscription, replay := hub.Subscribe(1, 10, 0)
+		defer subscription.Close()
+
+		if len(replay) != 0 {
+			t.Errorf("Got %d, want %d", len(replay), 0)
+		}
+	})
+
+	t.Run("asks for resync when missed events were trimmed", func(t *testing.T) {
+		hub := NewHub(2)
+
+		first, _ := hub.Publish(1, 0, EventTypePolicyChanged, nil)
+		_, _ = hub.Publish(1, 0, EventTypePolicyChanged, nil)
+		_, _ = hub.Publish(1, 0, EventTypePolicyChanged, nil)
+		_, _ = hub.Publish(1, 0, EventTypePolicyChanged, nil)
+
+		subscription, replay := hub.Subscribe(1, 10, first.Seq)
+		defer subscription.Close()
+
+		if len(replay) != 1 || replay[0].Type != EventTypeResync {
+			t.Errorf("Expected single resync event, received %+v", replay)
+		}
+	})
+
+	t.Run("asks for resync when the device saw events of a previous server run", func(t *testing.T) {
+		previousHub := NewHub(DefaultHistorySize)
+
+		seen, _ := previousHub.Publish(1, 0, EventTypePolicyChanged, nil)
+
+		time.Sleep(time.Millisecond)
+
+		hub := NewHub(DefaultHistorySize)
+
+		subscription, replay := hub.Subscribe(1, 10, seen.Seq)
+		defer subscription.Close()
+
+		if len(replay) != 1 || replay[0].Type != EventTypeResync {
+			t.Errorf("Expected single resync event, received %+v", replay)
+		}
+	})
+
+	t.Run("asks for resync when the device reports a sequence number from the future", func(t *testing.T) {
+		hub := NewHub(DefaultHistorySize)
+
+		subscription, replay := hub.Subscribe(1, 10, uint64(time.Now().Add(time.Hour).UnixMicro()))
+		defer subscription.Close()
+
+		if len(replay) != 1 || replay[0].Type != EventTypeResync {
+			t.Errorf("Expected single resync event, received %+v", replay)
+		}
+	})
+
+	t.Run("drops slow subscriber without blocking others", func(t *testing.T) {
+		hub := NewHub(DefaultHistorySize)
+
+		slow, _ := hub.Subscribe(1, 10, 0)
+		defer slow.Close()
+
+		for range subscriptionBufferSize + 1 {
+			_, err := hub.Publish(1, 0, EventTypePolicyChanged, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if hub.SubscriberCount(1) != 0 {
+			t.Errorf("Got %d, want %d", hub.SubscriberCount(1), 0)
+		}
+
+		received := 0
+		for range slow.Events() {
+			received++
+		}
+
+		if received != subscriptionBufferSize {
+			t.Errorf("Got %d, want %d", received, subscriptionBufferSize)
+		}
+	})
+
+	t.Run("close removes subscriber and can be called twice", func(t *testing.T) {
+		hub := NewHub(DefaultHistorySize)
+
+		subscription, _ := hub.Subscribe(1, 10, 0)
+		subscription.Close()
+		subscription.Close()
+
+		if hub.SubscriberCount(1) != 0 {
+			t.Errorf("Got %d, want %d", hub.SubscriberCount(1), 0)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package push
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryInterval is sent to clients as the delay before they reconnect after the stream ends.
+var RetryInterval = 2 * time.Second
+
+// HeartbeatInterval keeps proxies from closing idle streams and lets the server notice dead connections.
+var HeartbeatInterval = 25 * time.Second
+
+var ErrStreamingNotSupported = errors.New("response writer does not support streaming")
+
+// lastSeqFromRequest reads the Last-Event-ID header sent by browsers on reconnect,
+// clients that can not set headers may use the lastEventId query parameter.
+func lastSeqFromRequest(r *http.Request) uint64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+
+	lastSeq, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return lastSeq
+}
+
+func writeEvent(w http.ResponseWriter, event Event) error {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %d: %w", event.Seq, err)
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, encoded)
+	return err
+}
+
+// ServeEvents streams events of the device as server-sent events until the client disconnects
+// or falls too far behind, in which case it reconnects and gets the rest from the replay.
+func (hub *Hub) ServeEvents(w http.ResponseWriter, r *http.Request, childId int, deviceId int) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return ErrStreamingNotSupported
+	}
+
+	subscription, replay := hub.Subscribe(childId, deviceId, lastSeqFromRequest(r))
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", RetryInterval.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	for _, event := range replay {
+		err = writeEvent(w, event)
+		if err != nil {
+			return err
+		}
+	}
+
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case event, open := <-subscription.Events():
+			if !open {
+				return nil
+			}
+
+			err = writeEvent(w, event)
+			if err != nil {
+				return err
+			}
+
+			flusher.Flush()
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+			if err != nil {
+				return err
+			}
+
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"domanscy.group/parental-controls/server/push"
+)
+
+type AccessRequestDecidedEvent struct {
+	Id     int    `json:"id"`
+	Domain string `json:"domain"`
+	Status string `json:"status"`
+	// ExpiresAt is null unless the request was approved.
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type PolicyChangedEvent struct {
+	// Setting is the part of the policy that changed, e.g. "safe_search" or "blocked_categories".
+	Setting string `json:"setting"`
+}
+
+// publishEvent is called after the change is committed, a failure only delays the device until its next resync.
+func publishEvent(pushHub *push.Hub, childId int, deviceId int, eventType push.EventType, data any) {
+	_, err := pushHub.Publish(childId, deviceId, eventType, data)
+	if err != nil {
+		log.Printf("error occured while trying to publish %s event for child %d: %v", eventType, childId, err)
+	}
+}
+
+// HttpDeviceEvents streams events of the authenticated device as server-sent events.
+// After a dropped connection the device reconnects with the Last-Event-ID header and gets the events it missed.
+func HttpDeviceEvents(_ *ServerConfig, pushHub *push.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device := authenticatedDevice(r)
+
+		err := pushHub.ServeEvents(w, r, device.ChildId, device.Id)
+		if errors.Is(err, push.ErrStreamingNotSupported) {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to stream events to device %d: %v", device.Id, err)
+		}
+		// other errors mean the device went away while writing, it reconnects on its own
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/push"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpDeviceEvents(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateDeviceToken(db)).Get("/device/events", HttpDeviceEvents(testingCfg, pushHub))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	t.Run("streams events of the child of the device", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		client := push.NewClient(server.URL+"/device/events", http.Header{"Authorization": []string{"Device " + family.deviceToken}})
+
+		handled := make(chan push.Event, 4)
+		go func() {
+			_ = client.Run(ctx, func(event push.Event) { handled <- event })
+		}()
+
+		deadline := time.Now().Add(2 * time.Second)
+		for pushHub.SubscriberCount(family.childId) != 1 {
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for the device to connect")
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		publishEvent(pushHub, stranger.childId, 0, push.EventTypePolicyChanged, PolicyChangedEvent{Setting: "safe_search"})
+		publishEvent(pushHub, family.childId, 0, push.EventTypePolicyChanged, PolicyChangedEvent{Setting: "blocked_categories"})
+
+		select {
+		case event := <-handled:
+			if event.ChildId != family.childId || string(event.Data) != `{"setting":"blocked_categories"}` {
+				t.Errorf("Expected blocked categories event of the child, received %+v", event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for event")
+		}
+	})
+
+	t.Run("returns 401 for invalid device token", func(t *testing.T) {
+		client := push.NewClient(server.URL+"/device/events", http.Header{"Authorization": []string{"Device invalid"}})
+
+		err := client.Run(context.Background(), func(event push.Event) {})
+		if !errors.Is(err, push.ErrUnauthorized) {
+			t.Errorf("Expected %v, received %v", push.ErrUnauthorized, err)
+		}
+	})
+}
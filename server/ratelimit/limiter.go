@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket per key. Every key starts with a full bucket of Burst tokens
+// which refills at Rate tokens per second.
+type Limiter struct {
+	mutex   sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[int]*bucket
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: map[int]*bucket{},
+	}
+}
+
+// Allow takes cost tokens from the bucket of the key. When there are not enough tokens nothing is taken
+// and the returned duration says how long the caller has to wait until the cost fits.
+func (limiter *Limiter) Allow(key int, cost int, now time.Time) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	keyBucket, found := limiter.buckets[key]
+	if !found {
+		keyBucket = &bucket{tokens: limiter.burst, updatedAt: now}
+		limiter.buckets[key] = keyBucket
+	}
+
+	if elapsed := now.Sub(keyBucket.updatedAt).Seconds(); elapsed > 0 {
+		keyBucket.tokens = math.Min(limiter.burst, keyBucket.tokens+elapsed*limiter.rate)
+		keyBucket.updatedAt = now
+	}
+
+	needed := float64(cost)
+	if needed > limiter.burst {
+		// a cost larger than the bucket would never fit, it has to wait for a full bucket instead
+		needed = limiter.burst
+	}
+
+	if keyBucket.tokens >= needed {
+		keyBucket.tokens -= needed
+		return true, 0
+	}
+
+	missing := needed - keyBucket.tokens
+
+	return false, time.Duration(math.Ceil(missing/limiter.rate*1000)) * time.Millisecond
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+
+	t.Run("allows burst and then asks to wait", func(t *testing.T) {
+		limiter := NewLimiter(10, 100)
+
+		allowed, _ := limiter.Allow(1, 100, now)
+		if !allowed {
+			t.Fatal("Expected the first burst to be allowed")
+		}
+
+		allowed, retryAfter := limiter.Allow(1, 20, now)
+		if allowed {
+			t.Fatal("Expected the second batch to be limited")
+		}
+
+		if retryAfter != 2*time.Second {
+			t.Errorf("Got %s, want %s", retryAfter, 2*time.Second)
+		}
+
+		allowed, _ = limiter.Allow(1, 20, now.Add(2*time.Second))
+		if !allowed {
+			t.Error("Expected the batch to be allowed after waiting")
+		}
+	})
+
+	t.Run("keeps separate buckets per key", func(t *testing.T) {
+		limiter := NewLimiter(1, 5)
+
+		limiter.Allow(1, 5, now)
+
+		allowed, _ := limiter.Allow(2, 5, now)
+		if !allowed {
+			t.Error("Expected another key to have a full bucket")
+		}
+	})
+
+	t.Run("cost larger than burst waits for a full bucket", func(t *testing.T) {
+		limiter := NewLimiter(1, 5)
+
+		allowed, _ := limiter.Allow(1, 50, now)
+		if !allowed {
+			t.Error("Expected oversized cost to take a full bucket")
+		}
+
+		allowed, retryAfter := limiter.Allow(1, 50, now)
+		if allowed || retryAfter != 5*time.Second {
+			t.Errorf("Expected to wait %s, received %t and %s", 5*time.Second, allowed, retryAfter)
+		}
+	})
+}
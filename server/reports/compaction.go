@@ -0,0 +1,215 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/activity"
+)
+
+// CompactionChunkDays is how many days of raw events of a child are rolled up in a single transaction,
+// ingestion waits for the transaction to finish, so it has to stay short.
+const CompactionChunkDays = 1
+
+// HourlyRollupRetention is how long hourly rollups are kept, daily rollups are kept as long as the child exists.
+// The domains a child visited are personal data and are kept no longer than the raw events, see compactNextChunk.
+const HourlyRollupRetention = 90 * 24 * time.Hour
+
+func toRollups(period activity.Period, buckets []DailyStats) []activity.Rollup {
+	rollups := make([]activity.Rollup, 0)
+
+	for _, stats := range buckets {
+		add := func(metric activity.Metric, name string, value int64) {
+			if value > 0 {
+				rollups = append(rollups, activity.Rollup{Period: period, BucketStart: stats.Date, Metric: metric, Name: name, Value: value})
+			}
+		}
+
+		add(activity.MetricScreenTime, "", int64(stats.ScreenTime))
+		add(activity.MetricBlockedAttempts, "", int64(stats.BlockedAttempts))
+
+		for app, duration := range stats.AppTimes {
+			add(activity.MetricAppTime, app, int64(duration))
+		}
+
+		for domain, count := range stats.DomainCounts {
+			add(activity.MetricDomainQueries, domain, int64(count))
+		}
+	}
+
+	return rollups
+}
+
+// compactNextChunk rolls up the next chunk of raw events of the child and reports whether the child is up to date.
+// Whole days are rolled up from the raw events at once, so daily rollups never depend on hourly ones.
+func compactNextChunk(db *sql.DB, childId int, location *time.Location, now time.Time) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	// starting with a write takes the write lock right away, so events ingested while the chunk is computed
+	// wait for it instead of making the transaction fail when it writes the rollups
+	err = activity.DeleteRollupsBefore(tx, childId, activity.PeriodHour, now.Add(-HourlyRollupRetention))
+	if err != nil {
+		return false, littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	state, err := activity.FindOneCompactionStateByChildId(tx, childId)
+	if err != nil || state == nil {
+		return true, littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	// past the retention of the household only totals are kept, the queried domains go with the raw events
+	err = activity.DeleteRollupsOfMetricBefore(tx, childId, activity.MetricDomainQueries, StartOfDay(now.AddDate(0, 0, -state.RetentionDays), location))
+	if err != nil {
+		return false, littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	target := now.Truncate(time.Hour)
+
+	from := state.CompactedUntil
+	if !state.DirtyFrom.IsZero() && state.DirtyFrom.Before(from) {
+		from = state.DirtyFrom
+	}
+
+	if from.IsZero() {
+		from, err = activity.FindFirstOccurredAtByChildId(tx, childId)
+		if err != nil {
+			return false, littlehelpers.IfErrJoin(err, tx.Rollback())
+		}
+
+		if from.IsZero() || from.After(target) {
+			from = target
+		}
+	}
+
+	from = StartOfDay(from, location)
+	if from.Before(state.FinalizedBefore) {
+		from = state.FinalizedBefore
+	}
+
+	to := from.AddDate(0, 0, CompactionChunkDays)
+	if to.After(target) {
+		to = target
+	}
+
+	if from.Before(to) {
+		events, err := activity.FindAllByChildIdBetween(tx, childId, from.Add(-MaxOpenInterval), to)
+		if err != nil {
+			return false, littlehelpers.IfErrJoin(err, tx.Rollback())
+		}
+
+		// sessions still open at the end of the chunk are counted until then and continued by the next chunk
+		hourRange := newBucketRange(from, to, nextHour)
+		aggregateActivity(events, hourRange, to)
+
+		dayRange := newBucketRange(from, to, nextDay)
+		aggregateActivity(events, dayRange, to)
+
+		err = activity.ReplaceRollups(tx, childId, activity.PeriodHour, from, to, toRollups(activity.PeriodHour, hourRange.buckets))
+		if err != nil {
+			return false, littlehelpers.IfErrJoin(err, tx.Rollback())
+		}
+
+		err = activity.ReplaceRollups(tx, childId, activity.PeriodDay, from, to, toRollups(activity.PeriodDay, dayRange.buckets))
+		if err != nil {
+			return false, littlehelpers.IfErrJoin(err, tx.Rollback())
+		}
+	}
+
+	if to.Before(state.CompactedUntil) {
+		state.DirtyFrom = to
+	} else {
+		state.CompactedUntil = to
+		state.DirtyFrom = time.Time{}
+	}
+
+	done := !to.Before(target)
+
+	if done {
+		// raw events are kept for at least the retention of the household and until their day is rolled up,
+		// together with the events a session running at the start of the day may have started with
+		finalizeBefore := now.AddDate(0, 0, -state.RetentionDays)
+		if rolledUpUntil := state.RolledUpUntil(); rolledUpUntil.Before(finalizeBefore) {
+			finalizeBefore = rolledUpUntil
+		}
+
+		finalizeBefore = StartOfDay(finalizeBefore, location)
+		if finalizeBefore.After(state.FinalizedBefore) {
+			state.FinalizedBefore = finalizeBefore
+		}
+
+		if !state.FinalizedBefore.IsZero() {
+			_, err := activity.DeleteAllByChildIdOccurredBefore(tx, childId, state.FinalizedBefore.Add(-MaxOpenInterval))
+			if err != nil {
+				return false, littlehelpers.IfErrJoin(err, tx.Rollback())
+			}
+		}
+	}
+
+	err = activity.SaveCompactionState(tx, state)
+	if err != nil {
+		return false, littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("failed to commit the transaction: %w", err)
+	}
+
+	return done, nil
+}
+
+func compactChild(ctx context.Context, db *sql.DB, childId int, location *time.Location, now time.Time) error {
+	for ctx.Err() == nil {
+		done, err := compactNextChunk(db, childId, location, now)
+		if err != nil {
+			return fmt.Errorf("failed to compact activity of child %d: %w", childId, err)
+		}
+
+		if done {
+			return nil
+		}
+	}
+
+	return ctx.Err()
+}
+
+// CompactActivity rolls up raw activity events of every child into hourly and daily rollups and deletes raw
+// events and the rollups of queried domains older than the retention of their household. Progress is stored after every chunk, so a run that
+// was interrupted or failed continues where it stopped. Events arriving for hours already rolled up make
+// the next run roll them up again.
+func CompactActivity(ctx context.Context, db *sql.DB, location *time.Location, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	states, err := activity.FindAllCompactionStates(tx)
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit the transaction: %w", err)
+	}
+
+	var errs error
+
+	for _, state := range states {
+		if ctx.Err() != nil {
+			return errors.Join(errs, ctx.Err())
+		}
+
+		// one child failing must not stop the compaction of the others
+		errs = errors.Join(errs, compactChild(ctx, db, state.ChildId, location, now))
+	}
+
+	return errs
+}
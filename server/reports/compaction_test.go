@@ -0,0 +1,336 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/database"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/rewards"
+	"domanscy.group/parental-controls/server/timeextensions"
+	"domanscy.group/parental-controls/server/users"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0001_users":                users.MigrationFile,
+		"0002_households":           households.MigrationFile,
+		"0003_children":             children.MigrationFile,
+		"0006_activity":             activity.MigrationFile,
+		"0007_children_safe_search": children.SafeSearchMigrationFile,
+		"0010_time_extensions":      timeextensions.MigrationFile,
+		"0012_activity_ingestion":   activity.IngestionMigrationFile,
+		"0014_households_retention": households.RetentionMigrationFile,
+		"0015_activity_rollups":     activity.RollupsMigrationFile,
+		"0022_rewards":              rewards.MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// createTestChild creates a child in a household keeping raw activity for retentionDays.
+func createTestChild(t *testing.T, db *sql.DB, retentionDays int) int {
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userId, err := users.Create(tx, "parent@localhost.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	householdId, err := households.Create(tx, userId, "Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = households.UpdateActivityRetentionDays(tx, householdId, retentionDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	childId, err := children.Create(tx, householdId, "Adam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return childId
+}
+
+func createTestActivity(t *testing.T, db *sql.DB, childId int, events []activity.Model) {
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, event := range events {
+		_, err := activity.Create(tx, childId, 0, event.Type, event.Payload, event.OccurredAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func computeTestDays(t *testing.T, db *sql.DB, childId int, from time.Time, days int, location *time.Location, now time.Time) []DailyStats {
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dailyStats, err := ComputeDaily(tx, childId, from, days, location, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dailyStats
+}
+
+func findTestCompactionState(t *testing.T, db *sql.DB, childId int) *activity.CompactionState {
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := activity.FindOneCompactionStateByChildId(tx, childId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return state
+}
+
+func countTestRawEvents(t *testing.T, db *sql.DB, childId int) int {
+	var count int
+
+	err := db.QueryRow("SELECT COUNT(*) FROM activity_events WHERE child_id = $1", childId).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return count
+}
+
+func TestCompactActivity(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	monday := time.Date(2024, 9, 2, 0, 0, 0, 0, location)
+	at := func(day int, hour int, minute int) time.Time {
+		return monday.AddDate(0, 0, day).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	events := []activity.Model{
+		testEvent(0, activity.TypeScreenOn, at(0, 16, 0), ""),
+		testEvent(0, activity.TypeAppStart, at(0, 16, 0), `{"app":"minecraft"}`),
+		testEvent(0, activity.TypeDnsQuery, at(0, 16, 10), `{"domain":"minecraft.net","queryType":"A","transport":"udp","blocked":false}`),
+		testEvent(0, activity.TypeDnsQuery, at(0, 16, 20), `{"domain":"casino.example","queryType":"A","transport":"udp","blocked":true}`),
+		testEvent(0, activity.TypeAppStop, at(0, 17, 30), `{"app":"minecraft"}`),
+		testEvent(0, activity.TypeScreenOff, at(0, 17, 30), ""),
+		// session running over midnight is split between the days
+		testEvent(0, activity.TypeScreenOn, at(0, 23, 0), ""),
+		testEvent(0, activity.TypeAppStart, at(0, 23, 0), `{"app":"firefox"}`),
+		testEvent(0, activity.TypeAppStop, at(1, 1, 0), `{"app":"firefox"}`),
+		testEvent(0, activity.TypeScreenOff, at(1, 1, 0), ""),
+	}
+
+	t.Run("reports read the same stats from rollups as from raw events", func(t *testing.T) {
+		db := openDatabase(t)
+		childId := createTestChild(t, db, households.DefaultActivityRetentionDays)
+		createTestActivity(t, db, childId, events)
+
+		now := at(3, 12, 0)
+		fromRaw := computeTestDays(t, db, childId, monday, 3, location, now)
+
+		err := CompactActivity(context.Background(), db, location, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		state := findTestCompactionState(t, db, childId)
+		if !state.CompactedUntil.Equal(now) || !state.DirtyFrom.IsZero() {
+			t.Fatalf("Unexpected compaction state: %+v", state)
+		}
+
+		fromRollups := computeTestDays(t, db, childId, monday, 3, location, now)
+		if !reflect.DeepEqual(fromRaw, fromRollups) {
+			t.Fatalf("Got %+v from rollups, want %+v", fromRollups, fromRaw)
+		}
+
+		if fromRollups[0].ScreenTime != 150*time.Minute || fromRollups[1].ScreenTime != time.Hour {
+			t.Fatalf("Got screen time %s and %s, want 2h30m and 1h", fromRollups[0].ScreenTime, fromRollups[1].ScreenTime)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hours, err := activity.FindAllRollupsByChildIdBetween(tx, childId, activity.PeriodHour, at(0, 17, 0), at(0, 18, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []activity.Rollup{
+			{ChildId: childId, Period: activity.PeriodHour, Metric: activity.MetricAppTime, Name: "minecraft", Value: int64(30 * time.Minute)},
+			{ChildId: childId, Period: activity.PeriodHour, Metric: activity.MetricScreenTime, Value: int64(30 * time.Minute)},
+		}
+
+		if len(hours) != len(want) {
+			t.Fatalf("Got %d hourly rollups, want %d: %+v", len(hours), len(want), hours)
+		}
+
+		for i := range want {
+			want[i].BucketStart = hours[i].BucketStart
+			if !hours[i].BucketStart.Equal(at(0, 17, 0)) || hours[i] != want[i] {
+				t.Fatalf("Got %+v, want %+v", hours[i], want[i])
+			}
+		}
+	})
+
+	t.Run("deletes raw events and domains older than the retention and keeps reports", func(t *testing.T) {
+		db := openDatabase(t)
+		childId := createTestChild(t, db, 1)
+		createTestActivity(t, db, childId, events)
+
+		now := at(5, 12, 0)
+		fromRaw := computeTestDays(t, db, childId, monday, 3, location, now)
+
+		err := CompactActivity(context.Background(), db, location, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if count := countTestRawEvents(t, db, childId); count != 0 {
+			t.Fatalf("Got %d raw events, want 0", count)
+		}
+
+		// only the domains the child queried are forgotten, the totals stay
+		for i := range fromRaw {
+			fromRaw[i].DomainCounts = map[string]int{}
+		}
+
+		fromRollups := computeTestDays(t, db, childId, monday, 3, location, now)
+		if !reflect.DeepEqual(fromRaw, fromRollups) {
+			t.Fatalf("Got %+v from rollups, want %+v", fromRollups, fromRaw)
+		}
+	})
+
+	t.Run("rolls up late events again", func(t *testing.T) {
+		db := openDatabase(t)
+		childId := createTestChild(t, db, households.DefaultActivityRetentionDays)
+		createTestActivity(t, db, childId, events)
+
+		now := at(3, 12, 0)
+
+		err := CompactActivity(context.Background(), db, location, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a device that was offline uploads what it collected
+		createTestActivity(t, db, childId, []activity.Model{
+			testEvent(0, activity.TypeBlock, at(1, 10, 0), `{"domain":"casino.example"}`),
+		})
+
+		state := findTestCompactionState(t, db, childId)
+		if !state.DirtyFrom.Equal(at(1, 10, 0)) {
+			t.Fatalf("Got dirty from %s, want %s", state.DirtyFrom, at(1, 10, 0))
+		}
+
+		err = CompactActivity(context.Background(), db, location, now.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		days := computeTestDays(t, db, childId, monday, 3, location, now.Add(time.Hour))
+		if days[1].BlockedAttempts != 1 {
+			t.Fatalf("Got %d blocked attempts, want 1", days[1].BlockedAttempts)
+		}
+
+		if state := findTestCompactionState(t, db, childId); !state.DirtyFrom.IsZero() {
+			t.Fatalf("Got dirty from %s, want none", state.DirtyFrom)
+		}
+	})
+
+	t.Run("continues an interrupted run", func(t *testing.T) {
+		db := openDatabase(t)
+		childId := createTestChild(t, db, households.DefaultActivityRetentionDays)
+		createTestActivity(t, db, childId, events)
+
+		now := at(3, 12, 0)
+
+		done, err := compactNextChunk(db, childId, location, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if done {
+			t.Fatal("Got done after the first chunk, want more chunks")
+		}
+
+		if state := findTestCompactionState(t, db, childId); !state.CompactedUntil.Equal(at(1, 0, 0)) {
+			t.Fatalf("Got compacted until %s, want %s", state.CompactedUntil, at(1, 0, 0))
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err = CompactActivity(ctx, db, location, now)
+		if err == nil {
+			t.Fatal("Got no error from a cancelled run")
+		}
+
+		err = CompactActivity(context.Background(), db, location, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if state := findTestCompactionState(t, db, childId); !state.CompactedUntil.Equal(now) {
+			t.Fatalf("Got compacted until %s, want %s", state.CompactedUntil, now)
+		}
+	})
+}
@@ -0,0 +1,452 @@
+package reports
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/rewards"
+	"domanscy.group/parental-controls/server/timeextensions"
+)
+
+// TopLimit is the number of apps and domains listed in reports.
+const TopLimit = 5
+
+// MaxDays limits a single report query.
+const MaxDays = 31
+
+// MaxOpenInterval caps a screen or app session that never got its closing event, e.g. because the device ran out of battery.
+// Sessions that started up to this long before a report range are counted too.
+const MaxOpenInterval = 4 * time.Hour
+
+var ErrInvalidDays = fmt.Errorf("days must be between 1 and %d", MaxDays)
+var ErrNoDays = errors.New("no days to summarize")
+
+type NamedDuration struct {
+	Name     string
+	Duration time.Duration
+}
+
+type NamedCount struct {
+	Name  string
+	Count int
+}
+
+type DailyStats struct {
+	// Date is the midnight the day starts at in the location of the report.
+	Date       time.Time
+	ScreenTime time.Duration
+	// AppTimes and DomainCounts hold every app and domain of the day, TopApps and TopDomains pick from them.
+	AppTimes        map[string]time.Duration
+	DomainCounts    map[string]int
+	BlockedAttempts int
+	TimeRequests    int
+	// GrantedMinutes is the extra time approved for requests made that day.
+	GrantedMinutes int
+	// EarnedMinutes is the extra time the child exchanged points of chores for that day.
+	EarnedMinutes int
+}
+
+func (stats *DailyStats) TopApps() []NamedDuration {
+	return topDurations(stats.AppTimes, TopLimit)
+}
+
+func (stats *DailyStats) TopDomains() []NamedCount {
+	return topCounts(stats.DomainCounts, TopLimit)
+}
+
+func topDurations(durations map[string]time.Duration, limit int) []NamedDuration {
+	top := make([]NamedDuration, 0, len(durations))
+
+	for name, duration := range durations {
+		top = append(top, NamedDuration{Name: name, Duration: duration})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Duration != top[j].Duration {
+			return top[i].Duration > top[j].Duration
+		}
+
+		return top[i].Name < top[j].Name
+	})
+
+	return top[:min(limit, len(top))]
+}
+
+func topCounts(counts map[string]int, limit int) []NamedCount {
+	top := make([]NamedCount, 0, len(counts))
+
+	for name, count := range counts {
+		top = append(top, NamedCount{Name: name, Count: count})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+
+		return top[i].Name < top[j].Name
+	})
+
+	return top[:min(limit, len(top))]
+}
+
+// StartOfDay returns the midnight of the day of t in the location.
+func StartOfDay(t time.Time, location *time.Location) time.Time {
+	year, month, day := t.In(location).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
+
+// bucketRange holds stats of consecutive buckets, days or hours, covering [from, to).
+// The Date of a bucket is the time it starts at.
+type bucketRange struct {
+	from    time.Time
+	to      time.Time
+	buckets []DailyStats
+}
+
+func newBucketRange(from time.Time, to time.Time, next func(start time.Time) time.Time) *bucketRange {
+	buckets := make([]DailyStats, 0)
+	for start := from; start.Before(to); start = next(start) {
+		buckets = append(buckets, DailyStats{
+			Date:         start,
+			AppTimes:     map[string]time.Duration{},
+			DomainCounts: map[string]int{},
+		})
+	}
+
+	return &bucketRange{
+		from:    from,
+		to:      to,
+		buckets: buckets,
+	}
+}
+
+func nextDay(start time.Time) time.Time {
+	return start.AddDate(0, 0, 1)
+}
+
+func nextHour(start time.Time) time.Time {
+	return start.Add(time.Hour)
+}
+
+func newDayRange(from time.Time, days int, location *time.Location) *bucketRange {
+	from = StartOfDay(from, location)
+	return newBucketRange(from, from.AddDate(0, 0, days), nextDay)
+}
+
+// index returns the index of the bucket t falls into, -1 when t is outside of the range.
+func (bucketRange *bucketRange) index(t time.Time) int {
+	if t.Before(bucketRange.from) || !t.Before(bucketRange.to) {
+		return -1
+	}
+
+	return sort.Search(len(bucketRange.buckets), func(i int) bool {
+		return bucketRange.buckets[i].Date.After(t)
+	}) - 1
+}
+
+// bucket returns the stats of the bucket t falls into, nil when t is outside of the range.
+func (bucketRange *bucketRange) bucket(t time.Time) *DailyStats {
+	i := bucketRange.index(t)
+	if i < 0 {
+		return nil
+	}
+
+	return &bucketRange.buckets[i]
+}
+
+func (bucketRange *bucketRange) end(i int) time.Time {
+	if i+1 < len(bucketRange.buckets) {
+		return bucketRange.buckets[i+1].Date
+	}
+
+	return bucketRange.to
+}
+
+// tail returns the part of the range starting at the bucket from falls into, sharing stats with the range.
+func (bucketRange *bucketRange) tail(from time.Time) *bucketRange {
+	i := bucketRange.index(from)
+
+	tail := *bucketRange
+	tail.from = bucketRange.buckets[i].Date
+	tail.buckets = bucketRange.buckets[i:]
+
+	return &tail
+}
+
+// addInterval splits [start, end) at bucket boundaries and passes every part within the range to add.
+func (bucketRange *bucketRange) addInterval(start time.Time, end time.Time, add func(stats *DailyStats, duration time.Duration)) {
+	if start.Before(bucketRange.from) {
+		start = bucketRange.from
+	}
+
+	if end.After(bucketRange.to) {
+		end = bucketRange.to
+	}
+
+	for start.Before(end) {
+		i := bucketRange.index(start)
+		if i < 0 {
+			return
+		}
+
+		partEnd := bucketRange.end(i)
+		if partEnd.After(end) {
+			partEnd = end
+		}
+
+		add(&bucketRange.buckets[i], partEnd.Sub(start))
+		start = partEnd
+	}
+}
+
+type sessionKey struct {
+	deviceId int
+	app      string
+}
+
+// Aggregate turns raw events of a child into daily stats. Events have to be sorted by the time they occurred
+// and should start MaxOpenInterval before from, so sessions running at midnight are counted. Sessions still
+// open are counted until now.
+func Aggregate(events []activity.Model, timeRequests []timeextensions.Model, from time.Time, days int, location *time.Location, now time.Time) []DailyStats {
+	dayRange := newDayRange(from, days, location)
+
+	aggregateActivity(events, dayRange, now)
+	addTimeRequests(timeRequests, dayRange)
+
+	return dayRange.buckets
+}
+
+func aggregateActivity(events []activity.Model, bucketRange *bucketRange, now time.Time) {
+	screenOnSince := map[int]time.Time{}
+	appStartedAt := map[sessionKey]time.Time{}
+
+	addScreenTime := func(start time.Time, end time.Time) {
+		if end.Sub(start) > MaxOpenInterval {
+			end = start.Add(MaxOpenInterval)
+		}
+
+		bucketRange.addInterval(start, end, func(stats *DailyStats, duration time.Duration) {
+			stats.ScreenTime += duration
+		})
+	}
+
+	addAppTime := func(app string, start time.Time, end time.Time) {
+		if end.Sub(start) > MaxOpenInterval {
+			end = start.Add(MaxOpenInterval)
+		}
+
+		bucketRange.addInterval(start, end, func(stats *DailyStats, duration time.Duration) {
+			stats.AppTimes[app] += duration
+		})
+	}
+
+	for _, event := range events {
+		switch event.Type {
+		case activity.TypeScreenOn:
+			if _, on := screenOnSince[event.DeviceId]; !on {
+				screenOnSince[event.DeviceId] = event.OccurredAt
+			}
+		case activity.TypeScreenOff:
+			if since, on := screenOnSince[event.DeviceId]; on {
+				addScreenTime(since, event.OccurredAt)
+				delete(screenOnSince, event.DeviceId)
+			}
+		case activity.TypeAppStart, activity.TypeAppStop:
+			payload := activity.AppPayload{}
+			if err := json.Unmarshal(event.Payload, &payload); err != nil || payload.App == "" {
+				continue
+			}
+
+			key := sessionKey{deviceId: event.DeviceId, app: payload.App}
+			startedAt, running := appStartedAt[key]
+
+			if event.Type == activity.TypeAppStart && !running {
+				appStartedAt[key] = event.OccurredAt
+			} else if event.Type == activity.TypeAppStop && running {
+				addAppTime(payload.App, startedAt, event.OccurredAt)
+				delete(appStartedAt, key)
+			}
+		case activity.TypeDnsQuery:
+			stats := bucketRange.bucket(event.OccurredAt)
+			if stats == nil {
+				continue
+			}
+
+			payload := activity.DnsQueryPayload{}
+			if err := json.Unmarshal(event.Payload, &payload); err != nil || payload.Domain == "" {
+				continue
+			}
+
+			if payload.Blocked {
+				stats.BlockedAttempts++
+			} else {
+				stats.DomainCounts[payload.Domain]++
+			}
+		case activity.TypeBlock:
+			if stats := bucketRange.bucket(event.OccurredAt); stats != nil {
+				stats.BlockedAttempts++
+			}
+		}
+	}
+
+	for _, since := range screenOnSince {
+		addScreenTime(since, now)
+	}
+
+	for key, startedAt := range appStartedAt {
+		addAppTime(key.app, startedAt, now)
+	}
+}
+
+func addTimeRequests(timeRequests []timeextensions.Model, bucketRange *bucketRange) {
+	for _, request := range timeRequests {
+		if stats := bucketRange.bucket(request.CreatedAt); stats != nil {
+			stats.TimeRequests++
+			stats.GrantedMinutes += request.GrantedMinutes
+		}
+	}
+}
+
+func addRedemptions(redemptions []rewards.LedgerEntry, bucketRange *bucketRange) {
+	for _, redemption := range redemptions {
+		if stats := bucketRange.bucket(redemption.CreatedAt); stats != nil {
+			stats.EarnedMinutes += redemption.Minutes
+		}
+	}
+}
+
+// addRollups adds stored rollups to the stats of the buckets they start in.
+func addRollups(rollups []activity.Rollup, bucketRange *bucketRange) {
+	for _, rollup := range rollups {
+		stats := bucketRange.bucket(rollup.BucketStart)
+		if stats == nil {
+			continue
+		}
+
+		switch rollup.Metric {
+		case activity.MetricScreenTime:
+			stats.ScreenTime += time.Duration(rollup.Value)
+		case activity.MetricAppTime:
+			stats.AppTimes[rollup.Name] += time.Duration(rollup.Value)
+		case activity.MetricDomainQueries:
+			stats.DomainCounts[rollup.Name] += int(rollup.Value)
+		case activity.MetricBlockedAttempts:
+			stats.BlockedAttempts += int(rollup.Value)
+		}
+	}
+}
+
+// ComputeDaily returns stats of the child for days starting at the day of from. Days that are rolled up
+// completely are read from daily rollups, the rest is aggregated from raw events.
+func ComputeDaily(db *sql.Tx, childId int, from time.Time, days int, location *time.Location, now time.Time) ([]DailyStats, error) {
+	if days < 1 || days > MaxDays {
+		return nil, ErrInvalidDays
+	}
+
+	dayRange := newDayRange(from, days, location)
+
+	compactionState, err := activity.FindOneCompactionStateByChildId(db, childId)
+	if err != nil {
+		return nil, err
+	}
+
+	rawFrom := dayRange.from
+	if compactionState != nil {
+		rolledUpUntil := compactionState.RolledUpUntil()
+		for rawFrom.Before(dayRange.to) && !rolledUpUntil.Before(nextDay(rawFrom)) {
+			rawFrom = nextDay(rawFrom)
+		}
+	}
+
+	if rawFrom.After(dayRange.from) {
+		rollups, err := activity.FindAllRollupsByChildIdBetween(db, childId, activity.PeriodDay, dayRange.from, rawFrom)
+		if err != nil {
+			return nil, err
+		}
+
+		addRollups(rollups, dayRange)
+	}
+
+	if rawFrom.Before(dayRange.to) {
+		events, err := activity.FindAllByChildIdBetween(db, childId, rawFrom.Add(-MaxOpenInterval), dayRange.to)
+		if err != nil {
+			return nil, err
+		}
+
+		if now.After(dayRange.to) {
+			now = dayRange.to
+		}
+
+		aggregateActivity(events, dayRange.tail(rawFrom), now)
+	}
+
+	timeRequests, err := timeextensions.FindAllByChildId(db, childId)
+	if err != nil {
+		return nil, err
+	}
+
+	addTimeRequests(timeRequests, dayRange)
+
+	redemptions, err := rewards.FindAllRedemptionsByChildIdBetween(db, childId, dayRange.from, dayRange.to)
+	if err != nil {
+		return nil, err
+	}
+
+	addRedemptions(redemptions, dayRange)
+
+	return dayRange.buckets, nil
+}
+
+// Summary adds up daily stats, e.g. of a week for the digest.
+type Summary struct {
+	From            time.Time
+	To              time.Time
+	ScreenTime      time.Duration
+	TopApps         []NamedDuration
+	TopDomains      []NamedCount
+	BlockedAttempts int
+	TimeRequests    int
+	GrantedMinutes  int
+	EarnedMinutes   int
+}
+
+func Summarize(days []DailyStats) (Summary, error) {
+	if len(days) == 0 {
+		return Summary{}, ErrNoDays
+	}
+
+	summary := Summary{
+		From: days[0].Date,
+		To:   days[len(days)-1].Date.AddDate(0, 0, 1),
+	}
+
+	appTimes := map[string]time.Duration{}
+	domainCounts := map[string]int{}
+
+	for _, day := range days {
+		summary.ScreenTime += day.ScreenTime
+		summary.BlockedAttempts += day.BlockedAttempts
+		summary.TimeRequests += day.TimeRequests
+		summary.GrantedMinutes += day.GrantedMinutes
+		summary.EarnedMinutes += day.EarnedMinutes
+
+		for app, duration := range day.AppTimes {
+			appTimes[app] += duration
+		}
+
+		for domain, count := range day.DomainCounts {
+			domainCounts[domain] += count
+		}
+	}
+
+	summary.TopApps = topDurations(appTimes, TopLimit)
+	summary.TopDomains = topCounts(domainCounts, TopLimit)
+
+	return summary, nil
+}
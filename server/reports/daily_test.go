@@ -0,0 +1,125 @@
+package reports
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/timeextensions"
+)
+
+func testEvent(deviceId int, eventType activity.Type, occurredAt time.Time, payload string) activity.Model {
+	if payload == "" {
+		payload = "{}"
+	}
+
+	return activity.Model{DeviceId: deviceId, Type: eventType, OccurredAt: occurredAt, Payload: json.RawMessage(payload)}
+}
+
+func TestAggregate(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	monday := time.Date(2024, 9, 2, 0, 0, 0, 0, location)
+	at := func(day int, hour int, minute int) time.Time {
+		return monday.AddDate(0, 0, day).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	events := []activity.Model{
+		// session running over midnight before the report is counted only from midnight
+		testEvent(1, activity.TypeScreenOn, at(-1, 23, 30), ""),
+		testEvent(1, activity.TypeScreenOff, at(0, 0, 30), ""),
+		testEvent(1, activity.TypeScreenOn, at(0, 16, 0), ""),
+		testEvent(1, activity.TypeAppStart, at(0, 16, 0), `{"app":"minecraft"}`),
+		testEvent(2, activity.TypeScreenOn, at(0, 16, 30), ""),
+		testEvent(2, activity.TypeAppStart, at(0, 16, 30), `{"app":"firefox"}`),
+		testEvent(1, activity.TypeAppStop, at(0, 17, 0), `{"app":"minecraft"}`),
+		testEvent(1, activity.TypeScreenOff, at(0, 17, 0), ""),
+		testEvent(2, activity.TypeAppStop, at(0, 16, 45), `{"app":"firefox"}`),
+		testEvent(2, activity.TypeScreenOff, at(0, 16, 45), ""),
+		testEvent(1, activity.TypeDnsQuery, at(0, 16, 1), `{"domain":"minecraft.net"}`),
+		testEvent(1, activity.TypeDnsQuery, at(0, 16, 2), `{"domain":"minecraft.net"}`),
+		testEvent(2, activity.TypeDnsQuery, at(0, 16, 31), `{"domain":"wikipedia.org"}`),
+		testEvent(2, activity.TypeDnsQuery, at(0, 16, 32), `{"domain":"casino.test","blocked":true}`),
+		testEvent(1, activity.TypeBlock, at(1, 10, 0), `{"app":"tiktok"}`),
+		// screen left on without screen_off is capped
+		testEvent(1, activity.TypeScreenOn, at(1, 20, 0), ""),
+	}
+
+	timeRequests := []timeextensions.Model{
+		{CreatedAt: at(0, 18, 0), Status: timeextensions.StatusApproved, GrantedMinutes: 30},
+		{CreatedAt: at(0, 19, 0), Status: timeextensions.StatusDenied},
+		{CreatedAt: at(5, 12, 0)},
+	}
+
+	days := Aggregate(events, timeRequests, monday, 2, location, at(7, 0, 0))
+
+	if len(days) != 2 {
+		t.Fatalf("Got %d, want %d", len(days), 2)
+	}
+
+	first := days[0]
+
+	if first.ScreenTime != 30*time.Minute+time.Hour+15*time.Minute {
+		t.Errorf("Got %s, want %s", first.ScreenTime, 30*time.Minute+time.Hour+15*time.Minute)
+	}
+
+	topApps := first.TopApps()
+	if len(topApps) != 2 || topApps[0] != (NamedDuration{"minecraft", time.Hour}) || topApps[1] != (NamedDuration{"firefox", 15 * time.Minute}) {
+		t.Errorf("Expected minecraft for an hour and firefox for 15 minutes, received %+v", topApps)
+	}
+
+	topDomains := first.TopDomains()
+	if len(topDomains) != 2 || topDomains[0] != (NamedCount{"minecraft.net", 2}) {
+		t.Errorf("Expected minecraft.net twice on top, received %+v", topDomains)
+	}
+
+	if first.BlockedAttempts != 1 || first.TimeRequests != 2 || first.GrantedMinutes != 30 {
+		t.Errorf("Expected 1 blocked attempt and 2 time requests with 30 minutes granted, received %+v", first)
+	}
+
+	second := days[1]
+
+	if second.ScreenTime != MaxOpenInterval {
+		t.Errorf("Got %s, want %s", second.ScreenTime, MaxOpenInterval)
+	}
+
+	if second.BlockedAttempts != 1 {
+		t.Errorf("Got %d, want %d", second.BlockedAttempts, 1)
+	}
+
+	summary, err := Summarize(days)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.ScreenTime != first.ScreenTime+second.ScreenTime || summary.BlockedAttempts != 2 || !summary.To.Equal(monday.AddDate(0, 0, 2)) {
+		t.Errorf("Expected summary of both days, received %+v", summary)
+	}
+}
+
+func TestAggregateSplitsSessionsAtMidnight(t *testing.T) {
+	location := time.UTC
+	from := time.Date(2024, 9, 2, 0, 0, 0, 0, location)
+
+	events := []activity.Model{
+		testEvent(1, activity.TypeScreenOn, from.Add(23*time.Hour), ""),
+		testEvent(1, activity.TypeScreenOff, from.Add(25*time.Hour), ""),
+	}
+
+	days := Aggregate(events, nil, from, 2, location, from.AddDate(0, 0, 2))
+
+	if days[0].ScreenTime != time.Hour || days[1].ScreenTime != time.Hour {
+		t.Errorf("Expected an hour on both days, received %s and %s", days[0].ScreenTime, days[1].ScreenTime)
+	}
+}
+
+func TestSummarizeWithoutDays(t *testing.T) {
+	_, err := Summarize(nil)
+	if err != ErrNoDays {
+		t.Errorf("Expected %v, received %v", ErrNoDays, err)
+	}
+}
@@ -0,0 +1,40 @@
+package reports
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//go:embed migration.sql
+var MigrationFile string
+
+// WasDigestSent reports whether the user already got the digest of the period starting at periodStart.
+func WasDigestSent(db *sql.Tx, userId int, periodStart time.Time) (bool, error) {
+	var sentAt time.Time
+
+	err := db.QueryRow("SELECT sent_at FROM digest_deliveries WHERE user_id = $1 AND period_start = $2", userId, periodStart.UTC()).Scan(&sentAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return true, nil
+}
+
+func RecordDigestSent(db *sql.Tx, userId int, periodStart time.Time, sentAt time.Time) error {
+	_, err := db.Exec(
+		"INSERT INTO digest_deliveries (user_id, period_start, sent_at) VALUES (?, ?, ?);",
+		userId,
+		periodStart.UTC(),
+		sentAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'INSERT INTO digest_deliveries ...': %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,6 @@
+CREATE TABLE digest_deliveries (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    period_start TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, period_start)
+);
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/reports"
+)
+
+const reportDateFormat = "2006-01-02"
+
+var ErrInvalidReportDate = errors.New("from must be a date in YYYY-MM-DD format")
+
+type AppTimeResponse struct {
+	App     string `json:"app"`
+	Seconds int64  `json:"seconds"`
+}
+
+type DomainCountResponse struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+type DailyReportResponse struct {
+	Date              string                `json:"date"`
+	ScreenTimeSeconds int64                 `json:"screenTimeSeconds"`
+	TopApps           []AppTimeResponse     `json:"topApps"`
+	TopDomains        []DomainCountResponse `json:"topDomains"`
+	BlockedAttempts   int                   `json:"blockedAttempts"`
+	TimeRequests      int                   `json:"timeRequests"`
+	GrantedMinutes    int                   `json:"grantedMinutes"`
+	EarnedMinutes     int                   `json:"earnedMinutes"`
+}
+
+func newDailyReportResponse(stats *reports.DailyStats) DailyReportResponse {
+	response := DailyReportResponse{
+		Date:              stats.Date.Format(reportDateFormat),
+		ScreenTimeSeconds: int64(stats.ScreenTime.Seconds()),
+		TopApps:           make([]AppTimeResponse, 0, reports.TopLimit),
+		TopDomains:        make([]DomainCountResponse, 0, reports.TopLimit),
+		BlockedAttempts:   stats.BlockedAttempts,
+		TimeRequests:      stats.TimeRequests,
+		GrantedMinutes:    stats.GrantedMinutes,
+		EarnedMinutes:     stats.EarnedMinutes,
+	}
+
+	for _, app := range stats.TopApps() {
+		response.TopApps = append(response.TopApps, AppTimeResponse{App: app.Name, Seconds: int64(app.Duration.Seconds())})
+	}
+
+	for _, domain := range stats.TopDomains() {
+		response.TopDomains = append(response.TopDomains, DomainCountResponse{Domain: domain.Name, Count: domain.Count})
+	}
+
+	return response
+}
+
+// HttpChildrenReportsDaily returns per day stats of the child, by default for the last 7 days including today.
+// Days start at midnight in the timezone of the reports.
+func HttpChildrenReportsDaily(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		now := time.Now()
+
+		days := 7
+		if rawDays := r.URL.Query().Get("days"); rawDays != "" {
+			days, err = strconv.Atoi(rawDays)
+			if err != nil || days < 1 || days > reports.MaxDays {
+				respondWith400(w, r, reports.ErrInvalidDays)
+				return
+			}
+		}
+
+		from := reports.StartOfDay(now, cfg.ReportsLocation).AddDate(0, 0, 1-days)
+		if rawFrom := r.URL.Query().Get("from"); rawFrom != "" {
+			from, err = time.ParseInLocation(reportDateFormat, rawFrom, cfg.ReportsLocation)
+			if err != nil {
+				respondWith400(w, r, ErrInvalidReportDate)
+				return
+			}
+		}
+
+		tx, err := db.BeginTx(r.Context(), &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child, err := children.FindOneByIdAndOwnerUserId(tx, childId, authenticatedUserId(r))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child: %v", err)
+			return
+		}
+
+		if child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrChildNotFound)
+			return
+		}
+
+		dailyStats, err := reports.ComputeDaily(tx, child.Id, from, days, cfg.ReportsLocation, now)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to compute daily reports: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := make([]DailyReportResponse, 0, len(dailyStats))
+		for i := range dailyStats {
+			response = append(response, newDailyReportResponse(&dailyStats[i]))
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mailpitsuite"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/activity"
+	"github.com/go-chi/chi"
+)
+
+// reportsTestDay is a Monday, so the digest sent the week after covers it.
+var reportsTestDay = time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC)
+
+func createTestReportEvents(t *testing.T, db *sql.DB, family testFamily) {
+	at := func(hour int, minute int) time.Time {
+		return reportsTestDay.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	events := []activity.IncomingEvent{
+		{Seq: 1, Type: activity.TypeScreenOn, OccurredAt: at(10, 0), Payload: json.RawMessage(`{}`)},
+		{Seq: 2, Type: activity.TypeAppStart, OccurredAt: at(10, 0), Payload: json.RawMessage(`{"app":"youtube"}`)},
+		{Seq: 3, Type: activity.TypeDnsQuery, OccurredAt: at(10, 5), Payload: json.RawMessage(`{"domain":"youtube.com","queryType":"A","transport":"udp","blocked":false}`)},
+		{Seq: 4, Type: activity.TypeDnsQuery, OccurredAt: at(10, 6), Payload: json.RawMessage(`{"domain":"casino.example","queryType":"A","transport":"udp","blocked":true}`)},
+		{Seq: 5, Type: activity.TypeAppStop, OccurredAt: at(11, 30), Payload: json.RawMessage(`{"app":"youtube"}`)},
+		{Seq: 6, Type: activity.TypeScreenOff, OccurredAt: at(12, 0), Payload: json.RawMessage(`{}`)},
+	}
+
+	tx, err := db.Begin()
+	doTFatalIfErr(t, err)
+
+	_, err = activity.CreateBatch(tx, family.childId, family.deviceId, events)
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, tx.Commit())
+}
+
+func TestHttpChildrenReportsDaily(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	createTestReportEvents(t, db, family)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/children/{childId}/reports/daily", HttpChildrenReportsDaily(testingCfg, db))
+
+	sendRequest := func(userId int, childId int, query string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:8080/children/%d/reports/daily?%s", childId, query), nil)
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("returns stats of every day", func(t *testing.T) {
+		recorder := sendRequest(family.userId, family.childId, "from=2026-01-05&days=2")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response []DailyReportResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if len(response) != 2 {
+			t.Fatalf("Got %d days, want 2", len(response))
+		}
+
+		day := response[0]
+		if day.Date != "2026-01-05" || day.ScreenTimeSeconds != 2*3600 || day.BlockedAttempts != 1 {
+			t.Fatalf("Unexpected first day: %+v", day)
+		}
+
+		if len(day.TopApps) != 1 || day.TopApps[0].App != "youtube" || day.TopApps[0].Seconds != 90*60 {
+			t.Fatalf("Unexpected top apps: %+v", day.TopApps)
+		}
+
+		if len(day.TopDomains) != 1 || day.TopDomains[0].Domain != "youtube.com" || day.TopDomains[0].Count != 1 {
+			t.Fatalf("Unexpected top domains: %+v", day.TopDomains)
+		}
+
+		if response[1].Date != "2026-01-06" || response[1].ScreenTimeSeconds != 0 || len(response[1].TopApps) != 0 {
+			t.Fatalf("Unexpected second day: %+v", response[1])
+		}
+	})
+
+	t.Run("rejects invalid days and dates", func(t *testing.T) {
+		for _, query := range []string{"days=0", "days=32", "days=abc", "from=05.01.2026"} {
+			recorder := sendRequest(family.userId, family.childId, query)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", query, recorder.Code, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("does not show reports of other parents children", func(t *testing.T) {
+		recorder := sendRequest(stranger.userId, family.childId, "from=2026-01-05")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestSendWeeklyDigests(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	createTestReportEvents(t, db, family)
+
+	mailpit := initializeMailpitAndDeleteAllMessages(t)
+	defer func(mailpit *mailpitsuite.Api) {
+		err := mailpit.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(mailpit)
+
+	now := reportsTestDay.AddDate(0, 0, 7).Add(8 * time.Hour)
+
+	doTFatalIfErr(t, sendWeeklyDigests(testingCfg, db, now))
+
+	messages, err := mailpit.GetAllMessages()
+	if err != nil {
+		t.Fatalf("failed to get mailpit messages: %s", err.Error())
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("Got %d messages, want 1", len(messages))
+	}
+
+	messageSummary, err := mailpit.GetMessageSummary(messages[0].ID)
+	if err != nil {
+		t.Fatalf("failed to get message summary: %s", err.Error())
+	}
+
+	for _, expected := range []string{"Adam", "2 godz. 0 min", "youtube", "1 godz. 30 min", "05.01.2026"} {
+		if !strings.Contains(messageSummary.HTML, expected) {
+			t.Fatalf("Digest does not contain %q: %s", expected, messageSummary.HTML)
+		}
+	}
+
+	// the job runs again after every restart, the parent must not get the same digest twice
+	doTFatalIfErr(t, sendWeeklyDigests(testingCfg, db, now.Add(time.Hour)))
+
+	messages, err = mailpit.GetAllMessages()
+	if err != nil {
+		t.Fatalf("failed to get mailpit messages: %s", err.Error())
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("Got %d messages after second run, want 1", len(messages))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+const requestIdContextKey contextKey = "requestId"
+
+const maxRequestIdLength = 128
+
+// AssignRequestId keeps the X-Request-Id header of the request, e.g. one set by a proxy in front of the server, or
+// generates a new id. The id is sent back in the X-Request-Id header and in error responses, see requestId.
+func AssignRequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+		if !isValidRequestId(id) {
+			id = newRequestId()
+		}
+
+		w.Header().Set("X-Request-Id", id)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIdContextKey, id)))
+	})
+}
+
+// requestId is empty for requests which did not go through AssignRequestId.
+func requestId(r *http.Request) string {
+	id, _ := r.Context().Value(requestIdContextKey).(string)
+	return id
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		log.Printf("error occured while trying to generate a request id: %v", err)
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// isValidRequestId accepts ids which can be written to logs and headers as they are.
+func isValidRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+
+	for _, c := range id {
+		isAlphanumeric := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+		if !isAlphanumeric && c != '-' && c != '_' && c != '.' && c != ':' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,341 @@
+package rewards
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxChorePoints limits the points of a single chore.
+const MaxChorePoints = 1000
+
+// MaxTitleLength is counted in characters, not bytes.
+const MaxTitleLength = 100
+
+// periodOnce is the only period of chores done a single time.
+const periodOnce = "once"
+
+var ErrInvalidTitle = fmt.Errorf("title can not be empty or longer than %d characters", MaxTitleLength)
+var ErrInvalidPoints = fmt.Errorf("points must be between 1 and %d", MaxChorePoints)
+var ErrInvalidRecurrence = errors.New("invalid recurrence")
+var ErrChoreAlreadyDone = errors.New("chore is already done in this period")
+var ErrCompletionWithThisIdDoesNotExist = errors.New("chore completion with this id does not exist")
+var ErrCompletionIsAlreadyDecided = errors.New("chore completion is already approved or rejected")
+
+type Recurrence string
+
+const (
+	RecurrenceOnce   Recurrence = "once"
+	RecurrenceDaily  Recurrence = "daily"
+	RecurrenceWeekly Recurrence = "weekly"
+)
+
+func (recurrence Recurrence) IsValid() bool {
+	return recurrence == RecurrenceOnce || recurrence == RecurrenceDaily || recurrence == RecurrenceWeekly
+}
+
+// Period returns the period now falls in, a chore can be done once in every period. Days start at midnight
+// and weeks on Monday in the location.
+func (recurrence Recurrence) Period(now time.Time, location *time.Location) string {
+	year, month, day := now.In(location).Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, location)
+
+	switch recurrence {
+	case RecurrenceDaily:
+		return today.Format(time.DateOnly)
+	case RecurrenceWeekly:
+		daysSinceMonday := (int(today.Weekday()) + 6) % 7
+		return today.AddDate(0, 0, -daysSinceMonday).Format(time.DateOnly)
+	default:
+		return periodOnce
+	}
+}
+
+type Chore struct {
+	Id         int
+	ChildId    int
+	Title      string
+	Points     int
+	Recurrence Recurrence
+	CreatedAt  time.Time
+}
+
+type CompletionStatus string
+
+const (
+	StatusPending  CompletionStatus = "pending"
+	StatusApproved CompletionStatus = "approved"
+	StatusRejected CompletionStatus = "rejected"
+)
+
+// Completion is a chore the child marked done, the points are added to the ledger when the parent approves it.
+type Completion struct {
+	Id      int
+	ChoreId int
+	ChildId int
+	Period  string
+	Status  CompletionStatus
+	// DecidedAt is zero while the completion is pending.
+	DecidedAt time.Time
+	CreatedAt time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+const selectChoreColumns = "id, child_id, title, points, recurrence, created_at"
+
+func scanChore(row interface{ Scan(dest ...any) error }) (*Chore, error) {
+	chore := &Chore{}
+
+	err := row.Scan(&chore.Id, &chore.ChildId, &chore.Title, &chore.Points, &chore.Recurrence, &chore.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return chore, nil
+}
+
+func FindOneChoreById(db *sql.Tx, id int) (*Chore, error) {
+	chore, err := scanChore(db.QueryRow("SELECT "+selectChoreColumns+" FROM chores WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return chore, nil
+}
+
+func FindAllChoresByChildId(db *sql.Tx, childId int) ([]Chore, error) {
+	rows, err := db.Query("SELECT "+selectChoreColumns+" FROM chores WHERE child_id = $1 ORDER BY id", childId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM chores ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	chores := make([]Chore, 0)
+
+	for rows.Next() {
+		chore, err := scanChore(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		chores = append(chores, *chore)
+	}
+
+	return chores, nil
+}
+
+func CreateChore(db *sql.Tx, childId int, title string, points int, recurrence Recurrence) (int, error) {
+	title = strings.TrimSpace(title)
+	if title == "" || utf8.RuneCountInString(title) > MaxTitleLength {
+		return 0, ErrInvalidTitle
+	}
+
+	if points < 1 || points > MaxChorePoints {
+		return 0, ErrInvalidPoints
+	}
+
+	if !recurrence.IsValid() {
+		return 0, ErrInvalidRecurrence
+	}
+
+	exec, err := db.Exec("INSERT INTO chores (child_id, title, points, recurrence) VALUES (?, ?, ?, ?);", childId, title, points, recurrence)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO chores ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// DeleteChore removes the chore with its completions, points already earned for it stay in the ledger.
+func DeleteChore(db *sql.Tx, childId int, id int) (bool, error) {
+	_, err := db.Exec(
+		"UPDATE points_ledger SET completion_id = NULL WHERE completion_id IN (SELECT id FROM chore_completions WHERE chore_id = ? AND child_id = ?)",
+		id,
+		childId,
+	)
+	if err != nil {
+		return false, fmt.Errorf("an error occured while trying to execute query 'UPDATE points_ledger ...': %w", err)
+	}
+
+	_, err = db.Exec("DELETE FROM chore_completions WHERE chore_id = ? AND child_id = ?", id, childId)
+	if err != nil {
+		return false, fmt.Errorf("an error occured while trying to execute query 'DELETE FROM chore_completions ...': %w", err)
+	}
+
+	executed, err := db.Exec("DELETE FROM chores WHERE id = ? AND child_id = ?", id, childId)
+	if err != nil {
+		return false, fmt.Errorf("an error occured while trying to execute query 'DELETE FROM chores ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	return affectedRows > 0, nil
+}
+
+const selectCompletionColumns = "id, chore_id, child_id, period, status, decided_at, created_at"
+
+func scanCompletion(row interface{ Scan(dest ...any) error }) (*Completion, error) {
+	completion := &Completion{}
+
+	var decidedAt sql.NullTime
+
+	err := row.Scan(&completion.Id, &completion.ChoreId, &completion.ChildId, &completion.Period, &completion.Status, &decidedAt, &completion.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	completion.DecidedAt = decidedAt.Time
+
+	return completion, nil
+}
+
+func FindOneCompletionById(db *sql.Tx, id int) (*Completion, error) {
+	completion, err := scanCompletion(db.QueryRow("SELECT "+selectCompletionColumns+" FROM chore_completions WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return completion, nil
+}
+
+// FindAllCompletionsByChildId returns the completions of the child, newest first.
+func FindAllCompletionsByChildId(db *sql.Tx, childId int) ([]Completion, error) {
+	rows, err := db.Query("SELECT "+selectCompletionColumns+" FROM chore_completions WHERE child_id = $1 ORDER BY created_at DESC, id DESC", childId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM chore_completions ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	completions := make([]Completion, 0)
+
+	for rows.Next() {
+		completion, err := scanCompletion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		completions = append(completions, *completion)
+	}
+
+	return completions, nil
+}
+
+// CreateCompletion marks the chore done in the period now falls in, see Recurrence.Period.
+func CreateCompletion(db *sql.Tx, chore *Chore, now time.Time, location *time.Location) (int, error) {
+	exec, err := db.Exec(
+		"INSERT INTO chore_completions (chore_id, child_id, period, created_at) VALUES (?, ?, ?, ?);",
+		chore.Id,
+		chore.ChildId,
+		chore.Recurrence.Period(now, location),
+		now.UTC(),
+	)
+	if err != nil {
+		if err.Error() == "UNIQUE constraint failed: chore_completions.chore_id, chore_completions.period" {
+			return 0, ErrChoreAlreadyDone
+		}
+
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO chore_completions ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+func decide(db *sql.Tx, id int, status CompletionStatus, decidedAt time.Time) (*Completion, error) {
+	completion, err := FindOneCompletionById(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if completion == nil {
+		return nil, ErrCompletionWithThisIdDoesNotExist
+	}
+
+	if completion.Status != StatusPending {
+		return nil, ErrCompletionIsAlreadyDecided
+	}
+
+	_, err = db.Exec(
+		"UPDATE chore_completions SET status = ?, decided_at = ? WHERE id = ? AND status = ?",
+		status,
+		decidedAt.UTC(),
+		id,
+		StatusPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("an error occured while trying to execute query 'UPDATE chore_completions ...': %w", err)
+	}
+
+	completion.Status = status
+	completion.DecidedAt = decidedAt.UTC()
+
+	return completion, nil
+}
+
+// ApproveCompletion adds the points of the chore to the ledger of the child.
+func ApproveCompletion(db *sql.Tx, id int, now time.Time) error {
+	completion, err := decide(db, id, StatusApproved, now)
+	if err != nil {
+		return err
+	}
+
+	chore, err := FindOneChoreById(db, completion.ChoreId)
+	if err != nil {
+		return err
+	}
+
+	if chore == nil {
+		return ErrCompletionWithThisIdDoesNotExist
+	}
+
+	_, err = addEntry(db, LedgerEntry{
+		ChildId:      completion.ChildId,
+		Kind:         KindChore,
+		Points:       chore.Points,
+		CompletionId: completion.Id,
+		Description:  chore.Title,
+		CreatedAt:    now,
+	})
+
+	return err
+}
+
+func RejectCompletion(db *sql.Tx, id int, now time.Time) error {
+	_, err := decide(db, id, StatusRejected, now)
+	return err
+}
@@ -0,0 +1,313 @@
+package rewards
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxExchangeRateValue limits both sides of an exchange rate.
+const MaxExchangeRateValue = 1000
+
+// MaxDailyLimitMinutes is a whole day.
+const MaxDailyLimitMinutes = 24 * 60
+
+var ErrInvalidAdjustment = fmt.Errorf("adjustment must be between -%d and %d points and not zero", MaxChorePoints, MaxChorePoints)
+var ErrInvalidDescription = fmt.Errorf("description can not be empty or longer than %d characters", MaxTitleLength)
+var ErrInvalidExchangeRate = fmt.Errorf("points and minutes must be between 1 and %d and the daily limit between 0 and %d minutes", MaxExchangeRateValue, MaxDailyLimitMinutes)
+var ErrNoExchangeRate = errors.New("exchange rate is not configured")
+var ErrInvalidRedemptionMinutes = errors.New("minutes must be a positive multiple of the minutes of the exchange rate")
+var ErrInsufficientPoints = errors.New("not enough points")
+var ErrDailyLimitReached = errors.New("daily limit of redeemed minutes would be exceeded")
+
+type Kind string
+
+const (
+	// KindChore are points of an approved chore completion.
+	KindChore Kind = "chore"
+	// KindRedemption are points exchanged for screen time, they are negative.
+	KindRedemption Kind = "redemption"
+	// KindAdjustment are points a parent added or took away.
+	KindAdjustment Kind = "adjustment"
+)
+
+type LedgerEntry struct {
+	Id      int
+	ChildId int
+	Kind    Kind
+	Points  int
+	// Minutes of screen time the entry granted, only redemptions grant any.
+	Minutes int
+	// CompletionId is zero for entries not earned with a chore, or when the chore was deleted.
+	CompletionId int
+	Description  string
+	CreatedAt    time.Time
+}
+
+// ExchangeRate converts Points into Minutes of screen time, at most DailyLimitMinutes a day unless it is zero.
+type ExchangeRate struct {
+	ChildId           int
+	Points            int
+	Minutes           int
+	DailyLimitMinutes int
+}
+
+const selectLedgerColumns = "id, child_id, kind, points, minutes, completion_id, description, created_at"
+
+func scanLedgerEntry(row interface{ Scan(dest ...any) error }) (*LedgerEntry, error) {
+	entry := &LedgerEntry{}
+
+	var completionId sql.NullInt64
+
+	err := row.Scan(&entry.Id, &entry.ChildId, &entry.Kind, &entry.Points, &entry.Minutes, &completionId, &entry.Description, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.CompletionId = int(completionId.Int64)
+
+	return entry, nil
+}
+
+func addEntry(db *sql.Tx, entry LedgerEntry) (int, error) {
+	var completionId sql.NullInt64
+	if entry.CompletionId != 0 {
+		completionId = sql.NullInt64{Int64: int64(entry.CompletionId), Valid: true}
+	}
+
+	exec, err := db.Exec(
+		"INSERT INTO points_ledger (child_id, kind, points, minutes, completion_id, description, created_at) VALUES (?, ?, ?, ?, ?, ?, ?);",
+		entry.ChildId,
+		entry.Kind,
+		entry.Points,
+		entry.Minutes,
+		completionId,
+		entry.Description,
+		entry.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO points_ledger ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// FindAllLedgerEntriesByChildId returns the entries of the child, newest first.
+func FindAllLedgerEntriesByChildId(db *sql.Tx, childId int) ([]LedgerEntry, error) {
+	rows, err := db.Query("SELECT "+selectLedgerColumns+" FROM points_ledger WHERE child_id = $1 ORDER BY created_at DESC, id DESC", childId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM points_ledger ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	entries := make([]LedgerEntry, 0)
+
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		entries = append(entries, *entry)
+	}
+
+	return entries, nil
+}
+
+// Balance sums every entry of the child, it is never stored.
+func Balance(db *sql.Tx, childId int) (int, error) {
+	var balance int
+
+	err := db.QueryRow("SELECT COALESCE(SUM(points), 0) FROM points_ledger WHERE child_id = $1", childId).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query 'SELECT SUM(points) FROM points_ledger ...': %w", err)
+	}
+
+	return balance, nil
+}
+
+// FindAllRedemptionsByChildIdBetween returns redemptions made in [from, to), oldest first.
+func FindAllRedemptionsByChildIdBetween(db *sql.Tx, childId int, from time.Time, to time.Time) ([]LedgerEntry, error) {
+	rows, err := db.Query(
+		"SELECT "+selectLedgerColumns+" FROM points_ledger WHERE child_id = $1 AND kind = $2 AND created_at >= $3 AND created_at < $4 ORDER BY created_at, id",
+		childId,
+		KindRedemption,
+		from.UTC(),
+		to.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM points_ledger ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	entries := make([]LedgerEntry, 0)
+
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		entries = append(entries, *entry)
+	}
+
+	return entries, nil
+}
+
+// RedeemedMinutesBetween sums the minutes redeemed in [from, to).
+func RedeemedMinutesBetween(db *sql.Tx, childId int, from time.Time, to time.Time) (int, error) {
+	var minutes int
+
+	err := db.QueryRow(
+		"SELECT COALESCE(SUM(minutes), 0) FROM points_ledger WHERE child_id = $1 AND kind = $2 AND created_at >= $3 AND created_at < $4",
+		childId,
+		KindRedemption,
+		from.UTC(),
+		to.UTC(),
+	).Scan(&minutes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query 'SELECT SUM(minutes) FROM points_ledger ...': %w", err)
+	}
+
+	return minutes, nil
+}
+
+// AddAdjustment lets a parent add or take away points, a balance can not go below zero with it.
+func AddAdjustment(db *sql.Tx, childId int, points int, description string, now time.Time) (int, error) {
+	if points == 0 || points < -MaxChorePoints || points > MaxChorePoints {
+		return 0, ErrInvalidAdjustment
+	}
+
+	description = strings.TrimSpace(description)
+	if description == "" || utf8.RuneCountInString(description) > MaxTitleLength {
+		return 0, ErrInvalidDescription
+	}
+
+	if points < 0 {
+		balance, err := Balance(db, childId)
+		if err != nil {
+			return 0, err
+		}
+
+		if balance+points < 0 {
+			return 0, ErrInsufficientPoints
+		}
+	}
+
+	return addEntry(db, LedgerEntry{
+		ChildId:     childId,
+		Kind:        KindAdjustment,
+		Points:      points,
+		Description: description,
+		CreatedAt:   now,
+	})
+}
+
+// Redeem exchanges points for minutes of screen time granted on the day now falls in.
+func Redeem(db *sql.Tx, childId int, minutes int, now time.Time, location *time.Location) (int, error) {
+	rate, err := FindOneExchangeRateByChildId(db, childId)
+	if err != nil {
+		return 0, err
+	}
+
+	if rate == nil {
+		return 0, ErrNoExchangeRate
+	}
+
+	if minutes <= 0 || minutes%rate.Minutes != 0 {
+		return 0, ErrInvalidRedemptionMinutes
+	}
+
+	points := minutes / rate.Minutes * rate.Points
+
+	balance, err := Balance(db, childId)
+	if err != nil {
+		return 0, err
+	}
+
+	if balance < points {
+		return 0, ErrInsufficientPoints
+	}
+
+	if rate.DailyLimitMinutes > 0 {
+		year, month, day := now.In(location).Date()
+		startOfDay := time.Date(year, month, day, 0, 0, 0, 0, location)
+
+		redeemed, err := RedeemedMinutesBetween(db, childId, startOfDay, startOfDay.AddDate(0, 0, 1))
+		if err != nil {
+			return 0, err
+		}
+
+		if redeemed+minutes > rate.DailyLimitMinutes {
+			return 0, ErrDailyLimitReached
+		}
+	}
+
+	return addEntry(db, LedgerEntry{
+		ChildId:     childId,
+		Kind:        KindRedemption,
+		Points:      -points,
+		Minutes:     minutes,
+		Description: fmt.Sprintf("%d min", minutes),
+		CreatedAt:   now,
+	})
+}
+
+func FindOneExchangeRateByChildId(db *sql.Tx, childId int) (*ExchangeRate, error) {
+	rate := &ExchangeRate{}
+
+	err := db.QueryRow("SELECT child_id, points, minutes, daily_limit_minutes FROM reward_exchange_rates WHERE child_id = $1", childId).
+		Scan(&rate.ChildId, &rate.Points, &rate.Minutes, &rate.DailyLimitMinutes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return rate, nil
+}
+
+// SaveExchangeRate applies to redemptions made after it, earlier ones keep the minutes they granted.
+func SaveExchangeRate(db *sql.Tx, rate ExchangeRate) error {
+	if rate.Points < 1 || rate.Points > MaxExchangeRateValue || rate.Minutes < 1 || rate.Minutes > MaxExchangeRateValue {
+		return ErrInvalidExchangeRate
+	}
+
+	if rate.DailyLimitMinutes < 0 || rate.DailyLimitMinutes > MaxDailyLimitMinutes {
+		return ErrInvalidExchangeRate
+	}
+
+	_, err := db.Exec(
+		"INSERT INTO reward_exchange_rates (child_id, points, minutes, daily_limit_minutes) VALUES (?, ?, ?, ?) ON CONFLICT (child_id) DO UPDATE SET points = excluded.points, minutes = excluded.minutes, daily_limit_minutes = excluded.daily_limit_minutes",
+		rate.ChildId,
+		rate.Points,
+		rate.Minutes,
+		rate.DailyLimitMinutes,
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'INSERT INTO reward_exchange_rates ...': %w", err)
+	}
+
+	return nil
+}
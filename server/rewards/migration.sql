@@ -0,0 +1,48 @@
+CREATE TABLE chores (
+    id INTEGER PRIMARY KEY,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    title VARCHAR NOT NULL,
+    points INTEGER NOT NULL,
+    recurrence VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE chore_completions (
+    id INTEGER PRIMARY KEY,
+    chore_id INTEGER NOT NULL REFERENCES chores (id) ON DELETE CASCADE,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    period VARCHAR NOT NULL,
+    status VARCHAR NOT NULL DEFAULT 'pending',
+    decided_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- a rejected chore can be marked done again in the same period
+CREATE UNIQUE INDEX chore_completions_period ON chore_completions (chore_id, period) WHERE status != 'rejected';
+CREATE INDEX chore_completions_child_id ON chore_completions (child_id, created_at);
+
+CREATE TABLE points_ledger (
+    id INTEGER PRIMARY KEY,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    kind VARCHAR NOT NULL,
+    points INTEGER NOT NULL,
+    minutes INTEGER NOT NULL DEFAULT 0,
+    completion_id INTEGER UNIQUE REFERENCES chore_completions (id) ON DELETE SET NULL,
+    description VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX points_ledger_child_id ON points_ledger (child_id, created_at);
+
+-- entries are corrected with new entries, only the link to a deleted chore completion may change
+CREATE TRIGGER points_ledger_append_only BEFORE UPDATE OF child_id, kind, points, minutes, description, created_at ON points_ledger
+BEGIN
+    SELECT RAISE(ABORT, 'points ledger is append only');
+END;
+
+CREATE TABLE reward_exchange_rates (
+    child_id INTEGER PRIMARY KEY REFERENCES children (id) ON DELETE CASCADE,
+    points INTEGER NOT NULL,
+    minutes INTEGER NOT NULL,
+    daily_limit_minutes INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,280 @@
+package rewards
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0022_rewards": MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestRecurrencePeriod(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sunday 23:30 in Warsaw, already Monday in Tokyo
+	now := time.Date(2024, 3, 10, 22, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		recurrence Recurrence
+		location   *time.Location
+		want       string
+	}{
+		{RecurrenceOnce, warsaw, "once"},
+		{RecurrenceDaily, time.UTC, "2024-03-10"},
+		{RecurrenceDaily, warsaw, "2024-03-10"},
+		{RecurrenceWeekly, warsaw, "2024-03-04"},
+		{RecurrenceWeekly, time.FixedZone("Tokyo", 9*60*60), "2024-03-11"},
+	}
+
+	for _, test := range tests {
+		if got := test.recurrence.Period(now, test.location); got != test.want {
+			t.Errorf("%s in %s: got %s, want %s", test.recurrence, test.location, got, test.want)
+		}
+	}
+}
+
+func TestChoresAndLedger(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	now := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+
+	t.Run("returns error for invalid chores", func(t *testing.T) {
+		invalid := []struct {
+			title      string
+			points     int
+			recurrence Recurrence
+			want       error
+		}{
+			{" ", 10, RecurrenceDaily, ErrInvalidTitle},
+			{strings.Repeat("ą", MaxTitleLength+1), 10, RecurrenceDaily, ErrInvalidTitle},
+			{"Dishes", 0, RecurrenceDaily, ErrInvalidPoints},
+			{"Dishes", MaxChorePoints + 1, RecurrenceDaily, ErrInvalidPoints},
+			{"Dishes", 10, "monthly", ErrInvalidRecurrence},
+		}
+
+		for _, test := range invalid {
+			_, err := CreateChore(tx, 1, test.title, test.points, test.recurrence)
+			if !errors.Is(err, test.want) {
+				t.Errorf("%+v: expected %v, received %v", test, test.want, err)
+			}
+		}
+	})
+
+	choreId, err := CreateChore(tx, 1, " Dishes ", 10, RecurrenceDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chore, err := FindOneChoreById(tx, choreId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if chore.Title != "Dishes" {
+		t.Fatalf("Got title %q, want it trimmed", chore.Title)
+	}
+
+	t.Run("allows a single completion in a period unless rejected", func(t *testing.T) {
+		rejectedId, err := CreateCompletion(tx, chore, now, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = CreateCompletion(tx, chore, now.Add(time.Hour), time.UTC)
+		if !errors.Is(err, ErrChoreAlreadyDone) {
+			t.Fatalf("Expected %v, received %v", ErrChoreAlreadyDone, err)
+		}
+
+		err = RejectCompletion(tx, rejectedId, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		approvedId, err := CreateCompletion(tx, chore, now.Add(time.Hour), time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ApproveCompletion(tx, approvedId, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, id := range []int{rejectedId, approvedId} {
+			err = ApproveCompletion(tx, id, now)
+			if !errors.Is(err, ErrCompletionIsAlreadyDecided) {
+				t.Errorf("Expected %v, received %v", ErrCompletionIsAlreadyDecided, err)
+			}
+		}
+
+		err = RejectCompletion(tx, 1000, now)
+		if !errors.Is(err, ErrCompletionWithThisIdDoesNotExist) {
+			t.Errorf("Expected %v, received %v", ErrCompletionWithThisIdDoesNotExist, err)
+		}
+
+		_, err = CreateCompletion(tx, chore, now.AddDate(0, 0, 1), time.UTC)
+		if err != nil {
+			t.Fatalf("Expected the chore to be done again the next day, received %v", err)
+		}
+	})
+
+	t.Run("approved chores add points to the balance", func(t *testing.T) {
+		balance, err := Balance(tx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if balance != 10 {
+			t.Fatalf("Got balance %d, want 10", balance)
+		}
+	})
+
+	t.Run("redeems points for minutes", func(t *testing.T) {
+		_, err := Redeem(tx, 1, 15, now, time.UTC)
+		if !errors.Is(err, ErrNoExchangeRate) {
+			t.Fatalf("Expected %v, received %v", ErrNoExchangeRate, err)
+		}
+
+		err = SaveExchangeRate(tx, ExchangeRate{ChildId: 1, Points: 0, Minutes: 15})
+		if !errors.Is(err, ErrInvalidExchangeRate) {
+			t.Fatalf("Expected %v, received %v", ErrInvalidExchangeRate, err)
+		}
+
+		err = SaveExchangeRate(tx, ExchangeRate{ChildId: 1, Points: 5, Minutes: 15, DailyLimitMinutes: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Redeem(tx, 1, 20, now, time.UTC)
+		if !errors.Is(err, ErrInvalidRedemptionMinutes) {
+			t.Fatalf("Expected %v, received %v", ErrInvalidRedemptionMinutes, err)
+		}
+
+		_, err = Redeem(tx, 1, 45, now, time.UTC)
+		if !errors.Is(err, ErrInsufficientPoints) {
+			t.Fatalf("Expected %v, received %v", ErrInsufficientPoints, err)
+		}
+
+		_, err = Redeem(tx, 1, 15, now, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = AddAdjustment(tx, 1, 20, "Birthday", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Redeem(tx, 1, 30, now, time.UTC)
+		if !errors.Is(err, ErrDailyLimitReached) {
+			t.Fatalf("Expected %v, received %v", ErrDailyLimitReached, err)
+		}
+
+		_, err = Redeem(tx, 1, 30, now.AddDate(0, 0, 1), time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		redeemed, err := RedeemedMinutesBetween(tx, 1, now.Truncate(24*time.Hour), now.Truncate(24*time.Hour).AddDate(0, 0, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if redeemed != 15 {
+			t.Fatalf("Got %d minutes redeemed today, want 15", redeemed)
+		}
+
+		balance, err := Balance(tx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if balance != 10-5+20-10 {
+			t.Fatalf("Got balance %d, want %d", balance, 10-5+20-10)
+		}
+	})
+
+	t.Run("adjustments can not make the balance negative", func(t *testing.T) {
+		_, err := AddAdjustment(tx, 1, -100, "Broken window", now)
+		if !errors.Is(err, ErrInsufficientPoints) {
+			t.Fatalf("Expected %v, received %v", ErrInsufficientPoints, err)
+		}
+
+		_, err = AddAdjustment(tx, 1, 0, "Nothing", now)
+		if !errors.Is(err, ErrInvalidAdjustment) {
+			t.Fatalf("Expected %v, received %v", ErrInvalidAdjustment, err)
+		}
+	})
+
+	t.Run("ledger is append only and outlives deleted chores", func(t *testing.T) {
+		_, err := tx.Exec("UPDATE points_ledger SET points = 1000")
+		if err == nil || !strings.Contains(err.Error(), "append only") {
+			t.Fatalf("Expected the update to be refused, received %v", err)
+		}
+
+		deleted, err := DeleteChore(tx, 1, choreId)
+		if err != nil || !deleted {
+			t.Fatalf("Expected the chore deleted, received %v", err)
+		}
+
+		entries, err := FindAllLedgerEntriesByChildId(tx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != 4 {
+			t.Fatalf("Got %d entries, want 4", len(entries))
+		}
+
+		earned := entries[len(entries)-1]
+		if earned.Kind != KindChore || earned.Points != 10 || earned.Description != "Dishes" || earned.CompletionId != 0 {
+			t.Fatalf("Unexpected entry: %+v", earned)
+		}
+
+		completions, err := FindAllCompletionsByChildId(tx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(completions) != 0 {
+			t.Fatalf("Got %d completions, want them deleted with the chore", len(completions))
+		}
+	})
+}
@@ -0,0 +1,827 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/rewards"
+	"domanscy.group/parental-controls/server/webhooks"
+	"github.com/go-chi/chi"
+)
+
+var ErrInvalidChoreId = errors.New("invalid chore id")
+var ErrChoreNotFound = errors.New("chore not found")
+var ErrInvalidChoreCompletionId = errors.New("invalid chore completion id")
+var ErrChoreCompletionNotFound = errors.New("chore completion not found")
+
+type ChoreResponse struct {
+	Id         int       `json:"id"`
+	ChildId    int       `json:"childId"`
+	Title      string    `json:"title"`
+	Points     int       `json:"points"`
+	Recurrence string    `json:"recurrence"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func newChoreResponse(chore *rewards.Chore) ChoreResponse {
+	return ChoreResponse{
+		Id:         chore.Id,
+		ChildId:    chore.ChildId,
+		Title:      chore.Title,
+		Points:     chore.Points,
+		Recurrence: string(chore.Recurrence),
+		CreatedAt:  chore.CreatedAt,
+	}
+}
+
+func newChoreResponses(chores []rewards.Chore) []ChoreResponse {
+	response := make([]ChoreResponse, 0, len(chores))
+
+	for i := range chores {
+		response = append(response, newChoreResponse(&chores[i]))
+	}
+
+	return response
+}
+
+type ChoreCompletionResponse struct {
+	Id        int        `json:"id"`
+	ChoreId   int        `json:"choreId"`
+	ChildId   int        `json:"childId"`
+	Period    string     `json:"period"`
+	Status    string     `json:"status"`
+	DecidedAt *time.Time `json:"decidedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func newChoreCompletionResponse(completion *rewards.Completion) ChoreCompletionResponse {
+	response := ChoreCompletionResponse{
+		Id:        completion.Id,
+		ChoreId:   completion.ChoreId,
+		ChildId:   completion.ChildId,
+		Period:    completion.Period,
+		Status:    string(completion.Status),
+		CreatedAt: completion.CreatedAt,
+	}
+
+	if !completion.DecidedAt.IsZero() {
+		response.DecidedAt = &completion.DecidedAt
+	}
+
+	return response
+}
+
+// DeviceChoreResponse is a chore with its completion in the current period, Status is empty when it is not done yet.
+type DeviceChoreResponse struct {
+	ChoreResponse
+	Status string `json:"status"`
+}
+
+type ExchangeRateResponse struct {
+	Points            int `json:"points"`
+	Minutes           int `json:"minutes"`
+	DailyLimitMinutes int `json:"dailyLimitMinutes"`
+}
+
+type LedgerEntryResponse struct {
+	Id           int       `json:"id"`
+	Kind         string    `json:"kind"`
+	Points       int       `json:"points"`
+	Minutes      int       `json:"minutes"`
+	CompletionId *int      `json:"completionId"`
+	Description  string    `json:"description"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type PointsResponse struct {
+	Balance int `json:"balance"`
+	// ExchangeRate is null until a parent sets it, points can not be redeemed before.
+	ExchangeRate *ExchangeRateResponse `json:"exchangeRate"`
+	Entries      []LedgerEntryResponse `json:"entries"`
+}
+
+// findPoints returns the balance of the child together with the ledger it is computed from.
+func findPoints(tx *sql.Tx, childId int) (*PointsResponse, error) {
+	balance, err := rewards.Balance(tx, childId)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := rewards.FindOneExchangeRateByChildId(tx, childId)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := rewards.FindAllLedgerEntriesByChildId(tx, childId)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PointsResponse{
+		Balance: balance,
+		Entries: make([]LedgerEntryResponse, 0, len(entries)),
+	}
+
+	if rate != nil {
+		response.ExchangeRate = &ExchangeRateResponse{Points: rate.Points, Minutes: rate.Minutes, DailyLimitMinutes: rate.DailyLimitMinutes}
+	}
+
+	for _, entry := range entries {
+		entryResponse := LedgerEntryResponse{
+			Id:          entry.Id,
+			Kind:        string(entry.Kind),
+			Points:      entry.Points,
+			Minutes:     entry.Minutes,
+			Description: entry.Description,
+			CreatedAt:   entry.CreatedAt,
+		}
+
+		if entry.CompletionId != 0 {
+			completionId := entry.CompletionId
+			entryResponse.CompletionId = &completionId
+		}
+
+		response.Entries = append(response.Entries, entryResponse)
+	}
+
+	return response, nil
+}
+
+type CreateChoreRequestBody struct {
+	Title      string `json:"title" validate:"required,length=..100"`
+	Points     int    `json:"points" validate:"required"`
+	Recurrence string `json:"recurrence" validate:"required,oneof=once daily weekly"`
+}
+
+func HttpChildrenChoresCreate(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreateChoreRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		choreId, err := rewards.CreateChore(tx, child.Id, requestBody.Title, requestBody.Points, rewards.Recurrence(requestBody.Recurrence))
+		if errors.Is(err, rewards.ErrInvalidTitle) || errors.Is(err, rewards.ErrInvalidPoints) || errors.Is(err, rewards.ErrInvalidRecurrence) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create chore: %v", err)
+			return
+		}
+
+		chore, err := rewards.FindOneChoreById(tx, choreId)
+		if err != nil || chore == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find created chore: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusCreated, newChoreResponse(chore))
+	}
+}
+
+func HttpChildrenChoresList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		chores, err := rewards.FindAllChoresByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find chores: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, newChoreResponses(chores))
+	}
+}
+
+// HttpChildrenChoresDelete removes the chore and its completions, points earned with it stay in the ledger.
+func HttpChildrenChoresDelete(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		choreId, err := strconv.Atoi(chi.URLParam(r, "choreId"))
+		if err != nil || choreId <= 0 {
+			respondWith400(w, r, ErrInvalidChoreId)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		deleted, err := rewards.DeleteChore(tx, child.Id, choreId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to delete chore: %v", err)
+			return
+		}
+
+		if !deleted {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrChoreNotFound)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+func HttpChildrenChoreCompletionsList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		completions, err := rewards.FindAllCompletionsByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find chore completions: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := make([]ChoreCompletionResponse, 0, len(completions))
+		for i := range completions {
+			response = append(response, newChoreCompletionResponse(&completions[i]))
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
+
+type DecideChoreCompletionRequestBody struct {
+	Decision string `json:"decision" validate:"required,oneof=approve deny"`
+}
+
+// HttpChildrenChoreCompletionsDecide approves or denies a chore the child marked done, approving adds its points
+// to the ledger.
+func HttpChildrenChoreCompletionsDecide(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		completionId, err := strconv.Atoi(chi.URLParam(r, "completionId"))
+		if err != nil || completionId <= 0 {
+			respondWith400(w, r, ErrInvalidChoreCompletionId)
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[DecideChoreCompletionRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		completion, err := rewards.FindOneCompletionById(tx, completionId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find chore completion: %v", err)
+			return
+		}
+
+		if completion == nil || completion.ChildId != child.Id {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrChoreCompletionNotFound)
+			return
+		}
+
+		now := time.Now()
+
+		if requestBody.Decision == "approve" {
+			err = rewards.ApproveCompletion(tx, completion.Id, now)
+		} else {
+			err = rewards.RejectCompletion(tx, completion.Id, now)
+		}
+
+		if errors.Is(err, rewards.ErrCompletionIsAlreadyDecided) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to decide chore completion: %v", err)
+			return
+		}
+
+		completion, err = rewards.FindOneCompletionById(tx, completion.Id)
+		if err != nil || completion == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find decided chore completion: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, newChoreCompletionResponse(completion))
+	}
+}
+
+func HttpChildrenPointsGet(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		points, err := findPoints(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find points: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, points)
+	}
+}
+
+type CreatePointsAdjustmentRequestBody struct {
+	Points      int    `json:"points" validate:"required"`
+	Description string `json:"description" validate:"required,length=..100"`
+}
+
+// HttpChildrenPointsAdjustmentsCreate adds or takes away points, the ledger is never edited.
+func HttpChildrenPointsAdjustmentsCreate(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreatePointsAdjustmentRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		_, err = rewards.AddAdjustment(tx, child.Id, requestBody.Points, requestBody.Description, time.Now())
+		if errors.Is(err, rewards.ErrInvalidAdjustment) || errors.Is(err, rewards.ErrInvalidDescription) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if errors.Is(err, rewards.ErrInsufficientPoints) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to add points adjustment: %v", err)
+			return
+		}
+
+		points, err := findPoints(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find points: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusCreated, points)
+	}
+}
+
+type UpdateExchangeRateRequestBody struct {
+	Points  int `json:"points" validate:"required"`
+	Minutes int `json:"minutes" validate:"required"`
+	// DailyLimitMinutes is 0 when redeemed minutes are not limited.
+	DailyLimitMinutes int `json:"dailyLimitMinutes"`
+}
+
+// HttpChildrenUpdateExchangeRate sets how many points buy how many minutes, redemptions made before keep their minutes.
+func HttpChildrenUpdateExchangeRate(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[UpdateExchangeRateRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		err = rewards.SaveExchangeRate(tx, rewards.ExchangeRate{
+			ChildId:           child.Id,
+			Points:            requestBody.Points,
+			Minutes:           requestBody.Minutes,
+			DailyLimitMinutes: requestBody.DailyLimitMinutes,
+		})
+		if errors.Is(err, rewards.ErrInvalidExchangeRate) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to save exchange rate: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+// HttpDeviceChoresList returns the chores of the child with their status in the current period, so the child sees
+// what is left to do.
+func HttpDeviceChoresList(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device := authenticatedDevice(r)
+		now := time.Now()
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		chores, err := rewards.FindAllChoresByChildId(tx, device.ChildId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find chores: %v", err)
+			return
+		}
+
+		completions, err := rewards.FindAllCompletionsByChildId(tx, device.ChildId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find chore completions: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := make([]DeviceChoreResponse, 0, len(chores))
+
+		for i := range chores {
+			choreResponse := DeviceChoreResponse{ChoreResponse: newChoreResponse(&chores[i])}
+			period := chores[i].Recurrence.Period(now, cfg.ReportsLocation)
+
+			// completions are sorted newest first, a rejected one is only shown when the chore was not done again
+			for _, completion := range completions {
+				if completion.ChoreId == chores[i].Id && completion.Period == period {
+					choreResponse.Status = string(completion.Status)
+					break
+				}
+			}
+
+			response = append(response, choreResponse)
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
+
+// HttpDeviceChoreCompletionsCreate marks a chore done, a parent has to approve it before the points are added.
+func HttpDeviceChoreCompletionsCreate(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device := authenticatedDevice(r)
+
+		choreId, err := strconv.Atoi(chi.URLParam(r, "choreId"))
+		if err != nil || choreId <= 0 {
+			respondWith400(w, r, ErrInvalidChoreId)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		chore, err := rewards.FindOneChoreById(tx, choreId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find chore: %v", err)
+			return
+		}
+
+		if chore == nil || chore.ChildId != device.ChildId {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrChoreNotFound)
+			return
+		}
+
+		completionId, err := rewards.CreateCompletion(tx, chore, time.Now(), cfg.ReportsLocation)
+		if errors.Is(err, rewards.ErrChoreAlreadyDone) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create chore completion: %v", err)
+			return
+		}
+
+		completion, err := rewards.FindOneCompletionById(tx, completionId)
+		if err != nil || completion == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find created chore completion: %v", err)
+			return
+		}
+
+		child, err := children.FindOneById(tx, device.ChildId)
+		if err != nil || child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child of the device: %v", err)
+			return
+		}
+
+		completionResponse := newChoreCompletionResponse(completion)
+
+		err = enqueueWebhookEvent(tx, child, webhooks.EventChoreCompleted, "", WebhookEventData{ChoreCompletion: &completionResponse}, time.Now())
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to enqueue webhook event: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusCreated, completionResponse)
+	}
+}
+
+func HttpDevicePointsGet(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device := authenticatedDevice(r)
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		points, err := findPoints(tx, device.ChildId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find points: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, points)
+	}
+}
+
+type CreatePointsRedemptionRequestBody struct {
+	Minutes int `json:"minutes" validate:"required"`
+}
+
+// HttpDevicePointsRedemptionsCreate exchanges points for screen time of today, devices of the child are told to fetch
+// their budget again.
+func HttpDevicePointsRedemptionsCreate(cfg *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device := authenticatedDevice(r)
+
+		requestBody, err := decodeJsonRequestBody[CreatePointsRedemptionRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		_, err = rewards.Redeem(tx, device.ChildId, requestBody.Minutes, time.Now(), cfg.ReportsLocation)
+		if errors.Is(err, rewards.ErrInvalidRedemptionMinutes) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if errors.Is(err, rewards.ErrNoExchangeRate) || errors.Is(err, rewards.ErrInsufficientPoints) || errors.Is(err, rewards.ErrDailyLimitReached) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to redeem points: %v", err)
+			return
+		}
+
+		points, err := findPoints(tx, device.ChildId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find points: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishEvent(pushHub, device.ChildId, 0, push.EventTypePolicyChanged, PolicyChangedEvent{Setting: "screen_time"})
+
+		respondWithJson(w, r, http.StatusCreated, points)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"domanscy.group/parental-controls/server/push"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpRewards(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/children/{childId}/chores", HttpChildrenChoresCreate(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/children/{childId}/chores", HttpChildrenChoresList(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Delete("/children/{childId}/chores/{choreId}", HttpChildrenChoresDelete(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/children/{childId}/chore_completions", HttpChildrenChoreCompletionsList(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/children/{childId}/chore_completions/{completionId}/decision", HttpChildrenChoreCompletionsDecide(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/children/{childId}/points", HttpChildrenPointsGet(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/children/{childId}/points/adjustments", HttpChildrenPointsAdjustmentsCreate(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Put("/children/{childId}/exchange_rate", HttpChildrenUpdateExchangeRate(testingCfg, db))
+	router.With(AuthenticateDeviceToken(db)).Get("/device/chores", HttpDeviceChoresList(testingCfg, db))
+	router.With(AuthenticateDeviceToken(db)).Post("/device/chores/{choreId}/completions", HttpDeviceChoreCompletionsCreate(testingCfg, db))
+	router.With(AuthenticateDeviceToken(db)).Get("/device/points", HttpDevicePointsGet(testingCfg, db))
+	router.With(AuthenticateDeviceToken(db)).Post("/device/points/redemptions", HttpDevicePointsRedemptionsCreate(testingCfg, pushHub, db))
+	router.With(AuthenticateDeviceToken(db)).Get("/device/screen_time", HttpDeviceScreenTime(testingCfg, db))
+
+	sendParentRequest := func(userId int, method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	sendDeviceRequest := func(deviceToken string, method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(body))
+		request.Header.Set("Authorization", "Device "+deviceToken)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	childPath := fmt.Sprintf("/children/%d", family.childId)
+
+	var chore ChoreResponse
+
+	t.Run("creates chores", func(t *testing.T) {
+		invalid := []string{
+			`{"title": "", "points": 10, "recurrence": "daily"}`,
+			`{"title": "Dishes", "points": 0, "recurrence": "daily"}`,
+			`{"title": "Dishes", "points": 10, "recurrence": "monthly"}`,
+		}
+
+		for _, body := range invalid {
+			recorder := sendParentRequest(family.userId, http.MethodPost, childPath+"/chores", body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+
+		recorder := sendParentRequest(stranger.userId, http.MethodPost, childPath+"/chores", `{"title": "Dishes", "points": 10, "recurrence": "daily"}`)
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, childPath+"/chores", `{"title": "Dishes", "points": 10, "recurrence": "daily"}`)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &chore))
+
+		if chore.Title != "Dishes" || chore.Points != 10 || chore.Recurrence != "daily" {
+			t.Fatalf("Unexpected response: %+v", chore)
+		}
+	})
+
+	var completion ChoreCompletionResponse
+
+	t.Run("child marks chores done once a period", func(t *testing.T) {
+		recorder := sendDeviceRequest(stranger.deviceToken, http.MethodPost, fmt.Sprintf("/device/chores/%d/completions", chore.Id), "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendDeviceRequest(family.deviceToken, http.MethodPost, fmt.Sprintf("/device/chores/%d/completions", chore.Id), "")
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &completion))
+
+		if completion.Status != "pending" || completion.DecidedAt != nil {
+			t.Fatalf("Unexpected response: %+v", completion)
+		}
+
+		recorder = sendDeviceRequest(family.deviceToken, http.MethodPost, fmt.Sprintf("/device/chores/%d/completions", chore.Id), "")
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusConflict)
+		}
+
+		recorder = sendDeviceRequest(family.deviceToken, http.MethodGet, "/device/chores", "")
+
+		var chores []DeviceChoreResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &chores))
+
+		if len(chores) != 1 || chores[0].Id != chore.Id || chores[0].Status != "pending" {
+			t.Fatalf("Unexpected response: %+v", chores)
+		}
+	})
+
+	t.Run("parent approves completions once", func(t *testing.T) {
+		decisionPath := fmt.Sprintf("%s/chore_completions/%d/decision", childPath, completion.Id)
+
+		recorder := sendParentRequest(family.userId, http.MethodPost, decisionPath, `{"decision": "maybe"}`)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		recorder = sendParentRequest(stranger.userId, http.MethodPost, fmt.Sprintf("/children/%d/chore_completions/%d/decision", stranger.childId, completion.Id), `{"decision": "approve"}`)
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, decisionPath, `{"decision": "approve"}`)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, decisionPath, `{"decision": "deny"}`)
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusConflict)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, childPath+"/points", "")
+
+		var points PointsResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &points))
+
+		if points.Balance != 10 || points.ExchangeRate != nil || len(points.Entries) != 1 || *points.Entries[0].CompletionId != completion.Id {
+			t.Fatalf("Unexpected response: %+v", points)
+		}
+	})
+
+	t.Run("child redeems points for screen time", func(t *testing.T) {
+		recorder := sendDeviceRequest(family.deviceToken, http.MethodPost, "/device/points/redemptions", `{"minutes": 15}`)
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("Got %d without an exchange rate, want %d", recorder.Code, http.StatusConflict)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPut, childPath+"/exchange_rate", `{"points": 5, "minutes": 15, "dailyLimitMinutes": 2000}`)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPut, childPath+"/exchange_rate", `{"points": 5, "minutes": 15, "dailyLimitMinutes": 60}`)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		recorder = sendDeviceRequest(family.deviceToken, http.MethodPost, "/device/points/redemptions", `{"minutes": 10}`)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		recorder = sendDeviceRequest(family.deviceToken, http.MethodPost, "/device/points/redemptions", `{"minutes": 45}`)
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("Got %d with too few points, want %d", recorder.Code, http.StatusConflict)
+		}
+
+		subscription, _ := pushHub.Subscribe(family.childId, family.deviceId, 0)
+		defer subscription.Close()
+
+		recorder = sendDeviceRequest(family.deviceToken, http.MethodPost, "/device/points/redemptions", `{"minutes": 30}`)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		var points PointsResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &points))
+
+		if points.Balance != 0 || points.Entries[0].Kind != "redemption" || points.Entries[0].Minutes != 30 {
+			t.Fatalf("Unexpected response: %+v", points)
+		}
+
+		event := <-subscription.Events()
+		if event.Type != push.EventTypePolicyChanged || string(event.Data) != `{"setting":"screen_time"}` {
+			t.Fatalf("Unexpected event: %+v", event)
+		}
+
+		recorder = sendDeviceRequest(family.deviceToken, http.MethodGet, "/device/screen_time", "")
+
+		var screenTime ScreenTimeResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &screenTime))
+
+		if screenTime.GrantedMinutes != 30 || screenTime.EarnedMinutes != 30 {
+			t.Fatalf("Got %+v, want the redeemed minutes added to the budget", screenTime)
+		}
+	})
+
+	t.Run("parent adjusts points", func(t *testing.T) {
+		recorder := sendParentRequest(family.userId, http.MethodPost, childPath+"/points/adjustments", `{"points": -5, "description": "Broken window"}`)
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusConflict)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, childPath+"/points/adjustments", `{"points": 20, "description": ""}`)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, childPath+"/points/adjustments", `{"points": 20, "description": "Birthday"}`)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		recorder = sendDeviceRequest(family.deviceToken, http.MethodGet, "/device/points", "")
+
+		var points PointsResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &points))
+
+		if points.Balance != 20 || len(points.Entries) != 3 || points.ExchangeRate == nil || points.ExchangeRate.DailyLimitMinutes != 60 {
+			t.Fatalf("Unexpected response: %+v", points)
+		}
+	})
+
+	t.Run("deletes chores and keeps the ledger", func(t *testing.T) {
+		recorder := sendParentRequest(family.userId, http.MethodDelete, fmt.Sprintf("%s/chores/%d", childPath, chore.Id), "")
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodDelete, fmt.Sprintf("%s/chores/%d", childPath, chore.Id), "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, childPath+"/chore_completions", "")
+		if strings.TrimSpace(recorder.Body.String()) != "[]" {
+			t.Fatalf("Got %s, want the completions deleted", recorder.Body.String())
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, childPath+"/points", "")
+
+		var points PointsResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &points))
+
+		if points.Balance != 20 || len(points.Entries) != 3 {
+			t.Fatalf("Unexpected response: %+v", points)
+		}
+	})
+}
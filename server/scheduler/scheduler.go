@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Schedule returns the next time a job should run after the given time.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// Every runs the job in fixed intervals.
+type Every time.Duration
+
+func (every Every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(every))
+}
+
+// Weekly runs the job once a week at the given local time.
+type Weekly struct {
+	Weekday  time.Weekday
+	Hour     int
+	Minute   int
+	Location *time.Location
+}
+
+func (weekly Weekly) Next(after time.Time) time.Time {
+	local := after.In(weekly.Location)
+	year, month, day := local.Date()
+
+	next := time.Date(year, month, day, weekly.Hour, weekly.Minute, 0, 0, weekly.Location)
+	next = next.AddDate(0, 0, (int(weekly.Weekday)-int(next.Weekday())+7)%7)
+
+	if !next.After(after) {
+		next = next.AddDate(0, 0, 7)
+	}
+
+	return next
+}
+
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// RunOnStart runs the job right away too, jobs that catch up on missed work should use it
+	// so nothing is lost while the server was down at the scheduled time.
+	RunOnStart bool
+	Run        func(ctx context.Context, now time.Time) error
+}
+
+// Scheduler runs jobs in the background of the server. A job never runs concurrently with itself,
+// a run that takes longer than the interval delays the next one.
+type Scheduler struct {
+	mutex sync.Mutex
+	jobs  []Job
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+func (scheduler *Scheduler) Add(job Job) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	scheduler.jobs = append(scheduler.jobs, job)
+}
+
+// Run starts every added job and blocks until ctx is done and running jobs return.
+func (scheduler *Scheduler) Run(ctx context.Context) {
+	scheduler.mutex.Lock()
+	jobs := append([]Job(nil), scheduler.jobs...)
+	scheduler.mutex.Unlock()
+
+	wg := sync.WaitGroup{}
+
+	for _, job := range jobs {
+		wg.Add(1)
+
+		go func(job Job) {
+			defer wg.Done()
+			runJob(ctx, job)
+		}(job)
+	}
+
+	wg.Wait()
+}
+
+func runJob(ctx context.Context, job Job) {
+	if job.RunOnStart {
+		runOnce(ctx, job, time.Now())
+	}
+
+	for {
+		next := job.Schedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		runOnce(ctx, job, time.Now())
+	}
+}
+
+func runOnce(ctx context.Context, job Job, now time.Time) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("job %s panicked: %v", job.Name, recovered)
+		}
+	}()
+
+	err := job.Run(ctx, now)
+	if err != nil {
+		log.Printf("error occured while running job %s: %v", job.Name, err)
+	}
+}
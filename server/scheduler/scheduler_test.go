@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWeeklyNext(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weekly := Weekly{Weekday: time.Monday, Hour: 7, Minute: 0, Location: location}
+
+	testCases := []struct {
+		name     string
+		after    time.Time
+		expected time.Time
+	}{
+		{"later the same week", time.Date(2024, 9, 4, 12, 0, 0, 0, location), time.Date(2024, 9, 9, 7, 0, 0, 0, location)},
+		{"earlier the same day", time.Date(2024, 9, 9, 6, 59, 0, 0, location), time.Date(2024, 9, 9, 7, 0, 0, 0, location)},
+		{"exactly at the time", time.Date(2024, 9, 9, 7, 0, 0, 0, location), time.Date(2024, 9, 16, 7, 0, 0, 0, location)},
+		{"across daylight saving change", time.Date(2024, 10, 22, 12, 0, 0, 0, location), time.Date(2024, 10, 28, 7, 0, 0, 0, location)},
+		{"after given in another zone", time.Date(2024, 9, 9, 4, 30, 0, 0, time.UTC), time.Date(2024, 9, 9, 7, 0, 0, 0, location)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			next := weekly.Next(testCase.after)
+			if !next.Equal(testCase.expected) {
+				t.Errorf("Expected %s, received %s", testCase.expected, next)
+			}
+		})
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("runs jobs repeatedly until stopped and survives failing runs", func(t *testing.T) {
+		var runs atomic.Int32
+		var startRuns atomic.Int32
+
+		scheduler := New()
+		scheduler.Add(Job{
+			Name:     "failing",
+			Schedule: Every(5 * time.Millisecond),
+			Run: func(ctx context.Context, now time.Time) error {
+				runs.Add(1)
+				return errors.New("failed")
+			},
+		})
+		scheduler.Add(Job{
+			Name:       "on start",
+			Schedule:   Every(time.Hour),
+			RunOnStart: true,
+			Run: func(ctx context.Context, now time.Time) error {
+				startRuns.Add(1)
+				panic("boom")
+			},
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan struct{})
+		go func() {
+			scheduler.Run(ctx)
+			close(done)
+		}()
+
+		deadline := time.Now().Add(2 * time.Second)
+		for runs.Load() < 3 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected at least 3 runs, received %d", runs.Load())
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Scheduler did not stop")
+		}
+
+		if startRuns.Load() != 1 {
+			t.Errorf("Got %d, want %d", startRuns.Load(), 1)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/calendar"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/reports"
+	"domanscy.group/parental-controls/server/webhooks"
+)
+
+type ScreenTimeResponse struct {
+	Date string `json:"date"`
+	// DailyMinutes is 0 when the screen time of the child is not limited, an entry of the household calendar replaces it.
+	DailyMinutes int `json:"dailyMinutes"`
+	// GrantedMinutes adds the time approved for requests and the time earned with chores, EarnedMinutes is the latter.
+	GrantedMinutes int `json:"grantedMinutes"`
+	EarnedMinutes  int `json:"earnedMinutes"`
+	// UsedSeconds is the screen time of the day on all devices of the child, as far as they reported it.
+	UsedSeconds int64 `json:"usedSeconds"`
+}
+
+type UpdateScreenTimeRequestBody struct {
+	DailyMinutes int `json:"dailyMinutes"`
+}
+
+func HttpChildrenUpdateScreenTime(_ *ServerConfig, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[UpdateScreenTimeRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child := findOwnedChildAndHandleError(w, r, tx, childId)
+		if child == nil {
+			return
+		}
+
+		err = children.UpdateDailyScreenTime(tx, child.Id, requestBody.DailyMinutes)
+		if errors.Is(err, children.ErrInvalidDailyScreenTime) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to update daily screen time: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		publishEvent(pushHub, child.Id, 0, push.EventTypePolicyChanged, PolicyChangedEvent{Setting: "screen_time"})
+
+		w.WriteHeader(204)
+	}
+}
+
+// effectiveDailyScreenTimeMinutes is the daily screen time of the child on the day, entries of the household
+// calendar replace the usual one.
+func effectiveDailyScreenTimeMinutes(tx *sql.Tx, child *children.Model, day time.Time) (int, error) {
+	entry, err := calendar.FindEffective(tx, child.HouseholdId, child.Id, day)
+	if err != nil {
+		return 0, err
+	}
+
+	if entry != nil {
+		return entry.DailyScreenTimeMinutes, nil
+	}
+
+	return child.DailyScreenTimeMinutes, nil
+}
+
+// computeScreenTime returns the budget of the child today and how much of it was used.
+func computeScreenTime(cfg *ServerConfig, tx *sql.Tx, child *children.Model, now time.Time) (ScreenTimeResponse, error) {
+	days, err := reports.ComputeDaily(tx, child.Id, reports.StartOfDay(now, cfg.ReportsLocation), 1, cfg.ReportsLocation, now)
+	if err != nil {
+		return ScreenTimeResponse{}, err
+	}
+
+	dailyMinutes, err := effectiveDailyScreenTimeMinutes(tx, child, days[0].Date)
+	if err != nil {
+		return ScreenTimeResponse{}, fmt.Errorf("failed to find calendar entry of the day: %w", err)
+	}
+
+	return ScreenTimeResponse{
+		Date:           days[0].Date.Format(reportDateFormat),
+		DailyMinutes:   dailyMinutes,
+		GrantedMinutes: days[0].GrantedMinutes + days[0].EarnedMinutes,
+		EarnedMinutes:  days[0].EarnedMinutes,
+		UsedSeconds:    int64(days[0].ScreenTime.Seconds()),
+	}, nil
+}
+
+// enqueueScreenTimeLimitReached tells webhooks of the household when the child used up the budget of today,
+// the event is stored once a day however often the activity is uploaded after.
+func enqueueScreenTimeLimitReached(cfg *ServerConfig, tx *sql.Tx, child *children.Model, now time.Time) error {
+	screenTime, err := computeScreenTime(cfg, tx, child, now)
+	if err != nil {
+		return err
+	}
+
+	budget := int64(screenTime.DailyMinutes+screenTime.GrantedMinutes) * 60
+	if screenTime.DailyMinutes == 0 || screenTime.UsedSeconds < budget {
+		return nil
+	}
+
+	dedupKey := fmt.Sprintf("%s:%d:%s", webhooks.EventScreenTimeLimitReached, child.Id, screenTime.Date)
+
+	return enqueueWebhookEvent(tx, child, webhooks.EventScreenTimeLimitReached, dedupKey, WebhookEventData{ScreenTime: &screenTime}, now)
+}
+
+// HttpDeviceScreenTime returns the budget of today and how much of it the child already used, the agent
+// adds the time it has not reported yet and locks the session when nothing is left.
+func HttpDeviceScreenTime(cfg *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device := authenticatedDevice(r)
+		now := time.Now()
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child, err := children.FindOneById(tx, device.ChildId)
+		if err != nil || child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child of the device: %v", err)
+			return
+		}
+
+		screenTime, err := computeScreenTime(cfg, tx, child, now)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to compute screen time: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, screenTime)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/reports"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpScreenTime(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Put("/children/{childId}/screen_time", HttpChildrenUpdateScreenTime(testingCfg, pushHub, db))
+	router.With(AuthenticateDeviceToken(db)).Get("/device/screen_time", HttpDeviceScreenTime(testingCfg, db))
+
+	sendUpdate := func(userId int, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:8080/children/%d/screen_time", family.childId), strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("updates the daily screen time and notifies devices", func(t *testing.T) {
+		subscription, _ := pushHub.Subscribe(family.childId, family.deviceId, 0)
+		defer subscription.Close()
+
+		recorder := sendUpdate(family.userId, `{"dailyMinutes": 120}`)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		event := <-subscription.Events()
+		if event.Type != push.EventTypePolicyChanged || string(event.Data) != `{"setting":"screen_time"}` {
+			t.Fatalf("Unexpected event: %+v", event)
+		}
+	})
+
+	t.Run("rejects invalid daily screen time and strangers", func(t *testing.T) {
+		recorder := sendUpdate(family.userId, `{"dailyMinutes": 1441}`)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		recorder = sendUpdate(stranger.userId, `{"dailyMinutes": 0}`)
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("returns the budget and the screen time used today", func(t *testing.T) {
+		now := time.Now()
+		screenOn := now.Add(-10 * time.Minute)
+		if startOfDay := reports.StartOfDay(now, testingCfg.ReportsLocation); screenOn.Before(startOfDay) {
+			screenOn = startOfDay
+		}
+
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		_, err = activity.Create(tx, family.childId, family.deviceId, activity.TypeScreenOn, activity.ScreenPayload{}, screenOn)
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/device/screen_time", nil)
+		request.Header.Set("Authorization", "Device "+family.deviceToken)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response ScreenTimeResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		wantUsed := int64(now.Sub(screenOn).Seconds())
+		if response.DailyMinutes != 120 || response.GrantedMinutes != 0 || response.UsedSeconds < wantUsed || response.UsedSeconds > wantUsed+5 {
+			t.Fatalf("Unexpected response: %+v, want about %d used seconds", response, wantUsed)
+		}
+
+		if response.Date != now.In(testingCfg.ReportsLocation).Format(reportDateFormat) {
+			t.Fatalf("Got date %s", response.Date)
+		}
+	})
+}
@@ -0,0 +1,261 @@
+// Package sdk is a typed client of the api of the server, for the dashboard of parents and the agent on devices.
+//
+// The types and operations in generated.go are generated from openapi.json, a copy of the document the server
+// serves at /openapi.json. After changing the api run `go test -run TestSdkOpenApiDocument -update .` in the server
+// directory to update the copy and then `go generate ./sdk`, the tests of the server fail until both are done.
+// The same step generates the sentinels in generated_errors.go from the errorCodes table of the server.
+package sdk
+
+//go:generate go run ./generate -input openapi.json -output generated.go -server .. -errors-output generated_errors.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxResponseSize limits the bodies read from the server, the largest are calendar exports.
+const MaxResponseSize = 16 * 1024 * 1024
+
+var ErrMissingToken = errors.New("the route requires a token the client does not have")
+
+type security int
+
+const (
+	securityNone security = iota
+	securityBearer
+	securityDevice
+)
+
+type request struct {
+	Method   string
+	Path     string
+	Security security
+	Query    url.Values
+	// Body is encoded as json, nil when the route takes no body.
+	Body any
+}
+
+// Client calls the api of the server at BaseUrl. Requests the server could not handle at the moment are retried with
+// exponential backoff, a token the server rejects is refreshed once, other errors are returned as *Error.
+type Client struct {
+	BaseUrl    string
+	HttpClient *http.Client
+	// Bearer authenticates the routes of parents, nil for clients calling only the routes of devices.
+	Bearer TokenSource
+	// Device authenticates the routes of devices, nil for clients calling only the routes of parents.
+	Device TokenSource
+	// MaxRetries is the number of attempts after the first one, 0 turns retries off.
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func New(baseUrl string) *Client {
+	return &Client{
+		BaseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		HttpClient: &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: 3,
+		MinBackoff: 500 * time.Millisecond,
+		MaxBackoff: 10 * time.Second,
+	}
+}
+
+func (client *Client) tokenSource(security security) (TokenSource, string) {
+	switch security {
+	case securityBearer:
+		return client.Bearer, "Bearer "
+	case securityDevice:
+		return client.Device, "Device "
+	}
+
+	return nil, ""
+}
+
+// send returns the body of the successful response, or the error of the last attempt.
+func (client *Client) send(ctx context.Context, req request) ([]byte, error) {
+	var body []byte
+	if req.Body != nil {
+		encoded, err := json.Marshal(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the body of %s %s: %w", req.Method, req.Path, err)
+		}
+
+		body = encoded
+	}
+
+	tokens, prefix := client.tokenSource(req.Security)
+	if req.Security != securityNone && tokens == nil {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.Path, ErrMissingToken)
+	}
+
+	token := ""
+	refreshed := false
+	backoff := client.MinBackoff
+
+	for attempt := 0; ; attempt++ {
+		if tokens != nil && token == "" {
+			var err error
+
+			token, err = tokens.Token(ctx, false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the token for %s %s: %w", req.Method, req.Path, err)
+			}
+		}
+
+		response, err := client.attempt(ctx, req, body, prefix+token)
+
+		var retryAfter time.Duration
+
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || !isIdempotent(req.Method) {
+				return nil, err
+			}
+		case response.status == http.StatusUnauthorized && tokens != nil && !refreshed:
+			// a token is refreshed once per call, the server rejecting the new token too is returned as is
+			refreshed = true
+
+			newToken, err := tokens.Token(ctx, true)
+			if err != nil {
+				return nil, fmt.Errorf("failed to refresh the token for %s %s: %w", req.Method, req.Path, err)
+			}
+
+			if newToken != token {
+				token = newToken
+				attempt--
+				continue
+			}
+
+			return nil, response.err()
+		case response.status >= 200 && response.status < 300:
+			return response.body, nil
+		case isRetryable(req.Method, response.status):
+			retryAfter = response.retryAfter
+			err = response.err()
+		default:
+			return nil, response.err()
+		}
+
+		if attempt >= client.MaxRetries {
+			return nil, err
+		}
+
+		wait := max(backoff, retryAfter)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		backoff = min(backoff*2, client.MaxBackoff)
+	}
+}
+
+func (client *Client) sendAndDecode(ctx context.Context, req request, result any) error {
+	body, err := client.send(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		return fmt.Errorf("failed to decode the response of %s %s: %w", req.Method, req.Path, err)
+	}
+
+	return nil
+}
+
+type response struct {
+	method      string
+	path        string
+	status      int
+	contentType string
+	body        []byte
+	retryAfter  time.Duration
+}
+
+func (response *response) err() error {
+	return newError(response.method, response.path, response.status, response.contentType, response.body)
+}
+
+func (client *Client) attempt(ctx context.Context, req request, body []byte, authorization string) (*response, error) {
+	target := client.BaseUrl + req.Path
+	if len(req.Query) != 0 {
+		target += "?" + req.Query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, req.Method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		httpRequest.Header.Set("Content-Type", "application/json")
+	}
+
+	// errors as ErrorResponse with the English messages of the sentinels, other bodies as the route sends them
+	httpRequest.Header.Set("Accept", "application/json, */*;q=0.5")
+	httpRequest.Header.Set("Accept-Language", "en")
+
+	if req.Security != securityNone {
+		httpRequest.Header.Set("Authorization", authorization)
+	}
+
+	httpResponse, err := client.HttpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpResponse.Body.Close()
+
+	responseBody, err := io.ReadAll(io.LimitReader(httpResponse.Body, MaxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response of %s %s: %w", req.Method, req.Path, err)
+	}
+
+	received := &response{
+		method:      req.Method,
+		path:        req.Path,
+		status:      httpResponse.StatusCode,
+		contentType: httpResponse.Header.Get("Content-Type"),
+		body:        responseBody,
+	}
+
+	if seconds, err := strconv.Atoi(httpResponse.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		received.retryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return received, nil
+}
+
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete
+}
+
+// isRetryable reports whether the request can be sent again after the status. A request rejected as too many was not
+// handled at all, the others may have been, so only idempotent requests are sent again after them.
+func isRetryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	}
+
+	return false
+}
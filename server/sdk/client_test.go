@@ -0,0 +1,245 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/activity"
+	"domanscy.group/parental-controls/server/apprules"
+	"domanscy.group/parental-controls/server/domainrules"
+	"domanscy.group/parental-controls/server/openapi"
+	"domanscy.group/parental-controls/server/sdk/internal/generator"
+	"domanscy.group/parental-controls/server/validation"
+)
+
+func TestGeneratedIsUpToDate(t *testing.T) {
+	encoded, err := os.ReadFile("openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var document openapi.Document
+	err = json.Unmarshal(encoded, &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := generator.Generate(&document, "sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile("generated.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatal("generated.go is out of date, run go generate")
+	}
+}
+
+func TestGeneratedErrorsAreUpToDate(t *testing.T) {
+	want, err := generator.GenerateErrors("..", "sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile("generated_errors.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatal("generated_errors.go is out of date, run go generate")
+	}
+}
+
+func newTestClient(url string) *Client {
+	client := New(url)
+	client.MinBackoff = time.Millisecond
+	client.MaxBackoff = time.Millisecond
+
+	return client
+}
+
+func TestClientRetries(t *testing.T) {
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"dailyMinutes": 60}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+	client.Device = StaticToken("device")
+
+	t.Run("idempotent requests are sent again", func(t *testing.T) {
+		attempts.Store(0)
+
+		screenTime, err := client.DeviceScreenTime(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if attempts.Load() != 3 || screenTime.DailyMinutes != 60 {
+			t.Fatalf("Expected the third attempt to succeed, received %+v after %d attempts", screenTime, attempts.Load())
+		}
+	})
+
+	t.Run("other requests are not", func(t *testing.T) {
+		attempts.Store(0)
+
+		err := client.DeviceCommandsAcknowledge(context.Background(), 1)
+
+		var serverErr *Error
+		if !errors.As(err, &serverErr) || serverErr.StatusCode != http.StatusServiceUnavailable || attempts.Load() != 1 {
+			t.Fatalf("Expected a single attempt failing with 503, received %v after %d attempts", err, attempts.Load())
+		}
+	})
+
+	t.Run("gives up after the last retry", func(t *testing.T) {
+		attempts.Store(-10)
+
+		_, err := client.DeviceScreenTime(context.Background())
+		if err == nil || attempts.Load() != -10+int32(client.MaxRetries)+1 {
+			t.Fatalf("Expected an error after %d attempts, received %v after %d", client.MaxRetries+1, err, attempts.Load()+10)
+		}
+	})
+}
+
+func TestClientRefreshesTokens(t *testing.T) {
+	var exchanged atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/get_bearer_from_otat/otat-1":
+			_, _ = w.Write([]byte("expired"))
+			exchanged.Add(1)
+		case r.URL.Path == "/get_bearer_from_otat/otat-2":
+			_, _ = w.Write([]byte("valid"))
+			exchanged.Add(1)
+		case r.Header.Get("Authorization") == "Bearer valid":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error": {"code": "invalid_bearer_token", "message": "invalid bearer token"}}`))
+		}
+	}))
+	defer server.Close()
+
+	otats := []string{"otat-1", "otat-2"}
+
+	client := newTestClient(server.URL)
+	client.Bearer = NewOtatTokenSource(client, func(ctx context.Context) (string, error) {
+		if len(otats) == 0 {
+			return "", ErrNoOneTimeAccessToken
+		}
+
+		otat := otats[0]
+		otats = otats[1:]
+
+		return otat, nil
+	})
+
+	children, err := client.HouseholdsChildrenList(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if children == nil || exchanged.Load() != 2 {
+		t.Fatalf("Expected the request to succeed with the refreshed token, %d tokens exchanged", exchanged.Load())
+	}
+
+	_, err = client.HouseholdsChildrenList(context.Background(), 1)
+	if err != nil || exchanged.Load() != 2 {
+		t.Fatalf("Expected the refreshed token to be kept, received %v with %d tokens exchanged", err, exchanged.Load())
+	}
+
+	client.Bearer = StaticToken("expired")
+
+	_, err = client.HouseholdsChildrenList(context.Background(), 1)
+	if !errors.Is(err, ErrInvalidBearerToken) {
+		t.Fatalf("Expected ErrInvalidBearerToken from a token that can not be refreshed, received %v", err)
+	}
+
+	client.Bearer = nil
+
+	_, err = client.HouseholdsChildrenList(context.Background(), 1)
+	if !errors.Is(err, ErrMissingToken) {
+		t.Fatalf("Expected ErrMissingToken, received %v", err)
+	}
+}
+
+func TestErrorWrapsSentinels(t *testing.T) {
+	body := `{"error": {"code": "invalid_action", "message": "nieprawidłowa akcja"}}`
+
+	err := error(newError(http.MethodPost, "/children/1/app_rules", http.StatusBadRequest, "application/json", []byte(body)))
+	if !errors.Is(err, apprules.ErrInvalidAction) || !errors.Is(err, domainrules.ErrInvalidAction) {
+		t.Fatalf("Expected the sentinels of both packages, received %v", err)
+	}
+
+	if errors.Is(err, ErrChildNotFound) {
+		t.Fatal("Expected no other sentinels")
+	}
+
+	body = `{"error": {"code": "child_not_found", "message": "Nie znaleziono dziecka"}}`
+
+	err = newError(http.MethodGet, "/children/1", http.StatusNotFound, "application/json; charset=utf-8", []byte(body))
+	if !errors.Is(err, ErrChildNotFound) {
+		t.Fatalf("Expected ErrChildNotFound, received %v", err)
+	}
+
+	err = newError(http.MethodGet, "/children/1", http.StatusNotFound, "text/plain", []byte(ErrChildNotFound.Error()))
+	if errors.Is(err, ErrChildNotFound) {
+		t.Fatalf("Expected plain text messages to wrap no sentinels, received %v", err)
+	}
+
+	err = newError(http.MethodGet, "/children/1", http.StatusInternalServerError, "text/plain", []byte("Internal Server Error"))
+
+	var serverErr *Error
+	if !errors.As(err, &serverErr) || serverErr.StatusCode != http.StatusInternalServerError || errors.Unwrap(err) != nil {
+		t.Fatalf("Expected an error without sentinels, received %v", err)
+	}
+
+	body = `{"error": {"code": "invalid_fields", "message": "request contains invalid fields", "requestId": "abc",
+		"details": [{"field": "events[1]", "code": "invalid_activity_event_type", "message": "invalid activity event type"}]}}`
+
+	err = newError(http.MethodPost, "/device/activity", http.StatusBadRequest, "application/json", []byte(body))
+	if !errors.As(err, &serverErr) || serverErr.Code != "invalid_fields" || serverErr.RequestId != "abc" || len(serverErr.Details) != 1 {
+		t.Fatalf("Expected the error response to be decoded, received %+v", err)
+	}
+
+	if !errors.Is(err, ErrInvalidFields) || !errors.Is(err, activity.ErrInvalidType) {
+		t.Fatalf("Expected the sentinels of the error and its details, received %v", err)
+	}
+
+	body = `{"error": {"code": "invalid_fields", "message": "request contains invalid fields", "requestId": "abc",
+		"details": [{"field": "name", "code": "too_long", "message": "can have at most 50 characters"}]}}`
+
+	err = newError(http.MethodPost, "/households/1/children", http.StatusBadRequest, "application/json", []byte(body))
+	if !errors.Is(err, validation.ErrTooLong) || errors.Is(err, validation.ErrTooShort) {
+		t.Fatalf("Expected the sentinel of the broken rule, received %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// Error is a response of the server with an error status. It wraps the sentinels of the server with its code and the
+// codes of its details, so errors.Is(err, sdk.ErrChildNotFound) or errors.Is(err, rewards.ErrInsufficientPoints)
+// tell errors apart. The messages are translated and are not matched.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Code identifies the error, e.g. "invalid_email", it is empty when the server responded with plain text.
+	Code      string
+	Message   string
+	RequestId string
+	// Details describe the invalid fields of the request.
+	Details []FieldError
+
+	sentinels []error
+}
+
+// newError reads the ErrorResponse of the server, or its plain text message from servers and proxies which do not
+// send json, the latter has no code and wraps no sentinels.
+func newError(method string, path string, statusCode int, contentType string, body []byte) *Error {
+	err := &Error{Method: method, Path: path, StatusCode: statusCode, Message: strings.TrimSpace(string(body))}
+
+	var decoded ErrorResponse
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/json" && json.Unmarshal(body, &decoded) == nil {
+		err.Code = decoded.Error.Code
+		err.Message = decoded.Error.Message
+		err.RequestId = decoded.Error.RequestId
+		err.Details = decoded.Error.Details
+	}
+
+	err.sentinels = append(err.sentinels, serverErrors[err.Code]...)
+	for _, detail := range err.Details {
+		err.sentinels = append(err.sentinels, serverErrors[detail.Code]...)
+
+		if sentinel, ok := validationErrors[detail.Code]; ok {
+			err.sentinels = append(err.sentinels, sentinel)
+		}
+	}
+
+	return err
+}
+
+func (err *Error) Error() string {
+	if err.RequestId != "" {
+		return fmt.Sprintf("%s %s: %d %s (request %s)", err.Method, err.Path, err.StatusCode, err.Message, err.RequestId)
+	}
+
+	return fmt.Sprintf("%s %s: %d %s", err.Method, err.Path, err.StatusCode, err.Message)
+}
+
+func (err *Error) Unwrap() []error {
+	return err.sentinels
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"domanscy.group/parental-controls/server/openapi"
+	"domanscy.group/parental-controls/server/sdk/internal/generator"
+)
+
+var input string
+var output string
+var serverDirectory string
+var errorsOutput string
+var packageName string
+
+func init() {
+	flag.StringVar(&input, "input", "openapi.json", "Location of the openapi document of the server.")
+	flag.StringVar(&output, "output", "generated.go", "Location of the generated go source.")
+	flag.StringVar(&serverDirectory, "server", "..", "Location of the server, its error codes become the sentinels of the sdk.")
+	flag.StringVar(&errorsOutput, "errors-output", "generated_errors.go", "Location of the generated go source of the sentinels.")
+	flag.StringVar(&packageName, "package", "sdk", "Name of the package of the generated go source.")
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+
+	encoded, err := os.ReadFile(input)
+	if err != nil {
+		log.Fatalf("failed to read the openapi document: %v", err)
+	}
+
+	var document openapi.Document
+	err = json.Unmarshal(encoded, &document)
+	if err != nil {
+		log.Fatalf("failed to decode the openapi document: %v", err)
+	}
+
+	source, err := generator.Generate(&document, packageName)
+	if err != nil {
+		log.Fatalf("failed to generate the sdk: %v", err)
+	}
+
+	err = os.WriteFile(output, source, 0644)
+	if err != nil {
+		log.Fatalf("failed to write the sdk: %v", err)
+	}
+
+	source, err = generator.GenerateErrors(serverDirectory, packageName)
+	if err != nil {
+		log.Fatalf("failed to generate the sentinels of the sdk: %v", err)
+	}
+
+	err = os.WriteFile(errorsOutput, source, 0644)
+	if err != nil {
+		log.Fatalf("failed to write the sentinels of the sdk: %v", err)
+	}
+}
@@ -0,0 +1,405 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errorCodesFile declares errorCodes, the table of the sentinels the handlers of the server respond with.
+const errorCodesFile = "http_errors.go"
+
+// validationPackage holds the sentinels of the broken validation rules, the sdk finds them by the codes of the
+// details only.
+const validationPackage = "validation"
+
+type sourcePackage struct {
+	files []*ast.File
+}
+
+// errorsSource reads the server sources the sentinels and the constants of their messages are declared in.
+type errorsSource struct {
+	serverDirectory string
+	modulePath      string
+	fileSet         *token.FileSet
+	packages        map[string]*sourcePackage
+}
+
+type sentinel struct {
+	// packageName is empty for the sentinels of the main package, which the sdk can not import and declares again.
+	packageName string
+	name        string
+	code        string
+	// file declares the sentinel in the main package
+	file    string
+	message string
+}
+
+// GenerateErrors returns the formatted go source of the sentinels of the errorCodes table of the server in
+// serverDirectory for the package.
+func GenerateErrors(serverDirectory string, packageName string) ([]byte, error) {
+	goMod, err := os.ReadFile(filepath.Join(serverDirectory, "go.mod"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read go.mod of the server: %w", err)
+	}
+
+	source := &errorsSource{serverDirectory: serverDirectory, fileSet: token.NewFileSet(), packages: make(map[string]*sourcePackage)}
+	for _, line := range strings.Split(string(goMod), "\n") {
+		if modulePath, found := strings.CutPrefix(strings.TrimSpace(line), "module "); found {
+			source.modulePath = strings.TrimSpace(modulePath)
+			break
+		}
+	}
+
+	if source.modulePath == "" {
+		return nil, fmt.Errorf("go.mod of the server has no module path")
+	}
+
+	sentinels, imports, err := source.sentinels()
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	body.WriteString("// The sentinels of the handlers of the server, which live in its main package and can not be imported.\n")
+
+	file := ""
+	for _, sentinel := range sentinels {
+		if sentinel.packageName != "" {
+			continue
+		}
+
+		if sentinel.file != file {
+			file = sentinel.file
+			fmt.Fprintf(&body, "\n// %s\n", file)
+		}
+
+		fmt.Fprintf(&body, "var %s = errors.New(%q)\n", sentinel.name, sentinel.message)
+	}
+
+	body.WriteString("\n// serverErrors are the sentinels the server responds with by their codes, some codes are shared by sentinels of\n// several packages, e.g. \"invalid_action\". The sentinels of the packages of the server are used as they are.\nvar serverErrors = map[string][]error{\n")
+	var codes []string
+	byCode := make(map[string][]string)
+	for _, sentinel := range sentinels {
+		if sentinel.packageName == validationPackage {
+			continue
+		}
+
+		if _, found := byCode[sentinel.code]; !found {
+			codes = append(codes, sentinel.code)
+		}
+
+		if sentinel.packageName == "" {
+			byCode[sentinel.code] = append(byCode[sentinel.code], sentinel.name)
+		} else {
+			byCode[sentinel.code] = append(byCode[sentinel.code], sentinel.packageName+"."+sentinel.name)
+		}
+	}
+
+	for _, code := range codes {
+		fmt.Fprintf(&body, "\t%q: {%s},\n", code, strings.Join(byCode[code], ", "))
+	}
+	body.WriteString("}\n\n")
+
+	body.WriteString("// validationErrors are the sentinels of the broken validation rules by the codes of the details, their messages\n// describe the rule of the field, e.g. \"can have at most 50 characters\", instead of being the one of the sentinel.\nvar validationErrors = map[string]error{\n")
+	for _, sentinel := range sentinels {
+		if sentinel.packageName == validationPackage {
+			fmt.Fprintf(&body, "\t%q: %s.%s,\n", sentinel.code, sentinel.packageName, sentinel.name)
+		}
+	}
+	body.WriteString("}\n")
+
+	var generated bytes.Buffer
+	fmt.Fprintf(&generated, "// Code generated by sdk/generate from %s of the server; DO NOT EDIT.\n\npackage %s\n\n", errorCodesFile, packageName)
+
+	generated.WriteString("import (\n\t\"errors\"\n\n")
+	for _, imported := range imports {
+		fmt.Fprintf(&generated, "\t%q\n", imported)
+	}
+	generated.WriteString(")\n\n")
+	generated.Write(body.Bytes())
+
+	formatted, err := format.Source(generated.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format the generated source: %w\n%s", err, generated.Bytes())
+	}
+
+	return formatted, nil
+}
+
+// sentinels returns the sentinels of errorCodes in the order of the table and the sorted import paths of their packages.
+func (source *errorsSource) sentinels() ([]sentinel, []string, error) {
+	main, err := source.load(".")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	table, file := main.variable("errorCodes")
+	if table == nil {
+		return nil, nil, fmt.Errorf("the server declares no errorCodes")
+	}
+
+	literal, ok := table.(*ast.CompositeLit)
+	if !ok {
+		return nil, nil, fmt.Errorf("errorCodes is not a map literal")
+	}
+
+	var sentinels []sentinel
+	imported := make(map[string]bool)
+
+	for _, element := range literal.Elts {
+		pair, ok := element.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, nil, fmt.Errorf("errorCodes has an element without a key")
+		}
+
+		code, err := stringLiteral(pair.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("code of errorCodes element: %w", err)
+		}
+
+		switch key := pair.Key.(type) {
+		case *ast.Ident:
+			value, declaredIn := main.variable(key.Name)
+			if value == nil {
+				return nil, nil, fmt.Errorf("%s is not declared by the server", key.Name)
+			}
+
+			message, err := source.message(main, declaredIn, value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", key.Name, err)
+			}
+
+			sentinels = append(sentinels, sentinel{name: key.Name, code: code, file: filepath.Base(source.fileSet.File(declaredIn.Pos()).Name()), message: message})
+		case *ast.SelectorExpr:
+			packageName, ok := key.X.(*ast.Ident)
+			if !ok {
+				return nil, nil, fmt.Errorf("errorCodes has a key which is not a sentinel")
+			}
+
+			importPath, err := importPathOf(file, packageName.Name)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			imported[importPath] = true
+			sentinels = append(sentinels, sentinel{packageName: packageName.Name, name: key.Sel.Name, code: code})
+		default:
+			return nil, nil, fmt.Errorf("errorCodes has a key which is not a sentinel")
+		}
+	}
+
+	return sentinels, sortedKeys(imported), nil
+}
+
+// load parses the package in the directory relative to the server, without its tests.
+func (source *errorsSource) load(directory string) (*sourcePackage, error) {
+	if loaded, ok := source.packages[directory]; ok {
+		return loaded, nil
+	}
+
+	parsed, err := parser.ParseDir(source.fileSet, filepath.Join(source.serverDirectory, directory), func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the server package %s: %w", directory, err)
+	}
+
+	loaded := &sourcePackage{}
+	for _, parsedPackage := range parsed {
+		for _, name := range sortedKeys(parsedPackage.Files) {
+			loaded.files = append(loaded.files, parsedPackage.Files[name])
+		}
+	}
+
+	source.packages[directory] = loaded
+
+	return loaded, nil
+}
+
+// message evaluates the errors.New or fmt.Errorf call declaring a sentinel, its arguments must be constants.
+func (source *errorsSource) message(declaredBy *sourcePackage, file *ast.File, value ast.Expr) (string, error) {
+	call, ok := value.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", fmt.Errorf("is not declared with errors.New or fmt.Errorf")
+	}
+
+	function, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", fmt.Errorf("is not declared with errors.New or fmt.Errorf")
+	}
+
+	args := make([]any, 0, len(call.Args))
+	for _, arg := range call.Args {
+		evaluated, err := source.constant(declaredBy, file, arg)
+		if err != nil {
+			return "", err
+		}
+
+		switch evaluated.Kind() {
+		case constant.String:
+			args = append(args, constant.StringVal(evaluated))
+		case constant.Int:
+			number, _ := constant.Int64Val(evaluated)
+			args = append(args, number)
+		case constant.Float:
+			number, _ := constant.Float64Val(evaluated)
+			args = append(args, number)
+		case constant.Bool:
+			args = append(args, constant.BoolVal(evaluated))
+		default:
+			return "", fmt.Errorf("has an argument of unsupported kind %s", evaluated.Kind())
+		}
+	}
+
+	text, ok := args[0].(string)
+	if !ok {
+		return "", fmt.Errorf("has a message which is not a string")
+	}
+
+	switch function.Sel.Name {
+	case "New":
+		return text, nil
+	case "Errorf":
+		if strings.Contains(text, "%w") {
+			return "", fmt.Errorf("wraps another error")
+		}
+
+		return fmt.Sprintf(text, args[1:]...), nil
+	default:
+		return "", fmt.Errorf("is not declared with errors.New or fmt.Errorf")
+	}
+}
+
+// constant evaluates a constant expression, following the constants of the package and of the server packages it imports.
+func (source *errorsSource) constant(declaredBy *sourcePackage, file *ast.File, expr ast.Expr) (constant.Value, error) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		return constant.MakeFromLiteral(expr.Value, expr.Kind, 0), nil
+	case *ast.ParenExpr:
+		return source.constant(declaredBy, file, expr.X)
+	case *ast.BinaryExpr:
+		x, err := source.constant(declaredBy, file, expr.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := source.constant(declaredBy, file, expr.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		switch expr.Op {
+		case token.SHL, token.SHR:
+			shift, _ := constant.Uint64Val(y)
+			return constant.Shift(x, expr.Op, uint(shift)), nil
+		case token.QUO:
+			if x.Kind() == constant.Int && y.Kind() == constant.Int {
+				return constant.BinaryOp(x, token.QUO_ASSIGN, y), nil
+			}
+		}
+
+		return constant.BinaryOp(x, expr.Op, y), nil
+	case *ast.Ident:
+		value, declaredIn := declaredBy.constant(expr.Name)
+		if value == nil {
+			return nil, fmt.Errorf("%s is not a constant", expr.Name)
+		}
+
+		return source.constant(declaredBy, declaredIn, value)
+	case *ast.SelectorExpr:
+		packageName, ok := expr.X.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("unsupported constant expression")
+		}
+
+		importPath, err := importPathOf(file, packageName.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		directory, found := strings.CutPrefix(importPath, source.modulePath+"/")
+		if !found {
+			return nil, fmt.Errorf("%s is not a package of the server", importPath)
+		}
+
+		imported, err := source.load(directory)
+		if err != nil {
+			return nil, err
+		}
+
+		value, declaredIn := imported.constant(expr.Sel.Name)
+		if value == nil {
+			return nil, fmt.Errorf("%s.%s is not a constant", packageName.Name, expr.Sel.Name)
+		}
+
+		return source.constant(imported, declaredIn, value)
+	default:
+		return nil, fmt.Errorf("unsupported constant expression")
+	}
+}
+
+func (p *sourcePackage) variable(name string) (ast.Expr, *ast.File) {
+	return p.declaration(token.VAR, name)
+}
+
+func (p *sourcePackage) constant(name string) (ast.Expr, *ast.File) {
+	return p.declaration(token.CONST, name)
+}
+
+// declaration returns the value of the top level declaration with a single value and the file declaring it.
+func (p *sourcePackage) declaration(kind token.Token, name string) (ast.Expr, *ast.File) {
+	for _, file := range p.files {
+		for _, declaration := range file.Decls {
+			general, ok := declaration.(*ast.GenDecl)
+			if !ok || general.Tok != kind {
+				continue
+			}
+
+			for _, spec := range general.Specs {
+				valueSpec := spec.(*ast.ValueSpec)
+				for i, declared := range valueSpec.Names {
+					if declared.Name == name && i < len(valueSpec.Values) {
+						return valueSpec.Values[i], file
+					}
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func importPathOf(file *ast.File, packageName string) (string, error) {
+	for _, imported := range file.Imports {
+		importPath, err := strconv.Unquote(imported.Path.Value)
+		if err != nil {
+			return "", err
+		}
+
+		if (imported.Name != nil && imported.Name.Name == packageName) || (imported.Name == nil && path.Base(importPath) == packageName) {
+			return importPath, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s is not imported", packageName)
+}
+
+func stringLiteral(expr ast.Expr) (string, error) {
+	literal, ok := expr.(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return "", fmt.Errorf("is not a string literal")
+	}
+
+	return strconv.Unquote(literal.Value)
+}
@@ -0,0 +1,456 @@
+// Package generator writes the types and operations of the sdk from the openapi document of the server.
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"mime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"domanscy.group/parental-controls/server/openapi"
+)
+
+const componentsPrefix = "#/components/schemas/"
+
+// supportedContentTypes are the success responses the sdk decodes, the other routes are meant for browsers, resolvers
+// or long lived connections and are left out.
+var supportedContentTypes = map[string]bool{
+	"":                 true,
+	"application/json": true,
+	"text/plain":       true,
+	"text/calendar":    true,
+}
+
+type generator struct {
+	document *openapi.Document
+	// typeNames maps the names of the components to the names of the go types
+	typeNames map[string]string
+	imports   map[string]bool
+}
+
+// Generate returns the formatted go source of the document for the package.
+func Generate(document *openapi.Document, packageName string) ([]byte, error) {
+	g := &generator{document: document, typeNames: make(map[string]string), imports: make(map[string]bool)}
+
+	for name := range document.Components.Schemas {
+		g.typeNames[name] = typeName(name, document.Components.Schemas)
+	}
+
+	var body bytes.Buffer
+
+	err := g.writeTypes(&body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.writeOperations(&body)
+	if err != nil {
+		return nil, err
+	}
+
+	var source bytes.Buffer
+	fmt.Fprintf(&source, "// Code generated by sdk/generate from openapi.json; DO NOT EDIT.\n\npackage %s\n\n", packageName)
+
+	imports := make([]string, 0, len(g.imports))
+	for imported := range g.imports {
+		imports = append(imports, imported)
+	}
+	sort.Strings(imports)
+
+	source.WriteString("import (\n")
+	for _, imported := range imports {
+		fmt.Fprintf(&source, "\t%q\n", imported)
+	}
+	source.WriteString(")\n\n")
+	source.Write(body.Bytes())
+
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format the generated source: %w\n%s", err, source.Bytes())
+	}
+
+	return formatted, nil
+}
+
+// typeName drops the suffix the document gives to request bodies, unless the type is described as a response too.
+func typeName(name string, schemas map[string]*openapi.Schema) string {
+	trimmed, found := strings.CutSuffix(name, "Input")
+	if !found {
+		return name
+	}
+
+	if _, ok := schemas[trimmed]; ok {
+		return name
+	}
+
+	return trimmed
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (g *generator) writeTypes(w *bytes.Buffer) error {
+	names := sortedKeys(g.document.Components.Schemas)
+	sort.Slice(names, func(i, j int) bool { return g.typeNames[names[i]] < g.typeNames[names[j]] })
+
+	for _, name := range names {
+		schema := g.document.Components.Schemas[name]
+
+		goType, err := g.goType(schema, true)
+		if err != nil {
+			return fmt.Errorf("schema %s: %w", name, err)
+		}
+
+		fmt.Fprintf(w, "type %s %s\n\n", g.typeNames[name], goType)
+	}
+
+	return nil
+}
+
+// goType returns the go type of the schema, top level objects become struct types instead of maps.
+func (g *generator) goType(schema *openapi.Schema, declaration bool) (string, error) {
+	if schema.Ref != "" {
+		name, ok := g.typeNames[strings.TrimPrefix(schema.Ref, componentsPrefix)]
+		if !ok {
+			return "", fmt.Errorf("unknown schema %s", schema.Ref)
+		}
+
+		return name, nil
+	}
+
+	if len(schema.AllOf) == 1 {
+		inner, err := g.goType(schema.AllOf[0], false)
+		if err != nil {
+			return "", err
+		}
+
+		return nullable(inner, schema.Nullable), nil
+	}
+
+	if len(schema.AllOf) > 1 {
+		return "", fmt.Errorf("allOf of %d schemas can not be described", len(schema.AllOf))
+	}
+
+	switch schema.Type {
+	case "":
+		g.imports["encoding/json"] = true
+		return "json.RawMessage", nil
+	case "boolean":
+		return nullable("bool", schema.Nullable), nil
+	case "integer":
+		if schema.Format == "int64" {
+			return nullable("int64", schema.Nullable), nil
+		}
+
+		return nullable("int", schema.Nullable), nil
+	case "number":
+		return nullable("float64", schema.Nullable), nil
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return nullable("time.Time", schema.Nullable), nil
+		case "byte":
+			return "[]byte", nil
+		}
+
+		return nullable("string", schema.Nullable), nil
+	case "array":
+		items, err := g.goType(schema.Items, false)
+		if err != nil {
+			return "", err
+		}
+
+		return "[]" + items, nil
+	case "object":
+		if schema.AdditionalProperties != nil {
+			values, err := g.goType(schema.AdditionalProperties, false)
+			if err != nil {
+				return "", err
+			}
+
+			return "map[string]" + values, nil
+		}
+
+		structType, err := g.structType(schema)
+		if err != nil {
+			return "", err
+		}
+
+		if !declaration {
+			return nullable(structType, schema.Nullable), nil
+		}
+
+		return structType, nil
+	}
+
+	return "", fmt.Errorf("unknown type %s", schema.Type)
+}
+
+func nullable(goType string, isNullable bool) string {
+	if isNullable {
+		return "*" + goType
+	}
+
+	return goType
+}
+
+func (g *generator) structType(schema *openapi.Schema) (string, error) {
+	var w strings.Builder
+	w.WriteString("struct {\n")
+
+	for _, name := range propertyNames(schema) {
+		fieldType, err := g.goType(schema.Properties[name], false)
+		if err != nil {
+			return "", fmt.Errorf("property %s: %w", name, err)
+		}
+
+		tag := name
+		if len(schema.Required) != 0 && !slices.Contains(schema.Required, name) {
+			tag += ",omitempty"
+		}
+
+		fmt.Fprintf(&w, "%s %s `json:%q`\n", exportedName(name), fieldType, tag)
+	}
+
+	w.WriteString("}")
+
+	return w.String(), nil
+}
+
+// propertyNames keeps the order of the fields of response types, which require all of them but the omitted empty ones.
+func propertyNames(schema *openapi.Schema) []string {
+	names := slices.Clone(schema.Required)
+
+	for _, name := range sortedKeys(schema.Properties) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+type operation struct {
+	method    string
+	path      string
+	described *openapi.Operation
+}
+
+func (g *generator) writeOperations(w *bytes.Buffer) error {
+	var operations []operation
+
+	for _, path := range sortedKeys(g.document.Paths) {
+		item := *g.document.Paths[path]
+
+		for _, method := range sortedKeys(item) {
+			operations = append(operations, operation{method: strings.ToUpper(method), path: path, described: item[method]})
+		}
+	}
+
+	sort.Slice(operations, func(i, j int) bool {
+		return operations[i].described.OperationId < operations[j].described.OperationId
+	})
+
+	for _, op := range operations {
+		err := g.writeOperation(w, op)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", op.method, op.path, err)
+		}
+	}
+
+	return nil
+}
+
+// successResponse returns the first 2xx response of the operation, routes answering with redirects have none.
+func successResponse(described *openapi.Operation) *openapi.Response {
+	for _, code := range sortedKeys(described.Responses) {
+		status, err := strconv.Atoi(code)
+		if err == nil && status >= 200 && status < 300 {
+			return described.Responses[code]
+		}
+	}
+
+	return nil
+}
+
+func (g *generator) writeOperation(w *bytes.Buffer, op operation) error {
+	response := successResponse(op.described)
+	if response == nil {
+		return nil
+	}
+
+	contentType := ""
+	var content openapi.MediaType
+
+	for mediaType, described := range response.Content {
+		contentType, _, _ = mime.ParseMediaType(mediaType)
+		content = described
+	}
+
+	if !supportedContentTypes[contentType] {
+		return nil
+	}
+
+	name := exportedName(op.described.OperationId)
+	arguments := []string{"ctx context.Context"}
+	g.imports["context"] = true
+
+	path := op.path
+	var pathArguments []string
+
+	for _, parameter := range op.described.Parameters {
+		if parameter.In != "path" {
+			continue
+		}
+
+		if parameter.Schema.Type == "integer" {
+			arguments = append(arguments, parameter.Name+" int")
+			path = strings.Replace(path, "{"+parameter.Name+"}", "%d", 1)
+			pathArguments = append(pathArguments, parameter.Name)
+		} else {
+			arguments = append(arguments, parameter.Name+" string")
+			path = strings.Replace(path, "{"+parameter.Name+"}", "%s", 1)
+			pathArguments = append(pathArguments, "url.PathEscape("+parameter.Name+")")
+			g.imports["net/url"] = true
+		}
+	}
+
+	queryType, err := g.writeQueryType(w, name, op.described.Parameters)
+	if err != nil {
+		return err
+	}
+
+	if queryType != "" {
+		arguments = append(arguments, "query "+queryType)
+	}
+
+	if op.described.RequestBody != nil {
+		bodyType, err := g.goType(op.described.RequestBody.Content["application/json"].Schema, false)
+		if err != nil {
+			return fmt.Errorf("request body: %w", err)
+		}
+
+		arguments = append(arguments, "body "+bodyType)
+	}
+
+	security := "securityNone"
+	for _, requirement := range op.described.Security {
+		for scheme := range requirement {
+			security = "security" + exportedName(strings.TrimSuffix(scheme, "Auth"))
+		}
+	}
+
+	pathExpression := strconv.Quote(path)
+	if len(pathArguments) != 0 {
+		g.imports["fmt"] = true
+		pathExpression = fmt.Sprintf("fmt.Sprintf(%q, %s)", path, strings.Join(pathArguments, ", "))
+	}
+
+	request := fmt.Sprintf("request{Method: %q, Path: %s, Security: %s", op.method, pathExpression, security)
+	if queryType != "" {
+		request += ", Query: query.values()"
+	}
+	if op.described.RequestBody != nil {
+		request += ", Body: body"
+	}
+	request += "}"
+
+	fmt.Fprintf(w, "// %s sends %s %s.\n", name, op.method, op.path)
+	if op.described.Summary != "" {
+		fmt.Fprintf(w, "// %s.\n", op.described.Summary)
+	}
+
+	switch contentType {
+	case "":
+		fmt.Fprintf(w, "func (client *Client) %s(%s) error {\n", name, strings.Join(arguments, ", "))
+		fmt.Fprintf(w, "_, err := client.send(%s)\nreturn err\n}\n\n", "ctx, "+request)
+	case "application/json":
+		resultType, err := g.goType(content.Schema, false)
+		if err != nil {
+			return fmt.Errorf("response body: %w", err)
+		}
+
+		if content.Schema.Ref != "" {
+			fmt.Fprintf(w, "func (client *Client) %s(%s) (*%s, error) {\n", name, strings.Join(arguments, ", "), resultType)
+			fmt.Fprintf(w, "var result %s\nerr := client.sendAndDecode(ctx, %s, &result)\nif err != nil {\nreturn nil, err\n}\n\nreturn &result, nil\n}\n\n", resultType, request)
+		} else {
+			fmt.Fprintf(w, "func (client *Client) %s(%s) (%s, error) {\n", name, strings.Join(arguments, ", "), resultType)
+			fmt.Fprintf(w, "var result %s\nerr := client.sendAndDecode(ctx, %s, &result)\nreturn result, err\n}\n\n", resultType, request)
+		}
+	case "text/plain":
+		fmt.Fprintf(w, "func (client *Client) %s(%s) (string, error) {\n", name, strings.Join(arguments, ", "))
+		fmt.Fprintf(w, "body, err := client.send(ctx, %s)\nreturn string(body), err\n}\n\n", request)
+	default:
+		fmt.Fprintf(w, "func (client *Client) %s(%s) ([]byte, error) {\n", name, strings.Join(arguments, ", "))
+		fmt.Fprintf(w, "return client.send(ctx, %s)\n}\n\n", request)
+	}
+
+	return nil
+}
+
+// writeQueryType writes the struct of the query parameters of the operation, zero values are left out of the query.
+func (g *generator) writeQueryType(w *bytes.Buffer, operationName string, parameters []openapi.Parameter) (string, error) {
+	var query []openapi.Parameter
+	for _, parameter := range parameters {
+		if parameter.In == "query" {
+			query = append(query, parameter)
+		}
+	}
+
+	if len(query) == 0 {
+		return "", nil
+	}
+
+	g.imports["net/url"] = true
+
+	name := operationName + "Query"
+
+	fmt.Fprintf(w, "type %s struct {\n", name)
+	for _, parameter := range query {
+		fieldType, err := g.goType(parameter.Schema, false)
+		if err != nil {
+			return "", fmt.Errorf("query parameter %s: %w", parameter.Name, err)
+		}
+
+		fmt.Fprintf(w, "%s %s\n", exportedName(parameter.Name), fieldType)
+	}
+	w.WriteString("}\n\n")
+
+	fmt.Fprintf(w, "func (query %s) values() url.Values {\nvalues := url.Values{}\n", name)
+	for _, parameter := range query {
+		field := "query." + exportedName(parameter.Name)
+
+		switch parameter.Schema.Type {
+		case "integer":
+			g.imports["strconv"] = true
+			fmt.Fprintf(w, "if %s != 0 {\nvalues.Set(%q, strconv.Itoa(%s))\n}\n", field, parameter.Name, field)
+		case "string":
+			fmt.Fprintf(w, "if %s != \"\" {\nvalues.Set(%q, %s)\n}\n", field, parameter.Name, field)
+		default:
+			return "", fmt.Errorf("query parameter %s of type %s can not be described", parameter.Name, parameter.Schema.Type)
+		}
+	}
+	w.WriteString("return values\n}\n\n")
+
+	return name, nil
+}
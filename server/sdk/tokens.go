@@ -0,0 +1,67 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrNoOneTimeAccessToken = errors.New("no one time access token to exchange for a bearer token")
+
+// TokenSource provides the token of the Authorization header, without its "Bearer " or "Device " prefix.
+type TokenSource interface {
+	// Token returns the current token. After the server rejected it refresh is true and a new token should be
+	// returned, returning the same one again gives up.
+	Token(ctx context.Context, refresh bool) (string, error)
+}
+
+type staticToken string
+
+// StaticToken always returns the token, e.g. the token of a device or a bearer token kept by the dashboard.
+func StaticToken(token string) TokenSource {
+	return staticToken(token)
+}
+
+func (token staticToken) Token(_ context.Context, _ bool) (string, error) {
+	return string(token), nil
+}
+
+// OtatTokenSource exchanges one time access tokens for bearer tokens. Login links lead to the callback of the
+// dashboard with a one time access token, otat returns the next one, e.g. by sending a login link and waiting for
+// the callback, or ErrNoOneTimeAccessToken.
+type OtatTokenSource struct {
+	client *Client
+	otat   func(ctx context.Context) (string, error)
+
+	mu     sync.Mutex
+	bearer string
+}
+
+// NewOtatTokenSource exchanges the one time access tokens with the public route of the client, the client may be the
+// one using the token source.
+func NewOtatTokenSource(client *Client, otat func(ctx context.Context) (string, error)) *OtatTokenSource {
+	return &OtatTokenSource{client: client, otat: otat}
+}
+
+func (source *OtatTokenSource) Token(ctx context.Context, refresh bool) (string, error) {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+
+	if source.bearer != "" && !refresh {
+		return source.bearer, nil
+	}
+
+	otat, err := source.otat(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	bearer, err := source.client.AuthGetBearerTokenFromOtat(ctx, otat)
+	if err != nil {
+		return "", err
+	}
+
+	source.bearer = bearer
+
+	return bearer, nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/sdk"
+	"domanscy.group/parental-controls/server/validation"
+	"domanscy.group/rckstrvcache"
+)
+
+var update = flag.Bool("update", false, "update sdk/openapi.json, run go generate ./sdk afterwards")
+
+const sdkOpenApiDocumentPath = "sdk/openapi.json"
+
+// TestSdkOpenApiDocument keeps the copy of the document the sdk is generated from in sync with the handlers.
+func TestSdkOpenApiDocument(t *testing.T) {
+	encoded, err := json.MarshalIndent(openApiDocument(), "", "  ")
+	doTFatalIfErr(t, err)
+
+	encoded = append(encoded, '\n')
+
+	if *update {
+		doTFatalIfErr(t, os.WriteFile(sdkOpenApiDocumentPath, encoded, 0644))
+		return
+	}
+
+	current, err := os.ReadFile(sdkOpenApiDocumentPath)
+	doTFatalIfErr(t, err)
+
+	if !bytes.Equal(current, encoded) {
+		t.Fatalf("%s is out of date, run `go test -run TestSdkOpenApiDocument -update .` and `go generate ./sdk`", sdkOpenApiDocumentPath)
+	}
+}
+
+// wrapsSentinel reports whether the error of the sdk wraps the sentinel, the sentinels of the main package are declared
+// again by the sdk and are the same when their messages are.
+func wrapsSentinel(serverErr *sdk.Error, sentinel error) bool {
+	return slices.ContainsFunc(serverErr.Unwrap(), func(wrapped error) bool {
+		return wrapped == sentinel || wrapped.Error() == sentinel.Error()
+	})
+}
+
+// TestSdkErrors checks the sentinels generated from errorCodes are found by the codes the server responds with and
+// cover every sentinel of the handlers.
+func TestSdkErrors(t *testing.T) {
+	var responded error
+	var asDetail bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if asDetail {
+			respondWithError(w, r, http.StatusBadRequest, ErrInvalidFields, newFieldError("name", responded))
+			return
+		}
+
+		respondWithError(w, r, http.StatusBadRequest, responded)
+	}))
+	defer server.Close()
+
+	client := sdk.New(server.URL)
+	client.Bearer = sdk.StaticToken("token")
+
+	for sentinel, code := range errorCodes {
+		responded, asDetail = sentinel, false
+
+		_, err := client.HouseholdsList(context.Background())
+
+		var serverErr *sdk.Error
+		if !errors.As(err, &serverErr) {
+			t.Fatalf("%s: expected *sdk.Error, received %v", code, err)
+		}
+
+		if wrapsSentinel(serverErr, sentinel) {
+			continue
+		}
+
+		// the sentinels of the validation rules are found by the codes of the details only
+		asDetail = true
+
+		_, err = client.HouseholdsList(context.Background())
+		if !errors.As(err, &serverErr) || !wrapsSentinel(serverErr, sentinel) {
+			t.Errorf("%s: the sdk has no sentinel for %q, run go generate ./sdk", code, sentinel)
+		}
+	}
+
+	generated, err := parser.ParseFile(token.NewFileSet(), "sdk/generated_errors.go", nil, 0)
+	doTFatalIfErr(t, err)
+
+	declared := make(map[string]bool)
+	for name := range generated.Scope.Objects {
+		declared[name] = true
+	}
+
+	packages, err := parser.ParseDir(token.NewFileSet(), ".", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	doTFatalIfErr(t, err)
+
+	for _, file := range packages["main"].Files {
+		for _, declaration := range file.Decls {
+			general, ok := declaration.(*ast.GenDecl)
+			if !ok || general.Tok != token.VAR {
+				continue
+			}
+
+			for _, spec := range general.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					if strings.HasPrefix(name.Name, "Err") && !declared[name.Name] {
+						t.Errorf("%s has no code in errorCodes, so the sdk has no sentinel for it", name.Name)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestSdk calls the server through the sdk the way the dashboard and the agent do.
+func TestSdk(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	otatStore, otatErrCh, err := rckstrvcache.InitializeStore(time.Minute)
+	doTFatalIfErr(t, err)
+	defer func(store *rckstrvcache.Store) {
+		err := store.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(otatStore)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	server := httptest.NewServer(NewServer(*testingCfg, nil, otatStore, nil, db, nil, push.NewHub(push.DefaultHistorySize)))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	parent := sdk.New(server.URL)
+	parent.Bearer = sdk.NewOtatTokenSource(parent, func(ctx context.Context) (string, error) {
+		return otatStore.Put(fmt.Sprintf("userId:%d", family.userId))
+	})
+
+	t.Run("households and children", func(t *testing.T) {
+		owned, err := parent.HouseholdsList(ctx)
+		doTFatalIfErr(t, err)
+
+		if len(owned) != 1 || owned[0].Id != family.householdId {
+			t.Fatalf("Expected the household of the parent, received %+v", owned)
+		}
+
+		child, err := parent.HouseholdsChildrenCreate(ctx, family.householdId, sdk.CreateChildRequestBody{Name: "Ada", BirthDate: "2015-03-01"})
+		doTFatalIfErr(t, err)
+
+		if child.HouseholdId != family.householdId || child.Name != "Ada" || child.Age == nil {
+			t.Fatalf("Unexpected child: %+v", child)
+		}
+
+		listed, err := parent.HouseholdsChildrenList(ctx, family.householdId)
+		doTFatalIfErr(t, err)
+
+		if len(listed) != 2 {
+			t.Fatalf("Expected both children of the household, received %+v", listed)
+		}
+
+		_, err = parent.HouseholdsChildrenCreate(ctx, family.householdId, sdk.CreateChildRequestBody{})
+		if !errors.Is(err, sdk.ErrInvalidFields) || !errors.Is(err, validation.ErrRequired) {
+			t.Fatalf("Expected the name to be required, received %v", err)
+		}
+
+		err = parent.AuthLogin(ctx, sdk.LoginRequestBody{Email: "Parent <parent@localhost.local>", Callback: "/callback"})
+		if !errors.Is(err, sdk.ErrInvalidFields) || !errors.Is(err, validation.ErrInvalidEmail) || !errors.Is(err, validation.ErrInvalidUrl) {
+			t.Fatalf("Expected every broken rule of the body, received %v", err)
+		}
+
+		_, err = parent.HouseholdsChildrenList(ctx, stranger.householdId)
+
+		var serverErr *sdk.Error
+		if !errors.Is(err, sdk.ErrHouseholdNotFound) || !errors.As(err, &serverErr) || serverErr.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expected sdk.ErrHouseholdNotFound with 404, received %v", err)
+		}
+	})
+
+	t.Run("policies reach the device", func(t *testing.T) {
+		err := parent.ChildrenUpdateScreenTime(ctx, family.childId, sdk.UpdateScreenTimeRequestBody{DailyMinutes: 45})
+		doTFatalIfErr(t, err)
+
+		err = parent.ChildrenUpdateSafeSearch(ctx, family.childId, sdk.UpdateSafeSearchRequestBody{SafeSearch: true, YoutubeRestrictedMode: "strict"})
+		doTFatalIfErr(t, err)
+
+		rules, err := parent.ChildrenAppRulesCreate(ctx, family.childId, sdk.CreateAppRuleRequestBody{MatchType: "name", Pattern: "game.exe", Action: "block"})
+		doTFatalIfErr(t, err)
+
+		if len(rules) != 1 {
+			t.Fatalf("Expected the created rule, received %+v", rules)
+		}
+
+		templates, err := parent.HouseholdsPolicyTemplatesList(ctx, family.householdId)
+		doTFatalIfErr(t, err)
+
+		if len(templates) == 0 {
+			t.Fatal("Expected the built-in policy templates")
+		}
+
+		device := sdk.New(server.URL)
+		device.Device = sdk.StaticToken(family.deviceToken)
+
+		screenTime, err := device.DeviceScreenTime(ctx)
+		doTFatalIfErr(t, err)
+
+		if screenTime.DailyMinutes != 45 {
+			t.Fatalf("Expected the daily minutes set by the parent, received %+v", screenTime)
+		}
+
+		deviceRules, err := device.DeviceAppRulesList(ctx)
+		doTFatalIfErr(t, err)
+
+		if len(deviceRules) != 1 || deviceRules[0].Pattern != "game.exe" {
+			t.Fatalf("Expected the rule created by the parent, received %+v", deviceRules)
+		}
+	})
+
+	t.Run("devices", func(t *testing.T) {
+		created, err := parent.DevicesCreate(ctx, family.childId, sdk.CreateDeviceRequestBody{Name: "Tablet"})
+		doTFatalIfErr(t, err)
+
+		if created.ChildId != family.childId || created.DohUrl == "" || created.Token == "" || created.HeartbeatKey == "" {
+			t.Fatalf("Unexpected device: %+v", created)
+		}
+
+		health, err := parent.DevicesHealth(ctx, created.Id)
+		doTFatalIfErr(t, err)
+
+		if health.DeviceId != created.Id {
+			t.Fatalf("Unexpected health: %+v", health)
+		}
+
+		invalid := sdk.New(server.URL)
+		invalid.Device = sdk.StaticToken("invalid")
+
+		_, err = invalid.DeviceScreenTime(ctx)
+		if !errors.Is(err, sdk.ErrInvalidDeviceToken) {
+			t.Fatalf("Expected sdk.ErrInvalidDeviceToken, received %v", err)
+		}
+	})
+
+	select {
+	case err = <-otatErrCh:
+		t.Fatal(err)
+	default:
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/client/components"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/timeextensions"
+	"domanscy.group/parental-controls/server/webhooks"
+	"domanscy.group/rckstrvcache"
+	"github.com/go-chi/chi"
+)
+
+var ErrInvalidTimeExtensionRequestId = errors.New("invalid time extension request id")
+var ErrTimeExtensionRequestNotFound = errors.New("time extension request not found")
+var ErrInvalidDecisionLink = errors.New("decision link is invalid or has expired")
+var ErrInvalidDecision = errors.New("decision must be either approve or deny")
+
+// timeExtensionOtherAmounts are offered in the email next to the amount the child asked for.
+var timeExtensionOtherAmounts = []int{15, 30, 60}
+
+const timeExtensionTokenPrefix = "timeExtensionRequestId:"
+
+//go:embed mail_templates/time_extension_request.gohtml
+var timeExtensionRequestEmailBody string
+var timeExtensionRequestEmailTemplate = newMailTemplate(timeExtensionRequestEmailBody)
+
+type TimeExtensionRequestResponse struct {
+	Id               int    `json:"id"`
+	ChildId          int    `json:"childId"`
+	DeviceId         int    `json:"deviceId,omitempty"`
+	RequestedMinutes int    `json:"requestedMinutes"`
+	Reason           string `json:"reason"`
+	Status           string `json:"status"`
+	GrantedMinutes   int    `json:"grantedMinutes"`
+	// DecidedAt is null while the request is pending.
+	DecidedAt *time.Time `json:"decidedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func newTimeExtensionRequestResponse(request *timeextensions.Model) TimeExtensionRequestResponse {
+	response := TimeExtensionRequestResponse{
+		Id:               request.Id,
+		ChildId:          request.ChildId,
+		DeviceId:         request.DeviceId,
+		RequestedMinutes: request.RequestedMinutes,
+		Reason:           request.Reason,
+		Status:           string(request.Status),
+		GrantedMinutes:   request.GrantedMinutes,
+		CreatedAt:        request.CreatedAt,
+	}
+
+	if !request.DecidedAt.IsZero() {
+		response.DecidedAt = &request.DecidedAt
+	}
+
+	return response
+}
+
+func timeExtensionDecisionUrl(cfg *ServerConfig, token string, decision string, minutes int) string {
+	query := url.Values{"decision": {decision}}
+	if minutes != 0 {
+		query.Set("minutes", strconv.Itoa(minutes))
+	}
+
+	return fmt.Sprintf("%s/time_extension_requests/decide/%s?%s", cfg.AppUrl, url.PathEscape(token), query.Encode())
+}
+
+type CreateTimeExtensionRequestRequestBody struct {
+	Minutes int    `json:"minutes"`
+	Reason  string `json:"reason"`
+}
+
+func HttpDeviceTimeExtensionRequestsCreate(cfg *ServerConfig, timeExtensionTokensStore *rckstrvcache.Store, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestBody, err := decodeJsonRequestBody[CreateTimeExtensionRequestRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		device := authenticatedDevice(r)
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		requestId, err := timeextensions.Create(tx, device.ChildId, device.Id, requestBody.Minutes, strings.TrimSpace(requestBody.Reason))
+		if errors.Is(err, timeextensions.ErrInvalidMinutes) || errors.Is(err, timeextensions.ErrReasonTooLong) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create time extension request: %v", err)
+			return
+		}
+
+		request, err := timeextensions.FindOneById(tx, requestId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find created time extension request: %v", err)
+			return
+		}
+
+		child, parent, err := findChildAndParent(tx, device.ChildId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find parent of the child: %v", err)
+			return
+		}
+
+		requestResponse := newTimeExtensionRequestResponse(request)
+
+		err = enqueueWebhookEvent(tx, child, webhooks.EventTimeExtensionRequested, "", WebhookEventData{TimeExtensionRequest: &requestResponse}, time.Now())
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to enqueue webhook event: %v", err)
+			return
+		}
+
+		tokensTx, err := timeExtensionTokensStore.Begin()
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to begin time extension tokens store tx: %v", err)
+			return
+		}
+
+		token, err := tokensTx.Put(fmt.Sprintf("%s%d", timeExtensionTokenPrefix, requestId))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tokensTx.Rollback(), tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("an error occured while trying to generate decision token for time extension request %d: %v", requestId, err)
+			return
+		}
+
+		type amountLink struct {
+			Label string
+			Link  string
+		}
+
+		parentLanguage := userLanguage(parent)
+
+		otherAmountLinks := make([]amountLink, 0, len(timeExtensionOtherAmounts))
+
+		for _, minutes := range timeExtensionOtherAmounts {
+			if minutes == request.RequestedMinutes {
+				continue
+			}
+
+			otherAmountLinks = append(otherAmountLinks, amountLink{
+				Label: formatDuration(parentLanguage, minutes),
+				Link:  timeExtensionDecisionUrl(cfg, token, "approve", minutes),
+			})
+		}
+
+		emailBody, err := executeMailTemplate(timeExtensionRequestEmailTemplate, parentLanguage, struct {
+			ChildName        string
+			RequestedMinutes int
+			RequestedLabel   string
+			Reason           string
+			ApproveLink      string
+			OtherAmountLinks []amountLink
+			DenyLink         string
+		}{
+			ChildName:        child.Name,
+			RequestedMinutes: request.RequestedMinutes,
+			RequestedLabel:   formatDuration(parentLanguage, request.RequestedMinutes),
+			Reason:           request.Reason,
+			ApproveLink:      timeExtensionDecisionUrl(cfg, token, "approve", request.RequestedMinutes),
+			OtherAmountLinks: otherAmountLinks,
+			DenyLink:         timeExtensionDecisionUrl(cfg, token, "deny", 0),
+		})
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tokensTx.Rollback(), tx.Rollback())
+			log.Printf("failed to construct email template: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		err = sendMailAndHandleError(
+			w, r,
+			cfg.SmtpAddress,
+			cfg.SmtpPort,
+			cfg.EmailFromAddress,
+			parent.Email,
+			mailCatalog.Text(parentLanguage, "time_extension_request.subject", child.Name, request.RequestedMinutes),
+			emailBody,
+		)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tokensTx.Rollback(), tx.Rollback())
+			log.Printf("failed to send mail: %v", err)
+			return
+		}
+
+		err = tokensTx.Commit()
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			log.Printf("failed to commit to time extension tokens store: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusCreated, requestResponse)
+	}
+}
+
+// HttpDeviceTimeExtensionRequestsGet lets the device check whether the parent already decided about its request.
+func HttpDeviceTimeExtensionRequestsGet(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestId, err := strconv.Atoi(chi.URLParam(r, "requestId"))
+		if err != nil || requestId <= 0 {
+			respondWith400(w, r, ErrInvalidTimeExtensionRequestId)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		request, err := timeextensions.FindOneById(tx, requestId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find time extension request: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		// devices of the same child may look at each other's requests, the child is the one who gets the time
+		if request == nil || request.ChildId != authenticatedDevice(r).ChildId {
+			respondWith404(w, r, ErrTimeExtensionRequestNotFound)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, newTimeExtensionRequestResponse(request))
+	}
+}
+
+// HttpTimeExtensionRequestsDecide handles the links from the email, the token is removed after the first decision.
+func HttpTimeExtensionRequestsDecide(_ *ServerConfig, timeExtensionTokensStore *rckstrvcache.Store, pushHub *push.Hub, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := chi.URLParam(r, "token")
+
+		decision := r.URL.Query().Get("decision")
+		if decision != "approve" && decision != "deny" {
+			respondWith400(w, r, ErrInvalidDecision)
+			return
+		}
+
+		grantedMinutes := 0
+
+		if decision == "approve" {
+			minutes, err := strconv.Atoi(r.URL.Query().Get("minutes"))
+			if err != nil || minutes < 1 || minutes > timeextensions.MaxMinutes {
+				respondWith400(w, r, timeextensions.ErrInvalidMinutes)
+				return
+			}
+
+			grantedMinutes = minutes
+		}
+
+		tokenPayload, exists, err := timeExtensionTokensStore.Get(token)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to get time extension token from store: %v", err)
+			return
+		}
+
+		requestId, err := strconv.Atoi(strings.TrimPrefix(tokenPayload, timeExtensionTokenPrefix))
+		if !exists || err != nil || !strings.HasPrefix(tokenPayload, timeExtensionTokenPrefix) {
+			respondWith404(w, r, ErrInvalidDecisionLink)
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		request, err := timeextensions.FindOneById(tx, requestId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find time extension request: %v", err)
+			return
+		}
+
+		if request == nil {
+			err = tx.Rollback()
+			if err != nil {
+				log.Printf("failed to rollback the transaction: %v", err)
+			}
+
+			respondWith404(w, r, ErrTimeExtensionRequestNotFound)
+			return
+		}
+
+		child, parent, err := findChildAndParent(tx, request.ChildId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child and parent: %v", err)
+			return
+		}
+
+		// the link is opened by the parent the email was sent to
+		language := recipientLanguage(r, parent, "")
+
+		if decision == "approve" {
+			err = timeextensions.Approve(tx, request.Id, grantedMinutes, time.Now())
+		} else {
+			err = timeextensions.Deny(tx, request.Id, time.Now())
+		}
+
+		if errors.Is(err, timeextensions.ErrTimeExtensionRequestIsAlreadyDecided) {
+			err = tx.Rollback()
+			if err != nil {
+				log.Printf("failed to rollback the transaction: %v", err)
+			}
+
+			respondWithHtml(w, r, http.StatusConflict, components.AccessRequestAlreadyDecidedPage(language))
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to decide about time extension request: %v", err)
+			return
+		}
+
+		decidedRequest, err := timeextensions.FindOneById(tx, request.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find time extension request: %v", err)
+			return
+		}
+
+		decidedResponse := newTimeExtensionRequestResponse(decidedRequest)
+
+		err = enqueueWebhookEvent(tx, child, webhooks.EventTimeExtensionDecided, "", WebhookEventData{TimeExtensionRequest: &decidedResponse}, time.Now())
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to enqueue webhook event: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		_, err = timeExtensionTokensStore.Delete(token)
+		if err != nil {
+			// the request is decided already, the leftover token can only lead to the "already decided" page
+			log.Printf("error occured while trying to remove time extension token from store: %v", err)
+		}
+
+		// a request of a removed device is still shown to the other devices of the child
+		publishEvent(pushHub, decidedRequest.ChildId, decidedRequest.DeviceId, push.EventTypeTimeExtensionDecided, decidedResponse)
+
+		if decision == "approve" {
+			respondWithHtml(w, r, http.StatusOK, components.TimeExtensionApprovedPage(language, child.Name, grantedMinutes))
+		} else {
+			respondWithHtml(w, r, http.StatusOK, components.TimeExtensionDeniedPage(language, child.Name))
+		}
+	}
+}
+
+func HttpChildrenTimeExtensionRequestsList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		childId, err := parseChildIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		child, err := children.FindOneByIdAndOwnerUserId(tx, childId, authenticatedUserId(r))
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find child: %v", err)
+			return
+		}
+
+		if child == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrChildNotFound)
+			return
+		}
+
+		requests, err := timeextensions.FindAllByChildId(tx, child.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find time extension requests: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := make([]TimeExtensionRequestResponse, 0, len(requests))
+		for i := range requests {
+			response = append(response, newTimeExtensionRequestResponse(&requests[i]))
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mailpitsuite"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/push"
+	"domanscy.group/parental-controls/server/timeextensions"
+	"domanscy.group/parental-controls/server/users"
+	"domanscy.group/rckstrvcache"
+	"github.com/go-chi/chi"
+)
+
+func initializeTimeExtensionTokensStore(t *testing.T) *rckstrvcache.Store {
+	store, _, err := rckstrvcache.InitializeStore(time.Minute)
+	doTFatalIfErr(t, err)
+
+	t.Cleanup(func() {
+		err := store.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	return store
+}
+
+func createTestTimeExtensionRequest(t *testing.T, db *sql.DB, store *rckstrvcache.Store, family testFamily, minutes int) (int, string) {
+	tx, err := db.Begin()
+	doTFatalIfErr(t, err)
+
+	requestId, err := timeextensions.Create(tx, family.childId, family.deviceId, minutes, "homework")
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, tx.Commit())
+
+	token, err := store.Put(fmt.Sprintf("%s%d", timeExtensionTokenPrefix, requestId))
+	doTFatalIfErr(t, err)
+
+	return requestId, token
+}
+
+func findTestTimeExtensionRequest(t *testing.T, db *sql.DB, requestId int) *timeextensions.Model {
+	tx, err := db.Begin()
+	doTFatalIfErr(t, err)
+
+	request, err := timeextensions.FindOneById(tx, requestId)
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, tx.Commit())
+
+	return request
+}
+
+func TestHttpDeviceTimeExtensionRequestsCreate(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	store := initializeTimeExtensionTokensStore(t)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateDeviceToken(db)).Post("/device/time_extension_requests", HttpDeviceTimeExtensionRequestsCreate(testingCfg, store, db))
+
+	sendRequest := func(authorization string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/device/time_extension_requests", strings.NewReader(body))
+		request.Header.Set("Authorization", authorization)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("returns 401 without device token", func(t *testing.T) {
+		recorder := sendRequest("", `{"minutes": 30}`)
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusUnauthorized)
+		}
+
+		if recorder.Body.String() != ErrMissingDeviceToken.Error() {
+			t.Errorf("Got %s, want %s", recorder.Body.String(), ErrMissingDeviceToken.Error())
+		}
+	})
+
+	t.Run("returns 401 with unknown device token", func(t *testing.T) {
+		recorder := sendRequest("Device unknown", `{"minutes": 30}`)
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("returns 400 for invalid minutes", func(t *testing.T) {
+		recorder := sendRequest("Device "+family.deviceToken, `{"minutes": 0, "reason": "homework"}`)
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+
+		if recorder.Body.String() != timeextensions.ErrInvalidMinutes.Error() {
+			t.Errorf("Got %s, want %s", recorder.Body.String(), timeextensions.ErrInvalidMinutes.Error())
+		}
+	})
+
+	t.Run("creates request and emails the parent decision links", func(t *testing.T) {
+		mailpit := initializeMailpitAndDeleteAllMessages(t)
+		defer func(mailpit *mailpitsuite.Api) {
+			err := mailpit.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}(mailpit)
+
+		recorder := sendRequest("Device "+family.deviceToken, `{"minutes": 30, "reason": "homework"}`)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		var response TimeExtensionRequestResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if response.Status != string(timeextensions.StatusPending) || response.RequestedMinutes != 30 || response.ChildId != family.childId {
+			t.Errorf("Expected pending request of child %d for 30 minutes, received %+v", family.childId, response)
+		}
+
+		messages, err := mailpit.GetAllMessages()
+		if err != nil {
+			t.Fatalf("failed to get mailpit messages: %s", err.Error())
+		}
+
+		if len(messages) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(messages))
+		}
+
+		messageSummary, err := mailpit.GetMessageSummary(messages[0].ID)
+		doTFatalIfErr(t, err)
+
+		tokens, err := store.GetAllKeys()
+		doTFatalIfErr(t, err)
+
+		if len(tokens) != 1 {
+			t.Fatalf("Expected one decision token, received %d", len(tokens))
+		}
+
+		for _, expectedLink := range []string{
+			timeExtensionDecisionUrl(testingCfg, tokens[0], "approve", 30),
+			timeExtensionDecisionUrl(testingCfg, tokens[0], "approve", 15),
+			timeExtensionDecisionUrl(testingCfg, tokens[0], "deny", 0),
+		} {
+			if !strings.Contains(messageSummary.HTML, strings.ReplaceAll(expectedLink, "&", "&amp;")) {
+				t.Errorf("Expected email to contain %s, received:\n%s", expectedLink, messageSummary.HTML)
+			}
+		}
+	})
+}
+
+func TestHttpTimeExtensionRequestsDecide(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	store := initializeTimeExtensionTokensStore(t)
+
+	pushHub := push.NewHub(push.DefaultHistorySize)
+
+	router := chi.NewRouter()
+	router.Get("/time_extension_requests/decide/{token}", HttpTimeExtensionRequestsDecide(testingCfg, store, pushHub, db))
+
+	sendRequest := func(token string, query string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:8080/time_extension_requests/decide/%s?%s", token, query), nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("approves with another amount and invalidates the link", func(t *testing.T) {
+		requestId, token := createTestTimeExtensionRequest(t, db, store, family, 30)
+
+		subscription, _ := pushHub.Subscribe(family.childId, family.deviceId, 0)
+		defer subscription.Close()
+
+		recorder := sendRequest(token, "decision=approve&minutes=15")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		request := findTestTimeExtensionRequest(t, db, requestId)
+		if request.Status != timeextensions.StatusApproved || request.GrantedMinutes != 15 {
+			t.Errorf("Expected request approved for 15 minutes, received %+v", request)
+		}
+
+		select {
+		case event := <-subscription.Events():
+			if event.Type != push.EventTypeTimeExtensionDecided || event.DeviceId != family.deviceId || !strings.Contains(string(event.Data), `"grantedMinutes":15`) {
+				t.Errorf("Expected time extension event for the device, received %+v", event)
+			}
+		default:
+			t.Error("Expected time extension decided event to be published")
+		}
+
+		recorder = sendRequest(token, "decision=deny")
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("denies request", func(t *testing.T) {
+		requestId, token := createTestTimeExtensionRequest(t, db, store, family, 60)
+
+		recorder := sendRequest(token, "decision=deny")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		request := findTestTimeExtensionRequest(t, db, requestId)
+		if request.Status != timeextensions.StatusDenied || request.GrantedMinutes != 0 {
+			t.Errorf("Expected denied request, received %+v", request)
+		}
+	})
+
+	t.Run("returns 400 for unknown decision or amount", func(t *testing.T) {
+		_, token := createTestTimeExtensionRequest(t, db, store, family, 30)
+
+		for _, query := range []string{"decision=maybe", "decision=approve", fmt.Sprintf("decision=approve&minutes=%d", timeextensions.MaxMinutes+1)} {
+			recorder := sendRequest(token, query)
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("%s: got %d, want %d", query, recorder.Code, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("returns 404 for unknown token", func(t *testing.T) {
+		recorder := sendRequest("unknown", "decision=deny")
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("shows the page in the language of the parent", func(t *testing.T) {
+		_, token := createTestTimeExtensionRequest(t, db, store, family, 30)
+
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, users.UpdateLanguage(tx, family.userId, "en"))
+		doTFatalIfErr(t, tx.Commit())
+
+		request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:8080/time_extension_requests/decide/%s?decision=deny", token), nil)
+		request.Header.Set("Accept-Language", "pl")
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "will not get extra time") {
+			t.Fatalf("Expected the English page, received %d: %s", recorder.Code, recorder.Body.String())
+		}
+	})
+}
+
+func TestHttpTimeExtensionRequestsHistory(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+	store := initializeTimeExtensionTokensStore(t)
+
+	requestId, _ := createTestTimeExtensionRequest(t, db, store, family, 30)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateDeviceToken(db)).Get("/device/time_extension_requests/{requestId}", HttpDeviceTimeExtensionRequestsGet(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/children/{childId}/time_extension_requests", HttpChildrenTimeExtensionRequestsList(testingCfg, db))
+
+	sendRequest := func(path string, authorization string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil)
+		request.Header.Set("Authorization", authorization)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("device sees status of its request", func(t *testing.T) {
+		recorder := sendRequest(fmt.Sprintf("/device/time_extension_requests/%d", requestId), "Device "+family.deviceToken)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response TimeExtensionRequestResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if response.Id != requestId || response.Status != string(timeextensions.StatusPending) || response.DecidedAt != nil {
+			t.Errorf("Expected pending request %d, received %+v", requestId, response)
+		}
+	})
+
+	t.Run("device of another child can not see the request", func(t *testing.T) {
+		recorder := sendRequest(fmt.Sprintf("/device/time_extension_requests/%d", requestId), "Device "+stranger.deviceToken)
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("parent sees request history", func(t *testing.T) {
+		recorder := sendRequest(fmt.Sprintf("/children/%d/time_extension_requests", family.childId), bearerHeaderForUser(t, family.userId))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var response []TimeExtensionRequestResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if len(response) != 1 || response[0].Id != requestId || response[0].Reason != "homework" {
+			t.Errorf("Expected history with request %d, received %+v", requestId, response)
+		}
+	})
+
+	t.Run("returns 404 for history of someone else's child", func(t *testing.T) {
+		recorder := sendRequest(fmt.Sprintf("/children/%d/time_extension_requests", stranger.childId), bearerHeaderForUser(t, family.userId))
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"domanscy.group/parental-controls/server/timetokens"
+)
+
+type CreateTimeTokenRequestBody struct {
+	Nonce string `json:"nonce"`
+}
+
+// HttpDeviceTimeTokensCreate attests the time of the server to the agent, which keeps counting from it while
+// offline and refuses clocks set back before it.
+func HttpDeviceTimeTokensCreate(_ *ServerConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device := authenticatedDevice(r)
+
+		requestBody, err := decodeJsonRequestBody[CreateTimeTokenRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		token, err := timetokens.Issue(device.HeartbeatKey, device.Id, requestBody.Nonce, time.Now())
+		if errors.Is(err, timetokens.ErrInvalidNonce) {
+			respondWith400(w, r, err)
+			return
+		} else if err != nil {
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusOK, token)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/timetokens"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpDeviceTimeTokensCreate(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	heartbeatKey := findTestHeartbeatKey(t, db, family.deviceId)
+
+	router := chi.NewRouter()
+	router.With(AuthenticateDeviceToken(db)).Post("/device/time_tokens", HttpDeviceTimeTokensCreate(testingCfg))
+
+	sendRequest := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/device/time_tokens", strings.NewReader(body))
+		request.Header.Set("Authorization", "Device "+family.deviceToken)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("issues a token signed with the heartbeat key", func(t *testing.T) {
+		nonce := "2f1c0a9e5b7d4e3f8a6b"
+
+		recorder := sendRequest(`{"nonce": "` + nonce + `"}`)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+		}
+
+		var token timetokens.Token
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &token))
+		doTFatalIfErr(t, token.Verify(heartbeatKey, family.deviceId, nonce))
+
+		if time.Since(token.IssuedAt).Abs() > time.Minute {
+			t.Fatalf("Got token issued at %v, want now", token.IssuedAt)
+		}
+	})
+
+	t.Run("rejects invalid nonces", func(t *testing.T) {
+		recorder := sendRequest(`{"nonce": "short"}`)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusBadRequest)
+		}
+	})
+}
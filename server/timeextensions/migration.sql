@@ -0,0 +1,13 @@
+CREATE TABLE time_extension_requests (
+    id INTEGER PRIMARY KEY,
+    child_id INTEGER NOT NULL REFERENCES children (id) ON DELETE CASCADE,
+    device_id INTEGER REFERENCES devices (id) ON DELETE SET NULL,
+    requested_minutes INTEGER NOT NULL,
+    reason VARCHAR NOT NULL,
+    status VARCHAR NOT NULL DEFAULT 'pending',
+    granted_minutes INTEGER NOT NULL DEFAULT 0,
+    decided_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX time_extension_requests_child_id ON time_extension_requests (child_id, created_at);
@@ -0,0 +1,199 @@
+package timeextensions
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxMinutes limits both the requested and the granted amount of a single request.
+const MaxMinutes = 240
+
+// MaxReasonLength is counted in characters, not bytes.
+const MaxReasonLength = 500
+
+var ErrInvalidMinutes = fmt.Errorf("minutes must be between 1 and %d", MaxMinutes)
+var ErrReasonTooLong = fmt.Errorf("reason can not be longer than %d characters", MaxReasonLength)
+var ErrTimeExtensionRequestWithThisIdDoesNotExist = errors.New("time extension request with this id does not exist")
+var ErrTimeExtensionRequestIsAlreadyDecided = errors.New("time extension request is already approved or denied")
+
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusDenied   Status = "denied"
+)
+
+type Model struct {
+	Id      int
+	ChildId int
+	// DeviceId is 0 when the device the request came from has been removed.
+	DeviceId         int
+	RequestedMinutes int
+	Reason           string
+	Status           Status
+	// GrantedMinutes may differ from RequestedMinutes when the parent approved another amount, 0 unless approved.
+	GrantedMinutes int
+	// DecidedAt is zero while the request is pending.
+	DecidedAt time.Time
+	CreatedAt time.Time
+}
+
+//go:embed migration.sql
+var MigrationFile string
+
+const selectColumns = "id, child_id, device_id, requested_minutes, reason, status, granted_minutes, decided_at, created_at"
+
+func scanTimeExtensionRequest(row interface{ Scan(dest ...any) error }) (*Model, error) {
+	request := &Model{}
+
+	var deviceId sql.NullInt64
+	var decidedAt sql.NullTime
+
+	err := row.Scan(
+		&request.Id,
+		&request.ChildId,
+		&deviceId,
+		&request.RequestedMinutes,
+		&request.Reason,
+		&request.Status,
+		&request.GrantedMinutes,
+		&decidedAt,
+		&request.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	request.DeviceId = int(deviceId.Int64)
+	request.DecidedAt = decidedAt.Time
+
+	return request, nil
+}
+
+func FindOneById(db *sql.Tx, id int) (*Model, error) {
+	request, err := scanTimeExtensionRequest(db.QueryRow("SELECT "+selectColumns+" FROM time_extension_requests WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return request, nil
+}
+
+// FindAllByChildId returns the request history of the child, newest first.
+func FindAllByChildId(db *sql.Tx, childId int) ([]Model, error) {
+	rows, err := db.Query("SELECT "+selectColumns+" FROM time_extension_requests WHERE child_id = $1 ORDER BY created_at DESC, id DESC", childId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM time_extension_requests ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	requests := make([]Model, 0)
+
+	for rows.Next() {
+		request, err := scanTimeExtensionRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		requests = append(requests, *request)
+	}
+
+	return requests, nil
+}
+
+func validateMinutes(minutes int) error {
+	if minutes < 1 || minutes > MaxMinutes {
+		return ErrInvalidMinutes
+	}
+
+	return nil
+}
+
+func Create(db *sql.Tx, childId int, deviceId int, requestedMinutes int, reason string) (int, error) {
+	if err := validateMinutes(requestedMinutes); err != nil {
+		return 0, err
+	}
+
+	if utf8.RuneCountInString(reason) > MaxReasonLength {
+		return 0, ErrReasonTooLong
+	}
+
+	var nullableDeviceId sql.NullInt64
+	if deviceId != 0 {
+		nullableDeviceId = sql.NullInt64{Int64: int64(deviceId), Valid: true}
+	}
+
+	exec, err := db.Exec(
+		"INSERT INTO time_extension_requests (child_id, device_id, requested_minutes, reason, status) VALUES (?, ?, ?, ?, ?);",
+		childId,
+		nullableDeviceId,
+		requestedMinutes,
+		reason,
+		StatusPending,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO time_extension_requests ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+func decide(db *sql.Tx, id int, status Status, grantedMinutes int, decidedAt time.Time) error {
+	request, err := FindOneById(db, id)
+	if err != nil {
+		return err
+	}
+
+	if request == nil {
+		return ErrTimeExtensionRequestWithThisIdDoesNotExist
+	}
+
+	if request.Status != StatusPending {
+		return ErrTimeExtensionRequestIsAlreadyDecided
+	}
+
+	_, err = db.Exec(
+		"UPDATE time_extension_requests SET status = ?, granted_minutes = ?, decided_at = ? WHERE id = ? AND status = ?",
+		status,
+		grantedMinutes,
+		decidedAt.UTC(),
+		id,
+		StatusPending,
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE time_extension_requests ...': %w", err)
+	}
+
+	return nil
+}
+
+func Approve(db *sql.Tx, id int, grantedMinutes int, now time.Time) error {
+	if err := validateMinutes(grantedMinutes); err != nil {
+		return err
+	}
+
+	return decide(db, id, StatusApproved, grantedMinutes, now)
+}
+
+func Deny(db *sql.Tx, id int, now time.Time) error {
+	return decide(db, id, StatusDenied, 0, now)
+}
@@ -0,0 +1,124 @@
+package timeextensions
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0010_time_extensions": MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestTimeExtensionRequests(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	now := time.Now()
+
+	t.Run("returns error for invalid minutes", func(t *testing.T) {
+		for _, minutes := range []int{0, -5, MaxMinutes + 1} {
+			_, err := Create(tx, 1, 1, minutes, "homework")
+			if !errors.Is(err, ErrInvalidMinutes) {
+				t.Errorf("%d: expected %v, received %v", minutes, ErrInvalidMinutes, err)
+			}
+		}
+	})
+
+	t.Run("returns error for too long reason", func(t *testing.T) {
+		_, err := Create(tx, 1, 1, 30, strings.Repeat("ą", MaxReasonLength+1))
+		if !errors.Is(err, ErrReasonTooLong) {
+			t.Errorf("Expected %v, received %v", ErrReasonTooLong, err)
+		}
+	})
+
+	t.Run("approves with another amount", func(t *testing.T) {
+		id, err := Create(tx, 1, 1, 30, "homework")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Approve(tx, id, 15, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request, err := FindOneById(tx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if request.Status != StatusApproved || request.RequestedMinutes != 30 || request.GrantedMinutes != 15 || request.DecidedAt.IsZero() {
+			t.Errorf("Expected request approved for 15 of 30 minutes, received %+v", request)
+		}
+
+		err = Deny(tx, id, now)
+		if !errors.Is(err, ErrTimeExtensionRequestIsAlreadyDecided) {
+			t.Errorf("Expected %v, received %v", ErrTimeExtensionRequestIsAlreadyDecided, err)
+		}
+	})
+
+	t.Run("lists history of the child newest first", func(t *testing.T) {
+		id, err := Create(tx, 1, 0, 60, "movie")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Deny(tx, id, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Create(tx, 2, 0, 60, "other child")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		requests, err := FindAllByChildId(tx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(requests) != 2 || requests[0].Id != id || requests[0].Status != StatusDenied || requests[0].DeviceId != 0 {
+			t.Errorf("Expected denied request %d first of 2, received %+v", id, requests)
+		}
+	})
+
+	t.Run("returns error when request does not exist", func(t *testing.T) {
+		err := Approve(tx, 9999, 30, now)
+		if !errors.Is(err, ErrTimeExtensionRequestWithThisIdDoesNotExist) {
+			t.Errorf("Expected %v, received %v", ErrTimeExtensionRequestWithThisIdDoesNotExist, err)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package timetokens
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"domanscy.group/parental-controls/server/heartbeats"
+)
+
+// MinNonceLength and MaxNonceLength bound the random nonce of the agent, a fresh nonce tells the agent
+// the token was issued for its request and is not a replay of an older one.
+const MinNonceLength = 16
+const MaxNonceLength = 128
+
+var ErrInvalidNonce = fmt.Errorf("nonce must have between %d and %d characters", MinNonceLength, MaxNonceLength)
+var ErrInvalidToken = errors.New("invalid time token")
+
+// Token attests the time of the server at the moment it was issued, signed with the heartbeat key of the device,
+// so an agent offline later can tell a clock set back from time that really passed.
+type Token struct {
+	DeviceId  int       `json:"deviceId"`
+	Nonce     string    `json:"nonce"`
+	IssuedAt  time.Time `json:"issuedAt"`
+	Signature string    `json:"signature"`
+}
+
+func signedPayload(deviceId int, nonce string, issuedAt time.Time) []byte {
+	return []byte(strconv.Itoa(deviceId) + "\n" + nonce + "\n" + issuedAt.UTC().Format(time.RFC3339Nano))
+}
+
+func Issue(key string, deviceId int, nonce string, now time.Time) (Token, error) {
+	if length := utf8.RuneCountInString(nonce); length < MinNonceLength || length > MaxNonceLength {
+		return Token{}, ErrInvalidNonce
+	}
+
+	issuedAt := now.UTC()
+
+	return Token{
+		DeviceId:  deviceId,
+		Nonce:     nonce,
+		IssuedAt:  issuedAt,
+		Signature: heartbeats.Sign(key, signedPayload(deviceId, nonce, issuedAt)),
+	}, nil
+}
+
+// Verify checks the token was issued by the server for the device in response to the nonce.
+func (token Token) Verify(key string, deviceId int, nonce string) error {
+	if token.DeviceId != deviceId || token.Nonce != nonce || token.IssuedAt.IsZero() {
+		return ErrInvalidToken
+	}
+
+	if !heartbeats.VerifySignature(key, signedPayload(token.DeviceId, token.Nonce, token.IssuedAt), token.Signature) {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package timetokens
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	now := time.Date(2024, 9, 1, 16, 0, 0, 123456789, time.FixedZone("CEST", 2*60*60))
+	nonce := strings.Repeat("n", MinNonceLength)
+
+	_, err := Issue("key", 1, "short", now)
+	if !errors.Is(err, ErrInvalidNonce) {
+		t.Fatalf("Expected %v, received %v", ErrInvalidNonce, err)
+	}
+
+	token, err := Issue("key", 1, nonce, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Token
+
+	err = json.Unmarshal(encoded, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := decoded.Verify("key", 1, nonce); err != nil {
+		t.Fatalf("Expected the token to survive encoding, received %v", err)
+	}
+
+	if !decoded.IssuedAt.Equal(now) {
+		t.Fatalf("Got %v, want %v", decoded.IssuedAt, now)
+	}
+
+	rolledBack := decoded
+	rolledBack.IssuedAt = rolledBack.IssuedAt.Add(-time.Hour)
+
+	for name, check := range map[string]error{
+		"other key":         decoded.Verify("other", 1, nonce),
+		"other device":      decoded.Verify("key", 2, nonce),
+		"replayed token":    decoded.Verify("key", 1, strings.Repeat("m", MinNonceLength)),
+		"changed issued at": rolledBack.Verify("key", 1, nonce),
+	} {
+		if !errors.Is(check, ErrInvalidToken) {
+			t.Errorf("%s: expected %v, received %v", name, ErrInvalidToken, check)
+		}
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN language VARCHAR NOT NULL DEFAULT '';
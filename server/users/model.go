@@ -17,17 +17,22 @@ type Model struct {
 	Id        int
 	Email     string
 	CreatedAt time.Time
+	// Language is the language the user reads the emails in, e.g. "en", empty until they choose one.
+	Language string
 }
 
 //go:embed migration.sql
 var MigrationFile string
 
+//go:embed migration_language.sql
+var LanguageMigrationFile string
+
 func FindOneById(db *sql.Tx, id int) (*Model, error) {
-	row := db.QueryRow("SELECT id, email, created_at FROM users WHERE id = $1", id)
+	row := db.QueryRow("SELECT id, email, created_at, language FROM users WHERE id = $1", id)
 
 	user := &Model{}
 
-	err := row.Scan(&user.Id, &user.Email, &user.CreatedAt)
+	err := row.Scan(&user.Id, &user.Email, &user.CreatedAt, &user.Language)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	} else if err != nil {
@@ -38,11 +43,11 @@ func FindOneById(db *sql.Tx, id int) (*Model, error) {
 }
 
 func FindOneByEmail(db *sql.Tx, email string) (*Model, error) {
-	row := db.QueryRow("SELECT * FROM users WHERE email = $1", email)
+	row := db.QueryRow("SELECT id, email, created_at, language FROM users WHERE email = $1", email)
 
 	user := &Model{}
 
-	err := row.Scan(&user.Id, &user.Email, &user.CreatedAt)
+	err := row.Scan(&user.Id, &user.Email, &user.CreatedAt, &user.Language)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	} else if err != nil {
@@ -62,9 +67,9 @@ func GetAllByEmailSearch(db *sql.Tx, emailPart string) ([]Model, error) {
 
 	queryParam := "%" + emailPart + "%"
 
-	rows, err := db.Query("SELECT * FROM users WHERE email LIKE $1", queryParam)
+	rows, err := db.Query("SELECT id, email, created_at, language FROM users WHERE email LIKE $1", queryParam)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query 'SELECT * FROM users ...': %w", err)
+		return nil, fmt.Errorf("failed to execute query 'SELECT id, email, created_at, language FROM users ...': %w", err)
 	}
 
 	defer func(rows *sql.Rows) {
@@ -78,7 +83,7 @@ func GetAllByEmailSearch(db *sql.Tx, emailPart string) ([]Model, error) {
 
 	for rows.Next() {
 		user := Model{}
-		err := rows.Scan(&user.Id, &user.Email, &user.CreatedAt)
+		err := rows.Scan(&user.Id, &user.Email, &user.CreatedAt, &user.Language)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
 		}
@@ -135,3 +140,22 @@ func Update(db *sql.Tx, id int, newEmail string) error {
 
 	return nil
 }
+
+// UpdateLanguage stores the language the user reads the emails in.
+func UpdateLanguage(db *sql.Tx, id int, language string) error {
+	executed, err := db.Exec("UPDATE users SET language = ? WHERE id = ?", language, id)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE users SET language ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	if affectedRows == 0 {
+		return ErrUserWithThisIdDoesNotExist
+	}
+
+	return nil
+}
@@ -16,7 +16,8 @@ func TestFindOneByEmail(t *testing.T) {
 	}
 
 	err = database.Migrate(db, map[string]string{
-		"0001_users": MigrationFile,
+		"0001_users":          MigrationFile,
+		"0027_users_language": LanguageMigrationFile,
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -95,7 +96,8 @@ func TestFindOneById(t *testing.T) {
 	}
 
 	err = database.Migrate(db, map[string]string{
-		"0001_users": MigrationFile,
+		"0001_users":          MigrationFile,
+		"0027_users_language": LanguageMigrationFile,
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -179,7 +181,8 @@ func TestGetAllByEmailSearch(t *testing.T) {
 	}
 
 	err = database.Migrate(db, map[string]string{
-		"0001_users": MigrationFile,
+		"0001_users":          MigrationFile,
+		"0027_users_language": LanguageMigrationFile,
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -300,7 +303,8 @@ func TestCreate(t *testing.T) {
 		}
 
 		err = database.Migrate(db, map[string]string{
-			"0001_users": MigrationFile,
+			"0001_users":          MigrationFile,
+			"0027_users_language": LanguageMigrationFile,
 		})
 		if err != nil {
 			t.Fatal(err)
@@ -343,7 +347,8 @@ func TestCreate(t *testing.T) {
 		}
 
 		err = database.Migrate(db, map[string]string{
-			"0001_users": MigrationFile,
+			"0001_users":          MigrationFile,
+			"0027_users_language": LanguageMigrationFile,
 		})
 		if err != nil {
 			t.Fatal(err)
@@ -410,7 +415,8 @@ func TestCreate(t *testing.T) {
 		}
 
 		err = database.Migrate(db, map[string]string{
-			"0001_users": MigrationFile,
+			"0001_users":          MigrationFile,
+			"0027_users_language": LanguageMigrationFile,
 		})
 		if err != nil {
 			t.Fatal(err)
@@ -461,7 +467,8 @@ func TestUpdate(t *testing.T) {
 		}
 
 		err = database.Migrate(db, map[string]string{
-			"0001_users": MigrationFile,
+			"0001_users":          MigrationFile,
+			"0027_users_language": LanguageMigrationFile,
 		})
 		if err != nil {
 			t.Fatal(err)
@@ -511,7 +518,8 @@ func TestUpdate(t *testing.T) {
 		}
 
 		err = database.Migrate(db, map[string]string{
-			"0001_users": MigrationFile,
+			"0001_users":          MigrationFile,
+			"0027_users_language": LanguageMigrationFile,
 		})
 		if err != nil {
 			t.Fatal(err)
@@ -543,7 +551,8 @@ func TestUpdate(t *testing.T) {
 		}
 
 		err = database.Migrate(db, map[string]string{
-			"0001_users": MigrationFile,
+			"0001_users":          MigrationFile,
+			"0027_users_language": LanguageMigrationFile,
 		})
 		if err != nil {
 			t.Fatal(err)
@@ -579,3 +588,63 @@ func TestUpdate(t *testing.T) {
 		}
 	})
 }
+
+func TestUpdateLanguage(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = database.Migrate(db, map[string]string{
+		"0001_users":          MigrationFile,
+		"0027_users_language": LanguageMigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Commit()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	id, err := Create(tx, "user@domain.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userModel, err := FindOneById(tx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if userModel.Language != "" {
+		t.Errorf("Expected no language of a new user, received '%s'.", userModel.Language)
+	}
+
+	err = UpdateLanguage(tx, id, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userModel, err = FindOneByEmail(tx, "user@domain.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if userModel.Language != "en" {
+		t.Errorf("Expected language to be equal to 'en', received '%s'.", userModel.Language)
+	}
+
+	err = UpdateLanguage(tx, id+1, "en")
+	if !errors.Is(err, ErrUserWithThisIdDoesNotExist) {
+		t.Errorf("Expected ErrUserWithThisIdDoesNotExist, received: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/users"
+)
+
+type UpdateLanguageRequestBody struct {
+	// Language is the language of the emails sent to the parent, e.g. "en".
+	Language string `json:"language" validate:"required,oneof=pl en"`
+}
+
+// HttpUsersUpdateLanguage lets the parent choose the language of their emails, it takes precedence over the
+// Accept-Language header and the client they log in from.
+func HttpUsersUpdateLanguage(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestBody, err := decodeJsonRequestBody[UpdateLanguageRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		err = users.UpdateLanguage(tx, authenticatedUserId(r), requestBody.Language)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to update language of the user: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"domanscy.group/parental-controls/server/users"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpUsersUpdateLanguage(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg), LoadUserLanguage(db)).Put("/me/language", HttpUsersUpdateLanguage(testingCfg, db))
+
+	sendRequest := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, "http://localhost:8080/me/language", strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, family.userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	findLanguage := func(t *testing.T) string {
+		tx, err := db.Begin()
+		doTFatalIfErr(t, err)
+
+		user, err := users.FindOneById(tx, family.userId)
+		doTFatalIfErr(t, err)
+		doTFatalIfErr(t, tx.Commit())
+
+		return user.Language
+	}
+
+	t.Run("has no language until the parent chooses one", func(t *testing.T) {
+		if language := findLanguage(t); language != "" {
+			t.Fatalf("Got %q, want no language", language)
+		}
+	})
+
+	t.Run("updates the language", func(t *testing.T) {
+		recorder := sendRequest(`{"language": "en"}`)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		if language := findLanguage(t); language != "en" {
+			t.Fatalf("Got %q, want en", language)
+		}
+
+		if language := userLanguage(&users.Model{Language: findLanguage(t)}); language != "en" {
+			t.Fatalf("Got %q, want the emails in en", language)
+		}
+	})
+
+	t.Run("rejects unsupported languages", func(t *testing.T) {
+		for _, body := range []string{`{"language": "de"}`, `{"language": ""}`, `{}`} {
+			recorder := sendRequest(body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+
+		if language := findLanguage(t); language != "en" {
+			t.Fatalf("Got %q, want en", language)
+		}
+	})
+
+	t.Run("writes errors in the chosen language instead of the one of the browser", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPut, "http://localhost:8080/me/language", strings.NewReader(`{"language": "de"}`))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, family.userId))
+		request.Header.Set("Accept", "application/json")
+		request.Header.Set("Accept-Language", "pl")
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		var response ErrorResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if response.Error.Message != ErrInvalidFields.Error() || len(response.Error.Details) != 1 || response.Error.Details[0].Message != "must be one of pl, en" {
+			t.Fatalf("Expected the error in English, received %+v", response.Error)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+// Package validation checks decoded request bodies against the rules in the validate tags of their fields:
+//
+//	type LoginRequestBody struct {
+//		Email string `json:"email" validate:"required,email"`
+//	}
+//
+// The rules are required, email, url, length=min..max, oneof=a b c and timeofday. Rules other than required accept
+// the zero value, so optional fields are only checked when they are set. The rules of a slice of strings apply to
+// every item, nested structs and slices of structs are checked as well.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	RuleRequired  = "required"
+	RuleEmail     = "email"
+	RuleUrl       = "url"
+	RuleLength    = "length"
+	RuleOneOf     = "oneof"
+	RuleTimeOfDay = "timeofday"
+)
+
+// TimeOfDayLayout is the format of the timeofday rule, hours and minutes of the 24-hour clock.
+const TimeOfDayLayout = "15:04"
+
+var ErrInvalidTag = errors.New("invalid validate tag")
+
+type Rule struct {
+	Name string
+	// Min and Max bound the length in characters of the length rule, Max is -1 when the length is not bounded.
+	Min int
+	Max int
+	// Values are the allowed values of the oneof rule.
+	Values []string
+}
+
+// ParseRules parses the validate tag of a field, e.g. "required,length=1..50". The bounds of length are optional,
+// "length=..50" and "length=1.." bound one side only.
+func ParseRules(tag string) ([]Rule, error) {
+	if tag == "" {
+		return nil, nil
+	}
+
+	var rules []Rule
+
+	for _, part := range strings.Split(tag, ",") {
+		name, parameter, hasParameter := strings.Cut(part, "=")
+
+		rule := Rule{Name: name, Max: -1}
+
+		switch name {
+		case RuleRequired, RuleEmail, RuleUrl, RuleTimeOfDay:
+			if hasParameter {
+				return nil, fmt.Errorf("%w: %s takes no parameter in %q", ErrInvalidTag, name, tag)
+			}
+		case RuleLength:
+			minimum, maximum, found := strings.Cut(parameter, "..")
+			if !found || minimum == "" && maximum == "" {
+				return nil, fmt.Errorf("%w: length must be min..max in %q", ErrInvalidTag, tag)
+			}
+
+			var err error
+
+			if minimum != "" {
+				rule.Min, err = strconv.Atoi(minimum)
+				if err != nil || rule.Min < 0 {
+					return nil, fmt.Errorf("%w: invalid minimum length in %q", ErrInvalidTag, tag)
+				}
+			}
+
+			if maximum != "" {
+				rule.Max, err = strconv.Atoi(maximum)
+				if err != nil || rule.Max < rule.Min {
+					return nil, fmt.Errorf("%w: invalid maximum length in %q", ErrInvalidTag, tag)
+				}
+			}
+		case RuleOneOf:
+			rule.Values = strings.Fields(parameter)
+			if len(rule.Values) == 0 {
+				return nil, fmt.Errorf("%w: oneof requires values in %q", ErrInvalidTag, tag)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown rule %q in %q", ErrInvalidTag, name, tag)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
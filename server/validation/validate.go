@@ -0,0 +1,231 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrRequired = errors.New("required field is missing")
+var ErrInvalidEmail = errors.New("invalid email address")
+var ErrInvalidUrl = errors.New("invalid url")
+var ErrTooShort = errors.New("value is too short")
+var ErrTooLong = errors.New("value is too long")
+var ErrNotOneOf = errors.New("value is not allowed")
+var ErrInvalidTimeOfDay = errors.New("invalid time of day")
+
+// messages describe the broken rules, formatted with the Args of the FieldError.
+var messages = map[error]string{
+	ErrRequired:         "is required",
+	ErrInvalidEmail:     "must be an email address",
+	ErrInvalidUrl:       "must be an absolute http or https url",
+	ErrTooShort:         "must have at least %d characters",
+	ErrTooLong:          "can have at most %d characters",
+	ErrNotOneOf:         "must be one of %s",
+	ErrInvalidTimeOfDay: "must be a time of day in format HH:MM",
+}
+
+// FieldError is a rule broken by a field, Err is the sentinel of the rule.
+type FieldError struct {
+	// Field is the path of the field in the json body, e.g. "events[2].type".
+	Field string
+	Err   error
+	// Args are the parameters of the rule in its message, e.g. the maximum length.
+	Args []any
+}
+
+// Message describes the rule, e.g. "can have at most 50 characters".
+func (fieldError FieldError) Message() string {
+	return fmt.Sprintf(messages[fieldError.Err], fieldError.Args...)
+}
+
+func (fieldError FieldError) Error() string {
+	return fieldError.Field + ": " + fieldError.Message()
+}
+
+func (fieldError FieldError) Unwrap() error {
+	return fieldError.Err
+}
+
+// Errors are all the rules broken by a value.
+type Errors []FieldError
+
+func (errs Errors) Error() string {
+	described := make([]string, 0, len(errs))
+	for _, fieldError := range errs {
+		described = append(described, fieldError.Error())
+	}
+
+	return strings.Join(described, "; ")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Validate checks the fields of the struct value, or of the struct it points to, against their rules and returns
+// every broken one. It panics on an invalid validate tag, as it is a mistake in the type.
+func Validate(value any) Errors {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	var errs Errors
+	validateStruct(v, "", &errs)
+
+	return errs
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *Errors) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			validateStruct(v.Field(i), prefix, errs)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		rules, err := ParseRules(field.Tag.Get("validate"))
+		if err != nil {
+			panic(fmt.Sprintf("validation: field %s of %s: %v", field.Name, t, err))
+		}
+
+		validateValue(v.Field(i), prefix+name, rules, errs)
+	}
+}
+
+func validateValue(v reflect.Value, path string, rules []Rule, errs *Errors) {
+	for _, rule := range rules {
+		if rule.Name == RuleRequired && isMissing(v) {
+			*errs = append(*errs, FieldError{Field: path, Err: ErrRequired})
+			return
+		}
+	}
+
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Kind() == reflect.String:
+		for _, rule := range rules {
+			if fieldError, ok := checkString(v.String(), path, rule); !ok {
+				*errs = append(*errs, fieldError)
+			}
+		}
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), withoutRequired(rules), errs)
+		}
+	case v.Kind() == reflect.Struct && v.Type() != timeType:
+		mustOnlyRequire(v, path, rules)
+		validateStruct(v, path+".", errs)
+	default:
+		mustOnlyRequire(v, path, rules)
+	}
+}
+
+// mustOnlyRequire panics when rules for strings are declared for a value of another type.
+func mustOnlyRequire(v reflect.Value, path string, rules []Rule) {
+	for _, rule := range rules {
+		if rule.Name != RuleRequired {
+			panic(fmt.Sprintf("validation: rule %s of %s can not check a %s", rule.Name, path, v.Type()))
+		}
+	}
+}
+
+// isMissing reports whether a required field is missing, strings of white space and empty slices are missing too.
+func isMissing(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+
+	return v.IsZero()
+}
+
+// withoutRequired leaves out the required rule of a slice, which is about the slice and not about its items.
+func withoutRequired(rules []Rule) []Rule {
+	var itemRules []Rule
+	for _, rule := range rules {
+		if rule.Name != RuleRequired {
+			itemRules = append(itemRules, rule)
+		}
+	}
+
+	return itemRules
+}
+
+func checkString(value string, path string, rule Rule) (FieldError, bool) {
+	if value == "" || rule.Name == RuleRequired {
+		return FieldError{}, true
+	}
+
+	switch rule.Name {
+	case RuleEmail:
+		address, err := mail.ParseAddress(value)
+		if err != nil || address.Name != "" || address.Address == "" {
+			return FieldError{Field: path, Err: ErrInvalidEmail}, false
+		}
+	case RuleUrl:
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return FieldError{Field: path, Err: ErrInvalidUrl}, false
+		}
+	case RuleLength:
+		length := utf8.RuneCountInString(value)
+		if length < rule.Min {
+			return FieldError{Field: path, Err: ErrTooShort, Args: []any{rule.Min}}, false
+		}
+
+		if rule.Max >= 0 && length > rule.Max {
+			return FieldError{Field: path, Err: ErrTooLong, Args: []any{rule.Max}}, false
+		}
+	case RuleOneOf:
+		for _, allowed := range rule.Values {
+			if value == allowed {
+				return FieldError{}, true
+			}
+		}
+
+		return FieldError{Field: path, Err: ErrNotOneOf, Args: []any{strings.Join(rule.Values, ", ")}}, false
+	case RuleTimeOfDay:
+		_, err := time.Parse(TimeOfDayLayout, value)
+		if err != nil || len(value) != len(TimeOfDayLayout) {
+			return FieldError{Field: path, Err: ErrInvalidTimeOfDay}, false
+		}
+	}
+
+	return FieldError{}, true
+}
@@ -0,0 +1,147 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSchedule struct {
+	Start string `json:"start" validate:"required,timeofday"`
+	End   string `json:"end" validate:"timeofday"`
+}
+
+type testBody struct {
+	Email     string         `json:"email" validate:"required,email"`
+	Callback  string         `json:"callback" validate:"url"`
+	Name      string         `json:"name" validate:"required,length=2..5"`
+	Mode      *string        `json:"mode" validate:"oneof=strict moderate"`
+	Tags      []string       `json:"tags" validate:"required,length=..3"`
+	Schedules []testSchedule `json:"schedules"`
+	SentAt    time.Time      `json:"sentAt" validate:"required"`
+	Ignored   string         `json:"-" validate:"required"`
+	internal  string
+}
+
+func validTestBody() testBody {
+	mode := "strict"
+
+	return testBody{
+		Email:     "parent@localhost.local",
+		Callback:  "https://localhost/callback",
+		Name:      "Ada",
+		Mode:      &mode,
+		Tags:      []string{"a", "abc"},
+		Schedules: []testSchedule{{Start: "07:30", End: "21:00"}},
+		SentAt:    time.Now(),
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if errs := Validate(validTestBody()); errs != nil {
+		t.Fatalf("Expected a valid body, received %v", errs)
+	}
+
+	invalidMode := "off"
+
+	cases := []struct {
+		name   string
+		modify func(body *testBody)
+		want   Errors
+	}{
+		{"missing required fields", func(body *testBody) { body.Email = " "; body.Tags = nil; body.SentAt = time.Time{} },
+			Errors{{Field: "email", Err: ErrRequired}, {Field: "tags", Err: ErrRequired}, {Field: "sentAt", Err: ErrRequired}}},
+		{"email with a name", func(body *testBody) { body.Email = "Parent <parent@localhost.local>" },
+			Errors{{Field: "email", Err: ErrInvalidEmail}}},
+		{"relative url", func(body *testBody) { body.Callback = "/callback" },
+			Errors{{Field: "callback", Err: ErrInvalidUrl}}},
+		{"url of another scheme", func(body *testBody) { body.Callback = "ftp://localhost/callback" },
+			Errors{{Field: "callback", Err: ErrInvalidUrl}}},
+		{"length in characters", func(body *testBody) { body.Name = "żółwik" },
+			Errors{{Field: "name", Err: ErrTooLong, Args: []any{5}}}},
+		{"too short", func(body *testBody) { body.Name = "A" },
+			Errors{{Field: "name", Err: ErrTooShort, Args: []any{2}}}},
+		{"value of a pointer", func(body *testBody) { body.Mode = &invalidMode },
+			Errors{{Field: "mode", Err: ErrNotOneOf, Args: []any{"strict, moderate"}}}},
+		{"items of a slice", func(body *testBody) { body.Tags = []string{"a", "abcd", "b", "efgh"} },
+			Errors{{Field: "tags[1]", Err: ErrTooLong, Args: []any{3}}, {Field: "tags[3]", Err: ErrTooLong, Args: []any{3}}}},
+		{"fields of nested structs", func(body *testBody) { body.Schedules = append(body.Schedules, testSchedule{End: "24:00"}) },
+			Errors{{Field: "schedules[1].start", Err: ErrRequired}, {Field: "schedules[1].end", Err: ErrInvalidTimeOfDay}}},
+		{"time of day without leading zero", func(body *testBody) { body.Schedules[0].Start = "7:30" },
+			Errors{{Field: "schedules[0].start", Err: ErrInvalidTimeOfDay}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			body := validTestBody()
+			c.modify(&body)
+
+			errs := Validate(&body)
+			if !reflect.DeepEqual(errs, c.want) {
+				t.Fatalf("Expected %v, received %v", c.want, errs)
+			}
+		})
+	}
+}
+
+func TestValidateOptionalFields(t *testing.T) {
+	body := validTestBody()
+	body.Callback = ""
+	body.Mode = nil
+	body.Schedules = nil
+
+	if errs := Validate(body); errs != nil {
+		t.Fatalf("Expected zero values of optional fields to be valid, received %v", errs)
+	}
+}
+
+func TestFieldErrorMessages(t *testing.T) {
+	errs := Errors{{Field: "name", Err: ErrTooLong, Args: []any{5}}, {Field: "email", Err: ErrRequired}}
+
+	if errs.Error() != "name: can have at most 5 characters; email: is required" {
+		t.Fatalf("Unexpected message %q", errs.Error())
+	}
+
+	if !errors.Is(errs[0], ErrTooLong) {
+		t.Fatal("Expected the field error to wrap the sentinel of the rule")
+	}
+
+	for sentinel, message := range messages {
+		if strings.Contains(message, "%!") {
+			t.Fatalf("%v: invalid message %q", sentinel, message)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("required,length=..50,oneof=a b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Rule{{Name: RuleRequired, Max: -1}, {Name: RuleLength, Max: 50}, {Name: RuleOneOf, Max: -1, Values: []string{"a", "b"}}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("Expected %+v, received %+v", want, rules)
+	}
+
+	for _, tag := range []string{"unknown", "email=1", "length", "length=..", "length=5..1", "length=a..", "oneof="} {
+		_, err := ParseRules(tag)
+		if !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("%q: expected ErrInvalidTag, received %v", tag, err)
+		}
+	}
+}
+
+func TestValidatePanicsOnRulesOfOtherTypes(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected a panic")
+		}
+	}()
+
+	Validate(struct {
+		Days int `json:"days" validate:"email"`
+	}{Days: 1})
+}
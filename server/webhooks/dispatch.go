@@ -0,0 +1,191 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domanscy.group/littlehelpers"
+)
+
+// MaxAttempts is how many times a delivery is sent before it is dead, with Backoff the last one is about 4 hours
+// after the first.
+const MaxAttempts = 10
+
+const firstRetryDelay = 30 * time.Second
+const maxRetryDelay = 6 * time.Hour
+
+// maxErrorLength keeps long error messages out of the delivery log.
+const maxErrorLength = 500
+
+const (
+	HeaderDeliveryId = "X-Webhook-Delivery"
+	HeaderEventType  = "X-Webhook-Event"
+	HeaderTimestamp  = "X-Webhook-Timestamp"
+	HeaderSignature  = "X-Webhook-Signature"
+)
+
+// Backoff is the delay after the attempt with the given number, it doubles with every attempt.
+func Backoff(attempt int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxRetryDelay)
+}
+
+// Sign returns the signature of the payload sent at the unix timestamp, receivers compute it the same way
+// and compare it with the HeaderSignature: hex of HMAC-SHA256 of "<timestamp>.<body>", prefixed with "sha256=".
+// The timestamp is signed so an intercepted payload can not be replayed much later.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Payload is the body of every delivery, Data depends on the Type.
+type Payload struct {
+	Id          int             `json:"id"`
+	Type        EventType       `json:"type"`
+	HouseholdId int             `json:"householdId"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// Dispatcher sends due deliveries, it is run by the scheduler and never concurrently with itself.
+type Dispatcher struct {
+	Client *http.Client
+	// BatchSize limits the deliveries sent in one run, the rest waits for the next one.
+	BatchSize int
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		Client: &http.Client{
+			Timeout: 10 * time.Second,
+			// a redirect could point the signed payload at an internal address, it counts as a failed delivery instead
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		BatchSize: 50,
+	}
+}
+
+// send posts the delivery to the subscription, it returns the status code of the response or the reason there was none.
+func (dispatcher *Dispatcher) send(ctx context.Context, subscription *Subscription, delivery *Delivery, now time.Time) (int, string) {
+	body, err := json.Marshal(Payload{
+		Id:          delivery.Event.Id,
+		Type:        delivery.Event.Type,
+		HouseholdId: delivery.Event.HouseholdId,
+		CreatedAt:   delivery.Event.CreatedAt,
+		Data:        delivery.Event.Data,
+	})
+	if err != nil {
+		return 0, fmt.Sprintf("failed to encode payload: %v", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	timestamp := now.Unix()
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "parental-controls-webhooks/1")
+	request.Header.Set(HeaderDeliveryId, strconv.Itoa(delivery.Id))
+	request.Header.Set(HeaderEventType, string(delivery.Event.Type))
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, body))
+
+	response, err := dispatcher.Client.Do(request)
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	defer response.Body.Close()
+
+	// the body is read so the connection can be reused, it is never kept as it may echo whatever the receiver holds
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Sprintf("receiver responded with status %d", response.StatusCode)
+	}
+
+	return response.StatusCode, ""
+}
+
+// DeliverDue sends the deliveries due at now and records the results, a delivery that can not be recorded is sent again.
+func (dispatcher *Dispatcher) DeliverDue(ctx context.Context, db *sql.DB, now time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open database transaction: %w", err)
+	}
+
+	deliveries, err := FindAllDueDeliveries(tx, now, dispatcher.BatchSize)
+	if err != nil {
+		return littlehelpers.IfErrJoin(err, tx.Rollback())
+	}
+
+	subscriptions := make(map[int]*Subscription)
+
+	for _, delivery := range deliveries {
+		if _, ok := subscriptions[delivery.SubscriptionId]; ok {
+			continue
+		}
+
+		subscriptions[delivery.SubscriptionId], err = FindOneSubscriptionById(tx, delivery.SubscriptionId)
+		if err != nil {
+			return littlehelpers.IfErrJoin(err, tx.Rollback())
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit the transaction: %w", err)
+	}
+
+	for _, delivery := range deliveries {
+		subscription := subscriptions[delivery.SubscriptionId]
+		if subscription == nil {
+			continue
+		}
+
+		statusCode, errorMessage := dispatcher.send(ctx, subscription, &delivery, now)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to open database transaction: %w", err)
+		}
+
+		err = RecordAttempt(tx, delivery.Id, statusCode, errorMessage, now)
+		if err != nil {
+			return littlehelpers.IfErrJoin(err, tx.Rollback())
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return fmt.Errorf("failed to commit the transaction: %w", err)
+		}
+	}
+
+	return nil
+}
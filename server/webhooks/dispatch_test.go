@@ -0,0 +1,191 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/households"
+)
+
+func TestSign(t *testing.T) {
+	// computed with: printf '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+
+	if got := Sign("secret", 1700000000, []byte("{}")); got != want {
+		t.Fatalf("Expected %s, received %s", want, got)
+	}
+}
+
+func TestDispatcherDeliverDue(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+
+	var mu sync.Mutex
+	var requests []received
+	failing := true
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, received{header: r.Header.Clone(), body: body})
+
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("internal details"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	householdId, err := households.Create(tx, 1, "Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscriptionId, err := CreateSubscription(tx, householdId, receiver.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscription, err := FindOneSubscriptionById(tx, subscriptionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	err = Enqueue(tx, householdId, EventTamperDetected, "", map[string]string{"type": "agent_stopped"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := NewDispatcher()
+
+	err = dispatcher.DeliverDue(context.Background(), db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// not due before the backoff is over
+	err = dispatcher.DeliverDue(context.Background(), db, now.Add(Backoff(1)-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := FindAllDeliveriesBySubscriptionId(tx, subscriptionId, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 1 || deliveries[0].LastError != "receiver responded with status 503" {
+		t.Fatalf("Expected only the status of the failed attempt to be logged, received %+v", deliveries)
+	}
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+
+	err = dispatcher.DeliverDue(context.Background(), db, now.Add(Backoff(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, received %d", len(requests))
+	}
+
+	last := requests[1]
+
+	timestamp, err := strconv.ParseInt(last.header.Get(HeaderTimestamp), 10, 64)
+	if err != nil || timestamp != now.Add(Backoff(1)).Unix() {
+		t.Fatalf("Unexpected timestamp %q", last.header.Get(HeaderTimestamp))
+	}
+
+	if last.header.Get(HeaderSignature) != Sign(subscription.Secret, timestamp, last.body) || last.header.Get(HeaderEventType) != string(EventTamperDetected) {
+		t.Fatalf("Unexpected headers %v", last.header)
+	}
+
+	var payload Payload
+	err = json.Unmarshal(last.body, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.Type != EventTamperDetected || payload.HouseholdId != householdId || string(payload.Data) != `{"type":"agent_stopped"}` {
+		t.Fatalf("Unexpected payload %s", last.body)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tx.Rollback()
+
+	deliveries, err = FindAllDeliveriesBySubscriptionId(tx, subscriptionId, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 1 || deliveries[0].Status != DeliveryDelivered || deliveries[0].Attempts != 2 || deliveries[0].LastStatusCode != http.StatusNoContent {
+		t.Fatalf("Unexpected deliveries %+v", deliveries)
+	}
+}
+
+func TestDispatcherDoesNotFollowRedirects(t *testing.T) {
+	var redirected bool
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	delivery := &Delivery{Event: Event{Type: EventTamperDetected, Data: json.RawMessage(`{}`)}}
+
+	statusCode, errorMessage := NewDispatcher().send(context.Background(), &Subscription{Url: receiver.URL, Secret: "secret"}, delivery, time.Now())
+	if redirected || statusCode != http.StatusTemporaryRedirect || errorMessage != "receiver responded with status 307" {
+		t.Fatalf("Expected the redirect to fail the delivery, received %d %q, followed: %t", statusCode, errorMessage, redirected)
+	}
+}
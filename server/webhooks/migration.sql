@@ -0,0 +1,37 @@
+CREATE TABLE webhook_subscriptions (
+    id INTEGER PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    url VARCHAR NOT NULL,
+    -- key of the HMAC signature of payloads, only shown to the parent when the subscription is created
+    secret VARCHAR NOT NULL,
+    -- comma separated event types, empty for all of them
+    event_types VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE webhook_events (
+    id INTEGER PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    type VARCHAR NOT NULL,
+    -- events detected more than once, e.g. the limit reached on every upload of activity, are stored once per key
+    dedup_key VARCHAR UNIQUE,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+    id INTEGER PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id INTEGER NOT NULL REFERENCES webhook_events (id) ON DELETE CASCADE,
+    status VARCHAR NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_attempt_at TIMESTAMP,
+    -- 0 when the receiver did not respond at all
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_status_next_attempt_at ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX webhook_deliveries_subscription_id ON webhook_deliveries (subscription_id);
@@ -0,0 +1,474 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MaxSubscriptions is how many webhooks a household may have.
+const MaxSubscriptions = 10
+
+// MaxUrlLength is counted in bytes.
+const MaxUrlLength = 2048
+
+var ErrInvalidUrl = fmt.Errorf("url must be an absolute http or https url of at most %d characters", MaxUrlLength)
+var ErrInvalidEventType = errors.New("invalid event type")
+var ErrTooManySubscriptions = fmt.Errorf("a household can have at most %d webhooks", MaxSubscriptions)
+var ErrSubscriptionWithThisIdDoesNotExist = errors.New("webhook with this id does not exist")
+var ErrDeliveryWithThisIdDoesNotExist = errors.New("webhook delivery with this id does not exist")
+
+//go:embed migration.sql
+var MigrationFile string
+
+type EventType string
+
+const (
+	EventTimeExtensionRequested EventType = "time_extension_requested"
+	EventTimeExtensionDecided   EventType = "time_extension_decided"
+	EventAccessRequested        EventType = "access_requested"
+	EventAccessRequestDecided   EventType = "access_request_decided"
+	// EventScreenTimeLimitReached is sent once a day per child, when the uploaded activity uses up the budget.
+	EventScreenTimeLimitReached EventType = "screen_time_limit_reached"
+	EventTamperDetected         EventType = "tamper_detected"
+	EventChoreCompleted         EventType = "chore_completed"
+)
+
+var EventTypes = []EventType{
+	EventTimeExtensionRequested,
+	EventTimeExtensionDecided,
+	EventAccessRequested,
+	EventAccessRequestDecided,
+	EventScreenTimeLimitReached,
+	EventTamperDetected,
+	EventChoreCompleted,
+}
+
+func (eventType EventType) IsValid() bool {
+	for _, valid := range EventTypes {
+		if eventType == valid {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Subscription struct {
+	Id          int
+	HouseholdId int
+	Url         string
+	Secret      string
+	// EventTypes is empty when the subscription wants all events.
+	EventTypes []EventType
+	CreatedAt  time.Time
+}
+
+func (subscription *Subscription) Wants(eventType EventType) bool {
+	if len(subscription.EventTypes) == 0 {
+		return true
+	}
+
+	for _, wanted := range subscription.EventTypes {
+		if wanted == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Event struct {
+	Id          int
+	HouseholdId int
+	Type        EventType
+	// Data is the json of the event, it is sent as the data of the payload.
+	Data      json.RawMessage
+	CreatedAt time.Time
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead is a delivery that failed MaxAttempts times, it is only sent again when redelivered.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+type Delivery struct {
+	Id             int
+	SubscriptionId int
+	Event          Event
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	// LastAttemptAt is zero before the first attempt.
+	LastAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("an unknown error occured while trying to generate random bytes using crypto/rand.Read: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+const selectSubscriptionColumns = "id, household_id, url, secret, event_types, created_at"
+
+func scanSubscription(row interface{ Scan(dest ...any) error }) (*Subscription, error) {
+	subscription := &Subscription{EventTypes: make([]EventType, 0)}
+
+	var eventTypes string
+
+	err := row.Scan(&subscription.Id, &subscription.HouseholdId, &subscription.Url, &subscription.Secret, &eventTypes, &subscription.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, eventType := range strings.Split(eventTypes, ",") {
+		if eventType != "" {
+			subscription.EventTypes = append(subscription.EventTypes, EventType(eventType))
+		}
+	}
+
+	return subscription, nil
+}
+
+func FindOneSubscriptionById(db *sql.Tx, id int) (*Subscription, error) {
+	subscription, err := scanSubscription(db.QueryRow("SELECT "+selectSubscriptionColumns+" FROM webhook_subscriptions WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error occured while trying to scan the row for values: %w", err)
+	}
+
+	return subscription, nil
+}
+
+func FindAllSubscriptionsByHouseholdId(db *sql.Tx, householdId int) ([]Subscription, error) {
+	rows, err := db.Query("SELECT "+selectSubscriptionColumns+" FROM webhook_subscriptions WHERE household_id = $1 ORDER BY id", householdId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM webhook_subscriptions ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	subscriptions := make([]Subscription, 0)
+
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		subscriptions = append(subscriptions, *subscription)
+	}
+
+	return subscriptions, nil
+}
+
+// CreateSubscription generates the secret payloads of the subscription are signed with.
+func CreateSubscription(db *sql.Tx, householdId int, rawUrl string, eventTypes []EventType) (int, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || len(rawUrl) > MaxUrlLength || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return 0, ErrInvalidUrl
+	}
+
+	encodedEventTypes := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		if !eventType.IsValid() {
+			return 0, ErrInvalidEventType
+		}
+
+		encodedEventTypes = append(encodedEventTypes, string(eventType))
+	}
+
+	existing, err := FindAllSubscriptionsByHouseholdId(db, householdId)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(existing) >= MaxSubscriptions {
+		return 0, ErrTooManySubscriptions
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return 0, err
+	}
+
+	exec, err := db.Exec(
+		"INSERT INTO webhook_subscriptions (household_id, url, secret, event_types) VALUES (?, ?, ?, ?);",
+		householdId,
+		rawUrl,
+		secret,
+		strings.Join(encodedEventTypes, ","),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO webhook_subscriptions ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// DeleteSubscription removes the deliveries of the subscription too, pending ones are never sent.
+func DeleteSubscription(db *sql.Tx, householdId int, id int) error {
+	executed, err := db.Exec("DELETE FROM webhook_subscriptions WHERE id = ? AND household_id = ?", id, householdId)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'DELETE FROM webhook_subscriptions ...': %w", err)
+	}
+
+	affectedRows, err := executed.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unknown error occured when trying to get number of affected rows: %w", err)
+	}
+
+	if affectedRows == 0 {
+		return ErrSubscriptionWithThisIdDoesNotExist
+	}
+
+	// foreign keys are not enforced, the deliveries would outlive the subscription
+	_, err = db.Exec("DELETE FROM webhook_deliveries WHERE subscription_id = ?", id)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'DELETE FROM webhook_deliveries ...': %w", err)
+	}
+
+	return nil
+}
+
+// Enqueue stores the event and a pending delivery for every subscription of the household that wants it, it is meant
+// to run in the transaction of the change the event is about, so neither is kept without the other.
+// An event with the dedupKey of an earlier one is dropped, an empty dedupKey never matches.
+func Enqueue(db *sql.Tx, householdId int, eventType EventType, dedupKey string, data any, now time.Time) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode data of %s event: %w", eventType, err)
+	}
+
+	subscriptions, err := FindAllSubscriptionsByHouseholdId(db, householdId)
+	if err != nil {
+		return err
+	}
+
+	var eventId int
+
+	err = db.QueryRow(
+		"INSERT INTO webhook_events (household_id, type, dedup_key, data, created_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT (dedup_key) DO NOTHING RETURNING id;",
+		householdId,
+		eventType,
+		sql.NullString{String: dedupKey, Valid: dedupKey != ""},
+		string(encoded),
+		now.UTC(),
+	).Scan(&eventId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'INSERT INTO webhook_events ...': %w", err)
+	}
+
+	for _, subscription := range subscriptions {
+		if !subscription.Wants(eventType) {
+			continue
+		}
+
+		_, err := createDelivery(db, subscription.Id, eventId, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createDelivery(db *sql.Tx, subscriptionId int, eventId int, now time.Time) (int, error) {
+	exec, err := db.Exec(
+		"INSERT INTO webhook_deliveries (subscription_id, event_id, status, next_attempt_at) VALUES (?, ?, ?, ?);",
+		subscriptionId,
+		eventId,
+		DeliveryPending,
+		now.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to execute query 'INSERT INTO webhook_deliveries ...': %w", err)
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("an error occured while trying to get last inserted id from database: %w", err)
+	}
+
+	return int(id), nil
+}
+
+const selectDeliveryColumns = "webhook_deliveries.id, webhook_deliveries.subscription_id, webhook_events.id, webhook_events.household_id, webhook_events.type, webhook_events.data, webhook_events.created_at, " +
+	"webhook_deliveries.status, webhook_deliveries.attempts, webhook_deliveries.next_attempt_at, webhook_deliveries.last_attempt_at, webhook_deliveries.last_status_code, webhook_deliveries.last_error, webhook_deliveries.created_at"
+
+const fromDeliveries = " FROM webhook_deliveries JOIN webhook_events ON webhook_events.id = webhook_deliveries.event_id "
+
+func scanDelivery(row interface{ Scan(dest ...any) error }) (*Delivery, error) {
+	delivery := &Delivery{}
+
+	var data string
+	var lastAttemptAt sql.NullTime
+
+	err := row.Scan(
+		&delivery.Id,
+		&delivery.SubscriptionId,
+		&delivery.Event.Id,
+		&delivery.Event.HouseholdId,
+		&delivery.Event.Type,
+		&data,
+		&delivery.Event.CreatedAt,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&lastAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Event.Data = json.RawMessage(data)
+	delivery.LastAttemptAt = lastAttemptAt.Time
+
+	return delivery, nil
+}
+
+func findAllDeliveries(db *sql.Tx, query string, args ...any) ([]Delivery, error) {
+	rows, err := db.Query("SELECT "+selectDeliveryColumns+fromDeliveries+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query 'SELECT ... FROM webhook_deliveries ...': %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error occured while trying to close rows reader: %v", err)
+		}
+	}(rows)
+
+	deliveries := make([]Delivery, 0)
+
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the row for values: %w", err)
+		}
+
+		deliveries = append(deliveries, *delivery)
+	}
+
+	return deliveries, nil
+}
+
+func FindOneDeliveryById(db *sql.Tx, id int) (*Delivery, error) {
+	deliveries, err := findAllDeliveries(db, "WHERE webhook_deliveries.id = $1", id)
+	if err != nil || len(deliveries) == 0 {
+		return nil, err
+	}
+
+	return &deliveries[0], nil
+}
+
+// FindAllDeliveriesBySubscriptionId returns the newest deliveries first.
+func FindAllDeliveriesBySubscriptionId(db *sql.Tx, subscriptionId int, limit int) ([]Delivery, error) {
+	return findAllDeliveries(db, "WHERE webhook_deliveries.subscription_id = $1 ORDER BY webhook_deliveries.id DESC LIMIT $2", subscriptionId, limit)
+}
+
+// FindAllDueDeliveries returns pending deliveries whose next attempt is not after now, the oldest first.
+func FindAllDueDeliveries(db *sql.Tx, now time.Time, limit int) ([]Delivery, error) {
+	return findAllDeliveries(
+		db,
+		"WHERE webhook_deliveries.status = $1 AND webhook_deliveries.next_attempt_at <= $2 ORDER BY webhook_deliveries.next_attempt_at, webhook_deliveries.id LIMIT $3",
+		DeliveryPending,
+		now.UTC(),
+		limit,
+	)
+}
+
+// RecordAttempt stores the result of sending the delivery, statusCode is 0 and errorMessage set when the receiver
+// did not respond. Deliveries not answered with a 2xx status are retried later, until MaxAttempts.
+func RecordAttempt(db *sql.Tx, id int, statusCode int, errorMessage string, now time.Time) error {
+	delivery, err := FindOneDeliveryById(db, id)
+	if err != nil {
+		return err
+	}
+
+	if delivery == nil {
+		return ErrDeliveryWithThisIdDoesNotExist
+	}
+
+	attempts := delivery.Attempts + 1
+	status := DeliveryPending
+	nextAttemptAt := now.Add(Backoff(attempts))
+
+	if statusCode >= 200 && statusCode < 300 {
+		status = DeliveryDelivered
+		errorMessage = ""
+	} else if attempts >= MaxAttempts {
+		status = DeliveryDead
+	}
+
+	if len(errorMessage) > maxErrorLength {
+		errorMessage = errorMessage[:maxErrorLength]
+	}
+
+	_, err = db.Exec(
+		"UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_attempt_at = ?, last_status_code = ?, last_error = ? WHERE id = ?",
+		status,
+		attempts,
+		nextAttemptAt.UTC(),
+		now.UTC(),
+		statusCode,
+		errorMessage,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("an error occured while trying to execute query 'UPDATE webhook_deliveries ...': %w", err)
+	}
+
+	return nil
+}
+
+// Redeliver sends the event of the delivery again as a new delivery, whatever the status of the old one is.
+func Redeliver(db *sql.Tx, id int, now time.Time) (int, error) {
+	delivery, err := FindOneDeliveryById(db, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if delivery == nil {
+		return 0, ErrDeliveryWithThisIdDoesNotExist
+	}
+
+	return createDelivery(db, delivery.SubscriptionId, delivery.Event.Id, now)
+}
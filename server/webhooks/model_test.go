@@ -0,0 +1,225 @@
+package webhooks
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/database"
+	"domanscy.group/parental-controls/server/households"
+	"domanscy.group/parental-controls/server/users"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db, map[string]string{
+		"0001_users":      users.MigrationFile,
+		"0002_households": households.MigrationFile,
+		"0026_webhooks":   MigrationFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		9:  128 * time.Minute,
+		10: 256 * time.Minute,
+		20: 6 * time.Hour,
+	}
+
+	for attempt, want := range tests {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("attempt %d: expected %v, received %v", attempt, want, got)
+		}
+	}
+}
+
+func TestSubscriptionsAndDeliveries(t *testing.T) {
+	db := openDatabase(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	householdId, err := households.Create(tx, 1, "Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("validates subscriptions", func(t *testing.T) {
+		invalid := []struct {
+			url        string
+			eventTypes []EventType
+			want       error
+		}{
+			{"ftp://example.com/hook", nil, ErrInvalidUrl},
+			{"/hook", nil, ErrInvalidUrl},
+			{"https://example.com/hook", []EventType{"screen_time_exceeded"}, ErrInvalidEventType},
+		}
+
+		for _, test := range invalid {
+			_, err := CreateSubscription(tx, householdId, test.url, test.eventTypes)
+			if !errors.Is(err, test.want) {
+				t.Errorf("%s: expected %v, received %v", test.url, test.want, err)
+			}
+		}
+	})
+
+	var allId, choresId int
+
+	t.Run("enqueues events for subscriptions that want them once per dedup key", func(t *testing.T) {
+		allId, err = CreateSubscription(tx, householdId, "https://example.com/all", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		choresId, err = CreateSubscription(tx, householdId, "https://example.com/chores", []EventType{EventChoreCompleted})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			err = Enqueue(tx, householdId, EventScreenTimeLimitReached, "limit:1:2024-03-01", map[string]int{"childId": 1}, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = Enqueue(tx, householdId, EventChoreCompleted, "", map[string]int{"childId": 1}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		all, err := FindAllDeliveriesBySubscriptionId(tx, allId, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chores, err := FindAllDeliveriesBySubscriptionId(tx, choresId, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(all) != 2 || all[0].Event.Type != EventChoreCompleted || all[1].Event.Type != EventScreenTimeLimitReached {
+			t.Fatalf("Unexpected deliveries of the subscription to all events: %+v", all)
+		}
+
+		if len(chores) != 1 || chores[0].Event.Type != EventChoreCompleted || string(chores[0].Event.Data) != `{"childId":1}` {
+			t.Fatalf("Unexpected deliveries of the subscription to chores: %+v", chores)
+		}
+
+		due, err := FindAllDueDeliveries(tx, now, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(due) != 3 {
+			t.Fatalf("Expected 3 due deliveries, received %d", len(due))
+		}
+	})
+
+	t.Run("retries failed deliveries until they are dead", func(t *testing.T) {
+		deliveries, err := FindAllDeliveriesBySubscriptionId(tx, choresId, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id := deliveries[0].Id
+		at := now
+
+		for attempt := 1; attempt <= MaxAttempts; attempt++ {
+			err = RecordAttempt(tx, id, 500, "receiver responded with status 500", at)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			delivery, err := FindOneDeliveryById(tx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if attempt < MaxAttempts && (delivery.Status != DeliveryPending || !delivery.NextAttemptAt.Equal(at.Add(Backoff(attempt)))) {
+				t.Fatalf("attempt %d: unexpected delivery %+v", attempt, delivery)
+			}
+
+			if attempt == MaxAttempts && (delivery.Status != DeliveryDead || delivery.Attempts != MaxAttempts || delivery.LastStatusCode != 500) {
+				t.Fatalf("Expected a dead delivery, received %+v", delivery)
+			}
+
+			at = delivery.NextAttemptAt
+		}
+
+		due, err := FindAllDueDeliveries(tx, now.Add(24*time.Hour), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, delivery := range due {
+			if delivery.Id == id {
+				t.Fatal("Expected the dead delivery not to be due")
+			}
+		}
+
+		redeliveryId, err := Redeliver(tx, id, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = RecordAttempt(tx, redeliveryId, 204, "", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		redelivery, err := FindOneDeliveryById(tx, redeliveryId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if redelivery.Status != DeliveryDelivered || redelivery.Event.Id != deliveries[0].Event.Id || redelivery.Attempts != 1 {
+			t.Fatalf("Expected the redelivery to be delivered, received %+v", redelivery)
+		}
+	})
+
+	t.Run("deletes subscriptions with their deliveries", func(t *testing.T) {
+		err = DeleteSubscription(tx, householdId, allId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = DeleteSubscription(tx, householdId, allId)
+		if !errors.Is(err, ErrSubscriptionWithThisIdDoesNotExist) {
+			t.Fatalf("Expected %v, received %v", ErrSubscriptionWithThisIdDoesNotExist, err)
+		}
+
+		deliveries, err := FindAllDeliveriesBySubscriptionId(tx, allId, 10)
+		if err != nil || len(deliveries) != 0 {
+			t.Fatalf("Expected no deliveries, received %+v, %v", deliveries, err)
+		}
+	})
+}
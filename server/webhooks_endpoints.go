@@ -0,0 +1,432 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domanscy.group/littlehelpers"
+	"domanscy.group/parental-controls/server/children"
+	"domanscy.group/parental-controls/server/webhooks"
+	"github.com/go-chi/chi"
+)
+
+// webhookDeliveriesLimit is the number of the newest deliveries shown in the delivery log.
+const webhookDeliveriesLimit = 100
+
+var ErrInvalidWebhookId = errors.New("invalid webhook id")
+var ErrWebhookNotFound = errors.New("webhook not found")
+var ErrInvalidWebhookDeliveryId = errors.New("invalid webhook delivery id")
+var ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+type WebhookChild struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type WebhookTamperEvent struct {
+	DeviceId   int       `json:"deviceId"`
+	Type       string    `json:"type"`
+	Detail     string    `json:"detail"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// WebhookEventData is the data of webhook payloads, besides the child it holds only the field of the event type.
+type WebhookEventData struct {
+	Child                WebhookChild                  `json:"child"`
+	TimeExtensionRequest *TimeExtensionRequestResponse `json:"timeExtensionRequest,omitempty"`
+	AccessRequest        *AccessRequestDecidedEvent    `json:"accessRequest,omitempty"`
+	ScreenTime           *ScreenTimeResponse           `json:"screenTime,omitempty"`
+	TamperEvent          *WebhookTamperEvent           `json:"tamperEvent,omitempty"`
+	ChoreCompletion      *ChoreCompletionResponse      `json:"choreCompletion,omitempty"`
+}
+
+// enqueueWebhookEvent queues the event about the child for the webhooks of its household, in the transaction of the change.
+func enqueueWebhookEvent(tx *sql.Tx, child *children.Model, eventType webhooks.EventType, dedupKey string, data WebhookEventData, now time.Time) error {
+	data.Child = WebhookChild{Id: child.Id, Name: child.Name}
+
+	return webhooks.Enqueue(tx, child.HouseholdId, eventType, dedupKey, data, now)
+}
+
+type WebhookResponse struct {
+	Id         int      `json:"id"`
+	Url        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	// Secret is only returned when the webhook is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newWebhookResponse(subscription *webhooks.Subscription) WebhookResponse {
+	response := WebhookResponse{
+		Id:         subscription.Id,
+		Url:        subscription.Url,
+		EventTypes: make([]string, 0, len(subscription.EventTypes)),
+		CreatedAt:  subscription.CreatedAt,
+	}
+
+	for _, eventType := range subscription.EventTypes {
+		response.EventTypes = append(response.EventTypes, string(eventType))
+	}
+
+	return response
+}
+
+type WebhookDeliveryResponse struct {
+	Id        int    `json:"id"`
+	EventId   int    `json:"eventId"`
+	EventType string `json:"eventType"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	// NextAttemptAt is null unless the delivery is pending.
+	NextAttemptAt *time.Time `json:"nextAttemptAt"`
+	// LastAttemptAt is null before the first attempt, LastStatusCode is 0 when the receiver did not respond.
+	LastAttemptAt  *time.Time `json:"lastAttemptAt"`
+	LastStatusCode int        `json:"lastStatusCode"`
+	LastError      string     `json:"lastError"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func newWebhookDeliveryResponse(delivery *webhooks.Delivery) WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		Id:             delivery.Id,
+		EventId:        delivery.Event.Id,
+		EventType:      string(delivery.Event.Type),
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+	}
+
+	if delivery.Status == webhooks.DeliveryPending {
+		nextAttemptAt := delivery.NextAttemptAt
+		response.NextAttemptAt = &nextAttemptAt
+	}
+
+	if !delivery.LastAttemptAt.IsZero() {
+		lastAttemptAt := delivery.LastAttemptAt
+		response.LastAttemptAt = &lastAttemptAt
+	}
+
+	return response
+}
+
+func parseWebhookIdAndHandleErrorIfInvalid(w http.ResponseWriter, r *http.Request) (int, error) {
+	webhookId, err := strconv.Atoi(chi.URLParam(r, "webhookId"))
+	if err != nil || webhookId <= 0 {
+		respondWith400(w, r, ErrInvalidWebhookId)
+		return 0, ErrInvalidWebhookId
+	}
+
+	return webhookId, nil
+}
+
+// findOwnedWebhookAndHandleError finds the webhook of a household of the authenticated parent, it responds and returns nil if there is none.
+func findOwnedWebhookAndHandleError(w http.ResponseWriter, r *http.Request, tx *sql.Tx, householdId int, webhookId int) *webhooks.Subscription {
+	household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+	if household == nil {
+		return nil
+	}
+
+	subscription, err := webhooks.FindOneSubscriptionById(tx, webhookId)
+	if err != nil {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith500(w, r, nil)
+		log.Printf("error occured while trying to find webhook: %v", err)
+		return nil
+	}
+
+	if subscription == nil || subscription.HouseholdId != household.Id {
+		err = littlehelpers.IfErrJoin(err, tx.Rollback())
+		respondWith404(w, r, ErrWebhookNotFound)
+		return nil
+	}
+
+	return subscription
+}
+
+func HttpHouseholdsWebhooksList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		subscriptions, err := webhooks.FindAllSubscriptionsByHouseholdId(tx, household.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find webhooks: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := make([]WebhookResponse, 0, len(subscriptions))
+		for i := range subscriptions {
+			response = append(response, newWebhookResponse(&subscriptions[i]))
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
+
+type CreateWebhookRequestBody struct {
+	Url        string   `json:"url" validate:"required,url,length=..2048"`
+	EventTypes []string `json:"eventTypes" validate:"oneof=time_extension_requested time_extension_decided access_requested access_request_decided screen_time_limit_reached tamper_detected chore_completed"`
+}
+
+// HttpHouseholdsWebhooksCreate subscribes the url to events of the household, without event types to all of them.
+// The response holds the secret payloads are signed with, it is not shown again.
+func HttpHouseholdsWebhooksCreate(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		requestBody, err := decodeJsonRequestBody[CreateWebhookRequestBody](w, r)
+		if err != nil {
+			return
+		}
+
+		eventTypes := make([]webhooks.EventType, 0, len(requestBody.EventTypes))
+		for _, eventType := range requestBody.EventTypes {
+			eventTypes = append(eventTypes, webhooks.EventType(eventType))
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		household := findOwnedHouseholdAndHandleError(w, r, tx, householdId)
+		if household == nil {
+			return
+		}
+
+		webhookId, err := webhooks.CreateSubscription(tx, household.Id, requestBody.Url, eventTypes)
+		if errors.Is(err, webhooks.ErrInvalidUrl) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith400(w, r, err)
+			return
+		} else if errors.Is(err, webhooks.ErrTooManySubscriptions) {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith409(w, r, err)
+			return
+		} else if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to create webhook: %v", err)
+			return
+		}
+
+		subscription, err := webhooks.FindOneSubscriptionById(tx, webhookId)
+		if err != nil || subscription == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find created webhook: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := newWebhookResponse(subscription)
+		response.Secret = subscription.Secret
+
+		respondWithJson(w, r, http.StatusCreated, response)
+	}
+}
+
+func HttpHouseholdsWebhooksDelete(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		webhookId, err := parseWebhookIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		subscription := findOwnedWebhookAndHandleError(w, r, tx, householdId, webhookId)
+		if subscription == nil {
+			return
+		}
+
+		err = webhooks.DeleteSubscription(tx, subscription.HouseholdId, subscription.Id)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to delete webhook: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+// HttpHouseholdsWebhooksDeliveriesList is the delivery log of the webhook, the newest deliveries first.
+func HttpHouseholdsWebhooksDeliveriesList(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		webhookId, err := parseWebhookIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		subscription := findOwnedWebhookAndHandleError(w, r, tx, householdId, webhookId)
+		if subscription == nil {
+			return
+		}
+
+		deliveries, err := webhooks.FindAllDeliveriesBySubscriptionId(tx, subscription.Id, webhookDeliveriesLimit)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find webhook deliveries: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		response := make([]WebhookDeliveryResponse, 0, len(deliveries))
+		for i := range deliveries {
+			response = append(response, newWebhookDeliveryResponse(&deliveries[i]))
+		}
+
+		respondWithJson(w, r, http.StatusOK, response)
+	}
+}
+
+// HttpHouseholdsWebhooksDeliveriesRedeliver queues the event of the delivery again, dead deliveries included.
+// The new delivery is sent by the next run of the dispatcher.
+func HttpHouseholdsWebhooksDeliveriesRedeliver(_ *ServerConfig, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deliveryId, err := strconv.Atoi(chi.URLParam(r, "deliveryId"))
+		if err != nil || deliveryId <= 0 {
+			respondWith400(w, r, ErrInvalidWebhookDeliveryId)
+			return
+		}
+
+		householdId, err := parseHouseholdIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		webhookId, err := parseWebhookIdAndHandleErrorIfInvalid(w, r)
+		if err != nil {
+			return
+		}
+
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to start a transaction: %v", err)
+			return
+		}
+
+		subscription := findOwnedWebhookAndHandleError(w, r, tx, householdId, webhookId)
+		if subscription == nil {
+			return
+		}
+
+		delivery, err := webhooks.FindOneDeliveryById(tx, deliveryId)
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find webhook delivery: %v", err)
+			return
+		}
+
+		if delivery == nil || delivery.SubscriptionId != subscription.Id {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith404(w, r, ErrWebhookDeliveryNotFound)
+			return
+		}
+
+		redeliveryId, err := webhooks.Redeliver(tx, delivery.Id, time.Now())
+		if err != nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to redeliver webhook delivery: %v", err)
+			return
+		}
+
+		redelivery, err := webhooks.FindOneDeliveryById(tx, redeliveryId)
+		if err != nil || redelivery == nil {
+			err = littlehelpers.IfErrJoin(err, tx.Rollback())
+			respondWith500(w, r, nil)
+			log.Printf("error occured while trying to find created webhook delivery: %v", err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("failed to commit the transaction: %v", err)
+			respondWith500(w, r, nil)
+			return
+		}
+
+		respondWithJson(w, r, http.StatusAccepted, newWebhookDeliveryResponse(redelivery))
+	}
+}
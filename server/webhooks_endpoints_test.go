@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"domanscy.group/parental-controls/server/rewards"
+	"domanscy.group/parental-controls/server/webhooks"
+	"github.com/go-chi/chi"
+)
+
+func TestHttpHouseholdsWebhooks(t *testing.T) {
+	db := openDatabase(t)
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	family := createTestFamily(t, db, "parent@localhost.local")
+	stranger := createTestFamily(t, db, "stranger@localhost.local")
+
+	tx, err := db.Begin()
+	doTFatalIfErr(t, err)
+
+	choreId, err := rewards.CreateChore(tx, family.childId, "Dishes", 10, rewards.RecurrenceDaily)
+	doTFatalIfErr(t, err)
+	doTFatalIfErr(t, tx.Commit())
+
+	var mu sync.Mutex
+	var received []*http.Request
+	var receivedBodies [][]byte
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		received = append(received, r)
+		receivedBodies = append(receivedBodies, body)
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	router := chi.NewRouter()
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/households/{householdId}/webhooks", HttpHouseholdsWebhooksList(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/households/{householdId}/webhooks", HttpHouseholdsWebhooksCreate(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Delete("/households/{householdId}/webhooks/{webhookId}", HttpHouseholdsWebhooksDelete(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Get("/households/{householdId}/webhooks/{webhookId}/deliveries", HttpHouseholdsWebhooksDeliveriesList(testingCfg, db))
+	router.With(AuthenticateBearerToken(testingCfg)).Post("/households/{householdId}/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", HttpHouseholdsWebhooksDeliveriesRedeliver(testingCfg, db))
+	router.With(AuthenticateDeviceToken(db)).Post("/device/chores/{choreId}/completions", HttpDeviceChoreCompletionsCreate(testingCfg, db))
+
+	sendParentRequest := func(userId int, method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(body))
+		request.Header.Set("Authorization", bearerHeaderForUser(t, userId))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	webhooksPath := fmt.Sprintf("/households/%d/webhooks", family.householdId)
+
+	var webhook WebhookResponse
+
+	t.Run("creates webhooks", func(t *testing.T) {
+		invalid := []string{
+			`{"url": "mailto:parent@localhost.local"}`,
+			`{"url": "https://example.com/hook", "eventTypes": ["everything"]}`,
+		}
+
+		for _, body := range invalid {
+			recorder := sendParentRequest(family.userId, http.MethodPost, webhooksPath, body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s: got %d, want %d", body, recorder.Code, http.StatusBadRequest)
+			}
+		}
+
+		recorder := sendParentRequest(stranger.userId, http.MethodPost, webhooksPath, fmt.Sprintf(`{"url": "%s"}`, receiver.URL))
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, webhooksPath, fmt.Sprintf(`{"url": "%s", "eventTypes": ["chore_completed"]}`, receiver.URL))
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &webhook))
+
+		if webhook.Secret == "" || len(webhook.EventTypes) != 1 || webhook.EventTypes[0] != "chore_completed" {
+			t.Fatalf("Unexpected response: %+v", webhook)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, webhooksPath, "")
+
+		var listed []WebhookResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &listed))
+
+		if len(listed) != 1 || listed[0].Id != webhook.Id || listed[0].Secret != "" {
+			t.Fatalf("Expected the webhook without its secret, received %+v", listed)
+		}
+	})
+
+	deliveriesPath := fmt.Sprintf("%s/%d/deliveries", webhooksPath, webhook.Id)
+
+	var delivery WebhookDeliveryResponse
+
+	t.Run("delivers signed events and logs the deliveries", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:8080/device/chores/%d/completions", choreId), nil)
+		request.Header.Set("Authorization", "Device "+family.deviceToken)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+		}
+
+		doTFatalIfErr(t, webhooks.NewDispatcher().DeliverDue(context.Background(), db, time.Now()))
+
+		if len(received) != 1 {
+			t.Fatalf("Expected one delivered event, received %d", len(received))
+		}
+
+		timestamp, err := strconv.ParseInt(received[0].Header.Get(webhooks.HeaderTimestamp), 10, 64)
+		doTFatalIfErr(t, err)
+
+		if received[0].Header.Get(webhooks.HeaderSignature) != webhooks.Sign(webhook.Secret, timestamp, receivedBodies[0]) {
+			t.Fatalf("Signature %s does not match", received[0].Header.Get(webhooks.HeaderSignature))
+		}
+
+		var payload struct {
+			Type string `json:"type"`
+			Data struct {
+				Child           WebhookChild            `json:"child"`
+				ChoreCompletion ChoreCompletionResponse `json:"choreCompletion"`
+			} `json:"data"`
+		}
+		doTFatalIfErr(t, json.Unmarshal(receivedBodies[0], &payload))
+
+		if payload.Type != "chore_completed" || payload.Data.Child.Id != family.childId || payload.Data.ChoreCompletion.ChoreId != choreId {
+			t.Fatalf("Unexpected payload: %s", receivedBodies[0])
+		}
+
+		recorder = sendParentRequest(stranger.userId, http.MethodGet, deliveriesPath, "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, deliveriesPath, "")
+
+		var deliveries []WebhookDeliveryResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &deliveries))
+
+		if len(deliveries) != 1 || deliveries[0].Status != "delivered" || deliveries[0].LastStatusCode != http.StatusOK || deliveries[0].NextAttemptAt != nil {
+			t.Fatalf("Unexpected deliveries: %+v", deliveries)
+		}
+
+		delivery = deliveries[0]
+	})
+
+	t.Run("redelivers events", func(t *testing.T) {
+		recorder := sendParentRequest(family.userId, http.MethodPost, fmt.Sprintf("%s/%d/redeliver", deliveriesPath, delivery.Id+100), "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodPost, fmt.Sprintf("%s/%d/redeliver", deliveriesPath, delivery.Id), "")
+		if recorder.Code != http.StatusAccepted {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusAccepted, recorder.Body.String())
+		}
+
+		var redelivery WebhookDeliveryResponse
+		doTFatalIfErr(t, json.Unmarshal(recorder.Body.Bytes(), &redelivery))
+
+		if redelivery.Id == delivery.Id || redelivery.EventId != delivery.EventId || redelivery.Status != "pending" {
+			t.Fatalf("Unexpected redelivery: %+v", redelivery)
+		}
+
+		doTFatalIfErr(t, webhooks.NewDispatcher().DeliverDue(context.Background(), db, time.Now()))
+
+		if len(received) != 2 || string(receivedBodies[1]) != string(receivedBodies[0]) {
+			t.Fatalf("Expected the same payload delivered again, received %d deliveries", len(received))
+		}
+	})
+
+	t.Run("deletes webhooks", func(t *testing.T) {
+		path := fmt.Sprintf("%s/%d", webhooksPath, webhook.Id)
+
+		recorder := sendParentRequest(stranger.userId, http.MethodDelete, path, "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodDelete, path, "")
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d, response body: %s", recorder.Code, http.StatusNoContent, recorder.Body.String())
+		}
+
+		recorder = sendParentRequest(family.userId, http.MethodGet, deliveriesPath, "")
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Got %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	})
+}